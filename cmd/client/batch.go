@@ -0,0 +1,192 @@
+// cmd/client/batch.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/internal/client/network"
+	"time"
+)
+
+// exit codes of the non-interactive mode
+const (
+    exitOK    = 0
+    exitError = 1
+    exitUsage = 2
+    exitAuth  = 3
+)
+
+// how long the non-interactive mode waits for each server answer
+const batchTimeout = 10 * time.Second
+
+// batchOptions are the command-line flags of the non-interactive mode
+type batchOptions struct {
+    server       string
+    username     string
+    passwordFile string
+    send         string
+    to           string
+    history      int
+}
+
+// runBatch connects, sends the message and/or prints the history, then
+// returns the exit code of the process
+func runBatch(opts batchOptions) int {
+    if opts.username == "" || opts.passwordFile == "" {
+        fmt.Fprintln(os.Stderr, "textual: --user and --password-file are required with --send and --history")
+        return exitUsage
+    }
+    if opts.server == "" {
+        opts.server = "localhost:8080"
+    }
+
+    password, err := readPassword(opts.passwordFile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        return exitUsage
+    }
+
+    events := make(chan interface{}, 100)
+    handler, err := dialBatch(opts.server, events)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        return exitError
+    }
+    defer handler.Close()
+
+    if err := authenticate(handler, opts.username, password); err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        return exitAuth
+    }
+
+    // the recipient is given by username, the server knows its ID
+    var recipientID *string
+    if opts.to != "" {
+        if err := handler.LookupUser(opts.to); err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+        event, err := waitFor(events, func(event interface{}) bool {
+            _, ok := event.(models.UserFound)
+            return ok
+        })
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+        id := event.(models.UserFound).UserID
+        recipientID = &id
+    }
+
+    if opts.send != "" {
+        if err := handler.SendMessage(opts.send, recipientID, nil); err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+        // the server echoes the message once it is stored
+        _, err := waitFor(events, func(event interface{}) bool {
+            msg, ok := event.(models.Message)
+            return ok && msg.SenderID == handler.UserID() && msg.Content == opts.send
+        })
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "textual: message not delivered: %v\n", err)
+            return exitError
+        }
+    }
+
+    if opts.history > 0 {
+        conversation := ""
+        if recipientID != nil {
+            conversation = *recipientID
+        }
+        if err := handler.LoadConversation(conversation, "", "", opts.history); err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+        event, err := waitFor(events, func(event interface{}) bool {
+            history, ok := event.(models.HistoryLoaded)
+            return ok && history.RecipientID == conversation && history.GroupID == ""
+        })
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+
+        // the page is newest first
+        messages := event.(models.HistoryLoaded).Messages
+        for i := len(messages) - 1; i >= 0; i-- {
+            msg := messages[i]
+            fmt.Printf("%s %s: %s\n", msg.SentAt.Local().Format("2006-01-02 15:04"), msg.SenderName, msg.Content)
+        }
+    }
+
+    return exitOK
+}
+
+// dialBatch connects to the server, messages, events and errors all go to
+// the events channel
+func dialBatch(serverAddr string, events chan interface{}) (*network.ConnectionHandler, error) {
+    conn, err := network.NewConnection(serverAddr)
+    if err != nil {
+        return nil, fmt.Errorf("connection error: %v", err)
+    }
+
+    forward := func(event interface{}) {
+        select {
+        case events <- event:
+        default:
+            logging.Warnf("Dropping event %T: queue full", event)
+        }
+    }
+
+    handler := network.NewConnectionHandler(conn.GetUnderlyingConn())
+    handler.SetErrorHandler(func(err error) { forward(err) })
+    handler.SetMessageHandler(func(msg models.Message) { forward(msg) })
+    handler.SetEventHandler(forward)
+    handler.Start()
+    return handler, nil
+}
+
+// waitFor returns the first event accepted by match, a server error stops
+// the wait
+func waitFor(events <-chan interface{}, match func(interface{}) bool) (interface{}, error) {
+    timeout := time.After(batchTimeout)
+    for {
+        select {
+        case event := <-events:
+            if err, ok := event.(error); ok {
+                return nil, err
+            }
+            if match(event) {
+                return event, nil
+            }
+        case <-timeout:
+            return nil, fmt.Errorf("no answer from the server")
+        }
+    }
+}
+
+// readPassword reads the first line of the file, "-" reads stdin
+func readPassword(path string) (string, error) {
+    var data []byte
+    var err error
+    if path == "-" {
+        data, err = io.ReadAll(os.Stdin)
+    } else {
+        data, err = os.ReadFile(path)
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to read password: %v", err)
+    }
+
+    password, _, _ := strings.Cut(string(data), "\n")
+    password = strings.TrimSuffix(password, "\r")
+    if password == "" {
+        return "", fmt.Errorf("empty password in %s", path)
+    }
+    return password, nil
+}
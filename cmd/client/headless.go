@@ -0,0 +1,235 @@
+// cmd/client/headless.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// headlessEvent is a line written on stdout in headless mode
+type headlessEvent struct {
+    Type        string `json:"type"` // ready, message, message_edit, error
+    ID          string `json:"id,omitempty"`
+    SenderID    string `json:"sender_id,omitempty"`
+    Sender      string `json:"sender,omitempty"`
+    Content     string `json:"content,omitempty"`
+    RecipientID string `json:"recipient_id,omitempty"`
+    GroupID     string `json:"group_id,omitempty"`
+    SentAt      string `json:"sent_at,omitempty"`
+    Error       string `json:"error,omitempty"`
+}
+
+// headlessCommand is a line read on stdin, a line that is not JSON is sent
+// to the global chat as is
+type headlessCommand struct {
+    Content     string `json:"content"`
+    To          string `json:"to,omitempty"` // username
+    RecipientID string `json:"recipient_id,omitempty"`
+    GroupID     string `json:"group_id,omitempty"`
+}
+
+// disconnected is queued with the events when the server closes the connection
+type disconnected struct{}
+
+// runHeadless relays the messages between the server and stdin/stdout, or
+// the hook command when set, until the input is closed
+func runHeadless(opts batchOptions, hook string) int {
+    if opts.username == "" || opts.passwordFile == "" {
+        fmt.Fprintln(os.Stderr, "textual: --user and --password-file are required with --headless")
+        return exitUsage
+    }
+    // stdin carries the messages to send
+    if opts.passwordFile == "-" && hook == "" {
+        fmt.Fprintln(os.Stderr, "textual: --password-file - needs --hook in headless mode")
+        return exitUsage
+    }
+    if opts.server == "" {
+        opts.server = "localhost:8080"
+    }
+
+    password, err := readPassword(opts.passwordFile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        return exitUsage
+    }
+
+    var input io.Reader = os.Stdin
+    var output io.Writer = os.Stdout
+    if hook != "" {
+        cmd := exec.Command("sh", "-c", hook)
+        cmd.Stderr = os.Stderr
+        if output, err = cmd.StdinPipe(); err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+        if input, err = cmd.StdoutPipe(); err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            return exitError
+        }
+        if err := cmd.Start(); err != nil {
+            fmt.Fprintf(os.Stderr, "textual: failed to start hook: %v\n", err)
+            return exitError
+        }
+        defer cmd.Wait()
+        defer output.(io.Closer).Close()
+    }
+
+    events := make(chan interface{}, 100)
+    handler, err := dialBatch(opts.server, events)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        return exitError
+    }
+    defer handler.Close()
+    handler.SetDisconnectHandler(func() {
+        select {
+        case events <- disconnected{}:
+        default:
+        }
+    })
+
+    if err := authenticate(handler, opts.username, password); err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        return exitAuth
+    }
+
+    commands := make(chan headlessCommand)
+    go readCommands(input, commands)
+
+    encoder := json.NewEncoder(output)
+    write := func(event headlessEvent) {
+        if err := encoder.Encode(event); err != nil {
+            logging.Errorf("Failed to write headless event: %v", err)
+        }
+    }
+    write(headlessEvent{Type: "ready", SenderID: handler.UserID(), Sender: handler.Username()})
+
+    // usernames resolved by the server, and the messages waiting for them
+    userIDs := make(map[string]string)
+    pending := make(map[string][]string)
+
+    send := func(content string, recipientID, groupID string) {
+        var recipient, group *string
+        if recipientID != "" {
+            recipient = &recipientID
+        }
+        if groupID != "" {
+            group = &groupID
+        }
+        if err := handler.SendMessage(content, recipient, group); err != nil {
+            write(headlessEvent{Type: "error", Error: err.Error()})
+        }
+    }
+
+    for {
+        select {
+        case command, ok := <-commands:
+            if !ok {
+                // the input is closed, give the last messages time to leave
+                time.Sleep(200 * time.Millisecond)
+                return exitOK
+            }
+            switch {
+            case command.To != "" && userIDs[command.To] == "":
+                if len(pending[command.To]) == 0 {
+                    if err := handler.LookupUser(command.To); err != nil {
+                        write(headlessEvent{Type: "error", Error: err.Error()})
+                        continue
+                    }
+                }
+                pending[command.To] = append(pending[command.To], command.Content)
+            case command.To != "":
+                send(command.Content, userIDs[command.To], "")
+            default:
+                send(command.Content, command.RecipientID, command.GroupID)
+            }
+
+        case event := <-events:
+            switch event := event.(type) {
+            case models.Message:
+                // the bot's own messages are echoed
+                if event.SenderID == handler.UserID() {
+                    continue
+                }
+                write(messageEvent("message", event))
+            case models.MessageEdited:
+                write(messageEvent("message_edit", event.Message))
+            case models.UserFound:
+                for name, contents := range pending {
+                    if !strings.EqualFold(name, event.Username) {
+                        continue
+                    }
+                    userIDs[name] = event.UserID
+                    for _, content := range contents {
+                        send(content, event.UserID, "")
+                    }
+                    delete(pending, name)
+                }
+            case error:
+                // the messages waiting for an unknown user are dropped
+                if protoErr, ok := event.(protocol.Error); ok && protoErr.Code == protocol.ErrCodeUserNotFound {
+                    pending = make(map[string][]string)
+                }
+                write(headlessEvent{Type: "error", Error: event.Error()})
+            case models.Disconnected:
+                // the connection closes next
+                fmt.Fprintf(os.Stderr, "textual: disconnected by a moderator: %s\n", event.Reason)
+                return exitError
+            case disconnected:
+                fmt.Fprintln(os.Stderr, "textual: connection closed by the server")
+                return exitError
+            }
+        }
+    }
+}
+
+func messageEvent(kind string, msg models.Message) headlessEvent {
+    event := headlessEvent{
+        Type:     kind,
+        ID:       msg.ID,
+        SenderID: msg.SenderID,
+        Sender:   msg.SenderName,
+        Content:  msg.Content,
+        SentAt:   msg.SentAt.UTC().Format(time.RFC3339),
+    }
+    if msg.RecipientID != nil {
+        event.RecipientID = *msg.RecipientID
+    }
+    if msg.GroupID != nil {
+        event.GroupID = *msg.GroupID
+    }
+    return event
+}
+
+// readCommands decodes the input lines until it is closed
+func readCommands(input io.Reader, commands chan<- headlessCommand) {
+    defer close(commands)
+
+    scanner := bufio.NewScanner(input)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+
+        var command headlessCommand
+        if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &command) != nil {
+            command = headlessCommand{Content: line}
+        }
+        if command.Content != "" {
+            commands <- command
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        logging.Errorf("Failed to read headless input: %v", err)
+    }
+}
@@ -2,12 +2,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
+	"textual/internal/chaos"
+	"textual/internal/client/config"
+	"textual/internal/client/e2ee"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
 	"textual/internal/client/models"
 	"textual/internal/client/network"
 	"textual/internal/client/tui"
+	"textual/internal/wiretap"
+	"textual/pkg/protocol"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -19,14 +29,58 @@ type AppModel struct {
     chatModel   tui.Model
     connection  *network.ConnectionHandler
     isLoggedIn bool
+    config     config.Config
     err        error
+    // a login waits for the server, the next ones are ignored
+    connecting bool
+    // holds the events of the connection until the login completes
+    inbox      *inbox
+}
+
+// authResultMsg ends a login, once the server answered or the wait timed out
+type authResultMsg struct {
+    login   tui.LoginSuccessMsg
+    handler *network.ConnectionHandler
+    err     error
+}
+
+// inbox holds the events of the connection received before the login
+// completes, the chat model doesn't exist yet
+type inbox struct {
+    mu      sync.Mutex
+    holding bool
+    held    []tea.Msg
+}
+
+func (b *inbox) send(msg tea.Msg) {
+    b.mu.Lock()
+    if b.holding {
+        b.held = append(b.held, msg)
+        b.mu.Unlock()
+        return
+    }
+    b.mu.Unlock()
+    if p != nil {
+        p.Send(msg)
+    }
+}
+
+// release returns the events held, the next ones go to the program
+func (b *inbox) release() []tea.Msg {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.holding = false
+    held := b.held
+    b.held = nil
+    return held
 }
 
 
-func NewAppModel() AppModel {
+func NewAppModel(cfg config.Config) AppModel {
     return AppModel{
-        loginModel: tui.NewLoginModel(),
+        loginModel: tui.NewLoginModel(cfg.Profiles, cfg.LastProfile),
         chatModel:  tui.NewModel(nil),
+        config:     cfg,
     }
 }
 
@@ -41,30 +95,61 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
     switch msg := msg.(type) {
     case tui.LoginSuccessMsg:
-        var err error
-        serverAddr := fmt.Sprintf("%s:%s", msg.ServerHost, msg.ServerPort)
-        m.connection, err = m.setupConnection(msg.Username, msg.Password, serverAddr)
-        if err != nil {
-            log.Printf("Setup connection error: %v", err)
-            newModel, newCmd := m.loginModel.Update(tui.LoginErrorMsg{Error: err})
+        if m.connecting {
+            return m, nil
+        }
+        m.connecting = true
+        m.inbox = &inbox{holding: true}
+        return m, connect(msg, m.inbox)
+
+    case authResultMsg:
+        m.connecting = false
+        if msg.err != nil {
+            logging.Errorf("Setup connection error: %v", msg.err)
+            if msg.handler != nil {
+                msg.handler.Close()
+            }
+            newModel, newCmd := m.loginModel.Update(tui.LoginErrorMsg{Error: msg.err})
             if loginModel, ok := newModel.(tui.LoginModel); ok {
                 m.loginModel = loginModel
                 return m, newCmd
             }
             return m, nil
         }
+        m.connection = msg.handler
         m.isLoggedIn = true
 
+        if m.config.Encryption {
+            if err := enableEncryption(m.connection); err != nil {
+                logging.Errorf("Encryption disabled: %v", err)
+            }
+        }
+        logging.Infof("Connection setup complete, user: %s", m.connection.UserID())
+
+        // remember the server for the next login
+        m.config.SaveProfile(msg.login.Profile)
+        if err := config.Save(m.config); err != nil {
+            logging.Errorf("Failed to save profile: %v", err)
+        }
+
         // conf of callback to send messages
-        sendMessage := func(content string, recipientID *string, groupID *string) error {
-            return m.connection.SendMessage(content, recipientID, groupID)
+        sendMessage := func(content, clientID string, recipientID, groupID *string) error {
+            return m.connection.SendMessageWithID(content, clientID, recipientID, groupID)
         }
 
         // init chat model
         m.chatModel = tui.NewModel(sendMessage)
+        m.chatModel.SetConfig(m.config)
         m.chatModel.SetConnection(m.connection)
 
-        return m, nil
+        // the events received meanwhile, in their order
+        cmds := []tea.Cmd{m.chatModel.Init()}
+        for _, held := range m.inbox.release() {
+            newModel, newCmd := m.Update(held)
+            m = newModel.(AppModel)
+            cmds = append(cmds, newCmd)
+        }
+        return m, tea.Batch(cmds...)
 
     case models.MessageReceived:
         if m.isLoggedIn {
@@ -141,9 +226,23 @@ func (m AppModel) View() string {
     return m.loginModel.View()
 }
 
+// connect dials the server and sends the credentials, its message comes as
+// soon as the server answers
+func connect(login tui.LoginSuccessMsg, events *inbox) tea.Cmd {
+    return func() tea.Msg {
+        serverAddr := fmt.Sprintf("%s:%s", login.ServerHost, login.ServerPort)
+        handler, err := setupConnection(login.Username, serverAddr, events)
+        if err != nil {
+            return authResultMsg{login: login, err: err}
+        }
+        err = authenticate(handler, login.Username, login.Password)
+        return authResultMsg{login: login, handler: handler, err: err}
+    }
+}
+
 // setup connection with serv
-func (m *AppModel) setupConnection(username, password, serverAddr string) (*network.ConnectionHandler, error) {
-    log.Printf("Setting up connection for user: %s to server: %s", username, serverAddr)
+func setupConnection(username, serverAddr string, events *inbox) (*network.ConnectionHandler, error) {
+    logging.Infof("Setting up connection for user: %s to server: %s", username, serverAddr)
     
     
     conn, err := network.NewConnection(serverAddr)
@@ -156,73 +255,191 @@ func (m *AppModel) setupConnection(username, password, serverAddr string) (*netw
     
     
     handler.SetErrorHandler(func(err error) {
-        log.Printf("Error received: %v", err)
-        if p != nil {
-            p.Send(models.ErrorMsg{Error: err.Error()})
+        logging.Errorf("Error received: %v", err)
+        event := models.ErrorMsg{Error: err.Error()}
+        if protoErr, ok := err.(protocol.Error); ok && protoErr.Code == protocol.ErrCodeRateLimited {
+            event.RetryAfter = protoErr.RetryAfter
         }
+        events.send(event)
     })
 
-    // temp conf
     handler.SetMessageHandler(func(msg models.Message) {
-        log.Printf("Message received in main: %+v", msg)
-        if p != nil {
-            if msg.Content == "Friend request" {
-                p.Send(models.FriendRequestReceived{
-                    Request: models.FriendRequest{
-                        ID:        msg.ID,
-                        FromUser:  msg.SenderID,
-                        ToUser:    "",
-                        Status:    "pending",
-                        CreatedAt: msg.SentAt,
-                    },
-                })
-            } else {
-                p.Send(models.MessageReceived{Message: msg})
-            }
-        }
+        logging.Debugf("Message received in main: %+v", msg)
+        events.send(models.MessageReceived{Message: msg})
     })
 
+    handler.SetEventHandler(func(event interface{}) {
+        events.send(event)
+    })
+
+    // the connection is restored in the background when it drops
+    handler.SetReconnect(serverAddr)
+
     // start the handler
     handler.Start()
+    return handler, nil
+}
 
-    // try to authenticate
-    if err := handler.SendAuthRequest(username, password); err != nil {
-        return nil, fmt.Errorf("authentication error: %v", err)
+// enableEncryption loads the keys of the user on this device, they are
+// created the first time
+func enableEncryption(handler *network.ConnectionHandler) error {
+    path, err := config.KeysPath(handler.UserID())
+    if err != nil {
+        return err
     }
-
-    // wait for authentication
-    startTime := time.Now()
-    for !handler.IsAuthenticated() {
-        if time.Since(startTime) > 5*time.Second {
-            return nil, fmt.Errorf("authentication timeout")
-        }
-        // check for auth error
-        if handler.GetAuthError() != nil {
-            return nil, handler.GetAuthError()
-        }
-        time.Sleep(100 * time.Millisecond)
+    keys, err := e2ee.Open(path)
+    if err != nil {
+        return err
     }
+    handler.SetEncryption(keys)
+    return nil
+}
 
-    log.Printf("Connection setup complete, authenticated: %v", handler.IsAuthenticated())
-    return handler, nil
+// authenticate sends the credentials and waits for the server answer
+func authenticate(handler *network.ConnectionHandler, username, password string) error {
+    if err := handler.SendAuthRequest(username, password); err != nil {
+        return fmt.Errorf("authentication error: %v", err)
+    }
+    return handler.WaitAuth(authTimeout)
 }
 
 var p *tea.Program
 
+// how long the server has to answer the credentials
+const authTimeout = 5 * time.Second
+
+// the log file is rotated at 5 MB, keeping client.log.1 to client.log.3
+const (
+    maxLogSize = 5 << 20
+    logBackups = 3
+)
+
 func main() {
-    // log file
-    logFile, err := os.OpenFile("client.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+    // "textual standalone" runs a server in the process and connects to it
+    standalone := len(os.Args) > 1 && os.Args[1] == "standalone"
+    if standalone {
+        os.Args = append(os.Args[:1], os.Args[2:]...)
+    }
+
+    server := flag.String("server", "", "server address (host:port)")
+    user := flag.String("user", "", "username")
+    passwordFile := flag.String("password-file", "", "file holding the password, - reads it from stdin")
+    send := flag.String("send", "", "send this message and exit")
+    to := flag.String("to", "", "username receiving --send or whose --history is printed, the global chat when empty")
+    history := flag.Int("history", 0, "print the last n messages of the conversation and exit")
+    headless := flag.Bool("headless", false, "no interface: incoming messages are written on stdout as JSON lines, stdin lines are sent")
+    logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+    hook := flag.String("hook", "", "with --headless, shell command reading the messages on its stdin and writing replies on its stdout")
+    listen := flag.String("listen", "127.0.0.1:0", "with standalone, address of the server, :8080 lets the LAN join")
+    tapPath := flag.String("wire-tap", "", "record every frame sent and received in this file, passwords redacted, to replay with cmd/replay")
+    chaosSpec := flag.String("chaos", "", "test over a bad network, such as latency=200ms,jitter=50ms,partial=0.2,drop=0.01,seed=1")
+    flag.Parse()
+
+    if standalone && (*server != "" || *headless || *send != "" || *history > 0) {
+        fmt.Fprintln(os.Stderr, "textual: standalone runs the interface on its own server, without --server, --headless, --send or --history")
+        os.Exit(exitUsage)
+    }
+
+    level, err := logging.ParseLevel(*logLevel)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+        os.Exit(exitUsage)
+    }
+    logging.SetLevel(level)
+
+    if *tapPath != "" {
+        tap, err := wiretap.Open(*tapPath, wiretap.Client)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "textual: wire tap: %v\n", err)
+            os.Exit(exitError)
+        }
+        defer tap.Close()
+        network.SetWireTap(tap)
+    }
+    if *chaosSpec != "" {
+        chaosConfig, err := chaos.Parse(*chaosSpec)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "textual: %v\n", err)
+            os.Exit(exitUsage)
+        }
+        chaosNetwork := chaos.New(chaosConfig)
+        network.SetChaos(chaosNetwork)
+        logging.Infof("Connections go through a bad network: %s", chaosNetwork.Config())
+    }
+
+    // log file in ~/.local/state/textual, the last lines are also kept for /debug
+    logPath, err := config.LogPath()
+    if err != nil {
+        log.Fatal("Error finding log file:", err)
+    }
+    logFile, err := logging.OpenRotating(logPath, maxLogSize, logBackups)
     if err != nil {
         log.Fatal("Error opening log file:", err)
     }
     defer logFile.Close()
-    log.SetOutput(logFile)
+    log.SetOutput(io.MultiWriter(logFile, logging.Recent))
+
+    // colors from ~/.config/textual/config.toml
+    cfg, err := config.Load()
+    if err != nil {
+        logging.Errorf("Failed to load config: %v", err)
+    }
+    theme, err := tui.ThemeFromConfig(cfg)
+    if err != nil {
+        logging.Errorf("Invalid theme: %v", err)
+    }
+    tui.ApplyTheme(theme)
+    tui.SetHyperlinks(cfg.Hyperlinks)
+    tui.SetLinkPreviews(cfg.LinkPreviews)
+    tui.SetTimeline(cfg.DaySeparators, cfg.RelativeTimes)
+    tui.SetIdentity(cfg.UserColors, cfg.Avatars)
+    if err := i18n.SetLocale(cfg.Locale); err != nil {
+        logging.Errorf("Invalid locale: %v", err)
+    }
+    if err := i18n.SetClock(cfg.TimeFormat); err != nil {
+        logging.Errorf("Invalid time format: %v", err)
+    }
+    if err := i18n.SetTimezone(cfg.Timezone); err != nil {
+        logging.Errorf("Invalid timezone: %v", err)
+    }
+
+    // --headless, --send and --history run without the TUI
+    opts := batchOptions{
+        server:       *server,
+        username:     *user,
+        passwordFile: *passwordFile,
+        send:         *send,
+        to:           *to,
+        history:      *history,
+    }
+    if *headless {
+        os.Exit(runHeadless(opts, *hook))
+    }
+    if *send != "" || *history > 0 {
+        os.Exit(runBatch(opts))
+    }
+
+    if standalone {
+        srv, err := startStandalone(*listen)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "textual: standalone server: %v\n", err)
+            os.Exit(exitError)
+        }
+        defer srv.Stop()
+        logging.Infof("Standalone server listening on %s", srv.addr)
+        *server = srv.addr
+    }
 
     // init app model
-    model := NewAppModel()
+    model := NewAppModel(cfg)
+    model.loginModel.Prefill(*server, *user)
 
     // start program
-    p = tea.NewProgram(model, tea.WithAltScreen())
+    programOptions := []tea.ProgramOption{tea.WithAltScreen(), tea.WithReportFocus()}
+    if cfg.Mouse {
+        programOptions = append(programOptions, tea.WithMouseCellMotion())
+    }
+    p = tea.NewProgram(model, programOptions...)
     
     if err := p.Start(); err != nil {
         log.Fatal("Error running program:", err)
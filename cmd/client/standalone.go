@@ -0,0 +1,76 @@
+// cmd/client/standalone.go
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"textual/internal/server/bootstrap"
+	serverconfig "textual/internal/server/config"
+	"textual/internal/server/database"
+)
+
+// standaloneServer is the chat server "textual standalone" runs in the
+// process of the client, its accounts and messages kept in memory
+type standaloneServer struct {
+    db     database.Store
+    cancel context.CancelFunc
+    done   chan struct{}
+    // ends the jobs of the server once it stopped serving
+    stopServices func()
+    // address the client connects to
+    addr string
+}
+
+// startStandalone starts a chat server listening on listen, on an empty
+// in-memory store: nothing to install or migrate, and nothing kept once the
+// client exits. The optional settings are the ones of the server, read from
+// the environment
+func startStandalone(listen string) (*standaloneServer, error) {
+    listener, err := net.Listen("tcp", listen)
+    if err != nil {
+        return nil, err
+    }
+
+    db := database.NewMemory()
+    ctx, cancel := context.WithCancel(context.Background())
+    server, stopServices, err := bootstrap.Start(ctx, db, serverconfig.Load())
+    if err != nil {
+        cancel()
+        listener.Close()
+        return nil, err
+    }
+
+    s := &standaloneServer{
+        db:           db,
+        cancel:       cancel,
+        done:         make(chan struct{}),
+        stopServices: stopServices,
+        addr:         dialAddr(listener.Addr().(*net.TCPAddr)),
+    }
+    go func() {
+        defer close(s.done)
+        server.Serve(ctx, listener)
+    }()
+    return s, nil
+}
+
+// Stop logs the clients out, the ones of the LAN too, and closes the
+// store
+func (s *standaloneServer) Stop() {
+    s.cancel()
+    <-s.done
+    s.stopServices()
+    s.db.Close()
+}
+
+// dialAddr returns the address to reach a listener from this computer, the
+// loopback when it listens on every interface
+func dialAddr(addr *net.TCPAddr) string {
+    host := "127.0.0.1"
+    if !addr.IP.IsUnspecified() {
+        host = addr.IP.String()
+    }
+    return net.JoinHostPort(host, strconv.Itoa(addr.Port))
+}
@@ -0,0 +1,200 @@
+// cmd/loadtest/client.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"textual/pkg/protocol"
+)
+
+const (
+    dialTimeout  = 10 * time.Second
+    writeTimeout = 10 * time.Second
+)
+
+// incoming is a message of the server, its payload decoded once its type
+// is known
+type incoming struct {
+    Type    protocol.MessageType `json:"type"`
+    Payload json.RawMessage      `json:"payload"`
+}
+
+// simClient is a simulated user, speaking the protocol as the real client
+type simClient struct {
+    lt       *loadTest
+    index    int
+    username string
+
+    conn    net.Conn
+    userID  string
+    joined  atomic.Bool
+    writeMu sync.Mutex
+    enc     *json.Encoder
+}
+
+func newSimClient(lt *loadTest, index int) *simClient {
+    return &simClient{
+        lt:       lt,
+        index:    index,
+        username: fmt.Sprintf("%s%d", lt.prefix, index+1),
+    }
+}
+
+// connect logs in, then reads the server until the connection closes. A
+// failure is counted and leaves the client out of the run
+func (c *simClient) connect() {
+    start := time.Now()
+    conn, err := net.DialTimeout("tcp", c.lt.server, dialTimeout)
+    if err != nil {
+        c.lt.failed.Add(1)
+        c.lt.failure("connect: " + err.Error())
+        return
+    }
+    c.conn = conn
+    c.enc = json.NewEncoder(conn)
+    if err := c.write(protocol.NewMessage(protocol.TypeAuth, protocol.AuthPayload{
+        Username: c.username,
+        Password: c.lt.password,
+    })); err != nil {
+        c.fail("auth: " + err.Error())
+        return
+    }
+
+    // a single decoder, it may read ahead of the message it returns
+    dec := json.NewDecoder(conn)
+    for {
+        var msg incoming
+        if err := dec.Decode(&msg); err != nil {
+            c.fail("auth: " + err.Error())
+            return
+        }
+        if msg.Type == protocol.TypeError {
+            c.fail("auth: " + errorMessage(msg.Payload))
+            return
+        }
+        if msg.Type == protocol.TypeAuthResponse {
+            var response protocol.AuthResponsePayload
+            json.Unmarshal(msg.Payload, &response)
+            c.userID = response.UserID
+            break
+        }
+    }
+    c.lt.auth.record(time.Since(start))
+    c.lt.connected.Add(1)
+    c.lt.online.Add(1)
+
+    if c.lt.group != "" {
+        if err := c.write(protocol.NewGroupJoin(c.lt.group, c.userID)); err != nil {
+            c.lt.failure("join: " + err.Error())
+        }
+    }
+    go c.read(dec)
+}
+
+// fail closes the connection of a client that could not log in
+func (c *simClient) fail(reason string) {
+    c.conn.Close()
+    c.conn = nil
+    c.lt.failed.Add(1)
+    c.lt.failure(reason)
+}
+
+func (c *simClient) read(dec *json.Decoder) {
+    for {
+        var msg incoming
+        if err := dec.Decode(&msg); err != nil {
+            c.lt.online.Add(-1)
+            if c.joined.Load() {
+                c.lt.members.Add(-1)
+            }
+            if !c.lt.stopping.Load() {
+                c.lt.disconnected.Add(1)
+            }
+            return
+        }
+
+        switch msg.Type {
+        case protocol.TypeGlobalMessage, protocol.TypeGroupMessage:
+            var payload protocol.MessagePayload
+            if json.Unmarshal(msg.Payload, &payload) == nil && payload.ClientID != "" {
+                c.lt.received(payload.ClientID, c.index)
+            }
+        case protocol.TypeGroupJoin:
+            var payload protocol.GroupJoinPayload
+            json.Unmarshal(msg.Payload, &payload)
+            if payload.GroupID == c.lt.group && payload.UserID == c.userID && !c.joined.Swap(true) {
+                c.lt.members.Add(1)
+            }
+        case protocol.TypeError:
+            c.lt.failure(errorMessage(msg.Payload))
+        }
+    }
+}
+
+// chat sends messages at the rate of the run until stop is closed, the
+// first one after a random delay so the clients don't send all at once
+func (c *simClient) chat(stop <-chan struct{}) {
+    interval := time.Duration(float64(time.Second) / c.lt.rate)
+    select {
+    case <-time.After(time.Duration(rand.Int63n(int64(interval)))):
+    case <-stop:
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for seq := 1; ; seq++ {
+        c.send(seq)
+        select {
+        case <-ticker.C:
+        case <-stop:
+            return
+        }
+    }
+}
+
+func (c *simClient) send(seq int) {
+    clientID := fmt.Sprintf("loadtest-%s-%d-%d", c.lt.runID, c.index+1, seq)
+    content := fmt.Sprintf("%s #%d ", c.username, seq)
+    if len(content) < c.lt.size {
+        content += strings.Repeat(".", c.lt.size-len(content))
+    }
+
+    group := c.joined.Load() && rand.Float64() < c.lt.groupShare
+    msg := protocol.NewGlobalMessage(content, c.userID, c.username, clientID)
+    if group {
+        msg = protocol.NewGroupMessage(content, c.userID, c.username, c.lt.group, clientID)
+    }
+    c.lt.sending(clientID, c.index, group)
+    if err := c.write(msg); err != nil {
+        c.lt.failure("send: " + err.Error())
+    }
+}
+
+func (c *simClient) write(msg protocol.Message) error {
+    c.writeMu.Lock()
+    defer c.writeMu.Unlock()
+    c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+    return c.enc.Encode(msg)
+}
+
+func (c *simClient) close() {
+    if c.conn != nil {
+        c.conn.Close()
+    }
+}
+
+func errorMessage(payload json.RawMessage) string {
+    var e protocol.ErrorPayload
+    if err := json.Unmarshal(payload, &e); err != nil || e.Message == "" {
+        return "error without message"
+    }
+    return e.Message
+}
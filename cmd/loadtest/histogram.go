@@ -0,0 +1,97 @@
+// cmd/loadtest/histogram.go
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// each bucket is 5% wider than the previous one, the percentiles are as
+// precise, without keeping every sample of a long run
+const bucketGrowth = 1.05
+
+// histogram counts durations in buckets growing from a microsecond
+type histogram struct {
+    mu     sync.Mutex
+    counts []int64
+    total  int64
+    max    time.Duration
+}
+
+func (h *histogram) record(d time.Duration) {
+    i := bucketOf(d)
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if i >= len(h.counts) {
+        counts := make([]int64, i+1)
+        copy(counts, h.counts)
+        h.counts = counts
+    }
+    h.counts[i]++
+    h.total++
+    if d > h.max {
+        h.max = d
+    }
+}
+
+// percentile returns the upper bound of the bucket holding the p-th
+// percentile, p from 0 to 100
+func (h *histogram) percentile(p float64) time.Duration {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.total == 0 {
+        return 0
+    }
+    rank := int64(math.Ceil(p / 100 * float64(h.total)))
+    if rank < 1 {
+        rank = 1
+    }
+    var seen int64
+    for i, count := range h.counts {
+        seen += count
+        if seen >= rank {
+            return min(bucketValue(i), h.max)
+        }
+    }
+    return h.max
+}
+
+// summary writes the count and the usual percentiles on one line
+func (h *histogram) summary() string {
+    h.mu.Lock()
+    total, max := h.total, h.max
+    h.mu.Unlock()
+    if total == 0 {
+        return "no sample"
+    }
+    return fmt.Sprintf("p50 %-9s p90 %-9s p99 %-9s max %-9s (%d samples)",
+        round(h.percentile(50)), round(h.percentile(90)), round(h.percentile(99)), round(max), total)
+}
+
+func bucketOf(d time.Duration) int {
+    if d <= time.Microsecond {
+        return 0
+    }
+    return int(math.Ceil(math.Log(float64(d)/float64(time.Microsecond)) / math.Log(bucketGrowth)))
+}
+
+func bucketValue(i int) time.Duration {
+    return time.Duration(math.Pow(bucketGrowth, float64(i)) * float64(time.Microsecond))
+}
+
+// round keeps three significant digits, enough to compare two runs
+func round(d time.Duration) time.Duration {
+    switch {
+    case d >= time.Second:
+        return d.Round(10 * time.Millisecond)
+    case d >= 100*time.Millisecond:
+        return d.Round(time.Millisecond)
+    case d >= 10*time.Millisecond:
+        return d.Round(100 * time.Microsecond)
+    case d >= time.Millisecond:
+        return d.Round(10 * time.Microsecond)
+    }
+    return d.Round(time.Microsecond)
+}
@@ -0,0 +1,236 @@
+// cmd/loadtest/main.go
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const usage = `usage: textual-loadtest [flags]
+
+Connects simulated clients to a server, makes them chat at a steady rate in
+the global chat, and in a group with -group, then reports the latencies and
+the messages lost. The accounts are created by their first login, run it
+against a test server: every message sent is stored.
+
+Flags:
+`
+
+// settings are the flags of a run
+type settings struct {
+    server     string
+    clients    int
+    rate       float64
+    duration   time.Duration
+    ramp       time.Duration
+    drain      time.Duration
+    group      string
+    groupShare float64
+    size       int
+    prefix     string
+    password   string
+}
+
+// tracked is a message sent, waiting for its copies
+type tracked struct {
+    sentAt time.Time
+    sender int
+    // clients meant to receive it when it was sent, the sender included
+    expected int64
+    received int64
+}
+
+// loadTest holds the counters shared by the simulated clients
+type loadTest struct {
+    settings
+    // identifies the run in the client ids, a run never repeats the
+    // messages of a previous one
+    runID string
+
+    // login: from the connection to the auth response
+    auth histogram
+    // from the send to the copy received by the sender, stored and broadcast
+    echo histogram
+    // from the send to the copy received by each other client
+    delivery histogram
+
+    online       atomic.Int64
+    members      atomic.Int64
+    connected    atomic.Int64
+    failed       atomic.Int64
+    disconnected atomic.Int64
+    sent         atomic.Int64
+    stopping     atomic.Bool
+
+    mu       sync.Mutex
+    messages map[string]*tracked
+    errors   map[string]int
+}
+
+func main() {
+    var s settings
+    flag.StringVar(&s.server, "server", "localhost:8080", "address of the server")
+    flag.IntVar(&s.clients, "clients", 50, "simulated clients")
+    flag.Float64Var(&s.rate, "rate", 0.2, "messages sent per second by each client")
+    flag.DurationVar(&s.duration, "duration", 30*time.Second, "how long the clients chat, once all connected")
+    flag.DurationVar(&s.ramp, "ramp", 5*time.Second, "time over which the clients connect")
+    flag.DurationVar(&s.drain, "drain", 5*time.Second, "wait for the last messages before counting the lost ones")
+    flag.StringVar(&s.group, "group", "", "public group joined by the clients")
+    flag.Float64Var(&s.groupShare, "group-share", 0.5, "share of the messages sent to the group, from 0 to 1")
+    flag.IntVar(&s.size, "size", 64, "length of the messages")
+    flag.StringVar(&s.prefix, "prefix", "loadtest", "prefix of the usernames, followed by the number of the client")
+    flag.StringVar(&s.password, "password", "loadtest", "password of the accounts")
+    flag.Usage = func() {
+        fmt.Fprint(os.Stderr, usage)
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    if flag.NArg() != 0 || s.clients < 1 || s.rate <= 0 || s.groupShare < 0 || s.groupShare > 1 {
+        flag.Usage()
+        os.Exit(2)
+    }
+    if s.group == "" {
+        s.groupShare = 0
+    }
+
+    id := make([]byte, 4)
+    if _, err := rand.Read(id); err != nil {
+        fmt.Fprintf(os.Stderr, "textual-loadtest: %v\n", err)
+        os.Exit(1)
+    }
+    lt := &loadTest{
+        settings: s,
+        runID:    hex.EncodeToString(id),
+        messages: make(map[string]*tracked),
+        errors:   make(map[string]int),
+    }
+    lt.run()
+    fmt.Print(lt.report())
+}
+
+func (lt *loadTest) run() {
+    fmt.Fprintf(os.Stderr, "Connecting %d clients to %s over %s\n", lt.clients, lt.server, lt.ramp)
+    clients := make([]*simClient, lt.clients)
+    var wg sync.WaitGroup
+    for i := range clients {
+        clients[i] = newSimClient(lt, i)
+        wg.Add(1)
+        go func(c *simClient, delay time.Duration) {
+            defer wg.Done()
+            time.Sleep(delay)
+            c.connect()
+        }(clients[i], lt.ramp*time.Duration(i)/time.Duration(lt.clients))
+    }
+    wg.Wait()
+    fmt.Fprintf(os.Stderr, "%d clients connected, %d failed, chatting for %s\n", lt.connected.Load(), lt.failed.Load(), lt.duration)
+
+    stop := make(chan struct{})
+    for _, c := range clients {
+        if c.conn != nil {
+            wg.Add(1)
+            go func(c *simClient) {
+                defer wg.Done()
+                c.chat(stop)
+            }(c)
+        }
+    }
+    time.Sleep(lt.duration)
+    close(stop)
+    wg.Wait()
+
+    time.Sleep(lt.drain)
+    lt.stopping.Store(true)
+    for _, c := range clients {
+        c.close()
+    }
+}
+
+// sending registers a message before it is written, its copies may come
+// back before the write returns
+func (lt *loadTest) sending(clientID string, sender int, group bool) {
+    expected := lt.online.Load()
+    if group {
+        expected = lt.members.Load()
+    }
+    lt.mu.Lock()
+    lt.messages[clientID] = &tracked{sentAt: time.Now(), sender: sender, expected: expected}
+    lt.mu.Unlock()
+    lt.sent.Add(1)
+}
+
+// received counts a copy of a message, the ones sent by other runs or by
+// real users are ignored
+func (lt *loadTest) received(clientID string, receiver int) {
+    lt.mu.Lock()
+    msg, ok := lt.messages[clientID]
+    if ok {
+        msg.received++
+    }
+    lt.mu.Unlock()
+    if !ok {
+        return
+    }
+    latency := time.Since(msg.sentAt)
+    if receiver == msg.sender {
+        lt.echo.record(latency)
+    } else {
+        lt.delivery.record(latency)
+    }
+}
+
+func (lt *loadTest) failure(message string) {
+    lt.mu.Lock()
+    lt.errors[message]++
+    lt.mu.Unlock()
+}
+
+// report writes the results of the run, the progress goes to stderr so the
+// output can be kept to compare runs
+func (lt *loadTest) report() string {
+    lt.mu.Lock()
+    defer lt.mu.Unlock()
+
+    var expected, received, unanswered int64
+    for _, msg := range lt.messages {
+        expected += msg.expected
+        received += min(msg.received, msg.expected)
+        if msg.received == 0 {
+            unanswered++
+        }
+    }
+    dropped := expected - received
+
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "clients     %d connected, %d failed, %d disconnected by the server\n",
+        lt.connected.Load(), lt.failed.Load(), lt.disconnected.Load())
+    fmt.Fprintf(&sb, "login       %s\n", lt.auth.summary())
+    fmt.Fprintf(&sb, "messages    %d sent (%.1f/s), %d never seen again\n",
+        lt.sent.Load(), float64(lt.sent.Load())/lt.duration.Seconds(), unanswered)
+    fmt.Fprintf(&sb, "echo        %s\n", lt.echo.summary())
+    fmt.Fprintf(&sb, "delivery    %s\n", lt.delivery.summary())
+    if expected > 0 {
+        fmt.Fprintf(&sb, "dropped     %d of %d copies (%.2f%%)\n", dropped, expected, 100*float64(dropped)/float64(expected))
+    }
+
+    if len(lt.errors) > 0 {
+        sb.WriteString("errors\n")
+        messages := make([]string, 0, len(lt.errors))
+        for message := range lt.errors {
+            messages = append(messages, message)
+        }
+        sort.Slice(messages, func(i, j int) bool { return lt.errors[messages[i]] > lt.errors[messages[j]] })
+        for _, message := range messages {
+            fmt.Fprintf(&sb, "  %6d  %s\n", lt.errors[message], message)
+        }
+    }
+    return sb.String()
+}
@@ -0,0 +1,54 @@
+// cmd/replay/compare.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"textual/pkg/protocol"
+)
+
+// frames sent depending on the timing of the run or on who else is online,
+// left out of the comparison
+var unstable = map[protocol.MessageType]bool{
+    protocol.TypePing:         true,
+    protocol.TypePong:         true,
+    protocol.TypeTyping:       true,
+    protocol.TypeStatusUpdate: true,
+}
+
+// compare checks that the frames received have the types of the capture, in
+// the same order
+func compare(expected, received []json.RawMessage) error {
+    want, got := frameTypes(expected), frameTypes(received)
+    for i := 0; i < len(want) && i < len(got); i++ {
+        if want[i] != got[i] {
+            return fmt.Errorf("frame %d: received %s, the capture has %s", i+1, got[i], want[i])
+        }
+    }
+    switch {
+    case len(got) < len(want):
+        return fmt.Errorf("%d frames received, the capture has %d: %s is missing", len(got), len(want), want[len(got)])
+    case len(got) > len(want):
+        return fmt.Errorf("%d frames received, the capture has %d: %s is new", len(got), len(want), got[len(want)])
+    }
+    return nil
+}
+
+// frameTypes lists the types of the frames, the unstable ones left out
+func frameTypes(frames []json.RawMessage) []protocol.MessageType {
+    var types []protocol.MessageType
+    for _, frame := range frames {
+        var msg struct {
+            Type protocol.MessageType `json:"type"`
+        }
+        if err := json.Unmarshal(frame, &msg); err != nil {
+            types = append(types, "(not json)")
+            continue
+        }
+        if !unstable[msg.Type] {
+            types = append(types, msg.Type)
+        }
+    }
+    return types
+}
@@ -0,0 +1,217 @@
+// cmd/replay/main.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"textual/internal/wiretap"
+	"textual/pkg/protocol"
+)
+
+const usage = `usage: textual-replay [flags] capture
+
+Plays back a capture of the server (WIRE_TAP) or of the client (--wire-tap)
+to reproduce a bug: with -server the frames the client sent go to a server,
+with -listen the frames the server sent go to the client connecting there.
+The frames received are written on stdout as a capture, to compare two runs
+or to replay in turn. -check compares their types with the capture and fails
+on the first difference, to test a change of the protocol.
+
+The captures have their passwords redacted, -password logs in again. Replay
+against a test server: the messages of the capture are stored again.
+
+Flags:
+`
+
+// settings are the flags of a run
+type settings struct {
+    server   string
+    listen   string
+    conn     int
+    username string
+    password string
+    speed    float64
+    wait     time.Duration
+    check    bool
+}
+
+func main() {
+    var s settings
+    flag.StringVar(&s.server, "server", "", "address of the server to play the frames of the client to")
+    flag.StringVar(&s.listen, "listen", "", "address to wait for the client on, to play the frames of the server to it")
+    flag.IntVar(&s.conn, "conn", 0, "connection of the capture to play, the first one when 0")
+    flag.StringVar(&s.username, "user", "", "username replacing the one of the auth frames")
+    flag.StringVar(&s.password, "password", "", "password replacing the redacted one of the auth frames")
+    flag.Float64Var(&s.speed, "speed", 1, "1 keeps the pauses of the capture, 2 halves them, 0 sends without pause")
+    flag.DurationVar(&s.wait, "wait", 2*time.Second, "wait for the last frames after the capture is played")
+    flag.BoolVar(&s.check, "check", false, "fail when the types of the frames received differ from the capture")
+    flag.Usage = func() {
+        fmt.Fprint(os.Stderr, usage)
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    if flag.NArg() != 1 || (s.server == "") == (s.listen == "") || s.speed < 0 {
+        flag.Usage()
+        os.Exit(2)
+    }
+    if err := run(s, flag.Arg(0)); err != nil {
+        fmt.Fprintf(os.Stderr, "textual-replay: %v\n", err)
+        os.Exit(1)
+    }
+}
+
+func run(s settings, path string) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    records, err := wiretap.ReadCapture(file)
+    file.Close()
+    if err != nil {
+        return fmt.Errorf("%s: %v", path, err)
+    }
+
+    // the frames of one end are played, the other end answers them
+    play, peer := wiretap.Client, wiretap.Server
+    if s.listen != "" {
+        play, peer = wiretap.Server, wiretap.Client
+    }
+    sent, expected := selectConn(records, s.conn, play, peer)
+    if len(sent) == 0 {
+        return fmt.Errorf("%s: no frame of the %s to play", path, play)
+    }
+
+    conn, err := open(s)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+
+    start := time.Now()
+    var (
+        mu       sync.Mutex
+        received []json.RawMessage
+    )
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        dec := json.NewDecoder(conn)
+        out := json.NewEncoder(os.Stdout)
+        for {
+            var frame json.RawMessage
+            if err := dec.Decode(&frame); err != nil {
+                return
+            }
+            mu.Lock()
+            received = append(received, frame)
+            mu.Unlock()
+            out.Encode(wiretap.Record{Conn: 1, At: time.Since(start).Milliseconds(), From: peer, Frame: wiretap.Redact(frame)})
+        }
+    }()
+
+    if err := replay(conn, sent, s); err != nil {
+        return err
+    }
+    fmt.Fprintf(os.Stderr, "%d frames played, waiting %s for the last answers\n", len(sent), s.wait)
+    select {
+    case <-done:
+    case <-time.After(s.wait):
+    }
+    conn.Close()
+    <-done
+
+    if !s.check {
+        return nil
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    if err := compare(expected, received); err != nil {
+        return err
+    }
+    // stdout stays a capture
+    fmt.Fprintf(os.Stderr, "The %d frames received match the capture\n", len(received))
+    return nil
+}
+
+// selectConn returns the frames of a connection of the capture, by the end
+// that sent them
+func selectConn(records []wiretap.Record, conn int, play, peer string) (sent []wiretap.Record, expected []json.RawMessage) {
+    if conn == 0 && len(records) > 0 {
+        conn = records[0].Conn
+    }
+    for _, record := range records {
+        if record.Conn != conn {
+            continue
+        }
+        switch record.From {
+        case play:
+            sent = append(sent, record)
+        case peer:
+            expected = append(expected, record.Frame)
+        }
+    }
+    return sent, expected
+}
+
+// open dials the server, or waits for the client
+func open(s settings) (net.Conn, error) {
+    if s.server != "" {
+        return net.DialTimeout("tcp", s.server, 10*time.Second)
+    }
+    listener, err := net.Listen("tcp", s.listen)
+    if err != nil {
+        return nil, err
+    }
+    defer listener.Close()
+    fmt.Fprintf(os.Stderr, "Waiting for a client on %s\n", listener.Addr())
+    return listener.Accept()
+}
+
+// replay writes the frames with the pauses of the capture
+func replay(conn net.Conn, records []wiretap.Record, s settings) error {
+    last := records[0].At
+    for _, record := range records {
+        if s.speed > 0 && record.At > last {
+            time.Sleep(time.Duration(float64(time.Duration(record.At-last)*time.Millisecond) / s.speed))
+        }
+        last = record.At
+
+        frame, err := credentials(record.Frame, s)
+        if err != nil {
+            return err
+        }
+        if _, err := conn.Write(append(frame, '\n')); err != nil {
+            return fmt.Errorf("write: %v", err)
+        }
+    }
+    return nil
+}
+
+// credentials puts the username and password of the flags in an auth frame,
+// the other frames are sent as they were recorded
+func credentials(frame json.RawMessage, s settings) ([]byte, error) {
+    var msg struct {
+        Type    protocol.MessageType `json:"type"`
+        Payload protocol.AuthPayload `json:"payload"`
+    }
+    if json.Unmarshal(frame, &msg) != nil || msg.Type != protocol.TypeAuth {
+        return frame, nil
+    }
+    if s.username != "" {
+        msg.Payload.Username = s.username
+    }
+    if msg.Payload.Password == wiretap.Redacted {
+        if s.password == "" {
+            return nil, fmt.Errorf("the capture logs %s in with a redacted password, set -password", msg.Payload.Username)
+        }
+        msg.Payload.Password = s.password
+    }
+    return json.Marshal(protocol.NewMessage(protocol.TypeAuth, msg.Payload))
+}
@@ -0,0 +1,32 @@
+// cmd/server/revoke.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"textual/internal/server/database"
+)
+
+// revokeTokens revokes every API token of an account, for an account whose
+// tokens leaked. The tokens are deleted, the requests still using them get
+// 401 at once. It returns the exit code
+func revokeTokens(ctx context.Context, db database.Store, args []string) int {
+    if len(args) != 1 {
+        fmt.Fprintln(os.Stderr, "usage: server revoke-tokens <username>")
+        return 2
+    }
+    user, err := db.GetUserByUsername(ctx, args[0])
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "no account %s\n", args[0])
+        return 1
+    }
+    revoked, err := db.DeleteUserAPITokens(ctx, user.ID)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+    fmt.Printf("%d API tokens of %s revoked\n", revoked, user.Username)
+    return 0
+}
@@ -0,0 +1,91 @@
+// cmd/textual-import/csv.go
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts accepted for the timestamps, unix seconds work too
+var csvLayouts = []string{
+    time.RFC3339,
+    "2006-01-02 15:04:05",
+    "2006-01-02 15:04",
+    "2006-01-02T15:04:05",
+}
+
+// readCSV reads a file whose header names the timestamp, user and content
+// columns, in any order, and optionally a channel column
+func readCSV(path, group string, tz *time.Location) ([]record, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    columns := map[string]int{"channel": -1}
+    for i, name := range header {
+        columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+    }
+    for _, name := range []string{"timestamp", "user", "content"} {
+        if _, ok := columns[name]; !ok {
+            return nil, fmt.Errorf("%s: missing column %q", path, name)
+        }
+    }
+
+    var records []record
+    for line := 2; ; line++ {
+        row, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        field := func(name string) string {
+            if i := columns[name]; i >= 0 && i < len(row) {
+                return strings.TrimSpace(row[i])
+            }
+            return ""
+        }
+        if field("content") == "" {
+            continue
+        }
+        sentAt, err := csvTime(field("timestamp"), tz)
+        if err != nil {
+            return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+        }
+        channel := first(field("channel"), group)
+        records = append(records, record{
+            channel: channel,
+            user:    field("user"),
+            text:    field("content"),
+            sentAt:  sentAt,
+            key:     fmt.Sprintf("csv/%s/%s/%d", channel, sentAt.Format(time.RFC3339), line),
+        })
+    }
+    return records, nil
+}
+
+func csvTime(value string, tz *time.Location) (time.Time, error) {
+    if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+        return time.Unix(seconds, 0), nil
+    }
+    for _, layout := range csvLayouts {
+        if t, err := time.ParseInLocation(layout, value, tz); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
@@ -0,0 +1,249 @@
+// cmd/textual-import/importer.go
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+)
+
+// longest user and group names of the schema
+const maxNameLength = 50
+
+// importer stores the records, creating their authors and groups on the way
+type importer struct {
+    db     database.Store
+    format      string
+    prefix      string
+    groupPrefix string
+    owner       *models.User
+
+    users   map[string]*models.User
+    groups  map[string]string
+    members map[string]bool
+    // message ids by record key, for the replies of the threads
+    ids map[string]string
+
+    imported, skipped           int
+    usersCreated, groupsCreated int
+}
+
+func newImporter(ctx context.Context, db database.Store, format, prefix, groupPrefix, owner string) (*importer, error) {
+    im := &importer{
+        db:          db,
+        format:      format,
+        prefix:      prefix,
+        groupPrefix: groupPrefix,
+        users:   make(map[string]*models.User),
+        groups:  make(map[string]string),
+        members: make(map[string]bool),
+        ids:     make(map[string]string),
+    }
+    if owner != "" {
+        user, err := db.GetUserByUsername(ctx, owner)
+        if err != nil {
+            return nil, fmt.Errorf("owner: %v", err)
+        }
+        im.owner = user
+    }
+    return im, nil
+}
+
+// checkAuthors stops the import before anything is written when an author
+// has the name of an account of a user
+func (im *importer) checkAuthors(ctx context.Context, records []record) error {
+    checked := make(map[string]bool)
+    var taken []string
+    for _, r := range records {
+        name := username(im.prefix, r.user)
+        if checked[name] {
+            continue
+        }
+        checked[name] = true
+        ok, err := im.db.AccountTaken(ctx, name)
+        if err != nil {
+            return fmt.Errorf("account %s: %v", name, err)
+        }
+        if ok {
+            taken = append(taken, name)
+        }
+    }
+    if len(taken) > 0 {
+        return fmt.Errorf("authors named like existing accounts: %s; choose a -prefix to import their messages apart",
+            strings.Join(taken, ", "))
+    }
+    return nil
+}
+
+// checkGroups stops the import before anything is written when a channel
+// has the name of a group of the users, the imports only fill their own
+func (im *importer) checkGroups(ctx context.Context, records []record) error {
+    checked := make(map[string]bool)
+    var taken []string
+    for _, r := range records {
+        if r.channel == "" {
+            continue
+        }
+        name := groupName(im.groupPrefix, r.channel)
+        if checked[name] {
+            continue
+        }
+        checked[name] = true
+        ok, err := im.db.GroupTaken(ctx, name)
+        if err != nil {
+            return fmt.Errorf("group %s: %v", name, err)
+        }
+        if ok {
+            taken = append(taken, name)
+        }
+    }
+    if len(taken) > 0 {
+        return fmt.Errorf("channels named like existing groups: %s; choose a -group-prefix to import their messages apart",
+            strings.Join(taken, ", "))
+    }
+    return nil
+}
+
+func (im *importer) add(ctx context.Context, r record) error {
+    author, err := im.user(ctx, r.user)
+    if err != nil {
+        return err
+    }
+
+    msg := &models.Message{
+        Content:    r.text,
+        SenderID:   author.ID,
+        SenderName: author.Username,
+        SentAt:     r.sentAt,
+        Status:     models.MessageStatusSent,
+        ClientID:   clientID(r.key),
+    }
+    if r.channel != "" {
+        groupID, err := im.group(ctx, r.channel, author)
+        if err != nil {
+            return err
+        }
+        if err := im.member(ctx, groupID, author.ID); err != nil {
+            return err
+        }
+        msg.GroupID = &groupID
+    }
+    // a reply whose root was not imported stays a plain message
+    if rootID, ok := im.ids[r.threadKey]; ok && r.threadKey != "" {
+        msg.ThreadRootID = &rootID
+    }
+
+    err = im.db.SaveMessage(ctx, msg)
+    switch {
+    case err == database.ErrDuplicateMessage:
+        im.skipped++
+    case err != nil:
+        return err
+    default:
+        im.imported++
+    }
+    im.ids[r.key] = msg.ID
+    return nil
+}
+
+func (im *importer) user(ctx context.Context, name string) (*models.User, error) {
+    if user, ok := im.users[name]; ok {
+        return user, nil
+    }
+    user, created, err := im.db.GetOrCreateImportedUser(ctx, username(im.prefix, name))
+    if err == database.ErrAccountTaken {
+        return nil, fmt.Errorf("account %s: %v, choose a -prefix to import its messages apart", username(im.prefix, name), err)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("account %s: %v", name, err)
+    }
+    if created {
+        im.usersCreated++
+    }
+    im.users[name] = user
+    return user, nil
+}
+
+// group returns the group of a channel, created by the owner or by the
+// first author met. Only a group created by an import is filled again
+func (im *importer) group(ctx context.Context, channel string, author *models.User) (string, error) {
+    name := groupName(im.groupPrefix, channel)
+    if id, ok := im.groups[name]; ok {
+        return id, nil
+    }
+
+    id, err := im.db.FindImportedGroup(ctx, name)
+    if err != nil {
+        return "", err
+    }
+    if id == "" {
+        creator := author
+        if im.owner != nil {
+            creator = im.owner
+        }
+        group, err := im.db.CreateImportedGroup(ctx, name, "Imported from "+im.format, creator.ID)
+        if err != nil {
+            return "", fmt.Errorf("group %s: %v", name, err)
+        }
+        id = group.ID
+        im.groupsCreated++
+    }
+    im.groups[name] = id
+    return id, nil
+}
+
+func (im *importer) member(ctx context.Context, groupID, userID string) error {
+    key := groupID + "/" + userID
+    if im.members[key] {
+        return nil
+    }
+    if err := im.db.AddUserToGroup(ctx, userID, groupID); err != nil {
+        return err
+    }
+    im.members[key] = true
+    return nil
+}
+
+// username turns a name of the export into an account name: no spaces nor
+// control characters, cut to the length of the schema
+func username(prefix, name string) string {
+    name = strings.Map(func(r rune) rune {
+        switch {
+        case unicode.IsSpace(r):
+            return '_'
+        case unicode.IsControl(r):
+            return -1
+        }
+        return r
+    }, strings.TrimSpace(name))
+    if name == "" {
+        name = "unknown"
+    }
+    return truncate(prefix+name, maxNameLength)
+}
+
+// groupName turns a channel of the export into a group name, cut to the
+// length of the schema
+func groupName(prefix, channel string) string {
+    return truncate(prefix+channel, maxNameLength)
+}
+
+func truncate(s string, max int) string {
+    if runes := []rune(s); len(runes) > max {
+        return string(runes[:max])
+    }
+    return s
+}
+
+// clientID derives the idempotency key of a message from its key in the
+// export
+func clientID(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return "import-" + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,85 @@
+// cmd/textual-import/irc.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+    // "2024-05-01 12:34:56", "[12:34:56]", "12:34" before the nick
+    ircStamp = `^\[?(?:(\d{4}-\d{2}-\d{2})[ T])?(\d{1,2}:\d{2}(?::\d{2})?)\]?\s+`
+    // <nick> text, or nick<tab>text as written by weechat
+    ircMessage = regexp.MustCompile(ircStamp + `(?:<[ @+%&~]?([^>\s]+)>\s?|([^\s*<>-][^\t]*)\t)(.*)$`)
+    // * nick does something
+    ircAction = regexp.MustCompile(ircStamp + `\*\s+(\S+)\s(.*)$`)
+    // "--- Log opened Tue May 01 10:00:00 2024", "--- Day changed Wed May 02 2024"
+    ircDay = regexp.MustCompile(`^-+\s+(?:Log opened|Day changed)\s+\w{3}\s+(\w{3}\s+\d{1,2})\s+(?:[\d:]+\s+)?(\d{4})`)
+)
+
+// readIRC reads a channel log, the other lines (joins, quits, modes) are
+// skipped. The day comes from the lines, the day changes of irssi, or day
+func readIRC(path, channel string, day time.Time, tz *time.Location) ([]record, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    var records []record
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 64<<10), 1<<20)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimRight(scanner.Text(), "\r")
+
+        if match := ircDay.FindStringSubmatch(line); match != nil {
+            if parsed, err := time.ParseInLocation("Jan 2 2006", strings.Join(strings.Fields(match[1]), " ")+" "+match[2], tz); err == nil {
+                day = parsed
+            }
+            continue
+        }
+
+        var date, clock, nick, text string
+        if match := ircAction.FindStringSubmatch(line); match != nil {
+            date, clock, nick, text = match[1], match[2], match[3], "_"+match[4]+"_"
+        } else if match := ircMessage.FindStringSubmatch(line); match != nil {
+            date, clock, nick, text = match[1], match[2], first(match[3], strings.TrimLeft(match[4], "@+%&~")), match[5]
+        } else {
+            continue
+        }
+        if strings.TrimSpace(text) == "" {
+            continue
+        }
+
+        if date == "" {
+            if day.IsZero() {
+                return nil, fmt.Errorf("%s:%d: line without date, set it with -date", path, n)
+            }
+            date = day.Format("2006-01-02")
+        }
+        if strings.Index(clock, ":") == 1 {
+            clock = "0" + clock
+        }
+        layout := "2006-01-02 15:04"
+        if strings.Count(clock, ":") == 2 {
+            layout += ":05"
+        }
+        sentAt, err := time.ParseInLocation(layout, date+" "+clock, tz)
+        if err != nil {
+            return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+        }
+
+        records = append(records, record{
+            channel: channel,
+            user:    nick,
+            text:    text,
+            sentAt:  sentAt,
+            key:     fmt.Sprintf("irc/%s/%s/%d", channel, sentAt.Format(time.RFC3339), n),
+        })
+    }
+    return records, scanner.Err()
+}
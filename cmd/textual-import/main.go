@@ -0,0 +1,163 @@
+// cmd/textual-import/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"textual/internal/server/database"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// record is a message read from an export, before it is stored
+type record struct {
+    // group receiving the message, the global chat when empty
+    channel string
+    user    string
+    text    string
+    sentAt  time.Time
+    // identifies the message in the export, importing it twice stores it once
+    key string
+    // key of the root of its thread, if any
+    threadKey string
+}
+
+const usage = `usage: textual-import -format slack|irc|csv [flags] <path>
+
+Imports the history of another chat into the database of the server, read
+from the same .env. The authors become accounts without password, reused by
+the next imports; each channel becomes a group. An author named like an
+account of a user stops the import, -prefix keeps them apart; a channel named
+like a group of the users too, -group-prefix keeps them apart.
+
+  slack  the directory of an unzipped Slack export, every channel is imported
+  irc    a log file: "[hh:mm] <nick> text" lines, irssi and weechat styles
+  csv    a file with a header: timestamp,user,content and optionally channel
+
+Flags:
+`
+
+func main() {
+    format := flag.String("format", "", "slack, irc or csv")
+    group := flag.String("group", "", "group of the irc and csv messages without channel, the global chat when empty")
+    prefix := flag.String("prefix", "", "prefix of the names of the authors, needed when one is the name of an existing account")
+    groupPrefix := flag.String("group-prefix", "", "prefix of the names of the groups, needed when a channel is the name of an existing group")
+    owner := flag.String("owner", "", "existing account made admin of the groups created, their first author otherwise")
+    date := flag.String("date", "", "day of an irc log whose lines have no date (YYYY-MM-DD)")
+    location := flag.String("tz", "Local", "time zone of the timestamps without one")
+    envFile := flag.String("env", ".env", "file holding the database settings")
+    dryRun := flag.Bool("dry-run", false, "read the export and print what would be imported, without writing")
+    flag.Usage = func() {
+        fmt.Fprint(os.Stderr, usage)
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    if flag.NArg() != 1 || *format == "" {
+        flag.Usage()
+        os.Exit(2)
+    }
+    tz, err := time.LoadLocation(*location)
+    if err != nil {
+        fatalf("invalid time zone: %v", err)
+    }
+
+    var records []record
+    path := flag.Arg(0)
+    switch *format {
+    case "slack":
+        records, err = readSlack(path)
+    case "irc":
+        var day time.Time
+        if *date != "" {
+            if day, err = time.ParseInLocation("2006-01-02", *date, tz); err != nil {
+                fatalf("invalid date: %v", err)
+            }
+        }
+        records, err = readIRC(path, *group, day, tz)
+    case "csv":
+        records, err = readCSV(path, *group, tz)
+    default:
+        fatalf("unknown format %q", *format)
+    }
+    if err != nil {
+        fatalf("%v", err)
+    }
+    if len(records) == 0 {
+        fatalf("no message found in %s", path)
+    }
+
+    if *dryRun {
+        printSummary(records)
+        return
+    }
+
+    // the variables may also come from the environment
+    godotenv.Load(*envFile)
+    db, err := database.NewDB(
+        os.Getenv("DB_HOST"),
+        os.Getenv("DB_PORT"),
+        os.Getenv("DB_USER"),
+        os.Getenv("DB_PASSWORD"),
+        os.Getenv("DB_NAME"),
+    )
+    if err != nil {
+        fatalf("database connection error: %v", err)
+    }
+    defer db.Close()
+
+    ctx := context.Background()
+    im, err := newImporter(ctx, db, *format, *prefix, *groupPrefix, *owner)
+    if err != nil {
+        fatalf("%v", err)
+    }
+    if err := im.checkAuthors(ctx, records); err != nil {
+        fatalf("%v", err)
+    }
+    if err := im.checkGroups(ctx, records); err != nil {
+        fatalf("%v", err)
+    }
+    for i, r := range records {
+        if err := im.add(ctx, r); err != nil {
+            fatalf("message %d (%s): %v", i+1, r.key, err)
+        }
+        if (i+1)%1000 == 0 {
+            fmt.Printf("%d/%d messages\n", i+1, len(records))
+        }
+    }
+    fmt.Printf("Imported %d messages (%d already there), %d accounts and %d groups created\n",
+        im.imported, im.skipped, im.usersCreated, im.groupsCreated)
+}
+
+// printSummary lists the conversations and authors found by a dry run
+func printSummary(records []record) {
+    channels := make(map[string]int)
+    var order []string
+    users := make(map[string]bool)
+    for _, r := range records {
+        if _, ok := channels[r.channel]; !ok {
+            order = append(order, r.channel)
+        }
+        channels[r.channel]++
+        users[r.user] = true
+    }
+    for _, channel := range order {
+        name := channel
+        if name == "" {
+            name = "(global chat)"
+        }
+        fmt.Printf("%-30s %d messages\n", name, channels[channel])
+    }
+    fmt.Printf("%d messages by %d authors, from %s to %s\n", len(records), len(users),
+        records[0].sentAt.Format(time.DateTime), records[len(records)-1].sentAt.Format(time.DateTime))
+}
+
+func fatalf(format string, args ...interface{}) {
+    fmt.Fprintf(os.Stderr, "textual-import: "+format+"\n", args...)
+    os.Exit(1)
+}
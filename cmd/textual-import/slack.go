@@ -0,0 +1,160 @@
+// cmd/textual-import/slack.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type slackUser struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
+}
+
+type slackMessage struct {
+    Type        string `json:"type"`
+    Subtype     string `json:"subtype"`
+    User        string `json:"user"`
+    Username    string `json:"username"`
+    Text        string `json:"text"`
+    TS          string `json:"ts"`
+    ThreadTS    string `json:"thread_ts"`
+    UserProfile struct {
+        Name string `json:"name"`
+    } `json:"user_profile"`
+}
+
+// the subtypes written by people, the others are joins, topic changes...
+var slackSubtypes = map[string]bool{
+    "":                 true,
+    "bot_message":      true,
+    "me_message":       true,
+    "thread_broadcast": true,
+    "file_share":       true,
+}
+
+// <@U123>, <#C123|general>, <!here>, <https://url|label>
+var slackMarkup = regexp.MustCompile(`<([@#!]?)([^>|]*)(?:\|([^>]*))?>`)
+
+// readSlack reads an unzipped Slack export: users.json and a directory of
+// daily files per channel
+func readSlack(dir string) ([]record, error) {
+    users := make(map[string]string)
+    if data, err := os.ReadFile(filepath.Join(dir, "users.json")); err == nil {
+        var list []slackUser
+        if err := json.Unmarshal(data, &list); err != nil {
+            return nil, fmt.Errorf("users.json: %v", err)
+        }
+        for _, u := range list {
+            users[u.ID] = u.Name
+        }
+    } else if !os.IsNotExist(err) {
+        return nil, err
+    }
+
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+    var records []record
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            continue
+        }
+        channel := entry.Name()
+        days, err := filepath.Glob(filepath.Join(dir, channel, "*.json"))
+        if err != nil {
+            return nil, err
+        }
+        sort.Strings(days)
+
+        for _, day := range days {
+            data, err := os.ReadFile(day)
+            if err != nil {
+                return nil, err
+            }
+            var messages []slackMessage
+            if err := json.Unmarshal(data, &messages); err != nil {
+                return nil, fmt.Errorf("%s: %v", day, err)
+            }
+            sort.SliceStable(messages, func(i, j int) bool { return messages[i].TS < messages[j].TS })
+
+            for _, m := range messages {
+                if m.Type != "message" || !slackSubtypes[m.Subtype] || strings.TrimSpace(m.Text) == "" {
+                    continue
+                }
+                sentAt, err := slackTime(m.TS)
+                if err != nil {
+                    return nil, fmt.Errorf("%s: %v", day, err)
+                }
+                r := record{
+                    channel: channel,
+                    user:    first(users[m.User], m.Username, m.UserProfile.Name, m.User),
+                    text:    slackText(m.Text, users),
+                    sentAt:  sentAt,
+                    key:     "slack/" + channel + "/" + m.TS,
+                }
+                if m.Subtype == "me_message" {
+                    r.text = "_" + r.text + "_"
+                }
+                if m.ThreadTS != "" && m.ThreadTS != m.TS {
+                    r.threadKey = "slack/" + channel + "/" + m.ThreadTS
+                }
+                records = append(records, r)
+            }
+        }
+    }
+    return records, nil
+}
+
+// slackTime reads a timestamp like "1500000000.000100"
+func slackTime(ts string) (time.Time, error) {
+    sec, frac, _ := strings.Cut(ts, ".")
+    s, err := strconv.ParseInt(sec, 10, 64)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid timestamp %q", ts)
+    }
+    var micro int64
+    if frac != "" {
+        micro, _ = strconv.ParseInt((frac + "000000")[:6], 10, 64)
+    }
+    return time.Unix(s, micro*1000), nil
+}
+
+// slackText replaces the markup of the mentions and links by plain text
+func slackText(text string, users map[string]string) string {
+    text = slackMarkup.ReplaceAllStringFunc(text, func(match string) string {
+        parts := slackMarkup.FindStringSubmatch(match)
+        kind, target, label := parts[1], parts[2], parts[3]
+        switch kind {
+        case "@":
+            return "@" + first(users[target], label, target)
+        case "#":
+            return "#" + first(label, target)
+        case "!":
+            return "@" + first(label, target)
+        }
+        if label != "" && label != target {
+            return label + " (" + target + ")"
+        }
+        return target
+    })
+    return html.UnescapeString(text)
+}
+
+func first(values ...string) string {
+    for _, value := range values {
+        if value != "" {
+            return value
+        }
+    }
+    return ""
+}
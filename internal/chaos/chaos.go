@@ -0,0 +1,194 @@
+// internal/chaos/chaos.go
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDropped is returned by the read or write that cut the connection
+var ErrDropped = errors.New("chaos: connection dropped")
+
+// Config is the bad network the connections go through. The zero Config
+// changes nothing
+type Config struct {
+    // added to each read and each write, a round trip gets it four times
+    // when both ends use it
+    Latency time.Duration
+    // at most this much more or less than Latency, at random
+    Jitter time.Duration
+    // share of the writes sent in two parts, a short pause between them
+    Partial float64
+    // share of the reads and writes that cut the connection instead
+    Drop float64
+    // seed of the draws, the same seed makes the same failures; 0 draws a
+    // new one
+    Seed int64
+}
+
+// Parse reads a config such as "latency=200ms,jitter=50ms,partial=0.2,drop=0.01,seed=1",
+// the settings left out are off
+func Parse(spec string) (Config, error) {
+    var c Config
+    for _, field := range strings.Split(spec, ",") {
+        field = strings.TrimSpace(field)
+        if field == "" {
+            continue
+        }
+        key, value, ok := strings.Cut(field, "=")
+        if !ok {
+            return Config{}, fmt.Errorf("chaos: %q is not key=value", field)
+        }
+        var err error
+        switch key {
+        case "latency":
+            c.Latency, err = time.ParseDuration(value)
+        case "jitter":
+            c.Jitter, err = time.ParseDuration(value)
+        case "partial":
+            c.Partial, err = share(value)
+        case "drop":
+            c.Drop, err = share(value)
+        case "seed":
+            c.Seed, err = strconv.ParseInt(value, 10, 64)
+        default:
+            return Config{}, fmt.Errorf("chaos: unknown setting %q", key)
+        }
+        if err != nil {
+            return Config{}, fmt.Errorf("chaos: %s: %v", key, err)
+        }
+    }
+    if c.Latency < 0 || c.Jitter < 0 {
+        return Config{}, errors.New("chaos: negative latency")
+    }
+    return c, nil
+}
+
+// share reads a probability, from 0 to 1
+func share(value string) (float64, error) {
+    p, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+        return 0, err
+    }
+    if p < 0 || p > 1 {
+        return 0, fmt.Errorf("%v is not between 0 and 1", p)
+    }
+    return p, nil
+}
+
+// Enabled tells if the config changes anything
+func (c Config) Enabled() bool {
+    return c.Latency > 0 || c.Jitter > 0 || c.Partial > 0 || c.Drop > 0
+}
+
+// String writes the config as Parse reads it
+func (c Config) String() string {
+    return fmt.Sprintf("latency=%s,jitter=%s,partial=%g,drop=%g,seed=%d", c.Latency, c.Jitter, c.Partial, c.Drop, c.Seed)
+}
+
+// Network wraps connections in the failures of its config, each one drawing
+// its own from the seed
+type Network struct {
+    config Config
+
+    mu    sync.Mutex
+    conns int64
+}
+
+// New returns the network of a config, a random seed when it has none
+func New(config Config) *Network {
+    if config.Seed == 0 {
+        config.Seed = time.Now().UnixNano()
+    }
+    return &Network{config: config}
+}
+
+// Config is the config of the network, its seed drawn
+func (n *Network) Config() Config {
+    return n.config
+}
+
+// Wrap returns conn going through the network
+func (n *Network) Wrap(conn net.Conn) net.Conn {
+    n.mu.Lock()
+    n.conns++
+    seed := n.config.Seed + n.conns
+    n.mu.Unlock()
+    return &chaosConn{Conn: conn, config: n.config, rand: rand.New(rand.NewSource(seed))}
+}
+
+// chaosConn is a connection with the failures of a config. The reads and the
+// writes are each made by one goroutine, as the client and the server do,
+// so a write waits for the ones before it and the order is kept
+type chaosConn struct {
+    net.Conn
+    config Config
+
+    mu   sync.Mutex // the draws, the reads and writes share them
+    rand *rand.Rand
+}
+
+// draw returns a random number in [0, 1)
+func (c *chaosConn) draw() float64 {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.rand.Float64()
+}
+
+// delay waits the latency of a read or a write
+func (c *chaosConn) delay() {
+    d := c.config.Latency
+    if c.config.Jitter > 0 {
+        d += time.Duration((c.draw()*2 - 1) * float64(c.config.Jitter))
+    }
+    if d > 0 {
+        time.Sleep(d)
+    }
+}
+
+// dropped tells if this read or write cuts the connection, closing it
+func (c *chaosConn) dropped() bool {
+    if c.config.Drop <= 0 || c.draw() >= c.config.Drop {
+        return false
+    }
+    c.Conn.Close()
+    return true
+}
+
+func (c *chaosConn) Read(b []byte) (int, error) {
+    n, err := c.Conn.Read(b)
+    if n > 0 {
+        // the data arrived late
+        c.delay()
+        if c.dropped() {
+            return 0, ErrDropped
+        }
+    }
+    return n, err
+}
+
+func (c *chaosConn) Write(b []byte) (int, error) {
+    c.delay()
+    if c.dropped() {
+        return 0, ErrDropped
+    }
+    if len(b) < 2 || c.config.Partial <= 0 || c.draw() >= c.config.Partial {
+        return c.Conn.Write(b)
+    }
+
+    // the reader gets the start first, the rest a little later
+    cut := 1 + int(c.draw()*float64(len(b)-1))
+    n, err := c.Conn.Write(b[:cut])
+    if err != nil {
+        return n, err
+    }
+    time.Sleep(time.Duration(1+c.draw()*9) * time.Millisecond)
+    m, err := c.Conn.Write(b[cut:])
+    return n + m, err
+}
@@ -0,0 +1,100 @@
+// internal/chaos/chaos_test.go
+package chaos
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+    c, err := Parse("latency=200ms, jitter=50ms,partial=0.2,drop=0.01,seed=7")
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := Config{Latency: 200 * time.Millisecond, Jitter: 50 * time.Millisecond, Partial: 0.2, Drop: 0.01, Seed: 7}
+    if c != want {
+        t.Errorf("Parse = %+v, want %+v", c, want)
+    }
+    if again, err := Parse(c.String()); err != nil || again != c {
+        t.Errorf("Parse(%s) = %+v, %v", c, again, err)
+    }
+    if c, err := Parse(""); err != nil || c.Enabled() {
+        t.Errorf("empty spec: %+v, %v", c, err)
+    }
+    for _, spec := range []string{"latency", "latency=fast", "drop=2", "partial=-0.1", "loss=0.1", "latency=-1s"} {
+        if _, err := Parse(spec); err == nil {
+            t.Errorf("Parse(%q) accepted", spec)
+        }
+    }
+}
+
+func TestPartialWrites(t *testing.T) {
+    local, remote := net.Pipe()
+    conn := New(Config{Partial: 1, Seed: 1}).Wrap(local)
+
+    frame := []byte(`{"type":"global_message","payload":{"content":"hello"}}` + "\n")
+    go func() {
+        for i := 0; i < 5; i++ {
+            if n, err := conn.Write(frame); n != len(frame) || err != nil {
+                t.Errorf("write: %d, %v", n, err)
+            }
+        }
+        conn.Close()
+    }()
+    got, err := io.ReadAll(remote)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := bytes.Repeat(frame, 5); !bytes.Equal(got, want) {
+        t.Errorf("read %q, want %q", got, want)
+    }
+}
+
+func TestLatency(t *testing.T) {
+    local, remote := net.Pipe()
+    defer remote.Close()
+    conn := New(Config{Latency: 30 * time.Millisecond, Seed: 1}).Wrap(local)
+    defer conn.Close()
+
+    go io.Copy(io.Discard, remote)
+    start := time.Now()
+    conn.Write([]byte("ping\n"))
+    if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+        t.Errorf("write took %s, want the 30ms latency", elapsed)
+    }
+}
+
+func TestDrop(t *testing.T) {
+    local, remote := net.Pipe()
+    defer remote.Close()
+    conn := New(Config{Drop: 1, Seed: 1}).Wrap(local)
+
+    if _, err := conn.Write([]byte("ping\n")); !errors.Is(err, ErrDropped) {
+        t.Fatalf("write: %v, want ErrDropped", err)
+    }
+    // the connection is closed, the other end sees it
+    if _, err := remote.Read(make([]byte, 8)); err != io.EOF {
+        t.Errorf("remote read: %v, want EOF", err)
+    }
+}
+
+func TestSameSeedSameFailures(t *testing.T) {
+    draws := func() []float64 {
+        conn := New(Config{Seed: 42}).Wrap(nil).(*chaosConn)
+        var values []float64
+        for i := 0; i < 5; i++ {
+            values = append(values, conn.draw())
+        }
+        return values
+    }
+    first, second := draws(), draws()
+    for i := range first {
+        if first[i] != second[i] {
+            t.Fatalf("draws %v and %v differ with the same seed", first, second)
+        }
+    }
+}
@@ -0,0 +1,291 @@
+// internal/client/config/config.go
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config holds the client preferences stored in ~/.config/textual/config.toml
+type Config struct {
+    // Theme is the name of the color preset (dark, light, high-contrast)
+    Theme string `toml:"theme"`
+    // Colors overrides single colors of the preset, e.g. primary = "#874BFD"
+    Colors map[string]string `toml:"colors,omitempty"`
+    // Hyperlinks makes links clickable in terminals supporting OSC 8
+    Hyperlinks bool `toml:"hyperlinks"`
+    // LinkPreviews shows the title and description the server found for the
+    // links of the messages
+    LinkPreviews bool `toml:"link_previews"`
+    // Mouse enables clicks and the wheel, the terminal then selects text
+    // only while shift is held
+    Mouse bool `toml:"mouse"`
+    // Locale is the language of the interface (en, fr), empty follows $LANG
+    Locale string `toml:"locale,omitempty"`
+    // TimeFormat is the clock of the message times, 24h (default) or 12h
+    TimeFormat string `toml:"time_format,omitempty"`
+    // Timezone writes the times in an IANA timezone such as Europe/Paris,
+    // empty follows the system
+    Timezone string `toml:"timezone,omitempty"`
+    // UserColors gives each sender a color of the theme, derived from their ID
+    UserColors bool `toml:"user_colors"`
+    // Avatars draws a block with the initial of the sender before their name
+    Avatars bool `toml:"avatars"`
+    // Encryption encrypts the direct messages end to end with the users who
+    // enabled it too, the keys stay in the data directory
+    Encryption bool `toml:"encryption"`
+    // DaySeparators draws a line with the date between the days of a conversation
+    DaySeparators bool `toml:"day_separators"`
+    // RelativeTimes writes "5m ago" instead of the time of the recent messages
+    RelativeTimes bool `toml:"relative_times"`
+    // LastProfile is the profile selected when the login screen opens
+    LastProfile string `toml:"last_profile,omitempty"`
+    Notifications Notifications `toml:"notifications"`
+    Voice Voice `toml:"voice"`
+    History History `toml:"history"`
+    Presence Presence `toml:"presence"`
+    // Profiles are the servers remembered by the login screen
+    Profiles []Profile `toml:"profiles,omitempty"`
+}
+
+// Profile is a server the login screen remembers, the password is never stored
+type Profile struct {
+    Name     string `toml:"name"`
+    Host     string `toml:"host"`
+    Port     string `toml:"port"`
+    Username string `toml:"username"`
+}
+
+// SaveProfile adds a profile or replaces the one with the same name, and
+// selects it for the next login
+func (c *Config) SaveProfile(profile Profile) {
+    c.LastProfile = profile.Name
+    for i := range c.Profiles {
+        if c.Profiles[i].Name == profile.Name {
+            c.Profiles[i] = profile
+            return
+        }
+    }
+    c.Profiles = append(c.Profiles, profile)
+}
+
+// Notifications controls how the user is alerted of direct messages and mentions
+type Notifications struct {
+    // Bell rings the terminal bell
+    Bell bool `toml:"bell"`
+    // Desktop shows a native notification (notify-send, osascript)
+    Desktop bool `toml:"desktop"`
+    // Muted lists the conversations never notified, written by the versions
+    // before Levels and read as the "none" level
+    Muted []string `toml:"muted,omitempty"`
+    // Levels is the notification level of the conversations (user or group
+    // IDs, "global"), "all" when missing
+    Levels map[string]string `toml:"levels,omitempty"`
+    // Sync keeps the levels on the server, they follow the user on every
+    // computer and the notifications stored by the server respect them
+    Sync bool `toml:"sync"`
+}
+
+// History controls the pages of messages requested from the server
+type History struct {
+    // PageSize is the number of messages of a history page
+    PageSize int `toml:"page_size"`
+    // ThreadPageSize is the number of replies loaded when a thread opens
+    ThreadPageSize int `toml:"thread_page_size"`
+    // Prefetch requests the previous page a screenful before the top of the
+    // conversation is reached
+    Prefetch bool `toml:"prefetch"`
+}
+
+// the server sends 100 messages at most by page
+const maxPageSize = 100
+
+// Page returns the size of the history pages, within what the server sends
+func (h History) Page() int {
+    return pageSize(h.PageSize, 50)
+}
+
+// ThreadPage returns the number of replies loaded when a thread opens
+func (h History) ThreadPage() int {
+    return pageSize(h.ThreadPageSize, 100)
+}
+
+func pageSize(size, def int) int {
+    if size <= 0 {
+        return def
+    }
+    return min(size, maxPageSize)
+}
+
+// Presence controls the status set without a command
+type Presence struct {
+    // AwayAfter sets the status away after this long without a key or a
+    // click, back online on the next one, 0 disables it
+    AwayAfter time.Duration `toml:"away_after"`
+}
+
+// Voice holds the commands recording and playing the voice messages, {file}
+// is replaced by the path of the recording
+type Voice struct {
+    // Recorder records until it is interrupted, to a WAV file
+    Recorder string `toml:"recorder"`
+    // Player plays a recording and exits
+    Player string `toml:"player"`
+}
+
+// defaultVoice uses the tools found on a standard install
+func defaultVoice() Voice {
+    if runtime.GOOS == "darwin" {
+        return Voice{
+            Recorder: "sox -q -d -r 16000 -c 1 -b 16 {file}",
+            Player:   "afplay {file}",
+        }
+    }
+    return Voice{
+        Recorder: "arecord -q -f S16_LE -r 16000 -c 1 {file}",
+        Player:   "aplay -q {file}",
+    }
+}
+
+// notification levels of a conversation
+const (
+    LevelAll      = "all"      // bell, desktop notification and unread badge
+    LevelMentions = "mentions" // bell and desktop notification for the mentions only
+    LevelBadge    = "badge"    // unread badge only
+    LevelNone     = "none"     // nothing, the conversation is muted
+)
+
+// Levels lists the notification levels, from the loudest
+var Levels = []string{LevelAll, LevelMentions, LevelBadge, LevelNone}
+
+// ValidLevel reports whether level is one of the notification levels
+func ValidLevel(level string) bool {
+    for _, l := range Levels {
+        if l == level {
+            return true
+        }
+    }
+    return false
+}
+
+// Level returns the notification level of a conversation
+func (n Notifications) Level(chatID string) string {
+    if level, ok := n.Levels[chatID]; ok {
+        return level
+    }
+    for _, id := range n.Muted {
+        if id == chatID {
+            return LevelNone
+        }
+    }
+    return LevelAll
+}
+
+// SetLevel changes the notification level of a conversation, "all" is not
+// written since it is the default
+func (n *Notifications) SetLevel(chatID, level string) {
+    kept := n.Muted[:0:0]
+    for _, id := range n.Muted {
+        if id != chatID {
+            kept = append(kept, id)
+        }
+    }
+    n.Muted = kept
+
+    if level == LevelAll {
+        delete(n.Levels, chatID)
+        return
+    }
+    if n.Levels == nil {
+        n.Levels = make(map[string]string)
+    }
+    n.Levels[chatID] = level
+}
+
+func Default() Config {
+    return Config{
+        Theme:      "dark",
+        Colors:     make(map[string]string),
+        Hyperlinks: true,
+        LinkPreviews: true,
+        Mouse:      true,
+        UserColors: true,
+        DaySeparators: true,
+        Notifications: Notifications{
+            Bell: true,
+        },
+        Voice: defaultVoice(),
+        History: History{
+            PageSize:       50,
+            ThreadPageSize: 100,
+            Prefetch:       true,
+        },
+        Presence: Presence{
+            AwayAfter: 10 * time.Minute,
+        },
+    }
+}
+
+// Path returns the location of the config file, honoring XDG_CONFIG_HOME
+func Path() (string, error) {
+    dir := os.Getenv("XDG_CONFIG_HOME")
+    if dir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", fmt.Errorf("failed to find home directory: %v", err)
+        }
+        dir = filepath.Join(home, ".config")
+    }
+    return filepath.Join(dir, "textual", "config.toml"), nil
+}
+
+// Load reads the config file. A missing file is not an error, the defaults
+// are returned instead
+func Load() (Config, error) {
+    cfg := Default()
+
+    path, err := Path()
+    if err != nil {
+        return cfg, err
+    }
+
+    if _, err := toml.DecodeFile(path, &cfg); err != nil {
+        if os.IsNotExist(err) {
+            return cfg, nil
+        }
+        return Default(), fmt.Errorf("failed to read %s: %v", path, err)
+    }
+
+    if cfg.Colors == nil {
+        cfg.Colors = make(map[string]string)
+    }
+    return cfg, nil
+}
+
+// Save writes the config file, creating its directory if needed
+func Save(cfg Config) error {
+    path, err := Path()
+    if err != nil {
+        return err
+    }
+
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return fmt.Errorf("failed to create config directory: %v", err)
+    }
+
+    file, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("failed to write %s: %v", path, err)
+    }
+    defer file.Close()
+
+    if err := toml.NewEncoder(file).Encode(cfg); err != nil {
+        return fmt.Errorf("failed to encode config: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,118 @@
+// internal/client/config/drafts.go
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DataDir returns the directory of the client state, honoring XDG_DATA_HOME
+func DataDir() (string, error) {
+    dir := os.Getenv("XDG_DATA_HOME")
+    if dir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", fmt.Errorf("failed to find home directory: %v", err)
+        }
+        dir = filepath.Join(home, ".local", "share")
+    }
+    return filepath.Join(dir, "textual"), nil
+}
+
+// LogPath returns the client log file, under XDG_STATE_HOME
+func LogPath() (string, error) {
+    dir := os.Getenv("XDG_STATE_HOME")
+    if dir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", fmt.Errorf("failed to find home directory: %v", err)
+        }
+        dir = filepath.Join(home, ".local", "state")
+    }
+    return filepath.Join(dir, "textual", "client.log"), nil
+}
+
+// KeysPath returns the file of the encryption keys of a user on this device
+func KeysPath(userID string) (string, error) {
+    dir, err := DataDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "keys-"+userID+".json"), nil
+}
+
+// AttachmentPath returns where a downloaded attachment is kept
+func AttachmentPath(id string) (string, error) {
+    dir, err := DataDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "attachments", filepath.Base(id)), nil
+}
+
+// HistoryPath returns the file of the messages of a conversation kept on
+// this computer, for the scrollback of the next sessions
+func HistoryPath(userID, chatID string) (string, error) {
+    dir, err := DataDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "history-"+userID, filepath.Base(chatID)+".json"), nil
+}
+
+func draftsPath(userID string) (string, error) {
+    dir, err := DataDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "drafts-"+userID+".json"), nil
+}
+
+// LoadDrafts reads the unsent inputs of a user, keyed by conversation
+func LoadDrafts(userID string) (map[string]string, error) {
+    drafts := make(map[string]string)
+
+    path, err := draftsPath(userID)
+    if err != nil {
+        return drafts, err
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return drafts, nil
+        }
+        return drafts, fmt.Errorf("failed to read drafts: %v", err)
+    }
+
+    if err := json.Unmarshal(data, &drafts); err != nil {
+        return make(map[string]string), fmt.Errorf("failed to decode drafts: %v", err)
+    }
+    return drafts, nil
+}
+
+// SaveDrafts writes the unsent inputs of a user
+func SaveDrafts(userID string, drafts map[string]string) error {
+    path, err := draftsPath(userID)
+    if err != nil {
+        return err
+    }
+
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return fmt.Errorf("failed to create data directory: %v", err)
+    }
+
+    data, err := json.Marshal(drafts)
+    if err != nil {
+        return fmt.Errorf("failed to encode drafts: %v", err)
+    }
+
+    // write then rename so a crash never leaves a truncated file
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return fmt.Errorf("failed to write drafts: %v", err)
+    }
+    return os.Rename(tmp, path)
+}
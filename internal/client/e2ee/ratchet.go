@@ -0,0 +1,301 @@
+// internal/client/e2ee/ratchet.go
+package e2ee
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// max number of message keys kept for messages arriving out of order
+const maxSkip = 100
+
+var errNoSendChain = errors.New("the session can't send before it received a message")
+
+// session is the double ratchet state shared with one device of a peer
+type session struct {
+    RootKey   []byte `json:"root_key"`
+    SendChain []byte `json:"send_chain,omitempty"`
+    RecvChain []byte `json:"recv_chain,omitempty"`
+    DHPrivate []byte `json:"dh_private"`
+    DHRemote  []byte `json:"dh_remote,omitempty"`
+    SendN     uint32 `json:"send_n"`
+    RecvN     uint32 `json:"recv_n"`
+    PrevN     uint32 `json:"prev_n"`
+    // message keys of the messages not received yet, by ratchet key and number
+    Skipped map[string][]byte `json:"skipped,omitempty"`
+    // AD binds the messages to the identity keys of both devices
+    AD []byte `json:"ad"`
+    // Init is sent with every message until the peer answers, it lets the
+    // peer build the session from its prekeys
+    Init *initHeader `json:"init,omitempty"`
+    // InitEphemeral is the ephemeral key of the initiator of a session built
+    // from an init header, a retransmitted init does not reset the session
+    InitEphemeral []byte `json:"init_ephemeral,omitempty"`
+}
+
+// header is sent in clear with each message, it is authenticated
+type header struct {
+    DH   []byte      `json:"dh"`
+    PN   uint32      `json:"pn"`
+    N    uint32      `json:"n"`
+    Init *initHeader `json:"init,omitempty"`
+}
+
+// initHeader carries what the responder needs to run X3DH
+type initHeader struct {
+    IdentityKey  []byte `json:"identity_key"`
+    SigningKey   []byte `json:"signing_key"`
+    Ephemeral    []byte `json:"ephemeral"`
+    PreKeyID     uint32 `json:"prekey_id"`
+    OneTimeKeyID uint32 `json:"one_time_key_id,omitempty"`
+}
+
+// newInitiatorSession starts the ratchet of the device that sends first, with
+// the prekey of the peer as its first remote ratchet key
+func newInitiatorSession(sk, ad, remotePreKey []byte, init *initHeader) (*session, error) {
+    priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+    if err != nil {
+        return nil, err
+    }
+    out, err := dh(priv.Bytes(), remotePreKey)
+    if err != nil {
+        return nil, err
+    }
+    rootKey, sendChain := kdfRoot(sk, out)
+    return &session{
+        RootKey:   rootKey,
+        SendChain: sendChain,
+        DHPrivate: priv.Bytes(),
+        DHRemote:  remotePreKey,
+        Skipped:   make(map[string][]byte),
+        AD:        ad,
+        Init:      init,
+    }, nil
+}
+
+// newResponderSession starts the ratchet of the device that received the
+// init, its signed prekey is its first ratchet key
+func newResponderSession(sk, ad, preKey, ephemeral []byte) *session {
+    return &session{
+        RootKey:       sk,
+        DHPrivate:     preKey,
+        Skipped:       make(map[string][]byte),
+        AD:            ad,
+        InitEphemeral: ephemeral,
+    }
+}
+
+func (s *session) encrypt(plaintext []byte) (headerBytes, ciphertext []byte, err error) {
+    if s.SendChain == nil {
+        return nil, nil, errNoSendChain
+    }
+    pub, err := publicKey(s.DHPrivate)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    var messageKey []byte
+    s.SendChain, messageKey = kdfChain(s.SendChain)
+    h := header{DH: pub, PN: s.PrevN, N: s.SendN, Init: s.Init}
+    s.SendN++
+
+    if headerBytes, err = json.Marshal(h); err != nil {
+        return nil, nil, err
+    }
+    ciphertext, err = seal(messageKey, plaintext, concat(s.AD, headerBytes))
+    return headerBytes, ciphertext, err
+}
+
+// decrypt opens a message, the caller keeps the session only on success so a
+// forged message can't break it
+func (s *session) decrypt(headerBytes, ciphertext []byte) ([]byte, error) {
+    var h header
+    if err := json.Unmarshal(headerBytes, &h); err != nil {
+        return nil, fmt.Errorf("invalid header: %v", err)
+    }
+    ad := concat(s.AD, headerBytes)
+
+    if key, ok := s.Skipped[skippedKey(h.DH, h.N)]; ok {
+        delete(s.Skipped, skippedKey(h.DH, h.N))
+        return open(key, ciphertext, ad)
+    }
+
+    if !bytes.Equal(h.DH, s.DHRemote) {
+        if err := s.skip(h.PN); err != nil {
+            return nil, err
+        }
+        if err := s.ratchet(h.DH); err != nil {
+            return nil, err
+        }
+    }
+    if err := s.skip(h.N); err != nil {
+        return nil, err
+    }
+
+    var messageKey []byte
+    s.RecvChain, messageKey = kdfChain(s.RecvChain)
+    s.RecvN++
+    plaintext, err := open(messageKey, ciphertext, ad)
+    if err != nil {
+        return nil, err
+    }
+    // the peer has the session, the init is not needed anymore
+    s.Init = nil
+    return plaintext, nil
+}
+
+// skip keeps the keys of the messages of the receiving chain before n
+func (s *session) skip(n uint32) error {
+    if s.RecvChain == nil {
+        return nil
+    }
+    if n > s.RecvN+maxSkip || len(s.Skipped) > 2*maxSkip {
+        return errors.New("too many skipped messages")
+    }
+    for s.RecvN < n {
+        var messageKey []byte
+        s.RecvChain, messageKey = kdfChain(s.RecvChain)
+        s.Skipped[skippedKey(s.DHRemote, s.RecvN)] = messageKey
+        s.RecvN++
+    }
+    return nil
+}
+
+// ratchet steps the root chain with the new ratchet key of the peer, then
+// with a new key of ours
+func (s *session) ratchet(remote []byte) error {
+    s.PrevN = s.SendN
+    s.SendN = 0
+    s.RecvN = 0
+    s.DHRemote = remote
+
+    out, err := dh(s.DHPrivate, remote)
+    if err != nil {
+        return err
+    }
+    s.RootKey, s.RecvChain = kdfRoot(s.RootKey, out)
+
+    priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+    if err != nil {
+        return err
+    }
+    s.DHPrivate = priv.Bytes()
+    if out, err = dh(s.DHPrivate, remote); err != nil {
+        return err
+    }
+    s.RootKey, s.SendChain = kdfRoot(s.RootKey, out)
+    return nil
+}
+
+// clone copies the session, the copy is ratcheted and kept if it worked
+func (s *session) clone() *session {
+    data, _ := json.Marshal(s)
+    var c session
+    json.Unmarshal(data, &c)
+    if c.Skipped == nil {
+        c.Skipped = make(map[string][]byte)
+    }
+    return &c
+}
+
+func skippedKey(dh []byte, n uint32) string {
+    return fmt.Sprintf("%s:%d", hex.EncodeToString(dh), n)
+}
+
+func dh(private, public []byte) ([]byte, error) {
+    priv, err := ecdh.X25519().NewPrivateKey(private)
+    if err != nil {
+        return nil, err
+    }
+    pub, err := ecdh.X25519().NewPublicKey(public)
+    if err != nil {
+        return nil, err
+    }
+    return priv.ECDH(pub)
+}
+
+func publicKey(private []byte) ([]byte, error) {
+    priv, err := ecdh.X25519().NewPrivateKey(private)
+    if err != nil {
+        return nil, err
+    }
+    return priv.PublicKey().Bytes(), nil
+}
+
+// kdfRoot derives the next root key and a chain key from a DH output
+func kdfRoot(rootKey, dhOut []byte) ([]byte, []byte) {
+    out := derive(dhOut, rootKey, "textual-ratchet", 64)
+    return out[:32], out[32:]
+}
+
+// kdfChain derives the next chain key and the key of one message
+func kdfChain(chainKey []byte) ([]byte, []byte) {
+    mac := hmac.New(sha256.New, chainKey)
+    mac.Write([]byte{0x02})
+    next := mac.Sum(nil)
+    mac = hmac.New(sha256.New, chainKey)
+    mac.Write([]byte{0x01})
+    return next, mac.Sum(nil)
+}
+
+func derive(secret, salt []byte, info string, size int) []byte {
+    out := make([]byte, size)
+    // the reader can't fail before 255 blocks
+    io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), out)
+    return out
+}
+
+// seal encrypts with AES-256-GCM, the key and nonce come from the message key
+// which is used once
+func seal(messageKey, plaintext, ad []byte) ([]byte, error) {
+    aead, nonce, err := messageCipher(messageKey)
+    if err != nil {
+        return nil, err
+    }
+    return aead.Seal(nil, nonce, plaintext, ad), nil
+}
+
+func open(messageKey, ciphertext, ad []byte) ([]byte, error) {
+    aead, nonce, err := messageCipher(messageKey)
+    if err != nil {
+        return nil, err
+    }
+    plaintext, err := aead.Open(nil, nonce, ciphertext, ad)
+    if err != nil {
+        return nil, errors.New("message authentication failed")
+    }
+    return plaintext, nil
+}
+
+func messageCipher(messageKey []byte) (cipher.AEAD, []byte, error) {
+    out := derive(messageKey, nil, "textual-message", 32+12)
+    block, err := aes.NewCipher(out[:32])
+    if err != nil {
+        return nil, nil, err
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, nil, err
+    }
+    return aead, out[32:], nil
+}
+
+func concat(parts ...[]byte) []byte {
+    var out []byte
+    for _, part := range parts {
+        out = append(out, part...)
+    }
+    return out
+}
@@ -0,0 +1,136 @@
+// internal/client/e2ee/ratchet_test.go
+package e2ee
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type sealed struct {
+    header     []byte
+    ciphertext []byte
+}
+
+func mustEncrypt(t *testing.T, s *session, plaintext string) sealed {
+    t.Helper()
+    h, ciphertext, err := s.encrypt([]byte(plaintext))
+    if err != nil {
+        t.Fatalf("encrypt %q: %v", plaintext, err)
+    }
+    return sealed{h, ciphertext}
+}
+
+func mustDecrypt(t *testing.T, s *session, m sealed, want string) {
+    t.Helper()
+    plaintext, err := s.decrypt(m.header, m.ciphertext)
+    if err != nil {
+        t.Fatalf("decrypt %q: %v", want, err)
+    }
+    if string(plaintext) != want {
+        t.Fatalf("decrypt = %q, want %q", plaintext, want)
+    }
+}
+
+func TestRatchetRoundTrip(t *testing.T) {
+    alice, bob := handshake(t, true)
+
+    if _, _, err := bob.encrypt([]byte("too early")); !errors.Is(err, errNoSendChain) {
+        t.Fatalf("responder sending first: err = %v, want %v", err, errNoSendChain)
+    }
+
+    // several turns so both sides ratchet more than once
+    for turn := 0; turn < 3; turn++ {
+        for i := 0; i < 2; i++ {
+            text := fmt.Sprintf("alice %d.%d", turn, i)
+            mustDecrypt(t, bob, mustEncrypt(t, alice, text), text)
+        }
+        text := fmt.Sprintf("bob %d", turn)
+        mustDecrypt(t, alice, mustEncrypt(t, bob, text), text)
+    }
+    if alice.Init != nil {
+        t.Error("the initiator still sends its init header after an answer")
+    }
+}
+
+func TestRatchetOutOfOrder(t *testing.T) {
+    alice, bob := handshake(t, false)
+    mustDecrypt(t, bob, mustEncrypt(t, alice, "first"), "first")
+
+    first := mustEncrypt(t, bob, "b1")
+    second := mustEncrypt(t, bob, "b2")
+    third := mustEncrypt(t, bob, "b3")
+    mustDecrypt(t, alice, third, "b3")
+    mustDecrypt(t, alice, first, "b1")
+
+    // a message of the previous chain arriving after a ratchet step
+    reply := mustEncrypt(t, alice, "a2")
+    mustDecrypt(t, bob, reply, "a2")
+    mustDecrypt(t, alice, mustEncrypt(t, bob, "b4"), "b4")
+    mustDecrypt(t, alice, second, "b2")
+
+    if len(alice.Skipped) != 0 {
+        t.Errorf("%d skipped keys kept after every message arrived", len(alice.Skipped))
+    }
+}
+
+func TestRatchetReplay(t *testing.T) {
+    alice, bob := handshake(t, false)
+    m := mustEncrypt(t, alice, "once")
+    mustDecrypt(t, bob, m, "once")
+    if _, err := bob.clone().decrypt(m.header, m.ciphertext); err == nil {
+        t.Error("a message was decrypted twice")
+    }
+
+    late := mustEncrypt(t, alice, "late")
+    mustDecrypt(t, bob, mustEncrypt(t, alice, "next"), "next")
+    mustDecrypt(t, bob, late, "late")
+    if _, err := bob.clone().decrypt(late.header, late.ciphertext); err == nil {
+        t.Error("a skipped message was decrypted twice")
+    }
+}
+
+func TestRatchetTooManySkipped(t *testing.T) {
+    alice, bob := handshake(t, false)
+    mustDecrypt(t, bob, mustEncrypt(t, alice, "first"), "first")
+
+    for i := 0; i < maxSkip+1; i++ {
+        mustEncrypt(t, alice, "lost")
+    }
+    m := mustEncrypt(t, alice, "too far")
+    if _, err := bob.clone().decrypt(m.header, m.ciphertext); err == nil {
+        t.Errorf("decrypted a message after %d lost ones", maxSkip+1)
+    }
+}
+
+func TestRatchetTampered(t *testing.T) {
+    alice, bob := handshake(t, false)
+    m := mustEncrypt(t, alice, "hello")
+
+    ciphertext := append([]byte(nil), m.ciphertext...)
+    ciphertext[0] ^= 1
+    if _, err := bob.clone().decrypt(m.header, ciphertext); err == nil {
+        t.Error("decrypted a modified ciphertext")
+    }
+
+    // the header is authenticated with the message
+    other := mustEncrypt(t, alice, "other")
+    if _, err := bob.clone().decrypt(other.header, m.ciphertext); err == nil {
+        t.Error("decrypted a ciphertext under another header")
+    }
+
+    // the failures left the session usable
+    mustDecrypt(t, bob, m, "hello")
+}
+
+func TestSessionClone(t *testing.T) {
+    alice, bob := handshake(t, false)
+    mustDecrypt(t, bob, mustEncrypt(t, alice, "first"), "first")
+
+    saved := alice.clone()
+    m := mustEncrypt(t, alice, "after")
+    if saved.SendN == alice.SendN {
+        t.Fatal("the clone shares the state of the session")
+    }
+    mustDecrypt(t, bob, m, "after")
+}
@@ -0,0 +1,260 @@
+// internal/client/e2ee/senderkey.go
+package e2ee
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"textual/pkg/protocol"
+)
+
+// old sender keys kept per member, for the messages sent before a rotation
+// that arrive after it
+const maxSenderKeys = 4
+
+// ErrNoSenderKey is returned for a group message whose sender key did not
+// arrive yet, it is sent apart over the pairwise sessions
+var ErrNoSenderKey = errors.New("no sender key for this member")
+
+// senderKey is the chain of one member in a group. Every message key comes
+// from the chain key, which then moves forward; the messages are signed so
+// the members can't forge the messages of each other
+type senderKey struct {
+    KeyID     uint32 `json:"key_id"`
+    ChainKey  []byte `json:"chain_key"`
+    Iteration uint32 `json:"iteration"`
+    // SigningKey is private for our own keys, public for the ones received
+    SigningKey []byte `json:"signing_key"`
+    // message keys of the messages not received yet, by iteration
+    Skipped map[uint32][]byte `json:"skipped,omitempty"`
+}
+
+// ownSenderKey is our key in a group with the members it was sent to, a new
+// key is made when they change
+type ownSenderKey struct {
+    senderKey
+    Members []string `json:"members"`
+}
+
+// senderKeyDistribution is what a member receives to read our messages
+type senderKeyDistribution struct {
+    GroupID    string `json:"group_id"`
+    KeyID      uint32 `json:"key_id"`
+    Iteration  uint32 `json:"iteration"`
+    ChainKey   []byte `json:"chain_key"`
+    SigningKey []byte `json:"signing_key"`
+}
+
+// groupHeader is sent in clear with each group message, it is authenticated
+type groupHeader struct {
+    KeyID     uint32 `json:"key_id"`
+    Iteration uint32 `json:"iteration"`
+}
+
+// GroupKey returns the sender key to send to the other members of a group.
+// It is a new key when the members are not the ones who received the current
+// one, so a member who left can't read what follows and a new member can't
+// read what came before; distribution is empty when nothing must be sent
+func (s *Store) GroupKey(groupID string, members []string) (distribution string, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    members = append([]string(nil), members...)
+    sort.Strings(members)
+    own := s.state.Groups[groupID]
+    if own != nil && equalMembers(own.Members, members) {
+        return "", nil
+    }
+
+    chainKey := make([]byte, 32)
+    if _, err := rand.Read(chainKey); err != nil {
+        return "", err
+    }
+    _, signing, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        return "", err
+    }
+    keyID := uint32(1)
+    if own != nil {
+        keyID = own.KeyID + 1
+    }
+    own = &ownSenderKey{
+        senderKey: senderKey{KeyID: keyID, ChainKey: chainKey, SigningKey: signing},
+        Members:   members,
+    }
+    s.state.Groups[groupID] = own
+
+    data, err := json.Marshal(senderKeyDistribution{
+        GroupID:    groupID,
+        KeyID:      own.KeyID,
+        Iteration:  own.Iteration,
+        ChainKey:   own.ChainKey,
+        SigningKey: signing.Public().(ed25519.PublicKey),
+    })
+    if err != nil {
+        return "", err
+    }
+    return string(data), s.save()
+}
+
+// ForgetGroup drops our key and the keys of the members, once out of a group
+func (s *Store) ForgetGroup(groupID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.state.Groups, groupID)
+    delete(s.state.SenderKeys, groupID)
+    return s.save()
+}
+
+// ReceiveSenderKey stores the sender key of a device of a member, opened
+// from a pairwise message, and returns its group
+func (s *Store) ReceiveSenderKey(userID, deviceID, distribution string) (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var d senderKeyDistribution
+    if err := json.Unmarshal([]byte(distribution), &d); err != nil {
+        return "", fmt.Errorf("invalid sender key: %v", err)
+    }
+    if d.GroupID == "" || len(d.ChainKey) != 32 || len(d.SigningKey) != ed25519.PublicKeySize {
+        return "", errors.New("invalid sender key")
+    }
+
+    members := s.state.SenderKeys[d.GroupID]
+    if members == nil {
+        members = make(map[string][]*senderKey)
+        s.state.SenderKeys[d.GroupID] = members
+    }
+    device := userID + "/" + deviceID
+    keys := members[device]
+    for i, key := range keys {
+        if key.KeyID == d.KeyID {
+            keys = append(keys[:i], keys[i+1:]...)
+            break
+        }
+    }
+    keys = append(keys, &senderKey{KeyID: d.KeyID, ChainKey: d.ChainKey, Iteration: d.Iteration, SigningKey: d.SigningKey})
+    if len(keys) > maxSenderKeys {
+        keys = keys[len(keys)-maxSenderKeys:]
+    }
+    members[device] = keys
+    return d.GroupID, s.save()
+}
+
+// EncryptGroup encrypts a message with our sender key of the group, GroupKey
+// must have been called first
+func (s *Store) EncryptGroup(groupID, plaintext string) (protocol.EncryptedEnvelope, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    own := s.state.Groups[groupID]
+    if own == nil {
+        return protocol.EncryptedEnvelope{}, errors.New("no sender key for this group")
+    }
+    headerBytes, err := json.Marshal(groupHeader{KeyID: own.KeyID, Iteration: own.Iteration})
+    if err != nil {
+        return protocol.EncryptedEnvelope{}, err
+    }
+    next, messageKey := kdfChain(own.ChainKey)
+    ciphertext, err := seal(messageKey, []byte(plaintext), headerBytes)
+    if err != nil {
+        return protocol.EncryptedEnvelope{}, err
+    }
+    own.ChainKey = next
+    own.Iteration++
+
+    signature := ed25519.Sign(ed25519.PrivateKey(own.SigningKey), concat(headerBytes, ciphertext))
+    return protocol.EncryptedEnvelope{Header: headerBytes, Ciphertext: concat(ciphertext, signature)}, s.save()
+}
+
+// DecryptGroup opens a group message of a device of a member with its sender
+// key, ErrNoSenderKey tells to wait for the key
+func (s *Store) DecryptGroup(groupID, userID, deviceID string, env protocol.EncryptedEnvelope) (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var h groupHeader
+    if err := json.Unmarshal(env.Header, &h); err != nil {
+        return "", fmt.Errorf("invalid header: %v", err)
+    }
+    var key *senderKey
+    for _, k := range s.state.SenderKeys[groupID][userID+"/"+deviceID] {
+        if k.KeyID == h.KeyID {
+            key = k
+        }
+    }
+    if key == nil {
+        return "", ErrNoSenderKey
+    }
+
+    if len(env.Ciphertext) < ed25519.SignatureSize {
+        return "", errors.New("message too short")
+    }
+    split := len(env.Ciphertext) - ed25519.SignatureSize
+    ciphertext, signature := env.Ciphertext[:split], env.Ciphertext[split:]
+    if !ed25519.Verify(key.SigningKey, concat(env.Header, ciphertext), signature) {
+        return "", errors.New("the message is not signed by its sender")
+    }
+
+    // the chain only moves forward once the message is authenticated
+    chainKey, iteration := key.ChainKey, key.Iteration
+    skipped := make(map[uint32][]byte)
+    var messageKey []byte
+    switch {
+    case h.Iteration < iteration:
+        var ok bool
+        if messageKey, ok = key.Skipped[h.Iteration]; !ok {
+            return "", errors.New("message already received")
+        }
+    case h.Iteration-iteration > maxSkip:
+        return "", errors.New("too many messages skipped")
+    default:
+        for iteration < h.Iteration {
+            var mk []byte
+            chainKey, mk = kdfChain(chainKey)
+            skipped[iteration] = mk
+            iteration++
+        }
+        chainKey, messageKey = kdfChain(chainKey)
+        iteration++
+    }
+
+    plaintext, err := open(messageKey, ciphertext, env.Header)
+    if err != nil {
+        return "", err
+    }
+
+    if h.Iteration < key.Iteration {
+        delete(key.Skipped, h.Iteration)
+    } else {
+        key.ChainKey, key.Iteration = chainKey, iteration
+        if key.Skipped == nil {
+            key.Skipped = make(map[uint32][]byte)
+        }
+        for n, mk := range skipped {
+            key.Skipped[n] = mk
+        }
+        // the keys of messages too far behind are dropped
+        for n := range key.Skipped {
+            if n+maxSkip < key.Iteration {
+                delete(key.Skipped, n)
+            }
+        }
+    }
+    return string(plaintext), s.save()
+}
+
+func equalMembers(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,188 @@
+// internal/client/e2ee/senderkey_test.go
+package e2ee
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"textual/pkg/protocol"
+)
+
+// groupKey returns the sender key of from for the members, failing when
+// none is made
+func groupKey(t *testing.T, from *Store, groupID string, members ...string) string {
+    t.Helper()
+    distribution, err := from.GroupKey(groupID, members)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if distribution == "" {
+        t.Fatalf("no new sender key for %v", members)
+    }
+    return distribution
+}
+
+func encryptGroup(t *testing.T, s *Store, groupID, plaintext string) protocol.EncryptedEnvelope {
+    t.Helper()
+    env, err := s.EncryptGroup(groupID, plaintext)
+    if err != nil {
+        t.Fatalf("encrypt %q: %v", plaintext, err)
+    }
+    return env
+}
+
+func decryptGroup(t *testing.T, s *Store, groupID, from string, sender *Store, env protocol.EncryptedEnvelope, want string) {
+    t.Helper()
+    plaintext, err := s.DecryptGroup(groupID, from, sender.DeviceID(), env)
+    if err != nil {
+        t.Fatalf("decrypt %q: %v", want, err)
+    }
+    if plaintext != want {
+        t.Fatalf("decrypt = %q, want %q", plaintext, want)
+    }
+}
+
+func TestSenderKeyRotation(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    carol := openStore(t, filepath.Join(dir, "carol.json"))
+
+    first := groupKey(t, alice, "g", "carol", "bob", "alice")
+    for name, s := range map[string]*Store{"bob": bob, "carol": carol} {
+        if groupID, err := s.ReceiveSenderKey("alice", alice.DeviceID(), first); err != nil || groupID != "g" {
+            t.Fatalf("%s received the sender key of %s: %v", name, groupID, err)
+        }
+    }
+    // the same members in another order keep the key
+    if distribution, err := alice.GroupKey("g", []string{"alice", "bob", "carol"}); err != nil || distribution != "" {
+        t.Fatalf("new sender key for the same members: %q %v", distribution, err)
+    }
+    hello := encryptGroup(t, alice, "g", "hello")
+    decryptGroup(t, carol, "g", "alice", alice, hello, "hello")
+
+    // sent before carol leaves, received after the rotation
+    late := encryptGroup(t, alice, "g", "late")
+    second := groupKey(t, alice, "g", "alice", "bob")
+    after := encryptGroup(t, alice, "g", "after")
+    if _, err := carol.DecryptGroup("g", "alice", alice.DeviceID(), after); !errors.Is(err, ErrNoSenderKey) {
+        t.Fatalf("carol decrypted a message sent after leaving: %v", err)
+    }
+    if _, err := bob.DecryptGroup("g", "alice", alice.DeviceID(), after); !errors.Is(err, ErrNoSenderKey) {
+        t.Fatalf("decrypt before the new key arrived: %v, want %v", err, ErrNoSenderKey)
+    }
+    if _, err := bob.ReceiveSenderKey("alice", alice.DeviceID(), second); err != nil {
+        t.Fatal(err)
+    }
+    decryptGroup(t, bob, "g", "alice", alice, after, "after")
+    decryptGroup(t, bob, "g", "alice", alice, late, "late")
+
+    // a new member gets a new key, not the messages before it
+    third := groupKey(t, alice, "g", "alice", "bob", "dave")
+    var d senderKeyDistribution
+    if err := json.Unmarshal([]byte(third), &d); err != nil {
+        t.Fatal(err)
+    }
+    var s senderKeyDistribution
+    json.Unmarshal([]byte(second), &s)
+    if d.KeyID != s.KeyID+1 || string(d.ChainKey) == string(s.ChainKey) {
+        t.Errorf("key %d after key %d, the chain must be new", d.KeyID, s.KeyID)
+    }
+}
+
+func TestSenderKeyOutOfOrder(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    if _, err := bob.ReceiveSenderKey("alice", alice.DeviceID(), groupKey(t, alice, "g", "alice", "bob")); err != nil {
+        t.Fatal(err)
+    }
+
+    var envs []protocol.EncryptedEnvelope
+    texts := []string{"m0", "m1", "m2", "m3"}
+    for _, text := range texts {
+        envs = append(envs, encryptGroup(t, alice, "g", text))
+    }
+    for _, i := range []int{3, 1, 0, 2} {
+        decryptGroup(t, bob, "g", "alice", alice, envs[i], texts[i])
+    }
+    if key := bob.state.SenderKeys["g"]["alice/"+alice.DeviceID()][0]; len(key.Skipped) != 0 || key.Iteration != 4 {
+        t.Errorf("iteration %d with %d skipped keys after every message arrived", key.Iteration, len(key.Skipped))
+    }
+
+    for i := 0; i < maxSkip+1; i++ {
+        encryptGroup(t, alice, "g", "lost")
+    }
+    if _, err := bob.DecryptGroup("g", "alice", alice.DeviceID(), encryptGroup(t, alice, "g", "too far")); err == nil {
+        t.Errorf("decrypted a message after %d lost ones", maxSkip+1)
+    }
+}
+
+func TestSenderKeyForged(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    distribution := groupKey(t, alice, "g", "alice", "bob", "mallory")
+    if _, err := bob.ReceiveSenderKey("alice", alice.DeviceID(), distribution); err != nil {
+        t.Fatal(err)
+    }
+
+    // mallory is a member: the chain key of alice is known to every member,
+    // the signing key is not
+    var d senderKeyDistribution
+    if err := json.Unmarshal([]byte(distribution), &d); err != nil {
+        t.Fatal(err)
+    }
+    header, _ := json.Marshal(groupHeader{KeyID: d.KeyID, Iteration: d.Iteration})
+    _, messageKey := kdfChain(d.ChainKey)
+    ciphertext, err := seal(messageKey, []byte("send me your password"), header)
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, signing, _ := ed25519.GenerateKey(rand.Reader)
+    forged := protocol.EncryptedEnvelope{Header: header, Ciphertext: concat(ciphertext, ed25519.Sign(signing, concat(header, ciphertext)))}
+    if _, err := bob.DecryptGroup("g", "alice", alice.DeviceID(), forged); err == nil {
+        t.Fatal("decrypted a message signed by another member")
+    }
+
+    genuine := encryptGroup(t, alice, "g", "hello")
+    tampered := genuine
+    tampered.Ciphertext = append([]byte(nil), genuine.Ciphertext...)
+    tampered.Ciphertext[0] ^= 1
+    if _, err := bob.DecryptGroup("g", "alice", alice.DeviceID(), tampered); err == nil {
+        t.Fatal("decrypted a modified message")
+    }
+    // the failures did not move the chain
+    decryptGroup(t, bob, "g", "alice", alice, genuine, "hello")
+}
+
+func TestSenderKeyReplay(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    if _, err := bob.ReceiveSenderKey("alice", alice.DeviceID(), groupKey(t, alice, "g", "alice", "bob")); err != nil {
+        t.Fatal(err)
+    }
+
+    once := encryptGroup(t, alice, "g", "once")
+    decryptGroup(t, bob, "g", "alice", alice, once, "once")
+    if _, err := bob.DecryptGroup("g", "alice", alice.DeviceID(), once); err == nil {
+        t.Error("a message was decrypted twice")
+    }
+
+    late := encryptGroup(t, alice, "g", "late")
+    decryptGroup(t, bob, "g", "alice", alice, encryptGroup(t, alice, "g", "next"), "next")
+    decryptGroup(t, bob, "g", "alice", alice, late, "late")
+    if _, err := bob.DecryptGroup("g", "alice", alice.DeviceID(), late); err == nil {
+        t.Error("a skipped message was decrypted twice")
+    }
+
+    // the chain read back from the file still refuses it
+    bob2 := openStore(t, filepath.Join(dir, "bob.json"))
+    if _, err := bob2.DecryptGroup("g", "alice", alice.DeviceID(), once); err == nil {
+        t.Error("a message was decrypted twice after a restart")
+    }
+}
@@ -0,0 +1,347 @@
+// internal/client/e2ee/store.go
+package e2ee
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"textual/pkg/protocol"
+)
+
+// one-time prekeys kept published, topped up on each connection
+const (
+    minOneTimeKeys = 10
+    oneTimeKeys    = 20
+)
+
+// ErrNoSession is returned for a message of a device we have no session with
+// and that carries no init header
+var ErrNoSession = errors.New("no session with this device")
+
+// Store holds the keys of this device and the sessions with the devices of
+// the peers, saved in a file readable by the user only
+type Store struct {
+    mu    sync.Mutex
+    path  string
+    state state
+}
+
+type state struct {
+    DeviceID    string            `json:"device_id"`
+    IdentityKey []byte            `json:"identity_key"`
+    SigningKey  []byte            `json:"signing_key"`
+    PreKeyID    uint32            `json:"prekey_id"`
+    PreKey      []byte            `json:"prekey"`
+    OneTimeKeys map[uint32][]byte `json:"one_time_keys"`
+    NextKeyID   uint32            `json:"next_key_id"`
+    // Peers are the devices met, by user then device ID
+    Peers map[string]map[string]*peer `json:"peers"`
+    // Groups are our sender keys, by group ID
+    Groups map[string]*ownSenderKey `json:"groups,omitempty"`
+    // SenderKeys are the keys of the members, by group then user/device,
+    // the newest last
+    SenderKeys map[string]map[string][]*senderKey `json:"sender_keys,omitempty"`
+}
+
+// peer is a device of another user, its identity is trusted on first use
+type peer struct {
+    IdentityKey []byte   `json:"identity_key"`
+    SigningKey  []byte   `json:"signing_key"`
+    Session     *session `json:"session,omitempty"`
+}
+
+// Open loads the keys of the file, a new identity is created the first time
+func Open(path string) (*Store, error) {
+    s := &Store{path: path}
+    data, err := os.ReadFile(path)
+    switch {
+    case err == nil:
+        if err := json.Unmarshal(data, &s.state); err != nil {
+            return nil, fmt.Errorf("failed to decode the keys: %v", err)
+        }
+    case os.IsNotExist(err):
+        if err := s.generate(); err != nil {
+            return nil, fmt.Errorf("failed to generate the keys: %v", err)
+        }
+    default:
+        return nil, fmt.Errorf("failed to read the keys: %v", err)
+    }
+
+    if s.state.OneTimeKeys == nil {
+        s.state.OneTimeKeys = make(map[uint32][]byte)
+    }
+    if s.state.Peers == nil {
+        s.state.Peers = make(map[string]map[string]*peer)
+    }
+    if s.state.Groups == nil {
+        s.state.Groups = make(map[string]*ownSenderKey)
+    }
+    if s.state.SenderKeys == nil {
+        s.state.SenderKeys = make(map[string]map[string][]*senderKey)
+    }
+    if err := s.topUp(); err != nil {
+        return nil, err
+    }
+    return s, s.save()
+}
+
+// generate creates the identity of this device
+func (s *Store) generate() error {
+    id := make([]byte, 8)
+    if _, err := rand.Read(id); err != nil {
+        return err
+    }
+    identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+    if err != nil {
+        return err
+    }
+    _, signing, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        return err
+    }
+    preKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+    if err != nil {
+        return err
+    }
+
+    s.state = state{
+        DeviceID:    hex.EncodeToString(id),
+        IdentityKey: identity.Bytes(),
+        SigningKey:  signing,
+        PreKeyID:    1,
+        PreKey:      preKey.Bytes(),
+        NextKeyID:   1,
+    }
+    return nil
+}
+
+// topUp generates one-time prekeys when few are left
+func (s *Store) topUp() error {
+    if len(s.state.OneTimeKeys) >= minOneTimeKeys {
+        return nil
+    }
+    for len(s.state.OneTimeKeys) < oneTimeKeys {
+        key, err := ecdh.X25519().GenerateKey(rand.Reader)
+        if err != nil {
+            return err
+        }
+        s.state.OneTimeKeys[s.state.NextKeyID] = key.Bytes()
+        s.state.NextKeyID++
+    }
+    return nil
+}
+
+// save writes then renames so a crash never leaves a truncated file
+func (s *Store) save() error {
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return fmt.Errorf("failed to create the keys directory: %v", err)
+    }
+    data, err := json.Marshal(s.state)
+    if err != nil {
+        return fmt.Errorf("failed to encode the keys: %v", err)
+    }
+    tmp := s.path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return fmt.Errorf("failed to write the keys: %v", err)
+    }
+    return os.Rename(tmp, s.path)
+}
+
+func (s *Store) DeviceID() string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.state.DeviceID
+}
+
+// Bundle returns the public keys to publish, with the one-time prekeys
+func (s *Store) Bundle() protocol.KeyBundlePayload {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if err := s.topUp(); err == nil {
+        s.save()
+    }
+    identity, _ := publicKey(s.state.IdentityKey)
+    preKey, _ := publicKey(s.state.PreKey)
+    signing := ed25519.PrivateKey(s.state.SigningKey)
+
+    payload := protocol.KeyBundlePayload{
+        Devices: []protocol.DeviceBundle{{
+            DeviceID:       s.state.DeviceID,
+            IdentityKey:    identity,
+            SigningKey:     signing.Public().(ed25519.PublicKey),
+            SignedPreKey:   preKey,
+            SignedPreKeyID: s.state.PreKeyID,
+            Signature:      ed25519.Sign(signing, preKeyMessage(s.state.PreKeyID, preKey)),
+        }},
+    }
+    for id, key := range s.state.OneTimeKeys {
+        pub, err := publicKey(key)
+        if err != nil {
+            continue
+        }
+        payload.OneTimePreKeys = append(payload.OneTimePreKeys, protocol.OneTimePreKey{ID: id, Key: pub})
+    }
+    sort.Slice(payload.OneTimePreKeys, func(i, j int) bool {
+        return payload.OneTimePreKeys[i].ID < payload.OneTimePreKeys[j].ID
+    })
+    return payload
+}
+
+// KnowsPeer tells if a device of the user was met, a peer that had keys is
+// never written to in clear again
+func (s *Store) KnowsPeer(userID string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return len(s.state.Peers[userID]) > 0
+}
+
+// Encrypt encrypts a message for every device of the user. The sessions are
+// created from the bundles for the devices not met yet; changed reports a
+// device whose identity is not the one seen before
+func (s *Store) Encrypt(userID string, bundles []protocol.DeviceBundle, plaintext string) (envelopes []protocol.EncryptedEnvelope, changed bool, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    devices := s.state.Peers[userID]
+    if devices == nil {
+        devices = make(map[string]*peer)
+        s.state.Peers[userID] = devices
+    }
+
+    signing := ed25519.PrivateKey(s.state.SigningKey).Public().(ed25519.PublicKey)
+    for _, b := range bundles {
+        known, ok := devices[b.DeviceID]
+        if ok && known.Session != nil && bytes.Equal(known.IdentityKey, b.IdentityKey) && bytes.Equal(known.SigningKey, b.SigningKey) {
+            continue
+        }
+        sess, err := x3dhInitiate(s.state.IdentityKey, signing, b)
+        if err != nil {
+            return nil, false, fmt.Errorf("invalid keys for device %s: %v", b.DeviceID, err)
+        }
+        if ok && (!bytes.Equal(known.IdentityKey, b.IdentityKey) || !bytes.Equal(known.SigningKey, b.SigningKey)) {
+            changed = true
+        }
+        devices[b.DeviceID] = &peer{IdentityKey: b.IdentityKey, SigningKey: b.SigningKey, Session: sess}
+    }
+
+    for deviceID, p := range devices {
+        if p.Session == nil {
+            continue
+        }
+        header, ciphertext, err := p.Session.encrypt([]byte(plaintext))
+        if err != nil {
+            return nil, changed, fmt.Errorf("failed to encrypt for device %s: %v", deviceID, err)
+        }
+        envelopes = append(envelopes, protocol.EncryptedEnvelope{DeviceID: deviceID, Header: header, Ciphertext: ciphertext})
+    }
+    if len(envelopes) == 0 {
+        return nil, changed, errors.New("no device to encrypt for")
+    }
+    return envelopes, changed, s.save()
+}
+
+// Decrypt opens a message of a device of the user. A message carrying an init
+// header creates the session; changed reports a device whose identity is not
+// the one seen before
+func (s *Store) Decrypt(userID, deviceID string, env protocol.EncryptedEnvelope) (plaintext string, changed bool, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var h header
+    if err := json.Unmarshal(env.Header, &h); err != nil {
+        return "", false, fmt.Errorf("invalid header: %v", err)
+    }
+
+    devices := s.state.Peers[userID]
+    if devices == nil {
+        devices = make(map[string]*peer)
+    }
+    known := devices[deviceID]
+
+    var sess *session
+    usedOneTimeKey := uint32(0)
+    switch {
+    case known != nil && known.Session != nil && (h.Init == nil || bytes.Equal(h.Init.Ephemeral, known.Session.InitEphemeral)):
+        sess = known.Session.clone()
+    case h.Init != nil:
+        if h.Init.PreKeyID != s.state.PreKeyID {
+            return "", false, errors.New("unknown prekey")
+        }
+        var oneTimeKey []byte
+        if h.Init.OneTimeKeyID != 0 {
+            key, ok := s.state.OneTimeKeys[h.Init.OneTimeKeyID]
+            if !ok {
+                return "", false, errors.New("one-time prekey already used")
+            }
+            oneTimeKey, usedOneTimeKey = key, h.Init.OneTimeKeyID
+        }
+        if sess, err = x3dhRespond(s.state.IdentityKey, s.state.PreKey, oneTimeKey, h.Init); err != nil {
+            return "", false, err
+        }
+    default:
+        return "", false, ErrNoSession
+    }
+
+    data, err := sess.decrypt(env.Header, env.Ciphertext)
+    if err != nil {
+        return "", false, err
+    }
+
+    if known == nil || sess.InitEphemeral != nil && h.Init != nil && !bytes.Equal(known.IdentityKey, h.Init.IdentityKey) {
+        if known != nil {
+            changed = true
+        }
+        known = &peer{IdentityKey: h.Init.IdentityKey, SigningKey: h.Init.SigningKey}
+    }
+    known.Session = sess
+    devices[deviceID] = known
+    s.state.Peers[userID] = devices
+    if usedOneTimeKey != 0 {
+        delete(s.state.OneTimeKeys, usedOneTimeKey)
+    }
+    return string(data), changed, s.save()
+}
+
+// Fingerprint returns the fingerprint of this device
+func (s *Store) Fingerprint() string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    identity, _ := publicKey(s.state.IdentityKey)
+    return fingerprint(ed25519.PrivateKey(s.state.SigningKey).Public().(ed25519.PublicKey), identity)
+}
+
+// PeerFingerprints returns the fingerprints of the devices of a user, by
+// device ID
+func (s *Store) PeerFingerprints(userID string) map[string]string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    fingerprints := make(map[string]string)
+    for deviceID, p := range s.state.Peers[userID] {
+        fingerprints[deviceID] = fingerprint(p.SigningKey, p.IdentityKey)
+    }
+    return fingerprints
+}
+
+// fingerprint is the start of the hash of the identity keys of a device, in
+// groups of four hex digits to compare them aloud
+func fingerprint(signingKey, identityKey []byte) string {
+    sum := sha256.Sum256(concat(signingKey, identityKey))
+    digits := hex.EncodeToString(sum[:20])
+    groups := make([]string, 0, len(digits)/4)
+    for i := 0; i < len(digits); i += 4 {
+        groups = append(groups, digits[i:i+4])
+    }
+    return strings.Join(groups, " ")
+}
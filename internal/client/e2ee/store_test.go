@@ -0,0 +1,219 @@
+// internal/client/e2ee/store_test.go
+package e2ee
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"textual/pkg/protocol"
+)
+
+func openStore(t *testing.T, path string) *Store {
+    t.Helper()
+    s, err := Open(path)
+    if err != nil {
+        t.Fatalf("open %s: %v", path, err)
+    }
+    return s
+}
+
+// published is the bundle the server hands out for a device, with one of
+// its one-time prekeys when there is one left
+func published(s *Store, oneTime int) protocol.DeviceBundle {
+    payload := s.Bundle()
+    b := payload.Devices[0]
+    if oneTime < len(payload.OneTimePreKeys) {
+        b.OneTimePreKey = payload.OneTimePreKeys[oneTime].Key
+        b.OneTimePreKeyID = payload.OneTimePreKeys[oneTime].ID
+    }
+    return b
+}
+
+// send encrypts for the single device of the recipient
+func send(t *testing.T, from *Store, to string, bundles []protocol.DeviceBundle, plaintext string) protocol.EncryptedEnvelope {
+    t.Helper()
+    envelopes, _, err := from.Encrypt(to, bundles, plaintext)
+    if err != nil {
+        t.Fatalf("encrypt %q: %v", plaintext, err)
+    }
+    if len(envelopes) != 1 {
+        t.Fatalf("%d envelopes, want 1", len(envelopes))
+    }
+    return envelopes[0]
+}
+
+func receive(t *testing.T, s *Store, from, deviceID string, env protocol.EncryptedEnvelope, want string) {
+    t.Helper()
+    plaintext, changed, err := s.Decrypt(from, deviceID, env)
+    if err != nil {
+        t.Fatalf("decrypt %q: %v", want, err)
+    }
+    if plaintext != want {
+        t.Fatalf("decrypt = %q, want %q", plaintext, want)
+    }
+    if changed {
+        t.Fatalf("decrypt %q reported an identity change", want)
+    }
+}
+
+func TestStoreConversation(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    bundles := []protocol.DeviceBundle{published(bob, 0)}
+
+    receive(t, bob, "alice", alice.DeviceID(), send(t, alice, "bob", bundles, "hi bob"), "hi bob")
+    receive(t, alice, "bob", bob.DeviceID(), send(t, bob, "alice", nil, "hi alice"), "hi alice")
+    // the bundle again does not start a new session
+    receive(t, bob, "alice", alice.DeviceID(), send(t, alice, "bob", bundles, "again"), "again")
+
+    if !alice.KnowsPeer("bob") || !bob.KnowsPeer("alice") {
+        t.Error("the peers are not remembered")
+    }
+    if got := alice.PeerFingerprints("bob")[bob.DeviceID()]; got != bob.Fingerprint() {
+        t.Errorf("fingerprint of bob seen by alice = %q, want %q", got, bob.Fingerprint())
+    }
+}
+
+func TestStoreInitRetransmitted(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    bundles := []protocol.DeviceBundle{published(bob, 0)}
+
+    // both carry the init header, bob has not answered yet
+    first := send(t, alice, "bob", bundles, "one")
+    second := send(t, alice, "bob", bundles, "two")
+    receive(t, bob, "alice", alice.DeviceID(), second, "two")
+    receive(t, bob, "alice", alice.DeviceID(), first, "one")
+}
+
+func TestStoreOneTimeKeyConsumed(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    carol := openStore(t, filepath.Join(dir, "carol.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    bundle := published(bob, 0)
+    before := len(bob.Bundle().OneTimePreKeys)
+
+    receive(t, bob, "alice", alice.DeviceID(), send(t, alice, "bob", []protocol.DeviceBundle{bundle}, "hi"), "hi")
+    for _, key := range bob.Bundle().OneTimePreKeys {
+        if key.ID == bundle.OneTimePreKeyID {
+            t.Fatalf("one-time prekey %d still published after its use", key.ID)
+        }
+    }
+    if after := len(bob.Bundle().OneTimePreKeys); after != before-1 {
+        t.Errorf("%d one-time prekeys after one use, want %d", after, before-1)
+    }
+
+    // the server handing out the same key twice must not open a session
+    env := send(t, carol, "bob", []protocol.DeviceBundle{bundle}, "replayed key")
+    if _, _, err := bob.Decrypt("carol", carol.DeviceID(), env); err == nil {
+        t.Error("a one-time prekey was used twice")
+    }
+
+    // a bundle without one-time prekey still works
+    dave := openStore(t, filepath.Join(dir, "dave.json"))
+    plain := published(bob, len(bob.Bundle().OneTimePreKeys))
+    receive(t, bob, "dave", dave.DeviceID(), send(t, dave, "bob", []protocol.DeviceBundle{plain}, "no key left"), "no key left")
+}
+
+func TestStoreTopUp(t *testing.T) {
+    bob := openStore(t, filepath.Join(t.TempDir(), "bob.json"))
+    if n := len(bob.Bundle().OneTimePreKeys); n != oneTimeKeys {
+        t.Fatalf("%d one-time prekeys, want %d", n, oneTimeKeys)
+    }
+    for i := 0; i <= oneTimeKeys-minOneTimeKeys; i++ {
+        alice := openStore(t, filepath.Join(t.TempDir(), "alice.json"))
+        env := send(t, alice, "bob", []protocol.DeviceBundle{published(bob, 0)}, "hi")
+        receive(t, bob, "alice", alice.DeviceID(), env, "hi")
+    }
+    if n := len(bob.Bundle().OneTimePreKeys); n != oneTimeKeys {
+        t.Errorf("%d one-time prekeys after the top up, want %d", n, oneTimeKeys)
+    }
+}
+
+func TestStoreIdentityChange(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    receive(t, bob, "alice", alice.DeviceID(), send(t, alice, "bob", []protocol.DeviceBundle{published(bob, 0)}, "hi"), "hi")
+
+    // bob reinstalls: a new identity published under the same device ID
+    reinstalled := openStore(t, filepath.Join(dir, "bob2.json"))
+    bundle := published(reinstalled, 0)
+    bundle.DeviceID = bob.DeviceID()
+    envelopes, changed, err := alice.Encrypt("bob", []protocol.DeviceBundle{bundle}, "who are you")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !changed {
+        t.Error("encrypting to a new identity did not report the change")
+    }
+    if got := alice.PeerFingerprints("bob")[bob.DeviceID()]; got != reinstalled.Fingerprint() {
+        t.Errorf("fingerprint = %q, want the new one %q", got, reinstalled.Fingerprint())
+    }
+    if _, _, err := reinstalled.Decrypt("alice", alice.DeviceID(), envelopes[0]); err != nil {
+        t.Errorf("the new identity can't read: %v", err)
+    }
+
+    // alice reinstalls in turn and writes to the first bob
+    alice2 := openStore(t, filepath.Join(dir, "alice2.json"))
+    env := send(t, alice2, "bob", []protocol.DeviceBundle{published(bob, 0)}, "it's me")
+    plaintext, changed, err := bob.Decrypt("alice", alice.DeviceID(), env)
+    if err != nil || plaintext != "it's me" {
+        t.Fatalf("decrypt = %q, %v", plaintext, err)
+    }
+    if !changed {
+        t.Error("decrypting from a new identity did not report the change")
+    }
+}
+
+func TestStoreForgedBundle(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    mallory := openStore(t, filepath.Join(dir, "mallory.json"))
+
+    // the prekey of another device under the identity of bob
+    forged := published(bob, 0)
+    forged.SignedPreKey = published(mallory, 0).SignedPreKey
+    if _, _, err := alice.Encrypt("bob", []protocol.DeviceBundle{forged}, "secret"); err == nil {
+        t.Error("encrypted with a prekey not signed by its identity")
+    }
+    if alice.KnowsPeer("bob") {
+        t.Error("a rejected bundle was remembered")
+    }
+}
+
+func TestStoreNoSession(t *testing.T) {
+    dir := t.TempDir()
+    alice := openStore(t, filepath.Join(dir, "alice.json"))
+    bob := openStore(t, filepath.Join(dir, "bob.json"))
+    carol := openStore(t, filepath.Join(dir, "carol.json"))
+
+    receive(t, bob, "alice", alice.DeviceID(), send(t, alice, "bob", []protocol.DeviceBundle{published(bob, 0)}, "hi"), "hi")
+    // an answer of bob, without init header, sent to a device that never
+    // wrote to it
+    env := send(t, bob, "alice", nil, "hello")
+    if _, _, err := carol.Decrypt("bob", bob.DeviceID(), env); !errors.Is(err, ErrNoSession) {
+        t.Errorf("err = %v, want %v", err, ErrNoSession)
+    }
+}
+
+func TestStorePersistence(t *testing.T) {
+    dir := t.TempDir()
+    alicePath, bobPath := filepath.Join(dir, "alice.json"), filepath.Join(dir, "bob.json")
+    alice := openStore(t, alicePath)
+    bob := openStore(t, bobPath)
+    receive(t, bob, "alice", alice.DeviceID(), send(t, alice, "bob", []protocol.DeviceBundle{published(bob, 0)}, "hi"), "hi")
+    receive(t, alice, "bob", bob.DeviceID(), send(t, bob, "alice", nil, "hello"), "hello")
+
+    alice = openStore(t, alicePath)
+    reopened := openStore(t, bobPath)
+    if reopened.DeviceID() != bob.DeviceID() || reopened.Fingerprint() != bob.Fingerprint() {
+        t.Fatal("the identity changed when reopened")
+    }
+    receive(t, reopened, "alice", alice.DeviceID(), send(t, alice, "bob", nil, "still there"), "still there")
+    receive(t, alice, "bob", bob.DeviceID(), send(t, reopened, "alice", nil, "yes"), "yes")
+}
@@ -0,0 +1,121 @@
+// internal/client/e2ee/x3dh.go
+package e2ee
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"textual/pkg/protocol"
+)
+
+var errBadSignature = errors.New("the prekey of the bundle is not signed by its identity")
+
+// preKeyMessage is what the signing key signs: the ID and the prekey
+func preKeyMessage(id uint32, key []byte) []byte {
+    msg := []byte("textual-prekey")
+    msg = binary.BigEndian.AppendUint32(msg, id)
+    return append(msg, key...)
+}
+
+// verifyBundle checks the sizes of the keys and the signature of the prekey
+func verifyBundle(b protocol.DeviceBundle) error {
+    if len(b.IdentityKey) != 32 || len(b.SignedPreKey) != 32 || len(b.SigningKey) != ed25519.PublicKeySize {
+        return errors.New("invalid key sizes in the bundle")
+    }
+    if b.OneTimePreKey != nil && len(b.OneTimePreKey) != 32 {
+        return errors.New("invalid one-time prekey in the bundle")
+    }
+    if !ed25519.Verify(b.SigningKey, preKeyMessage(b.SignedPreKeyID, b.SignedPreKey), b.Signature) {
+        return errBadSignature
+    }
+    return nil
+}
+
+// x3dhInitiate agrees on the first secret with a device from its bundle, the
+// returned init header lets the device compute the same secret
+func x3dhInitiate(identityKey []byte, signingKey ed25519.PublicKey, b protocol.DeviceBundle) (*session, error) {
+    if err := verifyBundle(b); err != nil {
+        return nil, err
+    }
+    ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+    if err != nil {
+        return nil, err
+    }
+
+    secrets := [][]byte{}
+    for _, pair := range [][2][]byte{
+        {identityKey, b.SignedPreKey},
+        {ephemeral.Bytes(), b.IdentityKey},
+        {ephemeral.Bytes(), b.SignedPreKey},
+    } {
+        out, err := dh(pair[0], pair[1])
+        if err != nil {
+            return nil, err
+        }
+        secrets = append(secrets, out)
+    }
+    if b.OneTimePreKey != nil {
+        out, err := dh(ephemeral.Bytes(), b.OneTimePreKey)
+        if err != nil {
+            return nil, err
+        }
+        secrets = append(secrets, out)
+    }
+
+    ownPublic, err := publicKey(identityKey)
+    if err != nil {
+        return nil, err
+    }
+    init := &initHeader{
+        IdentityKey:  ownPublic,
+        SigningKey:   signingKey,
+        Ephemeral:    ephemeral.PublicKey().Bytes(),
+        PreKeyID:     b.SignedPreKeyID,
+        OneTimeKeyID: b.OneTimePreKeyID,
+    }
+    return newInitiatorSession(x3dhSecret(secrets), concat(ownPublic, b.IdentityKey), b.SignedPreKey, init)
+}
+
+// x3dhRespond computes the secret of an init header with the prekeys it
+// names, oneTimeKey is nil when none was used
+func x3dhRespond(identityKey, preKey, oneTimeKey []byte, init *initHeader) (*session, error) {
+    if len(init.IdentityKey) != 32 || len(init.Ephemeral) != 32 {
+        return nil, errors.New("invalid init header")
+    }
+
+    secrets := [][]byte{}
+    for _, pair := range [][2][]byte{
+        {preKey, init.IdentityKey},
+        {identityKey, init.Ephemeral},
+        {preKey, init.Ephemeral},
+    } {
+        out, err := dh(pair[0], pair[1])
+        if err != nil {
+            return nil, err
+        }
+        secrets = append(secrets, out)
+    }
+    if oneTimeKey != nil {
+        out, err := dh(oneTimeKey, init.Ephemeral)
+        if err != nil {
+            return nil, err
+        }
+        secrets = append(secrets, out)
+    }
+
+    ownPublic, err := publicKey(identityKey)
+    if err != nil {
+        return nil, err
+    }
+    return newResponderSession(x3dhSecret(secrets), concat(init.IdentityKey, ownPublic), preKey, init.Ephemeral), nil
+}
+
+// x3dhSecret derives the shared secret from the DH outputs, prefixed with
+// 0xFF bytes as in the X3DH specification
+func x3dhSecret(secrets [][]byte) []byte {
+    material := append(bytes.Repeat([]byte{0xFF}, 32), concat(secrets...)...)
+    return derive(material, make([]byte, 32), "textual-x3dh", 32)
+}
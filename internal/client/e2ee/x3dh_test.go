@@ -0,0 +1,149 @@
+// internal/client/e2ee/x3dh_test.go
+package e2ee
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"textual/pkg/protocol"
+)
+
+// device is the private keys of a test device
+type device struct {
+    identity []byte
+    signing  ed25519.PrivateKey
+    preKey   []byte
+    oneTime  []byte
+}
+
+func newDevice(t *testing.T) *device {
+    t.Helper()
+    _, signing, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return &device{
+        identity: newKey(t),
+        signing:  signing,
+        preKey:   newKey(t),
+        oneTime:  newKey(t),
+    }
+}
+
+func newKey(t *testing.T) []byte {
+    t.Helper()
+    key, err := ecdh.X25519().GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return key.Bytes()
+}
+
+func mustPublic(t *testing.T, private []byte) []byte {
+    t.Helper()
+    pub, err := publicKey(private)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return pub
+}
+
+// bundle is what the server hands out for the device, with its one-time
+// prekey when withOneTime is set
+func (d *device) bundle(t *testing.T, withOneTime bool) protocol.DeviceBundle {
+    t.Helper()
+    preKey := mustPublic(t, d.preKey)
+    b := protocol.DeviceBundle{
+        DeviceID:       "device",
+        IdentityKey:    mustPublic(t, d.identity),
+        SigningKey:     d.signing.Public().(ed25519.PublicKey),
+        SignedPreKey:   preKey,
+        SignedPreKeyID: 1,
+        Signature:      ed25519.Sign(d.signing, preKeyMessage(1, preKey)),
+    }
+    if withOneTime {
+        b.OneTimePreKey = mustPublic(t, d.oneTime)
+        b.OneTimePreKeyID = 7
+    }
+    return b
+}
+
+// handshake runs X3DH between two new devices and returns the session of
+// each side, the responder built from the first message
+func handshake(t *testing.T, withOneTime bool) (alice, bob *session) {
+    t.Helper()
+    a, b := newDevice(t), newDevice(t)
+    alice, err := x3dhInitiate(a.identity, a.signing.Public().(ed25519.PublicKey), b.bundle(t, withOneTime))
+    if err != nil {
+        t.Fatalf("initiate: %v", err)
+    }
+    var oneTime []byte
+    if withOneTime {
+        oneTime = b.oneTime
+    }
+    bob, err = x3dhRespond(b.identity, b.preKey, oneTime, alice.Init)
+    if err != nil {
+        t.Fatalf("respond: %v", err)
+    }
+    return alice, bob
+}
+
+func TestX3DHAgreement(t *testing.T) {
+    for _, withOneTime := range []bool{false, true} {
+        alice, bob := handshake(t, withOneTime)
+        if !bytes.Equal(alice.AD, bob.AD) {
+            t.Errorf("one-time %v: the associated data differ", withOneTime)
+        }
+        h, ciphertext, err := alice.encrypt([]byte("hello"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        plaintext, err := bob.decrypt(h, ciphertext)
+        if err != nil || string(plaintext) != "hello" {
+            t.Errorf("one-time %v: decrypt = %q, %v", withOneTime, plaintext, err)
+        }
+    }
+}
+
+func TestX3DHOneTimeKeyMismatch(t *testing.T) {
+    a, b := newDevice(t), newDevice(t)
+    alice, err := x3dhInitiate(a.identity, a.signing.Public().(ed25519.PublicKey), b.bundle(t, true))
+    if err != nil {
+        t.Fatal(err)
+    }
+    // the responder without the one-time prekey used can't find the secret
+    bob, err := x3dhRespond(b.identity, b.preKey, nil, alice.Init)
+    if err != nil {
+        t.Fatal(err)
+    }
+    h, ciphertext, _ := alice.encrypt([]byte("hello"))
+    if _, err := bob.decrypt(h, ciphertext); err == nil {
+        t.Error("decrypted without the one-time prekey")
+    }
+}
+
+func TestX3DHRejectsBadBundle(t *testing.T) {
+    a, b := newDevice(t), newDevice(t)
+    signing := a.signing.Public().(ed25519.PublicKey)
+
+    forged := b.bundle(t, false)
+    forged.SignedPreKey = mustPublic(t, newKey(t))
+    if _, err := x3dhInitiate(a.identity, signing, forged); !errors.Is(err, errBadSignature) {
+        t.Errorf("replaced prekey: err = %v, want %v", err, errBadSignature)
+    }
+
+    renumbered := b.bundle(t, false)
+    renumbered.SignedPreKeyID = 2
+    if _, err := x3dhInitiate(a.identity, signing, renumbered); !errors.Is(err, errBadSignature) {
+        t.Errorf("replaced prekey ID: err = %v, want %v", err, errBadSignature)
+    }
+
+    short := b.bundle(t, true)
+    short.OneTimePreKey = short.OneTimePreKey[:16]
+    if _, err := x3dhInitiate(a.identity, signing, short); err == nil {
+        t.Error("accepted a truncated one-time prekey")
+    }
+}
@@ -0,0 +1,149 @@
+// internal/client/i18n/format.go
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Clock is how the time of the messages is written
+type Clock string
+
+const (
+    Clock24h Clock = "24h"
+    Clock12h Clock = "12h"
+)
+
+var clock = Clock24h
+
+// zone is the timezone of the times written
+var zone = time.Local
+
+// SetClock selects the 24h or 12h clock, "" keeps the 24h one
+func SetClock(name string) error {
+    switch Clock(strings.ToLower(name)) {
+    case "", Clock24h:
+        setClock(Clock24h)
+    case Clock12h:
+        setClock(Clock12h)
+    default:
+        return fmt.Errorf("unknown time format %q (available: 24h, 12h)", name)
+    }
+    return nil
+}
+
+func setClock(c Clock) {
+    mu.Lock()
+    defer mu.Unlock()
+    clock = c
+}
+
+// SetTimezone selects the timezone of the times, an IANA name such as
+// Europe/Paris; "" keeps the one of the system
+func SetTimezone(name string) error {
+    loc := time.Local
+    if name != "" {
+        var err error
+        if loc, err = time.LoadLocation(name); err != nil {
+            return fmt.Errorf("unknown timezone %q", name)
+        }
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    zone = loc
+    return nil
+}
+
+// InZone returns t in the selected timezone
+func InZone(t time.Time) time.Time {
+    mu.RLock()
+    defer mu.RUnlock()
+    return t.In(zone)
+}
+
+// dateLayouts are the short dates of the messages of other days, the
+// English one is month first
+var dateLayouts = map[Locale]struct{ day, year string }{
+    English: {"01/02", "01/02/06"},
+    French:  {"02/01", "02/01/06"},
+}
+
+var weekdays = map[Locale][]string{
+    French: {"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+}
+
+var months = map[Locale][]string{
+    French: {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+}
+
+// FormatTime writes the time of day with the configured clock
+func FormatTime(t time.Time) string {
+    mu.RLock()
+    defer mu.RUnlock()
+    t = t.In(zone)
+    if clock == Clock12h {
+        return t.Format("3:04:05 PM")
+    }
+    return t.Format("15:04:05")
+}
+
+// FormatTimestamp writes the time of a message, the date is added when it
+// was not sent today and the year when it was not sent this year
+func FormatTimestamp(t, now time.Time) string {
+    t, now = InZone(t), InZone(now)
+    layouts := dateLayouts[CurrentLocale()]
+    switch {
+    case sameDay(t, now):
+        return FormatTime(t)
+    case t.Year() == now.Year():
+        return fmt.Sprintf("[%s %s]", t.Format(layouts.day), FormatTime(t))
+    }
+    return fmt.Sprintf("[%s %s]", t.Format(layouts.year), FormatTime(t))
+}
+
+// FormatDay writes the day separating the messages of the conversation, e.g.
+// "Today", "Yesterday", "Monday 3 March" or "lundi 3 mars 2024"
+func FormatDay(t, now time.Time) string {
+    t, now = InZone(t), InZone(now)
+    switch {
+    case sameDay(t, now):
+        return T("Today")
+    case sameDay(t, now.AddDate(0, 0, -1)):
+        return T("Yesterday")
+    }
+
+    locale := CurrentLocale()
+    weekday, month := t.Weekday().String(), t.Month().String()
+    if names, ok := weekdays[locale]; ok {
+        weekday = names[t.Weekday()]
+    }
+    if names, ok := months[locale]; ok {
+        month = names[t.Month()-1]
+    }
+
+    day := fmt.Sprintf("%s %d %s", weekday, t.Day(), month)
+    if t.Year() != now.Year() {
+        day += fmt.Sprintf(" %d", t.Year())
+    }
+    return day
+}
+
+// FormatRelative writes how long ago a message was sent, "now", "5m ago" or
+// "3h ago", and its timestamp once it is older than a day
+func FormatRelative(t, now time.Time) string {
+    d := now.Sub(t)
+    switch {
+    case d < time.Minute:
+        return T("now")
+    case d < time.Hour:
+        return T("%dm ago", int(d/time.Minute))
+    case d < 24*time.Hour:
+        return T("%dh ago", int(d/time.Hour))
+    }
+    return FormatTimestamp(t, now)
+}
+
+func sameDay(a, b time.Time) bool {
+    return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
@@ -0,0 +1,450 @@
+// internal/client/i18n/fr.go
+package i18n
+
+// frenchCatalog translates the interface in French, a missing string stays in
+// English
+var frenchCatalog = map[string]string{
+    // pages and titles
+    "Global":                 "Global",
+    "Groups":                 "Groupes",
+    "Messages":               "Messages",
+    "Friends":                "Amis",
+    "Chat":                   "Discussion",
+    "Conversations":          "Conversations",
+    "Direct messages":        "Messages privés",
+    "Keys":                   "Touches",
+    "Members":                "Membres",
+    "New group":              "Nouveau groupe",
+    "Public groups":          "Groupes publics",
+    "Selected messages":      "Messages sélectionnés",
+    "Chat Application Login": "Connexion au chat",
+    "Log (%s, /debug to hide)": "Journal (%s, /debug pour le masquer)",
+
+    // presence and connection
+    "online":                      "en ligne",
+    "away":                        "absent",
+    "dnd":                         "ne pas déranger",
+    "offline":                     "hors ligne",
+    "● connected":                 "● connecté",
+    "○ offline":                   "○ hors ligne",
+    "◌ reconnecting (attempt %d)": "◌ reconnexion (tentative %d)",
+    "Reconnected":                 "Reconnecté",
+    "not connected":               "non connecté",
+    "Offline, sending is paused until the connection is back":              "Hors ligne, l'envoi reprendra au retour de la connexion",
+    "Offline: the message stays in the input until the connection is back": "Hors ligne : le message reste dans la saisie jusqu'au retour de la connexion",
+    "offline: the message stays in the input until the connection is back": "hors ligne : le message reste dans la saisie jusqu'au retour de la connexion",
+
+    // dates
+    "Today":     "Aujourd'hui",
+    "Yesterday": "Hier",
+    "now":       "maintenant",
+    "%dm ago":   "il y a %d min",
+    "%dh ago":   "il y a %d h",
+
+    // chat
+    "You":                                 "Vous",
+    "Type a message...":                   "Écrire un message...",
+    " New messages ":                      " Nouveaux messages ",
+    "Loading more messages...\n":          "Chargement des messages précédents...\n",
+    "No messages yet":                     "Aucun message",
+    "No new messages in this conversation": "Aucun nouveau message dans cette conversation",
+    "No link in this conversation":        "Aucun lien dans cette conversation",
+    "Opening %s":                          "Ouverture de %s",
+    "Message copied to the clipboard":     "Message copié dans le presse-papiers",
+    "You can only edit your own messages": "Vous ne pouvez modifier que vos propres messages",
+    "Editing message • enter to save • esc to cancel": "Modification • entrée pour enregistrer • échap pour annuler",
+    "j/k move • g/G first/last • o open link • y/Y copy • l copy link • e edit • r retry • t thread • i back to input • esc leave": "j/k déplacer • g/G premier/dernier • o ouvrir le lien • y/Y copier • l copier le lien • e modifier • r réessayer • t fil • i retour à la saisie • échap quitter",
+    "(failed — press r to retry)":         "(échec — appuyez sur r pour réessayer)",
+    "Jump to a conversation...":         "Aller à une conversation...",
+    "No conversation found":             "Aucune conversation trouvée",
+    "↑/↓ move • enter open • esc close": "↑/↓ déplacer • entrée ouvrir • échap fermer",
+    "Conversation with %s":              "Conversation avec %s",
+    "%s %s (%d unread)":                 "%s %s (%d non lus)",
+    "No conversations yet. Add friends in the Friends tab to start chatting.": "Aucune conversation. Ajoutez des amis dans l'onglet Amis pour discuter.",
+    "\n\nPress Enter to open a conversation • Esc to come back to the list":   "\n\nEntrée pour ouvrir une conversation • Échap pour revenir à la liste",
+    "New message from %s\n": "Nouveau message de %s\n",
+
+    // commands
+    "Commands (start a message with // to send a literal /, ? lists the keys):": "Commandes (commencez par // pour envoyer un / littéral, ? liste les touches) :",
+    "unknown command %s, type /help for the list":  "commande %s inconnue, tapez /help pour la liste",
+    "usage: /friend add <username>":                "usage : /friend add <utilisateur>",
+    "usage: /group create <name>":                  "usage : /group create <nom>",
+    "list the commands":                            "lister les commandes",
+    "clear the messages of this conversation from the screen": "effacer les messages de cette conversation de l'écran",
+    "switch the color theme":                       "changer le thème de couleurs",
+    "set your status text, empty to clear it":      "définir le texte de votre statut, vide pour l'effacer",
+    "set your status to online":                    "passer votre statut à en ligne",
+    "set your status to away":                      "passer votre statut à absent",
+    "do not disturb, no notifications":             "ne pas déranger, aucune notification",
+    "mute the notifications of this conversation":  "couper les notifications de cette conversation",
+    "unmute the notifications of this conversation": "réactiver les notifications de cette conversation",
+    "show or hide the recent log lines":            "afficher ou masquer les dernières lignes du journal",
+    "send a friend request":                        "envoyer une demande d'ami",
+    "create a group":                               "créer un groupe",
+    "Open a conversation first":                    "Ouvrez d'abord une conversation",
+    "Conversation cleared":                         "Conversation effacée",
+    "Creating group %s...":                         "Création du groupe %s...",
+    "Current theme: %s (available: %s)":            "Thème actuel : %s (disponibles : %s)",
+    "Theme switched to %s":                         "Thème changé pour %s",
+    "Status set to %s (%s)":                        "Statut défini à %s (%s)",
+    "Status set to %s (%s) until %s":               "Statut défini à %s (%s) jusqu'à %s",
+    "a status for %s needs a text":                 "un statut pour %s demande un texte",
+    "Status set to %s":                             "Statut défini à %s",
+    "Notifications muted for this conversation":    "Notifications coupées pour cette conversation",
+    "Notifications enabled for this conversation":  "Notifications activées pour cette conversation",
+    "save this conversation to a file (.md, .json or text)": "enregistrer cette conversation dans un fichier (.md, .json ou texte)",
+    "Fetching the history of %s...":                "Récupération de l'historique de %s...",
+    "%d messages exported to %s":                   "%d messages exportés dans %s",
+    "Paste of %d lines: send as a code block? [y]es, [n]o as text, esc to cancel": "Collage de %d lignes : envoyer en bloc de code ? [y] oui, [n] non en texte, échap pour annuler",
+    "offline: the paste was not sent":              "hors ligne : le collage n'a pas été envoyé",
+    "show the encryption fingerprints of this conversation": "afficher les empreintes de chiffrement de cette conversation",
+    "🔒 encrypted":                                  "🔒 chiffrée",
+    "⚠ The encryption keys of %s changed, compare them with /verify": "⚠ Les clés de chiffrement de %s ont changé, comparez-les avec /verify",
+    "Encryption is disabled, set encryption = true in the config": "Le chiffrement est désactivé, mettez encryption = true dans la configuration",
+    "Your fingerprint: %s":                         "Votre empreinte : %s",
+    "No key of %s yet, send a message first":       "Aucune clé de %s pour l'instant, envoyez d'abord un message",
+    "choose what this conversation notifies":       "choisir ce que cette conversation notifie",
+    "Notifications of this conversation: %s":       "Notifications de cette conversation : %s",
+    "Unknown level %s, use all, mentions, badge or none": "Niveau %s inconnu, utilisez all, mentions, badge ou none",
+    "Only the mentions notify in this conversation":       "Seules les mentions notifient dans cette conversation",
+    "Only the unread badge for this conversation":  "Seulement le badge des non lus pour cette conversation",
+    "all":                                          "toutes",
+    "mentions":                                     "mentions seulement",
+    "badge":                                        "badge seulement",
+    "none":                                         "aucune",
+    "get a message from Textual later, /remind list to see them": "recevoir un message de Textual plus tard, /remind list pour les voir",
+    "usage: /remind me in <duration> <text>, /remind me at <hh:mm> <text>, /remind list or /remind cancel <number>": "usage : /remind me in <durée> <texte>, /remind me at <hh:mm> <texte>, /remind list ou /remind cancel <numéro>",
+    "invalid duration %s, e.g. 30m, 2h or 3d":      "durée %s invalide, par exemple 30m, 2h ou 3d",
+    "invalid time %s, e.g. 9:00 or 14:30":          "heure %s invalide, par exemple 9:00 ou 14:30",
+    "post the GIF found for the search": "publier le GIF trouvé pour la recherche",
+    "usage: /gif <search>":           "usage : /gif <recherche>",
+    "GIF search too long":            "recherche de GIF trop longue",
+    "GIFs can't be sent in encrypted conversations": "les GIF ne peuvent pas être envoyés dans les conversations chiffrées",
+    "Looking for a GIF...":           "Recherche d'un GIF...",
+    "a reminder needs a text":                      "un rappel demande un texte",
+    "no reminder %d, see /remind list":             "aucun rappel %d, voir /remind list",
+    "⏰ Reminder set for %s: %s":                    "⏰ Rappel prévu %s : %s",
+    "No reminder waiting":                          "Aucun rappel en attente",
+    "Reminders (/remind cancel <number> to drop one):": "Rappels (/remind cancel <numéro> pour en retirer un) :",
+    "record a voice message, again to send it":     "enregistrer un message vocal, à nouveau pour l'envoyer",
+    "play the last voice message of this conversation": "écouter le dernier message vocal de cette conversation",
+    "usage: /voice [cancel]":                       "usage : /voice [cancel]",
+    "Nothing is being recorded":                    "Aucun enregistrement en cours",
+    "Recording dropped":                            "Enregistrement abandonné",
+    "Recording too short, dropped":                 "Enregistrement trop court, abandonné",
+    "🎤 Recording… /voice to send, /voice cancel to drop": "🎤 Enregistrement… /voice pour envoyer, /voice cancel pour abandonner",
+    "🎤 Voice message (%s)":                         "🎤 Message vocal (%s)",
+    "Voice message":                                "Message vocal",
+    "voice messages can't be sent in encrypted conversations": "les messages vocaux ne peuvent pas être envoyés dans les conversations chiffrées",
+    "no recorder set, add [voice] recorder to the config file": "aucun enregistreur, ajoutez [voice] recorder au fichier de configuration",
+    "no player set, add [voice] player to the config file": "aucun lecteur, ajoutez [voice] player au fichier de configuration",
+    "failed to start the recorder: %v":             "impossible de lancer l'enregistreur : %v",
+    "failed to start the player: %v":               "impossible de lancer le lecteur : %v",
+    "No voice message in this conversation":        "Aucun message vocal dans cette conversation",
+    "Downloading the voice message...":             "Téléchargement du message vocal...",
+    "▶ Playing the voice message":                  "▶ Lecture du message vocal",
+    "Only a message sent can start a thread":       "Seul un message envoyé peut ouvrir un fil",
+    "Threads are not available in encrypted conversations": "Les fils ne sont pas disponibles dans les conversations chiffrées",
+    "Reply in the thread...":                       "Répondre dans le fil...",
+    "%d replies":                                   "%d réponses",
+    "↳ %d replies":                                 "↳ %d réponses",
+    "(%d new)":                                     "(%d nouvelles)",
+    "Loading the replies...":                       "Chargement des réponses...",
+    "Thread":                                       "Fil",
+    "enter reply • empty enter retries the failed replies • pgup/pgdown scroll • esc close": "entrée répondre • entrée à vide renvoie les réponses en échec • pgup/pgdown défiler • échap fermer",
+
+    // help overlay
+    "press any key to close":          "appuyez sur une touche pour fermer",
+    "next page":                       "page suivante",
+    "jump to a conversation":          "aller à une conversation",
+    "next conversation":               "conversation suivante",
+    "previous conversation":           "conversation précédente",
+    "open the last link":              "ouvrir le dernier lien",
+    "jump to the new messages":        "aller aux nouveaux messages",
+    "mark every conversation read":    "marquer toutes les conversations comme lues",
+    "mark this conversation read, or all of them": "marquer cette conversation comme lue, ou toutes",
+    "Conversation marked as read":     "Conversation marquée comme lue",
+    "usage: /markread [all]":          "usage : /markread [all]",
+    "Nothing unread":                  "Rien de non lu",
+    "%d conversations marked as read": "%d conversations marquées comme lues",
+    "show this help":                  "afficher cette aide",
+    "side pane: members, requests, notifications": "panneau latéral : membres, demandes, notifications",
+    "scroll the side pane up":         "remonter le panneau latéral",
+    "scroll the side pane down":       "descendre le panneau latéral",
+    "quit":                            "quitter",
+    "send":                            "envoyer",
+    "edit your last message":          "modifier votre dernier message",
+    "commands, /help lists them":      "commandes, /help les liste",
+    "select messages, cancel an edit": "sélectionner des messages, annuler une modification",
+    "next message":                    "message suivant",
+    "previous message":                "message précédent",
+    "first message":                   "premier message",
+    "last message":                    "dernier message",
+    "open the link":                   "ouvrir le lien",
+    "copy the text":                   "copier le texte",
+    "copy with author and time":       "copier avec l'auteur et l'heure",
+    "edit your message":               "modifier votre message",
+    "open the thread of the message":  "ouvrir le fil du message",
+    "reply, or retry the failed replies": "répondre, ou renvoyer les réponses en échec",
+    "scroll the thread up":            "remonter dans le fil",
+    "scroll the thread down":          "descendre dans le fil",
+    "close the thread":                "fermer le fil",
+    "retry a failed message":          "renvoyer un message en échec",
+    "back to the input":               "retour à la saisie",
+    "leave the selection":             "quitter la sélection",
+    "open the conversation":           "ouvrir la conversation",
+    "accept the selected request":     "accepter la demande sélectionnée",
+    "select a friend":                 "choisir un ami",
+    "next friend":                     "ami suivant",
+    "previous friend":                 "ami précédent",
+    "start a chat":                    "démarrer une discussion",
+    "remove the friend":               "retirer l'ami",
+    "block the user":                  "bloquer l'utilisateur",
+    "back to the search":              "retour à la recherche",
+    "open the group":                  "ouvrir le groupe",
+    "browse public groups":            "parcourir les groupes publics",
+    "members of the group":            "membres du groupe",
+    "next field":                      "champ suivant",
+    "public or private":               "public ou privé",
+    "encrypted or not":                "chiffré ou non",
+    "announcements only or not":       "annonces seulement ou non",
+    "create":                          "créer",
+    "cancel":                          "annuler",
+    "next member":                     "membre suivant",
+    "previous member":                 "membre précédent",
+    "invite someone (admins)":         "inviter quelqu'un (admins)",
+    "kick the member (admins)":        "exclure le membre (admins)",
+    "toggle admin (admins)":           "donner ou retirer l'admin (admins)",
+    "change what the group notifies":  "changer ce que le groupe notifie",
+    "back to the chat":                "retour à la discussion",
+    "next group":                      "groupe suivant",
+    "previous group":                  "groupe précédent",
+    "join or open the group":          "rejoindre ou ouvrir le groupe",
+    "refresh":                         "actualiser",
+    "back to your groups":             "retour à vos groupes",
+    "next notification":               "notification suivante",
+    "previous notification":           "notification précédente",
+    "open and mark read":              "ouvrir et marquer comme lue",
+    "mark read":                       "marquer comme lue",
+    "mark all read":                   "tout marquer comme lu",
+
+    // side pane
+    "Friend requests":                 "Demandes d'ami",
+    "Notifications":                   "Notifications",
+    "The terminal is too narrow for the side pane": "Le terminal est trop étroit pour le panneau latéral",
+    "Open a group to see its members": "Ouvrez un groupe pour voir ses membres",
+    "Loading members...":              "Chargement des membres...",
+    "No pending friend request":       "Aucune demande d'ami en attente",
+    "Answer them in the Friends tab":  "Répondez-y dans l'onglet Amis",
+    "No notification":                 "Aucune notification",
+
+    // notifications
+    "j/k move • enter open • m mark read • a mark all read • @ mentions": "j/k déplacer • entrée ouvrir • m marquer comme lue • a tout marquer comme lu • @ mentions",
+    "📨 %s sent you a friend request":  "📨 %s vous a envoyé une demande d'ami",
+    "👥 %s added you to %s":            "👥 %s vous a ajouté à %s",
+    "@ %s mentioned you":              "@ %s vous a mentionné",
+    "⭐ %s made you an admin of %s":    "⭐ %s vous a nommé admin de %s",
+    "%s removed you from the admins of %s": "%s vous a retiré des admins de %s",
+    "📢 Server announcement":           "📢 Annonce du serveur",
+
+    // login
+    "Username":                           "Nom d'utilisateur",
+    "Password":                           "Mot de passe",
+    "Server Host (default: localhost)":   "Hôte du serveur (par défaut : localhost)",
+    "Server Port (default: 8080)":        "Port du serveur (par défaut : 8080)",
+    "Profile name (default: user@host)":  "Nom du profil (par défaut : user@hôte)",
+    "Profile:\n":                         "Profil :\n",
+    "New profile":                        "Nouveau profil",
+    "Username:\n":                        "Nom d'utilisateur :\n",
+    "\n\nPassword:\n":                    "\n\nMot de passe :\n",
+    "\n\nServer Host:\n":                 "\n\nHôte du serveur :\n",
+    "\n\nServer Port:\n":                 "\n\nPort du serveur :\n",
+    "\n\nProfile Name:\n":                "\n\nNom du profil :\n",
+    "Press Tab to switch fields • Enter to submit": "Tab pour changer de champ • Entrée pour valider",
+    "\n↑/↓ to choose a profile":          "\n↑/↓ pour choisir un profil",
+    "username and password are required": "le nom d'utilisateur et le mot de passe sont requis",
+    "Error: %v":                          "Erreur : %v",
+
+    // friends
+    "Search for a user...":                     "Rechercher un utilisateur...",
+    "Search for users (press Enter to send friend request):\n": "Rechercher des utilisateurs (Entrée pour envoyer une demande d'ami) :\n",
+    "🔔 Pending Friend Requests:":              "🔔 Demandes d'ami en attente :",
+    "📨 From %s - [y] Accept • [n] Reject\n":   "📨 De %s - [y] Accepter • [n] Refuser\n",
+    "📤 Sent Friend Requests:":                 "📤 Demandes d'ami envoyées :",
+    "📤 To %s - Pending...\n":                  "📤 À %s - En attente...\n",
+    "📤 Request sent to %s":                    "📤 Demande envoyée à %s",
+    "📨 Request from %s":                       "📨 Demande de %s",
+    "Recent Activity:":                         "Activité récente :",
+    "Waiting for response...":                  "En attente de réponse...",
+    "Press [y] to accept or [n] to reject":     "[y] pour accepter ou [n] pour refuser",
+    "Status: %s":                               "Statut : %s",
+    "Error: not connected":                     "Erreur : non connecté",
+    "Friend request sent to %s":                "Demande d'ami envoyée à %s",
+    "Accepted friend request from %s":          "Demande d'ami de %s acceptée",
+    "New friend request from %s":               "Nouvelle demande d'ami de %s",
+    "Blocked %s":                               "%s bloqué",
+    "Block %s? They won't be able to message you. [y/n]": "Bloquer %s ? Cette personne ne pourra plus vous écrire. [y/n]",
+    "Remove %s from your friends? [y/n]":       "Retirer %s de vos amis ? [y/n]",
+    "j/k move • enter chat • r remove • b block • esc back to search": "j/k déplacer • entrée discuter • r retirer • b bloquer • échap retour à la recherche",
+    "↓ select a friend":                        "↓ choisir un ami",
+
+    // groups
+    "a group":                 "un groupe",
+    "You joined %s":           "Vous avez rejoint %s",
+    "You were removed from %s": "Vous avez été retiré de %s",
+    "Group name":              "Nom du groupe",
+    "Group description":       "Description du groupe",
+    "Loading groups...\n":     "Chargement des groupes...\n",
+    "ctrl+p members":          "ctrl+p membres",
+    "Create New Group\n\n":    "Nouveau groupe\n\n",
+    "Name:\n":                 "Nom :\n",
+    "\n\nDescription:\n":      "\n\nDescription :\n",
+    "[ ] Public (listed in the group directory)": "[ ] Public (listé dans l'annuaire des groupes)",
+    "[x] Public (listed in the group directory)": "[x] Public (listé dans l'annuaire des groupes)",
+    "ctrl+t toggle":           "ctrl+t basculer",
+    "[ ] Encrypted (members need encryption enabled, no history on the server)": "[ ] Chiffré (les membres doivent activer le chiffrement, pas d'historique sur le serveur)",
+    "[x] Encrypted (members need encryption enabled, no history on the server)": "[x] Chiffré (les membres doivent activer le chiffrement, pas d'historique sur le serveur)",
+    "ctrl+x toggle":           "ctrl+x basculer",
+    "[ ] Announcements (only the admins post, not encrypted)": "[ ] Annonces (seuls les admins publient, non chiffré)",
+    "[x] Announcements (only the admins post, not encrypted)": "[x] Annonces (seuls les admins publient, non chiffré)",
+    "ctrl+y toggle":           "ctrl+y basculer",
+    "📢 Announcements":         "📢 Annonces",
+    "only the admins post here": "seuls les admins publient ici",
+    "Read only, type / for a command": "Lecture seule, tapez / pour une commande",
+    "Only the admins can post in this announcement group": "Seuls les admins peuvent publier dans ce groupe d'annonces",
+    "not encrypted":           "non chiffré",
+    "\n\nPress Enter to create, Esc to cancel": "\n\nEntrée pour créer, Échap pour annuler",
+    "Last message: %s - %d members": "Dernier message : %s - %d membres",
+    "%d members":              "%d membres",
+    "Error editing message: %v":  "Erreur lors de la modification du message : %v",
+    "Error sending message: %v":  "Erreur lors de l'envoi du message : %v",
+    "Error creating group: %v":   "Erreur lors de la création du groupe : %v",
+    "Error loading messages: %v": "Erreur lors du chargement des messages : %v",
+    "Username to invite":         "Utilisateur à inviter",
+    "Members of %s (%d)":         "Membres de %s (%d)",
+    "Loading members...\n":       "Chargement des membres...\n",
+    "Kick %s from the group? [y/n]": "Exclure %s du groupe ? [y/n]",
+    "enter invite • esc cancel":  "entrée inviter • échap annuler",
+    "j/k move • i invite • x kick • r toggle admin • n notifications • esc back to chat": "j/k déplacer • i inviter • x exclure • r admin • n notifications • échap retour à la discussion",
+    "j/k move • n notifications • esc back to chat": "j/k déplacer • n notifications • échap retour à la discussion",
+    "Notifications: %s":           "Notifications : %s",
+    "Error loading members: %v":  "Erreur lors du chargement des membres : %v",
+    "owner":                      "propriétaire",
+    "admin":                      "admin",
+    "The creator of the group stays admin": "Le créateur du groupe reste admin",
+    "Error kicking %s: %v":       "Erreur lors de l'exclusion de %s : %v",
+    "Error inviting %s: %v":      "Erreur lors de l'invitation de %s : %v",
+    "Error changing role: %v":    "Erreur lors du changement de rôle : %v",
+    "Loading public groups...\n": "Chargement des groupes publics...\n",
+    "No public group yet. Create one with Ctrl+N and make it public.\n": "Aucun groupe public. Créez-en un avec Ctrl+N et rendez-le public.\n",
+    "joining...":                 "en cours...",
+    "joined":                     "rejoint",
+    "j/k move • enter join/open • r refresh • esc back": "j/k déplacer • entrée rejoindre/ouvrir • r actualiser • échap retour",
+    "Error loading public groups: %v": "Erreur lors du chargement des groupes publics : %v",
+    "Error joining %s: %v":       "Erreur en rejoignant %s : %v",
+
+    // discover page
+    "Discover":                   "Découvrir",
+    "Online users":               "Utilisateurs en ligne",
+    "Users starting with %s":     "Utilisateurs commençant par %s",
+    "Loading...":                 "Chargement...",
+    "Nobody found":               "Aucun utilisateur trouvé",
+    "friend":                     "ami",
+    "request sent":               "demande envoyée",
+    "No public group yet":        "Aucun groupe public",
+    "Error sending a friend request to %s: %v": "Erreur lors de l'envoi d'une demande d'ami à %s : %v",
+    "j/k move • enter add friend/chat, join/open group • / search • r refresh": "j/k déplacer • entrée ajouter en ami/discuter, rejoindre/ouvrir le groupe • / rechercher • r actualiser",
+    "enter search • esc cancel • empty search for the online users": "entrée rechercher • échap annuler • recherche vide pour les utilisateurs en ligne",
+    "next entry":                 "entrée suivante",
+    "previous entry":             "entrée précédente",
+    "send a friend request or chat, join or open the group": "envoyer une demande d'ami ou discuter, rejoindre ou ouvrir le groupe",
+    "search users by name":       "chercher des utilisateurs par nom",
+
+    // server page, admins only
+    "Server":                     "Serveur",
+    "a announce • x kick a user • r refresh • j/k scroll": "a annoncer • x déconnecter un utilisateur • r actualiser • j/k défiler",
+    "enter confirm • esc cancel": "entrée valider • échap annuler",
+    "Announcement to every connected user...": "Annonce à tous les utilisateurs connectés...",
+    "Username to disconnect, then the reason...": "Utilisateur à déconnecter, puis la raison...",
+    "%s kicked %s: %s":           "%s a déconnecté %s : %s",
+    "You were disconnected by a moderator: %s": "Vous avez été déconnecté par un modérateur : %s",
+    "You were disconnected by a moderator": "Vous avez été déconnecté par un modérateur",
+    "Live stats":                 "Statistiques en direct",
+    "Online users: %d (%d sessions)": "Utilisateurs en ligne : %d (%d sessions)",
+    "Messages: %d in the last minute": "Messages : %d dans la dernière minute",
+    "Queued messages: %d, %d at most for a session": "Messages en attente : %d, %d au plus pour une session",
+    "%d sessions degraded":       "%d sessions en mode dégradé",
+    "Moderation":                 "Modération",
+    "Nothing since the server started": "Rien depuis le démarrage du serveur",
+    "%s announced: %s":           "%s a annoncé : %s",
+    "%s kicked %s":               "%s a déconnecté %s",
+    "%s removed %s from %s":      "%s a retiré %s de %s",
+    "📢 %s: %s":                  "📢 %s : %s",
+    "📢 Announcement from %s":    "📢 Annonce de %s",
+    "announce to every connected user": "annoncer à tous les utilisateurs connectés",
+    "disconnect a user":          "déconnecter un utilisateur",
+    "refresh the stats":          "actualiser les statistiques",
+    "scroll down":                "défiler vers le bas",
+    "scroll up":                  "défiler vers le haut",
+
+    "%s joined the group":  "%s a rejoint le groupe",
+    "%s left the group":    "%s a quitté le groupe",
+    "%s added %s":          "%s a ajouté %s",
+    "%s removed %s":        "%s a retiré %s",
+    "%s made %s an admin":  "%s a nommé %s admin",
+    "%s made %s a member":  "%s a rendu %s simple membre",
+    "You left %s":          "Vous avez quitté %s",
+    "Open a group first":   "Ouvrez d'abord un groupe",
+    "leave the open group": "quitter le groupe ouvert",
+
+    "decline the selected request":         "refuser la demande sélectionnée",
+    "Declined friend request from %s":      "Demande d'ami de %s refusée",
+    "%s accepted your friend request":      "%s a accepté votre demande d'ami",
+    "%s declined your friend request":      "%s a refusé votre demande d'ami",
+    "Mentions":                             "Mentions",
+    "j/k move • enter go to the message • @ notifications": "j/k déplacer • entrée aller au message • @ notifications",
+    "mentions / notifications":             "mentions / notifications",
+    "Nobody mentioned you":                 "Personne ne vous a mentionné",
+    "@ %s in %s":                           "@ %s dans %s",
+    "Topic: %s":                            "Sujet : %s",
+    "%s cleared the topic":                 "%s a effacé le sujet",
+    "%s set the topic: %s":                 "%s a défini le sujet : %s",
+    "No topic set, /group topic <text> sets one": "Aucun sujet, /group topic <texte> en définit un",
+    "show or set the topic of the open group": "afficher ou définir le sujet du groupe ouvert",
+    "copy the link of the message":         "copier le lien du message",
+    "This message has no link":             "Ce message n'a pas de lien",
+    "Link copied to the clipboard, /goto opens it": "Lien copié dans le presse-papiers, /goto l'ouvre",
+    "Not a message link: %s":               "Ce n'est pas un lien de message : %s",
+    "This message is in a conversation of other users": "Ce message est dans une conversation d'autres utilisateurs",
+    "The message is too far back to be shown in the conversation": "Le message est trop ancien pour être affiché dans la conversation",
+    "Message not found":                    "Message introuvable",
+    "open a message from its link, l copies it": "ouvrir un message depuis son lien, l le copie",
+    "Notes":                                "Notes",
+    "📝 Notes":                             "📝 Notes",
+    "only you see them, on all your devices": "vous seul les voyez, sur tous vos appareils",
+    "open your notes, a conversation with yourself": "ouvrir vos notes, une conversation avec vous-même",
+    "show or set the timezone, clock and language of all your devices": "afficher ou changer le fuseau horaire, l'horloge et la langue de tous vos appareils",
+    "Timezone %s, clock %s, language %s":   "Fuseau horaire %s, horloge %s, langue %s",
+    "of this device":                       "de cet appareil",
+    "usage: /prefs [timezone <name>|clock <24h|12h>|locale <%s>], default for the setting of each device": "usage : /prefs [timezone <nom>|clock <24h|12h>|locale <%s>], default pour le réglage de chaque appareil",
+    "Unknown timezone %s, use a name such as Europe/Paris": "Fuseau horaire %s inconnu, utilisez un nom comme Europe/Paris",
+    "Display preferences saved on all your devices": "Préférences d'affichage enregistrées sur tous vos appareils",
+    "Slow down: you can send again in %ds": "Doucement : vous pourrez envoyer à nouveau dans %d s",
+    "You can send messages again":          "Vous pouvez à nouveau envoyer des messages",
+    "The server does not support %s, it may run an older version of Textual": "Le serveur ne prend pas en charge %s, il utilise peut-être une version plus ancienne de Textual",
+    "group topics (/group topic)":          "les sujets des groupes (/group topic)",
+    "message links (/goto)":                "les liens vers les messages (/goto)",
+    "synced display preferences (/prefs)":  "les préférences d'affichage synchronisées (/prefs)",
+    "the Mentions section":                 "la section Mentions",
+    "synced notification levels":           "les niveaux de notification synchronisés",
+    "reminders (/remind)":                  "les rappels (/remind)",
+    "GIF search (/gif)":                    "la recherche de GIF (/gif)",
+    "voice messages":                       "les messages vocaux",
+    "threads":                              "les fils de discussion",
+    "the edit history of messages":         "l'historique des modifications des messages",
+    "the public group directory":           "l'annuaire des groupes publics",
+    "show the earlier versions of the message": "afficher les versions précédentes du message",
+    "This message was not edited":              "Ce message n'a pas été modifié",
+    "No earlier version of this message":       "Aucune version précédente de ce message",
+    "Earlier versions of the message:":         "Versions précédentes du message :",
+}
@@ -0,0 +1,105 @@
+// internal/client/i18n/i18n.go
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Locale is the language of the interface, the English strings of the code
+// are the keys of the catalogs
+type Locale string
+
+const (
+    English Locale = "en"
+    French  Locale = "fr"
+)
+
+// catalogs translate the English strings, English has no catalog
+var catalogs = map[Locale]map[string]string{
+    French: frenchCatalog,
+}
+
+var (
+    mu      sync.RWMutex
+    current = English
+)
+
+// Locales returns the names accepted by SetLocale
+func Locales() []string {
+    names := []string{string(English)}
+    for locale := range catalogs {
+        names = append(names, string(locale))
+    }
+    sort.Strings(names)
+    return names
+}
+
+// SetLocale selects the language, "" follows the environment ($LC_ALL,
+// $LC_MESSAGES, $LANG) and falls back to English
+func SetLocale(name string) error {
+    locale := English
+    if name == "" {
+        locale = detect()
+    } else if parsed, ok := parse(name); ok {
+        locale = parsed
+    } else {
+        return fmt.Errorf("unknown locale %q (available: %s)", name, strings.Join(Locales(), ", "))
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    current = locale
+    return nil
+}
+
+func CurrentLocale() Locale {
+    mu.RLock()
+    defer mu.RUnlock()
+    return current
+}
+
+// detect reads the language of the environment, e.g. fr_FR.UTF-8
+func detect() Locale {
+    for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+        value := os.Getenv(env)
+        if value == "" {
+            continue
+        }
+        if locale, ok := parse(value); ok {
+            return locale
+        }
+        return English
+    }
+    return English
+}
+
+// parse keeps the language of a name like fr, fr_FR or fr-CA.UTF-8
+func parse(name string) (Locale, bool) {
+    language := strings.ToLower(name)
+    if i := strings.IndexAny(language, "_-.@"); i >= 0 {
+        language = language[:i]
+    }
+    locale := Locale(language)
+    if _, ok := catalogs[locale]; ok || locale == English {
+        return locale, true
+    }
+    return English, false
+}
+
+// T translates a string of the interface, with args it is a format string
+func T(msg string, args ...interface{}) string {
+    mu.RLock()
+    if translated, ok := catalogs[current][msg]; ok {
+        msg = translated
+    }
+    mu.RUnlock()
+
+    if len(args) > 0 {
+        return fmt.Sprintf(msg, args...)
+    }
+    return msg
+}
@@ -0,0 +1,79 @@
+// internal/client/logging/logging.go
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Level orders the log messages, the ones below the configured level are dropped
+type Level int
+
+const (
+    LevelDebug Level = iota
+    LevelInfo
+    LevelWarn
+    LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+    if l < LevelDebug || l > LevelError {
+        return fmt.Sprintf("level(%d)", int(l))
+    }
+    return levelNames[l]
+}
+
+// ParseLevel reads a level name as given to --log-level
+func ParseLevel(name string) (Level, error) {
+    for i, levelName := range levelNames {
+        if strings.EqualFold(name, levelName) {
+            return Level(i), nil
+        }
+    }
+    return LevelInfo, fmt.Errorf("unknown log level %q (available: %s)", name, strings.Join(levelNames, ", "))
+}
+
+var (
+    mu    sync.RWMutex
+    level = LevelInfo
+)
+
+func SetLevel(l Level) {
+    mu.Lock()
+    defer mu.Unlock()
+    level = l
+}
+
+func CurrentLevel() Level {
+    mu.RLock()
+    defer mu.RUnlock()
+    return level
+}
+
+func logf(l Level, format string, args ...interface{}) {
+    if l < CurrentLevel() {
+        return
+    }
+    log.Printf(strings.ToUpper(l.String())+" "+format, args...)
+}
+
+// Debugf logs the details of every message exchanged with the server
+func Debugf(format string, args ...interface{}) {
+    logf(LevelDebug, format, args...)
+}
+
+func Infof(format string, args ...interface{}) {
+    logf(LevelInfo, format, args...)
+}
+
+func Warnf(format string, args ...interface{}) {
+    logf(LevelWarn, format, args...)
+}
+
+func Errorf(format string, args ...interface{}) {
+    logf(LevelError, format, args...)
+}
@@ -0,0 +1,56 @@
+// internal/client/logging/recent.go
+package logging
+
+import (
+	"strings"
+	"sync"
+)
+
+// Recent keeps the last lines written to the log, shown by the /debug pane
+var Recent = NewRing(200)
+
+// Ring is an io.Writer keeping the last lines written to it
+type Ring struct {
+    mu    sync.Mutex
+    lines []string
+    next  int
+    full  bool
+}
+
+func NewRing(size int) *Ring {
+    return &Ring{lines: make([]string, size)}
+}
+
+func (r *Ring) Write(p []byte) (int, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+        r.lines[r.next] = line
+        r.next = (r.next + 1) % len(r.lines)
+        if r.next == 0 {
+            r.full = true
+        }
+    }
+    return len(p), nil
+}
+
+// Lines returns up to n of the last lines, oldest first
+func (r *Ring) Lines(n int) []string {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    count := r.next
+    if r.full {
+        count = len(r.lines)
+    }
+    if n > count {
+        n = count
+    }
+
+    lines := make([]string, 0, n)
+    for i := n; i > 0; i-- {
+        lines = append(lines, r.lines[(r.next-i+len(r.lines))%len(r.lines)])
+    }
+    return lines
+}
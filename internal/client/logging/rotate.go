@@ -0,0 +1,88 @@
+// internal/client/logging/rotate.go
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file renamed to path.1 once it reaches maxSize,
+// path.1 becoming path.2 and so on up to the number of backups kept
+type RotatingFile struct {
+    mu      sync.Mutex
+    path    string
+    maxSize int64
+    backups int
+    file    *os.File
+    size    int64
+}
+
+// OpenRotating opens the log file in append mode, creating its directory
+func OpenRotating(path string, maxSize int64, backups int) (*RotatingFile, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return nil, fmt.Errorf("failed to create log directory: %v", err)
+    }
+
+    f := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+    if err := f.open(); err != nil {
+        return nil, err
+    }
+    return f, nil
+}
+
+func (f *RotatingFile) open() error {
+    file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+    if err != nil {
+        return fmt.Errorf("failed to open log file: %v", err)
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return fmt.Errorf("failed to stat log file: %v", err)
+    }
+
+    f.file = file
+    f.size = info.Size()
+    return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if f.size+int64(len(p)) > f.maxSize && f.size > 0 {
+        if err := f.rotate(); err != nil {
+            return 0, err
+        }
+    }
+
+    n, err := f.file.Write(p)
+    f.size += int64(n)
+    return n, err
+}
+
+// rotate shifts the backups, the oldest one is overwritten
+func (f *RotatingFile) rotate() error {
+    if err := f.file.Close(); err != nil {
+        return fmt.Errorf("failed to close log file: %v", err)
+    }
+
+    for i := f.backups - 1; i > 0; i-- {
+        os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+    }
+    if f.backups > 0 {
+        os.Rename(f.path, f.path+".1")
+    } else {
+        os.Remove(f.path)
+    }
+
+    return f.open()
+}
+
+func (f *RotatingFile) Close() error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.file.Close()
+}
@@ -16,8 +16,68 @@ type Message struct {
     GroupID     *string    `json:"group_id,omitempty"`
     SentAt      time.Time  `json:"sent_at"`
     ReadAt      *time.Time `json:"read_at,omitempty"`
+    EditedAt    *time.Time `json:"edited_at,omitempty"`
     Read        bool       `json:"read"`
     SenderName  string     `json:"sender_name,omitempty"`
+    // ClientID is the key of a message sent from this client, the server
+    // echoes it back so the pending copy can be replaced
+    ClientID    string     `json:"client_id,omitempty"`
+    // SendState is empty once the server stored the message
+    SendState   string     `json:"-"`
+    // Encrypted is set on the direct messages encrypted end to end, the
+    // server has no copy of them
+    Encrypted   bool       `json:"encrypted,omitempty"`
+    // Voice is set on the voice messages, Content then describes them
+    Voice       *Voice     `json:"voice,omitempty"`
+    // ThreadRootID is set on the replies of a thread, they are not shown in
+    // the conversation; ReplyCount is set on the root
+    ThreadRootID *string   `json:"thread_root_id,omitempty"`
+    ReplyCount  int        `json:"reply_count,omitempty"`
+    // Preview describes the page of the first link, the server adds it once
+    // fetched
+    Preview     *LinkPreview `json:"preview,omitempty"`
+    // RecipientDND is set on the copy of a direct message sent to a user in
+    // do not disturb, who won't be notified of it for now
+    RecipientDND bool      `json:"recipient_dnd,omitempty"`
+    // Status is how far a direct message went: sent, delivered to a device
+    // of the recipient, or read
+    Status      string     `json:"status,omitempty"`
+    // System is set on the events of a group shown among its messages, they
+    // have no sender and are not stored
+    System      *SystemEvent `json:"system,omitempty"`
+}
+
+// SystemEvent is what happened in a group, the kinds are
+// protocol.SystemJoined and the next ones
+type SystemEvent struct {
+    Kind   string `json:"kind"`
+    Actor  string `json:"actor"`
+    Target string `json:"target,omitempty"`
+}
+
+// Voice is the recording of a voice message
+type Voice struct {
+    AttachmentID string `json:"attachment_id"`
+    Duration     int64  `json:"duration_ms"`
+    Waveform     []byte `json:"waveform,omitempty"`
+    // Path is the recording on this computer, for the messages sent from it
+    Path         string `json:"-"`
+}
+
+// LinkPreview is the title, description and image of a linked page
+type LinkPreview struct {
+    URL         string `json:"url"`
+    Title       string `json:"title,omitempty"`
+    Description string `json:"description,omitempty"`
+    ImageURL    string `json:"image_url,omitempty"`
+    SiteName    string `json:"site_name,omitempty"`
+}
+
+
+type MessageRevision struct {
+    Content  string    `json:"content"`
+    EditedBy string    `json:"edited_by"`
+    EditedAt time.Time `json:"edited_at"`
 }
 
 
@@ -25,6 +85,26 @@ type User struct {
     ID       string `json:"id"`
     Username string `json:"username"`
     Status   string `json:"status"`
+    // StatusText is the custom status ("in a meeting"), hidden once
+    // StatusExpires is past when set
+    StatusText    string    `json:"text,omitempty"`
+    StatusExpires time.Time `json:"-"`
+}
+
+// DisplayPrefs are the timezone, clock (24h or 12h) and language the user
+// chose for all their devices, an empty field keeps the setting of the device
+type DisplayPrefs struct {
+    Timezone   string
+    TimeFormat string
+    Locale     string
+}
+
+// ActiveStatusText returns the custom status of the user unless it expired
+func (u User) ActiveStatusText() string {
+    if !u.StatusExpires.IsZero() && time.Now().After(u.StatusExpires) {
+        return ""
+    }
+    return u.StatusText
 }
 
 
@@ -35,6 +115,106 @@ type Group struct {
     CreatedBy   string    `json:"created_by"`
     CreatedAt   time.Time `json:"created_at"`
     Members     []string  `json:"members"`
+    Public      bool      `json:"public"`
+    Encrypted   bool      `json:"encrypted"`
+    // only the Posters write in an announcement group, the others read
+    Announcement bool     `json:"announcement"`
+    Posters     []string  `json:"posters,omitempty"`
+    // set by the admins, shown above the chat
+    Topic       string    `json:"topic,omitempty"`
+}
+
+// CanPost tells if a user can write in the group
+func (g Group) CanPost(userID string) bool {
+    if !g.Announcement {
+        return true
+    }
+    for _, id := range g.Posters {
+        if id == userID {
+            return true
+        }
+    }
+    return false
+}
+
+// GroupSummary is a public group listed in the group directory
+type GroupSummary struct {
+    ID          string
+    Name        string
+    Description string
+    MemberCount int
+    IsMember    bool
+    Announcement bool
+}
+
+// UserSummary is a user found by a user search
+type UserSummary struct {
+    ID         string
+    Username   string
+    Status     string
+    StatusText string
+    IsFriend   bool
+}
+
+// AdminStats is the state of the server shown to its admins
+type AdminStats struct {
+    OnlineUsers       int
+    Sessions          int
+    MessagesPerMinute int
+    QueuedMessages    int
+    MaxQueued         int
+    Degraded          int
+    // the recent moderation events, oldest first
+    Events []ModerationEvent
+}
+
+// ModerationEvent is an announcement or a kick by an admin
+type ModerationEvent struct {
+    Action string
+    Actor  string
+    Target string
+    Detail string
+    At     time.Time
+}
+
+type GroupMember struct {
+    UserID   string `json:"user_id"`
+    Username string `json:"username"`
+    Role     string `json:"role"`
+    Status   string `json:"status"`
+}
+
+
+// Notification is an entry of the notification center. ID is empty for the
+// server announcements, the server does not keep them
+type Notification struct {
+    ID        string
+    Kind      string // protocol.Notice*
+    Actor     string
+    Content   string
+    RelatedID string // group or user to open, empty for the global chat
+    CreatedAt time.Time
+    Read      bool
+}
+
+// Mention is a message of the global chat or of a group naming the user,
+// GroupID is empty for the global chat
+type Mention struct {
+    MessageID string
+    GroupID   string
+    GroupName string
+    Sender    string
+    Content   string
+    SentAt    time.Time
+}
+
+// Reminder is a reminder set with /remind, the server sends it as a direct
+// message at RemindAt
+type Reminder struct {
+    ID        string
+    Content   string
+    RemindAt  time.Time
+    CreatedAt time.Time
 }
 
 
@@ -61,9 +241,13 @@ type (
     }
 
 
+    // StatusUpdate is the presence of a user and their custom status text,
+    // shown until ExpiresAt when set
     StatusUpdate struct {
-        UserID string
-        Status string
+        UserID    string
+        Status    string
+        Text      string
+        ExpiresAt time.Time
     }
 
 
@@ -72,23 +256,292 @@ type (
     }
 
 
+    // FriendRequestReceived is a friend request to answer, the pending ones
+    // come again at each login
     FriendRequestReceived struct {
         Request FriendRequest
     }
 
+    // FriendListUpdated carries all the friends of the user, sent at login
+    // and when a request is accepted
+    FriendListUpdated struct {
+        Friends []User
+    }
 
+    // FriendRequestResponded tells that a friend request was answered, on
+    // both sides: Username is the other user
+    FriendRequestResponded struct {
+        RequestID string
+        Username  string
+        Accepted  bool
+    }
+
+
+    // GroupInviteReceived tells that an admin added the user to a group
     GroupInviteReceived struct {
+        Group    Group
+        FromUser string
+    }
+
+    GroupMembersLoaded struct {
+        GroupID string
+        Members []GroupMember
+    }
+
+    // GroupRemoved tells that the user was kicked from a group, or left it
+    // from one of their sessions
+    GroupRemoved struct {
+        GroupID string
+        Left    bool
+    }
+
+    // SystemMessageReceived is an event of a group shown among its messages
+    SystemMessageReceived struct {
+        Message Message
+    }
+
+
+    MessageEdited struct {
+        Message Message
+    }
+
+    // LinkPreviewReceived carries a message again once the server added the
+    // preview of its link
+    LinkPreviewReceived struct {
+        Message Message
+    }
+
+
+    // HistoryLoaded carries a page of history, newest first. RecipientID and
+    // GroupID tell which conversation it belongs to (both empty for global).
+    HistoryLoaded struct {
+        Messages    []Message
+        BeforeID    string
+        RecipientID string
+        GroupID     string
+    }
+
+
+    // ContextLoaded carries a message to jump to with the messages around
+    // it, newest first. Complete is false when messages are missing between
+    // them and those of the client
+    ContextLoaded struct {
+        MessageID   string
+        RecipientID string
+        GroupID     string
+        Messages    []Message
+        Complete    bool
+    }
+
+    // ThreadLoaded carries the root of a thread and a page of its replies,
+    // newest first
+    ThreadLoaded struct {
+        Root     Message
+        Messages []Message
+        BeforeID string
+    }
+
+
+    GroupsLoaded struct {
+        Groups []Group
+    }
+
+    // Synced carries the state sent after each login: the lists of the user,
+    // the unread direct messages by sender and the latest messages of each
+    // conversation
+    Synced struct {
+        Profile       User
+        Friends       []User
+        Requests      []FriendRequest
+        Groups        []Group
+        Unread        map[string]int
+        Conversations []HistoryLoaded
+        Prefs         DisplayPrefs
+    }
+
+
+    GroupCreated struct {
         Group Group
     }
+
+    // GroupTopicChanged carries the new topic of a group, empty once cleared
+    GroupTopicChanged struct {
+        GroupID string
+        Topic   string
+    }
+
+    // GroupJoined confirms that the user joined a public group
+    GroupJoined struct {
+        Group Group
+    }
+
+    GroupDirectoryLoaded struct {
+        Groups []GroupSummary
+    }
+
+
+    // ServerNotice is an announcement from the server (degraded mode, ...)
+    ServerNotice struct {
+        Kind    string
+        Message string
+        Actor   string // the admin of an announcement
+    }
+
+
+    // NotificationReceived is a notification the server keeps until read
+    NotificationReceived struct {
+        Notification Notification
+    }
+
+    // NotificationsLoaded carries the latest notifications, newest first
+    NotificationsLoaded struct {
+        Notifications []Notification
+    }
+
+    // MentionsLoaded carries the latest messages naming the user, newest first
+    MentionsLoaded struct {
+        Mentions []Mention
+    }
+
+    // AttachmentDownloaded is sent once an attachment is saved to Path
+    AttachmentDownloaded struct {
+        ID   string
+        Path string
+    }
+
+    // KeysChanged is sent when a device of a user has new encryption keys:
+    // a new install, or someone between the two users
+    KeysChanged struct {
+        UserID   string
+        Username string
+    }
+
+    // ReminderCreated confirms a reminder the server saved
+    ReminderCreated struct {
+        Reminder Reminder
+    }
+
+    // RemindersLoaded carries the reminders waiting, soonest first
+    RemindersLoaded struct {
+        Reminders []Reminder
+    }
+
+    // NotificationLevelsLoaded carries the notification levels saved on the
+    // server, by conversation
+    NotificationLevelsLoaded struct {
+        Levels map[string]string
+    }
+
+    // DisplayPrefsChanged carries the display preferences saved from one of
+    // the devices of the user
+    DisplayPrefsChanged struct {
+        Prefs DisplayPrefs
+    }
+
+
+    MessageRevisionsLoaded struct {
+        MessageID string
+        Revisions []MessageRevision
+    }
+
+
+    // TypingUpdate tells that a user is typing in a conversation
+    TypingUpdate struct {
+        UserID      string
+        Username    string
+        RecipientID string
+        GroupID     string
+    }
+
+    // MessageStatusChanged tells that the direct messages sent to ChatID,
+    // up to MessageID or all of them, reached Status
+    MessageStatusChanged struct {
+        ChatID    string
+        MessageID string
+        Status    string
+    }
+
+    // ReadMarker tells that a conversation was read on another device of
+    // the user, up to MessageID
+    ReadMarker struct {
+        ChatID    string
+        MessageID string
+    }
+
+    // FriendRemoved tells that a friendship ended, Blocked is set when the
+    // user blocked the friend
+    FriendRemoved struct {
+        UserID  string
+        Blocked bool
+    }
+
+    // ConnectionState tells that the connection to the server was lost or
+    // restored, Attempt counts the reconnection attempts
+    ConnectionState struct {
+        State   string
+        Attempt int
+    }
+
+    // UserFound answers a user lookup by username
+    UserFound struct {
+        UserID   string
+        Username string
+        Status   string
+    }
+
+    // UsersFound answers a user search, Query is empty for the online users
+    UsersFound struct {
+        Query string
+        Users []UserSummary
+    }
+
+    // Unsupported tells that the server does not know a message type the
+    // client sent, it runs an older version
+    Unsupported struct {
+        Type string
+    }
+
+    // Disconnected tells that the server ended the session, a moderator
+    // kicked the user. The client does not reconnect
+    Disconnected struct {
+        Reason string
+        By     string
+    }
+
+    // AdminStatsLoaded carries the stats of the server, only its admins
+    // receive them
+    AdminStatsLoaded struct {
+        Stats AdminStats
+    }
 )
 
 // status
 const (
     StatusOnline  = "online"
     StatusAway    = "away"
+    StatusDND     = "dnd"
     StatusOffline = "offline"
 )
 
+// send states of an own message not acknowledged yet
+const (
+    SendPending = "pending"
+    SendFailed  = "failed"
+)
+
+// connection states
+const (
+    ConnectionConnected    = "connected"
+    ConnectionReconnecting = "reconnecting"
+    ConnectionOffline      = "offline"
+)
+
+// roles in a group
+const (
+    GroupRoleAdmin  = "admin"
+    GroupRoleMember = "member"
+)
+
 // type of message
 const (
     MessageTypeGlobal = "global"
@@ -123,11 +576,21 @@ func (m *Message) IsGroup() bool {
 }
 
 
+func (m *Message) IsSystem() bool {
+    return m.System != nil
+}
+
+
 func (m *Message) IsGlobal() bool {
     return m.RecipientID == nil && m.GroupID == nil
 }
 
 
+func (m *Message) IsEdited() bool {
+    return m.EditedAt != nil
+}
+
+
 func (m *Message) GetChatID() string {
     if m.GroupID != nil {
         return *m.GroupID
@@ -195,4 +658,7 @@ type Client struct {
 
 type ErrorMsg struct {
 	Error string `json:"error"`
+	// how long the server refuses the messages of the global chat, set with
+	// protocol.ErrCodeRateLimited
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
 }
@@ -0,0 +1,215 @@
+// internal/client/network/attachments.go
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sync"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+)
+
+// transfers are the uploads waiting for the server and the downloads in
+// progress
+type transfers struct {
+    mu sync.Mutex
+    // uploads are by upload ID
+    uploads map[string]*upload
+    // downloads are by attachment ID
+    downloads map[string]*download
+}
+
+// upload is a recording sent to the server, its voice message follows
+type upload struct {
+    path    string
+    message protocol.VoiceMessagePayload
+}
+
+type download struct {
+    path string
+    data bytes.Buffer
+}
+
+// SendVoiceMessage uploads a recording, then sends it as a voice message to
+// the conversation. The upload runs in the background, the server echoes the
+// message with clientID once stored. The recording is then renamed after its
+// attachment ID in its directory, so one made in the directory of the
+// downloads is not downloaded again
+func (h *ConnectionHandler) SendVoiceMessage(voice models.Voice, clientID string, recipientID, groupID *string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+    // the recording would go in clear
+    if recipientID != nil && h.Encrypted(*recipientID) || groupID != nil && h.GroupEncrypted(*groupID) {
+        return fmt.Errorf("voice messages can't be sent in encrypted conversations")
+    }
+
+    data, err := os.ReadFile(voice.Path)
+    if err != nil {
+        return fmt.Errorf("failed to read the recording: %v", err)
+    }
+    if len(data) == 0 {
+        return fmt.Errorf("the recording is empty")
+    }
+    mimeType := mime.TypeByExtension(filepath.Ext(voice.Path))
+    if mimeType == "" {
+        mimeType = "audio/wav"
+    }
+
+    payload := protocol.VoiceMessagePayload{
+        ClientID: clientID,
+        Voice:    protocol.VoicePayload{Duration: voice.Duration, Waveform: voice.Waveform},
+    }
+    if recipientID != nil {
+        payload.RecipientID = *recipientID
+    }
+    if groupID != nil {
+        payload.GroupID = *groupID
+    }
+
+    h.transfers.mu.Lock()
+    if h.transfers.uploads == nil {
+        h.transfers.uploads = make(map[string]*upload)
+    }
+    h.transfers.uploads[clientID] = &upload{path: voice.Path, message: payload}
+    h.transfers.mu.Unlock()
+
+    go func() {
+        if err := h.upload(clientID, filepath.Base(voice.Path), mimeType, data); err != nil {
+            h.transfers.mu.Lock()
+            delete(h.transfers.uploads, clientID)
+            h.transfers.mu.Unlock()
+            if h.onError != nil {
+                h.onError(fmt.Errorf("failed to upload the voice message: %v", err))
+            }
+        }
+    }()
+    return nil
+}
+
+// upload sends a file in chunks, in order
+func (h *ConnectionHandler) upload(uploadID, name, mimeType string, data []byte) error {
+    for offset := 0; offset < len(data); offset += protocol.AttachmentChunkSize {
+        end := offset + protocol.AttachmentChunkSize
+        if end > len(data) {
+            end = len(data)
+        }
+        chunk := protocol.AttachmentChunkPayload{
+            UploadID: uploadID,
+            Offset:   int64(offset),
+            Data:     data[offset:end],
+            Final:    end == len(data),
+        }
+        if offset == 0 {
+            chunk.Name, chunk.MimeType, chunk.Size = name, mimeType, int64(len(data))
+        }
+        if err := h.sendMessage(protocol.NewMessage(protocol.TypeAttachmentUpload, chunk)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// handleAttachmentUpload sends the voice message of an upload the server
+// stored
+func (h *ConnectionHandler) handleAttachmentUpload(msg protocol.Message) {
+    var chunk protocol.AttachmentChunkPayload
+    if err := decodePayload(msg.Payload, &chunk); err != nil {
+        logging.Warnf("Failed to decode upload: %v", err)
+        return
+    }
+
+    h.transfers.mu.Lock()
+    up, ok := h.transfers.uploads[chunk.UploadID]
+    delete(h.transfers.uploads, chunk.UploadID)
+    h.transfers.mu.Unlock()
+    if !ok {
+        return
+    }
+
+    if err := os.Rename(up.path, filepath.Join(filepath.Dir(up.path), filepath.Base(chunk.AttachmentID))); err != nil {
+        logging.Warnf("Failed to keep the recording: %v", err)
+    }
+    up.message.Voice.AttachmentID = chunk.AttachmentID
+    if err := h.sendMessage(protocol.NewMessage(protocol.TypeVoiceMessage, up.message)); err != nil && h.onError != nil {
+        h.onError(fmt.Errorf("failed to send the voice message: %v", err))
+    }
+}
+
+// DownloadAttachment saves an attachment to path, AttachmentDownloaded is
+// sent once done
+func (h *ConnectionHandler) DownloadAttachment(id, path string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    h.transfers.mu.Lock()
+    if h.transfers.downloads == nil {
+        h.transfers.downloads = make(map[string]*download)
+    }
+    _, started := h.transfers.downloads[id]
+    if !started {
+        h.transfers.downloads[id] = &download{path: path}
+    }
+    h.transfers.mu.Unlock()
+    if started {
+        return nil
+    }
+
+    return h.sendMessage(protocol.NewMessage(protocol.TypeAttachmentDownload, protocol.AttachmentChunkPayload{AttachmentID: id}))
+}
+
+// handleAttachmentDownload adds a chunk to its download, the last one writes
+// the file
+func (h *ConnectionHandler) handleAttachmentDownload(msg protocol.Message) {
+    var chunk protocol.AttachmentChunkPayload
+    if err := decodePayload(msg.Payload, &chunk); err != nil {
+        logging.Warnf("Failed to decode attachment chunk: %v", err)
+        return
+    }
+
+    h.transfers.mu.Lock()
+    dl, ok := h.transfers.downloads[chunk.AttachmentID]
+    if !ok {
+        h.transfers.mu.Unlock()
+        return
+    }
+    if chunk.Offset != int64(dl.data.Len()) {
+        delete(h.transfers.downloads, chunk.AttachmentID)
+        h.transfers.mu.Unlock()
+        logging.Warnf("Attachment %s: chunk at %d, expected %d", chunk.AttachmentID, chunk.Offset, dl.data.Len())
+        return
+    }
+    dl.data.Write(chunk.Data)
+    if !chunk.Final {
+        h.transfers.mu.Unlock()
+        return
+    }
+    delete(h.transfers.downloads, chunk.AttachmentID)
+    h.transfers.mu.Unlock()
+
+    if err := writeFile(dl.path, dl.data.Bytes()); err != nil {
+        if h.onError != nil {
+            h.onError(fmt.Errorf("failed to save the attachment: %v", err))
+        }
+        return
+    }
+    h.emit(models.AttachmentDownloaded{ID: chunk.AttachmentID, Path: dl.path})
+}
+
+// writeFile writes then renames so a partial file is never taken for a
+// downloaded one
+func writeFile(path string, data []byte) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return err
+    }
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
@@ -0,0 +1,479 @@
+// internal/client/network/e2ee.go
+package network
+
+import (
+	"fmt"
+	"sort"
+	"textual/internal/client/e2ee"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// encryption is the end-to-end encryption state of the connection
+type encryption struct {
+    keys *e2ee.Store
+    // bundles are the devices of the peers, requested once per connection
+    bundles map[string][]protocol.DeviceBundle
+    // queued are the messages waiting for the bundles of their recipient
+    queued map[string][]queuedMessage
+    // sent are the texts of the messages waiting for the server ack
+    sent map[string]string
+    // members are the other members of the encrypted groups, as pushed by
+    // the server on each change
+    members map[string][]string
+    // groupQueued are the group messages waiting for the member list
+    groupQueued map[string][]queuedMessage
+    // pending are the group messages received before the sender key of
+    // their sender, by group and device
+    pending map[string][]protocol.EncryptedMessagePayload
+}
+
+// queuedMessage is a direct message, or the sender key of groupID
+type queuedMessage struct {
+    content  string
+    clientID string
+    groupID  string
+}
+
+// messages kept waiting for a sender key, by sender device
+const maxPendingGroupMessages = 100
+
+// SetEncryption encrypts the direct messages end to end with the keys of the
+// store. The keys of the device are published right away when authenticated,
+// and after each reconnection
+func (h *ConnectionHandler) SetEncryption(keys *e2ee.Store) {
+    h.e2eeMu.Lock()
+    h.e2ee = &encryption{
+        keys:        keys,
+        bundles:     make(map[string][]protocol.DeviceBundle),
+        queued:      make(map[string][]queuedMessage),
+        sent:        make(map[string]string),
+        members:     make(map[string][]string),
+        groupQueued: make(map[string][]queuedMessage),
+        pending:     make(map[string][]protocol.EncryptedMessagePayload),
+    }
+    h.e2eeMu.Unlock()
+    h.publishKeys()
+}
+
+// publishKeys sends the bundle of this device, the server then delivers the
+// encrypted messages that waited for it
+func (h *ConnectionHandler) publishKeys() {
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    if enc != nil {
+        // the peers may have new devices since the last connection, and the
+        // groups new members
+        enc.bundles = make(map[string][]protocol.DeviceBundle)
+        enc.members = make(map[string][]string)
+    }
+    h.e2eeMu.Unlock()
+
+    if enc == nil || !h.IsAuthenticated() {
+        return
+    }
+    if err := h.sendMessage(protocol.NewMessage(protocol.TypeKeyBundle, enc.keys.Bundle())); err != nil {
+        logging.Errorf("Failed to publish the encryption keys: %v", err)
+    }
+}
+
+// Encrypted tells if the direct messages with a user are encrypted
+func (h *ConnectionHandler) Encrypted(userID string) bool {
+    h.e2eeMu.Lock()
+    defer h.e2eeMu.Unlock()
+    if h.e2ee == nil || userID == h.userID {
+        return false
+    }
+    if bundles, ok := h.e2ee.bundles[userID]; ok && len(bundles) > 0 {
+        return true
+    }
+    return h.e2ee.keys.KnowsPeer(userID)
+}
+
+// Fingerprints returns the fingerprint of this device and the ones of the
+// devices of a user, ok is false when encryption is disabled
+func (h *ConnectionHandler) Fingerprints(userID string) (own string, peers []string, ok bool) {
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    h.e2eeMu.Unlock()
+    if enc == nil {
+        return "", nil, false
+    }
+
+    for _, fingerprint := range enc.keys.PeerFingerprints(userID) {
+        peers = append(peers, fingerprint)
+    }
+    sort.Strings(peers)
+    return enc.keys.Fingerprint(), peers, true
+}
+
+// sendEncrypted encrypts a direct message for the devices of the recipient,
+// their keys are requested first. It reports false when the message must go
+// in clear: encryption is disabled or the recipient never had keys
+func (h *ConnectionHandler) sendEncrypted(content, clientID, recipientID string) (bool, error) {
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    h.e2eeMu.Unlock()
+    // the notes of the user are kept by the server for their other devices
+    if enc == nil || recipientID == h.userID {
+        return false, nil
+    }
+    return h.sendPairwise(enc, recipientID, queuedMessage{content: content, clientID: clientID})
+}
+
+// sendPairwise encrypts for the devices of a user, their keys are requested
+// first
+func (h *ConnectionHandler) sendPairwise(enc *encryption, recipientID string, msg queuedMessage) (bool, error) {
+    h.e2eeMu.Lock()
+    bundles, fetched := enc.bundles[recipientID]
+    if !fetched {
+        first := len(enc.queued[recipientID]) == 0
+        enc.queued[recipientID] = append(enc.queued[recipientID], msg)
+        h.e2eeMu.Unlock()
+        if !first {
+            return true, nil
+        }
+        return true, h.sendMessage(protocol.NewMessage(protocol.TypeKeyBundleRequest, protocol.KeyBundleRequestPayload{UserID: recipientID}))
+    }
+    h.e2eeMu.Unlock()
+
+    return h.deliverEncrypted(enc, recipientID, bundles, msg)
+}
+
+// deliverEncrypted encrypts and sends one message once the bundles are known
+func (h *ConnectionHandler) deliverEncrypted(enc *encryption, recipientID string, bundles []protocol.DeviceBundle, msg queuedMessage) (bool, error) {
+    if len(bundles) == 0 && !enc.keys.KnowsPeer(recipientID) {
+        return false, nil
+    }
+    if len(bundles) == 0 {
+        // the server may be hiding the keys to read the message
+        return true, fmt.Errorf("the encryption keys of this user are gone, the message was not sent")
+    }
+
+    envelopes, changed, err := enc.keys.Encrypt(recipientID, bundles, msg.content)
+    if err != nil {
+        return true, fmt.Errorf("failed to encrypt the message: %v", err)
+    }
+    if changed {
+        h.emit(models.KeysChanged{UserID: recipientID})
+    }
+
+    if msg.groupID == "" {
+        h.e2eeMu.Lock()
+        enc.sent[msg.clientID] = msg.content
+        h.e2eeMu.Unlock()
+    }
+
+    return true, h.sendMessage(protocol.NewMessage(protocol.TypeEncryptedMessage, protocol.EncryptedMessagePayload{
+        SenderDevice: enc.keys.DeviceID(),
+        RecipientID:  recipientID,
+        GroupID:      msg.groupID,
+        SenderKey:    msg.groupID != "",
+        ClientID:     msg.clientID,
+        Envelopes:    envelopes,
+    }))
+}
+
+// handleKeyBundle sends the messages waiting for the keys of a user
+func (h *ConnectionHandler) handleKeyBundle(msg protocol.Message) {
+    var payload protocol.KeyBundlePayload
+    if err := decodePayload(msg.Payload, &payload); err != nil {
+        logging.Warnf("Failed to decode key bundle: %v", err)
+        return
+    }
+
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    if enc == nil {
+        h.e2eeMu.Unlock()
+        return
+    }
+    enc.bundles[payload.UserID] = payload.Devices
+    queued := enc.queued[payload.UserID]
+    delete(enc.queued, payload.UserID)
+    h.e2eeMu.Unlock()
+
+    for _, q := range queued {
+        encrypted, err := h.deliverEncrypted(enc, payload.UserID, payload.Devices, q)
+        if !encrypted && q.groupID != "" {
+            logging.Warnf("Member %s has no encryption keys, it can't read group %s", payload.UserID, q.groupID)
+            continue
+        }
+        if !encrypted {
+            recipientID := payload.UserID
+            err = h.SendMessageWithID(q.content, q.clientID, &recipientID, nil)
+        }
+        if err != nil && h.onError != nil {
+            h.onError(err)
+        }
+    }
+}
+
+// handleEncryptedMessage decrypts a message of a peer, or turns the ack of a
+// message sent into the stored message
+func (h *ConnectionHandler) handleEncryptedMessage(msg protocol.Message) {
+    var payload protocol.EncryptedMessagePayload
+    if err := decodePayload(msg.Payload, &payload); err != nil {
+        logging.Warnf("Failed to decode encrypted message: %v", err)
+        return
+    }
+
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    h.e2eeMu.Unlock()
+    if enc == nil || h.onMessage == nil {
+        return
+    }
+    h.openEncrypted(enc, payload)
+}
+
+func (h *ConnectionHandler) openEncrypted(enc *encryption, payload protocol.EncryptedMessagePayload) {
+    modelMsg := models.Message{
+        ID:         payload.ID,
+        SenderID:   payload.SenderID,
+        SenderName: payload.SenderName,
+        ClientID:   payload.ClientID,
+        SentAt:     time.Unix(payload.SentAt, 0),
+        Encrypted:  true,
+    }
+    if payload.GroupID != "" {
+        modelMsg.GroupID = &payload.GroupID
+    } else {
+        modelMsg.RecipientID = &payload.RecipientID
+    }
+
+    if payload.SenderID == h.UserID() {
+        h.e2eeMu.Lock()
+        content, ok := enc.sent[payload.ClientID]
+        delete(enc.sent, payload.ClientID)
+        h.e2eeMu.Unlock()
+        if !ok {
+            return
+        }
+        modelMsg.Content = content
+        h.onMessage(modelMsg)
+        return
+    }
+
+    if payload.GroupID != "" && !payload.SenderKey {
+        h.openGroupMessage(enc, payload, modelMsg)
+        return
+    }
+
+    deviceID := enc.keys.DeviceID()
+    for _, env := range payload.Envelopes {
+        if env.DeviceID != deviceID {
+            continue
+        }
+        content, changed, err := enc.keys.Decrypt(payload.SenderID, payload.SenderDevice, env)
+        if err != nil {
+            logging.Warnf("Failed to decrypt message %s: %v", payload.ID, err)
+            if h.onError != nil {
+                h.onError(fmt.Errorf("failed to decrypt a message from %s: %v", payload.SenderName, err))
+            }
+            return
+        }
+        if changed {
+            h.emit(models.KeysChanged{UserID: payload.SenderID, Username: payload.SenderName})
+        }
+        if payload.SenderKey {
+            h.receiveSenderKey(enc, payload, content)
+            return
+        }
+        modelMsg.Content = content
+        h.onMessage(modelMsg)
+        return
+    }
+    logging.Warnf("Encrypted message %s has no envelope for this device", payload.ID)
+}
+
+// trackGroups notes the encrypted groups among the groups the server sent,
+// their messages never go in clear
+func (h *ConnectionHandler) trackGroups(groups ...protocol.GroupPayload) {
+    h.e2eeMu.Lock()
+    defer h.e2eeMu.Unlock()
+    if h.encryptedGroups == nil {
+        h.encryptedGroups = make(map[string]bool)
+    }
+    for _, group := range groups {
+        if group.Encrypted {
+            h.encryptedGroups[group.ID] = true
+        }
+    }
+}
+
+// trackMembers keeps the members of an encrypted group, the next message
+// sent after a change goes with a new sender key. The messages waiting for
+// the members are sent
+func (h *ConnectionHandler) trackMembers(groupID string, members []protocol.GroupMemberInfo) {
+    userID := h.UserID()
+    others := make([]string, 0, len(members))
+    for _, member := range members {
+        if member.ID != userID {
+            others = append(others, member.ID)
+        }
+    }
+
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    if enc == nil || !h.encryptedGroups[groupID] {
+        h.e2eeMu.Unlock()
+        return
+    }
+    enc.members[groupID] = others
+    queued := enc.groupQueued[groupID]
+    delete(enc.groupQueued, groupID)
+    h.e2eeMu.Unlock()
+
+    for _, q := range queued {
+        if err := h.deliverGroupEncrypted(enc, groupID, others, q); err != nil && h.onError != nil {
+            h.onError(err)
+        }
+    }
+}
+
+// forgetGroup drops the keys of a group we are no longer a member of
+func (h *ConnectionHandler) forgetGroup(groupID string) {
+    h.e2eeMu.Lock()
+    enc := h.e2ee
+    delete(h.encryptedGroups, groupID)
+    if enc != nil {
+        delete(enc.members, groupID)
+        delete(enc.groupQueued, groupID)
+    }
+    h.e2eeMu.Unlock()
+
+    if enc != nil {
+        if err := enc.keys.ForgetGroup(groupID); err != nil {
+            logging.Warnf("Failed to forget the keys of group %s: %v", groupID, err)
+        }
+    }
+}
+
+// GroupEncrypted tells if the messages of a group are encrypted
+func (h *ConnectionHandler) GroupEncrypted(groupID string) bool {
+    h.e2eeMu.Lock()
+    defer h.e2eeMu.Unlock()
+    return h.encryptedGroups[groupID]
+}
+
+// sendGroupEncrypted encrypts a message of an encrypted group, the member
+// list is requested first. It reports false for the other groups
+func (h *ConnectionHandler) sendGroupEncrypted(content, clientID, groupID string) (bool, error) {
+    h.e2eeMu.Lock()
+    if !h.encryptedGroups[groupID] {
+        h.e2eeMu.Unlock()
+        return false, nil
+    }
+    enc := h.e2ee
+    if enc == nil {
+        h.e2eeMu.Unlock()
+        return true, fmt.Errorf("this group is end-to-end encrypted, enable encryption to write in it")
+    }
+    msg := queuedMessage{content: content, clientID: clientID}
+    members, known := enc.members[groupID]
+    if !known {
+        first := len(enc.groupQueued[groupID]) == 0
+        enc.groupQueued[groupID] = append(enc.groupQueued[groupID], msg)
+        h.e2eeMu.Unlock()
+        if !first {
+            return true, nil
+        }
+        return true, h.sendMessage(protocol.NewMessage(protocol.TypeGroupMembers, protocol.GroupMembersPayload{GroupID: groupID}))
+    }
+    h.e2eeMu.Unlock()
+
+    return true, h.deliverGroupEncrypted(enc, groupID, members, msg)
+}
+
+// deliverGroupEncrypted sends a new sender key to the members when they
+// changed, then the message encrypted with it
+func (h *ConnectionHandler) deliverGroupEncrypted(enc *encryption, groupID string, members []string, msg queuedMessage) error {
+    distribution, err := enc.keys.GroupKey(groupID, members)
+    if err != nil {
+        return fmt.Errorf("failed to create the group key: %v", err)
+    }
+    if distribution != "" {
+        // a member getting the message before the key keeps it until then
+        for _, memberID := range members {
+            if _, err := h.sendPairwise(enc, memberID, queuedMessage{content: distribution, groupID: groupID}); err != nil {
+                logging.Warnf("Failed to send the group key to %s: %v", memberID, err)
+            }
+        }
+    }
+
+    env, err := enc.keys.EncryptGroup(groupID, msg.content)
+    if err != nil {
+        return fmt.Errorf("failed to encrypt the message: %v", err)
+    }
+
+    h.e2eeMu.Lock()
+    enc.sent[msg.clientID] = msg.content
+    h.e2eeMu.Unlock()
+
+    return h.sendMessage(protocol.NewMessage(protocol.TypeEncryptedMessage, protocol.EncryptedMessagePayload{
+        SenderDevice: enc.keys.DeviceID(),
+        GroupID:      groupID,
+        ClientID:     msg.clientID,
+        Envelopes:    []protocol.EncryptedEnvelope{env},
+    }))
+}
+
+// openGroupMessage decrypts a group message with the sender key of its
+// sender, it waits for the key when it did not arrive yet
+func (h *ConnectionHandler) openGroupMessage(enc *encryption, payload protocol.EncryptedMessagePayload, modelMsg models.Message) {
+    if len(payload.Envelopes) != 1 {
+        logging.Warnf("Encrypted group message %s has %d envelopes", payload.ID, len(payload.Envelopes))
+        return
+    }
+
+    content, err := enc.keys.DecryptGroup(payload.GroupID, payload.SenderID, payload.SenderDevice, payload.Envelopes[0])
+    if err == e2ee.ErrNoSenderKey {
+        key := pendingKey(payload)
+        h.e2eeMu.Lock()
+        if len(enc.pending[key]) < maxPendingGroupMessages {
+            enc.pending[key] = append(enc.pending[key], payload)
+        }
+        h.e2eeMu.Unlock()
+        return
+    }
+    if err != nil {
+        logging.Warnf("Failed to decrypt group message %s: %v", payload.ID, err)
+        if h.onError != nil {
+            h.onError(fmt.Errorf("failed to decrypt a group message from %s: %v", payload.SenderName, err))
+        }
+        return
+    }
+    modelMsg.Content = content
+    h.onMessage(modelMsg)
+}
+
+// receiveSenderKey stores the sender key of a member, then opens the group
+// messages that waited for it
+func (h *ConnectionHandler) receiveSenderKey(enc *encryption, payload protocol.EncryptedMessagePayload, distribution string) {
+    groupID, err := enc.keys.ReceiveSenderKey(payload.SenderID, payload.SenderDevice, distribution)
+    if err != nil {
+        logging.Warnf("Failed to store the group key of %s: %v", payload.SenderName, err)
+        return
+    }
+    if groupID != payload.GroupID {
+        logging.Warnf("Group key of %s sent for another group", payload.SenderName)
+        return
+    }
+
+    key := pendingKey(payload)
+    h.e2eeMu.Lock()
+    pending := enc.pending[key]
+    delete(enc.pending, key)
+    h.e2eeMu.Unlock()
+
+    for _, p := range pending {
+        h.openEncrypted(enc, p)
+    }
+}
+
+func pendingKey(payload protocol.EncryptedMessagePayload) string {
+    return payload.GroupID + "/" + payload.SenderID + "/" + payload.SenderDevice
+}
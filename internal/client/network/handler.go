@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"sync"
+	"textual/internal/client/logging"
 	"textual/internal/client/models"
+	"textual/internal/chaos"
+	"textual/internal/clock"
+	"textual/internal/wiretap"
 	"textual/pkg/protocol"
 	"time"
 )
@@ -18,6 +21,7 @@ type ConnectionHandler struct {
     sendChan     chan protocol.Message
     onMessage    func(models.Message)
     onLoadedMessages func([]models.Message)
+    onEvent      func(interface{})
     onError      func(error)
     onConnect    func()
     onDisconnect func()
@@ -26,36 +30,91 @@ type ConnectionHandler struct {
     mu           sync.RWMutex
     authComplete bool
     userID       string
+    username     string
+    // the custom status kept by the server from the last session
+    statusText    string
+    statusExpires time.Time
     authError error
+    // receives the answer to the auth request, see AuthResult
+    authResult   chan error
+    password     string
+    address      string // redialed when the connection is lost, empty disables it
+    e2ee         *encryption // nil unless the direct messages are encrypted
+    e2eeMu       sync.Mutex
+    // encryptedGroups are the groups whose messages are encrypted, known even
+    // when encryption is disabled so they never go in clear
+    encryptedGroups map[string]bool
+    transfers    transfers
+    clock        clock.Clock
+}
+
+// wireTap records the frames of the connections, nil unless --wire-tap is set
+var wireTap *wiretap.Tap
+
+// chaosNetwork slows and breaks the connections, nil unless --chaos is set
+var chaosNetwork *chaos.Network
+
+// SetWireTap records the frames of the handlers created from now on in the
+// capture of tap, their reconnections included
+func SetWireTap(tap *wiretap.Tap) {
+    wireTap = tap
+}
+
+// SetChaos sends the connections of the handlers created from now on through
+// a bad network, their reconnections included
+func SetChaos(network *chaos.Network) {
+    chaosNetwork = network
+}
+
+// wrapConn puts a new connection through the chaos network and the wire tap,
+// the tap records the frames as the handler sees them
+func wrapConn(conn net.Conn) net.Conn {
+    if chaosNetwork != nil {
+        conn = chaosNetwork.Wrap(conn)
+    }
+    if wireTap != nil {
+        conn = wireTap.Wrap(conn)
+    }
+    return conn
 }
 
 func NewConnectionHandler(conn net.Conn) *ConnectionHandler {
+    conn = wrapConn(conn)
     return &ConnectionHandler{
         conn:         conn,
         sendChan:     make(chan protocol.Message, 100),
         done:         make(chan struct{}),
         authComplete: false,
+        authResult:   make(chan error, 1),
+        clock:        clock.Real,
     }
 }
 
+// SetClock replaces the clock of the pings and of the reconnection backoff,
+// before Start
+func (h *ConnectionHandler) SetClock(c clock.Clock) {
+    h.clock = c
+}
+
 func (h *ConnectionHandler) Start() {
-    log.Printf("Starting connection handler")
-    go h.readLoop()
-    go h.writeLoop()
+    logging.Infof("Starting connection handler")
+    h.startLoops(h.conn, json.NewDecoder(h.conn))
 
     if h.onConnect != nil {
         h.onConnect()
     }
 }
 
+// startLoops reads and writes on conn until it is lost
+func (h *ConnectionHandler) startLoops(conn net.Conn, decoder *json.Decoder) {
+    connDone := make(chan struct{})
+    go h.readLoop(conn, decoder, connDone)
+    go h.writeLoop(conn, connDone)
+}
 
+func (h *ConnectionHandler) readLoop(conn net.Conn, decoder *json.Decoder, connDone chan struct{}) {
+    defer h.connectionLost(conn, connDone)
 
-
-
-func (h *ConnectionHandler) readLoop() {
-    defer h.handleDisconnect()
-
-    decoder := json.NewDecoder(h.conn)
     for {
         select {
         case <-h.done:
@@ -64,7 +123,7 @@ func (h *ConnectionHandler) readLoop() {
             var msg protocol.Message
             if err := decoder.Decode(&msg); err != nil {
                 if err != io.EOF {
-                    log.Printf("Read error: %v", err)
+                    logging.Errorf("Read error: %v", err)
                     if h.onError != nil {
                         h.onError(fmt.Errorf("read error: %v", err))
                     }
@@ -72,32 +131,36 @@ func (h *ConnectionHandler) readLoop() {
                 return
             }
 
-            log.Printf("Received message type: %s", msg.Type)
+            logging.Debugf("Received message type: %s", msg.Type)
             h.handleMessage(msg)
         }
     }
 }
 
-func (h *ConnectionHandler) writeLoop() {
-    encoder := json.NewEncoder(h.conn)
-    ticker := time.NewTicker(30 * time.Second)
+func (h *ConnectionHandler) writeLoop(conn net.Conn, connDone chan struct{}) {
+    encoder := json.NewEncoder(conn)
+    ticker := h.clock.NewTicker(30 * time.Second)
     defer ticker.Stop()
 
     for {
         select {
         case <-h.done:
             return
+        case <-connDone:
+            return
         case msg := <-h.sendChan:
-            h.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+            conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
             if err := encoder.Encode(msg); err != nil {
-                log.Printf("Write error: %v", err)
+                logging.Errorf("Write error: %v", err)
                 if h.onError != nil {
                     h.onError(fmt.Errorf("write error: %v", err))
                 }
+                // the read loop fails too and handles the loss
+                conn.Close()
                 return
             }
-            log.Printf("Successfully sent message type: %s", msg.Type)
-        case <-ticker.C:
+            logging.Debugf("Successfully sent message type: %s", msg.Type)
+        case <-ticker.C():
             h.mu.RLock()
             isAuth := h.authComplete
             h.mu.RUnlock()
@@ -116,51 +179,122 @@ func (h *ConnectionHandler) handleMessage(msg protocol.Message) {
     switch msg.Type {
     case protocol.TypeFriendRequest:
         var friendReq protocol.FriendRequestPayload
-        data, err := json.Marshal(msg.Payload)
-        if err != nil {
-            log.Printf("Error marshaling friend request payload: %v", err)
+        if err := decodePayload(msg.Payload, &friendReq); err != nil {
+            logging.Warnf("Error decoding friend request payload: %v", err)
             return
         }
-        
-        if err := json.Unmarshal(data, &friendReq); err != nil {
-            log.Printf("Error unmarshaling friend request payload: %v", err)
+        // "sent" confirms a request of the user, "pending" is one to answer
+        if friendReq.Status != "pending" {
+            logging.Debugf("Friend request to %s %s", friendReq.ToUser, friendReq.Status)
+            return
+        }
+        h.emit(models.FriendRequestReceived{Request: models.FriendRequest{
+            ID:        friendReq.RequestID,
+            FromUser:  friendReq.FromUser,
+            ToUser:    friendReq.ToUser,
+            Status:    friendReq.Status,
+            CreatedAt: time.Unix(msg.Timestamp, 0),
+        }})
+
+    case protocol.TypeFriendList:
+        var payload protocol.FriendListPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode friend list: %v", err)
             return
         }
+        friends := make([]models.User, 0, len(payload.Friends))
+        for _, friend := range payload.Friends {
+            friends = append(friends, convertUser(friend))
+        }
+        h.emit(models.FriendListUpdated{Friends: friends})
 
-        if h.onMessage != nil {
-            // friend request message
-            h.onMessage(models.Message{
-                ID:         friendReq.RequestID,
-                Content:    "Friend request",
-                SenderID:   friendReq.FromUser,
-                SenderName: friendReq.FromUser,
-                SentAt:    time.Now(),
-            })
+    case protocol.TypeSync:
+        var payload protocol.SyncPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode sync: %v", err)
+            return
         }
-    case protocol.TypeMessageHistory:
-        data, err := json.Marshal(msg.Payload)
-        if err != nil {
-            log.Printf("Failed to marshal message history payload: %v", err)
+        h.trackGroups(payload.Groups...)
+        h.emit(convertSync(payload))
+
+    case protocol.TypeFriendResponse:
+        var payload protocol.FriendResponsePayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode friend response: %v", err)
             return
         }
-        
+        h.emit(models.FriendRequestResponded{
+            RequestID: payload.RequestID,
+            Username:  payload.FromUser,
+            Accepted:  payload.Accept,
+        })
+    case protocol.TypeMessageHistory:
         var historyPayload struct {
-            Messages []models.Message `json:"messages"`
+            Messages    []models.Message `json:"messages"`
+            BeforeID    string           `json:"before_id"`
+            RecipientID string           `json:"recipient_id"`
+            GroupID     string           `json:"group_id"`
         }
         
-        if err := json.Unmarshal(data, &historyPayload); err != nil {
-            log.Printf("Failed to unmarshal message history: %v", err)
+        if err := decodePayload(msg.Payload, &historyPayload); err != nil {
+            logging.Warnf("Failed to decode message history: %v", err)
             return
         }
         
-        for _, msg := range historyPayload.Messages {
-            if h.onMessage != nil {
-                h.onMessage(msg)
-            }
-        }
+        h.emit(models.HistoryLoaded{
+            Messages:    historyPayload.Messages,
+            BeforeID:    historyPayload.BeforeID,
+            RecipientID: historyPayload.RecipientID,
+            GroupID:     historyPayload.GroupID,
+        })
         
+    case protocol.TypeFetchContext:
+        var context struct {
+            MessageID   string           `json:"message_id"`
+            Messages    []models.Message `json:"messages"`
+            RecipientID string           `json:"recipient_id"`
+            GroupID     string           `json:"group_id"`
+            Complete    bool             `json:"complete"`
+        }
+        if err := decodePayload(msg.Payload, &context); err != nil {
+            logging.Warnf("Failed to decode message context: %v", err)
+            return
+        }
+        h.emit(models.ContextLoaded{
+            MessageID:   context.MessageID,
+            RecipientID: context.RecipientID,
+            GroupID:     context.GroupID,
+            Messages:    context.Messages,
+            Complete:    context.Complete,
+        })
+
+    case protocol.TypeThreadHistory:
+        var thread struct {
+            Root     models.Message   `json:"root"`
+            Messages []models.Message `json:"messages"`
+            BeforeID string           `json:"before_id"`
+        }
+        if err := decodePayload(msg.Payload, &thread); err != nil {
+            logging.Warnf("Failed to decode thread: %v", err)
+            return
+        }
+        h.emit(models.ThreadLoaded{Root: thread.Root, Messages: thread.Messages, BeforeID: thread.BeforeID})
+
     case protocol.TypeAuthResponse:
         h.handleAuthResponse(msg)
+        h.publishKeys()
+
+    case protocol.TypeKeyBundle:
+        h.handleKeyBundle(msg)
+
+    case protocol.TypeEncryptedMessage:
+        h.handleEncryptedMessage(msg)
+
+    case protocol.TypeAttachmentUpload:
+        h.handleAttachmentUpload(msg)
+
+    case protocol.TypeAttachmentDownload:
+        h.handleAttachmentDownload(msg)
         
     case protocol.TypeDirectMessage, protocol.TypeGroupMessage, protocol.TypeGlobalMessage:
         h.mu.RLock()
@@ -168,24 +302,429 @@ func (h *ConnectionHandler) handleMessage(msg protocol.Message) {
         h.mu.RUnlock()
         
         if !isAuth {
-            log.Printf("Received message but not authenticated")
+            logging.Warnf("Received message but not authenticated")
             return
         }
         
         if modelMsg, err := h.convertToModelMessage(msg); err == nil {
-            log.Printf("Converted message: %+v", modelMsg)
+            logging.Debugf("Converted message: %+v", modelMsg)
             if h.onMessage != nil {
                 h.onMessage(modelMsg)
             }
         } else {
-            log.Printf("Failed to convert message: %v", err)
+            logging.Warnf("Failed to convert message: %v", err)
         }
         
+    case protocol.TypeMessageEdit:
+        if modelMsg, err := h.convertToModelMessage(msg); err == nil {
+            h.emit(models.MessageEdited{Message: modelMsg})
+        } else {
+            logging.Warnf("Failed to convert edited message: %v", err)
+        }
+
+    case protocol.TypeLinkPreview:
+        if modelMsg, err := h.convertToModelMessage(msg); err == nil {
+            h.emit(models.LinkPreviewReceived{Message: modelMsg})
+        } else {
+            logging.Warnf("Failed to convert link preview: %v", err)
+        }
+
+    case protocol.TypeMessageRevisions:
+        var payload protocol.MessageRevisionsPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode message revisions: %v", err)
+            return
+        }
+
+        revisions := make([]models.MessageRevision, 0, len(payload.Revisions))
+        for _, rev := range payload.Revisions {
+            revisions = append(revisions, models.MessageRevision{
+                Content:  rev.Content,
+                EditedBy: rev.EditedBy,
+                EditedAt: time.Unix(rev.EditedAt, 0),
+            })
+        }
+        h.emit(models.MessageRevisionsLoaded{MessageID: payload.MessageID, Revisions: revisions})
+
+    case protocol.TypeStatusUpdate:
+        var payload protocol.StatusUpdatePayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode status update: %v", err)
+            return
+        }
+        h.emit(models.StatusUpdate{
+            UserID:    payload.UserID,
+            Status:    payload.Status,
+            Text:      payload.Text,
+            ExpiresAt: unixTime(payload.ExpiresAt),
+        })
+
+    case protocol.TypeGroupList:
+        var payload protocol.GroupListPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode group list: %v", err)
+            return
+        }
+
+        h.trackGroups(payload.Groups...)
+        groups := make([]models.Group, 0, len(payload.Groups))
+        for _, group := range payload.Groups {
+            groups = append(groups, convertGroup(group))
+        }
+        h.emit(models.GroupsLoaded{Groups: groups})
+
+    case protocol.TypeGroupCreate:
+        var payload protocol.GroupPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode created group: %v", err)
+            return
+        }
+        h.trackGroups(payload)
+        h.emit(models.GroupCreated{Group: convertGroup(payload)})
+
+    case protocol.TypeGroupDirectory:
+        var payload protocol.GroupDirectoryPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode group directory: %v", err)
+            return
+        }
+
+        groups := make([]models.GroupSummary, 0, len(payload.Groups))
+        for _, group := range payload.Groups {
+            groups = append(groups, models.GroupSummary{
+                ID:          group.ID,
+                Name:        group.Name,
+                Description: group.Description,
+                MemberCount: group.MemberCount,
+                IsMember:    group.IsMember,
+                Announcement: group.Announcement,
+            })
+        }
+        h.emit(models.GroupDirectoryLoaded{Groups: groups})
+
+    case protocol.TypeGroupJoin:
+        var payload protocol.GroupJoinPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil || payload.Group == nil {
+            logging.Warnf("Failed to decode group join: %v", err)
+            return
+        }
+        h.trackGroups(*payload.Group)
+        h.emit(models.GroupJoined{Group: convertGroup(*payload.Group)})
+
+    case protocol.TypeGroupInvite:
+        var payload protocol.GroupInvitePayload
+        if err := decodePayload(msg.Payload, &payload); err != nil || payload.Group == nil {
+            logging.Warnf("Failed to decode group invite: %v", err)
+            return
+        }
+        h.trackGroups(*payload.Group)
+        h.emit(models.GroupInviteReceived{Group: convertGroup(*payload.Group), FromUser: payload.FromUser})
+
+    case protocol.TypeGroupMembers:
+        var payload protocol.GroupMembersPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode group members: %v", err)
+            return
+        }
+
+        members := make([]models.GroupMember, 0, len(payload.Members))
+        for _, member := range payload.Members {
+            members = append(members, models.GroupMember{
+                UserID:   member.ID,
+                Username: member.Username,
+                Role:     member.Role,
+                Status:   member.Status,
+            })
+        }
+        h.trackMembers(payload.GroupID, payload.Members)
+        h.emit(models.GroupMembersLoaded{GroupID: payload.GroupID, Members: members})
+
+    case protocol.TypeGroupKick:
+        var payload protocol.GroupJoinPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode group kick: %v", err)
+            return
+        }
+        h.forgetGroup(payload.GroupID)
+        h.emit(models.GroupRemoved{GroupID: payload.GroupID})
+
+    case protocol.TypeGroupLeave:
+        var payload protocol.GroupJoinPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode group leave: %v", err)
+            return
+        }
+        h.forgetGroup(payload.GroupID)
+        h.emit(models.GroupRemoved{GroupID: payload.GroupID, Left: true})
+
+    case protocol.TypeSystemMessage:
+        var payload protocol.SystemMessagePayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode system message: %v", err)
+            return
+        }
+        h.emit(models.SystemMessageReceived{Message: systemMessage(payload)})
+
+    case protocol.TypeGroupTopic:
+        var payload protocol.GroupTopicPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode group topic: %v", err)
+            return
+        }
+        h.emit(models.GroupTopicChanged{GroupID: payload.GroupID, Topic: payload.Topic})
+
+    case protocol.TypeGroupTopicHistory:
+        var payload protocol.GroupTopicHistoryPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode topic history: %v", err)
+            return
+        }
+        // shown among the messages like the changes received live
+        for _, change := range payload.Changes {
+            h.emit(models.SystemMessageReceived{Message: systemMessage(protocol.SystemMessagePayload{
+                GroupID: payload.GroupID,
+                Kind:    protocol.SystemTopic,
+                Actor:   change.SetBy,
+                Target:  change.Topic,
+                At:      change.SetAt,
+            })})
+        }
+
+    case protocol.TypeNotification:
+        var notice protocol.NotificationPayload
+        if err := decodePayload(msg.Payload, &notice); err != nil {
+            logging.Warnf("Failed to decode notification: %v", err)
+            return
+        }
+        if notice.ID == "" {
+            h.emit(models.ServerNotice{Kind: notice.Type, Message: notice.Message, Actor: notice.Actor})
+            return
+        }
+        h.emit(models.NotificationReceived{Notification: convertNotification(notice)})
+
+    case protocol.TypeNotificationList:
+        var payload protocol.NotificationListPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode notifications: %v", err)
+            return
+        }
+        notifications := make([]models.Notification, 0, len(payload.Notifications))
+        for _, notice := range payload.Notifications {
+            notifications = append(notifications, convertNotification(notice))
+        }
+        h.emit(models.NotificationsLoaded{Notifications: notifications})
+
+    case protocol.TypeMentionsList:
+        var payload protocol.MentionsListPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode mentions: %v", err)
+            return
+        }
+        mentions := make([]models.Mention, 0, len(payload.Mentions))
+        for _, mention := range payload.Mentions {
+            mentions = append(mentions, models.Mention{
+                MessageID: mention.MessageID,
+                GroupID:   mention.GroupID,
+                GroupName: mention.GroupName,
+                Sender:    mention.Sender,
+                Content:   mention.Content,
+                SentAt:    time.Unix(mention.SentAt, 0),
+            })
+        }
+        h.emit(models.MentionsLoaded{Mentions: mentions})
+
+    case protocol.TypeNotificationPrefs:
+        var payload protocol.NotificationPrefsPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode notification levels: %v", err)
+            return
+        }
+        h.emit(models.NotificationLevelsLoaded{Levels: payload.Levels})
+
+    case protocol.TypeDisplayPrefs:
+        var payload protocol.DisplayPrefsPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode display prefs: %v", err)
+            return
+        }
+        h.emit(models.DisplayPrefsChanged{Prefs: convertDisplayPrefs(payload)})
+
+    case protocol.TypeReminderCreate:
+        var payload protocol.ReminderPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode reminder: %v", err)
+            return
+        }
+        h.emit(models.ReminderCreated{Reminder: convertReminder(payload)})
+
+    case protocol.TypeReminderList:
+        var payload protocol.ReminderListPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode reminders: %v", err)
+            return
+        }
+        reminders := make([]models.Reminder, 0, len(payload.Reminders))
+        for _, reminder := range payload.Reminders {
+            reminders = append(reminders, convertReminder(reminder))
+        }
+        h.emit(models.RemindersLoaded{Reminders: reminders})
+
+    case protocol.TypeTyping:
+        var payload protocol.TypingPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode typing notification: %v", err)
+            return
+        }
+        h.emit(models.TypingUpdate{
+            UserID:      payload.UserID,
+            Username:    payload.Username,
+            RecipientID: payload.RecipientID,
+            GroupID:     payload.GroupID,
+        })
+
+    case protocol.TypeReadMarker:
+        var payload protocol.ReadMarkerPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode read marker: %v", err)
+            return
+        }
+        h.emit(models.ReadMarker{ChatID: payload.ChatID, MessageID: payload.MessageID})
+
+    case protocol.TypeMessageStatus:
+        var payload protocol.MessageStatusPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode message status: %v", err)
+            return
+        }
+        h.emit(models.MessageStatusChanged{
+            ChatID:    payload.ChatID,
+            MessageID: payload.MessageID,
+            Status:    payload.Status,
+        })
+
+    case protocol.TypeFriendRemove, protocol.TypeFriendBlock:
+        var payload protocol.FriendRemovePayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode friend removal: %v", err)
+            return
+        }
+        h.emit(models.FriendRemoved{
+            UserID:  payload.FriendID,
+            Blocked: payload.Blocked,
+        })
+
+    case protocol.TypeUserLookup:
+        var payload protocol.UserLookupPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode user lookup: %v", err)
+            return
+        }
+        h.emit(models.UserFound{UserID: payload.UserID, Username: payload.Username, Status: payload.Status})
+
+    case protocol.TypeUserSearch:
+        var payload protocol.UserSearchPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode user search: %v", err)
+            return
+        }
+        users := make([]models.UserSummary, 0, len(payload.Users))
+        for _, user := range payload.Users {
+            users = append(users, models.UserSummary{
+                ID:         user.ID,
+                Username:   user.Username,
+                Status:     user.Status,
+                StatusText: user.StatusText,
+                IsFriend:   user.IsFriend,
+            })
+        }
+        h.emit(models.UsersFound{Query: payload.Query, Users: users})
+
+    case protocol.TypeAdminStats:
+        var payload protocol.AdminStatsPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode admin stats: %v", err)
+            return
+        }
+        stats := models.AdminStats{
+            OnlineUsers:       payload.OnlineUsers,
+            Sessions:          payload.Sessions,
+            MessagesPerMinute: payload.MessagesPerMinute,
+            QueuedMessages:    payload.QueuedMessages,
+            MaxQueued:         payload.MaxQueued,
+            Degraded:          payload.Degraded,
+            Events:            make([]models.ModerationEvent, 0, len(payload.Events)),
+        }
+        for _, event := range payload.Events {
+            stats.Events = append(stats.Events, models.ModerationEvent{
+                Action: event.Action,
+                Actor:  event.Actor,
+                Target: event.Target,
+                Detail: event.Detail,
+                At:     time.Unix(event.At, 0),
+            })
+        }
+        h.emit(models.AdminStatsLoaded{Stats: stats})
+
+    case protocol.TypeDisconnectNotice:
+        var payload protocol.DisconnectNoticePayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode disconnect notice: %v", err)
+        }
+        // the server closes the connection next, coming back would undo
+        // the kick
+        h.mu.Lock()
+        h.address = ""
+        h.mu.Unlock()
+        h.emit(models.Disconnected{Reason: payload.Reason, By: payload.By})
+
+    case protocol.TypeError:
+        var errPayload struct {
+            Code       int    `json:"code"`
+            Message    string `json:"message"`
+            Error      string `json:"error"`
+            RetryAfter int    `json:"retry_after"`
+        }
+        if err := decodePayload(msg.Payload, &errPayload); err != nil {
+            logging.Warnf("Failed to decode error: %v", err)
+            return
+        }
+
+        message := errPayload.Message
+        if message == "" {
+            message = errPayload.Error
+        }
+        logging.Errorf("Server error [%d]: %s", errPayload.Code, message)
+        if !h.IsAuthenticated() {
+            h.setAuthError(fmt.Errorf("authentication failed: %s", message))
+        }
+        if h.onError != nil {
+            protoErr := protocol.NewError(errPayload.Code, message)
+            protoErr.RetryAfter = time.Duration(errPayload.RetryAfter) * time.Second
+            h.onError(protoErr)
+        }
+
     case protocol.TypePong:
         // Ignore pong messages
-        
+
+    case protocol.TypePing:
+        // the server checks the connection, the write succeeding is the answer
+
+    case protocol.TypeUnsupported:
+        var payload protocol.UnsupportedPayload
+        if err := decodePayload(msg.Payload, &payload); err != nil {
+            logging.Warnf("Failed to decode unsupported notice: %v", err)
+            return
+        }
+        logging.Warnf("The server does not support %s", payload.Type)
+        h.emit(models.Unsupported{Type: string(payload.Type)})
+
     default:
-        log.Printf("Received unknown message type: %s", msg.Type)
+        // a newer server, it learns that this client is older
+        logging.Warnf("Received unknown message type: %s", msg.Type)
+        reply := protocol.NewMessage(protocol.TypeUnsupported, protocol.UnsupportedPayload{Type: msg.Type})
+        if err := h.sendMessage(reply); err != nil {
+            logging.Warnf("Failed to send unsupported notice: %v", err)
+        }
     }
 }
 
@@ -227,6 +766,24 @@ func (h *ConnectionHandler) SetMessageHandler(handler func(models.Message)) {
     h.onMessage = handler
 }
 
+// SetEventHandler registers the callback receiving client events
+// (models.MessageEdited, models.MessageRevisionsLoaded, ...)
+func (h *ConnectionHandler) SetEventHandler(handler func(interface{})) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.onEvent = handler
+}
+
+func (h *ConnectionHandler) emit(event interface{}) {
+    h.mu.RLock()
+    onEvent := h.onEvent
+    h.mu.RUnlock()
+
+    if onEvent != nil {
+        onEvent(event)
+    }
+}
+
 func (h *ConnectionHandler) SetErrorHandler(handler func(error)) {
     h.mu.Lock()
     defer h.mu.Unlock()
@@ -248,7 +805,11 @@ func (h *ConnectionHandler) SetDisconnectHandler(handler func()) {
 func (h *ConnectionHandler) handleDisconnect() {
     h.closeOnce.Do(func() {
         close(h.done)
-        h.conn.Close()
+        h.mu.RLock()
+        conn := h.conn
+        h.mu.RUnlock()
+        conn.Close()
+        h.emit(models.ConnectionState{State: models.ConnectionOffline})
         if h.onDisconnect != nil {
             h.onDisconnect()
         }
@@ -262,18 +823,11 @@ func (h *ConnectionHandler) Close() error {
 
 
 func (h *ConnectionHandler) handleAuthResponse(msg protocol.Message) {
-    log.Printf("Processing auth response: %+v", msg)
+    logging.Debugf("Processing auth response: %+v", msg)
 
     var authResp protocol.AuthResponsePayload
-    data, err := json.Marshal(msg.Payload)
-    if err != nil {
-        log.Printf("Failed to marshal auth payload: %v", err)
-        h.setAuthError(err)
-        return
-    }
-
-    if err := json.Unmarshal(data, &authResp); err != nil {
-        log.Printf("Failed to unmarshal auth payload: %v", err)
+    if err := decodePayload(msg.Payload, &authResp); err != nil {
+        logging.Warnf("Failed to decode auth payload: %v", err)
         h.setAuthError(err)
         return
     }
@@ -284,15 +838,21 @@ func (h *ConnectionHandler) handleAuthResponse(msg protocol.Message) {
     if authResp.Success {
         h.authComplete = true
         h.userID = authResp.UserID
+        if authResp.Username != "" {
+            h.username = authResp.Username
+        }
+        h.statusText, h.statusExpires = authResp.StatusText, unixTime(authResp.StatusExpiresAt)
         h.authError = nil
-        log.Printf("Authentication successful. UserID: %s", h.userID)
+        h.resolveAuth(nil)
+        logging.Infof("Authentication successful. UserID: %s", h.userID)
     } else {
         h.authComplete = false
         h.authError = fmt.Errorf("authentication failed: %s", authResp.Error)
+        h.resolveAuth(h.authError)
         if h.onError != nil {
             h.onError(h.authError)
         }
-        log.Printf("Authentication failed: %s", authResp.Error)
+        logging.Errorf("Authentication failed: %s", authResp.Error)
     }
 }
 
@@ -300,6 +860,35 @@ func (h *ConnectionHandler) setAuthError(err error) {
     h.mu.Lock()
     defer h.mu.Unlock()
     h.authError = err
+    h.resolveAuth(err)
+}
+
+// resolveAuth hands the answer to the auth request to AuthResult, with h.mu
+// held. An answer nobody waits for is dropped by the next request
+func (h *ConnectionHandler) resolveAuth(err error) {
+    select {
+    case h.authResult <- err:
+    default:
+    }
+}
+
+// AuthResult receives the answer to the last auth request: nil once
+// authenticated, the reason otherwise
+func (h *ConnectionHandler) AuthResult() <-chan error {
+    return h.authResult
+}
+
+// WaitAuth waits for the answer to the auth request, at most timeout on the
+// clock of the handler
+func (h *ConnectionHandler) WaitAuth(timeout time.Duration) error {
+    select {
+    case err := <-h.authResult:
+        return err
+    case <-h.done:
+        return fmt.Errorf("connection closed")
+    case <-h.clock.After(timeout):
+        return fmt.Errorf("authentication timeout")
+    }
 }
 
 func (h *ConnectionHandler) GetAuthError() error {
@@ -309,7 +898,7 @@ func (h *ConnectionHandler) GetAuthError() error {
 }
 
 func (h *ConnectionHandler) SendAuthRequest(username, password string) error {
-    log.Printf("Sending auth request for user: %s", username)
+    logging.Infof("Sending auth request for user: %s", username)
     
     authReq := protocol.Message{
         Type: protocol.TypeAuth,
@@ -322,11 +911,38 @@ func (h *ConnectionHandler) SendAuthRequest(username, password string) error {
 
     h.mu.Lock()
     h.authComplete = false // Reset auth state
+    h.authError = nil
+    select {
+    case <-h.authResult:
+    default:
+    }
+    h.username = username
+    h.password = password // kept to authenticate again after a reconnection
     h.mu.Unlock()
 
     return h.sendMessage(authReq)
 }
 
+func (h *ConnectionHandler) UserID() string {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return h.userID
+}
+
+func (h *ConnectionHandler) Username() string {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return h.username
+}
+
+// StatusText returns the custom status set in the last session and when it
+// expires, zero when it doesn't
+func (h *ConnectionHandler) StatusText() (string, time.Time) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return h.statusText, h.statusExpires
+}
+
 func (h *ConnectionHandler) IsAuthenticated() bool {
     h.mu.RLock()
     defer h.mu.RUnlock()
@@ -334,32 +950,48 @@ func (h *ConnectionHandler) IsAuthenticated() bool {
 }
 
 func (h *ConnectionHandler) sendMessage(msg protocol.Message) error {
-    log.Printf("Sending message type: %s", msg.Type)
+    logging.Debugf("Sending message type: %s", msg.Type)
     select {
     case h.sendChan <- msg:
         return nil
     case <-h.done:
         return fmt.Errorf("connection closed")
-    case <-time.After(5 * time.Second):
+    case <-h.clock.After(5 * time.Second):
         return fmt.Errorf("send timeout")
     }
 }
 
 func (h *ConnectionHandler) SendMessage(content string, recipientID *string, groupID *string) error {
+    return h.SendMessageWithID(content, "", recipientID, groupID)
+}
+
+// SendMessageWithID sends a message with the key chosen by the client, the
+// server stores a message once per key so a retry is safe
+func (h *ConnectionHandler) SendMessageWithID(content, clientID string, recipientID *string, groupID *string) error {
     if !h.IsAuthenticated() {
-        log.Printf("Attempting to send message without authentication")
+        logging.Warnf("Attempting to send message without authentication")
         return fmt.Errorf("not authenticated")
     }
 
-    log.Printf("Preparing to send message. Auth state: %v", h.IsAuthenticated())
+    logging.Debugf("Preparing to send message. Auth state: %v", h.IsAuthenticated())
+
+    if recipientID != nil {
+        if encrypted, err := h.sendEncrypted(content, clientID, *recipientID); encrypted || err != nil {
+            return err
+        }
+    } else if groupID != nil {
+        if encrypted, err := h.sendGroupEncrypted(content, clientID, *groupID); encrypted || err != nil {
+            return err
+        }
+    }
 
     var msg protocol.Message
     if recipientID != nil {
-        msg = protocol.NewDirectMessage(content, h.userID, "", *recipientID)
+        msg = protocol.NewDirectMessage(content, h.userID, "", *recipientID, clientID)
     } else if groupID != nil {
-        msg = protocol.NewGroupMessage(content, h.userID, "", *groupID)
+        msg = protocol.NewGroupMessage(content, h.userID, "", *groupID, clientID)
     } else {
-        msg = protocol.NewGlobalMessage(content, h.userID, "")
+        msg = protocol.NewGlobalMessage(content, h.userID, "", clientID)
     }
 
     return h.sendMessage(msg)
@@ -394,6 +1026,9 @@ func (h *ConnectionHandler) convertToModelMessage(msg protocol.Message) (models.
     if senderName, ok := payload["sender_name"].(string); ok {
         modelMsg.SenderName = senderName
     }
+    if clientID, ok := payload["client_id"].(string); ok {
+        modelMsg.ClientID = clientID
+    }
     if sentAt, ok := payload["sent_at"].(float64); ok {
         modelMsg.SentAt = time.Unix(int64(sentAt), 0)
     }
@@ -401,49 +1036,399 @@ func (h *ConnectionHandler) convertToModelMessage(msg protocol.Message) (models.
         t := time.Unix(int64(readAt), 0)
         modelMsg.ReadAt = &t
     }
+    if editedAt, ok := payload["edited_at"].(float64); ok {
+        t := time.Unix(int64(editedAt), 0)
+        modelMsg.EditedAt = &t
+    }
+    if rootID, ok := payload["thread_root_id"].(string); ok {
+        modelMsg.ThreadRootID = &rootID
+    }
+    if replies, ok := payload["reply_count"].(float64); ok {
+        modelMsg.ReplyCount = int(replies)
+    }
+    if dnd, ok := payload["recipient_dnd"].(bool); ok {
+        modelMsg.RecipientDND = dnd
+    }
+    if status, ok := payload["status"].(string); ok {
+        modelMsg.Status = status
+    }
+    if voice, ok := payload["voice"]; ok {
+        modelMsg.Voice = &models.Voice{}
+        if err := decodePayload(voice, modelMsg.Voice); err != nil {
+            logging.Warnf("Failed to decode voice message: %v", err)
+            modelMsg.Voice = nil
+        }
+    }
+    if preview, ok := payload["preview"]; ok {
+        modelMsg.Preview = &models.LinkPreview{}
+        if err := decodePayload(preview, modelMsg.Preview); err != nil {
+            logging.Warnf("Failed to decode link preview: %v", err)
+            modelMsg.Preview = nil
+        }
+    }
 
     return modelMsg, nil
 }
 
-func (h *ConnectionHandler) SetLoadedMessagesHandler(handler func([]models.Message)) {
-    h.mu.Lock()
-    defer h.mu.Unlock()
-    h.onLoadedMessages = handler
+// systemMessage turns an event of a group into a message of its chat
+func systemMessage(payload protocol.SystemMessagePayload) models.Message {
+    groupID := payload.GroupID
+    return models.Message{
+        // no ID from the server, this one keeps the event selectable and
+        // the same event received twice shown once
+        ID:      fmt.Sprintf("system:%s:%s:%s:%d", payload.Kind, payload.Actor, payload.Target, payload.At),
+        GroupID: &groupID,
+        SentAt:  time.Unix(payload.At, 0),
+        Read:    true,
+        System: &models.SystemEvent{
+            Kind:   payload.Kind,
+            Actor:  payload.Actor,
+            Target: payload.Target,
+        },
+    }
 }
 
-func (h *ConnectionHandler) LoadMessages(beforeID string, limit int) error {
-    if !h.IsAuthenticated() {
-        return fmt.Errorf("not authenticated")
+func convertDisplayPrefs(prefs protocol.DisplayPrefsPayload) models.DisplayPrefs {
+    return models.DisplayPrefs{
+        Timezone:   prefs.Timezone,
+        TimeFormat: prefs.TimeFormat,
+        Locale:     prefs.Locale,
     }
+}
 
-    msg := protocol.NewLoadMessagesRequest(beforeID, limit)
-    return h.sendMessage(msg)
+func convertUser(user protocol.UserInfo) models.User {
+    return models.User{
+        ID:            user.ID,
+        Username:      user.Username,
+        Status:        user.Status,
+        StatusText:    user.Text,
+        StatusExpires: unixTime(user.TextExpiresAt),
+    }
 }
 
-func (h *ConnectionHandler) SendFriendRequest(username string) error {
-    msg := protocol.NewMessage(protocol.TypeFriendRequest, protocol.FriendRequestPayload{
-        ToUser: username,
-    })
-    return h.sendMessage(msg)
+// convertSync turns the sync payload into an event, a conversation that
+// can't be decoded is left out
+func convertSync(payload protocol.SyncPayload) models.Synced {
+    synced := models.Synced{
+        Profile: convertUser(payload.Profile),
+        Unread:  payload.Unread,
+        Prefs:   convertDisplayPrefs(payload.Prefs),
+    }
+    for _, friend := range payload.Friends {
+        synced.Friends = append(synced.Friends, convertUser(friend))
+    }
+    for _, req := range payload.Requests {
+        synced.Requests = append(synced.Requests, models.FriendRequest{
+            ID:        req.RequestID,
+            FromUser:  req.FromUser,
+            ToUser:    req.ToUser,
+            Status:    req.Status,
+            CreatedAt: time.Unix(req.CreatedAt, 0),
+        })
+    }
+    for _, group := range payload.Groups {
+        synced.Groups = append(synced.Groups, convertGroup(group))
+    }
+    for _, conv := range payload.Conversations {
+        var messages []models.Message
+        if err := json.Unmarshal(conv.Messages, &messages); err != nil {
+            logging.Warnf("Failed to decode synced messages: %v", err)
+            continue
+        }
+        synced.Conversations = append(synced.Conversations, models.HistoryLoaded{
+            Messages:    messages,
+            RecipientID: conv.RecipientID,
+            GroupID:     conv.GroupID,
+        })
+    }
+    return synced
 }
 
-func (h *ConnectionHandler) AcceptFriendRequest(requestID string) error {
-    msg := protocol.NewMessage(protocol.TypeFriendResponse, protocol.FriendResponsePayload{
+func convertGroup(group protocol.GroupPayload) models.Group {
+    return models.Group{
+        ID:          group.ID,
+        Name:        group.Name,
+        Description: group.Description,
+        CreatedBy:   group.CreatedBy,
+        CreatedAt:   time.Unix(group.CreatedAt, 0),
+        Members:     group.MemberIDs,
+        Public:      group.Public,
+        Encrypted:   group.Encrypted,
+        Announcement: group.Announcement,
+        Posters:     group.Posters,
+        Topic:       group.Topic,
+    }
+}
+
+func convertNotification(notice protocol.NotificationPayload) models.Notification {
+    return models.Notification{
+        ID:        notice.ID,
+        Kind:      notice.Type,
+        Actor:     notice.Actor,
+        Content:   notice.Message,
+        RelatedID: notice.RelatedID,
+        CreatedAt: time.Unix(notice.CreatedAt, 0),
+        Read:      notice.Read,
+    }
+}
+
+func convertReminder(reminder protocol.ReminderPayload) models.Reminder {
+    return models.Reminder{
+        ID:        reminder.ID,
+        Content:   reminder.Content,
+        RemindAt:  time.Unix(reminder.RemindAt, 0),
+        CreatedAt: time.Unix(reminder.CreatedAt, 0),
+    }
+}
+
+func decodePayload(payload interface{}, target interface{}) error {
+    return protocol.DecodePayload(payload, target)
+}
+
+func (h *ConnectionHandler) SetLoadedMessagesHandler(handler func([]models.Message)) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.onLoadedMessages = handler
+}
+
+func (h *ConnectionHandler) LoadMessages(beforeID string, limit int) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewLoadMessagesRequest(beforeID, limit)
+    return h.sendMessage(msg)
+}
+
+// LoadConversation requests a page of the direct messages with recipientID,
+// or of the group when groupID is set
+func (h *ConnectionHandler) LoadConversation(recipientID, groupID, beforeID string, limit int) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeLoadMessages, protocol.LoadMessagesPayload{
+        BeforeID:    beforeID,
+        Limit:       limit,
+        RecipientID: recipientID,
+        GroupID:     groupID,
+    })
+    return h.sendMessage(msg)
+}
+
+// FetchContext requests a message with the messages around it: before of
+// those sent before it, and those sent after it until untilID
+func (h *ConnectionHandler) FetchContext(messageID, untilID string, before int) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeFetchContext, protocol.FetchContextPayload{
+        MessageID: messageID,
+        Before:    before,
+        UntilID:   untilID,
+    })
+    return h.sendMessage(msg)
+}
+
+// LoadThread requests the root of a thread and a page of its replies
+func (h *ConnectionHandler) LoadThread(rootID, beforeID string, limit int) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeLoadThread, protocol.LoadThreadPayload{
+        RootID:   rootID,
+        BeforeID: beforeID,
+        Limit:    limit,
+    })
+    return h.sendMessage(msg)
+}
+
+// SendReply sends a message to the thread of rootID, in the conversation of
+// the root. The encrypted conversations have no threads
+func (h *ConnectionHandler) SendReply(content, clientID, rootID string, recipientID, groupID *string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+    if recipientID != nil && h.Encrypted(*recipientID) || groupID != nil && h.GroupEncrypted(*groupID) {
+        return fmt.Errorf("threads are not available in encrypted conversations")
+    }
+
+    payload := map[string]interface{}{
+        "content":        content,
+        "sender_id":      h.userID,
+        "client_id":      clientID,
+        "thread_root_id": rootID,
+    }
+    msgType := protocol.TypeGlobalMessage
+    if recipientID != nil {
+        msgType = protocol.TypeDirectMessage
+        payload["recipient_id"] = *recipientID
+    } else if groupID != nil {
+        msgType = protocol.TypeGroupMessage
+        payload["group_id"] = *groupID
+    }
+    return h.sendMessage(protocol.NewMessage(msgType, payload))
+}
+
+// SendStatus sets the user's status and custom status text, the text clears
+// itself at expires unless it is zero
+func (h *ConnectionHandler) SendStatus(status, text string, expires time.Time) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    payload := protocol.StatusUpdatePayload{
+        Status: status,
+        Text:   text,
+    }
+    if !expires.IsZero() {
+        payload.ExpiresAt = expires.Unix()
+    }
+    return h.sendMessage(protocol.NewMessage(protocol.TypeStatusUpdate, payload))
+}
+
+// unixTime reads an optional Unix time, 0 gives the zero time
+func unixTime(seconds int64) time.Time {
+    if seconds == 0 {
+        return time.Time{}
+    }
+    return time.Unix(seconds, 0)
+}
+
+// SendTyping tells the other users of a conversation that the user is typing
+func (h *ConnectionHandler) SendTyping(recipientID, groupID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeTyping, protocol.TypingPayload{
+        RecipientID: recipientID,
+        GroupID:     groupID,
+    })
+    return h.sendMessage(msg)
+}
+
+// SendReadMarker tells the other devices of the user that a conversation
+// was read up to a message
+func (h *ConnectionHandler) SendReadMarker(chatID, messageID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeReadMarker, protocol.ReadMarkerPayload{
+        ChatID:    chatID,
+        MessageID: messageID,
+    })
+    return h.sendMessage(msg)
+}
+
+// LookupUser asks the server for the ID of a user, answered with a
+// models.UserFound event
+func (h *ConnectionHandler) LookupUser(username string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeUserLookup, protocol.UserLookupPayload{
+        Username: username,
+    })
+    return h.sendMessage(msg)
+}
+
+// SearchUsers asks the server for the users whose name starts with query,
+// the online ones when it is empty, answered with a models.UsersFound event
+func (h *ConnectionHandler) SearchUsers(query string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeUserSearch, protocol.UserSearchPayload{
+        Query: query,
+    })
+    return h.sendMessage(msg)
+}
+
+// LoadAdminStats asks the server for its stats, refused unless the user is
+// one of its admins
+func (h *ConnectionHandler) LoadAdminStats() error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+    return h.sendMessage(protocol.NewMessage(protocol.TypeAdminStats, nil))
+}
+
+// Announce sends a notice to every connected user, admins only
+func (h *ConnectionHandler) Announce(message string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+    msg := protocol.NewMessage(protocol.TypeAdminAnnounce, protocol.AdminAnnouncePayload{
+        Message: message,
+    })
+    return h.sendMessage(msg)
+}
+
+// KickUser disconnects every session of a user, who is shown the reason,
+// admins only
+func (h *ConnectionHandler) KickUser(username, reason string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+    msg := protocol.NewMessage(protocol.TypeAdminKick, protocol.AdminKickPayload{
+        Username: username,
+        Reason:   reason,
+    })
+    return h.sendMessage(msg)
+}
+
+func (h *ConnectionHandler) SendFriendRequest(username string) error {
+    msg := protocol.NewMessage(protocol.TypeFriendRequest, protocol.FriendRequestPayload{
+        ToUser: username,
+    })
+    return h.sendMessage(msg)
+}
+
+func (h *ConnectionHandler) AcceptFriendRequest(requestID string) error {
+    return h.RespondFriendRequest(requestID, true)
+}
+
+// RespondFriendRequest accepts or declines a friend request received
+func (h *ConnectionHandler) RespondFriendRequest(requestID string, accept bool) error {
+    msg := protocol.NewMessage(protocol.TypeFriendResponse, protocol.FriendResponsePayload{
         RequestID: requestID,
-        Accept:    true,
+        Accept:    accept,
     })
     return h.sendMessage(msg)
 }
 
 func (h *ConnectionHandler) RemoveFriend(friendID string) error {
-    msg := protocol.NewMessage(protocol.TypeFriendRemove, map[string]string{
-        "friend_id": friendID,
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeFriendRemove, protocol.FriendRemovePayload{
+        FriendID: friendID,
     })
     return h.sendMessage(msg)
 }
 
+// BlockUser removes the user from the friends and prevents any further
+// message or friend request between the two users
+func (h *ConnectionHandler) BlockUser(userID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeFriendBlock, protocol.FriendRemovePayload{
+        FriendID: userID,
+    })
+    return h.sendMessage(msg)
+}
 
-func (h *ConnectionHandler) CreateGroup(name, description string) error {
+
+func (h *ConnectionHandler) CreateGroup(name, description string, public, encrypted, announcement bool) error {
     if !h.IsAuthenticated() {
         return fmt.Errorf("not authenticated")
     }
@@ -451,6 +1436,9 @@ func (h *ConnectionHandler) CreateGroup(name, description string) error {
     msg := protocol.NewMessage(protocol.TypeGroupCreate, protocol.GroupCreatePayload{
         Name:        name,
         Description: description,
+        Public:      public,
+        Encrypted:   encrypted,
+        Announcement: announcement,
     })
 
     return h.sendMessage(msg)
@@ -466,6 +1454,16 @@ func (h *ConnectionHandler) LoadGroups() error {
 }
 
 
+// LoadGroupDirectory requests the list of public groups
+func (h *ConnectionHandler) LoadGroupDirectory() error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupDirectory, nil)
+    return h.sendMessage(msg)
+}
+
 func (h *ConnectionHandler) JoinGroup(groupID string) error {
     if !h.IsAuthenticated() {
         return fmt.Errorf("not authenticated")
@@ -480,6 +1478,58 @@ func (h *ConnectionHandler) JoinGroup(groupID string) error {
 }
 
 
+// LoadGroupMembers requests the members of a group with their roles
+func (h *ConnectionHandler) LoadGroupMembers(groupID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupMembers, protocol.GroupMembersPayload{
+        GroupID: groupID,
+    })
+    return h.sendMessage(msg)
+}
+
+// InviteToGroup adds a user to a group, admins only
+func (h *ConnectionHandler) InviteToGroup(groupID, username string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupInvite, protocol.GroupInvitePayload{
+        GroupID: groupID,
+        ToUser:  username,
+    })
+    return h.sendMessage(msg)
+}
+
+// KickFromGroup removes a member from a group, admins only
+func (h *ConnectionHandler) KickFromGroup(groupID, userID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupKick, protocol.GroupJoinPayload{
+        GroupID: groupID,
+        UserID:  userID,
+    })
+    return h.sendMessage(msg)
+}
+
+// SetGroupRole changes the role of a member, admins only
+func (h *ConnectionHandler) SetGroupRole(groupID, userID, role string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupRoleUpdate, protocol.GroupRolePayload{
+        GroupID: groupID,
+        UserID:  userID,
+        Role:    role,
+    })
+    return h.sendMessage(msg)
+}
+
 func (h *ConnectionHandler) LeaveGroup(groupID string) error {
     if !h.IsAuthenticated() {
         return fmt.Errorf("not authenticated")
@@ -492,3 +1542,160 @@ func (h *ConnectionHandler) LeaveGroup(groupID string) error {
 
     return h.sendMessage(msg)
 }
+
+// SetGroupTopic sets the topic of a group, empty to clear it
+func (h *ConnectionHandler) SetGroupTopic(groupID, topic string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupTopic, protocol.GroupTopicPayload{
+        GroupID: groupID,
+        Topic:   topic,
+    })
+    return h.sendMessage(msg)
+}
+
+// LoadTopicHistory requests the latest topics set on a group
+func (h *ConnectionHandler) LoadTopicHistory(groupID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupTopicHistory, protocol.GroupTopicHistoryPayload{GroupID: groupID})
+    return h.sendMessage(msg)
+}
+
+func (h *ConnectionHandler) EditMessage(messageID, content string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeMessageEdit, protocol.MessageEditPayload{
+        MessageID: messageID,
+        Content:   content,
+    })
+    return h.sendMessage(msg)
+}
+
+// LoadNotifications requests the latest notifications kept by the server
+func (h *ConnectionHandler) LoadNotifications() error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeNotificationList, nil)
+    return h.sendMessage(msg)
+}
+
+// LoadMentions requests the messages naming the user
+func (h *ConnectionHandler) LoadMentions() error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeMentionsList, nil)
+    return h.sendMessage(msg)
+}
+
+// MarkNotificationsRead tells the server the notifications were read, all of
+// them when ids is empty
+func (h *ConnectionHandler) MarkNotificationsRead(ids []string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeNotificationRead, protocol.NotificationReadPayload{
+        IDs: ids,
+    })
+    return h.sendMessage(msg)
+}
+
+// SendNotificationLevels saves notification levels on the server, a nil map
+// only requests the saved ones
+func (h *ConnectionHandler) SendNotificationLevels(levels map[string]string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeNotificationPrefs, protocol.NotificationPrefsPayload{
+        Levels: levels,
+    })
+    return h.sendMessage(msg)
+}
+
+// SetDisplayPrefs saves the display preferences of the user, the server
+// sends them back to all their devices
+func (h *ConnectionHandler) SetDisplayPrefs(prefs models.DisplayPrefs) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeDisplayPrefs, protocol.DisplayPrefsPayload{
+        Timezone:   prefs.Timezone,
+        TimeFormat: prefs.TimeFormat,
+        Locale:     prefs.Locale,
+    })
+    return h.sendMessage(msg)
+}
+
+// CreateReminder asks the server to send content back to the user at a time
+func (h *ConnectionHandler) CreateReminder(content string, at time.Time) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeReminderCreate, protocol.ReminderPayload{
+        Content:  content,
+        RemindAt: at.Unix(),
+    })
+    return h.sendMessage(msg)
+}
+
+// RequestReminders asks for the reminders waiting
+func (h *ConnectionHandler) RequestReminders() error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    return h.sendMessage(protocol.NewMessage(protocol.TypeReminderList, nil))
+}
+
+// CancelReminder deletes a reminder, the server answers with the ones left
+func (h *ConnectionHandler) CancelReminder(id string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeReminderCancel, protocol.ReminderCancelPayload{
+        ID: id,
+    })
+    return h.sendMessage(msg)
+}
+
+// SendGif asks the server to post a GIF found for query, to a user, a group,
+// or the global chat when both are empty
+func (h *ConnectionHandler) SendGif(query, clientID, recipientID, groupID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGif, protocol.GifPayload{
+        Query:       query,
+        RecipientID: recipientID,
+        GroupID:     groupID,
+        ClientID:    clientID,
+    })
+    return h.sendMessage(msg)
+}
+
+func (h *ConnectionHandler) RequestMessageRevisions(messageID string) error {
+    if !h.IsAuthenticated() {
+        return fmt.Errorf("not authenticated")
+    }
+
+    msg := protocol.NewMessage(protocol.TypeMessageRevisions, protocol.MessageRevisionsPayload{
+        MessageID: messageID,
+    })
+    return h.sendMessage(msg)
+}
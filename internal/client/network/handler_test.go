@@ -0,0 +1,106 @@
+// internal/client/network/handler_test.go
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"textual/internal/clock"
+	"textual/pkg/protocol"
+)
+
+// serveAuth answers the auth request read on conn with response
+func serveAuth(t *testing.T, conn net.Conn, response protocol.AuthResponsePayload) {
+    t.Helper()
+    var msg protocol.Message
+    if err := json.NewDecoder(conn).Decode(&msg); err != nil || msg.Type != protocol.TypeAuth {
+        t.Errorf("auth request: %v %+v", err, msg)
+        return
+    }
+    json.NewEncoder(conn).Encode(protocol.NewMessage(protocol.TypeAuthResponse, response))
+}
+
+func TestWaitAuth(t *testing.T) {
+    for _, test := range []struct {
+        name     string
+        response protocol.AuthResponsePayload
+        ok       bool
+    }{
+        {"accepted", protocol.AuthResponsePayload{Success: true, UserID: "u1"}, true},
+        {"refused", protocol.AuthResponsePayload{Error: "invalid password"}, false},
+    } {
+        t.Run(test.name, func(t *testing.T) {
+            local, remote := net.Pipe()
+            defer remote.Close()
+            h := NewConnectionHandler(local)
+            h.Start()
+            defer h.Close()
+            go serveAuth(t, remote, test.response)
+
+            if err := h.SendAuthRequest("alice", "secret"); err != nil {
+                t.Fatal(err)
+            }
+            err := h.WaitAuth(5 * time.Second)
+            if (err == nil) != test.ok {
+                t.Fatalf("WaitAuth: %v", err)
+            }
+            if h.IsAuthenticated() != test.ok {
+                t.Fatalf("authenticated %v after %v", h.IsAuthenticated(), err)
+            }
+        })
+    }
+}
+
+func TestWaitAuthTimeout(t *testing.T) {
+    local, remote := net.Pipe()
+    defer remote.Close()
+    fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+    h := NewConnectionHandler(local)
+    h.SetClock(fake)
+
+    result := make(chan error, 1)
+    go func() { result <- h.WaitAuth(5 * time.Second) }()
+    fake.BlockUntil(1)
+    fake.Advance(5*time.Second - time.Millisecond)
+    select {
+    case err := <-result:
+        t.Fatalf("WaitAuth returned %v before the timeout", err)
+    case <-time.After(10 * time.Millisecond):
+    }
+    fake.Advance(time.Millisecond)
+    select {
+    case err := <-result:
+        if err == nil {
+            t.Fatal("WaitAuth succeeded without an answer")
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("WaitAuth did not time out")
+    }
+}
+
+func TestPingNotUnsupported(t *testing.T) {
+    local, remote := net.Pipe()
+    defer remote.Close()
+    h := NewConnectionHandler(local)
+    h.Start()
+    defer h.Close()
+
+    // a ping then a type this client doesn't know: the only answer is the
+    // notice of the second
+    enc := json.NewEncoder(remote)
+    enc.Encode(protocol.NewMessage(protocol.TypePing, nil))
+    enc.Encode(protocol.NewMessage("from_the_future", nil))
+    remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+    var msg struct {
+        Type    protocol.MessageType        `json:"type"`
+        Payload protocol.UnsupportedPayload `json:"payload"`
+    }
+    if err := json.NewDecoder(remote).Decode(&msg); err != nil {
+        t.Fatal(err)
+    }
+    if msg.Type != protocol.TypeUnsupported || msg.Payload.Type != "from_the_future" {
+        t.Fatalf("answer %s %+v, want the unsupported notice of from_the_future", msg.Type, msg.Payload)
+    }
+}
@@ -0,0 +1,161 @@
+// internal/client/network/reconnect.go
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// reconnection backoff: 1s, 2s, 4s... up to maxReconnectDelay, the handler
+// gives up after maxReconnectAttempts
+const (
+    maxReconnectAttempts = 10
+    maxReconnectDelay    = 30 * time.Second
+)
+
+// SetReconnect makes the handler dial address again when the connection is
+// lost, instead of closing
+func (h *ConnectionHandler) SetReconnect(address string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.address = address
+}
+
+// connectionLost stops the loops of a lost connection, and reconnects or
+// closes the handler
+func (h *ConnectionHandler) connectionLost(conn net.Conn, connDone chan struct{}) {
+    close(connDone)
+    conn.Close()
+
+    select {
+    case <-h.done:
+        // closed by the user
+        return
+    default:
+    }
+
+    h.mu.Lock()
+    address := h.address
+    wasAuthenticated := h.authComplete
+    h.authComplete = false
+    h.mu.Unlock()
+
+    // without a session there is nothing to restore
+    if address == "" || !wasAuthenticated {
+        h.handleDisconnect()
+        return
+    }
+    h.reconnect(address)
+}
+
+func (h *ConnectionHandler) reconnect(address string) {
+    if dropped := h.drainSendQueue(); dropped > 0 {
+        logging.Warnf("Dropped %d messages queued on the lost connection", dropped)
+    }
+
+    h.mu.RLock()
+    username, password := h.username, h.password
+    h.mu.RUnlock()
+
+    delay := time.Second
+    for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+        h.emit(models.ConnectionState{State: models.ConnectionReconnecting, Attempt: attempt})
+
+        select {
+        case <-h.done:
+            return
+        case <-h.clock.After(delay):
+        }
+        if delay *= 2; delay > maxReconnectDelay {
+            delay = maxReconnectDelay
+        }
+
+        conn, decoder, err := h.redial(address, username, password)
+        if err != nil {
+            logging.Warnf("Reconnection attempt %d failed: %v", attempt, err)
+            continue
+        }
+
+        h.mu.Lock()
+        h.conn = conn
+        onConnect := h.onConnect
+        h.mu.Unlock()
+
+        logging.Infof("Reconnected after %d attempt(s)", attempt)
+        h.startLoops(conn, decoder)
+        h.emit(models.ConnectionState{State: models.ConnectionConnected})
+        if onConnect != nil {
+            onConnect()
+        }
+        return
+    }
+
+    logging.Errorf("Giving up reconnecting to %s", address)
+    h.handleDisconnect()
+}
+
+// redial opens a connection and authenticates on it before the loops start,
+// so a failed attempt never reaches connectionLost
+func (h *ConnectionHandler) redial(address, username, password string) (net.Conn, *json.Decoder, error) {
+    conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+    if err != nil {
+        return nil, nil, err
+    }
+    conn = wrapConn(conn)
+    conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+    auth := protocol.Message{
+        Type: protocol.TypeAuth,
+        Payload: protocol.AuthPayload{
+            Username: username,
+            Password: password,
+        },
+        Timestamp: time.Now().Unix(),
+    }
+    if err := json.NewEncoder(conn).Encode(auth); err != nil {
+        conn.Close()
+        return nil, nil, err
+    }
+
+    // the decoder goes on with the read loop, it may hold the next messages
+    decoder := json.NewDecoder(conn)
+    for {
+        var msg protocol.Message
+        if err := decoder.Decode(&msg); err != nil {
+            conn.Close()
+            return nil, nil, err
+        }
+
+        switch msg.Type {
+        case protocol.TypeAuthResponse:
+            h.handleAuthResponse(msg)
+            if !h.IsAuthenticated() {
+                conn.Close()
+                return nil, nil, h.GetAuthError()
+            }
+            conn.SetDeadline(time.Time{})
+            return conn, decoder, nil
+        case protocol.TypeError:
+            conn.Close()
+            return nil, nil, fmt.Errorf("server refused the connection")
+        }
+    }
+}
+
+// drainSendQueue drops the messages that were waiting for the lost connection
+func (h *ConnectionHandler) drainSendQueue() int {
+    dropped := 0
+    for {
+        select {
+        case <-h.sendChan:
+            dropped++
+        default:
+            return dropped
+        }
+    }
+}
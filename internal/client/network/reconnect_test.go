@@ -0,0 +1,64 @@
+// internal/client/network/reconnect_test.go
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"textual/internal/client/models"
+	"textual/internal/clock"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+    // a port nothing listens on, each attempt fails at once
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    address := ln.Addr().String()
+    ln.Close()
+
+    local, remote := net.Pipe()
+    defer remote.Close()
+    fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+    h := NewConnectionHandler(local)
+    h.SetClock(fake)
+    states := make(chan models.ConnectionState, maxReconnectAttempts+1)
+    h.SetEventHandler(func(event interface{}) {
+        if state, ok := event.(models.ConnectionState); ok {
+            states <- state
+        }
+    })
+
+    next := func() models.ConnectionState {
+        t.Helper()
+        select {
+        case state := <-states:
+            return state
+        case <-time.After(5 * time.Second):
+            t.Fatal("no connection state")
+        }
+        return models.ConnectionState{}
+    }
+
+    go h.reconnect(address)
+    delay := time.Second
+    for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+        if state := next(); state.State != models.ConnectionReconnecting || state.Attempt != attempt {
+            t.Fatalf("state %+v, want attempt %d", state, attempt)
+        }
+        fake.BlockUntil(1)
+        fake.Advance(delay - time.Millisecond)
+        select {
+        case state := <-states:
+            t.Fatalf("attempt %d: %+v before the %s delay", attempt, state, delay)
+        case <-time.After(10 * time.Millisecond):
+        }
+        fake.Advance(time.Millisecond)
+        delay = min(2*delay, maxReconnectDelay)
+    }
+    if state := next(); state.State != models.ConnectionOffline {
+        t.Fatalf("state %+v after the last attempt, want offline", state)
+    }
+}
@@ -0,0 +1,241 @@
+// internal/client/tui/admin.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// how often the Server page asks for fresh stats while shown
+const adminRefresh = 5 * time.Second
+
+// adminTickMsg asks for the stats again while the Server page is shown
+type adminTickMsg struct{}
+
+// actions of the Server page input
+const (
+    adminAnnounce = "announce"
+    adminKick     = "kick"
+)
+
+// AdminView is the Server page of the server admins: the live stats, the
+// recent moderation events and the announce and kick actions
+type AdminView struct {
+    viewport viewport.Model
+    input    textinput.Model
+    action   string // adminAnnounce or adminKick while the input is open
+    stats    models.AdminStats
+    loaded   bool
+    ticking  bool // a refresh is scheduled
+    width    int
+    height   int
+}
+
+func NewAdminView() *AdminView {
+    input := textinput.New()
+    input.CharLimit = 500
+    return &AdminView{
+        viewport: viewport.New(0, 0),
+        input:    input,
+    }
+}
+
+func (a *AdminView) Update(msg tea.Msg) tea.Cmd {
+    var cmd tea.Cmd
+    a.viewport, cmd = a.viewport.Update(msg)
+    return cmd
+}
+
+func (a *AdminView) View() string {
+    header := titleStyle.Render(i18n.T("Server"))
+    help := i18n.T("a announce • x kick a user • r refresh • j/k scroll")
+    input := ""
+    if a.input.Focused() {
+        input = a.input.View() + "\n"
+        help = i18n.T("enter confirm • esc cancel")
+    }
+    return header + "\n" + input + a.viewport.View() + "\n" + timestampStyleBase.Render(help)
+}
+
+func (a *AdminView) Resize(width, height int) {
+    a.width = width
+    a.height = height
+    a.input.Width = max(width-4, 10)
+    a.layout()
+}
+
+// layout gives the viewport the lines the input leaves
+func (a *AdminView) layout() {
+    a.viewport.Width = a.width
+    a.viewport.Height = a.height - 3 // title, its margin and the help line
+    if a.input.Focused() {
+        a.viewport.Height--
+    }
+    a.updateContent()
+}
+
+// SetStats stores the stats sent by the server
+func (a *AdminView) SetStats(stats models.AdminStats) {
+    a.stats = stats
+    a.loaded = true
+    a.updateContent()
+}
+
+// open shows the input of an action
+func (a *AdminView) open(action string) tea.Cmd {
+    a.action = action
+    a.input.Reset()
+    a.input.Placeholder = i18n.T("Announcement to every connected user...")
+    if action == adminKick {
+        a.input.Placeholder = i18n.T("Username to disconnect, then the reason...")
+    }
+    cmd := a.input.Focus()
+    a.layout()
+    return cmd
+}
+
+func (a *AdminView) close() {
+    a.action = ""
+    a.input.Blur()
+    a.layout()
+}
+
+func (a *AdminView) updateContent() {
+    if !a.loaded {
+        a.viewport.SetContent(timestampStyleBase.Render(i18n.T("Loading...")))
+        return
+    }
+    s := a.stats
+    var sb strings.Builder
+    sb.WriteString(sidebarSectionStyle.Render(i18n.T("Live stats")) + "\n")
+    sb.WriteString(i18n.T("Online users: %d (%d sessions)", s.OnlineUsers, s.Sessions) + "\n")
+    sb.WriteString(i18n.T("Messages: %d in the last minute", s.MessagesPerMinute) + "\n")
+    queues := i18n.T("Queued messages: %d, %d at most for a session", s.QueuedMessages, s.MaxQueued)
+    if s.Degraded > 0 {
+        queues += " " + noticeStyle.Render(i18n.T("%d sessions degraded", s.Degraded))
+    }
+    sb.WriteString(queues + "\n")
+
+    sb.WriteString("\n" + sidebarSectionStyle.Render(i18n.T("Moderation")) + "\n")
+    if len(s.Events) == 0 {
+        sb.WriteString(timestampStyleBase.Render(i18n.T("Nothing since the server started")) + "\n")
+    }
+    // newest first
+    for i := len(s.Events) - 1; i >= 0; i-- {
+        event := s.Events[i]
+        line := timestampStyleBase.Render(i18n.FormatTimestamp(i18n.InZone(event.At), time.Now())) + " " + moderationText(event)
+        sb.WriteString(runewidth.Truncate(line, max(a.width, 10), "…") + "\n")
+    }
+    a.viewport.SetContent(sb.String())
+}
+
+// moderationText describes a moderation event on one line
+func moderationText(event models.ModerationEvent) string {
+    switch event.Action {
+    case protocol.ModerationAnnounce:
+        return i18n.T("%s announced: %s", event.Actor, strings.ReplaceAll(event.Detail, "\n", " "))
+    case protocol.ModerationKick:
+        if event.Detail != "" {
+            return i18n.T("%s kicked %s: %s", event.Actor, event.Target, event.Detail)
+        }
+        return i18n.T("%s kicked %s", event.Actor, event.Target)
+    case protocol.ModerationGroupKick:
+        return i18n.T("%s removed %s from %s", event.Actor, event.Target, event.Detail)
+    }
+    return fmt.Sprintf("%s %s %s", event.Actor, event.Action, event.Target)
+}
+
+// tickAdmin schedules the next refresh of the Server page
+func tickAdmin() tea.Cmd {
+    return tea.Tick(adminRefresh, func(time.Time) tea.Msg {
+        return adminTickMsg{}
+    })
+}
+
+// adminStatsLoaded shows the stats, the first ones tell the user is an
+// admin and add the Server page. The refreshes go on while it is shown
+func (m *Model) adminStatsLoaded(stats models.AdminStats) tea.Cmd {
+    m.isAdmin = true
+    m.admin.SetStats(stats)
+    if m.currentPage != ServerPage || m.admin.ticking {
+        return nil
+    }
+    m.admin.ticking = true
+    return tickAdmin()
+}
+
+// refreshAdmin asks for the stats when the Server page is shown, the answer
+// schedules the next refresh
+func (m *Model) refreshAdmin() {
+    m.admin.ticking = false
+    if m.currentPage == ServerPage {
+        m.loadAdminStats()
+    }
+}
+
+func (m *Model) loadAdminStats() {
+    if m.connection == nil {
+        return
+    }
+    if err := m.connection.LoadAdminStats(); err != nil {
+        logging.Errorf("Failed to load the server stats: %v", err)
+    }
+}
+
+// handleAdminKey runs the keys of the Server page
+func (m *Model) handleAdminKey(msg tea.KeyMsg) tea.Cmd {
+    a := m.admin
+    if a.input.Focused() {
+        switch msg.String() {
+        case "enter":
+            value := strings.TrimSpace(a.input.Value())
+            if value == "" {
+                return nil
+            }
+            var err error
+            if a.action == adminKick {
+                username, reason, _ := strings.Cut(value, " ")
+                err = m.connection.KickUser(strings.TrimPrefix(username, "@"), strings.TrimSpace(reason))
+            } else {
+                err = m.connection.Announce(value)
+            }
+            if err != nil {
+                m.err = err
+                return nil
+            }
+            a.close()
+        case "esc":
+            a.close()
+        default:
+            var cmd tea.Cmd
+            a.input, cmd = a.input.Update(msg)
+            return cmd
+        }
+        return nil
+    }
+
+    switch {
+    case key.Matches(msg, adminKeys.Announce):
+        return a.open(adminAnnounce)
+    case key.Matches(msg, adminKeys.Kick):
+        return a.open(adminKick)
+    case key.Matches(msg, adminKeys.Refresh):
+        m.loadAdminStats()
+    case key.Matches(msg, adminKeys.Down):
+        a.viewport.LineDown(1)
+    case key.Matches(msg, adminKeys.Up):
+        a.viewport.LineUp(1)
+    }
+    return nil
+}
@@ -2,15 +2,20 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"sort"
 	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
 	"textual/internal/client/models"
 	"textual/internal/client/network"
+	"textual/pkg/protocol"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -25,45 +30,9 @@ const (
 	GroupsPage
 	MessagesPage
 	FriendsPage
-)
-
-var (
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.Color("#874BFD")).
-			Padding(0, 1)
-
-	tabStyle = headerStyle.
-			Background(lipgloss.Color("#383838"))
-
-	activeTabStyle = headerStyle.
-			Background(lipgloss.Color("#874BFD")).
-			Underline(true)
-
-	// messageStyle = lipgloss.NewStyle().
-	// 	PaddingLeft(2)
-
-	timestampStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#666666")).
-			Width(10)
-
-	// usernameStyle = lipgloss.NewStyle().
-	// 	Bold(true).
-	// 	Foreground(lipgloss.Color("#874BFD")).
-	// 	PaddingRight(1)
-
-	// contentStyle = lipgloss.NewStyle().
-	// 	PaddingLeft(1)
-
-	inputStyle = lipgloss.NewStyle().
-			BorderStyle(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#874BFD")).
-			Padding(0, 1)
-
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF0000")).
-			Bold(true)
+	NotificationsPage
+	DiscoverPage
+	ServerPage // admins only
 )
 
 type Model struct {
@@ -75,22 +44,64 @@ type Model struct {
 	width           int
 	height          int
 	err             error
-	onSendMessage   func(string, *string, *string) error
-	onLoadMessages  func(string, int) error
+	onSendMessage   func(content, clientID string, recipientID, groupID *string) error
 	connection      *network.ConnectionHandler
 	friendsView     *FriendsView
+	messagesView    *MessagesView
+	groupsView      *GroupsView
+	sidebar         *Sidebar
+	pane            *SidePane
+	notifications   *NotificationView
+	discover        *DiscoverView
+	admin           *AdminView
+	isAdmin         bool // the server sent its stats, the Server page is shown
+	switcher        QuickSwitcher
+	showHelp        bool
+	showDebug       bool
+	connState       string // models.Connection*, empty until the first change
+	reconnectAttempt int
+	cooldownUntil   time.Time // the global chat refuses messages until then
 	userID          string
+	username        string
 	isLoading       bool
-	hasMoreMessages bool
-}
-
-type MessagesLoadedMsg struct {
-	Messages []models.Message
+	prefetching     bool // a history page requested in the background
+	noMoreHistory   map[string]bool
+	historyLoaded   map[string]bool
+	unread          map[string]int
+	mentions        map[string]int
+	completer       MentionCompleter
+	commands        CommandCompleter
+	selection       messageSelection
+	pendingJump     string // message to select once the messages around it arrive
+	editingID       string
+	typing          *typingTracker
+	config          config.Config
+	displayPrefs    models.DisplayPrefs // shared by the devices of the user, over the config
+	focused         bool // terminal focus, reported when the program enables it
+	firstUnread     map[string]string // chat ID -> first message received while away
+	dividerChat     string            // chat showing its "new messages" divider
+	drafts          *draftStore
+	status          string
+	statusText      string
+	statusExpires   time.Time // zero keeps the status text until changed
+	lastInput       time.Time // last key or click, for the idle detection
+	idleAway        bool      // set away by the idle detection
+	draftChat       string // chat whose draft is in the input
+	notice          string
+	export          *pendingExport // /export waiting for the history
+	paste           *pendingPaste  // multi-line paste waiting for y/n
+	recording       *recording     // voice message being recorded
+	playing         string         // voice message /play is downloading
+	thread          *threadView    // thread open over the conversation
+	threadUnread    map[string]int // thread root ID -> replies not read
+	reminders       []models.Reminder // last /remind list, for /remind cancel
+	history         *historyCache     // bounds m.messages, with the history files
+	index           *messageIndex     // of m.messages, shared with the messages view
 }
 
-func NewModel(onSendMessage func(string, *string, *string) error) Model {
+func NewModel(onSendMessage func(content, clientID string, recipientID, groupID *string) error) Model {
     input := textinput.New()
-    input.Placeholder = "Type a message..."
+    input.Placeholder = i18n.T("Type a message...")
     input.Focus()
     input.CharLimit = 1000
  
@@ -102,19 +113,46 @@ func NewModel(onSendMessage func(string, *string, *string) error) Model {
         height = 24
     }
  
-    vp := viewport.New(width, height-4)
+    sidebar := NewSidebar()
+    sidebar.Resize(width, height-1)
+    mainWidth := width - sidebar.Width()
+
+    vp := viewport.New(mainWidth, height-5)
     vp.SetContent("")
  
-    input.Width = width - 8
+    input.Width = mainWidth - 8
  
+    messagesView := NewMessagesView()
+    messagesView.Resize(mainWidth, height-5)
+    index := newMessageIndex()
+    messagesView.index = index
+
     return Model{
         viewport:        vp,
         input:          input,
         currentPage:    GlobalPage,
         messages:       make(map[string][]models.Message),
+        index:          index,
         selectedChat:   "global",
         onSendMessage:  onSendMessage,
-        hasMoreMessages: true,
+        messagesView:   messagesView,
+        sidebar:        sidebar,
+        pane:           NewSidePane(),
+        notifications:  NewNotificationView(),
+        discover:       NewDiscoverView(),
+        admin:          NewAdminView(),
+        switcher:       NewQuickSwitcher(),
+        noMoreHistory:  make(map[string]bool),
+        historyLoaded:  make(map[string]bool),
+        unread:         make(map[string]int),
+        mentions:       make(map[string]int),
+        threadUnread:   make(map[string]int),
+        typing:         newTypingTracker(),
+        config:         config.Default(),
+        focused:        true,
+        lastInput:      time.Now(),
+        status:         models.StatusOnline,
+        firstUnread:    make(map[string]string),
         width:          width,
         height:         height,
     }
@@ -125,20 +163,55 @@ func (m *Model) SetConnectionHandler(handler *network.ConnectionHandler) {
 	// Réinitialiser la vue amis si elle existe
 	if m.friendsView != nil {
 		m.friendsView = NewFriendsView(handler)
-		m.friendsView.onStartChat = func(friendID string) {
-			m.currentPage = MessagesPage
-			m.selectedChat = friendID
-			m.updateContent()
-		}
+	}
+}
+
+// SetConfig sets the client preferences loaded at startup
+func (m *Model) SetConfig(cfg config.Config) {
+	m.config = cfg
+	if m.groupsView != nil {
+		m.groupsView.pageSize = cfg.History.Page()
 	}
 }
 
 func (m *Model) SetConnection(handler *network.ConnectionHandler) {
 	m.connection = handler
+	m.userID = handler.UserID()
+	m.username = handler.Username()
+	m.statusText, m.statusExpires = handler.StatusText()
+
+	m.index.SetUserID(m.userID)
+	m.messagesView.SetSelf(m.userID)
+	m.groupsView = NewGroupsView(handler)
+	m.groupsView.SetUserID(m.userID)
+	m.groupsView.SetUsername(m.username)
+	m.groupsView.SetNameLookup(m.messagesView.LookupName)
+	m.groupsView.pageSize = m.config.History.Page()
+	m.groupsView.typing = m.typing
+	m.groupsView.firstUnread = m.firstUnread
+	m.groupsView.threadUnread = m.threadUnread
+
+	m.drafts = loadDraftStore(m.userID)
+	m.groupsView.drafts = m.drafts
+	m.history = newHistoryCache(m.userID, m.messages, m.groupsView.messages, m.noMoreHistory, m.historyLoaded, m.groupsView.historyLoaded)
+	m.groupsView.history = m.history
+	m.history.Open(m.selectedChat)
+	m.syncDraft()
+	m.groupsView.Resize(m.mainWidth(), m.viewport.Height)
+
+	// groups are listed in the sidebar from the start
+	if err := handler.LoadGroups(); err != nil {
+		logging.Errorf("Failed to load groups: %v", err)
+	} else {
+		m.groupsView.loading = true
+	}
+	m.loadNotifications()
+	m.loadNotificationLevels()
+	m.shareNotificationLevels()
 }
 
 func (m Model) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, tickRelative(), tickIdle(), tickHistorySave())
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -147,197 +220,665 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
-			return m, tea.Quit
-
-		case "tab":
-			oldPage := m.currentPage
-			m.currentPage = (m.currentPage + 1) % 4
-			// if m.currentPage == GlobalPage {
-			//     m.selectedChat = "global"
-			// }
-			switch m.currentPage {
-			case GlobalPage:
-				m.selectedChat = "global"
-				m.input.Focus()
-				if m.friendsView != nil {
-					m.friendsView.Blur()
+		m.active()
+		if m.switcher.Active() {
+			conv, ok, cmd := m.switcher.HandleKey(msg, m.switcherCandidates())
+			if ok {
+				if conv.Kind == directConversation {
+					m.messagesView.AddContact(conv.ID, conv.Name)
 				}
+				m.switchConversation(conv)
+			}
+			return m, cmd
+		}
 
-			case GroupsPage:
-				m.input.Blur()
-				// TODO: handle groups view
+		// any key closes the help
+		if m.showHelp {
+			m.showHelp = false
+			return m, nil
+		}
+		// "?" is typed in the input once there is text in it
+		if key.Matches(msg, globalKeys.Help) && (msg.Type != tea.KeyRunes || m.helpAvailable()) {
+			m.showHelp = true
+			return m, nil
+		}
 
-			case MessagesPage:
-				if m.selectedChat != "" {
-					m.input.Focus()
-				} else {
-					m.input.Blur()
-				}
+		if m.paste != nil {
+			return m, m.handlePasteKey(msg.String())
+		}
+		// a pasted snippet is sent whole, once the user chose its format
+		if isMultilinePaste(msg) && m.activeConversation() != "" && m.editingID == "" && !m.selection.active {
+			m.startPaste(msg)
+			return m, nil
+		}
 
-			case FriendsPage:
-				m.input.Blur()
-				if m.friendsView == nil && m.connection != nil {
-					m.friendsView = NewFriendsView(m.connection)
-					m.friendsView.onStartChat = func(friendID string) {
-						m.currentPage = MessagesPage
-						m.selectedChat = friendID
-						m.input.Focus()
-						m.updateContent()
-					}
-				}
-				if m.friendsView != nil {
-					m.friendsView.Focus()
-				}
+		if m.thread != nil {
+			if handled, cmd := m.handleThreadKey(msg); handled {
+				return m, cmd
 			}
+		}
 
-			if oldPage == FriendsPage {
-				if m.friendsView != nil {
-					m.friendsView.Blur()
-				}
+		if m.selection.active {
+			if handled, cmd := m.handleSelectionKey(msg); handled {
+				return m, cmd
+			}
+		}
+
+		if m.commands.Active() && m.input.Focused() && m.commands.HandleKey(msg.String(), &m.input) {
+			return m, nil
+		}
+
+		if m.completer.Active() && m.input.Focused() && m.completer.HandleKey(msg.String(), &m.input) {
+			return m, nil
+		}
+
+		// up in an empty input edits the last message sent in the chat
+		if key.Matches(msg, chatKeys.EditLast) && m.input.Focused() && m.input.Value() == "" && m.editingID == "" {
+			if last, ok := lastOwnMessage(m.messages[m.selectedChat], m.userID); ok {
+				m.startEditing(last)
+				return m, nil
+			}
+		}
+
+		switch {
+		case key.Matches(msg, globalKeys.Quit):
+			m.saveDrafts()
+			m.history.Save()
+			if m.recording != nil {
+				m.voiceCommand("cancel")
 			}
+			return m, tea.Quit
+
+		case key.Matches(msg, globalKeys.OpenLink):
+			m.openLastLink()
+			return m, nil
 
+		case key.Matches(msg, globalKeys.MarkAllRead):
+			m.notifyMarkedRead(m.markAllRead())
 			m.updateContent()
+			return m, nil
+
+		case key.Matches(msg, globalKeys.JumpUnread):
+			if line, ok := m.dividerLine(); ok {
+				m.viewport.SetYOffset(line)
+			} else {
+				m.notice = i18n.T("No new messages in this conversation")
+			}
+			return m, nil
+
+		case key.Matches(msg, globalKeys.Switcher):
+			return m, m.switcher.Open(m.switcherCandidates())
+
+		case key.Matches(msg, globalKeys.SidePane):
+			m.togglePane()
+			return m, nil
+
+		case key.Matches(msg, globalKeys.PaneUp, globalKeys.PaneDown):
+			delta := m.pane.viewport.Height / 2
+			if key.Matches(msg, globalKeys.PaneUp) {
+				delta = -delta
+			}
+			m.pane.Scroll(delta)
+			return m, nil
+
+		case key.Matches(msg, globalKeys.NextConversation, globalKeys.PrevConversation):
+			delta := 1
+			if key.Matches(msg, globalKeys.PrevConversation) {
+				delta = -1
+			}
+			if conv, ok := m.sidebar.Move(m.conversations(), delta); ok {
+				m.switchConversation(conv)
+			}
+			return m, nil
+
+		case key.Matches(msg, globalKeys.NextPage):
+			// the group creation form uses tab to switch fields
+			if m.currentPage == GroupsPage && m.groupsView != nil && m.groupsView.mode == GroupCreateMode {
+				return m, m.groupsView.Update(msg)
+			}
+
+			m.setPage((m.currentPage + 1) % (m.lastPage() + 1))
+
+		case key.Matches(msg, chatKeys.Send):
+            if m.currentPage == DiscoverPage {
+                return m, m.handleDiscoverKey(msg)
+            }
+
+            if m.currentPage == ServerPage {
+                return m, m.handleAdminKey(msg)
+            }
+
+            if m.currentPage == NotificationsPage {
+                if m.notifications.ShowingMentions() {
+                    m.openMention()
+                } else {
+                    m.openNotification()
+                }
+                return m, nil
+            }
 
-		case "enter":
             if m.currentPage == FriendsPage && m.friendsView != nil {
-                var cmd tea.Cmd
-                m.friendsView.Update(msg)
+                _, cmd := m.friendsView.Update(msg)
+                return m, cmd
+            }
+
+            if m.currentPage == GroupsPage && m.groupsView != nil {
+                cmd := m.groupsView.Update(msg)
+                if groupID := m.groupsView.ActiveGroup(); groupID != "" {
+                    m.markRead(groupID)
+                    m.sidebar.Select(groupID)
+                    m.loadPaneMembers()
+                }
                 return m, cmd
             }
 
-            // if m.currentPage == GroupsPage && m.groupsView != nil {
-            //     var cmd tea.Cmd
-            //     m.groupsView.Update(msg)
-            //     return m, cmd
-            // }
+            if m.currentPage == MessagesPage && m.selectedChat == "" {
+                if friendID, ok := m.messagesView.SelectedContact(); ok {
+                    m.openConversation(friendID)
+                }
+                return m, nil
+            }
 
             if m.input.Value() != "" && m.onSendMessage != nil {
                 content := m.input.Value()
-                var err error
-
-                switch m.currentPage {
-                case GlobalPage:
-                    err = m.onSendMessage(content, nil, nil)
-                case MessagesPage:
-                    if m.selectedChat != "" {
-                        recipientID := m.selectedChat
-                        err = m.onSendMessage(content, &recipientID, nil)
+                if m.offline() && !strings.HasPrefix(content, "/") {
+                    m.err = errors.New(i18n.T("offline: the message stays in the input until the connection is back"))
+                    return m, nil
+                }
+                if m.editingID != "" {
+                    if err := m.connection.EditMessage(m.editingID, content); err != nil {
+                        m.err = err
+                        logging.Errorf("Error editing message: %v", err)
                     }
+                    m.cancelEdit()
+                    return m, nil
                 }
 
-                if err != nil {
-                    m.err = err
-                    log.Printf("Error sending message: %v", err)
-                } else {
+                if ok, cmd := m.runCommand(content); ok {
                     m.input.Reset()
-                    m.viewport.GotoBottom()
+                    m.commands.Dismiss()
+                    m.updateContent()
+                    return m, cmd
+                }
+                // the text waits in the input for the end of the countdown
+                if m.currentPage == GlobalPage && m.coolingDown() {
+                    return m, nil
+                }
+                // "//" sends a message starting with "/"
+                if strings.HasPrefix(content, "//") {
+                    content = content[1:]
+                }
+
+                // the message shows up right away, marked pending until the server echoes it
+                var recipientID *string
+                if m.currentPage == MessagesPage {
+                    if m.selectedChat == "" {
+                        return m, nil
+                    }
+                    chat := m.selectedChat
+                    recipientID = &chat
+                }
+                cmd := m.sendPending(newPendingMessage(m.userID, m.username, content, recipientID, nil))
+
+                m.input.Reset()
+                // answering means the new messages were read
+                if m.dividerChat == m.selectedChat {
+                    m.clearDivider()
+                }
+                m.updateContent()
+                m.viewport.GotoBottom()
+                return m, cmd
+            }
+
+        case key.Matches(msg, chatKeys.Select):
+            if m.currentPage == DiscoverPage {
+                return m, m.handleDiscoverKey(msg)
+            }
+
+            if m.currentPage == ServerPage {
+                return m, m.handleAdminKey(msg)
+            }
+
+            if m.currentPage == GroupsPage && m.groupsView != nil {
+                return m, m.groupsView.Update(msg)
+            }
+
+            if m.currentPage == FriendsPage && m.friendsView != nil {
+                _, cmd := m.friendsView.Update(msg)
+                return m, cmd
+            }
+
+            if m.editingID != "" {
+                m.cancelEdit()
+                return m, nil
+            }
+
+            // esc leaves the input for the selection mode
+            if m.currentPage == GlobalPage || (m.currentPage == MessagesPage && m.selectedChat != "") {
+                if m.selection.Start(m.messages[m.selectedChat]) {
+                    m.input.Blur()
+                    m.updateContent()
+                    m.scrollToSelection()
+                } else if m.currentPage == MessagesPage {
+                    m.closeConversation()
                 }
                 return m, nil
             }
 
         default:
+            if m.currentPage == NotificationsPage {
+                m.handleNotificationKey(msg)
+                return m, nil
+            }
+
+            if m.currentPage == DiscoverPage {
+                return m, m.handleDiscoverKey(msg)
+            }
+
+            if m.currentPage == ServerPage {
+                return m, m.handleAdminKey(msg)
+            }
+
             if m.currentPage == FriendsPage && m.friendsView != nil {
-                var cmd tea.Cmd
-                m.friendsView.Update(msg)
+                _, cmd := m.friendsView.Update(msg)
                 return m, cmd
             }
 
-            // if m.currentPage == GroupsPage && m.groupsView != nil {
-            //     var cmd tea.Cmd
-            //     m.groupsView.Update(msg)
-            //     return m, cmd
-            // }
+            if m.currentPage == MessagesPage && m.selectedChat == "" {
+                return m, m.messagesView.Update(msg)
+            }
+
+            if m.currentPage == GroupsPage && m.groupsView != nil {
+                return m, m.groupsView.Update(msg)
+            }
 		}
 
 	case tea.MouseMsg:
-		if msg.Type == tea.MouseWheelUp {
-			if m.viewport.YOffset == 0 && !m.isLoading && m.hasMoreMessages {
-				m.isLoading = true
-				if m.onLoadMessages != nil && len(m.messages[m.selectedChat]) > 0 {
-					firstMsg := m.messages[m.selectedChat][0]
-					if err := m.onLoadMessages(firstMsg.ID, 50); err != nil {
-						log.Printf("Failed to load more messages: %v", err)
-						m.isLoading = false
-					}
-				}
-			}
+		if msg.Action == tea.MouseActionPress {
+			m.active()
+		}
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			m.handleClick(msg.X, msg.Y)
+			return m, nil
+		}
+		// the wheel over the side pane scrolls it, not the chat
+		if m.pane.Contains(m.width, msg.X) {
+			return m, m.pane.Update(msg)
+		}
+		if m.currentPage == NotificationsPage {
+			return m, m.notifications.Update(msg)
+		}
+		if m.currentPage == DiscoverPage {
+			return m, m.discover.Update(msg)
+		}
+		if m.currentPage == ServerPage {
+			return m, m.admin.Update(msg)
+		}
+		if msg.Type == tea.MouseWheelUp && m.viewport.YOffset == 0 {
+			m.loadOlder(false)
 		}
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-
-		headerHeight := 1
-		inputHeight := 3
-		verticalMargin := headerHeight + inputHeight + 1
-
-		m.viewport.Width = msg.Width
-		m.viewport.Height = msg.Height - verticalMargin
-		m.input.Width = msg.Width - 8
-
-		if m.friendsView != nil {
-			m.friendsView.resize()
-		}
-
+		m.resize()
 		m.updateContent()
 
 	case models.MessageReceived:
-		log.Printf("Received message in TUI: %+v", msg.Message)
+		logging.Debugf("Received message in TUI: %+v", msg.Message)
+		if msg.Message.ThreadRootID != nil {
+			m.receiveReply(msg.Message)
+			break
+		}
 		chatID := m.getChatID(msg.Message)
-		if m.messages[chatID] == nil {
-			m.messages[chatID] = make([]models.Message, 0)
+		m.storeMessages(chatID, msg.Message)
+		m.typing.Remove(chatID, msg.Message.SenderID)
+		m.notifyMessage(chatID, msg.Message)
+		if msg.Message.IsGroup() && m.groupsView != nil {
+			m.groupsView.Update(msg)
+		}
+		if msg.Message.IsDirect() {
+			m.messagesView.AddContact(chatID, m.partnerName(msg.Message))
+		}
+
+		if !m.isViewing(chatID) && msg.Message.SenderID != m.userID {
+			// a muted conversation gets no badge
+			if m.config.Notifications.Level(chatID) != config.LevelNone {
+				m.unread[chatID]++
+				if mentionsUser(msg.Message.Content, m.username) {
+					m.mentions[chatID]++
+				}
+			}
+			if _, ok := m.firstUnread[chatID]; !ok && msg.Message.ID != "" {
+				m.firstUnread[chatID] = msg.Message.ID
+			}
+		} else if msg.Message.SenderID != m.userID {
+			// read here, the other devices of the user count it as unread
+			m.sendReadMarker(chatID)
 		}
-		m.messages[chatID] = append(m.messages[chatID], msg.Message)
 
 		if chatID == m.selectedChat {
 			m.updateContent()
 			m.viewport.GotoBottom()
+		} else if m.currentPage == MessagesPage && m.selectedChat == "" {
+			m.messagesView.Refresh(m.messages, m.unread)
+			m.updateContent()
 		}
 
-	case MessagesLoadedMsg:
+	case models.MessageEdited:
+		m.updateMessage(msg.Message)
+
+	case models.LinkPreviewReceived:
+		m.updateMessage(msg.Message)
+
+	case models.StatusUpdate:
+		m.updatePresence(msg)
+
+	case models.HistoryLoaded:
 		m.isLoading = false
-		if len(msg.Messages) > 0 {
-			chatID := m.selectedChat
+		m.prefetching = false
+		m.applyHistory(msg)
+
+	case models.ContextLoaded:
+		m.applyContext(msg)
 
-			// slice for all messages
-			allMessages := make([]models.Message, 0, len(msg.Messages)+len(m.messages[chatID]))
+	case models.TypingUpdate:
+		if msg.UserID == m.userID {
+			break
+		}
+		chatID := "global"
+		if msg.GroupID != "" {
+			chatID = msg.GroupID
+		} else if msg.RecipientID != "" {
+			chatID = msg.UserID
+		}
+		cmds = append(cmds, m.typing.Add(chatID, msg.UserID, msg.Username))
 
-			// add older messages first
-			allMessages = append(allMessages, msg.Messages...)
+	case tea.FocusMsg:
+		m.focused = true
 
-			// add newer messages
-			allMessages = append(allMessages, m.messages[chatID]...)
+	case tea.BlurMsg:
+		m.focused = false
 
-			// update messages
-			m.messages[chatID] = allMessages
+	case typingExpiredMsg:
+		m.typing.Expire()
 
-			// update content
-			m.updateContent()
-		} else {
-			m.hasMoreMessages = false
+	case idleTickMsg:
+		cmds = append(cmds, m.checkIdle())
+
+	case relativeTickMsg:
+		m.updateContent()
+		cmds = append(cmds, tickRelative())
+
+	case historySaveMsg:
+		m.history.Save()
+		cmds = append(cmds, tickHistorySave())
+
+	case commandMsg:
+		if _, cmd := m.runCommand(msg.input); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+
+	case pendingSendMsg:
+		cmds = append(cmds, m.sendPending(msg.message))
+
+	case retryMsg:
+		cmds = append(cmds, m.retry(msg.message))
+
+	case sendTimeoutMsg:
+		if pending, ok := m.pendingMessage(msg.clientID); ok && pending.SendState == models.SendPending {
+			m.setSendState(msg.clientID, models.SendFailed)
+		}
+
+	case groupLevelMsg:
+		m.cycleGroupLevel(msg.groupID)
+
+	case startChatMsg:
+		m.messagesView.AddContact(msg.friend.ID, msg.friend.Username)
+		m.switchConversation(conversation{ID: msg.friend.ID, Kind: directConversation, Name: msg.friend.Username})
+
+	case models.MessageStatusChanged:
+		m.setMessageStatus(msg.ChatID, msg.MessageID, msg.Status)
+
+	case models.ReadMarker:
+		// the chat was read on another device of the user
+		delete(m.unread, msg.ChatID)
+		delete(m.mentions, msg.ChatID)
+		if msg.ChatID != m.dividerChat {
+			delete(m.firstUnread, msg.ChatID)
+		}
+		if m.currentPage == MessagesPage && m.selectedChat == "" {
+			m.messagesView.Refresh(m.messages, m.unread)
+		}
+		m.updateContent()
+
+	case models.FriendRemoved:
+		if m.friendsView != nil {
+			m.friendsView.RemoveFriend(msg.UserID, msg.Blocked)
+		}
+
+	case models.FriendListUpdated:
+		if m.friendsView != nil {
+			m.friendsView.SetFriends(msg.Friends)
+		}
+		m.messagesView.SetFriends(msg.Friends)
+		m.refreshContacts()
+
+	case models.FriendRequestReceived:
+		if m.friendsView != nil {
+			m.friendsView.AddPendingRequest(msg.Request)
+		}
+
+	case models.FriendRequestResponded:
+		if m.friendsView != nil {
+			m.friendsView.RequestResponded(msg)
+		}
+
+	case models.GroupsLoaded:
+		if m.groupsView != nil {
+			m.groupsView.SetGroups(msg.Groups)
+		}
+
+	case models.Synced:
+		m.applySync(msg)
+
+	case models.DisplayPrefsChanged:
+		m.applyDisplayPrefs(msg.Prefs)
+		m.notice = i18n.T("Display preferences saved on all your devices")
+
+	case models.GroupCreated:
+		if m.groupsView != nil {
+			m.groupsView.AddGroup(msg.Group)
+		}
+
+	case models.GroupTopicChanged:
+		if m.groupsView != nil {
+			m.groupsView.SetTopic(msg.GroupID, msg.Topic)
+		}
+
+	case models.GroupJoined:
+		m.discover.GroupJoined(msg.Group)
+		if m.groupsView != nil {
+			m.groupsView.GroupJoined(msg.Group)
+			if groupID := m.groupsView.ActiveGroup(); groupID != "" && m.currentPage == GroupsPage {
+				m.sidebar.Select(groupID)
+			}
+		}
+		m.notice = i18n.T("You joined %s", msg.Group.Name)
+
+	case models.GroupDirectoryLoaded:
+		if m.groupsView != nil {
+			m.groupsView.SetDirectory(msg.Groups)
+		}
+		m.discover.SetGroups(msg.Groups)
+
+	case models.UsersFound:
+		m.discover.SetUsers(msg.Query, msg.Users)
+
+	case models.AdminStatsLoaded:
+		return m, m.adminStatsLoaded(msg.Stats)
+
+	case adminTickMsg:
+		m.refreshAdmin()
+
+	case models.Unsupported:
+		m.err = errors.New(unsupportedError(msg.Type))
+
+	case models.Disconnected:
+		m.err = errors.New(i18n.T("You were disconnected by a moderator"))
+		if msg.Reason != "" {
+			m.err = errors.New(i18n.T("You were disconnected by a moderator: %s", msg.Reason))
+		}
+
+	case models.GroupInviteReceived:
+		if m.groupsView != nil {
+			m.groupsView.AddGroup(msg.Group)
+		}
+		m.notice = fmt.Sprintf("%s added you to %s", msg.FromUser, msg.Group.Name)
+
+	case models.GroupMembersLoaded:
+		if m.groupsView != nil {
+			m.groupsView.SetMembers(msg.GroupID, msg.Members)
+		}
+
+	case models.GroupRemoved:
+		m.notice = i18n.T("You were removed from %s", m.groupName(msg.GroupID))
+		if msg.Left {
+			m.notice = i18n.T("You left %s", m.groupName(msg.GroupID))
+		}
+		delete(m.unread, msg.GroupID)
+		delete(m.mentions, msg.GroupID)
+		if m.groupsView != nil {
+			m.groupsView.RemoveGroup(msg.GroupID)
 		}
 
-		// Scroll to the last message
-		if m.viewport.YOffset > 0 {
-			m.viewport.SetYOffset(m.viewport.YOffset)
+	case models.SystemMessageReceived:
+		// only shown in the group chat, the history and the badges ignore it
+		if m.groupsView != nil {
+			m.groupsView.AddMessage(msg.Message)
+			if m.groupsView.ActiveGroup() == *msg.Message.GroupID {
+				m.groupsView.viewport.GotoBottom()
+			}
 		}
 
+	case models.ThreadLoaded:
+		m.threadLoaded(msg)
+
+	case openThreadMsg:
+		m.openThread(msg.root)
+
+	case models.AttachmentDownloaded:
+		m.attachmentDownloaded(msg)
+
 	case models.ErrorMsg:
+		if msg.RetryAfter > 0 {
+			// the countdown above the input replaces the error
+			cmds = append(cmds, m.startCooldown(msg.RetryAfter))
+			break
+		}
 		m.err = fmt.Errorf("%s", msg.Error)
-		log.Printf("Error received: %v", m.err)
+		logging.Errorf("Error received: %v", m.err)
+
+	case cooldownTickMsg:
+		cmds = append(cmds, m.updateCooldown())
+
+	case models.ConnectionState:
+		if msg.State == models.ConnectionConnected && m.offline() {
+			m.notice = i18n.T("Reconnected")
+			m.err = nil
+			// notifications may have arrived while offline
+			m.loadNotifications()
+			m.loadNotificationLevels()
+		}
+		m.connState = msg.State
+		m.reconnectAttempt = msg.Attempt
+		// the server sets online a session that logs in again
+		if msg.State == models.ConnectionConnected && m.idleAway {
+			m.sendPresence(models.StatusAway)
+		}
+		if m.groupsView != nil {
+			m.groupsView.offline = m.offline()
+		}
+
+	case models.ServerNotice:
+		m.notice = msg.Message
+		if msg.Kind == protocol.NoticeAnnouncement {
+			m.notice = i18n.T("📢 %s: %s", msg.Actor, msg.Message)
+		}
+		if msg.Kind == protocol.NoticeRestored {
+			m.err = nil
+		}
+		m.notifications.AddNotification(models.Notification{
+			Kind:      msg.Kind,
+			Content:   msg.Message,
+			Actor:     msg.Actor,
+			CreatedAt: time.Now(),
+		})
+
+	case models.NotificationReceived:
+		m.notifications.AddNotification(msg.Notification)
+		if msg.Notification.Kind == protocol.NoticeMention && m.notifications.ShowingMentions() {
+			m.loadMentions()
+		}
+
+	case models.NotificationsLoaded:
+		m.notifications.SetNotifications(msg.Notifications)
+
+	case models.MentionsLoaded:
+		m.notifications.SetMentions(msg.Mentions)
+
+	case models.NotificationLevelsLoaded:
+		m.adoptNotificationLevels(msg.Levels)
+
+	case models.ReminderCreated:
+		m.reminderCreated(msg.Reminder)
+
+	case models.RemindersLoaded:
+		m.remindersLoaded(msg.Reminders)
+
+	case models.MessageRevisionsLoaded:
+		m.revisionsLoaded(msg)
+
+	case models.KeysChanged:
+		name := msg.Username
+		if name == "" {
+			name = m.messagesView.ContactName(msg.UserID)
+		}
+		m.notice = i18n.T("⚠ The encryption keys of %s changed, compare them with /verify", name)
 	}
 
 	// Update viewport
 	var cmd tea.Cmd
+	offset := m.viewport.YOffset
 	m.viewport, cmd = m.viewport.Update(msg)
 	cmds = append(cmds, cmd)
+	// the previous page arrives before the user reaches the top
+	if m.config.History.Prefetch && m.viewport.YOffset < offset && m.viewport.YOffset < m.viewport.Height {
+		m.loadOlder(true)
+	}
 
 	// Update input
+	before := m.input.Value()
 	m.input, cmd = m.input.Update(msg)
 	cmds = append(cmds, cmd)
 
+	if value := m.input.Value(); value != before && value != "" && !strings.HasPrefix(value, "/") {
+		m.sendTyping()
+	}
+
+	if _, ok := msg.(tea.KeyMsg); ok && m.input.Focused() {
+		m.completer.Refresh(m.input, m.mentionCandidates())
+		m.commands.Refresh(m.input)
+	}
+
+	// the divider goes away once the user scrolled past it
+	if line, ok := m.dividerLine(); ok && line < m.viewport.YOffset {
+		offset := m.viewport.YOffset
+		m.clearDivider()
+		m.viewport.SetContent(m.renderMessages(m.messages[m.selectedChat]))
+		m.viewport.SetYOffset(offset - 1)
+	}
+
 	return m, tea.Batch(cmds...)
 }
 
@@ -351,74 +892,358 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) View() string {
     var sb strings.Builder
 
-    sb.WriteString(m.renderHeader())
-    sb.WriteString("\n")
+    if m.notice != "" {
+        sb.WriteString(noticeStyle.Render(m.notice))
+        sb.WriteString("\n")
+    }
 
     if m.err != nil {
         sb.WriteString(errorStyle.Render(m.err.Error()))
         sb.WriteString("\n")
     }
 
-    switch m.currentPage {
-    case FriendsPage:
+    switch {
+    case m.thread != nil:
+        sb.WriteString(m.threadContent())
+    case m.currentPage == FriendsPage:
         if m.friendsView != nil {
             sb.WriteString(m.friendsView.View())
         }
+    case m.currentPage == NotificationsPage:
+        sb.WriteString(m.notifications.View())
+    case m.currentPage == DiscoverPage:
+        sb.WriteString(m.discover.View())
+    case m.currentPage == ServerPage:
+        sb.WriteString(m.admin.View())
+    case m.currentPage == GroupsPage:
+        if m.groupsView != nil {
+            sb.WriteString(m.groupsView.View())
+        }
+    case m.currentPage == MessagesPage:
+        if m.selectedChat == "" {
+            sb.WriteString(m.messagesView.View())
+            break
+        }
+        sb.WriteString(m.messagesView.Header())
+        if m.connection != nil && m.connection.Encrypted(m.selectedChat) {
+            sb.WriteString(" " + successStyle.Render(i18n.T("🔒 encrypted")))
+        }
+        sb.WriteString("\n")
+        sb.WriteString(m.viewport.View())
+        sb.WriteString("\n")
+        sb.WriteString(m.renderTyping())
+        sb.WriteString(m.renderInput())
     default:
         sb.WriteString(m.viewport.View())
         sb.WriteString("\n")
-        sb.WriteString(inputStyle.Render(m.input.View()))
+        sb.WriteString(m.renderTyping())
+        sb.WriteString(m.renderInput())
     }
 
-    return sb.String()
-}
-
-func (m *Model) updateContent() {
-    var content string
-    switch m.currentPage {
-    case GlobalPage:
-        content = m.renderMessages(m.messages["global"])
-    case GroupsPage:
-        content = "group page (TODO)"
-    case MessagesPage:
-        // if m.selectedChat != "" {
-        //     content = m.renderMessages(m.messages[m.selectedChat])
-        // } else {
-        //     content = "Select a friend to start a conversation"
-        // }
-		content = "private message (TODO)"
-    case FriendsPage:
-        if m.friendsView != nil {
-            content = m.friendsView.View()
-        }
+    content := sb.String()
+    if m.showDebug {
+        content += "\n" + m.debugView(m.mainWidth())
     }
-
-    m.viewport.SetContent(content)
-    if m.currentPage == GlobalPage || m.currentPage == MessagesPage {
-        m.viewport.GotoBottom()
+    if m.switcher.Active() {
+        content = m.switcherView(m.mainWidth(), m.height-2)
+    }
+    if m.showHelp {
+        content = m.helpView(m.mainWidth(), m.height-2)
+    }
+    if pane := m.paneView(); pane != "" {
+        // the pane stays against the right edge whatever the content width
+        content = lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.PlaceHorizontal(m.mainWidth(), lipgloss.Left, content), pane)
+    }
+    convs := m.conversations()
+    if m.quiet() {
+        convs = withoutBadges(convs)
+    }
+    if sidebar := m.sidebar.View(convs, m.activeConversation()); sidebar != "" {
+        content = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, content)
     }
-}
-
-var (
-	timestampStyleBase = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#666666"))
 
-	usernameStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#874BFD")).
-			PaddingRight(1).
-			Width(15).
-			Align(lipgloss.Left)
+    return m.renderHeader() + "\n" + content
+}
 
-	contentStyle = lipgloss.NewStyle().
-			PaddingLeft(1)
-)
+// setPage shows a page, tab goes through them in order
+func (m *Model) setPage(page Page) {
+	oldPage := m.currentPage
+	m.currentPage = page
+	switch m.currentPage {
+	case GlobalPage:
+		m.selectedChat = "global"
+		m.history.Open("global")
+		m.markRead("global")
+		m.input.Focus()
+		if m.friendsView != nil {
+			m.friendsView.Blur()
+		}
+
+	case GroupsPage:
+		m.input.Blur()
+		if m.groupsView != nil {
+			m.groupsView.Focus()
+			if len(m.groupsView.groups) == 0 && !m.groupsView.loading {
+				if err := m.connection.LoadGroups(); err != nil {
+					logging.Errorf("Failed to load groups: %v", err)
+				} else {
+					m.groupsView.loading = true
+				}
+			}
+		}
+
+	case MessagesPage:
+		m.selectedChat = m.messagesView.ActiveChat()
+		if m.selectedChat != "" {
+			m.history.Open(m.selectedChat)
+			m.input.Focus()
+			m.markRead(m.selectedChat)
+		} else {
+			m.input.Blur()
+			m.messagesView.Refresh(m.messages, m.unread)
+		}
+
+	case FriendsPage:
+		m.input.Blur()
+		if m.friendsView == nil && m.connection != nil {
+			m.friendsView = NewFriendsView(m.connection)
+		}
+		if m.friendsView != nil {
+			m.friendsView.Focus()
+		}
+
+	case NotificationsPage:
+		m.input.Blur()
+
+	case DiscoverPage:
+		// the online users change, the page is loaded again each time
+		m.input.Blur()
+		m.loadDiscover()
+
+	case ServerPage:
+		m.input.Blur()
+		m.loadAdminStats()
+	}
+
+	if oldPage == FriendsPage {
+		if m.friendsView != nil {
+			m.friendsView.Blur()
+		}
+	}
+	if oldPage == GroupsPage && m.groupsView != nil {
+		m.groupsView.Blur()
+	}
+
+	m.sidebar.Select(m.activeConversation())
+	m.updateContent()
+}
+
+// resize lays the views out for the terminal size
+func (m *Model) resize() {
+	headerHeight := 1
+	inputHeight := 3
+	typingHeight := 1
+	verticalMargin := headerHeight + inputHeight + typingHeight + 1
+	if m.showDebug {
+		verticalMargin += debugPaneHeight + 2
+	}
+
+	m.sidebar.Resize(m.width, m.height-headerHeight)
+	m.pane.Resize(m.width, m.height-headerHeight)
+	mainWidth := m.mainWidth()
+
+	m.viewport.Width = mainWidth
+	m.viewport.Height = m.height - verticalMargin
+	m.input.Width = mainWidth - 8
+
+	if m.friendsView != nil {
+		m.friendsView.resize()
+	}
+	m.messagesView.Resize(mainWidth, m.viewport.Height)
+	m.notifications.Resize(mainWidth, m.height-headerHeight-2)
+	m.discover.Resize(mainWidth, m.height-headerHeight-2)
+	m.admin.Resize(mainWidth, m.height-headerHeight-2)
+	if m.groupsView != nil {
+		m.groupsView.Resize(mainWidth, m.viewport.Height)
+	}
+	m.resizeThread()
+}
+
+// mainWidth returns the columns left to the page between the sidebar and the
+// side pane
+func (m Model) mainWidth() int {
+	return m.width - m.sidebar.Width() - m.pane.Width()
+}
+
+// renderTyping draws the line telling who is typing in the open chat
+func (m Model) renderTyping() string {
+    return editedStyle.Render(m.typing.Indicator(m.selectedChat)) + "\n"
+}
+
+// renderInput draws the input box with the mention suggestions above it
+func (m Model) renderInput() string {
+    if m.selection.active {
+        return selectionHelp()
+    }
+    input := inputStyle.Render(m.input.View())
+    if m.offline() {
+        // the input keeps its text but nothing can be sent
+        input = editedStyle.Render(i18n.T("Offline, sending is paused until the connection is back")) + "\n" +
+            offlineInputStyle.Render(m.input.Prompt+m.input.Value())
+    }
+    if m.currentPage == GlobalPage && m.coolingDown() && !m.offline() && m.editingID == "" {
+        input = editedStyle.Render(i18n.T("Slow down: you can send again in %ds", m.cooldownLeft())) + "\n" +
+            offlineInputStyle.Render(m.input.Prompt+m.input.Value())
+    }
+    if m.editingID != "" {
+        input = editedStyle.Render(i18n.T("Editing message • enter to save • esc to cancel")) + "\n" + input
+    }
+    if suggestions := m.commands.View(); suggestions != "" {
+        return suggestions + "\n" + input
+    }
+    if suggestions := m.completer.View(); suggestions != "" {
+        return suggestions + "\n" + input
+    }
+    return input
+}
+
+func (m *Model) updateContent() {
+    var content string
+    switch m.currentPage {
+    case GlobalPage:
+        content = m.renderMessages(m.messages["global"])
+    case GroupsPage:
+        if m.groupsView != nil {
+            m.groupsView.updateContent()
+        }
+    case MessagesPage:
+        if m.selectedChat != "" {
+            content = m.renderMessages(m.messages[m.selectedChat])
+        } else {
+            content = m.messagesView.View()
+        }
+    case FriendsPage:
+        if m.friendsView != nil {
+            content = m.friendsView.View()
+        }
+    }
+
+    m.viewport.SetContent(content)
+    if !m.selection.active && (m.currentPage == GlobalPage || m.currentPage == MessagesPage) {
+        m.viewport.GotoBottom()
+    }
+    m.trackDivider()
+    m.syncDraft()
+}
+
+// syncDraft keeps the input content per chat when the chat on screen changes
+func (m *Model) syncDraft() {
+    if m.drafts == nil {
+        return
+    }
+
+    chatID := ""
+    if m.currentPage == GlobalPage || (m.currentPage == MessagesPage && m.selectedChat != "") {
+        chatID = m.selectedChat
+    }
+    if chatID == m.draftChat {
+        return
+    }
+
+    if m.editingID != "" {
+        m.cancelEdit()
+    }
+    m.input.SetValue(m.drafts.Swap(m.draftChat, chatID, m.input.Value()))
+    m.input.CursorEnd()
+    m.draftChat = chatID
+    m.drafts.Save()
+}
+
+// saveDrafts writes the inputs being typed before quitting
+func (m *Model) saveDrafts() {
+    if m.drafts == nil {
+        return
+    }
+    if m.draftChat != "" && m.editingID == "" {
+        m.drafts.Swap(m.draftChat, m.draftChat, m.input.Value())
+    }
+    if m.groupsView != nil {
+        m.groupsView.saveDraft()
+    }
+    m.drafts.Save()
+}
+
+// trackDivider drops the divider of the chat the user left, and shows the
+// first new message when opening a chat with more unread messages than fit
+func (m *Model) trackDivider() {
+    chatID := m.activeConversation()
+    if chatID == m.dividerChat {
+        return
+    }
+
+    if m.dividerChat != "" {
+        m.clearDivider()
+    }
+    if _, ok := m.firstUnread[chatID]; !ok {
+        return
+    }
+
+    m.dividerChat = chatID
+    if line, ok := m.dividerLine(); ok && line < m.viewport.YOffset {
+        m.viewport.SetYOffset(line)
+    }
+}
+
+func (m *Model) clearDivider() {
+    delete(m.firstUnread, m.dividerChat)
+    m.dividerChat = ""
+}
+
+// dividerLine returns the viewport line of the "new messages" divider of the open chat
+func (m Model) dividerLine() (int, bool) {
+    if m.dividerChat == "" || m.dividerChat != m.selectedChat {
+        return 0, false
+    }
+    line, ok := m.messageLine(m.firstUnread[m.dividerChat])
+    return line - 1, ok
+}
+
+// messageLine returns the viewport line where a message of the open chat
+// starts, messageID is a messageKey
+func (m Model) messageLine(messageID string) (int, bool) {
+    line := 0
+    if m.isLoading {
+        line++
+    }
+
+    firstUnread := m.firstUnread[m.selectedChat]
+    for _, msg := range m.messages[m.selectedChat] {
+        if msg.ID != "" && msg.ID == firstUnread && m.dividerChat == m.selectedChat {
+            line++
+        }
+        if messageKey(msg) == messageID {
+            return line, true
+        }
+        line += strings.Count(msg.Content, "\n") + 1
+    }
+    return 0, false
+}
+
+// renderDivider draws the line above the first unread message
+func renderDivider(width int) string {
+    label := i18n.T(" New messages ")
+    side := (width - lipgloss.Width(label)) / 2
+    if side < 2 {
+        side = 2
+    }
+    return unreadDividerStyle.Render(strings.Repeat("─", side) + label + strings.Repeat("─", side))
+}
 
 func (m Model) renderMessages(messages []models.Message) string {
 	var sb strings.Builder
 
 	if m.isLoading {
-		sb.WriteString("Loading more messages...\n")
+		sb.WriteString(i18n.T("Loading more messages...\n"))
 	}
 
 	sortedMessages := make([]models.Message, len(messages))
@@ -431,83 +1256,968 @@ func (m Model) renderMessages(messages []models.Message) string {
 		return sortedMessages[i].SentAt.Before(sortedMessages[j].SentAt)
 	})
 
+	firstUnread := m.firstUnread[m.selectedChat]
+	var prev time.Time
 	for _, msg := range sortedMessages {
-		timestamp := m.formatTimestamp(msg.SentAt.Local()) // convert to local time
+		sb.WriteString(daySeparator(prev, msg.SentAt, m.viewport.Width))
+		prev = msg.SentAt
+		if msg.ID != "" && msg.ID == firstUnread && m.dividerChat == m.selectedChat {
+			sb.WriteString(renderDivider(m.viewport.Width) + "\n")
+		}
+
+		timestamp := messageTime(msg.SentAt, true)
 		senderName := msg.SenderName
 
 		timestampStyle := timestampStyleBase
-		if len(timestamp) > 8 {
+		if textWidth(timestamp) > 8 {
 			timestampStyle = timestampStyle.Width(20)
 		} else {
 			timestampStyle = timestampStyle.Width(10)
 		}
 
 		timeStr := timestampStyle.Render(timestamp)
-		nameStr := usernameStyle.Render(senderName)
-		contentStr := contentStyle.Render(msg.Content)
+		nameStr := renderSender(msg.SenderID, senderName)
+		textStyle := lipgloss.NewStyle()
+		if msg.SenderID != m.userID && mentionsUser(msg.Content, m.username) {
+			textStyle = mentionStyle
+		}
+		content := renderContent(msg, textStyle)
+		if msg.IsEdited() {
+			content += editedStyle.Render(" (edited)")
+		}
+		content = renderSendState(msg, content)
+		if msg.SenderID == m.userID {
+			content += renderStatus(msg)
+		}
 
-		line := fmt.Sprintf("%s%s%s\n", timeStr, nameStr, contentStr)
+		prefix := timeStr + nameStr + " "
+		indent := textWidth(prefix)
+		if m.selection.active {
+			indent++ // selection marker
+		}
+		line := prefix + wrapMessage(content, m.viewport.Width, indent) + "\n"
+		line += renderLinkPreview(msg, m.viewport.Width, indent)
+		if replies := renderReplies(msg, m.threadUnread[msg.ID]); replies != "" {
+			line += strings.Repeat(" ", indent) + replies + "\n"
+		}
+		if m.selection.IsSelected(msg) {
+			line = selectionMarkerStyle.Render("▌") + line
+		} else if m.selection.active {
+			line = " " + line
+		}
 		sb.WriteString(line)
 	}
 	return sb.String()
 }
 
-func (m Model) formatTimestamp(t time.Time) string {
-	now := time.Now()
-	if t.Year() == now.Year() && t.Month() == now.Month() && t.Day() == now.Day() {
-		return t.Format("15:04:05")
-	} else if t.Year() == now.Year() {
-		return fmt.Sprintf("[%s %s]", t.Format("02/01"), t.Format("15:04:05"))
-	}
-	return fmt.Sprintf("[%s %s]", t.Format("02/01/06"), t.Format("15:04:05"))
-}
-
-func (m Model) renderHeader() string {
+// tabNames returns the label of each page tab with its counters
+func (m Model) tabNames() []string {
     tabNames := []string{
-        "Global",
-        "Groups",
-        "Messages",
-        "Friends",
+        i18n.T("Global"),
+        i18n.T("Groups"),
+        i18n.T("Messages"),
+        i18n.T("Friends"),
+        i18n.T("Notifications"),
+        i18n.T("Discover"),
+    }
+    if m.isAdmin {
+        tabNames = append(tabNames, i18n.T("Server"))
     }
 
-    var renderedTabs []string
+    // counts are kept and shown again once out of do not disturb
+    if m.quiet() {
+        return tabNames
+    }
     for i, name := range tabNames {
-        style := tabStyle
-        if Page(i) == m.currentPage {
-            style = activeTabStyle
+        // count unread direct messages
+        if Page(i) == MessagesPage {
+            if count := m.unreadDirectMessages(); count > 0 {
+                name = fmt.Sprintf("%s (%d)", name, count)
+            }
         }
-        
+
         // count pending friend requests
         if Page(i) == FriendsPage && m.friendsView != nil && len(m.friendsView.pendingRequests) > 0 {
             name = fmt.Sprintf("%s +%d", name, len(m.friendsView.pendingRequests))
         }
-        
+
+        // count unread notifications
+        if Page(i) == NotificationsPage {
+            if count := m.notifications.Unread(); count > 0 {
+                name = fmt.Sprintf("%s (%d)", name, count)
+            }
+        }
+        tabNames[i] = name
+    }
+    return tabNames
+}
+
+// lastPage is the last page tab goes through, the Server page is only
+// shown to the admins
+func (m Model) lastPage() Page {
+    if m.isAdmin {
+        return ServerPage
+    }
+    return DiscoverPage
+}
+
+// tabAt returns the page whose tab is drawn at the column x of the header
+func (m Model) tabAt(x int) (Page, bool) {
+    left := 0
+    for i, name := range m.tabNames() {
+        width := lipgloss.Width(tabStyle.Render(name))
+        if x >= left && x < left+width {
+            return Page(i), true
+        }
+        left += width
+    }
+    return 0, false
+}
+
+func (m Model) renderHeader() string {
+    var renderedTabs []string
+    for i, name := range m.tabNames() {
+        style := tabStyle
+        if Page(i) == m.currentPage {
+            style = activeTabStyle
+        }
         renderedTabs = append(renderedTabs, style.Render(name))
     }
 
+    // mentions are counted apart from the unread messages
+    mentions := 0
+    for _, n := range m.mentions {
+        mentions += n
+    }
+    if mentions > 0 && !m.quiet() {
+        renderedTabs = append(renderedTabs, mentionBadgeStyle.Render(fmt.Sprintf(" @%d ", mentions)))
+    }
+
+    // own presence, set with /online, /away, /dnd and /status
+    presence := fmt.Sprintf("%s %s", statusIcon(m.status), i18n.T(m.status))
+    if text := m.activeStatusText(); text != "" {
+        presence += " · " + text
+    }
+    renderedTabs = append(renderedTabs, tabStyle.Render(presence))
+    renderedTabs = append(renderedTabs, m.connectionIndicator())
+
     return lipgloss.JoinHorizontal(lipgloss.Top, renderedTabs...)
 }
 
+// offline tells if the connection to the server is lost
+func (m Model) offline() bool {
+    return m.connState == models.ConnectionReconnecting || m.connState == models.ConnectionOffline
+}
+
+func (m Model) connectionIndicator() string {
+    switch m.connState {
+    case models.ConnectionReconnecting:
+        return tabStyle.Foreground(currentTheme.Warning).Render(i18n.T("◌ reconnecting (attempt %d)", m.reconnectAttempt))
+    case models.ConnectionOffline:
+        return tabStyle.Foreground(currentTheme.Error).Render(i18n.T("○ offline"))
+    }
+    return tabStyle.Foreground(currentTheme.Success).Render(i18n.T("● connected"))
+}
+
 func (m Model) getChatID(msg models.Message) string {
 	if msg.GroupID != nil {
 		return *msg.GroupID
 	}
 	if msg.RecipientID != nil {
+		// a direct message belongs to the conversation with the other user
+		if *msg.RecipientID == m.userID {
+			return msg.SenderID
+		}
 		return *msg.RecipientID
 	}
 	return "global"
 }
 
+// partnerName returns the username of the other user of a direct message, if known
+func (m Model) partnerName(msg models.Message) string {
+	if msg.SenderID != m.userID {
+		return msg.SenderName
+	}
+	return ""
+}
+
+// storeMessages adds messages to a chat, skipping the ones already known and
+// keeping the chat sorted from oldest to newest
+func (m *Model) storeMessages(chatID string, messages ...models.Message) {
+	m.messages[chatID] = mergeMessages(m.messages[chatID], messages...)
+	m.history.Stored(chatID)
+}
+
+// mergeMessages adds messages to a chat, replacing the pending copies and
+// skipping the messages already in it, and sorts it from oldest to newest
+func mergeMessages(chat []models.Message, messages ...models.Message) []models.Message {
+	for _, msg := range messages {
+		chat = replacePending(chat, msg)
+		duplicate := false
+		if msg.ID != "" {
+			for _, existing := range chat {
+				if existing.ID == msg.ID {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if !duplicate {
+			chat = append(chat, msg)
+		}
+	}
+
+	sort.SliceStable(chat, func(i, j int) bool {
+		return chat[i].SentAt.Before(chat[j].SentAt)
+	})
+	return chat
+}
+
+// updateMessage shows the new content, edit time and link preview of a
+// stored message wherever it is displayed
+func (m *Model) updateMessage(msg models.Message) {
+	m.editThreadMessage(msg)
+	chatID := m.getChatID(msg)
+	if i, ok := m.index.Position(chatID, m.messages[chatID], msg.ID); ok {
+		m.messages[chatID][i].Content = msg.Content
+		m.messages[chatID][i].EditedAt = msg.EditedAt
+		m.messages[chatID][i].Preview = msg.Preview
+		m.index.Invalidate(chatID)
+		m.history.Stored(chatID)
+	}
+	if msg.IsGroup() && m.groupsView != nil {
+		m.groupsView.UpdateMessage(msg)
+	}
+	if chatID == m.selectedChat {
+		m.updateContent()
+	}
+}
+
+// isViewing reports whether the chat is currently on screen
+func (m Model) isViewing(chatID string) bool {
+	switch m.currentPage {
+	case GlobalPage:
+		return chatID == "global"
+	case MessagesPage:
+		return chatID == m.selectedChat
+	case GroupsPage:
+		return m.groupsView != nil && chatID == m.groupsView.ActiveGroup()
+	}
+	return false
+}
+
+// handleSelectionKey runs the selection mode actions, it returns false when
+// the key must go through the normal handling
+func (m *Model) handleSelectionKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	chat := m.messages[m.selectedChat]
+
+	switch {
+	case key.Matches(msg, globalKeys.Quit, globalKeys.NextPage, globalKeys.Switcher, globalKeys.NextConversation, globalKeys.PrevConversation):
+		m.stopSelection()
+		return false, nil
+
+	case key.Matches(msg, selectionKeys.Down):
+		m.selection.Move(chat, 1)
+	case key.Matches(msg, selectionKeys.Up):
+		m.selection.Move(chat, -1)
+	case key.Matches(msg, selectionKeys.First):
+		m.selection.Move(chat, -len(chat))
+	case key.Matches(msg, selectionKeys.Last):
+		m.selection.Move(chat, len(chat))
+
+	case key.Matches(msg, selectionKeys.OpenLink):
+		if selected, ok := m.selection.Selected(chat); ok {
+			url, err := openMessageLink(selected)
+			if err != nil {
+				m.notice = err.Error()
+			} else {
+				m.notice = i18n.T("Opening %s", url)
+			}
+		}
+
+	case key.Matches(msg, selectionKeys.Copy, selectionKeys.CopyFull):
+		if selected, ok := m.selection.Selected(chat); ok {
+			if err := copyMessage(selected, key.Matches(msg, selectionKeys.CopyFull)); err != nil {
+				m.err = err
+			} else {
+				m.notice = i18n.T("Message copied to the clipboard")
+			}
+		}
+
+	case key.Matches(msg, selectionKeys.Link):
+		if selected, ok := m.selection.Selected(chat); ok {
+			if err := copyMessageLink(selected); err != nil {
+				m.err = err
+			} else {
+				m.notice = i18n.T("Link copied to the clipboard, /goto opens it")
+			}
+		}
+
+	case key.Matches(msg, selectionKeys.Edit):
+		if selected, ok := m.selection.Selected(chat); ok {
+			if selected.SenderID != m.userID {
+				m.notice = i18n.T("You can only edit your own messages")
+				break
+			}
+			m.stopSelection()
+			m.startEditing(selected)
+			m.updateContent()
+			return true, nil
+		}
+
+	case key.Matches(msg, selectionKeys.Retry):
+		if selected, ok := m.selection.Selected(chat); ok && selected.SendState == models.SendFailed {
+			return true, m.retry(selected)
+		}
+
+	case key.Matches(msg, selectionKeys.Thread):
+		if selected, ok := m.selection.Selected(chat); ok {
+			m.openThread(selected)
+			return true, nil
+		}
+
+	case key.Matches(msg, selectionKeys.History):
+		if selected, ok := m.selection.Selected(chat); ok {
+			if err := requestRevisions(m.connection, selected); err != nil {
+				m.notice = err.Error()
+			}
+		}
+
+	case key.Matches(msg, selectionKeys.Input):
+		m.stopSelection()
+		m.updateContent()
+		return true, nil
+
+	case key.Matches(msg, selectionKeys.Leave):
+		m.stopSelection()
+		if m.currentPage == MessagesPage {
+			m.closeConversation()
+		} else {
+			m.updateContent()
+		}
+		return true, nil
+	}
+
+	m.updateContent()
+	m.scrollToSelection()
+	return true, nil
+}
+
+// sendTyping announces to the open chat that the user is typing
+func (m *Model) sendTyping() {
+	if m.connection == nil || m.selectedChat == "" || m.editingID != "" {
+		return
+	}
+	if !m.typing.ShouldSend(m.selectedChat) {
+		return
+	}
+
+	recipientID := ""
+	if m.selectedChat != "global" {
+		recipientID = m.selectedChat
+	}
+	if err := m.connection.SendTyping(recipientID, ""); err != nil {
+		logging.Warnf("Failed to send typing notification: %v", err)
+	}
+}
+
+// startEditing loads one of the user's messages in the input
+func (m *Model) startEditing(msg models.Message) {
+	m.editingID = msg.ID
+	m.input.SetValue(msg.Content)
+	m.input.CursorEnd()
+	m.input.Focus()
+}
+
+func (m *Model) cancelEdit() {
+	m.editingID = ""
+	m.input.Reset()
+}
+
+func (m *Model) stopSelection() {
+	m.selection.Stop()
+	m.input.Focus()
+}
+
+// scrollToSelection keeps the selected message inside the viewport
+func (m *Model) scrollToSelection() {
+	selected, ok := m.selection.Selected(m.messages[m.selectedChat])
+	if !ok {
+		return
+	}
+	line, ok := m.messageLine(messageKey(selected))
+	if !ok {
+		return
+	}
+
+	if line < m.viewport.YOffset {
+		m.viewport.SetYOffset(line)
+	} else if line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line - m.viewport.Height + 1)
+	}
+}
+
+// jumpToMessage selects a message of the open chat, it returns false when
+// the message is not loaded
+func (m *Model) jumpToMessage(messageID string) bool {
+	if m.currentPage == GroupsPage {
+		return m.groupsView != nil && m.groupsView.SelectMessage(messageID)
+	}
+	for _, msg := range m.messages[m.selectedChat] {
+		if msg.ID == messageID {
+			m.selection.Select(messageID)
+			m.input.Blur()
+			m.updateContent()
+			m.scrollToSelection()
+			return true
+		}
+	}
+	return false
+}
+
+// closeConversation goes back to the list of private conversations
+func (m *Model) closeConversation() {
+	m.messagesView.CloseChat()
+	m.selectedChat = ""
+	m.input.Blur()
+	m.messagesView.Refresh(m.messages, m.unread)
+	m.updateContent()
+}
+
+// openLastLink opens the most recent link posted in the chat on screen
+func (m *Model) openLastLink() {
+	chat := m.messages[m.activeConversation()]
+	for i := len(chat) - 1; i >= 0; i-- {
+		links := findLinks(chat[i].Content)
+		if len(links) == 0 {
+			continue
+		}
+		m.openLink(links[len(links)-1])
+		return
+	}
+	m.notice = i18n.T("No link in this conversation")
+}
+
+func (m *Model) openLink(url string) {
+	if err := openURL(url); err != nil {
+		m.err = err
+		return
+	}
+	m.notice = i18n.T("Opening %s", url)
+}
+
+// markRead clears the unread and mention counters of a chat, on the other
+// devices of the user too
+func (m *Model) markRead(chatID string) {
+	if m.unread[chatID] > 0 || m.mentions[chatID] > 0 {
+		m.sendReadMarker(chatID)
+	}
+	delete(m.unread, chatID)
+	delete(m.mentions, chatID)
+}
+
+// markAllRead clears the unread counts of every conversation, the other
+// devices and the senders of the direct messages are told. It returns how
+// many conversations were unread
+func (m *Model) markAllRead() int {
+	chats := make(map[string]bool)
+	for chatID := range m.unread {
+		chats[chatID] = true
+	}
+	for chatID := range m.mentions {
+		chats[chatID] = true
+	}
+	for chatID := range chats {
+		m.markRead(chatID)
+		// the divider of the chat on screen stays until it is left
+		if chatID != m.dividerChat {
+			delete(m.firstUnread, chatID)
+		}
+	}
+	if m.currentPage == MessagesPage && m.selectedChat == "" {
+		m.messagesView.Refresh(m.messages, m.unread)
+	}
+	return len(chats)
+}
+
+// markReadCommand runs /markread: the conversation on screen, or all of
+// them with "all"
+func (m *Model) markReadCommand(args string) {
+	switch strings.TrimSpace(args) {
+	case "all":
+		m.notifyMarkedRead(m.markAllRead())
+	case "":
+		chatID := m.activeConversation()
+		if chatID == "" {
+			m.notice = i18n.T("Open a conversation first")
+			return
+		}
+		// sent even without a count, another device may have one
+		if m.unread[chatID] == 0 && m.mentions[chatID] == 0 {
+			m.sendReadMarker(chatID)
+		}
+		m.markRead(chatID)
+		m.notice = i18n.T("Conversation marked as read")
+	default:
+		m.err = errors.New(i18n.T("usage: /markread [all]"))
+	}
+}
+
+func (m *Model) notifyMarkedRead(count int) {
+	if count == 0 {
+		m.notice = i18n.T("Nothing unread")
+		return
+	}
+	m.notice = i18n.T("%d conversations marked as read", count)
+}
+
+// sendReadMarker tells the other devices of the user that a chat was read up
+// to its last message
+func (m *Model) sendReadMarker(chatID string) {
+	if m.connection == nil {
+		return
+	}
+	var last string
+	if chat := m.messages[chatID]; len(chat) > 0 {
+		last = chat[len(chat)-1].ID
+	}
+	if err := m.connection.SendReadMarker(chatID, last); err != nil {
+		logging.Warnf("Failed to send read marker: %v", err)
+	}
+}
+
+// mentionCandidates returns the usernames suggested after "@" in the input
+func (m Model) mentionCandidates() []string {
+	var names []string
+	for id := range m.messagesView.contacts {
+		names = append(names, m.messagesView.ContactName(id))
+	}
+	for _, msg := range m.messages[m.selectedChat] {
+		names = append(names, msg.SenderName)
+	}
+	return mentionCandidates(names, m.username)
+}
+
+func (m Model) unreadDirectMessages() int {
+	count := 0
+	for chatID, n := range m.unread {
+		if _, ok := m.messagesView.contacts[chatID]; ok {
+			count += n
+		}
+	}
+	return count
+}
+
+// openConversation shows the direct messages with a friend, loading the
+// history the first time
+func (m *Model) openConversation(friendID string) {
+	m.messagesView.SetActiveChat(friendID)
+	m.selectedChat = friendID
+	m.sidebar.Select(friendID)
+	m.history.Open(friendID)
+	m.markRead(friendID)
+	m.input.Focus()
+
+	if !m.historyLoaded[friendID] {
+		if err := m.loadHistory(friendID, ""); err != nil {
+			logging.Errorf("Failed to load conversation: %v", err)
+		} else {
+			m.historyLoaded[friendID] = true
+		}
+	}
+
+	m.updateContent()
+}
+
+// activeConversation returns the ID of the chat on screen, or "" when a list is shown
+func (m Model) activeConversation() string {
+	switch m.currentPage {
+	case GlobalPage:
+		return "global"
+	case MessagesPage:
+		return m.selectedChat
+	case GroupsPage:
+		if m.groupsView != nil {
+			return m.groupsView.ActiveGroup()
+		}
+	}
+	return ""
+}
+
+// conversations lists the global chat, the groups and the direct messages
+// shown in the sidebar
+func (m Model) conversations() []conversation {
+	convs := []conversation{{
+		ID:      "global",
+		Kind:    globalConversation,
+		Name:    "global",
+		Unread:   m.unread["global"],
+		Mentions: m.mentions["global"],
+		Preview:  m.lastMessagePreview("global"),
+	}}
+
+	if m.groupsView != nil {
+		groups := make([]conversation, 0, len(m.groupsView.groups))
+		for _, group := range m.groupsView.groups {
+			groups = append(groups, conversation{
+				ID:      group.ID,
+				Kind:    groupConversation,
+				Name:    group.Name,
+				Unread:   m.unread[group.ID],
+				Mentions: m.mentions[group.ID],
+				Preview:  m.lastMessagePreview(group.ID),
+			})
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name)
+		})
+		convs = append(convs, groups...)
+	}
+
+	direct := make([]conversation, 0, len(m.messagesView.contacts))
+	for id := range m.messagesView.contacts {
+		direct = append(direct, conversation{
+			ID:      id,
+			Kind:    directConversation,
+			Name:    m.messagesView.ContactName(id),
+			Unread:   m.unread[id],
+			Mentions: m.mentions[id],
+			Preview:  m.lastMessagePreview(id),
+		})
+	}
+	// the notes, then the most recent conversations first
+	sort.Slice(direct, func(i, j int) bool {
+		if ni, nj := m.messagesView.IsNotes(direct[i].ID), m.messagesView.IsNotes(direct[j].ID); ni != nj {
+			return ni
+		}
+		ti, tj := m.lastActivity(direct[i].ID), m.lastActivity(direct[j].ID)
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return strings.ToLower(direct[i].Name) < strings.ToLower(direct[j].Name)
+	})
+
+	return append(convs, direct...)
+}
+
+func (m Model) lastActivity(chatID string) time.Time {
+	last, ok := m.index.Last(chatID, m.messages[chatID])
+	if !ok {
+		return time.Time{}
+	}
+	return last.SentAt
+}
+
+func (m Model) lastMessagePreview(chatID string) string {
+	last, ok := m.index.Last(chatID, m.messages[chatID])
+	if !ok {
+		return ""
+	}
+	sender := last.SenderName
+	if last.SenderID == m.userID {
+		sender = i18n.T("You")
+	}
+	return fmt.Sprintf("%s: %s", sender, last.Content)
+}
+
+// switchConversation shows the chat selected in the sidebar, whatever the current page
+func (m *Model) switchConversation(conv conversation) {
+	m.pendingJump = ""
+	if m.editingID != "" {
+		m.cancelEdit()
+	}
+	if m.friendsView != nil {
+		m.friendsView.Blur()
+	}
+	if m.groupsView != nil && conv.Kind != groupConversation {
+		m.groupsView.Blur()
+	}
+
+	switch conv.Kind {
+	case globalConversation:
+		m.currentPage = GlobalPage
+		m.selectedChat = "global"
+		m.history.Open("global")
+		m.markRead("global")
+		m.input.Focus()
+
+	case groupConversation:
+		if m.groupsView == nil {
+			return
+		}
+		m.currentPage = GroupsPage
+		m.input.Blur()
+		m.groupsView.Focus()
+		m.groupsView.OpenGroup(conv.ID)
+		m.markRead(conv.ID)
+		m.loadPaneMembers()
+
+	case directConversation:
+		m.currentPage = MessagesPage
+		m.openConversation(conv.ID)
+		return
+	}
+
+	m.updateContent()
+}
+
+// applyHistory stores a page of messages of a chat, and redraws it when it
+// is on screen
+func (m *Model) applyHistory(msg models.HistoryLoaded) {
+	chatID := "global"
+	if msg.GroupID != "" {
+		chatID = msg.GroupID
+	} else if msg.RecipientID != "" {
+		chatID = msg.RecipientID
+	}
+
+	// the message on top before the page, the reading position follows it
+	var firstKey string
+	var firstLine int
+	if chatID == m.selectedChat && msg.BeforeID != "" && len(m.messages[chatID]) > 0 {
+		firstKey = messageKey(m.messages[chatID][0])
+		firstLine, _ = m.messageLine(firstKey)
+	}
+
+	if len(msg.Messages) > 0 {
+		m.storeMessages(chatID, msg.Messages...)
+		if msg.GroupID != "" && m.groupsView != nil {
+			for _, message := range msg.Messages {
+				m.groupsView.AddMessage(message)
+			}
+		}
+		for _, message := range msg.Messages {
+			if message.IsDirect() {
+				m.messagesView.AddContact(m.getChatID(message), m.partnerName(message))
+			}
+		}
+	} else if msg.BeforeID != "" {
+		m.noMoreHistory[chatID] = true
+	}
+
+	if chatID == m.selectedChat {
+		offset := m.viewport.YOffset
+		m.updateContent()
+		// keep the reading position when older messages were prepended
+		if line, ok := m.messageLine(firstKey); ok && firstKey != "" {
+			m.viewport.SetYOffset(offset + line - firstLine)
+		}
+	}
+	if m.export != nil && m.export.chatID == chatID {
+		m.continueExport()
+	}
+}
+
+// loadHistory requests the page of messages of a chat sent before beforeID
+func (m *Model) loadHistory(chatID, beforeID string) error {
+	if m.connection == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	size := m.config.History.Page()
+	if chatID == "global" {
+		return m.connection.LoadMessages(beforeID, size)
+	}
+	if m.isGroup(chatID) {
+		return m.connection.LoadConversation("", chatID, beforeID, size)
+	}
+	return m.connection.LoadConversation(chatID, "", beforeID, size)
+}
+
+// loadOlder requests the page before the oldest message of the open chat. A
+// prefetch goes without the loading line, it shows if the user reaches the
+// top before the page arrives
+func (m *Model) loadOlder(prefetch bool) {
+	if m.selectedChat == "" || m.noMoreHistory[m.selectedChat] || m.isLoading {
+		return
+	}
+	if m.prefetching {
+		m.isLoading = !prefetch
+		return
+	}
+	chat := m.messages[m.selectedChat]
+	if len(chat) == 0 {
+		return
+	}
+	if err := m.loadHistory(m.selectedChat, chat[0].ID); err != nil {
+		logging.Errorf("Failed to load more messages: %v", err)
+		return
+	}
+	if prefetch {
+		m.prefetching = true
+	} else {
+		m.isLoading = true
+	}
+}
+
+// isGroup reports whether the chat is one of the user's groups
+func (m Model) isGroup(chatID string) bool {
+	if m.groupsView == nil {
+		return false
+	}
+	for _, group := range m.groupsView.groups {
+		if group.ID == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// switchTheme applies a theme preset and saves it in the config file
+func (m *Model) switchTheme(args []string) {
+	if len(args) == 0 {
+		m.notice = i18n.T("Current theme: %s (available: %s)", CurrentTheme().Name, strings.Join(ThemeNames(), ", "))
+		return
+	}
+
+	cfg := m.config
+	cfg.Theme = args[0]
+
+	theme, err := ThemeFromConfig(cfg)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	ApplyTheme(theme)
+	m.messagesView.list.Styles.Title = titleStyle
+	if m.groupsView != nil {
+		m.groupsView.list.Styles.Title = titleStyle
+	}
+	m.updateContent()
+
+	m.config = cfg
+	m.saveConfig()
+	m.err = nil
+	m.notice = i18n.T("Theme switched to %s", theme.Name)
+}
+
+// setStatus changes the user's presence and custom text, "" clears the text
+// and a non-zero expires clears it at that time
+func (m *Model) setStatus(status, text string, expires time.Time) {
+	if m.connection == nil {
+		return
+	}
+	if err := m.connection.SendStatus(status, text, expires); err != nil {
+		m.err = err
+		return
+	}
+
+	m.status = status
+	m.statusText = text
+	m.statusExpires = expires
+	if text != "" && !expires.IsZero() {
+		m.notice = i18n.T("Status set to %s (%s) until %s", i18n.T(status), text, i18n.FormatTime(expires))
+	} else if text != "" {
+		m.notice = i18n.T("Status set to %s (%s)", i18n.T(status), text)
+	} else {
+		m.notice = i18n.T("Status set to %s", i18n.T(status))
+	}
+}
+
+// setNotificationLevel changes the notification level of the chat on screen,
+// without a level it shows the current one
+func (m *Model) setNotificationLevel(level string) {
+	chatID := m.activeConversation()
+	if chatID == "" {
+		m.notice = i18n.T("Open a conversation first")
+		return
+	}
+	if level == "" {
+		m.notice = i18n.T("Notifications of this conversation: %s", i18n.T(m.config.Notifications.Level(chatID)))
+		return
+	}
+	if !config.ValidLevel(level) {
+		m.err = fmt.Errorf("%s", i18n.T("Unknown level %s, use all, mentions, badge or none", level))
+		return
+	}
+	m.applyNotificationLevel(chatID, level)
+}
+
+// applyNotificationLevel saves the notification level of a conversation, on
+// the server too when the levels are synced
+func (m *Model) applyNotificationLevel(chatID, level string) {
+	m.config.Notifications.SetLevel(chatID, level)
+	m.saveConfig()
+	if m.config.Notifications.Sync {
+		m.sendNotificationLevels(map[string]string{chatID: level})
+	}
+	m.shareNotificationLevels()
+	switch level {
+	case config.LevelNone:
+		m.notice = i18n.T("Notifications muted for this conversation")
+	case config.LevelBadge:
+		m.notice = i18n.T("Only the unread badge for this conversation")
+	case config.LevelMentions:
+		m.notice = i18n.T("Only the mentions notify in this conversation")
+	default:
+		m.notice = i18n.T("Notifications enabled for this conversation")
+	}
+}
+
+// cycleGroupLevel moves a group to the next notification level, after
+// "none" it notifies everything again
+func (m *Model) cycleGroupLevel(groupID string) {
+	current := m.config.Notifications.Level(groupID)
+	next := config.Levels[0]
+	for i, level := range config.Levels {
+		if level == current && i+1 < len(config.Levels) {
+			next = config.Levels[i+1]
+		}
+	}
+	m.applyNotificationLevel(groupID, next)
+}
+
+func (m *Model) saveConfig() {
+	if err := config.Save(m.config); err != nil {
+		logging.Errorf("Failed to save config: %v", err)
+	}
+}
+
+// notifyMessage alerts the user of a message they can't see: a direct
+// message, a mention, or any message of a group notifying everything
+func (m *Model) notifyMessage(chatID string, msg models.Message) {
+	if msg.SenderID == m.userID || m.status == models.StatusDND {
+		return
+	}
+	level := m.config.Notifications.Level(chatID)
+	if level != config.LevelAll && level != config.LevelMentions {
+		return
+	}
+	if m.isViewing(chatID) && m.focused {
+		return
+	}
+	if !msg.IsDirect() && !(msg.IsGroup() && level == config.LevelAll) && !mentionsUser(msg.Content, m.username) {
+		return
+	}
+
+	title := msg.SenderName
+	if msg.IsGroup() {
+		title = fmt.Sprintf("%s in %s", msg.SenderName, m.groupName(chatID))
+	} else if !msg.IsDirect() {
+		title = fmt.Sprintf("%s in global", msg.SenderName)
+	}
+	notify(m.config.Notifications, title, msg.Content)
+}
+
+func (m Model) groupName(groupID string) string {
+	if m.groupsView != nil {
+		for _, group := range m.groupsView.groups {
+			if group.ID == groupID {
+				return group.Name
+			}
+		}
+	}
+	return i18n.T("a group")
+}
+
 func (m *Model) SetUserID(userID string) {
 	m.userID = userID
 }
 
 func (m *Model) AddMessage(msg models.Message) {
 	chatID := m.getChatID(msg)
-	if m.messages[chatID] == nil {
-		m.messages[chatID] = make([]models.Message, 0)
-	}
-	m.messages[chatID] = append(m.messages[chatID], msg)
+	m.storeMessages(chatID, msg)
 
 	if chatID == m.selectedChat {
 		m.updateContent()
@@ -0,0 +1,33 @@
+// internal/client/tui/clipboard.go
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+
+	"github.com/atotto/clipboard"
+)
+
+// copyToClipboard copies text through OSC 52, which works over SSH in most
+// terminals, and through the platform clipboard when one is available
+func copyToClipboard(text string) error {
+    osc52 := fmt.Sprintf("\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+    _, oscErr := os.Stdout.WriteString(osc52)
+
+    if err := clipboard.WriteAll(text); err != nil && oscErr != nil {
+        return fmt.Errorf("failed to copy to clipboard: %v", err)
+    }
+    return nil
+}
+
+// copyMessage copies the content of a message, with its author and time when full is set
+func copyMessage(msg models.Message, full bool) error {
+    text := msg.Content
+    if full {
+        text = fmt.Sprintf("[%s] %s: %s", i18n.InZone(msg.SentAt).Format("2006-01-02 15:04:05"), msg.SenderName, msg.Content)
+    }
+    return copyToClipboard(text)
+}
@@ -0,0 +1,304 @@
+// internal/client/tui/commands.go
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// max number of commands suggested above the input
+const maxCommandSuggestions = 6
+
+// slashCommand is a client side command typed in the input, e.g. /theme light
+type slashCommand struct {
+    name  string // may have several words, e.g. "/friend add"
+    usage string
+    help  string
+    run   func(m *Model, args string)
+    // start replaces run for the commands with something to wait for
+    start func(m *Model, args string) tea.Cmd
+}
+
+// commandMsg is sent by the group chat to run a command typed in its input
+type commandMsg struct {
+    input string
+}
+
+// slashCommands is filled by init, /help needs to list it
+var slashCommands []slashCommand
+
+func init() {
+    slashCommands = []slashCommand{
+        {name: "/help", help: "list the commands", run: func(m *Model, _ string) {
+            m.notice = commandHelp()
+        }},
+        {name: "/clear", help: "clear the messages of this conversation from the screen", run: (*Model).clearConversation},
+        {name: "/theme", usage: "[name]", help: "switch the color theme", run: func(m *Model, args string) {
+            m.switchTheme(strings.Fields(args))
+        }},
+        {name: "/status", usage: "[for <duration>] [text]", help: "set your status text, empty to clear it", run: (*Model).statusCommand},
+        {name: "/online", help: "set your status to online", run: func(m *Model, _ string) {
+            m.setStatus(models.StatusOnline, m.activeStatusText(), m.statusExpires)
+        }},
+        {name: "/away", help: "set your status to away", run: func(m *Model, _ string) {
+            m.setStatus(models.StatusAway, m.activeStatusText(), m.statusExpires)
+        }},
+        {name: "/dnd", help: "do not disturb, no notifications", run: func(m *Model, _ string) {
+            m.setStatus(models.StatusDND, m.activeStatusText(), m.statusExpires)
+        }},
+        {name: "/notify", usage: "[all|mentions|badge|none]", help: "choose what this conversation notifies", run: func(m *Model, args string) {
+            m.setNotificationLevel(strings.TrimSpace(args))
+        }},
+        {name: "/mute", help: "mute the notifications of this conversation", run: func(m *Model, _ string) {
+            m.setNotificationLevel(config.LevelNone)
+        }},
+        {name: "/unmute", help: "unmute the notifications of this conversation", run: func(m *Model, _ string) {
+            m.setNotificationLevel(config.LevelAll)
+        }},
+        {name: "/markread", usage: "[all]", help: "mark this conversation read, or all of them", run: (*Model).markReadCommand},
+        {name: "/verify", help: "show the encryption fingerprints of this conversation", run: func(m *Model, _ string) {
+            m.showFingerprints()
+        }},
+        {name: "/remind", usage: "me in <duration> <text>", help: "get a message from Textual later, /remind list to see them", run: (*Model).remindCommand},
+        {name: "/gif", usage: "<search>", help: "post the GIF found for the search", run: (*Model).gifCommand},
+        {name: "/voice", usage: "[cancel]", help: "record a voice message, again to send it", start: (*Model).voiceCommand},
+        {name: "/play", help: "play the last voice message of this conversation", run: (*Model).playVoice},
+        {name: "/notes", help: "open your notes, a conversation with yourself", run: func(m *Model, _ string) {
+            m.switchConversation(conversation{ID: m.userID, Kind: directConversation})
+        }},
+        {name: "/prefs", usage: "[timezone|clock|locale <value>]", help: "show or set the timezone, clock and language of all your devices", run: (*Model).prefsCommand},
+        {name: "/goto", usage: "<link>", help: "open a message from its link, l copies it", run: (*Model).gotoCommand},
+        {name: "/export", usage: "[path]", help: "save this conversation to a file (.md, .json or text)", run: (*Model).exportConversation},
+        {name: "/debug", help: "show or hide the recent log lines", run: (*Model).toggleDebug},
+        {name: "/friend add", usage: "<username>", help: "send a friend request", run: (*Model).addFriend},
+        {name: "/group create", usage: "<name>", help: "create a group", run: (*Model).createGroup},
+        {name: "/group leave", help: "leave the open group", run: (*Model).leaveGroup},
+        {name: "/group topic", usage: "[text|clear]", help: "show or set the topic of the open group", run: (*Model).groupTopic},
+    }
+}
+
+// parseCommand finds the command of the input, the longest name wins so
+// "/friend add bob" is not read as "/friend" with "add bob"
+func parseCommand(input string) (slashCommand, string, bool) {
+    var found slashCommand
+    var args string
+    for _, cmd := range slashCommands {
+        if input != cmd.name && !strings.HasPrefix(input, cmd.name+" ") {
+            continue
+        }
+        if len(cmd.name) > len(found.name) {
+            found = cmd
+            args = strings.TrimSpace(strings.TrimPrefix(input, cmd.name))
+        }
+    }
+    return found, args, found.run != nil || found.start != nil
+}
+
+// runCommand runs the command typed in the input. It returns false when the
+// input is a message, "//" escapes a message starting with "/"
+func (m *Model) runCommand(input string) (bool, tea.Cmd) {
+    if !strings.HasPrefix(input, "/") || strings.HasPrefix(input, "//") {
+        return false, nil
+    }
+
+    cmd, args, ok := parseCommand(strings.TrimSpace(input))
+    if !ok {
+        m.err = fmt.Errorf(i18n.T("unknown command %s, type /help for the list"), strings.Fields(input)[0])
+        return true, nil
+    }
+    m.err = nil
+    if cmd.start != nil {
+        return true, cmd.start(m, args)
+    }
+    cmd.run(m, args)
+    return true, nil
+}
+
+func commandHelp() string {
+    var sb strings.Builder
+    sb.WriteString(i18n.T("Commands (start a message with // to send a literal /, ? lists the keys):"))
+    for _, cmd := range slashCommands {
+        sb.WriteString(fmt.Sprintf("\n  %-28s %s", strings.TrimSpace(cmd.name+" "+cmd.usage), i18n.T(cmd.help)))
+    }
+    return sb.String()
+}
+
+// clearConversation empties the conversation on screen, the messages stay on
+// the server and come back with the history
+func (m *Model) clearConversation(_ string) {
+    chatID := m.activeConversation()
+    if chatID == "" {
+        m.notice = i18n.T("Open a conversation first")
+        return
+    }
+
+    delete(m.messages, chatID)
+    m.history.Forget(chatID)
+    if m.groupsView != nil {
+        delete(m.groupsView.messages, chatID)
+    }
+    if m.dividerChat == chatID {
+        m.clearDivider()
+    }
+    m.updateContent()
+    m.notice = i18n.T("Conversation cleared")
+}
+
+func (m *Model) addFriend(username string) {
+    if username == "" {
+        m.err = errors.New(i18n.T("usage: /friend add <username>"))
+        return
+    }
+    if m.friendsView == nil {
+        if m.connection == nil {
+            m.err = errors.New(i18n.T("not connected"))
+            return
+        }
+        m.friendsView = NewFriendsView(m.connection)
+    }
+
+    if err := m.friendsView.AddFriend(username); err != nil {
+        m.err = err
+        return
+    }
+    m.notice = i18n.T("Friend request sent to %s", username)
+}
+
+func (m *Model) createGroup(name string) {
+    if name == "" {
+        m.err = errors.New(i18n.T("usage: /group create <name>"))
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    if err := m.connection.CreateGroup(name, "", false, false, false); err != nil {
+        m.err = err
+        return
+    }
+    m.notice = i18n.T("Creating group %s...", name)
+}
+
+// leaveGroup leaves the open group, the server answers with the group to drop
+func (m *Model) leaveGroup(_ string) {
+    groupID := ""
+    if m.groupsView != nil {
+        groupID = m.groupsView.ActiveGroup()
+    }
+    if groupID == "" {
+        m.err = errors.New(i18n.T("Open a group first"))
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    if err := m.connection.LeaveGroup(groupID); err != nil {
+        m.err = err
+    }
+}
+
+// groupTopic shows the topic of the open group without text, sets it
+// otherwise, the server only lets the admins do it
+func (m *Model) groupTopic(args string) {
+    groupID := ""
+    if m.groupsView != nil {
+        groupID = m.groupsView.ActiveGroup()
+    }
+    if groupID == "" {
+        m.err = errors.New(i18n.T("Open a group first"))
+        return
+    }
+    if args == "" {
+        if topic := m.groupsView.Topic(); topic != "" {
+            m.notice = i18n.T("Topic: %s", topic)
+        } else {
+            m.notice = i18n.T("No topic set, /group topic <text> sets one")
+        }
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    if args == "clear" {
+        args = ""
+    }
+    if err := m.connection.SetGroupTopic(groupID, args); err != nil {
+        m.err = err
+    }
+}
+
+// CommandCompleter suggests the commands matching the "/word" being typed
+type CommandCompleter struct {
+    suggestions
+    matches []slashCommand
+}
+
+// Refresh updates the suggestions from the input
+func (c *CommandCompleter) Refresh(input textinput.Model) {
+    value := input.Value()
+    if !strings.HasPrefix(value, "/") || strings.HasPrefix(value, "//") {
+        c.Dismiss()
+        return
+    }
+
+    c.matches = c.matches[:0]
+    for _, cmd := range slashCommands {
+        if strings.HasPrefix(cmd.name, value) && cmd.name != value {
+            c.matches = append(c.matches, cmd)
+        }
+    }
+    sort.SliceStable(c.matches, func(i, j int) bool {
+        return c.matches[i].name < c.matches[j].name
+    })
+    if len(c.matches) > maxCommandSuggestions {
+        c.matches = c.matches[:maxCommandSuggestions]
+    }
+    c.show(len(c.matches))
+}
+
+// HandleKey handles the navigation keys while suggestions are shown, it
+// returns false when the key is not for the completer
+func (c *CommandCompleter) HandleKey(key string, input *textinput.Model) bool {
+    return c.handleKey(key, func(selected int) {
+        cmd := c.matches[selected]
+        value := cmd.name
+        if cmd.usage != "" {
+            value += " "
+        }
+        input.SetValue(value)
+        input.CursorEnd()
+    })
+}
+
+func (c *CommandCompleter) View() string {
+    return c.render("\n", func(i int, selected bool) string {
+        cmd := c.matches[i]
+        label := strings.TrimSpace(cmd.name + " " + cmd.usage)
+        if selected {
+            label = sidebarCursorStyle.Render(label)
+        }
+        return label + " " + timestampStyleBase.Render(cmd.help)
+    })
+}
+
+// sendCommand hands a command typed in a view without access to the model
+// over to Model.Update
+func sendCommand(input string) tea.Cmd {
+    return func() tea.Msg {
+        return commandMsg{input: input}
+    }
+}
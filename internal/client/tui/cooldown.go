@@ -0,0 +1,59 @@
+// internal/client/tui/cooldown.go
+package tui
+
+import (
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// cooldownTickMsg redraws the countdown of the global chat every second
+type cooldownTickMsg struct{}
+
+// startCooldown pauses the global chat for the wait the server asked: the
+// messages it refused are marked failed, to retry once the countdown ends
+func (m *Model) startCooldown(wait time.Duration) tea.Cmd {
+    running := m.coolingDown()
+    m.cooldownUntil = time.Now().Add(wait)
+    for _, msg := range m.messages["global"] {
+        if msg.SendState == models.SendPending {
+            m.setSendState(msg.ClientID, models.SendFailed)
+        }
+    }
+    m.updateContent()
+    if running {
+        // a tick is already scheduled
+        return nil
+    }
+    return tickCooldown()
+}
+
+func tickCooldown() tea.Cmd {
+    return tea.Tick(time.Second, func(time.Time) tea.Msg {
+        return cooldownTickMsg{}
+    })
+}
+
+// coolingDown tells if the server refuses the messages of the global chat
+func (m Model) coolingDown() bool {
+    return time.Now().Before(m.cooldownUntil)
+}
+
+// cooldownLeft is the countdown shown above the input, in whole seconds
+func (m Model) cooldownLeft() int {
+    return int((time.Until(m.cooldownUntil) + time.Second - 1) / time.Second)
+}
+
+// updateCooldown ticks the countdown until its end
+func (m *Model) updateCooldown() tea.Cmd {
+    if m.coolingDown() {
+        return tickCooldown()
+    }
+    m.cooldownUntil = time.Time{}
+    if m.currentPage == GlobalPage {
+        m.notice = i18n.T("You can send messages again")
+    }
+    return nil
+}
@@ -0,0 +1,35 @@
+// internal/client/tui/debug.go
+package tui
+
+import (
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// number of log lines shown by /debug
+const debugPaneHeight = 8
+
+func (m *Model) toggleDebug(_ string) {
+    m.showDebug = !m.showDebug
+    m.resize()
+    m.updateContent()
+}
+
+// debugView draws the last lines of the client log under the page
+func (m Model) debugView(width int) string {
+    lines := logging.Recent.Lines(debugPaneHeight)
+    for len(lines) < debugPaneHeight {
+        lines = append(lines, "")
+    }
+
+    line := lipgloss.NewStyle().MaxWidth(width)
+    for i := range lines {
+        lines[i] = line.Render(timestampStyleBase.Render(lines[i]))
+    }
+
+    title := sidebarSectionStyle.Render(i18n.T("Log (%s, /debug to hide)", logging.CurrentLevel()))
+    return title + "\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,274 @@
+// internal/client/tui/discover.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// DiscoverView is the Discover page: the online users, or the ones found by
+// a search, then the public groups
+type DiscoverView struct {
+    viewport  viewport.Model
+    search    textinput.Model
+    query     string // search the users listed answer, "" for the online ones
+    users     []models.UserSummary
+    groups    []models.GroupSummary
+    requested map[string]bool // users sent a friend request from the page
+    joining   string          // group joined from the page, waiting for the server
+    cursor    int             // users first, then groups
+    loaded    bool
+    width     int
+    height    int
+}
+
+func NewDiscoverView() *DiscoverView {
+    search := textinput.New()
+    search.Placeholder = i18n.T("Search for a user...")
+    search.CharLimit = 50
+    vp := viewport.New(0, 0)
+    vp.KeyMap = viewport.KeyMap{} // the cursor moves with the keys, the wheel scrolls
+    return &DiscoverView{
+        viewport:  vp,
+        search:    search,
+        requested: make(map[string]bool),
+    }
+}
+
+func (d *DiscoverView) Update(msg tea.Msg) tea.Cmd {
+    var cmd tea.Cmd
+    d.viewport, cmd = d.viewport.Update(msg)
+    return cmd
+}
+
+func (d *DiscoverView) View() string {
+    header := titleStyle.Render(i18n.T("Discover"))
+    help := i18n.T("j/k move • enter add friend/chat, join/open group • / search • r refresh")
+    if d.search.Focused() {
+        help = i18n.T("enter search • esc cancel • empty search for the online users")
+    }
+    return header + "\n" + d.search.View() + "\n" + d.viewport.View() + "\n" + timestampStyleBase.Render(help)
+}
+
+func (d *DiscoverView) Resize(width, height int) {
+    d.width = width
+    d.height = height
+    d.search.Width = max(width-4, 10)
+    d.viewport.Width = width
+    d.viewport.Height = height - 4 // title, its margin, the search and the help line
+    d.updateContent()
+}
+
+// SetUsers stores the users found by a search, an answer to an older
+// search is dropped
+func (d *DiscoverView) SetUsers(query string, users []models.UserSummary) {
+    if query != d.query {
+        return
+    }
+    d.users = users
+    d.loaded = true
+    d.clampCursor()
+    d.updateContent()
+}
+
+// SetGroups stores the public groups sent by the server
+func (d *DiscoverView) SetGroups(groups []models.GroupSummary) {
+    d.groups = groups
+    d.clampCursor()
+    d.updateContent()
+}
+
+// GroupJoined marks a group joined
+func (d *DiscoverView) GroupJoined(group models.Group) {
+    for i := range d.groups {
+        if d.groups[i].ID == group.ID {
+            d.groups[i].IsMember = true
+            d.groups[i].MemberCount = len(group.Members)
+        }
+    }
+    if d.joining == group.ID {
+        d.joining = ""
+    }
+    d.updateContent()
+}
+
+func (d *DiscoverView) clampCursor() {
+    d.cursor = min(d.cursor, max(len(d.users)+len(d.groups)-1, 0))
+}
+
+func (d *DiscoverView) Move(delta int) {
+    d.cursor = min(max(d.cursor+delta, 0), max(len(d.users)+len(d.groups)-1, 0))
+    d.updateContent()
+}
+
+// selected returns the user or the group under the cursor, nil for the other
+func (d *DiscoverView) selected() (*models.UserSummary, *models.GroupSummary) {
+    switch {
+    case d.cursor < len(d.users):
+        return &d.users[d.cursor], nil
+    case d.cursor-len(d.users) < len(d.groups):
+        return nil, &d.groups[d.cursor-len(d.users)]
+    }
+    return nil, nil
+}
+
+func (d *DiscoverView) updateContent() {
+    var sb strings.Builder
+    line, cursorLine := 0, 0
+    write := func(i int, text, detail string) {
+        marker := " "
+        if i == d.cursor {
+            marker = selectionMarkerStyle.Render("▌")
+            cursorLine = line
+        }
+        sb.WriteString(marker + text + "\n")
+        line++
+        if detail != "" {
+            sb.WriteString("   " + sidebarPreviewStyle.Render(runewidth.Truncate(detail, max(d.width-3, 10), "…")) + "\n")
+            line++
+        }
+    }
+
+    title := i18n.T("Online users")
+    if d.query != "" {
+        title = i18n.T("Users starting with %s", d.query)
+    }
+    sb.WriteString(sidebarSectionStyle.Render(title) + "\n")
+    line++
+    switch {
+    case !d.loaded:
+        sb.WriteString(timestampStyleBase.Render(i18n.T("Loading...")) + "\n")
+        line++
+    case len(d.users) == 0:
+        sb.WriteString(timestampStyleBase.Render(i18n.T("Nobody found")) + "\n")
+        line++
+    }
+    for i, user := range d.users {
+        text := fmt.Sprintf("%s %s", statusIcon(user.Status), user.Username)
+        switch {
+        case user.IsFriend:
+            text += " " + successStyle.Render(i18n.T("friend"))
+        case d.requested[user.ID]:
+            text += " " + noticeStyle.Render(i18n.T("request sent"))
+        }
+        write(i, text, user.StatusText)
+    }
+
+    sb.WriteString("\n" + sidebarSectionStyle.Render(i18n.T("Public groups")) + "\n")
+    line += 2
+    if len(d.groups) == 0 {
+        sb.WriteString(timestampStyleBase.Render(i18n.T("No public group yet")) + "\n")
+    }
+    for i, group := range d.groups {
+        icon := "📦"
+        if group.Announcement {
+            icon = "📢"
+        }
+        text := fmt.Sprintf("%s %s - %d members", icon, group.Name, group.MemberCount)
+        switch {
+        case group.ID == d.joining:
+            text += " " + noticeStyle.Render(i18n.T("joining..."))
+        case group.IsMember:
+            text += " " + successStyle.Render(i18n.T("joined"))
+        }
+        write(len(d.users)+i, text, group.Description)
+    }
+
+    d.viewport.SetContent(sb.String())
+    if cursorLine < d.viewport.YOffset {
+        d.viewport.SetYOffset(cursorLine)
+    } else if cursorLine+2 > d.viewport.YOffset+d.viewport.Height {
+        d.viewport.SetYOffset(cursorLine + 2 - d.viewport.Height)
+    }
+}
+
+// loadDiscover requests the users of the current search and the public
+// groups
+func (m *Model) loadDiscover() {
+    if m.connection == nil {
+        return
+    }
+    if err := m.connection.SearchUsers(m.discover.query); err != nil {
+        logging.Errorf("Failed to search users: %v", err)
+    }
+    if err := m.connection.LoadGroupDirectory(); err != nil {
+        logging.Errorf("Failed to load public groups: %v", err)
+    }
+}
+
+// handleDiscoverKey runs the keys of the Discover page
+func (m *Model) handleDiscoverKey(msg tea.KeyMsg) tea.Cmd {
+    d := m.discover
+    if d.search.Focused() {
+        switch msg.String() {
+        case "enter":
+            d.query = strings.TrimSpace(d.search.Value())
+            d.loaded = false
+            d.cursor = 0
+            d.search.Blur()
+            d.updateContent()
+            m.loadDiscover()
+        case "esc":
+            d.search.SetValue(d.query)
+            d.search.Blur()
+        default:
+            var cmd tea.Cmd
+            d.search, cmd = d.search.Update(msg)
+            return cmd
+        }
+        return nil
+    }
+
+    switch {
+    case key.Matches(msg, discoverKeys.Down):
+        d.Move(1)
+    case key.Matches(msg, discoverKeys.Up):
+        d.Move(-1)
+    case key.Matches(msg, discoverKeys.Search):
+        return d.search.Focus()
+    case key.Matches(msg, discoverKeys.Refresh):
+        m.loadDiscover()
+    case key.Matches(msg, discoverKeys.Open):
+        m.openDiscovered()
+    }
+    return nil
+}
+
+// openDiscovered acts on the selected entry of the Discover page: a friend
+// request to a user, or the chat once they are friends, and the join of a
+// group, or the group once joined
+func (m *Model) openDiscovered() {
+    d := m.discover
+    user, group := d.selected()
+    switch {
+    case user != nil && user.IsFriend:
+        m.messagesView.AddContact(user.ID, user.Username)
+        m.switchConversation(conversation{ID: user.ID, Kind: directConversation, Name: user.Username})
+    case user != nil && !d.requested[user.ID]:
+        if err := m.connection.SendFriendRequest(user.Username); err != nil {
+            m.err = fmt.Errorf("%s", i18n.T("Error sending a friend request to %s: %v", user.Username, err))
+            return
+        }
+        d.requested[user.ID] = true
+        d.updateContent()
+    case group != nil && group.IsMember:
+        m.switchConversation(conversation{ID: group.ID, Kind: groupConversation, Name: group.Name})
+        m.sidebar.Select(m.activeConversation())
+    case group != nil && d.joining == "":
+        if err := m.connection.JoinGroup(group.ID); err != nil {
+            m.err = fmt.Errorf("%s", i18n.T("Error joining %s: %v", group.Name, err))
+            return
+        }
+        d.joining = group.ID
+        d.updateContent()
+    }
+}
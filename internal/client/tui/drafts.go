@@ -0,0 +1,48 @@
+// internal/client/tui/drafts.go
+package tui
+
+import (
+	"textual/internal/client/config"
+	"textual/internal/client/logging"
+)
+
+// draftStore keeps what was typed but not sent in each conversation
+type draftStore struct {
+    userID string
+    drafts map[string]string
+    dirty  bool
+}
+
+func loadDraftStore(userID string) *draftStore {
+    drafts, err := config.LoadDrafts(userID)
+    if err != nil {
+        logging.Errorf("Failed to load drafts: %v", err)
+    }
+    return &draftStore{userID: userID, drafts: drafts}
+}
+
+// Swap stores the input of the chat being left and returns the draft of the chat opened
+func (d *draftStore) Swap(from, to, input string) string {
+    if from != "" && d.drafts[from] != input {
+        if input == "" {
+            delete(d.drafts, from)
+        } else {
+            d.drafts[from] = input
+        }
+        d.dirty = true
+    }
+
+    return d.drafts[to]
+}
+
+// Save writes the drafts to disk when they changed
+func (d *draftStore) Save() {
+    if !d.dirty || d.userID == "" {
+        return
+    }
+    if err := config.SaveDrafts(d.userID, d.drafts); err != nil {
+        logging.Errorf("Failed to save drafts: %v", err)
+        return
+    }
+    d.dirty = false
+}
@@ -0,0 +1,35 @@
+// internal/client/tui/encryption.go
+package tui
+
+import (
+	"textual/internal/client/i18n"
+)
+
+// showFingerprints writes the fingerprint of this device and, in a direct
+// conversation, the ones of the devices of the contact. Both users read them
+// aloud or in person, they match when nobody is between them
+func (m *Model) showFingerprints() {
+    if m.connection == nil {
+        return
+    }
+    chatID := ""
+    if m.currentPage == MessagesPage {
+        chatID = m.selectedChat
+    }
+    own, peers, ok := m.connection.Fingerprints(chatID)
+    if !ok {
+        m.notice = i18n.T("Encryption is disabled, set encryption = true in the config")
+        return
+    }
+
+    notice := i18n.T("Your fingerprint: %s", own)
+    if chatID != "" {
+        if len(peers) == 0 {
+            notice += "\n" + i18n.T("No key of %s yet, send a message first", m.messagesView.ContactName(chatID))
+        }
+        for _, fingerprint := range peers {
+            notice += "\n" + i18n.T("%s: %s", m.messagesView.ContactName(chatID), fingerprint)
+        }
+    }
+    m.notice = notice
+}
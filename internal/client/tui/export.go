@@ -0,0 +1,176 @@
+// internal/client/tui/export.go
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+	"time"
+)
+
+// pages of history fetched at most for an export, older messages are left out
+const maxExportPages = 40
+
+// exports are meant to be archived, the dates don't follow the locale
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// pendingExport is an export waiting for the older history of its chat
+type pendingExport struct {
+    chatID string
+    name   string
+    path   string
+    pages  int
+}
+
+// exportedMessage is a message in a JSON export
+type exportedMessage struct {
+    ID       string     `json:"id"`
+    SenderID string     `json:"sender_id"`
+    Sender   string     `json:"sender"`
+    Content  string     `json:"content"`
+    SentAt   time.Time  `json:"sent_at"`
+    EditedAt *time.Time `json:"edited_at,omitempty"`
+}
+
+// exportConversation runs /export [path]: the history of the open chat is
+// fetched from the server, then written as Markdown (.md), JSON (.json) or
+// plain text (any other extension)
+func (m *Model) exportConversation(args string) {
+    chatID := m.activeConversation()
+    if chatID == "" {
+        m.notice = i18n.T("Open a conversation first")
+        return
+    }
+
+    name := m.exportName(chatID)
+    path := strings.TrimSpace(args)
+    if path == "" {
+        path = fmt.Sprintf("textual-%s-%s.md", safeFileName(name), time.Now().Format("20060102-150405"))
+    } else if strings.HasPrefix(path, "~/") {
+        if home, err := os.UserHomeDir(); err == nil {
+            path = filepath.Join(home, path[2:])
+        }
+    }
+
+    m.export = &pendingExport{chatID: chatID, name: name, path: path}
+    m.continueExport()
+}
+
+// continueExport requests the next page of older messages, or writes the
+// file once the whole history is loaded
+func (m *Model) continueExport() {
+    e := m.export
+    chat := m.messages[e.chatID]
+    done := m.noMoreHistory[e.chatID] || e.pages >= maxExportPages || (len(chat) == 0 && e.pages > 0)
+    if !done && m.connection != nil {
+        beforeID := ""
+        if len(chat) > 0 {
+            beforeID = chat[0].ID
+        }
+        if err := m.loadHistory(e.chatID, beforeID); err == nil {
+            e.pages++
+            m.isLoading = true
+            m.notice = i18n.T("Fetching the history of %s...", e.name)
+            return
+        }
+    }
+
+    m.export = nil
+    count, err := writeExport(e.path, e.name, chat)
+    if err != nil {
+        m.err = err
+        return
+    }
+    m.notice = i18n.T("%d messages exported to %s", count, e.path)
+}
+
+// exportName is the name of the chat as listed in the sidebar
+func (m Model) exportName(chatID string) string {
+    for _, conv := range m.conversations() {
+        if conv.ID == chatID {
+            return conv.Name
+        }
+    }
+    return m.messagesView.ContactName(chatID)
+}
+
+// writeExport writes the stored messages of a chat, the ones still pending
+// or failed are left out
+func writeExport(path, name string, chat []models.Message) (int, error) {
+    var messages []models.Message
+    for _, msg := range chat {
+        if msg.SendState == "" {
+            messages = append(messages, msg)
+        }
+    }
+
+    var data []byte
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".json":
+        exported := make([]exportedMessage, 0, len(messages))
+        for _, msg := range messages {
+            exported = append(exported, exportedMessage{
+                ID:       msg.ID,
+                SenderID: msg.SenderID,
+                Sender:   msg.SenderName,
+                Content:  msg.Content,
+                SentAt:   msg.SentAt,
+                EditedAt: msg.EditedAt,
+            })
+        }
+        encoded, err := json.MarshalIndent(exported, "", "  ")
+        if err != nil {
+            return 0, fmt.Errorf("failed to encode the export: %v", err)
+        }
+        data = append(encoded, '\n')
+
+    case ".md", ".markdown":
+        var sb strings.Builder
+        sb.WriteString(fmt.Sprintf("# %s\n\n", name))
+        sb.WriteString(fmt.Sprintf("_Exported on %s_\n", time.Now().Format(exportTimeLayout)))
+        for _, msg := range messages {
+            sb.WriteString(fmt.Sprintf("\n**%s** · %s", msg.SenderName, i18n.InZone(msg.SentAt).Format(exportTimeLayout)))
+            if msg.IsEdited() {
+                sb.WriteString(" (edited)")
+            }
+            // a hard line break keeps the lines of multi-line messages
+            sb.WriteString("\n\n" + strings.ReplaceAll(msg.Content, "\n", "  \n") + "\n")
+        }
+        data = []byte(sb.String())
+
+    default:
+        var sb strings.Builder
+        for _, msg := range messages {
+            line := fmt.Sprintf("[%s] %s: %s", i18n.InZone(msg.SentAt).Format(exportTimeLayout), msg.SenderName, msg.Content)
+            if msg.IsEdited() {
+                line += " (edited)"
+            }
+            sb.WriteString(line + "\n")
+        }
+        data = []byte(sb.String())
+    }
+
+    // conversations are private, the file is readable by the user only
+    if err := os.WriteFile(path, data, 0600); err != nil {
+        return 0, fmt.Errorf("failed to write %s: %v", path, err)
+    }
+    return len(messages), nil
+}
+
+// safeFileName keeps the letters and digits of a conversation name
+func safeFileName(name string) string {
+    name = strings.Map(func(r rune) rune {
+        if r == '-' || r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+            return r
+        }
+        return '-'
+    }, name)
+    if name = strings.Trim(name, "-"); name == "" {
+        return "conversation"
+    }
+    return name
+}
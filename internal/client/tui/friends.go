@@ -3,12 +3,13 @@ package tui
 
 import (
 	"fmt"
-	"log"
 	"strings"
+	"textual/internal/client/i18n"
 	"textual/internal/client/models"
 	"textual/internal/client/network"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -19,33 +20,33 @@ var (
     friendsViewStyle = lipgloss.NewStyle().
         Padding(1, 2)
 
-    friendTitleStyle = lipgloss.NewStyle().
-        Bold(true).
-        Foreground(lipgloss.Color("#FF87D7"))
-
     // friendInputStyle = lipgloss.NewStyle().
     //     BorderStyle(lipgloss.RoundedBorder()).
     //     BorderForeground(lipgloss.Color("#874BFD")).
     //     Padding(0, 1)
-    
-    successStyle = lipgloss.NewStyle().
-        Foreground(lipgloss.Color("#5AF78E"))
-
-    notificationStyle = lipgloss.NewStyle().
-        Foreground(lipgloss.Color("#FFD700")).
-        Bold(true)
-
-    pendingRequestStyle = lipgloss.NewStyle().
-        Foreground(lipgloss.Color("#FFB6C1")).
-        Bold(true)
 )
 
+// a friend line is never narrower, the name stays readable on a small
+// terminal
+const minFriendLine = 20
+
 type Notification struct {
     Message   string
     Timestamp time.Time
     IsError   bool
 }
 
+// friendAction is an action on a friend waiting for the user's confirmation
+type friendAction struct {
+    block  bool
+    friend models.User
+}
+
+// startChatMsg asks the chat model to open the conversation with a friend
+type startChatMsg struct {
+    friend models.User
+}
+
 type FriendsView struct {
     list              list.Model
     searchInput       textinput.Model
@@ -55,14 +56,17 @@ type FriendsView struct {
     width             int
     height            int
     connectionHandler *network.ConnectionHandler
-    onStartChat       func(string)
     notifications     []Notification
     userID           string
+    // browsing is set while the cursor is on the friends instead of the search input
+    browsing          bool
+    cursor            int
+    confirm           *friendAction
 }
 
 func NewFriendsView(handler *network.ConnectionHandler) *FriendsView {
     searchInput := textinput.New()
-    searchInput.Placeholder = "Search for a user..."
+    searchInput.Placeholder = i18n.T("Search for a user...")
     searchInput.Focus()
     searchInput.CharLimit = 100
 
@@ -70,7 +74,7 @@ func NewFriendsView(handler *network.ConnectionHandler) *FriendsView {
     delegate.ShowDescription = true
 
     l := list.New([]list.Item{}, delegate, 0, 0)
-    l.Title = "Friends"
+    l.Title = i18n.T("Friends")
     l.SetShowStatusBar(false)
     l.SetFilteringEnabled(false)
 
@@ -98,23 +102,49 @@ func (f *FriendsView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
     switch msg := msg.(type) {
     case tea.KeyMsg:
-        switch msg.String() {
-        case "y":
+        if f.confirm != nil {
+            f.handleConfirmKey(msg.String())
+            return f, nil
+        }
+        if f.browsing {
+            return f, f.handleFriendKey(msg)
+        }
+
+        switch {
+        case key.Matches(msg, friendSearchKeys.Browse):
+            if len(f.friends) > 0 {
+                f.browsing = true
+                f.searchInput.Blur()
+                return f, nil
+            }
+
+        case key.Matches(msg, friendSearchKeys.Accept):
             if item, ok := f.list.SelectedItem().(requestItem); ok && !item.isSent {
                 err := f.AcceptRequest(item.request.ID)
                 if err != nil {
-                    f.addNotification(fmt.Sprintf("Error: %v", err), true)
+                    f.addNotification(i18n.T("Error: %v", err), true)
+                } else {
+                    f.addNotification(i18n.T("Accepted friend request from %s", item.request.FromUser), false)
+                    f.RemovePendingRequest(item.request.ID)
+                }
+            }
+
+        case key.Matches(msg, friendSearchKeys.Reject):
+            if item, ok := f.list.SelectedItem().(requestItem); ok && !item.isSent {
+                err := f.RejectRequest(item.request.ID)
+                if err != nil {
+                    f.addNotification(i18n.T("Error: %v", err), true)
                 } else {
-                    f.addNotification(fmt.Sprintf("Accepted friend request from %s", item.request.FromUser), false)
+                    f.addNotification(i18n.T("Declined friend request from %s", item.request.FromUser), false)
                     f.RemovePendingRequest(item.request.ID)
                 }
             }
 
-        case "enter":
+        case key.Matches(msg, friendSearchKeys.Add):
             if f.searchInput.Value() != "" {
                 username := f.searchInput.Value()
                 if err := f.AddFriend(username); err != nil {
-                    f.addNotification(fmt.Sprintf("Error: %v", err), true)
+                    f.addNotification(i18n.T("Error: %v", err), true)
                 }
                 f.searchInput.Reset()
                 f.updateItems()
@@ -127,22 +157,6 @@ func (f *FriendsView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         cmds = append(cmds, cmd)
         return f, tea.Batch(cmds...)
 
-    case models.MessageReceived:
-        log.Printf("Message received in FriendsView: %+v", msg.Message)
-        if msg.Message.Content == "Friend request" {
-            log.Printf("Processing friend request from %s", msg.Message.SenderName)
-            newRequest := models.FriendRequest{
-                ID:        msg.Message.ID,
-                FromUser:  msg.Message.SenderName,
-                CreatedAt: msg.Message.SentAt,
-                Status:    "pending",
-            }
-            f.pendingRequests = append(f.pendingRequests, newRequest)
-            f.addNotification(fmt.Sprintf("New friend request from %s", msg.Message.SenderName), false)
-            f.updateItems()
-        }
-        return f, nil
-
     case tea.WindowSizeMsg:
         f.width = msg.Width
         f.height = msg.Height
@@ -154,37 +168,157 @@ func (f *FriendsView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     return f, tea.Batch(cmds...)
 }
 
+// handleFriendKey handles the keys while a friend is selected
+func (f *FriendsView) handleFriendKey(msg tea.KeyMsg) tea.Cmd {
+    friend, ok := f.selectedFriend()
+    if !ok {
+        f.stopBrowsing()
+        return nil
+    }
+
+    switch {
+    case key.Matches(msg, friendListKeys.Up):
+        if f.cursor == 0 {
+            f.stopBrowsing()
+        } else {
+            f.cursor--
+        }
+    case key.Matches(msg, friendListKeys.Down):
+        if f.cursor < len(f.friends)-1 {
+            f.cursor++
+        }
+    case key.Matches(msg, friendListKeys.Chat):
+        f.stopBrowsing()
+        return func() tea.Msg {
+            return startChatMsg{friend: friend}
+        }
+    case key.Matches(msg, friendListKeys.Remove):
+        f.confirm = &friendAction{friend: friend}
+    case key.Matches(msg, friendListKeys.Block):
+        f.confirm = &friendAction{friend: friend, block: true}
+    case key.Matches(msg, friendListKeys.Back):
+        f.stopBrowsing()
+    }
+    return nil
+}
+
+// handleConfirmKey runs the pending action on "y", any other key cancels it
+func (f *FriendsView) handleConfirmKey(key string) {
+    action := f.confirm
+    f.confirm = nil
+    if key != "y" {
+        return
+    }
+
+    if f.connectionHandler == nil {
+        f.addNotification(i18n.T("Error: not connected"), true)
+        return
+    }
+
+    var err error
+    if action.block {
+        err = f.connectionHandler.BlockUser(action.friend.ID)
+    } else {
+        err = f.connectionHandler.RemoveFriend(action.friend.ID)
+    }
+    if err != nil {
+        f.addNotification(i18n.T("Error: %v", err), true)
+    }
+}
+
+// RemoveFriend drops a friend once the server confirmed the removal
+// SetFriends replaces the friends with the list sent by the server
+func (f *FriendsView) SetFriends(friends []models.User) {
+    f.friends = friends
+    if f.cursor >= len(f.friends) {
+        f.cursor = len(f.friends) - 1
+    }
+    if len(f.friends) == 0 {
+        f.stopBrowsing()
+    }
+    f.updateItems()
+}
+
+func (f *FriendsView) RemoveFriend(userID string, blocked bool) {
+    for i, friend := range f.friends {
+        if friend.ID != userID {
+            continue
+        }
+        f.friends = append(f.friends[:i], f.friends[i+1:]...)
+        if blocked {
+            f.addNotification(i18n.T("Blocked %s", friend.Username), false)
+        } else {
+            f.addNotification(fmt.Sprintf("%s is no longer your friend", friend.Username), false)
+        }
+        break
+    }
+
+    if f.cursor >= len(f.friends) {
+        f.cursor = len(f.friends) - 1
+    }
+    if len(f.friends) == 0 {
+        f.stopBrowsing()
+    }
+    f.updateItems()
+}
+
+// SetPresence shows the new status and custom text of a friend
+func (f *FriendsView) SetPresence(update models.StatusUpdate) {
+    for i := range f.friends {
+        if f.friends[i].ID == update.UserID {
+            f.friends[i].Status = update.Status
+            f.friends[i].StatusText = update.Text
+            f.friends[i].StatusExpires = update.ExpiresAt
+            f.updateItems()
+            return
+        }
+    }
+}
+
+func (f *FriendsView) selectedFriend() (models.User, bool) {
+    if f.cursor < 0 || f.cursor >= len(f.friends) {
+        return models.User{}, false
+    }
+    return f.friends[f.cursor], true
+}
+
+func (f *FriendsView) stopBrowsing() {
+    f.browsing = false
+    f.cursor = 0
+    f.searchInput.Focus()
+}
+
 func (f *FriendsView) View() string {
     var sb strings.Builder
 
     // search input
-    sb.WriteString("Search for users (press Enter to send friend request):\n")
+    sb.WriteString(i18n.T("Search for users (press Enter to send friend request):\n"))
     sb.WriteString(f.searchInput.View())
     sb.WriteString("\n\n")
 
     // Display pending requests
     if len(f.pendingRequests) > 0 {
-        sb.WriteString(pendingRequestStyle.Render("🔔 Pending Friend Requests:"))
+        sb.WriteString(pendingRequestStyle.Render(i18n.T("🔔 Pending Friend Requests:")))
         sb.WriteString("\n")
         for _, req := range f.pendingRequests {
-            sb.WriteString(fmt.Sprintf("📨 From %s - [y] Accept • [n] Reject\n", req.FromUser))
+            sb.WriteString(i18n.T("📨 From %s - [y] Accept • [n] Reject\n", req.FromUser))
         }
         sb.WriteString("\n")
     }
 
     // Display sent requests
     if len(f.sentRequests) > 0 {
-        sb.WriteString(pendingRequestStyle.Render("📤 Sent Friend Requests:"))
+        sb.WriteString(pendingRequestStyle.Render(i18n.T("📤 Sent Friend Requests:")))
         sb.WriteString("\n")
         for _, req := range f.sentRequests {
-            sb.WriteString(fmt.Sprintf("📤 To %s - Pending...\n", req.ToUser))
+            sb.WriteString(i18n.T("📤 To %s - Pending...\n", req.ToUser))
         }
         sb.WriteString("\n")
     }
 
     // Display notifications
     if len(f.notifications) > 0 {
-        sb.WriteString(notificationStyle.Render("Recent Activity:"))
+        sb.WriteString(notificationStyle.Render(i18n.T("Recent Activity:")))
         sb.WriteString("\n")
         start := len(f.notifications) - 5
         if start < 0 {
@@ -196,7 +330,7 @@ func (f *FriendsView) View() string {
                 style = errorStyle
             }
             sb.WriteString(style.Render(fmt.Sprintf("[%s] %s\n",
-                notif.Timestamp.Format("15:04:05"),
+                i18n.FormatTime(notif.Timestamp),
                 notif.Message)))
         }
         sb.WriteString("\n")
@@ -204,16 +338,32 @@ func (f *FriendsView) View() string {
 
     // Display friends
     if len(f.friends) > 0 {
-        sb.WriteString(friendTitleStyle.Render("Friends"))
+        sb.WriteString(friendTitleStyle.Render(i18n.T("Friends")))
         sb.WriteString("\n")
-        for _, friend := range f.friends {
-            statusIcon := "⭘"
-            if friend.Status == "online" {
-                statusIcon = "🟢"
-            } else if friend.Status == "away" {
-                statusIcon = "🟡"
+        for i, friend := range f.friends {
+            // the name first, the status text in the room left
+            room := max(f.width-6, minFriendLine)
+            line := fmt.Sprintf("%s %s", statusIcon(friend.Status), truncateWidth(friend.Username, room-3))
+            if text := friend.ActiveStatusText(); text != "" && textWidth(line)+2 < room {
+                line += " " + timestampStyleBase.Render(truncateWidth(text, room-textWidth(line)-1))
+            }
+            if f.browsing && i == f.cursor {
+                sb.WriteString(selectionMarkerStyle.Render("▌") + sidebarCursorStyle.Render(line) + "\n")
+            } else {
+                sb.WriteString(" " + line + "\n")
             }
-            sb.WriteString(fmt.Sprintf("%s %s\n", statusIcon, friend.Username))
+        }
+        sb.WriteString("\n")
+
+        switch {
+        case f.confirm != nil && f.confirm.block:
+            sb.WriteString(noticeStyle.Render(i18n.T("Block %s? They won't be able to message you. [y/n]", f.confirm.friend.Username)))
+        case f.confirm != nil:
+            sb.WriteString(noticeStyle.Render(i18n.T("Remove %s from your friends? [y/n]", f.confirm.friend.Username)))
+        case f.browsing:
+            sb.WriteString(timestampStyleBase.Render(i18n.T("j/k move • enter chat • r remove • b block • esc back to search")))
+        default:
+            sb.WriteString(timestampStyleBase.Render(i18n.T("↓ select a friend")))
         }
     }
 
@@ -241,7 +391,7 @@ func (f *FriendsView) AddFriend(username string) error {
         return err
     }
 
-    f.addNotification(fmt.Sprintf("Friend request sent to %s", username), false)
+    f.addNotification(i18n.T("Friend request sent to %s", username), false)
     return nil
 }
 
@@ -277,6 +427,49 @@ func (f *FriendsView) AcceptRequest(requestID string) error {
     return nil
 }
 
+func (f *FriendsView) RejectRequest(requestID string) error {
+    if f.connectionHandler == nil {
+        return fmt.Errorf("not connected")
+    }
+    return f.connectionHandler.RespondFriendRequest(requestID, false)
+}
+
+// AddPendingRequest shows a friend request to answer, once: the pending ones
+// come again after a reconnection
+func (f *FriendsView) AddPendingRequest(request models.FriendRequest) {
+    for _, req := range f.pendingRequests {
+        if req.ID == request.ID {
+            return
+        }
+    }
+    f.pendingRequests = append(f.pendingRequests, request)
+    f.addNotification(i18n.T("New friend request from %s", request.FromUser), false)
+    f.updateItems()
+}
+
+// RequestResponded drops an answered request. The answer to a request of
+// the user is told, the ones the user gave are already known
+func (f *FriendsView) RequestResponded(msg models.FriendRequestResponded) {
+    for _, req := range f.pendingRequests {
+        if req.ID == msg.RequestID {
+            f.RemovePendingRequest(msg.RequestID)
+            return
+        }
+    }
+    for _, req := range f.sentRequests {
+        if !strings.EqualFold(req.ToUser, msg.Username) {
+            continue
+        }
+        if msg.Accepted {
+            f.addNotification(i18n.T("%s accepted your friend request", msg.Username), false)
+        } else {
+            f.addNotification(i18n.T("%s declined your friend request", msg.Username), false)
+        }
+        f.removeSentRequest(req.ToUser)
+        return
+    }
+}
+
 func (f *FriendsView) addNotification(msg string, isError bool) {
     notification := Notification{
         Message:   msg,
@@ -317,7 +510,9 @@ func (f *FriendsView) resize() {
 }
 
 func (f *FriendsView) Focus() {
-    f.searchInput.Focus()
+    if !f.browsing {
+        f.searchInput.Focus()
+    }
 }
 
 func (f *FriendsView) Blur() {
@@ -330,17 +525,28 @@ type friendItem struct {
 }
 
 func (i friendItem) Title() string {
-    statusIcon := "⭘" // offline
-    if i.user.Status == "online" {
-        statusIcon = "🟢"
-    } else if i.user.Status == "away" {
-        statusIcon = "🟡"
+    return fmt.Sprintf("%s %s", statusIcon(i.user.Status), i.user.Username)
+}
+
+// statusIcon returns the presence icon shown next to usernames
+func statusIcon(status string) string {
+    switch status {
+    case models.StatusOnline:
+        return "🟢"
+    case models.StatusAway:
+        return "🟡"
+    case models.StatusDND:
+        return "⛔"
+    default:
+        return "⭘"
     }
-    return fmt.Sprintf("%s %s", statusIcon, i.user.Username)
 }
 
 func (i friendItem) Description() string {
-    return fmt.Sprintf("Status: %s", i.user.Status)
+    if text := i.user.ActiveStatusText(); text != "" {
+        return i18n.T("Status: %s", i18n.T(i.user.Status)) + " · " + text
+    }
+    return i18n.T("Status: %s", i18n.T(i.user.Status))
 }
 
 func (i friendItem) FilterValue() string {
@@ -354,16 +560,16 @@ type requestItem struct {
 
 func (i requestItem) Title() string {
     if i.isSent {
-        return fmt.Sprintf("📤 Request sent to %s", i.request.ToUser)
+        return i18n.T("📤 Request sent to %s", i.request.ToUser)
     }
-    return fmt.Sprintf("📨 Request from %s", i.request.FromUser)
+    return i18n.T("📨 Request from %s", i.request.FromUser)
 }
 
 func (i requestItem) Description() string {
     if i.isSent {
-        return "Waiting for response..."
+        return i18n.T("Waiting for response...")
     }
-    return "Press [y] to accept or [n] to reject"
+    return i18n.T("Press [y] to accept or [n] to reject")
 }
 
 func (i requestItem) FilterValue() string {
@@ -0,0 +1,53 @@
+// internal/client/tui/gifs.go
+package tui
+
+import (
+	"errors"
+	"textual/internal/client/i18n"
+	"textual/pkg/protocol"
+	"unicode/utf8"
+)
+
+// gifCommand runs /gif <query>: the server searches the GIF and posts it in
+// the open conversation, its still image comes as the preview of the link
+func (m *Model) gifCommand(query string) {
+    if query == "" {
+        m.err = errors.New(i18n.T("usage: /gif <search>"))
+        return
+    }
+    if utf8.RuneCountInString(query) > protocol.MaxGifQuery {
+        m.err = errors.New(i18n.T("GIF search too long"))
+        return
+    }
+    chatID := m.activeConversation()
+    if chatID == "" {
+        m.notice = i18n.T("Open a conversation first")
+        return
+    }
+    if m.connection == nil || m.offline() {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    var recipientID, groupID string
+    switch m.currentPage {
+    case MessagesPage:
+        recipientID = chatID
+    case GroupsPage:
+        groupID = chatID
+    }
+    if recipientID != "" && m.connection.Encrypted(chatID) || groupID != "" && m.connection.GroupEncrypted(chatID) {
+        m.err = errors.New(i18n.T("GIFs can't be sent in encrypted conversations"))
+        return
+    }
+    if groupID != "" && m.groupsView != nil && !m.groupsView.canPost() {
+        m.err = errors.New(i18n.T("Only the admins can post in this announcement group"))
+        return
+    }
+
+    if err := m.connection.SendGif(query, newClientID(), recipientID, groupID); err != nil {
+        m.err = err
+        return
+    }
+    m.notice = i18n.T("Looking for a GIF...")
+}
@@ -0,0 +1,137 @@
+// internal/client/tui/group_directory.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// groupDirectory is the browse mode of the groups page, listing the public
+// groups the user can join
+type groupDirectory struct {
+    groups  []models.GroupSummary
+    cursor  int
+    loading bool
+    joining string // group joined from the directory, opened once confirmed
+}
+
+// SetDirectory stores the public groups sent by the server
+func (g *GroupsView) SetDirectory(groups []models.GroupSummary) {
+    g.directory.groups = groups
+    g.directory.loading = false
+    if g.directory.cursor >= len(groups) {
+        g.directory.cursor = 0
+    }
+}
+
+// GroupJoined adds a group joined from the directory and opens it
+func (g *GroupsView) GroupJoined(group models.Group) {
+    g.AddGroup(group)
+    for i := range g.directory.groups {
+        if g.directory.groups[i].ID == group.ID {
+            g.directory.groups[i].IsMember = true
+            g.directory.groups[i].MemberCount = len(group.Members)
+        }
+    }
+
+    if g.mode == GroupBrowseMode && g.directory.joining == group.ID {
+        g.directory.joining = ""
+        g.OpenGroup(group.ID)
+    }
+}
+
+// openDirectory switches to the browse mode and requests the public groups
+func (g *GroupsView) openDirectory() {
+    g.mode = GroupBrowseMode
+    g.directory.loading = true
+    g.directory.joining = ""
+    if g.connection == nil {
+        return
+    }
+    if err := g.connection.LoadGroupDirectory(); err != nil {
+        g.directory.loading = false
+        g.error = i18n.T("Error loading public groups: %v", err)
+    }
+}
+
+// handleDirectoryKey handles the keys of the browse mode
+func (g *GroupsView) handleDirectoryKey(msg tea.KeyMsg) tea.Cmd {
+    dir := &g.directory
+
+    switch {
+    case key.Matches(msg, directoryKeys.Down):
+        if dir.cursor < len(dir.groups)-1 {
+            dir.cursor++
+        }
+    case key.Matches(msg, directoryKeys.Up):
+        if dir.cursor > 0 {
+            dir.cursor--
+        }
+    case key.Matches(msg, directoryKeys.Refresh):
+        g.openDirectory()
+    case key.Matches(msg, directoryKeys.Join):
+        if dir.cursor >= len(dir.groups) {
+            return nil
+        }
+        group := dir.groups[dir.cursor]
+        if group.IsMember {
+            g.OpenGroup(group.ID)
+            return nil
+        }
+        if err := g.connection.JoinGroup(group.ID); err != nil {
+            g.error = i18n.T("Error joining %s: %v", group.Name, err)
+            return nil
+        }
+        dir.joining = group.ID
+    case key.Matches(msg, directoryKeys.Back):
+        g.mode = GroupListMode
+        dir.joining = ""
+    }
+    return nil
+}
+
+func (g *GroupsView) directoryView() string {
+    var sb strings.Builder
+    dir := g.directory
+
+    sb.WriteString(titleStyle.Render(i18n.T("Public groups")))
+    sb.WriteString("\n")
+
+    switch {
+    case dir.loading:
+        sb.WriteString(i18n.T("Loading public groups...\n"))
+    case len(dir.groups) == 0:
+        sb.WriteString(i18n.T("No public group yet. Create one with Ctrl+N and make it public.\n"))
+    }
+
+    for i, group := range dir.groups {
+        icon := "📦"
+        if group.Announcement {
+            icon = "📢"
+        }
+        line := fmt.Sprintf("%s %s - %d members", icon, group.Name, group.MemberCount)
+        switch {
+        case group.ID == dir.joining:
+            line += " " + noticeStyle.Render(i18n.T("joining..."))
+        case group.IsMember:
+            line += " " + successStyle.Render(i18n.T("joined"))
+        }
+        if i == dir.cursor {
+            sb.WriteString(selectionMarkerStyle.Render("▌") + line + "\n")
+        } else {
+            sb.WriteString(" " + line + "\n")
+        }
+        if group.Description != "" {
+            sb.WriteString("   " + sidebarPreviewStyle.Render(group.Description) + "\n")
+        }
+    }
+    sb.WriteString("\n")
+    sb.WriteString(timestampStyleBase.Render(i18n.T("j/k move • enter join/open • r refresh • esc back")))
+
+    return sb.String()
+}
@@ -0,0 +1,268 @@
+// internal/client/tui/group_members.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// memberPanel lists the members of the open group, admins can invite, kick
+// and change the role of members from it
+type memberPanel struct {
+    members  map[string][]models.GroupMember // by group ID
+    cursor   int
+    invite   textinput.Model
+    inviting bool
+    kick     *models.GroupMember // member waiting for the kick confirmation
+}
+
+// groupLevelMsg asks the chat model to change the notification level of a
+// group
+type groupLevelMsg struct {
+    groupID string
+}
+
+func newMemberPanel() memberPanel {
+    invite := textinput.New()
+    invite.Placeholder = i18n.T("Username to invite")
+    invite.CharLimit = 50
+
+    return memberPanel{
+        members: make(map[string][]models.GroupMember),
+        invite:  invite,
+    }
+}
+
+// SetMembers stores the member list sent by the server
+func (g *GroupsView) SetMembers(groupID string, members []models.GroupMember) {
+    g.members.members[groupID] = members
+    if g.members.cursor >= len(members) {
+        g.members.cursor = len(members) - 1
+    }
+    if g.members.cursor < 0 {
+        g.members.cursor = 0
+    }
+}
+
+// RemoveGroup drops a group the user was kicked from
+func (g *GroupsView) RemoveGroup(groupID string) {
+    for i, group := range g.groups {
+        if group.ID == groupID {
+            g.groups = append(g.groups[:i], g.groups[i+1:]...)
+            break
+        }
+    }
+    delete(g.members.members, groupID)
+    delete(g.messages, groupID)
+
+    if g.selectedGroup == groupID {
+        g.mode = GroupListMode
+        g.selectedGroup = ""
+        g.editingID = ""
+        g.selection.Stop()
+        g.input.Reset()
+    }
+    g.updateGroupList()
+}
+
+// openMembers shows the member panel of the open group
+func (g *GroupsView) openMembers() {
+    g.mode = GroupMembersMode
+    g.members.cursor = 0
+    g.input.Blur()
+    if g.connection == nil {
+        return
+    }
+    if err := g.connection.LoadGroupMembers(g.selectedGroup); err != nil {
+        g.error = i18n.T("Error loading members: %v", err)
+    }
+}
+
+// closeMembers goes back to the chat of the group
+func (g *GroupsView) closeMembers() {
+    g.mode = GroupChatMode
+    g.members.inviting = false
+    g.members.kick = nil
+    g.members.invite.Reset()
+    g.members.invite.Blur()
+    g.input.Focus()
+}
+
+// groupCreator returns the ID of the user who created a group
+func (g *GroupsView) groupCreator(groupID string) string {
+    for _, group := range g.groups {
+        if group.ID == groupID {
+            return group.CreatedBy
+        }
+    }
+    return ""
+}
+
+// roleBadge shows the role of a member of a group: the creator owns it and
+// stays admin, the others may be made admins
+func (g *GroupsView) roleBadge(groupID string, member models.GroupMember) string {
+    switch {
+    case member.UserID == g.groupCreator(groupID):
+        return " " + ownerBadgeStyle.Render(" "+i18n.T("owner")+" ")
+    case member.Role == models.GroupRoleAdmin:
+        return " " + roleBadgeStyle.Render(" "+i18n.T("admin")+" ")
+    }
+    return ""
+}
+
+func (g *GroupsView) isGroupAdmin() bool {
+    for _, member := range g.members.members[g.selectedGroup] {
+        if member.UserID == g.userID {
+            return member.Role == models.GroupRoleAdmin
+        }
+    }
+    return false
+}
+
+// handleMembersKey handles the keys of the member panel
+func (g *GroupsView) handleMembersKey(msg tea.KeyMsg) tea.Cmd {
+    panel := &g.members
+    members := panel.members[g.selectedGroup]
+
+    if panel.kick != nil {
+        member := *panel.kick
+        panel.kick = nil
+        if msg.String() == "y" {
+            if err := g.connection.KickFromGroup(g.selectedGroup, member.UserID); err != nil {
+                g.error = i18n.T("Error kicking %s: %v", member.Username, err)
+            }
+        }
+        return nil
+    }
+
+    if panel.inviting {
+        switch msg.String() {
+        case "enter":
+            if username := strings.TrimSpace(panel.invite.Value()); username != "" {
+                if err := g.connection.InviteToGroup(g.selectedGroup, username); err != nil {
+                    g.error = i18n.T("Error inviting %s: %v", username, err)
+                }
+            }
+            fallthrough
+        case "esc":
+            panel.inviting = false
+            panel.invite.Reset()
+            panel.invite.Blur()
+            return nil
+        }
+        var cmd tea.Cmd
+        panel.invite, cmd = panel.invite.Update(msg)
+        return cmd
+    }
+
+    admin := g.isGroupAdmin()
+    var selected *models.GroupMember
+    if panel.cursor < len(members) {
+        selected = &members[panel.cursor]
+    }
+
+    switch {
+    case key.Matches(msg, memberKeys.Down):
+        if panel.cursor < len(members)-1 {
+            panel.cursor++
+        }
+    case key.Matches(msg, memberKeys.Up):
+        if panel.cursor > 0 {
+            panel.cursor--
+        }
+    case key.Matches(msg, memberKeys.Invite):
+        if admin {
+            panel.inviting = true
+            return panel.invite.Focus()
+        }
+    case key.Matches(msg, memberKeys.Kick):
+        if admin && selected != nil && selected.UserID != g.userID {
+            member := *selected
+            panel.kick = &member
+        }
+    case key.Matches(msg, memberKeys.Role):
+        if admin && selected != nil && selected.UserID == g.groupCreator(g.selectedGroup) {
+            g.error = i18n.T("The creator of the group stays admin")
+        } else if admin && selected != nil && selected.UserID != g.userID {
+            role := models.GroupRoleAdmin
+            if selected.Role == models.GroupRoleAdmin {
+                role = models.GroupRoleMember
+            }
+            if err := g.connection.SetGroupRole(g.selectedGroup, selected.UserID, role); err != nil {
+                g.error = i18n.T("Error changing role: %v", err)
+            }
+        }
+    case key.Matches(msg, memberKeys.Notify):
+        groupID := g.selectedGroup
+        return func() tea.Msg {
+            return groupLevelMsg{groupID: groupID}
+        }
+    case key.Matches(msg, memberKeys.Back):
+        g.closeMembers()
+    }
+    return nil
+}
+
+// notificationLevel returns the notification level of a group
+func (g *GroupsView) notificationLevel(groupID string) string {
+    if level, ok := g.levels[groupID]; ok {
+        return level
+    }
+    return config.LevelAll
+}
+
+func (g *GroupsView) membersView() string {
+    var sb strings.Builder
+    panel := g.members
+    members, loaded := panel.members[g.selectedGroup]
+
+    name := g.selectedGroup
+    for _, group := range g.groups {
+        if group.ID == g.selectedGroup {
+            name = group.Name
+        }
+    }
+    sb.WriteString(titleStyle.Render(i18n.T("Members of %s (%d)", name, len(members))))
+    sb.WriteString("\n")
+    sb.WriteString(timestampStyleBase.Render(i18n.T("Notifications: %s", i18n.T(g.notificationLevel(g.selectedGroup)))))
+    sb.WriteString("\n")
+
+    if !loaded {
+        sb.WriteString(i18n.T("Loading members...\n"))
+    }
+    for i, member := range members {
+        line := fmt.Sprintf("%s %s", statusIcon(member.Status), member.Username)
+        if member.UserID == g.userID {
+            line += " (you)"
+        }
+        line += g.roleBadge(g.selectedGroup, member)
+        if i == panel.cursor {
+            sb.WriteString(selectionMarkerStyle.Render("▌") + line + "\n")
+        } else {
+            sb.WriteString(" " + line + "\n")
+        }
+    }
+    sb.WriteString("\n")
+
+    switch {
+    case panel.kick != nil:
+        sb.WriteString(noticeStyle.Render(i18n.T("Kick %s from the group? [y/n]", panel.kick.Username)))
+    case panel.inviting:
+        sb.WriteString(panel.invite.View())
+        sb.WriteString("\n")
+        sb.WriteString(timestampStyleBase.Render(i18n.T("enter invite • esc cancel")))
+    case g.isGroupAdmin():
+        sb.WriteString(timestampStyleBase.Render(i18n.T("j/k move • i invite • x kick • r toggle admin • n notifications • esc back to chat")))
+    default:
+        sb.WriteString(timestampStyleBase.Render(i18n.T("j/k move • n notifications • esc back to chat")))
+    }
+
+    return sb.String()
+}
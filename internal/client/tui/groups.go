@@ -3,10 +3,16 @@ package tui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
 	"textual/internal/client/models"
 	"textual/internal/client/network"
+	"textual/pkg/protocol"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -21,6 +27,8 @@ const (
     GroupListMode GroupMode = iota
     GroupChatMode
     GroupCreateMode
+    GroupMembersMode
+    GroupBrowseMode
 )
 
 type GroupsView struct {
@@ -33,36 +41,56 @@ type GroupsView struct {
     width           int
     height          int
     style           lipgloss.Style
-    onSendMessage   func(string, *string, *string) error
     list            list.Model
     groups          []models.Group
     mode            GroupMode
     connection      *network.ConnectionHandler
     userID          string
+    username        string
+    nameLookup      func(string) (string, bool)
+    completer       MentionCompleter
+    commands        CommandCompleter
+    selection       messageSelection
+    editingID       string
+    members         memberPanel
+    directory       groupDirectory
+    public          bool // visibility of the group being created
+    encrypted       bool // end-to-end encryption of the group being created
+    announcement    bool // only the admins post in the group being created
+    typing          *typingTracker
+    firstUnread     map[string]string // shared with the chat model
+    threadUnread    map[string]int    // shared with the chat model
+    drafts          *draftStore
+    history         *historyCache
+    index           *messageIndex // of g.messages, for the group list
     focused         bool
     activeInput     int // 0: list, 1: input
     error           string
     loading         bool
+    historyLoaded   map[string]bool
+    pageSize        int // messages of the first page of a group
+    levels          map[string]string // notification levels set by the chat model, all when missing
+    offline         bool // connection lost, sending is paused
 }
 
-func NewGroupsView(onSendMessage func(string, *string, *string) error, connection *network.ConnectionHandler) *GroupsView {
+func NewGroupsView(connection *network.ConnectionHandler) *GroupsView {
     input := textinput.New()
-    input.Placeholder = "Type a message..."
+    input.Placeholder = i18n.T("Type a message...")
     input.CharLimit = 500
 
     nameInput := textinput.New()
-    nameInput.Placeholder = "Group name"
+    nameInput.Placeholder = i18n.T("Group name")
     nameInput.CharLimit = 50
 
     descInput := textinput.New()
-    descInput.Placeholder = "Group description"
+    descInput.Placeholder = i18n.T("Group description")
     descInput.CharLimit = 200
 
     delegate := list.NewDefaultDelegate()
     delegate.ShowDescription = true
 
     l := list.New(nil, delegate, 0, 0)
-    l.Title = "Groups"
+    l.Title = i18n.T("Groups")
     l.SetShowStatusBar(false)
     l.SetFilteringEnabled(false)
     l.Styles.Title = titleStyle
@@ -73,19 +101,31 @@ func NewGroupsView(onSendMessage func(string, *string, *string) error, connectio
         nameInput:     nameInput,
         descInput:     descInput,
         messages:      make(map[string][]models.Message),
+        index:         newMessageIndex(),
         style:         lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1),
-        onSendMessage: onSendMessage,
         list:          l,
         groups:        make([]models.Group, 0),
         mode:          GroupListMode,
         connection:    connection,
         focused:       false,
         activeInput:   0,
+        historyLoaded: make(map[string]bool),
+        members:       newMemberPanel(),
     }
 }
 
 func (g *GroupsView) SetUserID(userID string) {
     g.userID = userID
+    g.index.SetUserID(userID)
+}
+
+func (g *GroupsView) SetUsername(username string) {
+    g.username = username
+}
+
+// SetNameLookup sets the function resolving member IDs to usernames
+func (g *GroupsView) SetNameLookup(lookup func(string) (string, bool)) {
+    g.nameLookup = lookup
 }
 
 func (g *GroupsView) Update(msg tea.Msg) tea.Cmd {
@@ -93,8 +133,68 @@ func (g *GroupsView) Update(msg tea.Msg) tea.Cmd {
 
     switch msg := msg.(type) {
     case tea.KeyMsg:
-        switch msg.String() {
-        case "ctrl+n":
+        if g.mode == GroupMembersMode {
+            return g.handleMembersKey(msg)
+        }
+        if g.mode == GroupBrowseMode {
+            return g.handleDirectoryKey(msg)
+        }
+
+        if g.mode == GroupChatMode && g.selection.active {
+            return g.handleSelectionKey(msg)
+        }
+
+        if g.mode == GroupChatMode && g.commands.Active() && g.commands.HandleKey(msg.String(), &g.input) {
+            return nil
+        }
+
+        if g.mode == GroupChatMode && g.completer.Active() && g.completer.HandleKey(msg.String(), &g.input) {
+            return nil
+        }
+
+        // up in an empty input edits the last message sent in the group
+        if g.mode == GroupChatMode && key.Matches(msg, chatKeys.EditLast) && g.input.Value() == "" && g.editingID == "" {
+            if last, ok := lastOwnMessage(g.messages[g.selectedGroup], g.userID); ok {
+                g.startEditing(last)
+                return nil
+            }
+        }
+
+        switch {
+        case key.Matches(msg, groupChatKeys.Members):
+            if g.mode == GroupChatMode {
+                g.openMembers()
+                return nil
+            }
+
+        case key.Matches(msg, groupListKeys.Browse):
+            if g.mode == GroupListMode {
+                g.openDirectory()
+                return nil
+            }
+
+        case key.Matches(msg, groupCreateKeys.Public):
+            if g.mode == GroupCreateMode {
+                g.public = !g.public
+                return nil
+            }
+
+        case key.Matches(msg, groupCreateKeys.Encrypted):
+            if g.mode == GroupCreateMode {
+                g.encrypted = !g.encrypted
+                // the server must read the messages to check who posts
+                g.announcement = g.announcement && !g.encrypted
+                return nil
+            }
+
+        case key.Matches(msg, groupCreateKeys.Announcement):
+            if g.mode == GroupCreateMode {
+                g.announcement = !g.announcement
+                g.encrypted = g.encrypted && !g.announcement
+                return nil
+            }
+
+        case key.Matches(msg, groupListKeys.Create):
             if g.mode == GroupListMode {
                 g.mode = GroupCreateMode
                 g.nameInput.Focus()
@@ -102,21 +202,31 @@ func (g *GroupsView) Update(msg tea.Msg) tea.Cmd {
                 return nil
             }
 
-        case "esc":
+        case key.Matches(msg, chatKeys.Select, groupCreateKeys.Cancel):
             switch g.mode {
             case GroupChatMode:
-                g.mode = GroupListMode
-                g.selectedGroup = ""
-                g.input.Reset()
-                g.updateGroupList()
+                if g.editingID != "" {
+                    g.editingID = ""
+                    g.input.Reset()
+                    return nil
+                }
+                // first esc selects the messages, the second one leaves the chat
+                if g.selection.Start(g.messages[g.selectedGroup]) {
+                    g.input.Blur()
+                    return nil
+                }
+                g.closeGroup()
             case GroupCreateMode:
                 g.mode = GroupListMode
                 g.nameInput.Reset()
                 g.descInput.Reset()
+                g.public = false
+                g.encrypted = false
+                g.announcement = false
             }
             return nil
 
-        case "tab":
+        case key.Matches(msg, groupCreateKeys.NextField):
             switch g.mode {
             case GroupCreateMode:
                 if g.activeInput == 0 {
@@ -131,28 +241,51 @@ func (g *GroupsView) Update(msg tea.Msg) tea.Cmd {
                 return nil
             }
 
-        case "enter":
+        case key.Matches(msg, groupListKeys.Open, chatKeys.Send, groupCreateKeys.Create):
             switch g.mode {
             case GroupListMode:
                 if item, ok := g.list.SelectedItem().(groupItem); ok {
-                    g.selectedGroup = item.group.ID
-                    g.mode = GroupChatMode
-                    g.input.Focus()
-                    g.updateContent()
+                    g.OpenGroup(item.group.ID)
                 }
                 return nil
 
             case GroupChatMode:
+                if !g.canPost() && g.editingID == "" && !strings.HasPrefix(g.input.Value(), "/") {
+                    if g.input.Value() != "" {
+                        g.error = i18n.T("Only the admins can post in this announcement group")
+                    }
+                    return nil
+                }
+                if g.offline && g.input.Value() != "" && !strings.HasPrefix(g.input.Value(), "/") {
+                    g.error = i18n.T("Offline: the message stays in the input until the connection is back")
+                    return nil
+                }
+                if g.input.Value() != "" && g.editingID != "" {
+                    if err := g.connection.EditMessage(g.editingID, g.input.Value()); err != nil {
+                        g.error = i18n.T("Error editing message: %v", err)
+                    }
+                    g.editingID = ""
+                    g.input.Reset()
+                    return nil
+                }
+                // commands run in the chat model, "//" sends a message starting with "/"
+                if strings.HasPrefix(g.input.Value(), "/") && !strings.HasPrefix(g.input.Value(), "//") {
+                    cmd := sendCommand(g.input.Value())
+                    g.input.Reset()
+                    g.commands.Dismiss()
+                    return cmd
+                }
                 if g.input.Value() != "" {
                     content := g.input.Value()
-                    if g.onSendMessage != nil {
-                        if err := g.onSendMessage(content, nil, &g.selectedGroup); err != nil {
-                            g.error = fmt.Sprintf("Error sending message: %v", err)
-                        } else {
-                            g.input.Reset()
-                            g.viewport.GotoBottom()
-                        }
+                    if strings.HasPrefix(content, "//") {
+                        content = content[1:]
                     }
+                    // the chat model shows the message as pending and sends it
+                    groupID := g.selectedGroup
+                    message := newPendingMessage(g.userID, g.username, content, nil, &groupID)
+                    g.input.Reset()
+                    g.viewport.GotoBottom()
+                    return func() tea.Msg { return pendingSendMsg{message: message} }
                 }
                 return nil
 
@@ -160,12 +293,15 @@ func (g *GroupsView) Update(msg tea.Msg) tea.Cmd {
                 if g.nameInput.Value() != "" {
                     name := g.nameInput.Value()
                     desc := g.descInput.Value()
-                    if err := g.connection.CreateGroup(name, desc); err != nil {
-                        g.error = fmt.Sprintf("Error creating group: %v", err)
+                    if err := g.connection.CreateGroup(name, desc, g.public, g.encrypted, g.announcement); err != nil {
+                        g.error = i18n.T("Error creating group: %v", err)
                     } else {
                         g.mode = GroupListMode
                         g.nameInput.Reset()
                         g.descInput.Reset()
+                        g.public = false
+                        g.encrypted = false
+                        g.announcement = false
                         g.loading = true
                         // Group will be added when server confirms creation
                     }
@@ -179,7 +315,13 @@ func (g *GroupsView) Update(msg tea.Msg) tea.Cmd {
         case GroupChatMode:
             if g.input.Focused() {
                 var cmd tea.Cmd
+                before := g.input.Value()
                 g.input, cmd = g.input.Update(msg)
+                g.completer.Refresh(g.input, g.mentionCandidates())
+                g.commands.Refresh(g.input)
+                if value := g.input.Value(); value != before && value != "" && g.editingID == "" && !strings.HasPrefix(value, "/") {
+                    g.sendTyping()
+                }
                 return cmd
             }
         case GroupCreateMode:
@@ -223,38 +365,144 @@ func (g *GroupsView) View() string {
     switch g.mode {
     case GroupListMode:
         if g.loading {
-            sb.WriteString("Loading groups...\n")
+            sb.WriteString(i18n.T("Loading groups...\n"))
         } else {
             sb.WriteString(g.list.View())
-            sb.WriteString("\n\nPress Ctrl+N to create a new group")
+            sb.WriteString("\n\nPress Ctrl+N to create a new group, Ctrl+B to browse public groups")
         }
 
+    case GroupBrowseMode:
+        sb.WriteString(g.directoryView())
+
     case GroupChatMode:
+        info := g.selectedGroupInfo()
+        if info.Announcement {
+            sb.WriteString(announcementStyle.Render(i18n.T("📢 Announcements")) + " " +
+                timestampStyleBase.Render(i18n.T("only the admins post here")) + "\n")
+        }
+        if info.Topic != "" {
+            sb.WriteString(topicStyle.Render(i18n.T("Topic: %s", info.Topic)) + "\n")
+        }
         if messages, ok := g.messages[g.selectedGroup]; ok {
+            var prev time.Time
             for _, msg := range messages {
-                timestamp := msg.SentAt.Format("15:04:05")
+                sb.WriteString(daySeparator(prev, msg.SentAt, g.width-4))
+                prev = msg.SentAt
+                if id, ok := g.firstUnread[g.selectedGroup]; ok && id == msg.ID {
+                    sb.WriteString(renderDivider(g.width - 4) + "\n")
+                }
+                timestamp := messageTime(msg.SentAt, false)
+                if msg.IsSystem() {
+                    line := timestampStyle.Render(timestamp) + " " + systemMessageStyle.Render(systemText(*msg.System)) + "\n"
+                    if g.selection.IsSelected(msg) {
+                        line = selectionMarkerStyle.Render("▌") + line
+                    } else if g.selection.active {
+                        line = " " + line
+                    }
+                    sb.WriteString(line)
+                    continue
+                }
                 senderName := msg.SenderName
                 if msg.SenderID == g.userID {
-                    senderName = "You"
+                    senderName = i18n.T("You")
                 }
                 
-                line := fmt.Sprintf("%s %s: %s\n",
-                    timestampStyle.Render(timestamp),
-                    usernameStyle.Render(senderName),
-                    contentStyle.Render(msg.Content))
+                textStyle := lipgloss.NewStyle()
+                if info.Announcement {
+                    textStyle = announcementStyle
+                }
+                if msg.SenderID != g.userID && mentionsUser(msg.Content, g.username) {
+                    textStyle = mentionStyle
+                }
+                content := renderContent(msg, textStyle)
+                if msg.IsEdited() {
+                    content += editedStyle.Render(" (edited)")
+                }
+                content = renderSendState(msg, content)
+                prefix := fmt.Sprintf("%s %s: ", timestampStyle.Render(timestamp), renderSender(msg.SenderID, senderName))
+                indent := textWidth(prefix)
+                if g.selection.active {
+                    indent++ // selection marker
+                }
+                line := prefix + wrapMessage(content, g.width-4, indent) + "\n"
+                line += renderLinkPreview(msg, g.width-4, indent)
+                if replies := renderReplies(msg, g.threadUnread[msg.ID]); replies != "" {
+                    line += strings.Repeat(" ", indent) + replies + "\n"
+                }
+                if g.selection.IsSelected(msg) {
+                    line = selectionMarkerStyle.Render("▌") + line
+                } else if g.selection.active {
+                    line = " " + line
+                }
                 sb.WriteString(line)
             }
         }
+        if g.typing != nil {
+            sb.WriteString(editedStyle.Render(g.typing.Indicator(g.selectedGroup)))
+        }
         sb.WriteString("\n")
-        sb.WriteString(g.input.View())
+        if g.selection.active {
+            sb.WriteString(selectionHelp())
+            break
+        }
+        if suggestions := g.commands.View(); suggestions != "" {
+            sb.WriteString(suggestions)
+            sb.WriteString("\n")
+        }
+        if suggestions := g.completer.View(); suggestions != "" {
+            sb.WriteString(suggestions)
+            sb.WriteString("\n")
+        }
+        if g.editingID != "" {
+            sb.WriteString(editedStyle.Render(i18n.T("Editing message • enter to save • esc to cancel")))
+            sb.WriteString("\n")
+        }
+        if g.offline {
+            sb.WriteString(editedStyle.Render(i18n.T("Offline, sending is paused until the connection is back")))
+            sb.WriteString("\n")
+            sb.WriteString(timestampStyleBase.Render(g.input.Prompt + g.input.Value()))
+        } else if !g.canPost() && g.editingID == "" {
+            // commands still run from the input
+            placeholder := g.input.Placeholder
+            g.input.Placeholder = i18n.T("Read only, type / for a command")
+            sb.WriteString(g.input.View())
+            g.input.Placeholder = placeholder
+        } else {
+            sb.WriteString(g.input.View())
+        }
+        sb.WriteString("\n")
+        if g.selectedEncrypted() {
+            sb.WriteString(successStyle.Render(i18n.T("🔒 encrypted")) + " ")
+        } else {
+            sb.WriteString(timestampStyleBase.Render(i18n.T("not encrypted")) + " ")
+        }
+        sb.WriteString(timestampStyleBase.Render(i18n.T("ctrl+p members")))
+
+    case GroupMembersMode:
+        sb.WriteString(g.membersView())
 
     case GroupCreateMode:
-        sb.WriteString("Create New Group\n\n")
-        sb.WriteString("Name:\n")
+        sb.WriteString(i18n.T("Create New Group\n\n"))
+        sb.WriteString(i18n.T("Name:\n"))
         sb.WriteString(g.nameInput.View())
-        sb.WriteString("\n\nDescription:\n")
+        sb.WriteString(i18n.T("\n\nDescription:\n"))
         sb.WriteString(g.descInput.View())
-        sb.WriteString("\n\nPress Enter to create, Esc to cancel")
+        visibility := i18n.T("[ ] Public (listed in the group directory)")
+        if g.public {
+            visibility = i18n.T("[x] Public (listed in the group directory)")
+        }
+        sb.WriteString("\n\n" + visibility + "  " + timestampStyleBase.Render(i18n.T("ctrl+t toggle")))
+        encryption := i18n.T("[ ] Encrypted (members need encryption enabled, no history on the server)")
+        if g.encrypted {
+            encryption = i18n.T("[x] Encrypted (members need encryption enabled, no history on the server)")
+        }
+        sb.WriteString("\n" + encryption + "  " + timestampStyleBase.Render(i18n.T("ctrl+x toggle")))
+        announcement := i18n.T("[ ] Announcements (only the admins post, not encrypted)")
+        if g.announcement {
+            announcement = i18n.T("[x] Announcements (only the admins post, not encrypted)")
+        }
+        sb.WriteString("\n" + announcement + "  " + timestampStyleBase.Render(i18n.T("ctrl+y toggle")))
+        sb.WriteString(i18n.T("\n\nPress Enter to create, Esc to cancel"))
     }
 
     return g.style.Render(sb.String())
@@ -269,56 +517,371 @@ func (g *GroupsView) AddMessage(msg models.Message) {
     if g.messages[groupID] == nil {
         g.messages[groupID] = make([]models.Message, 0)
     }
+
+    g.messages[groupID] = replacePending(g.messages[groupID], msg)
+    for _, existing := range g.messages[groupID] {
+        if msg.ID != "" && existing.ID == msg.ID {
+            if groupID == g.selectedGroup {
+                g.updateContent()
+            }
+            return
+        }
+    }
     
     g.messages[groupID] = append(g.messages[groupID], msg)
+    sort.SliceStable(g.messages[groupID], func(i, j int) bool {
+        return g.messages[groupID][i].SentAt.Before(g.messages[groupID][j].SentAt)
+    })
     
     if groupID == g.selectedGroup {
         g.updateContent()
     }
 }
 
+// UpdateMessage applies an edit received from the server
+func (g *GroupsView) UpdateMessage(msg models.Message) {
+    if msg.GroupID == nil {
+        return
+    }
+    messages := g.messages[*msg.GroupID]
+    if i, ok := g.index.Position(*msg.GroupID, messages, msg.ID); ok {
+        messages[i].Content = msg.Content
+        messages[i].EditedAt = msg.EditedAt
+        messages[i].Preview = msg.Preview
+        g.index.Invalidate(*msg.GroupID)
+    }
+    if *msg.GroupID == g.selectedGroup {
+        g.updateContent()
+    }
+}
+
 func (g *GroupsView) updateContent() {
     if g.selectedGroup == "" {
         return
     }
 
     var content strings.Builder
+    var prev time.Time
     for _, msg := range g.messages[g.selectedGroup] {
-        timestamp := msg.SentAt.Format("15:04:05")
+        content.WriteString(daySeparator(prev, msg.SentAt, g.width-4))
+        prev = msg.SentAt
+        timestamp := messageTime(msg.SentAt, false)
+        if msg.IsSystem() {
+            content.WriteString(fmt.Sprintf("%s %s\n", timestamp, systemMessageStyle.Render(systemText(*msg.System))))
+            continue
+        }
         sender := msg.SenderName
         if msg.SenderID == g.userID {
-            sender = "You"
+            sender = i18n.T("You")
+        }
+        textStyle := lipgloss.NewStyle()
+        if msg.SenderID != g.userID && mentionsUser(msg.Content, g.username) {
+            textStyle = mentionStyle
         }
+        text := renderSendState(msg, renderContent(msg, textStyle))
         content.WriteString(fmt.Sprintf("%s %s: %s\n",
             timestamp,
             sender,
-            msg.Content))
+            text))
+        content.WriteString(renderLinkPreview(msg, g.width-4, 2))
+        if replies := renderReplies(msg, g.threadUnread[msg.ID]); replies != "" {
+            content.WriteString("  " + replies + "\n")
+        }
     }
     
     g.viewport.SetContent(content.String())
     g.viewport.GotoBottom()
 }
 
+// systemText describes an event of a group
+func systemText(event models.SystemEvent) string {
+    switch event.Kind {
+    case protocol.SystemJoined:
+        return i18n.T("%s joined the group", event.Actor)
+    case protocol.SystemLeft:
+        return i18n.T("%s left the group", event.Actor)
+    case protocol.SystemAdded:
+        return i18n.T("%s added %s", event.Actor, event.Target)
+    case protocol.SystemRemoved:
+        return i18n.T("%s removed %s", event.Actor, event.Target)
+    case protocol.SystemPromoted:
+        return i18n.T("%s made %s an admin", event.Actor, event.Target)
+    case protocol.SystemDemoted:
+        return i18n.T("%s made %s a member", event.Actor, event.Target)
+    case protocol.SystemTopic:
+        if event.Target == "" {
+            return i18n.T("%s cleared the topic", event.Actor)
+        }
+        return i18n.T("%s set the topic: %s", event.Actor, event.Target)
+    }
+    return strings.TrimSpace(fmt.Sprintf("%s %s %s", event.Actor, event.Kind, event.Target))
+}
+
 func (g *GroupsView) SetGroups(groups []models.Group) {
     g.groups = groups
     g.loading = false
     g.updateGroupList()
 }
 
+// AddGroup adds a newly created or joined group to the list
+func (g *GroupsView) AddGroup(group models.Group) {
+    g.loading = false
+    for i, existing := range g.groups {
+        if existing.ID == group.ID {
+            g.groups[i] = group
+            g.updateGroupList()
+            return
+        }
+    }
+    g.groups = append(g.groups, group)
+    g.updateGroupList()
+}
+
+// SetTopic updates the topic shown above the chat of a group
+func (g *GroupsView) SetTopic(groupID, topic string) {
+    for i := range g.groups {
+        if g.groups[i].ID == groupID {
+            g.groups[i].Topic = topic
+        }
+    }
+}
+
+// Topic returns the topic of the open group
+func (g *GroupsView) Topic() string {
+    return g.selectedGroupInfo().Topic
+}
+
+// OpenGroup switches to the chat of a group
+func (g *GroupsView) OpenGroup(groupID string) {
+    if g.drafts != nil {
+        from := g.ActiveGroup()
+        if g.editingID != "" {
+            from = ""
+        }
+        g.input.SetValue(g.drafts.Swap(from, groupID, g.input.Value()))
+        g.input.CursorEnd()
+        g.drafts.Save()
+    }
+    g.selectedGroup = groupID
+    g.mode = GroupChatMode
+    g.selection.Stop()
+    g.editingID = ""
+    g.input.Focus()
+    g.history.Open(groupID)
+    g.loadHistory(groupID)
+    g.updateContent()
+}
+
+// closeGroup goes back to the group list, keeping the input as a draft
+func (g *GroupsView) closeGroup() {
+    g.saveDraft()
+    if g.drafts != nil {
+        g.drafts.Save()
+    }
+    g.mode = GroupListMode
+    g.selectedGroup = ""
+    g.editingID = ""
+    g.input.Reset()
+    g.updateGroupList()
+}
+
+// saveDraft stores the input of the open group
+func (g *GroupsView) saveDraft() {
+    if g.drafts == nil || g.editingID != "" || g.ActiveGroup() == "" {
+        return
+    }
+    g.drafts.Swap(g.selectedGroup, g.selectedGroup, g.input.Value())
+}
+
+// ActiveGroup returns the ID of the open group chat, or "" when none is open
+func (g *GroupsView) ActiveGroup() string {
+    if g.mode != GroupChatMode && g.mode != GroupMembersMode {
+        return ""
+    }
+    return g.selectedGroup
+}
+
+// sendTyping announces to the group that the user is typing
+func (g *GroupsView) sendTyping() {
+    if g.connection == nil || g.typing == nil || !g.typing.ShouldSend(g.selectedGroup) {
+        return
+    }
+    if err := g.connection.SendTyping("", g.selectedGroup); err != nil {
+        logging.Warnf("Failed to send typing notification: %v", err)
+    }
+}
+
+// startEditing loads one of the user's messages in the input
+func (g *GroupsView) startEditing(msg models.Message) {
+    g.editingID = msg.ID
+    g.input.SetValue(msg.Content)
+    g.input.CursorEnd()
+    g.input.Focus()
+}
+
+// handleSelectionKey runs the selection mode actions in the open group
+func (g *GroupsView) handleSelectionKey(msg tea.KeyMsg) tea.Cmd {
+    messages := g.messages[g.selectedGroup]
+
+    switch {
+    case key.Matches(msg, selectionKeys.Down):
+        g.selection.Move(messages, 1)
+    case key.Matches(msg, selectionKeys.Up):
+        g.selection.Move(messages, -1)
+    case key.Matches(msg, selectionKeys.First):
+        g.selection.Move(messages, -len(messages))
+    case key.Matches(msg, selectionKeys.Last):
+        g.selection.Move(messages, len(messages))
+
+    case key.Matches(msg, selectionKeys.OpenLink):
+        if selected, ok := g.selection.Selected(messages); ok {
+            if _, err := openMessageLink(selected); err != nil {
+                g.error = err.Error()
+            }
+        }
+
+    case key.Matches(msg, selectionKeys.Copy, selectionKeys.CopyFull):
+        if selected, ok := g.selection.Selected(messages); ok {
+            if err := copyMessage(selected, key.Matches(msg, selectionKeys.CopyFull)); err != nil {
+                g.error = err.Error()
+            }
+        }
+
+    case key.Matches(msg, selectionKeys.Link):
+        if selected, ok := g.selection.Selected(messages); ok {
+            // the encrypted groups have no copy on the server
+            if g.selectedEncrypted() {
+                g.error = i18n.T("This message has no link")
+            } else if err := copyMessageLink(selected); err != nil {
+                g.error = err.Error()
+            }
+        }
+
+    case key.Matches(msg, selectionKeys.Edit):
+        if selected, ok := g.selection.Selected(messages); ok && selected.SenderID == g.userID {
+            g.selection.Stop()
+            g.startEditing(selected)
+        }
+
+    case key.Matches(msg, selectionKeys.Retry):
+        if selected, ok := g.selection.Selected(messages); ok && selected.SendState == models.SendFailed {
+            return func() tea.Msg { return retryMsg{message: selected} }
+        }
+
+    case key.Matches(msg, selectionKeys.Thread):
+        if selected, ok := g.selection.Selected(messages); ok {
+            g.selection.Stop()
+            return openThreadCmd(selected)
+        }
+
+    case key.Matches(msg, selectionKeys.History):
+        if selected, ok := g.selection.Selected(messages); ok {
+            if err := requestRevisions(g.connection, selected); err != nil {
+                g.error = err.Error()
+            }
+        }
+
+    case key.Matches(msg, selectionKeys.Input):
+        g.selection.Stop()
+        g.input.Focus()
+
+    case key.Matches(msg, selectionKeys.Leave):
+        g.selection.Stop()
+        g.closeGroup()
+    }
+    return nil
+}
+
+// SelectMessage selects a message of the open group, it returns false when
+// the message is not loaded
+func (g *GroupsView) SelectMessage(messageID string) bool {
+    for _, msg := range g.messages[g.selectedGroup] {
+        if msg.ID == messageID {
+            g.selection.Select(messageID)
+            g.input.Blur()
+            g.updateContent()
+            return true
+        }
+    }
+    return false
+}
+
+// selectedEncrypted tells if the messages of the open group are encrypted
+func (g *GroupsView) selectedEncrypted() bool {
+    return g.selectedGroupInfo().Encrypted
+}
+
+// selectedGroupInfo returns the open group, empty when unknown
+func (g *GroupsView) selectedGroupInfo() models.Group {
+    for _, group := range g.groups {
+        if group.ID == g.selectedGroup {
+            return group
+        }
+    }
+    return models.Group{}
+}
+
+// canPost tells if the user can write in the open group, the server checks
+// it again
+func (g *GroupsView) canPost() bool {
+    return g.selectedGroupInfo().CanPost(g.userID)
+}
+
+// mentionCandidates returns the usernames of the members of the open group
+func (g *GroupsView) mentionCandidates() []string {
+    var names []string
+    for _, group := range g.groups {
+        if group.ID != g.selectedGroup || g.nameLookup == nil {
+            continue
+        }
+        for _, memberID := range group.Members {
+            if name, ok := g.nameLookup(memberID); ok {
+                names = append(names, name)
+            }
+        }
+    }
+    for _, member := range g.members.members[g.selectedGroup] {
+        names = append(names, member.Username)
+    }
+    // members whose name is only known from their messages
+    for _, msg := range g.messages[g.selectedGroup] {
+        names = append(names, msg.SenderName)
+    }
+    return mentionCandidates(names, g.username)
+}
+
+// loadHistory requests the latest messages of a group the first time it is opened
+func (g *GroupsView) loadHistory(groupID string) {
+    if g.historyLoaded[groupID] || g.connection == nil {
+        return
+    }
+
+    if err := g.connection.LoadConversation("", groupID, "", g.pageSize); err != nil {
+        g.error = i18n.T("Error loading messages: %v", err)
+        return
+    }
+    // the topic changes go among the messages
+    if err := g.connection.LoadTopicHistory(groupID); err != nil {
+        g.error = i18n.T("Error loading messages: %v", err)
+        return
+    }
+    g.historyLoaded[groupID] = true
+}
+
 func (g *GroupsView) updateGroupList() {
     var items []list.Item
     for _, group := range g.groups {
         var lastMsg string
-        var unreadCount int
-        
-        if messages, ok := g.messages[group.ID]; ok && len(messages) > 0 {
-            lastMsg = messages[len(messages)-1].Content
-            for _, msg := range messages {
-                if !msg.Read && msg.SenderID != g.userID {
-                    unreadCount++
-                }
+        messages := g.messages[group.ID]
+        if last, ok := g.index.Last(group.ID, messages); ok {
+            // on the one line of the description
+            text := last.Content
+            if last.IsSystem() {
+                text = systemText(*last.System)
             }
+            lastMsg = truncateWidth(strings.Join(strings.Fields(text), " "), maxPreviewWidth)
         }
+        unreadCount := g.index.Unread(group.ID, messages)
 
         items = append(items, groupItem{
             group:       group,
@@ -330,6 +893,9 @@ func (g *GroupsView) updateGroupList() {
     g.list.SetItems(items)
 }
 
+// the last message shown under the name of a group, on a line
+const maxPreviewWidth = 40
+
 type groupItem struct {
     group       models.Group
     lastMsg     string
@@ -337,17 +903,25 @@ type groupItem struct {
 }
 
 func (i groupItem) Title() string {
+    name := i.group.Name
+    if i.group.Encrypted {
+        name += " 🔒"
+    }
+    icon := "📦"
+    if i.group.Announcement {
+        icon = "📢"
+    }
     if i.unreadCount > 0 {
-        return fmt.Sprintf("📦 %s (%d unread)", i.group.Name, i.unreadCount)
+        return fmt.Sprintf("%s %s (%d unread)", icon, name, i.unreadCount)
     }
-    return fmt.Sprintf("📦 %s", i.group.Name)
+    return fmt.Sprintf("%s %s", icon, name)
 }
 
 func (i groupItem) Description() string {
     if i.lastMsg != "" {
-        return fmt.Sprintf("Last message: %s - %d members", i.lastMsg, len(i.group.Members))
+        return i18n.T("Last message: %s - %d members", i.lastMsg, len(i.group.Members))
     }
-    return fmt.Sprintf("%d members", len(i.group.Members))
+    return i18n.T("%d members", len(i.group.Members))
 }
 
 func (i groupItem) FilterValue() string {
@@ -369,6 +943,10 @@ func (g *GroupsView) Focus() {
     switch g.mode {
     case GroupChatMode:
         g.input.Focus()
+    case GroupMembersMode:
+        if g.members.inviting {
+            g.members.invite.Focus()
+        }
     case GroupCreateMode:
         if g.activeInput == 0 {
             g.nameInput.Focus()
@@ -381,6 +959,7 @@ func (g *GroupsView) Focus() {
 func (g *GroupsView) Blur() {
     g.focused = false
     g.input.Blur()
+    g.members.invite.Blur()
     g.nameInput.Blur()
     g.descInput.Blur()
 }
\ No newline at end of file
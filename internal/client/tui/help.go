@@ -0,0 +1,176 @@
+// internal/client/tui/help.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// helpSection is a titled group of bindings in the help overlay
+type helpSection struct {
+    title    string
+    bindings []key.Binding
+}
+
+// helpSections returns the bindings active on the current page, the global
+// ones first
+func (m Model) helpSections() []helpSection {
+    sections := []helpSection{{i18n.T("Global"), []key.Binding{
+        globalKeys.NextPage, globalKeys.Switcher, globalKeys.NextConversation, globalKeys.PrevConversation,
+        globalKeys.OpenLink, globalKeys.JumpUnread, globalKeys.MarkAllRead, globalKeys.SidePane, globalKeys.PaneUp, globalKeys.PaneDown,
+        globalKeys.Help, globalKeys.Quit,
+    }}}
+
+    chat := []helpSection{
+        {i18n.T("Chat"), []key.Binding{chatKeys.Send, chatKeys.EditLast, chatKeys.Command, chatKeys.Select}},
+        {i18n.T("Selected messages"), []key.Binding{
+            selectionKeys.Down, selectionKeys.Up, selectionKeys.First, selectionKeys.Last, selectionKeys.OpenLink,
+            selectionKeys.Copy, selectionKeys.CopyFull, selectionKeys.Link, selectionKeys.Edit, selectionKeys.Retry, selectionKeys.Thread, selectionKeys.History, selectionKeys.Input, selectionKeys.Leave,
+        }},
+    }
+
+    switch m.currentPage {
+    case GlobalPage:
+        sections = append(sections, chat...)
+
+    case MessagesPage:
+        if m.selectedChat == "" {
+            sections = append(sections, helpSection{i18n.T("Conversations"), []key.Binding{contactKeys.Open}})
+        } else {
+            sections = append(sections, chat...)
+        }
+
+    case FriendsPage:
+        if m.friendsView != nil && m.friendsView.browsing {
+            sections = append(sections, helpSection{i18n.T("Friends"), []key.Binding{
+                friendListKeys.Down, friendListKeys.Up, friendListKeys.Chat,
+                friendListKeys.Remove, friendListKeys.Block, friendListKeys.Back,
+            }})
+        } else {
+            sections = append(sections, helpSection{i18n.T("Friends"), []key.Binding{
+                friendSearchKeys.Add, friendSearchKeys.Accept, friendSearchKeys.Reject, friendSearchKeys.Browse,
+            }})
+        }
+
+    case GroupsPage:
+        mode := GroupListMode
+        if m.groupsView != nil {
+            mode = m.groupsView.mode
+        }
+        switch mode {
+        case GroupListMode:
+            sections = append(sections, helpSection{i18n.T("Groups"), []key.Binding{
+                groupListKeys.Open, groupListKeys.Create, groupListKeys.Browse,
+            }})
+        case GroupChatMode:
+            groupChat := chat[0]
+            groupChat.bindings = append(groupChat.bindings, groupChatKeys.Members)
+            sections = append(sections, groupChat, chat[1])
+        case GroupCreateMode:
+            sections = append(sections, helpSection{i18n.T("New group"), []key.Binding{
+                groupCreateKeys.NextField, groupCreateKeys.Public, groupCreateKeys.Encrypted, groupCreateKeys.Create, groupCreateKeys.Cancel,
+            }})
+        case GroupMembersMode:
+            sections = append(sections, helpSection{i18n.T("Members"), []key.Binding{
+                memberKeys.Down, memberKeys.Up, memberKeys.Invite, memberKeys.Kick, memberKeys.Role, memberKeys.Notify, memberKeys.Back,
+            }})
+        case GroupBrowseMode:
+            sections = append(sections, helpSection{i18n.T("Public groups"), []key.Binding{
+                directoryKeys.Down, directoryKeys.Up, directoryKeys.Join, directoryKeys.Refresh, directoryKeys.Back,
+            }})
+        }
+
+    case NotificationsPage:
+        sections = append(sections, helpSection{i18n.T("Notifications"), []key.Binding{
+            notificationKeys.Down, notificationKeys.Up, notificationKeys.Open, notificationKeys.Read, notificationKeys.ReadAll, notificationKeys.Mentions,
+        }})
+
+    case DiscoverPage:
+        sections = append(sections, helpSection{i18n.T("Discover"), []key.Binding{
+            discoverKeys.Down, discoverKeys.Up, discoverKeys.Open, discoverKeys.Search, discoverKeys.Refresh,
+        }})
+
+    case ServerPage:
+        sections = append(sections, helpSection{i18n.T("Server"), []key.Binding{
+            adminKeys.Announce, adminKeys.Kick, adminKeys.Refresh, adminKeys.Down, adminKeys.Up,
+        }})
+    }
+
+    return sections
+}
+
+// helpAvailable tells if "?" opens the help. It is a normal character once
+// something is typed in the focused input, f1 always works
+func (m Model) helpAvailable() bool {
+    switch m.currentPage {
+    case GlobalPage, MessagesPage:
+        return !m.input.Focused() || m.input.Value() == ""
+    case FriendsPage:
+        return m.friendsView == nil || m.friendsView.browsing || m.friendsView.searchInput.Value() == ""
+    case GroupsPage:
+        g := m.groupsView
+        if g == nil {
+            return true
+        }
+        switch g.mode {
+        case GroupChatMode:
+            return !g.input.Focused() || g.input.Value() == ""
+        case GroupCreateMode:
+            return g.nameInput.Value() == "" && g.descInput.Value() == ""
+        case GroupMembersMode:
+            return !g.members.inviting
+        }
+    case DiscoverPage:
+        return !m.discover.search.Focused() || m.discover.search.Value() == ""
+    case ServerPage:
+        return !m.admin.input.Focused() || m.admin.input.Value() == ""
+    }
+    return true
+}
+
+func renderHelpSection(section helpSection) string {
+    width := 0
+    for _, binding := range section.bindings {
+        if w := lipgloss.Width(binding.Help().Key); w > width {
+            width = w
+        }
+    }
+
+    var sb strings.Builder
+    sb.WriteString(sidebarSectionStyle.Render(section.title))
+    for _, binding := range section.bindings {
+        if !binding.Enabled() {
+            continue
+        }
+        help := binding.Help()
+        sb.WriteString(fmt.Sprintf("\n%s%s  %s", help.Key, strings.Repeat(" ", width-lipgloss.Width(help.Key)), timestampStyleBase.Render(i18n.T(help.Desc))))
+    }
+    return sb.String()
+}
+
+// helpView draws the help overlay over the main area, the sections are side
+// by side when there is room for them
+func (m Model) helpView(width, height int) string {
+    sections := m.helpSections()
+    blocks := make([]string, 0, len(sections))
+    total := 0
+    for _, section := range sections {
+        block := lipgloss.NewStyle().PaddingRight(3).Render(renderHelpSection(section))
+        blocks = append(blocks, block)
+        total += lipgloss.Width(block)
+    }
+
+    var body string
+    if total+4 <= width {
+        body = lipgloss.JoinHorizontal(lipgloss.Top, blocks...)
+    } else {
+        body = strings.Join(blocks, "\n\n")
+    }
+
+    content := titleStyle.Render(i18n.T("Keys")) + "\n" + body + "\n\n" + timestampStyleBase.Render(i18n.T("press any key to close"))
+    return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Top, inputStyle.Render(content))
+}
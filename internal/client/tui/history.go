@@ -0,0 +1,242 @@
+// internal/client/tui/history.go
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"textual/internal/client/config"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// the conversations kept in memory, the least recently used ones go back to
+// their history file, and the messages kept of a conversation off screen.
+// The older ones are loaded from the server again when scrolling up, the
+// conversation on screen keeps the pages scrolled up to until it is left
+const (
+    maxCachedChats    = 20
+    maxCachedMessages = 500
+)
+
+// how often the conversations changed are written, a crash loses at most
+// this much
+const historySaveInterval = time.Minute
+
+// historySaveMsg writes the conversations changed
+type historySaveMsg struct{}
+
+func tickHistorySave() tea.Cmd {
+    return tea.Tick(historySaveInterval, func(time.Time) tea.Msg {
+        return historySaveMsg{}
+    })
+}
+
+// historyCache bounds the messages held in memory and keeps them in a file
+// per conversation, so the next session shows them before the server
+// answers. It works on the maps of the model and of the groups view
+type historyCache struct {
+    userID string
+    chats  map[string][]models.Message
+    groups map[string][]models.Message
+    // reset for the conversations evicted, so they are loaded again
+    loaded        []map[string]bool
+    noMoreHistory map[string]bool
+
+    current  string   // the conversation on screen
+    recent   []string // conversations opened or receiving messages, the last used at the end
+    restored map[string]bool
+    dirty    map[string]bool
+}
+
+func newHistoryCache(userID string, chats, groups map[string][]models.Message, noMoreHistory map[string]bool, loaded ...map[string]bool) *historyCache {
+    return &historyCache{
+        userID:        userID,
+        chats:         chats,
+        groups:        groups,
+        loaded:        loaded,
+        noMoreHistory: noMoreHistory,
+        restored:      make(map[string]bool),
+        dirty:         make(map[string]bool),
+    }
+}
+
+// Open restores a conversation from its file the first time it is shown,
+// and sends the least recently used ones back to theirs
+func (h *historyCache) Open(chatID string) {
+    if h == nil || chatID == "" {
+        return
+    }
+    if previous := h.current; previous != "" && previous != chatID {
+        h.trim(previous)
+        h.save(previous)
+    }
+    h.current = chatID
+    h.use(chatID)
+}
+
+// Stored marks a conversation to write, after new messages. One off screen
+// keeps its latest messages only, received without being opened it counts
+// as used too
+func (h *historyCache) Stored(chatID string) {
+    if h == nil || chatID == "" {
+        return
+    }
+    h.dirty[chatID] = true
+    h.use(chatID)
+    if chatID != h.current {
+        h.trim(chatID)
+    }
+}
+
+// use moves a conversation to the most recently used, restoring it from its
+// file first so the file keeps its messages, and evicts the least recently
+// used ones past maxCachedChats. The conversation on screen stays
+func (h *historyCache) use(chatID string) {
+    for i, id := range h.recent {
+        if id == chatID {
+            h.recent = append(h.recent[:i], h.recent[i+1:]...)
+            break
+        }
+    }
+    h.recent = append(h.recent, chatID)
+
+    if !h.restored[chatID] {
+        h.restored[chatID] = true
+        h.restore(chatID)
+    }
+    for i := 0; len(h.recent) > maxCachedChats && i < len(h.recent); {
+        if id := h.recent[i]; id != h.current && id != chatID {
+            h.evict(id)
+            h.recent = append(h.recent[:i], h.recent[i+1:]...)
+            continue
+        }
+        i++
+    }
+}
+
+// Forget drops the file of a conversation cleared
+func (h *historyCache) Forget(chatID string) {
+    if h == nil {
+        return
+    }
+    delete(h.dirty, chatID)
+    path, err := config.HistoryPath(h.userID, chatID)
+    if err != nil {
+        return
+    }
+    if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+        logging.Errorf("Failed to remove history of %s: %v", chatID, err)
+    }
+}
+
+// Save writes the conversations changed, every historySaveInterval and
+// before quitting
+func (h *historyCache) Save() {
+    if h == nil {
+        return
+    }
+    for chatID := range h.dirty {
+        h.save(chatID)
+    }
+}
+
+func (h *historyCache) restore(chatID string) {
+    path, err := config.HistoryPath(h.userID, chatID)
+    if err != nil {
+        return
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        if !os.IsNotExist(err) {
+            logging.Errorf("Failed to read history of %s: %v", chatID, err)
+        }
+        return
+    }
+    var messages []models.Message
+    if err := json.Unmarshal(data, &messages); err != nil {
+        logging.Errorf("Failed to decode history of %s: %v", chatID, err)
+        return
+    }
+    if len(messages) == 0 {
+        return
+    }
+    h.chats[chatID] = mergeMessages(h.chats[chatID], messages...)
+    if messages[0].IsGroup() && h.groups != nil {
+        h.groups[chatID] = mergeMessages(h.groups[chatID], messages...)
+    }
+}
+
+// trim keeps the latest messages of a conversation off screen, the ones the
+// file keeps
+func (h *historyCache) trim(chatID string) {
+    for _, chats := range []map[string][]models.Message{h.chats, h.groups} {
+        if chat := chats[chatID]; len(chat) > maxCachedMessages {
+            chats[chatID] = append([]models.Message(nil), chat[len(chat)-maxCachedMessages:]...)
+            delete(h.noMoreHistory, chatID)
+        }
+    }
+}
+
+// evict writes a conversation to its file and drops it from memory
+func (h *historyCache) evict(chatID string) {
+    h.save(chatID)
+    delete(h.chats, chatID)
+    if h.groups != nil {
+        delete(h.groups, chatID)
+    }
+    delete(h.restored, chatID)
+    delete(h.noMoreHistory, chatID)
+    for _, loaded := range h.loaded {
+        delete(loaded, chatID)
+    }
+}
+
+// save writes the latest messages of a conversation. The pending and the
+// system ones are left out, and the encrypted ones: the server has no copy
+// of them, the file would be the only one in clear
+func (h *historyCache) save(chatID string) {
+    if !h.dirty[chatID] {
+        return
+    }
+    chat := h.chats[chatID]
+    if len(chat) > maxCachedMessages {
+        chat = chat[len(chat)-maxCachedMessages:]
+    }
+    kept := make([]models.Message, 0, len(chat))
+    for _, msg := range chat {
+        if msg.ID != "" && msg.SendState == "" && !msg.Encrypted && !msg.IsSystem() {
+            kept = append(kept, msg)
+        }
+    }
+    if err := writeHistory(h.userID, chatID, kept); err != nil {
+        logging.Errorf("Failed to save history of %s: %v", chatID, err)
+        return
+    }
+    delete(h.dirty, chatID)
+}
+
+func writeHistory(userID, chatID string, messages []models.Message) error {
+    path, err := config.HistoryPath(userID, chatID)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return fmt.Errorf("failed to create history directory: %v", err)
+    }
+    data, err := json.Marshal(messages)
+    if err != nil {
+        return fmt.Errorf("failed to encode history: %v", err)
+    }
+
+    // write then rename so a crash never leaves a truncated file
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return fmt.Errorf("failed to write history: %v", err)
+    }
+    return os.Rename(tmp, path)
+}
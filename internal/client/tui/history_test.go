@@ -0,0 +1,132 @@
+// internal/client/tui/history_test.go
+package tui
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"textual/internal/client/models"
+)
+
+func chatMessages(chatID string, n int) []models.Message {
+    start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+    messages := make([]models.Message, n)
+    for i := range messages {
+        recipient := chatID
+        messages[i] = models.Message{
+            ID:          fmt.Sprintf("%s-%d", chatID, i),
+            Content:     fmt.Sprintf("message %d", i),
+            RecipientID: &recipient,
+            SentAt:      start.Add(time.Duration(i) * time.Minute),
+        }
+    }
+    return messages
+}
+
+func newTestCache(chats map[string][]models.Message, loaded map[string]bool) *historyCache {
+    return newHistoryCache("user", chats, make(map[string][]models.Message), make(map[string]bool), loaded)
+}
+
+func TestHistoryCacheRestores(t *testing.T) {
+    t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+    chats := make(map[string][]models.Message)
+    h := newTestCache(chats, make(map[string]bool))
+    h.Open("bob")
+    chats["bob"] = mergeMessages(chats["bob"], chatMessages("bob", 3)...)
+    pending := models.Message{Content: "sending", SendState: "pending", SentAt: time.Now()}
+    chats["bob"] = append(chats["bob"], pending)
+    h.Stored("bob")
+    h.Save()
+
+    next := make(map[string][]models.Message)
+    newTestCache(next, make(map[string]bool)).Open("bob")
+    if got := len(next["bob"]); got != 3 {
+        t.Fatalf("restored %d messages, want the 3 sent", got)
+    }
+    if next["bob"][2].Content != "message 2" {
+        t.Fatalf("last message restored %q", next["bob"][2].Content)
+    }
+}
+
+func TestHistoryCacheEvicts(t *testing.T) {
+    t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+    chats := make(map[string][]models.Message)
+    loaded := make(map[string]bool)
+    h := newTestCache(chats, loaded)
+    for i := 0; i <= maxCachedChats; i++ {
+        chatID := fmt.Sprintf("chat-%d", i)
+        h.Open(chatID)
+        chats[chatID] = chatMessages(chatID, 2)
+        loaded[chatID] = true
+        h.Stored(chatID)
+    }
+
+    if len(chats) != maxCachedChats {
+        t.Fatalf("%d conversations in memory, want %d", len(chats), maxCachedChats)
+    }
+    if _, ok := chats["chat-0"]; ok || loaded["chat-0"] {
+        t.Fatal("least recently opened conversation kept")
+    }
+
+    h.Open("chat-0")
+    if got := len(chats["chat-0"]); got != 2 {
+        t.Fatalf("reopened conversation has %d messages, want 2 from its file", got)
+    }
+}
+
+func TestHistoryCacheTrims(t *testing.T) {
+    t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+    chats := make(map[string][]models.Message)
+    h := newTestCache(chats, make(map[string]bool))
+    h.Open("bob")
+    chats["bob"] = chatMessages("bob", maxCachedMessages+20)
+    h.noMoreHistory["bob"] = true
+    h.Stored("bob")
+
+    h.Open("carol")
+    if got := len(chats["bob"]); got != maxCachedMessages {
+        t.Fatalf("%d messages kept once left, want %d", got, maxCachedMessages)
+    }
+    if chats["bob"][0].ID != "bob-20" || h.noMoreHistory["bob"] {
+        t.Fatalf("kept from %s, the older ones must load again", chats["bob"][0].ID)
+    }
+}
+
+func TestHistoryCacheBoundsUnopened(t *testing.T) {
+    t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+    chats := make(map[string][]models.Message)
+    h := newTestCache(chats, make(map[string]bool))
+    h.Open("global")
+    // messages arrive in conversations never opened
+    for i := 0; i <= maxCachedChats; i++ {
+        chatID := fmt.Sprintf("chat-%d", i)
+        chats[chatID] = chatMessages(chatID, maxCachedMessages+10)
+        h.Stored(chatID)
+    }
+
+    if len(chats) > maxCachedChats {
+        t.Fatalf("%d conversations in memory, want at most %d", len(chats), maxCachedChats)
+    }
+    if _, ok := chats["chat-0"]; ok {
+        t.Fatal("least recently used conversation kept")
+    }
+    last := fmt.Sprintf("chat-%d", maxCachedChats)
+    if got := len(chats[last]); got != maxCachedMessages {
+        t.Fatalf("%d messages kept off screen, want %d", got, maxCachedMessages)
+    }
+
+    // the file of the evicted one kept its messages, a new one merges with it
+    chats["chat-0"] = []models.Message{{ID: "new", Content: "new", SentAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}
+    h.Stored("chat-0")
+    if got := len(chats["chat-0"]); got != maxCachedMessages {
+        t.Fatalf("%d messages after a new one, want %d from the file and the new one", got, maxCachedMessages)
+    }
+    if chats["chat-0"][maxCachedMessages-1].ID != "new" {
+        t.Fatalf("last message %s", chats["chat-0"][maxCachedMessages-1].ID)
+    }
+}
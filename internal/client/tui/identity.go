@@ -0,0 +1,58 @@
+// internal/client/tui/identity.go
+package tui
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+    // each sender gets a color of the theme instead of the primary one
+    userColorsEnabled = true
+    // a block with the initial of the sender before the name
+    avatarsEnabled = false
+)
+
+// SetIdentity enables the colored sender names and the initial blocks
+func SetIdentity(userColors, avatars bool) {
+    userColorsEnabled = userColors
+    avatarsEnabled = avatars
+}
+
+// userColor picks the color of a sender from their ID, the same user gets
+// the same color on every screen and after every restart
+func userColor(userID string) lipgloss.Color {
+    names := currentTheme.Names
+    if !userColorsEnabled || len(names) == 0 || userID == "" {
+        return currentTheme.Primary
+    }
+    h := fnv.New32a()
+    h.Write([]byte(userID))
+    return names[h.Sum32()%uint32(len(names))]
+}
+
+// renderSender writes the name of the author of a message in their color,
+// after their initial when the avatars are enabled
+func renderSender(userID, name string) string {
+    color := userColor(userID)
+    // the name column is 15 cells, the padding included
+    rendered := usernameStyle.Foreground(color).Render(fitWidth(name, 14))
+    if !avatarsEnabled {
+        return rendered
+    }
+
+    initial, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+    if initial == utf8.RuneError {
+        initial = '?'
+    }
+    avatar := lipgloss.NewStyle().
+        Bold(true).
+        Foreground(currentTheme.Text).
+        Background(color).
+        Render(string(unicode.ToUpper(initial)))
+    return avatar + " " + rendered
+}
@@ -0,0 +1,59 @@
+// internal/client/tui/idle.go
+package tui
+
+import (
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// how often the idle time is checked, the user goes away at most this late
+const idleCheck = 15 * time.Second
+
+// idleTickMsg checks if the user left the keyboard
+type idleTickMsg struct{}
+
+func tickIdle() tea.Cmd {
+    return tea.Tick(idleCheck, func(time.Time) tea.Msg {
+        return idleTickMsg{}
+    })
+}
+
+// checkIdle sets the user away once the keyboard and the mouse were left
+// alone for the away_after of the config. Only an online user is set away:
+// do not disturb and an away chosen by hand stay
+func (m *Model) checkIdle() tea.Cmd {
+    awayAfter := m.config.Presence.AwayAfter
+    if awayAfter > 0 && !m.idleAway && m.status == models.StatusOnline && time.Since(m.lastInput) >= awayAfter {
+        if m.sendPresence(models.StatusAway) {
+            m.idleAway = true
+        }
+    }
+    return tickIdle()
+}
+
+// active records a key or a click, a user set away by checkIdle is back
+// online
+func (m *Model) active() {
+    m.lastInput = time.Now()
+    if m.idleAway && m.status == models.StatusAway {
+        m.sendPresence(models.StatusOnline)
+    }
+    m.idleAway = false
+}
+
+// sendPresence changes the status without touching the custom text, nor
+// showing a notice as the commands do
+func (m *Model) sendPresence(status string) bool {
+    if m.connection == nil || m.offline() {
+        return false
+    }
+    if err := m.connection.SendStatus(status, m.activeStatusText(), m.statusExpires); err != nil {
+        logging.Errorf("Failed to send the %s status: %v", status, err)
+        return false
+    }
+    m.status = status
+    return true
+}
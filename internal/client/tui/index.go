@@ -0,0 +1,94 @@
+// internal/client/tui/index.go
+package tui
+
+import "textual/internal/client/models"
+
+// chatIndex summarizes a chat for the lists: where each message is, its
+// last message and the messages of the others not read
+type chatIndex struct {
+    positions map[string]int // message ID -> position in the chat
+    count     int
+    last      models.Message
+    unread    int
+}
+
+// messageIndex keeps a chatIndex per chat of a message map. It follows the
+// chats as they are read: a message appended updates it, another change
+// rebuilds the index of that chat only
+type messageIndex struct {
+    userID string
+    chats  map[string]*chatIndex
+}
+
+func newMessageIndex() *messageIndex {
+    return &messageIndex{chats: make(map[string]*chatIndex)}
+}
+
+// SetUserID sets whose messages are never unread
+func (x *messageIndex) SetUserID(userID string) {
+    if x.userID != userID {
+        x.userID = userID
+        x.chats = make(map[string]*chatIndex)
+    }
+}
+
+// get returns the index of chat, brought up to date
+func (x *messageIndex) get(chatID string, chat []models.Message) *chatIndex {
+    idx, ok := x.chats[chatID]
+    switch {
+    case !ok:
+    case idx.count == len(chat) && (idx.count == 0 || sameMessage(idx.last, chat[idx.count-1])):
+        return idx
+    case idx.count > 0 && idx.count == len(chat)-1 && sameMessage(idx.last, chat[idx.count-1]):
+        x.add(idx, chat[idx.count])
+        return idx
+    }
+
+    idx = &chatIndex{positions: make(map[string]int, len(chat))}
+    for _, msg := range chat {
+        x.add(idx, msg)
+    }
+    x.chats[chatID] = idx
+    return idx
+}
+
+func (x *messageIndex) add(idx *chatIndex, msg models.Message) {
+    if msg.ID != "" {
+        idx.positions[msg.ID] = idx.count
+    }
+    idx.count++
+    idx.last = msg
+    if !msg.Read && msg.SenderID != x.userID {
+        idx.unread++
+    }
+}
+
+// Position returns where a message is in its chat
+func (x *messageIndex) Position(chatID string, chat []models.Message, messageID string) (int, bool) {
+    i, ok := x.get(chatID, chat).positions[messageID]
+    return i, ok
+}
+
+// Last returns the latest message of a chat
+func (x *messageIndex) Last(chatID string, chat []models.Message) (models.Message, bool) {
+    if len(chat) == 0 {
+        return models.Message{}, false
+    }
+    return x.get(chatID, chat).last, true
+}
+
+// Unread returns the messages of the others not read in a chat
+func (x *messageIndex) Unread(chatID string, chat []models.Message) int {
+    return x.get(chatID, chat).unread
+}
+
+// Invalidate drops the index of a chat whose messages changed in place
+func (x *messageIndex) Invalidate(chatID string) {
+    delete(x.chats, chatID)
+}
+
+// sameMessage tells if two copies are the same message in the same state,
+// the pending ones have no ID yet
+func sameMessage(a, b models.Message) bool {
+    return a.ID == b.ID && a.ClientID == b.ClientID && a.SendState == b.SendState && a.SentAt.Equal(b.SentAt)
+}
@@ -0,0 +1,54 @@
+// internal/client/tui/index_test.go
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"textual/internal/client/models"
+)
+
+func TestMessageIndex(t *testing.T) {
+    x := newMessageIndex()
+    x.SetUserID("me")
+    chat := chatMessages("bob", 3)
+    chat[1].SenderID = "me"
+
+    if got := x.Unread("bob", chat); got != 2 {
+        t.Fatalf("unread %d, want the 2 of bob", got)
+    }
+
+    // appended: the index follows without a rebuild
+    idx := x.chats["bob"]
+    chat = append(chat, models.Message{ID: "bob-3", SenderID: "bob", SentAt: chat[2].SentAt.Add(1)})
+    if last, _ := x.Last("bob", chat); last.ID != "bob-3" {
+        t.Fatalf("last %s, want bob-3", last.ID)
+    }
+    if x.chats["bob"] != idx || idx.unread != 3 {
+        t.Fatalf("append rebuilt the index or missed the unread message (%d)", idx.unread)
+    }
+
+    // older messages loaded: positions move
+    older := chatMessages("old", 2)
+    for i := range older {
+        older[i].SentAt = chat[0].SentAt.Add(-time.Hour)
+    }
+    chat = mergeMessages(chat, older...)
+    if i, ok := x.Position("bob", chat, "bob-0"); !ok || i != 2 {
+        t.Fatalf("bob-0 at %d (%t), want 2 after the older messages", i, ok)
+    }
+    if _, ok := x.Position("bob", chat, "missing"); ok {
+        t.Fatal("unknown message found")
+    }
+
+    // a message changed in place
+    chat[len(chat)-1].Content = "edited"
+    x.Invalidate("bob")
+    if last, _ := x.Last("bob", chat); last.Content != "edited" {
+        t.Fatalf("last content %q after an edit", last.Content)
+    }
+
+    if _, ok := x.Last("empty", nil); ok {
+        t.Fatal("last message of an empty chat")
+    }
+}
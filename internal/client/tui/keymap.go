@@ -0,0 +1,233 @@
+// internal/client/tui/keymap.go
+package tui
+
+import "github.com/charmbracelet/bubbles/key"
+
+// key bindings of every page, the handlers match keys against them and the
+// help overlay (?) is generated from them
+
+var globalKeys = struct {
+    NextPage         key.Binding
+    Switcher         key.Binding
+    NextConversation key.Binding
+    PrevConversation key.Binding
+    OpenLink         key.Binding
+    JumpUnread       key.Binding
+    MarkAllRead      key.Binding
+    SidePane         key.Binding
+    PaneUp           key.Binding
+    PaneDown         key.Binding
+    Help             key.Binding
+    Quit             key.Binding
+}{
+    NextPage:         key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next page")),
+    Switcher:         key.NewBinding(key.WithKeys("ctrl+k"), key.WithHelp("ctrl+k", "jump to a conversation")),
+    NextConversation: key.NewBinding(key.WithKeys("alt+j", "alt+down"), key.WithHelp("alt+j/↓", "next conversation")),
+    PrevConversation: key.NewBinding(key.WithKeys("alt+k", "alt+up"), key.WithHelp("alt+k/↑", "previous conversation")),
+    OpenLink:         key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "open the last link")),
+    JumpUnread:       key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "jump to the new messages")),
+    MarkAllRead:      key.NewBinding(key.WithKeys("alt+r"), key.WithHelp("alt+r", "mark every conversation read")),
+    SidePane:         key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "side pane: members, requests, notifications")),
+    PaneUp:           key.NewBinding(key.WithKeys("alt+pgup"), key.WithHelp("alt+pgup", "scroll the side pane up")),
+    PaneDown:         key.NewBinding(key.WithKeys("alt+pgdown"), key.WithHelp("alt+pgdown", "scroll the side pane down")),
+    Help:             key.NewBinding(key.WithKeys("?", "f1"), key.WithHelp("?/f1", "show this help")),
+    Quit:             key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+}
+
+var chatKeys = struct {
+    Send     key.Binding
+    EditLast key.Binding
+    Command  key.Binding
+    Select   key.Binding
+}{
+    Send:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "send")),
+    EditLast: key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", "edit your last message")),
+    Command:  key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "commands, /help lists them")),
+    Select:   key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "select messages, cancel an edit")),
+}
+
+var selectionKeys = struct {
+    Down     key.Binding
+    Up       key.Binding
+    First    key.Binding
+    Last     key.Binding
+    OpenLink key.Binding
+    Copy     key.Binding
+    CopyFull key.Binding
+    Link     key.Binding
+    Edit     key.Binding
+    Retry    key.Binding
+    Thread   key.Binding
+    History  key.Binding
+    Input    key.Binding
+    Leave    key.Binding
+}{
+    Down:     key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "next message")),
+    Up:       key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "previous message")),
+    First:    key.NewBinding(key.WithKeys("g", "home"), key.WithHelp("g", "first message")),
+    Last:     key.NewBinding(key.WithKeys("G", "end"), key.WithHelp("G", "last message")),
+    OpenLink: key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open the link")),
+    Copy:     key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy the text")),
+    CopyFull: key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "copy with author and time")),
+    Link:     key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "copy the link of the message")),
+    Edit:     key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit your message")),
+    Retry:    key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "retry a failed message")),
+    Thread:   key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "open the thread of the message")),
+    History:  key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "show the earlier versions of the message")),
+    Input:    key.NewBinding(key.WithKeys("i", "enter"), key.WithHelp("i", "back to the input")),
+    Leave:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "leave the selection")),
+}
+
+var contactKeys = struct {
+    Open key.Binding
+}{
+    Open: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open the conversation")),
+}
+
+var friendSearchKeys = struct {
+    Add    key.Binding
+    Accept key.Binding
+    Reject key.Binding
+    Browse key.Binding
+}{
+    Add:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "send a friend request")),
+    Accept: key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "accept the selected request")),
+    Reject: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "decline the selected request")),
+    Browse: key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", "select a friend")),
+}
+
+var friendListKeys = struct {
+    Down   key.Binding
+    Up     key.Binding
+    Chat   key.Binding
+    Remove key.Binding
+    Block  key.Binding
+    Back   key.Binding
+}{
+    Down:   key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "next friend")),
+    Up:     key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "previous friend")),
+    Chat:   key.NewBinding(key.WithKeys("enter", "c"), key.WithHelp("enter/c", "start a chat")),
+    Remove: key.NewBinding(key.WithKeys("r", "delete"), key.WithHelp("r", "remove the friend")),
+    Block:  key.NewBinding(key.WithKeys("b"), key.WithHelp("b", "block the user")),
+    Back:   key.NewBinding(key.WithKeys("esc", "i"), key.WithHelp("esc", "back to the search")),
+}
+
+var groupListKeys = struct {
+    Open   key.Binding
+    Create key.Binding
+    Browse key.Binding
+}{
+    Open:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open the group")),
+    Create: key.NewBinding(key.WithKeys("ctrl+n"), key.WithHelp("ctrl+n", "create a group")),
+    Browse: key.NewBinding(key.WithKeys("ctrl+b"), key.WithHelp("ctrl+b", "browse public groups")),
+}
+
+var groupChatKeys = struct {
+    Members key.Binding
+}{
+    Members: key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "members of the group")),
+}
+
+var groupCreateKeys = struct {
+    NextField key.Binding
+    Public    key.Binding
+    Encrypted key.Binding
+    Announcement key.Binding
+    Create    key.Binding
+    Cancel    key.Binding
+}{
+    NextField: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next field")),
+    Public:    key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "public or private")),
+    Encrypted: key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "encrypted or not")),
+    Announcement: key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("ctrl+y", "announcements only or not")),
+    Create:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "create")),
+    Cancel:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+}
+
+var memberKeys = struct {
+    Down   key.Binding
+    Up     key.Binding
+    Invite key.Binding
+    Kick   key.Binding
+    Role   key.Binding
+    Notify key.Binding
+    Back   key.Binding
+}{
+    Down:   key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "next member")),
+    Up:     key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "previous member")),
+    Invite: key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "invite someone (admins)")),
+    Kick:   key.NewBinding(key.WithKeys("x", "delete"), key.WithHelp("x", "kick the member (admins)")),
+    Role:   key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "toggle admin (admins)")),
+    Notify: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "change what the group notifies")),
+    Back:   key.NewBinding(key.WithKeys("esc", "ctrl+p"), key.WithHelp("esc", "back to the chat")),
+}
+
+var directoryKeys = struct {
+    Down    key.Binding
+    Up      key.Binding
+    Join    key.Binding
+    Refresh key.Binding
+    Back    key.Binding
+}{
+    Down:    key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "next group")),
+    Up:      key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "previous group")),
+    Join:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "join or open the group")),
+    Refresh: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+    Back:    key.NewBinding(key.WithKeys("esc", "ctrl+b"), key.WithHelp("esc", "back to your groups")),
+}
+
+var notificationKeys = struct {
+    Down    key.Binding
+    Up      key.Binding
+    Open    key.Binding
+    Read    key.Binding
+    ReadAll key.Binding
+    Mentions key.Binding
+}{
+    Down:    key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "next notification")),
+    Up:      key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "previous notification")),
+    Open:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open and mark read")),
+    Read:    key.NewBinding(key.WithKeys("m", " "), key.WithHelp("m", "mark read")),
+    ReadAll: key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "mark all read")),
+    Mentions: key.NewBinding(key.WithKeys("@"), key.WithHelp("@", "mentions / notifications")),
+}
+
+var discoverKeys = struct {
+    Down    key.Binding
+    Up      key.Binding
+    Open    key.Binding
+    Search  key.Binding
+    Refresh key.Binding
+}{
+    Down:    key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "next entry")),
+    Up:      key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "previous entry")),
+    Open:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "send a friend request or chat, join or open the group")),
+    Search:  key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search users by name")),
+    Refresh: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+}
+
+var adminKeys = struct {
+    Announce key.Binding
+    Kick     key.Binding
+    Refresh  key.Binding
+    Down     key.Binding
+    Up       key.Binding
+}{
+    Announce: key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "announce to every connected user")),
+    Kick:     key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "disconnect a user")),
+    Refresh:  key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh the stats")),
+    Down:     key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "scroll down")),
+    Up:       key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "scroll up")),
+}
+
+var threadKeys = struct {
+    Reply      key.Binding
+    ScrollUp   key.Binding
+    ScrollDown key.Binding
+    Close      key.Binding
+}{
+    Reply:      key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "reply, or retry the failed replies")),
+    ScrollUp:   key.NewBinding(key.WithKeys("pgup"), key.WithHelp("pgup", "scroll the thread up")),
+    ScrollDown: key.NewBinding(key.WithKeys("pgdown"), key.WithHelp("pgdown", "scroll the thread down")),
+    Close:      key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "close the thread")),
+}
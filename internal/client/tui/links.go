@@ -0,0 +1,90 @@
+// internal/client/tui/links.go
+package tui
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+    urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+    // OSC 8 hyperlinks, ignored by terminals that don't support them
+    hyperlinksEnabled = true
+)
+
+// SetHyperlinks enables or disables the OSC 8 escape sequences around links
+func SetHyperlinks(enabled bool) {
+    hyperlinksEnabled = enabled
+}
+
+// findLinks returns the URLs of a message, in order
+func findLinks(content string) []string {
+    var links []string
+    for _, loc := range linkLocations(content) {
+        links = append(links, content[loc[0]:loc[1]])
+    }
+    return links
+}
+
+// linkLocations finds the URLs, without the punctuation that usually ends a sentence
+func linkLocations(content string) [][]int {
+    locs := urlPattern.FindAllStringIndex(content, -1)
+    for _, loc := range locs {
+        for loc[1] > loc[0] && strings.ContainsRune(".,;:!?)]'", rune(content[loc[1]-1])) {
+            loc[1]--
+        }
+    }
+    return locs
+}
+
+// renderLinks renders the content with the style, underlining the URLs
+func renderLinks(content string, style lipgloss.Style) string {
+    locs := linkLocations(content)
+    if len(locs) == 0 {
+        return style.Render(content)
+    }
+
+    var sb strings.Builder
+    last := 0
+    for _, loc := range locs {
+        if loc[0] > last {
+            sb.WriteString(style.Render(content[last:loc[0]]))
+        }
+        url := content[loc[0]:loc[1]]
+        link := style.Underline(true).Render(url)
+        if hyperlinksEnabled {
+            link = fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", url, link)
+        }
+        sb.WriteString(link)
+        last = loc[1]
+    }
+    if last < len(content) {
+        sb.WriteString(style.Render(content[last:]))
+    }
+    return sb.String()
+}
+
+// openURL opens a link in the default browser
+func openURL(url string) error {
+    var cmd *exec.Cmd
+    switch runtime.GOOS {
+    case "darwin":
+        cmd = exec.Command("open", url)
+    case "windows":
+        cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+    default:
+        cmd = exec.Command("xdg-open", url)
+    }
+
+    if err := cmd.Start(); err != nil {
+        return fmt.Errorf("failed to open %s: %v", url, err)
+    }
+    go cmd.Wait()
+    return nil
+}
@@ -2,7 +2,11 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -15,11 +19,6 @@ var (
         Border(lipgloss.RoundedBorder()).
         Padding(1, 2)
 
-    titleStyle = lipgloss.NewStyle().
-        Foreground(lipgloss.Color("#FF87D7")).
-        Bold(true).
-        MarginBottom(1)
-
     // errorStyle = lipgloss.NewStyle().
     //     Foreground(lipgloss.Color("#FF0000")).
     //     MarginTop(1)
@@ -30,34 +29,102 @@ type LoginModel struct {
     password    textinput.Model
     serverHost  textinput.Model
     serverPort  textinput.Model
+    profileName textinput.Model
+    profiles    []config.Profile
+    profile     int // index in profiles, len(profiles) is a new profile
     focusIndex  int
     err         error
     width       int
     height      int
 }
 
-func NewLoginModel() LoginModel {
+// number of inputs of the form, cycled with tab
+const loginInputs = 5
+
+func NewLoginModel(profiles []config.Profile, last string) LoginModel {
     username := textinput.New()
-    username.Placeholder = "Username"
+    username.Placeholder = i18n.T("Username")
     username.Focus()
 
     password := textinput.New()
-    password.Placeholder = "Password"
+    password.Placeholder = i18n.T("Password")
     password.EchoMode = textinput.EchoPassword
 
     serverHost := textinput.New()
-    serverHost.Placeholder = "Server Host (default: localhost)"
+    serverHost.Placeholder = i18n.T("Server Host (default: localhost)")
 
     serverPort := textinput.New()
-    serverPort.Placeholder = "Server Port (default: 8080)"
+    serverPort.Placeholder = i18n.T("Server Port (default: 8080)")
+
+    profileName := textinput.New()
+    profileName.Placeholder = i18n.T("Profile name (default: user@host)")
 
-    return LoginModel{
+    m := LoginModel{
         username:    username,
         password:    password,
         serverHost:  serverHost,
         serverPort:  serverPort,
+        profileName: profileName,
+        profiles:    profiles,
+        profile:     len(profiles),
         focusIndex:  0,
     }
+    for i, profile := range profiles {
+        if profile.Name == last {
+            m.selectProfile(i)
+        }
+    }
+    return m
+}
+
+// Prefill fills the form from the command-line flags, address is host:port
+func (m *LoginModel) Prefill(address, username string) {
+    if address != "" {
+        host, port, found := strings.Cut(address, ":")
+        m.serverHost.SetValue(host)
+        if found {
+            m.serverPort.SetValue(port)
+        }
+        m.profileName.Reset()
+        m.profile = len(m.profiles)
+    }
+    if username != "" {
+        m.username.SetValue(username)
+        m.focus(1)
+    }
+}
+
+// selectProfile fills the form with a saved profile, only the password is
+// left to type
+func (m *LoginModel) selectProfile(index int) {
+    m.profile = index
+    if index == len(m.profiles) {
+        m.username.Reset()
+        m.serverHost.Reset()
+        m.serverPort.Reset()
+        m.profileName.Reset()
+        m.focus(0)
+        return
+    }
+
+    profile := m.profiles[index]
+    m.username.SetValue(profile.Username)
+    m.serverHost.SetValue(profile.Host)
+    m.serverPort.SetValue(profile.Port)
+    m.profileName.SetValue(profile.Name)
+    m.focus(1)
+}
+
+func (m *LoginModel) focus(index int) {
+    m.focusIndex = index
+    inputs := []*textinput.Model{&m.username, &m.password, &m.serverHost, &m.serverPort, &m.profileName}
+    for i, input := range inputs {
+        if i == index {
+            input.Focus()
+        } else {
+            input.Blur()
+        }
+    }
 }
 
 func (m LoginModel) Init() tea.Cmd {
@@ -75,41 +142,29 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         case "tab", "shift+tab":
             // Cycle focus between all inputs
             if msg.String() == "tab" {
-                m.focusIndex = (m.focusIndex + 1) % 4
+                m.focus((m.focusIndex + 1) % loginInputs)
             } else {
-                m.focusIndex = (m.focusIndex - 1 + 4) % 4
+                m.focus((m.focusIndex - 1 + loginInputs) % loginInputs)
             }
+            return m, nil
 
-            for i := range []textinput.Model{m.username, m.password, m.serverHost, m.serverPort} {
-                if i == m.focusIndex {
-                    switch i {
-                    case 0:
-                        m.username.Focus()
-                    case 1:
-                        m.password.Focus()
-                    case 2:
-                        m.serverHost.Focus()
-                    case 3:
-                        m.serverPort.Focus()
-                    }
-                } else {
-                    switch i {
-                    case 0:
-                        m.username.Blur()
-                    case 1:
-                        m.password.Blur()
-                    case 2:
-                        m.serverHost.Blur()
-                    case 3:
-                        m.serverPort.Blur()
-                    }
-                }
+        case "up", "down":
+            if len(m.profiles) == 0 {
+                return m, nil
             }
+            // the last entry is a new profile
+            count := len(m.profiles) + 1
+            if msg.String() == "down" {
+                m.selectProfile((m.profile + 1) % count)
+            } else {
+                m.selectProfile((m.profile - 1 + count) % count)
+            }
+            m.password.Reset()
             return m, nil
 
         case "enter":
             if m.username.Value() == "" || m.password.Value() == "" {
-                m.err = fmt.Errorf("username and password are required")
+                m.err = errors.New(i18n.T("username and password are required"))
                 return m, nil
             }
 
@@ -124,12 +179,18 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 port = m.serverPort.Value()
             }
 
+            name := m.profileName.Value()
+            if name == "" {
+                name = fmt.Sprintf("%s@%s", m.username.Value(), host)
+            }
+
             return m, func() tea.Msg {
                 return LoginSuccessMsg{
                     Username:   m.username.Value(),
                     Password:   m.password.Value(),
                     ServerHost: host,
                     ServerPort: port,
+                    Profile:    config.Profile{Name: name, Host: host, Port: port, Username: m.username.Value()},
                 }
             }
         }
@@ -152,6 +213,8 @@ func (m LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     cmds = append(cmds, cmd)
     m.serverPort, cmd = m.serverPort.Update(msg)
     cmds = append(cmds, cmd)
+    m.profileName, cmd = m.profileName.Update(msg)
+    cmds = append(cmds, cmd)
 
     return m, tea.Batch(cmds...)
 }
@@ -160,26 +223,41 @@ func (m LoginModel) View() string {
     var content string
 
     // Title
-    content += titleStyle.Render("Chat Application Login")
+    content += titleStyle.Render(i18n.T("Chat Application Login"))
     content += "\n\n"
 
+    // Saved profiles
+    if len(m.profiles) > 0 {
+        content += i18n.T("Profile:\n")
+        for i, profile := range m.profiles {
+            content += m.profileLine(i, fmt.Sprintf("%s (%s:%s)", profile.Name, profile.Host, profile.Port))
+        }
+        content += m.profileLine(len(m.profiles), i18n.T("New profile"))
+        content += "\n"
+    }
+
     // Inputs
-    content += "Username:\n"
+    content += i18n.T("Username:\n")
     content += m.username.View()
-    content += "\n\nPassword:\n"
+    content += i18n.T("\n\nPassword:\n")
     content += m.password.View()
-    content += "\n\nServer Host:\n"
+    content += i18n.T("\n\nServer Host:\n")
     content += m.serverHost.View()
-    content += "\n\nServer Port:\n"
+    content += i18n.T("\n\nServer Port:\n")
     content += m.serverPort.View()
+    content += i18n.T("\n\nProfile Name:\n")
+    content += m.profileName.View()
     content += "\n\n"
 
     // Help
-    content += "Press Tab to switch fields • Enter to submit"
+    content += i18n.T("Press Tab to switch fields • Enter to submit")
+    if len(m.profiles) > 0 {
+        content += i18n.T("\n↑/↓ to choose a profile")
+    }
 
     // Error
     if m.err != nil {
-        content += "\n\n" + errorStyle.Render(fmt.Sprintf("Error: %v", m.err))
+        content += "\n\n" + errorStyle.Render(i18n.T("Error: %v", m.err))
     }
 
     // Center everything
@@ -192,11 +270,19 @@ func (m LoginModel) View() string {
     )
 }
 
+func (m LoginModel) profileLine(index int, label string) string {
+    if index == m.profile {
+        return selectionMarkerStyle.Render("▌") + sidebarCursorStyle.Render(label) + "\n"
+    }
+    return " " + label + "\n"
+}
+
 type LoginSuccessMsg struct {
     Username   string
     Password   string
     ServerHost string
     ServerPort string
+    Profile    config.Profile // saved once logged in
 }
 
 type LoginErrorMsg struct {
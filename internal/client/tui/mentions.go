@@ -0,0 +1,143 @@
+// internal/client/tui/mentions.go
+package tui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+// max number of suggestions shown above the input
+const maxMentionSuggestions = 5
+
+// MentionCompleter suggests usernames while an "@word" is being typed
+type MentionCompleter struct {
+    suggestions
+    matches []string
+}
+
+// Refresh updates the suggestions from the word under the input cursor
+func (c *MentionCompleter) Refresh(input textinput.Model, names []string) {
+    prefix, _, ok := mentionAtCursor(input)
+    if !ok {
+        c.Dismiss()
+        return
+    }
+
+    prefix = strings.ToLower(prefix)
+    c.matches = c.matches[:0]
+    for _, name := range names {
+        if strings.HasPrefix(strings.ToLower(name), prefix) && !strings.EqualFold(name, prefix) {
+            c.matches = append(c.matches, name)
+        }
+        if len(c.matches) == maxMentionSuggestions {
+            break
+        }
+    }
+    c.show(len(c.matches))
+}
+
+// HandleKey handles the navigation keys while suggestions are shown, it
+// returns false when the key is not for the completer
+func (c *MentionCompleter) HandleKey(key string, input *textinput.Model) bool {
+    return c.handleKey(key, func(selected int) {
+        c.complete(input, c.matches[selected])
+    })
+}
+
+// complete replaces the word under the cursor with the selected username
+func (c *MentionCompleter) complete(input *textinput.Model, name string) {
+    _, start, ok := mentionAtCursor(*input)
+    if !ok {
+        return
+    }
+
+    value := []rune(input.Value())
+    pos := input.Position()
+    completion := []rune("@" + name + " ")
+
+    result := append(append(append([]rune{}, value[:start]...), completion...), value[pos:]...)
+    input.SetValue(string(result))
+    input.SetCursor(start + len(completion))
+}
+
+func (c *MentionCompleter) View() string {
+    return c.render(" ", func(i int, selected bool) string {
+        if selected {
+            return sidebarCursorStyle.Render("@" + c.matches[i])
+        }
+        return timestampStyleBase.Render("@" + c.matches[i])
+    })
+}
+
+// mentionAtCursor returns the partial username after the "@" under the
+// cursor and the position of the "@"
+func mentionAtCursor(input textinput.Model) (string, int, bool) {
+    value := []rune(input.Value())
+    pos := input.Position()
+    if pos > len(value) {
+        pos = len(value)
+    }
+
+    start := pos
+    for start > 0 && isMentionRune(value[start-1]) {
+        start--
+    }
+    if start == 0 || value[start-1] != '@' {
+        return "", 0, false
+    }
+    // "@" must start a word, e.g. not an email address
+    if start > 1 && !unicode.IsSpace(value[start-2]) {
+        return "", 0, false
+    }
+
+    return string(value[start:pos]), start - 1, true
+}
+
+func isMentionRune(r rune) bool {
+    return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+// mentionsUser reports whether the content contains "@username"
+func mentionsUser(content, username string) bool {
+    if username == "" {
+        return false
+    }
+
+    lower := []rune(strings.ToLower(content))
+    target := []rune("@" + strings.ToLower(username))
+    for i := 0; i+len(target) <= len(lower); i++ {
+        if string(lower[i:i+len(target)]) != string(target) {
+            continue
+        }
+        if i > 0 && !unicode.IsSpace(lower[i-1]) {
+            continue
+        }
+        end := i + len(target)
+        if end < len(lower) && isMentionRune(lower[end]) && lower[end] != '.' {
+            continue
+        }
+        return true
+    }
+    return false
+}
+
+// mentionCandidates returns the sorted unique names, without the user's own
+func mentionCandidates(names []string, self string) []string {
+    seen := make(map[string]bool)
+    result := make([]string, 0, len(names))
+    for _, name := range names {
+        key := strings.ToLower(name)
+        if name == "" || seen[key] || strings.EqualFold(name, self) {
+            continue
+        }
+        seen[key] = true
+        result = append(result, name)
+    }
+    sort.Slice(result, func(i, j int) bool {
+        return strings.ToLower(result[i]) < strings.ToLower(result[j])
+    })
+    return result
+}
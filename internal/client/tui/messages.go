@@ -2,29 +2,229 @@
 package tui
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"textual/internal/client/i18n"
 	"textual/internal/client/models"
 
-	"github.com/charmbracelet/bubbles/textinput"
-	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
 )
 
+// MessagesView is the private messages page: a list of conversations with
+// friends, and the currently open conversation
 type MessagesView struct {
-    viewport    viewport.Model
-    input       textinput.Model
-    messages    map[string][]models.Message
+    list        list.Model
+    contacts    map[string]models.User
+    selfID      string // the notes are the conversation with the user
     activeChat  *string
+    index       *messageIndex // of the messages of the model
+    width       int
+    height      int
 }
 
 func NewMessagesView() *MessagesView {
+    delegate := list.NewDefaultDelegate()
+    delegate.ShowDescription = true
+
+    l := list.New([]list.Item{}, delegate, 0, 0)
+    l.Title = i18n.T("Conversations")
+    l.SetShowStatusBar(false)
+    l.SetFilteringEnabled(false)
+    l.SetShowHelp(false)
+    l.Styles.Title = titleStyle
+
     return &MessagesView{
-        viewport:   viewport.New(0, 0),
-        input:      textinput.New(),
-        messages:   make(map[string][]models.Message),
+        list:       l,
+        contacts:   make(map[string]models.User),
         activeChat: nil,
+        index:      newMessageIndex(),
+    }
+}
+
+// SetFriends adds the friends to the conversation list, keeping the other known contacts
+func (m *MessagesView) SetFriends(friends []models.User) {
+    for _, friend := range friends {
+        m.contacts[friend.ID] = friend
+    }
+}
+
+// SetSelf adds the notes of the user, the conversation with themselves
+func (m *MessagesView) SetSelf(userID string) {
+    m.selfID = userID
+    m.contacts[userID] = models.User{ID: userID, Username: i18n.T("Notes")}
+}
+
+// IsNotes tells if a conversation is the notes of the user
+func (m *MessagesView) IsNotes(id string) bool {
+    return id != "" && id == m.selfID
+}
+
+// AddContact registers a conversation partner seen in a direct message
+func (m *MessagesView) AddContact(id, username string) {
+    if contact, ok := m.contacts[id]; ok {
+        if contact.Username == "" && username != "" {
+            contact.Username = username
+            m.contacts[id] = contact
+        }
+        return
+    }
+    m.contacts[id] = models.User{ID: id, Username: username}
+}
+
+// SetPresence shows the new status and custom text of a known contact
+func (m *MessagesView) SetPresence(update models.StatusUpdate) {
+    contact, ok := m.contacts[update.UserID]
+    if !ok {
+        return
     }
+    contact.Status = update.Status
+    contact.StatusText = update.Text
+    contact.StatusExpires = update.ExpiresAt
+    m.contacts[update.UserID] = contact
+}
+
+// LookupName returns the username of a known contact
+func (m *MessagesView) LookupName(id string) (string, bool) {
+    contact, ok := m.contacts[id]
+    if !ok || contact.Username == "" || m.IsNotes(id) {
+        return "", false
+    }
+    return contact.Username, true
+}
+
+// ContactName returns the display name of a conversation partner
+func (m *MessagesView) ContactName(id string) string {
+    if contact, ok := m.contacts[id]; ok && contact.Username != "" {
+        return contact.Username
+    }
+    if len(id) > 8 {
+        return id[:8]
+    }
+    return id
 }
 
 func (m *MessagesView) SetActiveChat(id string) {
     m.activeChat = &id
 }
 
+func (m *MessagesView) CloseChat() {
+    m.activeChat = nil
+}
+
+// ActiveChat returns the ID of the open conversation, or "" when the list is shown
+func (m *MessagesView) ActiveChat() string {
+    if m.activeChat == nil {
+        return ""
+    }
+    return *m.activeChat
+}
+
+// SelectedContact returns the conversation highlighted in the list
+func (m *MessagesView) SelectedContact() (string, bool) {
+    if item, ok := m.list.SelectedItem().(conversationItem); ok {
+        return item.user.ID, true
+    }
+    return "", false
+}
+
+// Refresh rebuilds the conversation list, most recent activity first
+func (m *MessagesView) Refresh(messages map[string][]models.Message, unread map[string]int) {
+    items := make([]conversationItem, 0, len(m.contacts))
+    for id, contact := range m.contacts {
+        item := conversationItem{user: contact, unread: unread[id], notes: m.IsNotes(id)}
+        if item.user.Username == "" {
+            item.user.Username = m.ContactName(id)
+        }
+        item.lastMsg, item.hasLastMsg = m.index.Last(id, messages[id])
+        items = append(items, item)
+    }
+
+    sort.Slice(items, func(i, j int) bool {
+        if items[i].notes != items[j].notes {
+            return items[i].notes
+        }
+        if items[i].hasLastMsg != items[j].hasLastMsg {
+            return items[i].hasLastMsg
+        }
+        if items[i].hasLastMsg && !items[i].lastMsg.SentAt.Equal(items[j].lastMsg.SentAt) {
+            return items[i].lastMsg.SentAt.After(items[j].lastMsg.SentAt)
+        }
+        return strings.ToLower(items[i].user.Username) < strings.ToLower(items[j].user.Username)
+    })
+
+    listItems := make([]list.Item, 0, len(items))
+    for _, item := range items {
+        listItems = append(listItems, item)
+    }
+    m.list.SetItems(listItems)
+}
+
+func (m *MessagesView) Update(msg tea.Msg) tea.Cmd {
+    var cmd tea.Cmd
+    m.list, cmd = m.list.Update(msg)
+    return cmd
+}
+
+func (m *MessagesView) View() string {
+    if len(m.list.Items()) == 0 {
+        return i18n.T("No conversations yet. Add friends in the Friends tab to start chatting.")
+    }
+    return m.list.View() + i18n.T("\n\nPress Enter to open a conversation • Esc to come back to the list")
+}
+
+// Header is shown above the open conversation
+func (m *MessagesView) Header() string {
+    id := m.ActiveChat()
+    if id == "" {
+        return ""
+    }
+    if m.IsNotes(id) {
+        return conversationHeaderStyle.Render(i18n.T("📝 Notes")) + " " + timestampStyleBase.Render(i18n.T("only you see them, on all your devices"))
+    }
+    contact := m.contacts[id]
+    header := conversationHeaderStyle.Render(i18n.T("Conversation with %s", m.ContactName(id)))
+    if contact.Status != "" {
+        header += " " + statusIcon(contact.Status)
+    }
+    if text := contact.ActiveStatusText(); text != "" {
+        header += " " + timestampStyleBase.Render(text)
+    }
+    return header
+}
+
+func (m *MessagesView) Resize(width, height int) {
+    m.width = width
+    m.height = height
+    m.list.SetSize(width, height-2)
+}
+
+type conversationItem struct {
+    user       models.User
+    unread     int
+    notes      bool
+    lastMsg    models.Message
+    hasLastMsg bool
+}
+
+func (i conversationItem) Title() string {
+    if i.notes {
+        return "📝 " + i.user.Username
+    }
+    if i.unread > 0 {
+        return i18n.T("%s %s (%d unread)", statusIcon(i.user.Status), i.user.Username, i.unread)
+    }
+    return fmt.Sprintf("%s %s", statusIcon(i.user.Status), i.user.Username)
+}
+
+func (i conversationItem) Description() string {
+    if i.hasLastMsg {
+        return fmt.Sprintf("%s: %s", i.lastMsg.SenderName, i.lastMsg.Content)
+    }
+    return i18n.T("No messages yet")
+}
+
+func (i conversationItem) FilterValue() string {
+    return i.user.Username
+}
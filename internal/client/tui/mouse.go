@@ -0,0 +1,56 @@
+// internal/client/tui/mouse.go
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// handleClick switches page when a tab is clicked, opens the conversation
+// clicked in the sidebar or the link under the pointer
+func (m *Model) handleClick(x, y int) {
+    if m.showHelp || m.switcher.Active() {
+        return
+    }
+
+    // the header is the first line of the screen
+    if y == 0 {
+        if page, ok := m.tabAt(x); ok && page != m.currentPage {
+            m.setPage(page)
+        }
+        return
+    }
+
+    if x < m.sidebar.Width() {
+        if conv, ok := m.sidebar.At(m.conversations(), y-1); ok {
+            m.sidebar.Select(conv.ID)
+            m.switchConversation(conv)
+        }
+        return
+    }
+
+    if url, ok := m.linkAt(x, y); ok {
+        m.openLink(url)
+    }
+}
+
+// linkAt returns the URL drawn at the cell x, y of the screen. The screen is
+// rendered again so the hit-test matches what the user sees on every page
+func (m Model) linkAt(x, y int) (string, bool) {
+    lines := strings.Split(m.View(), "\n")
+    if y < 0 || y >= len(lines) {
+        return "", false
+    }
+
+    line := ansi.Strip(lines[y])
+    for _, loc := range linkLocations(line) {
+        start := runewidth.StringWidth(line[:loc[0]])
+        end := start + runewidth.StringWidth(line[loc[0]:loc[1]])
+        if x >= start && x < end {
+            return line[loc[0]:loc[1]], true
+        }
+    }
+    return "", false
+}
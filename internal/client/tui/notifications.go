@@ -2,30 +2,40 @@
 package tui
 
 import (
-	"fmt"
+	"sort"
 	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
 	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 )
 
+// NotificationView is the Notifications page: friend requests, group invites,
+// mentions and server announcements, newest first. Its Mentions section lists
+// the messages naming the user
 type NotificationView struct {
-    viewport    viewport.Model
-    notifications []models.Message
-    width       int
-    height      int
-    style       lipgloss.Style
+    viewport      viewport.Model
+    notifications []models.Notification
+    mentions      []models.Mention
+    showMentions  bool
+    cursor        int
+    width         int
+    height        int
 }
 
 func NewNotificationView() *NotificationView {
+    vp := viewport.New(0, 0)
+    vp.KeyMap = viewport.KeyMap{} // the cursor moves with the keys, the wheel scrolls
     return &NotificationView{
-        viewport:      viewport.New(0, 0),
-        notifications: make([]models.Message, 0),
-        style: lipgloss.NewStyle().
-            Border(lipgloss.RoundedBorder()).
-            Padding(0, 1),
+        viewport:      vp,
+        notifications: make([]models.Notification, 0),
     }
 }
 
@@ -36,26 +46,401 @@ func (n *NotificationView) Update(msg tea.Msg) tea.Cmd {
 }
 
 func (n *NotificationView) View() string {
-    return n.style.Render(n.viewport.View())
+    if n.showMentions {
+        header := titleStyle.Render(i18n.T("Mentions"))
+        help := timestampStyleBase.Render(i18n.T("j/k move • enter go to the message • @ notifications"))
+        return header + "\n" + n.viewport.View() + "\n" + help
+    }
+    header := titleStyle.Render(i18n.T("Notifications"))
+    help := timestampStyleBase.Render(i18n.T("j/k move • enter open • m mark read • a mark all read • @ mentions"))
+    return header + "\n" + n.viewport.View() + "\n" + help
 }
 
 func (n *NotificationView) Resize(width, height int) {
     n.width = width
     n.height = height
     n.viewport.Width = width
-    n.viewport.Height = height
-    n.style = n.style.Width(width)
+    n.viewport.Height = height - 3 // title, its margin and the help line
+    n.updateContent()
+}
+
+// max number of notifications kept
+const maxNotifications = 100
+
+// SetNotifications replaces the notifications kept by the server, the
+// announcements received since the start stay
+func (n *NotificationView) SetNotifications(notifications []models.Notification) {
+    merged := append([]models.Notification{}, notifications...)
+    for _, notif := range n.notifications {
+        if notif.ID == "" {
+            merged = append(merged, notif)
+        }
+    }
+    sort.SliceStable(merged, func(i, j int) bool {
+        return merged[i].CreatedAt.After(merged[j].CreatedAt)
+    })
+    n.notifications = merged
+    n.trim()
+    n.updateContent()
+}
+
+func (n *NotificationView) AddNotification(notif models.Notification) {
+    if notif.ID != "" {
+        for _, existing := range n.notifications {
+            if existing.ID == notif.ID {
+                return
+            }
+        }
+    }
+    n.notifications = append([]models.Notification{notif}, n.notifications...)
+    // the cursor stays on the same notification
+    if n.cursor > 0 {
+        n.cursor++
+    }
+    n.trim()
+    n.updateContent()
+}
+
+func (n *NotificationView) trim() {
+    if len(n.notifications) > maxNotifications {
+        n.notifications = n.notifications[:maxNotifications]
+    }
+    if n.cursor >= n.length() {
+        n.cursor = max(n.length()-1, 0)
+    }
+}
+
+// length is the number of entries of the section shown
+func (n *NotificationView) length() int {
+    if n.showMentions {
+        return len(n.mentions)
+    }
+    return len(n.notifications)
+}
+
+// SetMentions replaces the messages of the Mentions section
+func (n *NotificationView) SetMentions(mentions []models.Mention) {
+    n.mentions = mentions
+    n.trim()
+    n.updateContent()
 }
 
-func (n *NotificationView) AddNotification(msg models.Message) {
-    n.notifications = append(n.notifications, msg)
+// ToggleMentions switches between the notifications and the mentions, it
+// returns true when the mentions are shown
+func (n *NotificationView) ToggleMentions() bool {
+    n.showMentions = !n.showMentions
+    n.cursor = 0
+    n.viewport.SetYOffset(0)
     n.updateContent()
+    return n.showMentions
+}
+
+func (n *NotificationView) ShowingMentions() bool {
+    return n.showMentions
+}
+
+func (n *NotificationView) SelectedMention() (models.Mention, bool) {
+    if !n.showMentions || n.cursor >= len(n.mentions) {
+        return models.Mention{}, false
+    }
+    return n.mentions[n.cursor], true
+}
+
+// Unread counts the notifications not read yet
+func (n *NotificationView) Unread() int {
+    count := 0
+    for _, notif := range n.notifications {
+        if !notif.Read {
+            count++
+        }
+    }
+    return count
+}
+
+func (n *NotificationView) Move(delta int) {
+    n.cursor = min(max(n.cursor+delta, 0), max(n.length()-1, 0))
+    n.updateContent()
+    n.scrollToCursor()
+}
+
+func (n *NotificationView) Selected() (models.Notification, bool) {
+    if n.showMentions || n.cursor >= len(n.notifications) {
+        return models.Notification{}, false
+    }
+    return n.notifications[n.cursor], true
+}
+
+// MarkSelectedRead marks the selected notification as read, it returns the ID
+// to send to the server, "" when there is nothing to sync
+func (n *NotificationView) MarkSelectedRead() string {
+    if n.cursor >= len(n.notifications) || n.notifications[n.cursor].Read {
+        return ""
+    }
+    n.notifications[n.cursor].Read = true
+    n.updateContent()
+    return n.notifications[n.cursor].ID
+}
+
+// MarkAllRead marks every notification as read, it reports whether some of
+// them are kept by the server
+func (n *NotificationView) MarkAllRead() bool {
+    sync := false
+    for i := range n.notifications {
+        if !n.notifications[i].Read && n.notifications[i].ID != "" {
+            sync = true
+        }
+        n.notifications[i].Read = true
+    }
+    n.updateContent()
+    return sync
+}
+
+// each notification takes two lines
+func (n *NotificationView) scrollToCursor() {
+    line := n.cursor * 2
+    if line < n.viewport.YOffset {
+        n.viewport.SetYOffset(line)
+    } else if line+2 > n.viewport.YOffset+n.viewport.Height {
+        n.viewport.SetYOffset(line + 2 - n.viewport.Height)
+    }
 }
 
 func (n *NotificationView) updateContent() {
+    if n.showMentions {
+        n.updateMentions()
+        return
+    }
+    if len(n.notifications) == 0 {
+        n.viewport.SetContent(timestampStyleBase.Render(i18n.T("No notification")))
+        return
+    }
+
     var sb strings.Builder
-    for _, notif := range n.notifications {
-        sb.WriteString(fmt.Sprintf("New message from %s\n", notif.SenderID))
+    for i, notif := range n.notifications {
+        marker := " "
+        if i == n.cursor {
+            marker = selectionMarkerStyle.Render("▌")
+        }
+        title := notificationTitle(notif)
+        if !notif.Read {
+            title = badgeStyle.Render("●") + " " + friendTitleStyle.Render(title)
+        } else {
+            title = "  " + title
+        }
+        sb.WriteString(marker + title + "\n")
+
+        detail := i18n.FormatTimestamp(i18n.InZone(notif.CreatedAt), time.Now())
+        if text := notificationText(notif); text != "" {
+            detail += " " + text
+        }
+        sb.WriteString("   " + sidebarPreviewStyle.Render(runewidth.Truncate(detail, max(n.width-3, 10), "…")) + "\n")
+    }
+    n.viewport.SetContent(sb.String())
+}
+
+// updateMentions draws the Mentions section, two lines by message as the
+// notifications
+func (n *NotificationView) updateMentions() {
+    if len(n.mentions) == 0 {
+        n.viewport.SetContent(timestampStyleBase.Render(i18n.T("Nobody mentioned you")))
+        return
+    }
+
+    var sb strings.Builder
+    for i, mention := range n.mentions {
+        marker := " "
+        if i == n.cursor {
+            marker = selectionMarkerStyle.Render("▌")
+        }
+        place := "global"
+        if mention.GroupID != "" {
+            place = mention.GroupName
+        }
+        sb.WriteString(marker + "  " + i18n.T("@ %s in %s", mention.Sender, place) + "\n")
+
+        detail := i18n.FormatTimestamp(i18n.InZone(mention.SentAt), time.Now()) + " " + strings.ReplaceAll(mention.Content, "\n", " ")
+        sb.WriteString("   " + sidebarPreviewStyle.Render(runewidth.Truncate(detail, max(n.width-3, 10), "…")) + "\n")
     }
     n.viewport.SetContent(sb.String())
 }
+
+// notificationTitle describes a notification in one line
+func notificationTitle(notif models.Notification) string {
+    switch notif.Kind {
+    case protocol.NoticeFriendRequest:
+        return i18n.T("📨 %s sent you a friend request", notif.Actor)
+    case protocol.NoticeGroupInvite:
+        return i18n.T("👥 %s added you to %s", notif.Actor, notif.Content)
+    case protocol.NoticeMention:
+        return i18n.T("@ %s mentioned you", notif.Actor)
+    case protocol.NoticeGroupPromoted:
+        return i18n.T("⭐ %s made you an admin of %s", notif.Actor, notif.Content)
+    case protocol.NoticeGroupDemoted:
+        return i18n.T("%s removed you from the admins of %s", notif.Actor, notif.Content)
+    case protocol.NoticeAnnouncement:
+        return i18n.T("📢 Announcement from %s", notif.Actor)
+    }
+    return i18n.T("📢 Server announcement")
+}
+
+// notificationText is the text shown under the title, on one line
+func notificationText(notif models.Notification) string {
+    switch notif.Kind {
+    case protocol.NoticeFriendRequest, protocol.NoticeGroupInvite, protocol.NoticeGroupPromoted, protocol.NoticeGroupDemoted:
+        return ""
+    }
+    return strings.ReplaceAll(notif.Content, "\n", " ")
+}
+
+// loadNotifications requests the notifications kept by the server
+func (m *Model) loadNotifications() {
+    if m.connection == nil {
+        return
+    }
+    if err := m.connection.LoadNotifications(); err != nil {
+        logging.Errorf("Failed to load notifications: %v", err)
+    }
+}
+
+// loadMentions requests the messages naming the user
+func (m *Model) loadMentions() {
+    if m.connection == nil {
+        return
+    }
+    if err := m.connection.LoadMentions(); err != nil {
+        logging.Errorf("Failed to load mentions: %v", err)
+    }
+}
+
+// markNotificationsRead tells the server the notifications were read, all of
+// them when ids is empty
+func (m *Model) markNotificationsRead(ids []string) {
+    if m.connection == nil {
+        return
+    }
+    if err := m.connection.MarkNotificationsRead(ids); err != nil {
+        logging.Errorf("Failed to mark notifications as read: %v", err)
+    }
+}
+
+// loadNotificationLevels requests the notification levels saved on the server
+// when they are synced
+func (m *Model) loadNotificationLevels() {
+    if !m.config.Notifications.Sync {
+        return
+    }
+    m.sendNotificationLevels(nil)
+}
+
+// sendNotificationLevels saves levels on the server, nil only requests them
+func (m *Model) sendNotificationLevels(levels map[string]string) {
+    if m.connection == nil {
+        return
+    }
+    if err := m.connection.SendNotificationLevels(levels); err != nil {
+        logging.Errorf("Failed to sync notification levels: %v", err)
+    }
+}
+
+// adoptNotificationLevels replaces the levels of the config by the ones of the
+// server. The first sync uploads the levels set before it was enabled
+func (m *Model) adoptNotificationLevels(levels map[string]string) {
+    if !m.config.Notifications.Sync {
+        return
+    }
+    n := &m.config.Notifications
+    if len(levels) == 0 && (len(n.Levels) > 0 || len(n.Muted) > 0) {
+        local := make(map[string]string)
+        for _, chatID := range n.Muted {
+            local[chatID] = config.LevelNone
+        }
+        for chatID, level := range n.Levels {
+            local[chatID] = level
+        }
+        m.sendNotificationLevels(local)
+        return
+    }
+
+    n.Muted = nil
+    n.Levels = levels
+    m.saveConfig()
+    m.shareNotificationLevels()
+}
+
+// shareNotificationLevels gives the group view the levels of the config, the
+// member panel shows the one of its group
+func (m *Model) shareNotificationLevels() {
+    if m.groupsView == nil {
+        return
+    }
+    levels := make(map[string]string)
+    for _, chatID := range m.config.Notifications.Muted {
+        levels[chatID] = config.LevelNone
+    }
+    for chatID, level := range m.config.Notifications.Levels {
+        levels[chatID] = level
+    }
+    m.groupsView.levels = levels
+}
+
+// handleNotificationKey runs the keys of the Notifications page
+func (m *Model) handleNotificationKey(msg tea.KeyMsg) {
+    switch {
+    case key.Matches(msg, notificationKeys.Down):
+        m.notifications.Move(1)
+    case key.Matches(msg, notificationKeys.Up):
+        m.notifications.Move(-1)
+    case key.Matches(msg, notificationKeys.Mentions):
+        if m.notifications.ToggleMentions() {
+            m.loadMentions()
+        }
+    case m.notifications.ShowingMentions():
+        // the mentions are not marked read
+    case key.Matches(msg, notificationKeys.Read):
+        if id := m.notifications.MarkSelectedRead(); id != "" {
+            m.markNotificationsRead([]string{id})
+        }
+    case key.Matches(msg, notificationKeys.ReadAll):
+        if m.notifications.MarkAllRead() {
+            m.markNotificationsRead(nil)
+        }
+    }
+}
+
+// openMention opens the conversation of the selected mention and selects the
+// message
+func (m *Model) openMention() {
+    mention, ok := m.notifications.SelectedMention()
+    if !ok {
+        return
+    }
+    if mention.GroupID == "" {
+        m.jumpTo(conversation{ID: "global", Kind: globalConversation, Name: "global"}, mention.MessageID)
+    } else {
+        m.jumpTo(conversation{ID: mention.GroupID, Kind: groupConversation, Name: mention.GroupName}, mention.MessageID)
+    }
+}
+
+// openNotification marks the selected notification as read and opens what it
+// is about: the Friends page for a request, the conversation for the others
+func (m *Model) openNotification() {
+    notif, ok := m.notifications.Selected()
+    if !ok {
+        return
+    }
+    if id := m.notifications.MarkSelectedRead(); id != "" {
+        m.markNotificationsRead([]string{id})
+    }
+
+    switch notif.Kind {
+    case protocol.NoticeFriendRequest:
+        m.setPage(FriendsPage)
+    case protocol.NoticeGroupInvite, protocol.NoticeMention, protocol.NoticeGroupPromoted, protocol.NoticeGroupDemoted:
+        if notif.RelatedID == "" {
+            m.switchConversation(conversation{ID: "global", Kind: globalConversation, Name: "global"})
+        } else {
+            m.switchConversation(conversation{ID: notif.RelatedID, Kind: groupConversation, Name: m.groupName(notif.RelatedID)})
+        }
+        m.sidebar.Select(m.activeConversation())
+    }
+}
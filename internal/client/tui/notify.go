@@ -0,0 +1,53 @@
+// internal/client/tui/notify.go
+package tui
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/logging"
+)
+
+// longest message body shown in a desktop notification
+const maxNotificationBody = 200
+
+// notify rings the terminal bell and shows a desktop notification, as enabled in the settings
+func notify(settings config.Notifications, title, body string) {
+    if settings.Bell {
+        os.Stdout.WriteString("\a")
+    }
+
+    if !settings.Desktop {
+        return
+    }
+
+    if len(body) > maxNotificationBody {
+        body = body[:maxNotificationBody] + "…"
+    }
+
+    var cmd *exec.Cmd
+    switch runtime.GOOS {
+    case "darwin":
+        script := "display notification " + appleScriptString(body) + " with title " + appleScriptString(title)
+        cmd = exec.Command("osascript", "-e", script)
+    case "linux", "freebsd", "openbsd":
+        cmd = exec.Command("notify-send", "--app-name=Textual", title, body)
+    default:
+        return
+    }
+
+    if err := cmd.Start(); err != nil {
+        logging.Errorf("Failed to show desktop notification: %v", err)
+        return
+    }
+    go cmd.Wait()
+}
+
+// appleScriptString quotes a string for osascript
+func appleScriptString(s string) string {
+    s = strings.ReplaceAll(s, `\`, `\\`)
+    s = strings.ReplaceAll(s, `"`, `\"`)
+    return `"` + s + `"`
+}
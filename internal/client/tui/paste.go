@@ -0,0 +1,87 @@
+// internal/client/tui/paste.go
+package tui
+
+import (
+	"errors"
+	"strings"
+	"textual/internal/client/i18n"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pendingPaste is a multi-line paste waiting for the user to choose how it
+// is sent
+type pendingPaste struct {
+    chatID string
+    text   string
+}
+
+// isMultilinePaste tells if the key is a bracketed paste of several lines.
+// Without bracketed paste the terminal sends the lines as typed, the first
+// newline then sends the message
+func isMultilinePaste(msg tea.KeyMsg) bool {
+    return msg.Paste && strings.ContainsAny(string(msg.Runes), "\r\n")
+}
+
+// startPaste keeps a multi-line paste and asks whether to send it as a code
+// block, the text already typed comes first
+func (m *Model) startPaste(msg tea.KeyMsg) {
+    chatID := m.activeConversation()
+    text := strings.ReplaceAll(string(msg.Runes), "\r\n", "\n")
+    text = strings.ReplaceAll(text, "\r", "\n")
+    text = strings.Trim(m.pasteInput().Value()+text, "\n")
+
+    m.paste = &pendingPaste{chatID: chatID, text: text}
+    m.notice = i18n.T("Paste of %d lines: send as a code block? [y]es, [n]o as text, esc to cancel", strings.Count(text, "\n")+1)
+}
+
+// pasteInput is the input of the open chat, the groups have their own
+func (m *Model) pasteInput() *textinput.Model {
+    if m.currentPage == GroupsPage && m.groupsView != nil {
+        return &m.groupsView.input
+    }
+    return &m.input
+}
+
+// handlePasteKey sends the paste as a code block on "y", as text on "n",
+// any other key drops it
+func (m *Model) handlePasteKey(key string) tea.Cmd {
+    paste := m.paste
+    m.paste = nil
+    m.notice = ""
+
+    content := paste.text
+    switch key {
+    case "y":
+        content = "```\n" + content + "\n```"
+    case "n":
+    default:
+        return nil
+    }
+    if m.offline() {
+        m.err = errors.New(i18n.T("offline: the paste was not sent"))
+        return nil
+    }
+    if m.currentPage == GlobalPage && m.coolingDown() {
+        m.err = errors.New(i18n.T("Slow down: you can send again in %ds", m.cooldownLeft()))
+        return nil
+    }
+    // the conversation may have changed behind the prompt
+    if paste.chatID != m.activeConversation() {
+        return nil
+    }
+
+    var recipientID, groupID *string
+    switch {
+    case m.currentPage == MessagesPage:
+        recipientID = &paste.chatID
+    case m.currentPage == GroupsPage:
+        groupID = &paste.chatID
+    }
+    m.pasteInput().Reset()
+    cmd := m.sendPending(newPendingMessage(m.userID, m.username, content, recipientID, groupID))
+    m.updateContent()
+    m.viewport.GotoBottom()
+    return cmd
+}
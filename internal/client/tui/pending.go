@@ -0,0 +1,255 @@
+// internal/client/tui/pending.go
+package tui
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// how long a sent message waits for the server before it is marked failed
+const sendTimeout = 10 * time.Second
+
+// sendTimeoutMsg is sent once the server had sendTimeout to store a message
+type sendTimeoutMsg struct {
+    clientID string
+}
+
+// pendingSendMsg is sent by the group chat to send a message typed in its input
+type pendingSendMsg struct {
+    message models.Message
+}
+
+// retryMsg is sent by the group chat to send a failed message again
+type retryMsg struct {
+    message models.Message
+}
+
+// newClientID returns a random key identifying a message sent from this client
+func newClientID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return fmt.Sprintf("%x", time.Now().UnixNano())
+    }
+    return hex.EncodeToString(b)
+}
+
+// newPendingMessage builds the copy of a message shown until the server stores it
+func newPendingMessage(userID, username, content string, recipientID, groupID *string) models.Message {
+    return models.Message{
+        Content:     content,
+        SenderID:    userID,
+        SenderName:  username,
+        RecipientID: recipientID,
+        GroupID:     groupID,
+        SentAt:      time.Now(),
+        ClientID:    newClientID(),
+        SendState:   models.SendPending,
+    }
+}
+
+// sendPending shows the message right away and sends it
+func (m *Model) sendPending(msg models.Message) tea.Cmd {
+    if msg.ThreadRootID != nil {
+        if m.thread != nil {
+            m.thread.addReplies(msg)
+            m.updateThread()
+        }
+        return m.transmit(msg)
+    }
+    m.storeMessages(m.getChatID(msg), msg)
+    if msg.IsGroup() && m.groupsView != nil {
+        m.groupsView.AddMessage(msg)
+    }
+    return m.transmit(msg)
+}
+
+// retry sends a failed message again, with the same client ID so the server
+// does not store it twice if the first try went through
+func (m *Model) retry(msg models.Message) tea.Cmd {
+    if msg.SendState != models.SendFailed {
+        return nil
+    }
+    m.setSendState(msg.ClientID, models.SendPending)
+    return m.transmit(msg)
+}
+
+// transmit hands the message to the connection and waits for the server echo
+func (m *Model) transmit(msg models.Message) tea.Cmd {
+    if m.onSendMessage == nil {
+        return nil
+    }
+    var err error
+    if msg.Voice != nil && m.connection != nil {
+        err = m.connection.SendVoiceMessage(*msg.Voice, msg.ClientID, msg.RecipientID, msg.GroupID)
+    } else if msg.ThreadRootID != nil && m.connection != nil {
+        err = m.connection.SendReply(msg.Content, msg.ClientID, *msg.ThreadRootID, msg.RecipientID, msg.GroupID)
+    } else {
+        err = m.onSendMessage(msg.Content, msg.ClientID, msg.RecipientID, msg.GroupID)
+    }
+    if err != nil {
+        logging.Errorf("Error sending message: %v", err)
+        m.setSendState(msg.ClientID, models.SendFailed)
+        return nil
+    }
+
+    clientID := msg.ClientID
+    return tea.Tick(sendTimeout, func(time.Time) tea.Msg {
+        return sendTimeoutMsg{clientID: clientID}
+    })
+}
+
+// pendingMessage finds a message not acknowledged yet
+func (m Model) pendingMessage(clientID string) (models.Message, bool) {
+    if m.thread != nil {
+        for _, msg := range m.thread.replies {
+            if msg.ClientID == clientID && msg.SendState != "" {
+                return msg, true
+            }
+        }
+    }
+    for _, chat := range m.messages {
+        for _, msg := range chat {
+            if msg.ClientID == clientID && msg.SendState != "" {
+                return msg, true
+            }
+        }
+    }
+    return models.Message{}, false
+}
+
+// setSendState changes the state of a message not acknowledged yet
+func (m *Model) setSendState(clientID, state string) {
+    if m.thread != nil {
+        for i := range m.thread.replies {
+            if m.thread.replies[i].ClientID == clientID && m.thread.replies[i].SendState != "" {
+                m.thread.replies[i].SendState = state
+                m.updateThread()
+                return
+            }
+        }
+    }
+    for chatID, chat := range m.messages {
+        for i := range chat {
+            if chat[i].ClientID != clientID || chat[i].SendState == "" {
+                continue
+            }
+            chat[i].SendState = state
+            if chat[i].IsGroup() && m.groupsView != nil {
+                m.groupsView.setSendState(chatID, clientID, state)
+            }
+            if chatID == m.selectedChat {
+                m.updateContent()
+            }
+            return
+        }
+    }
+}
+
+func (g *GroupsView) setSendState(groupID, clientID, state string) {
+    for i, msg := range g.messages[groupID] {
+        if msg.ClientID == clientID {
+            g.messages[groupID][i].SendState = state
+        }
+    }
+    if groupID == g.selectedGroup {
+        g.updateContent()
+    }
+}
+
+// replacePending drops the pending copy of a message the server echoed back
+func replacePending(chat []models.Message, msg models.Message) []models.Message {
+    if msg.ClientID == "" || msg.SendState != "" {
+        return chat
+    }
+    for i, existing := range chat {
+        if existing.ClientID == msg.ClientID && existing.SendState != "" {
+            return append(chat[:i:i], chat[i+1:]...)
+        }
+    }
+    return chat
+}
+
+// renderSendState styles the text of an own message not stored yet: dimmed
+// with a clock while pending, red while failed
+func renderSendState(msg models.Message, content string) string {
+    switch msg.SendState {
+    case models.SendPending:
+        return content + timestampStyleBase.Render(" 🕓")
+    case models.SendFailed:
+        return lipgloss.NewStyle().Foreground(currentTheme.Error).Render(msg.Content) +
+            errorStyle.Render(" "+i18n.T("(failed — press r to retry)"))
+    }
+    // delivered, the recipient is notified once out of do not disturb
+    if msg.RecipientDND {
+        return content + timestampStyleBase.Render(" ⛔")
+    }
+    return content
+}
+
+// renderStatus marks an own direct message stored by the server: one check
+// once sent, two once delivered to a device of the recipient, colored once
+// read
+func renderStatus(msg models.Message) string {
+    if msg.SendState != "" || !msg.IsDirect() {
+        return ""
+    }
+    switch msg.Status {
+    case protocol.MessageSent:
+        return timestampStyleBase.Render(" ✓")
+    case protocol.MessageDelivered:
+        return timestampStyleBase.Render(" ✓✓")
+    case protocol.MessageRead:
+        return readStyle.Render(" ✓✓")
+    }
+    return ""
+}
+
+// statusRank orders the statuses of a message, it never goes back: a late
+// delivery doesn't undo a read
+func statusRank(status string) int {
+    switch status {
+    case protocol.MessageSent:
+        return 1
+    case protocol.MessageDelivered:
+        return 2
+    case protocol.MessageRead:
+        return 3
+    }
+    return 0
+}
+
+// setMessageStatus moves the own messages of a direct conversation, up to
+// messageID or all of them, to status
+func (m *Model) setMessageStatus(chatID, messageID, status string) {
+    chat := m.messages[chatID]
+    end := len(chat)
+    for i := range chat {
+        if messageID != "" && chat[i].ID == messageID {
+            end = i + 1
+            break
+        }
+    }
+    changed := false
+    for i := range chat[:end] {
+        // the pending and the encrypted messages have no status
+        if chat[i].SenderID != m.userID || chat[i].Status == "" {
+            continue
+        }
+        if statusRank(status) > statusRank(chat[i].Status) {
+            chat[i].Status = status
+            changed = true
+        }
+    }
+    if changed && chatID == m.selectedChat {
+        m.updateContent()
+    }
+}
@@ -0,0 +1,120 @@
+// internal/client/tui/permalinks.go
+package tui
+
+import (
+	"errors"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+)
+
+// messageRef returns the permalink of a message stored by the server
+func messageRef(msg models.Message) (protocol.MessageRef, error) {
+    if msg.ID == "" || msg.SendState != "" || msg.IsSystem() || msg.Encrypted {
+        return protocol.MessageRef{}, errors.New(i18n.T("This message has no link"))
+    }
+    var groupID, recipientID string
+    if msg.GroupID != nil {
+        groupID = *msg.GroupID
+    }
+    if msg.RecipientID != nil {
+        recipientID = *msg.RecipientID
+    }
+    return protocol.NewMessageRef(groupID, msg.SenderID, recipientID, msg.ID), nil
+}
+
+// copyMessageLink copies the permalink of a message, /goto opens it
+func copyMessageLink(msg models.Message) error {
+    ref, err := messageRef(msg)
+    if err != nil {
+        return err
+    }
+    return copyToClipboard(ref.String())
+}
+
+// gotoCommand opens the conversation of a permalink with its message selected
+func (m *Model) gotoCommand(args string) {
+    ref, err := protocol.ParseMessageRef(args)
+    if err != nil {
+        m.err = errors.New(i18n.T("Not a message link: %s", args))
+        return
+    }
+
+    switch {
+    case ref.Conversation == "global":
+        m.jumpTo(conversation{ID: "global", Kind: globalConversation, Name: "global"}, ref.MessageID)
+    case ref.GroupID() != "":
+        m.jumpTo(conversation{ID: ref.GroupID(), Kind: groupConversation, Name: m.groupName(ref.GroupID())}, ref.MessageID)
+    case ref.Peer(m.userID) != "":
+        m.jumpTo(conversation{ID: ref.Peer(m.userID), Kind: directConversation}, ref.MessageID)
+    default:
+        m.err = errors.New(i18n.T("This message is in a conversation of other users"))
+    }
+}
+
+// jumpTo opens a conversation and selects one of its messages. A message
+// not loaded is fetched with the messages sent since, the selection then
+// waits for them
+func (m *Model) jumpTo(conv conversation, messageID string) {
+    m.switchConversation(conv)
+    chatID := m.activeConversation()
+    if chatID == "" {
+        return
+    }
+    m.sidebar.Select(chatID)
+    if m.jumpToMessage(messageID) {
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    if err := m.connection.FetchContext(messageID, m.oldestLoaded(chatID), m.config.History.Page()); err != nil {
+        m.err = err
+        return
+    }
+    m.pendingJump = messageID
+}
+
+// oldestLoaded returns the oldest message of a chat the server knows, the
+// messages fetched around a message go up to it
+func (m *Model) oldestLoaded(chatID string) string {
+    for _, msg := range m.messages[chatID] {
+        if msg.ID != "" && msg.SendState == "" && !msg.IsSystem() && !msg.Encrypted {
+            return msg.ID
+        }
+    }
+    return ""
+}
+
+// applyContext stores the messages fetched around a message and selects it
+// when the jump is still awaited. The message of a thread reply is its root
+func (m *Model) applyContext(msg models.ContextLoaded) {
+    if !msg.Complete {
+        if m.pendingJump != "" {
+            m.notice = i18n.T("The message is too far back to be shown in the conversation")
+            m.pendingJump = ""
+        }
+        return
+    }
+
+    m.applyHistory(models.HistoryLoaded{
+        Messages:    msg.Messages,
+        RecipientID: msg.RecipientID,
+        GroupID:     msg.GroupID,
+    })
+    chatID := "global"
+    if msg.GroupID != "" {
+        chatID = msg.GroupID
+    } else if msg.RecipientID != "" {
+        chatID = msg.RecipientID
+    }
+    if m.pendingJump == "" || chatID != m.activeConversation() {
+        return
+    }
+    m.pendingJump = ""
+    if !m.jumpToMessage(msg.MessageID) {
+        m.notice = i18n.T("Message not found")
+    }
+}
@@ -0,0 +1,103 @@
+// internal/client/tui/prefs.go
+package tui
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// applyDisplayPrefs writes the times and the interface with the preferences
+// of the user, the config of this device fills the fields left empty
+func (m *Model) applyDisplayPrefs(prefs models.DisplayPrefs) {
+    m.displayPrefs = prefs
+
+    timezone, clock, locale := prefs.Timezone, prefs.TimeFormat, prefs.Locale
+    if timezone == "" {
+        timezone = m.config.Timezone
+    }
+    if clock == "" {
+        clock = m.config.TimeFormat
+    }
+    if locale == "" {
+        locale = m.config.Locale
+    }
+    if err := i18n.SetTimezone(timezone); err != nil {
+        logging.Errorf("Invalid timezone: %v", err)
+    }
+    if err := i18n.SetClock(clock); err != nil {
+        logging.Errorf("Invalid time format: %v", err)
+    }
+    if err := i18n.SetLocale(locale); err != nil {
+        logging.Errorf("Invalid locale: %v", err)
+    }
+    m.updateContent()
+}
+
+// prefsCommand shows the display preferences, or changes one of them on all
+// the devices of the user; "default" gives it back to each device
+func (m *Model) prefsCommand(args string) {
+    fields := strings.Fields(args)
+    if len(fields) == 0 {
+        m.notice = i18n.T("Timezone %s, clock %s, language %s", prefValue(m.displayPrefs.Timezone),
+            prefValue(m.displayPrefs.TimeFormat), prefValue(m.displayPrefs.Locale))
+        return
+    }
+    usage := errors.New(i18n.T("usage: /prefs [timezone <name>|clock <24h|12h>|locale <%s>], default for the setting of each device", strings.Join(i18n.Locales(), "|")))
+    if len(fields) != 2 {
+        m.err = usage
+        return
+    }
+
+    prefs := m.displayPrefs
+    value := fields[1]
+    if value == "default" {
+        value = ""
+    }
+    switch fields[0] {
+    case "timezone":
+        // "Local" would be the timezone of each device, that is the default
+        if _, err := time.LoadLocation(value); value != "" && (err != nil || value == "Local") {
+            m.err = errors.New(i18n.T("Unknown timezone %s, use a name such as Europe/Paris", value))
+            return
+        }
+        prefs.Timezone = value
+    case "clock":
+        if value != "" && value != protocol.TimeFormat24h && value != protocol.TimeFormat12h {
+            m.err = usage
+            return
+        }
+        prefs.TimeFormat = value
+    case "locale":
+        if value != "" && !slices.Contains(i18n.Locales(), value) {
+            m.err = usage
+            return
+        }
+        prefs.Locale = value
+    default:
+        m.err = usage
+        return
+    }
+
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+    // applied once the server sends them back
+    if err := m.connection.SetDisplayPrefs(prefs); err != nil {
+        m.err = err
+    }
+}
+
+// prefValue writes a display preference, empty for the one of the device
+func prefValue(value string) string {
+    if value == "" {
+        return i18n.T("of this device")
+    }
+    return value
+}
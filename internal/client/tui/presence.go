@@ -0,0 +1,90 @@
+// internal/client/tui/presence.go
+package tui
+
+import (
+	"errors"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+	"time"
+)
+
+// statusCommand runs /status: "for <duration>" first makes the text clear
+// itself, e.g. /status for 1h in a meeting
+func (m *Model) statusCommand(args string) {
+    text, expires, err := parseStatusText(args, time.Now())
+    if err != nil {
+        m.err = err
+        return
+    }
+    m.setStatus(m.status, text, expires)
+}
+
+// parseStatusText splits the arguments of /status into the text and the time
+// it expires, zero when it doesn't. Without a duration after "for" the whole
+// is the text ("for lunch")
+func parseStatusText(args string, now time.Time) (string, time.Time, error) {
+    fields := strings.Fields(args)
+    if len(fields) < 2 || fields[0] != "for" {
+        return strings.TrimSpace(args), time.Time{}, nil
+    }
+    d, err := time.ParseDuration(fields[1])
+    if err != nil || d <= 0 {
+        return strings.TrimSpace(args), time.Time{}, nil
+    }
+    text := strings.Join(fields[2:], " ")
+    if text == "" {
+        return "", time.Time{}, errors.New(i18n.T("a status for %s needs a text", d))
+    }
+    return text, now.Add(d), nil
+}
+
+// activeStatusText is the user's own custom status, unless it expired
+func (m Model) activeStatusText() string {
+    return models.User{StatusText: m.statusText, StatusExpires: m.statusExpires}.ActiveStatusText()
+}
+
+// quiet tells if the badges are hidden, in do not disturb
+func (m Model) quiet() bool {
+    return m.status == models.StatusDND
+}
+
+// withoutBadges clears the unread and mention counts of the conversations
+func withoutBadges(convs []conversation) []conversation {
+    quiet := make([]conversation, len(convs))
+    for i, conv := range convs {
+        conv.Unread, conv.Mentions = 0, 0
+        quiet[i] = conv
+    }
+    return quiet
+}
+
+// updatePresence shows the new status of a user next to their name, and
+// follows the user's own status set from another session
+func (m *Model) updatePresence(update models.StatusUpdate) {
+    if update.UserID == m.userID {
+        // the server says offline when the other session closes
+        if update.Status != models.StatusOffline {
+            m.status = update.Status
+        }
+        m.statusText, m.statusExpires = update.Text, update.ExpiresAt
+        return
+    }
+    if m.friendsView != nil {
+        m.friendsView.SetPresence(update)
+    }
+    m.messagesView.SetPresence(update)
+    m.refreshContacts()
+}
+
+// refreshContacts redraws the Friends page or the conversation list when
+// shown, the header of a direct conversation is drawn with the page
+func (m *Model) refreshContacts() {
+    switch {
+    case m.currentPage == FriendsPage:
+        m.updateContent()
+    case m.currentPage == MessagesPage && m.selectedChat == "":
+        m.messagesView.Refresh(m.messages, m.unread)
+        m.updateContent()
+    }
+}
@@ -0,0 +1,56 @@
+// internal/client/tui/previews.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/models"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the previews of the links sent by the server, drawn under their message
+var linkPreviewsEnabled = true
+
+// SetLinkPreviews shows or hides the previews of the links
+func SetLinkPreviews(enabled bool) {
+    linkPreviewsEnabled = enabled
+}
+
+// renderLinkPreview draws the preview of the link of a message under its
+// text, starting at indent:
+//
+//	▎ Title · Site 🖼
+//	▎ The description, cut to the width
+func renderLinkPreview(msg models.Message, width, indent int) string {
+    preview := msg.Preview
+    if !linkPreviewsEnabled || preview == nil {
+        return ""
+    }
+    width -= indent + 2
+    if width < minWrapWidth {
+        width = minWrapWidth
+    }
+
+    bar := strings.Repeat(" ", indent) + lipgloss.NewStyle().Foreground(currentTheme.Primary).Render("▎") + " "
+    var sb strings.Builder
+    if preview.Title != "" {
+        title := preview.Title
+        if preview.SiteName != "" && preview.SiteName != title {
+            title += " · " + preview.SiteName
+        }
+        title = lipgloss.NewStyle().Bold(true).Render(truncateWidth(title, width-3))
+        if hyperlinksEnabled {
+            title = fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", preview.URL, title)
+            // terminals can't draw the image, the link opens it
+            if preview.ImageURL != "" {
+                title += fmt.Sprintf(" \x1b]8;;%s\x1b\\🖼\x1b]8;;\x1b\\", preview.ImageURL)
+            }
+        }
+        sb.WriteString(bar + title + "\n")
+    }
+    if preview.Description != "" {
+        sb.WriteString(bar + timestampStyleBase.Render(truncateWidth(preview.Description, width)) + "\n")
+    }
+    return sb.String()
+}
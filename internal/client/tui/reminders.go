@@ -0,0 +1,141 @@
+// internal/client/tui/reminders.go
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+	"time"
+)
+
+const remindUsage = "usage: /remind me in <duration> <text>, /remind me at <hh:mm> <text>, /remind list or /remind cancel <number>"
+
+// remindCommand runs /remind. The server keeps the reminder and sends it back
+// as a direct message of the system account, even if the client is closed
+func (m *Model) remindCommand(args string) {
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    fields := strings.Fields(args)
+    switch {
+    case len(fields) == 0 || len(fields) == 1 && fields[0] == "list":
+        if err := m.connection.RequestReminders(); err != nil {
+            m.err = err
+        }
+        return
+    case fields[0] == "cancel":
+        m.cancelReminder(fields[1:])
+        return
+    }
+
+    text, at, err := parseReminder(args, i18n.InZone(time.Now()))
+    if err != nil {
+        m.err = err
+        return
+    }
+    if err := m.connection.CreateReminder(text, at); err != nil {
+        m.err = err
+    }
+}
+
+// parseReminder reads "[me] in <duration> <text>" or "[me] at <hh:mm> <text>",
+// the text may be quoted. A time already past today is for tomorrow
+func parseReminder(args string, now time.Time) (string, time.Time, error) {
+    fields := strings.Fields(args)
+    if len(fields) > 0 && fields[0] == "me" {
+        fields = fields[1:]
+    }
+    if len(fields) < 2 {
+        return "", time.Time{}, errors.New(i18n.T(remindUsage))
+    }
+
+    var at time.Time
+    switch fields[0] {
+    case "in":
+        d, err := parseReminderDelay(fields[1])
+        if err != nil || d <= 0 {
+            return "", time.Time{}, errors.New(i18n.T("invalid duration %s, e.g. 30m, 2h or 3d", fields[1]))
+        }
+        at = now.Add(d)
+    case "at":
+        clock, err := time.Parse("15:04", fields[1])
+        if err != nil {
+            return "", time.Time{}, errors.New(i18n.T("invalid time %s, e.g. 9:00 or 14:30", fields[1]))
+        }
+        at = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+        if !at.After(now) {
+            at = at.AddDate(0, 0, 1)
+        }
+    default:
+        return "", time.Time{}, errors.New(i18n.T(remindUsage))
+    }
+
+    text := strings.TrimSpace(strings.Trim(strings.Join(fields[2:], " "), `"“”`))
+    if text == "" {
+        return "", time.Time{}, errors.New(i18n.T("a reminder needs a text"))
+    }
+    return text, at, nil
+}
+
+// parseReminderDelay reads a duration, days ("3d") on top of the units of
+// time.ParseDuration
+func parseReminderDelay(s string) (time.Duration, error) {
+    if days, ok := strings.CutSuffix(s, "d"); ok {
+        n, err := strconv.Atoi(days)
+        if err != nil {
+            return 0, err
+        }
+        return time.Duration(n) * 24 * time.Hour, nil
+    }
+    return time.ParseDuration(s)
+}
+
+// cancelReminder cancels a reminder by its number in the last /remind list
+func (m *Model) cancelReminder(args []string) {
+    n, err := 0, errors.New(remindUsage)
+    if len(args) == 1 {
+        n, err = strconv.Atoi(args[0])
+    }
+    if err != nil {
+        m.err = errors.New(i18n.T(remindUsage))
+        return
+    }
+    if n < 1 || n > len(m.reminders) {
+        m.err = errors.New(i18n.T("no reminder %d, see /remind list", n))
+        return
+    }
+    if err := m.connection.CancelReminder(m.reminders[n-1].ID); err != nil {
+        m.err = err
+    }
+}
+
+func (m *Model) reminderCreated(reminder models.Reminder) {
+    m.notice = i18n.T("⏰ Reminder set for %s: %s", reminderTime(reminder.RemindAt), reminder.Content)
+}
+
+// remindersLoaded lists the reminders waiting, their numbers are the ones
+// /remind cancel takes
+func (m *Model) remindersLoaded(reminders []models.Reminder) {
+    m.reminders = reminders
+    if len(reminders) == 0 {
+        m.notice = i18n.T("No reminder waiting")
+        return
+    }
+    var sb strings.Builder
+    sb.WriteString(i18n.T("Reminders (/remind cancel <number> to drop one):"))
+    for i, reminder := range reminders {
+        sb.WriteString(fmt.Sprintf("\n  %d. %s  %s", i+1, reminderTime(reminder.RemindAt), reminder.Content))
+    }
+    m.notice = sb.String()
+}
+
+// reminderTime writes when a reminder is due, e.g. "Today 14:30:00"
+func reminderTime(t time.Time) string {
+    t = i18n.InZone(t)
+    return i18n.FormatDay(t, time.Now()) + " " + i18n.FormatTime(t)
+}
@@ -0,0 +1,37 @@
+// internal/client/tui/revisions.go
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+	"textual/internal/client/network"
+)
+
+// requestRevisions asks the server for the earlier versions of an edited
+// message, its author and the moderators see them
+func requestRevisions(connection *network.ConnectionHandler, msg models.Message) error {
+    if !msg.IsEdited() || msg.ID == "" {
+        return errors.New(i18n.T("This message was not edited"))
+    }
+    if connection == nil {
+        return errors.New(i18n.T("not connected"))
+    }
+    return connection.RequestMessageRevisions(msg.ID)
+}
+
+// revisionsLoaded shows the earlier versions of a message, the oldest first
+func (m *Model) revisionsLoaded(msg models.MessageRevisionsLoaded) {
+    if len(msg.Revisions) == 0 {
+        m.notice = i18n.T("No earlier version of this message")
+        return
+    }
+    var sb strings.Builder
+    sb.WriteString(i18n.T("Earlier versions of the message:"))
+    for _, rev := range msg.Revisions {
+        sb.WriteString(fmt.Sprintf("\n  %s  %s", reminderTime(rev.EditedAt), rev.Content))
+    }
+    m.notice = sb.String()
+}
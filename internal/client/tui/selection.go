@@ -0,0 +1,105 @@
+// internal/client/tui/selection.go
+package tui
+
+import (
+	"fmt"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+)
+
+// messageSelection is the cursor of the selection mode, where j/k move
+// through the messages of a chat and single keys act on the selected one
+type messageSelection struct {
+    active    bool
+    messageID string // see messageKey
+}
+
+// messageKey identifies a message in the selection, a message not stored by
+// the server yet only has its client ID
+func messageKey(msg models.Message) string {
+    if msg.ID == "" && msg.ClientID != "" {
+        return "client:" + msg.ClientID
+    }
+    return msg.ID
+}
+
+// Start selects the newest message, it returns false for an empty chat
+func (s *messageSelection) Start(messages []models.Message) bool {
+    if len(messages) == 0 {
+        return false
+    }
+    s.active = true
+    s.messageID = messageKey(messages[len(messages)-1])
+    return true
+}
+
+// Select selects a message by its ID
+func (s *messageSelection) Select(messageID string) {
+    s.active = true
+    s.messageID = messageID
+}
+
+func (s *messageSelection) Stop() {
+    s.active = false
+    s.messageID = ""
+}
+
+// Move moves the cursor by delta messages, staying within the chat
+func (s *messageSelection) Move(messages []models.Message, delta int) {
+    if len(messages) == 0 {
+        return
+    }
+    index := s.Index(messages) + delta
+    if index < 0 {
+        index = 0
+    }
+    if index >= len(messages) {
+        index = len(messages) - 1
+    }
+    s.messageID = messageKey(messages[index])
+}
+
+// Index returns the position of the selected message, the last one when it is gone
+func (s *messageSelection) Index(messages []models.Message) int {
+    for i, msg := range messages {
+        if messageKey(msg) == s.messageID {
+            return i
+        }
+    }
+    return len(messages) - 1
+}
+
+func (s *messageSelection) Selected(messages []models.Message) (models.Message, bool) {
+    if !s.active || len(messages) == 0 {
+        return models.Message{}, false
+    }
+    return messages[s.Index(messages)], true
+}
+
+func (s *messageSelection) IsSelected(msg models.Message) bool {
+    return s.active && messageKey(msg) != "" && messageKey(msg) == s.messageID
+}
+
+// selectionHelp is shown instead of the input while selecting
+func selectionHelp() string {
+    return timestampStyleBase.Render(i18n.T("j/k move • g/G first/last • o open link • y/Y copy • l copy link • e edit • r retry • t thread • i back to input • esc leave"))
+}
+
+// lastOwnMessage returns the last message of the chat sent by the user
+func lastOwnMessage(messages []models.Message, userID string) (models.Message, bool) {
+    for i := len(messages) - 1; i >= 0; i-- {
+        if messages[i].SenderID == userID && messages[i].ID != "" {
+            return messages[i], true
+        }
+    }
+    return models.Message{}, false
+}
+
+// openMessageLink opens the first link of a message
+func openMessageLink(msg models.Message) (string, error) {
+    links := findLinks(msg.Content)
+    if len(links) == 0 {
+        return "", fmt.Errorf("no link in this message")
+    }
+    return links[0], openURL(links[0])
+}
@@ -0,0 +1,187 @@
+// internal/client/tui/sidebar.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+)
+
+const (
+    sidebarWidth    = 28
+    sidebarMinWidth = 70 // below this terminal width the sidebar is hidden
+)
+
+type conversationKind int
+
+const (
+    globalConversation conversationKind = iota
+    groupConversation
+    directConversation
+)
+
+// conversation is one entry of the sidebar
+type conversation struct {
+    ID      string
+    Kind    conversationKind
+    Name    string
+    Unread   int
+    Mentions int
+    Preview  string
+}
+
+// Sidebar lists every conversation (global chat, groups and direct messages)
+// and lets the user switch between them with alt+j/alt+k
+type Sidebar struct {
+    cursor string
+    width  int
+    height int
+    hidden bool
+}
+
+func NewSidebar() *Sidebar {
+    return &Sidebar{cursor: "global"}
+}
+
+// Width returns the columns taken by the sidebar, 0 when hidden
+func (s *Sidebar) Width() int {
+    if s.hidden {
+        return 0
+    }
+    return sidebarWidth + 2 // border and padding
+}
+
+func (s *Sidebar) Resize(termWidth, height int) {
+    s.hidden = termWidth < sidebarMinWidth
+    s.width = sidebarWidth
+    s.height = height
+}
+
+// Move moves the cursor by delta entries and returns the new entry
+func (s *Sidebar) Move(conversations []conversation, delta int) (conversation, bool) {
+    if len(conversations) == 0 {
+        return conversation{}, false
+    }
+
+    index := 0
+    for i, conv := range conversations {
+        if conv.ID == s.cursor {
+            index = i
+            break
+        }
+    }
+
+    index = (index + delta + len(conversations)) % len(conversations)
+    s.cursor = conversations[index].ID
+    return conversations[index], true
+}
+
+// Select moves the cursor to the given conversation
+func (s *Sidebar) Select(id string) {
+    if id != "" {
+        s.cursor = id
+    }
+}
+
+// At returns the conversation drawn on the given line of the sidebar, it
+// follows the layout of View
+func (s *Sidebar) At(conversations []conversation, line int) (conversation, bool) {
+    if s.hidden {
+        return conversation{}, false
+    }
+
+    row := 0
+    lastKind := conversationKind(-1)
+    for _, conv := range conversations {
+        if conv.Kind != lastKind {
+            if conv.Kind != globalConversation {
+                row += 2 // blank line and section title
+            }
+            lastKind = conv.Kind
+        }
+
+        rows := 1
+        if conv.Preview != "" {
+            rows++
+        }
+        if line >= row && line < row+rows {
+            return conv, true
+        }
+        row += rows
+    }
+    return conversation{}, false
+}
+
+func (s *Sidebar) View(conversations []conversation, activeID string) string {
+    if s.hidden {
+        return ""
+    }
+
+    var sb strings.Builder
+    lastKind := conversationKind(-1)
+    for _, conv := range conversations {
+        if conv.Kind != lastKind {
+            switch conv.Kind {
+            case groupConversation:
+                sb.WriteString("\n" + sidebarSectionStyle.Render(i18n.T("Groups")) + "\n")
+            case directConversation:
+                sb.WriteString("\n" + sidebarSectionStyle.Render(i18n.T("Direct messages")) + "\n")
+            }
+            lastKind = conv.Kind
+        }
+
+        prefix := "  "
+        if conv.ID == activeID {
+            prefix = "▌ "
+        }
+
+        var badges []string
+        if conv.Mentions > 0 {
+            badges = append(badges, mentionBadgeStyle.Render(fmt.Sprintf(" @%d ", conv.Mentions)))
+        }
+        if conv.Unread > 0 {
+            badges = append(badges, badgeStyle.Render(fmt.Sprintf(" %d ", conv.Unread)))
+        }
+        badge := strings.Join(badges, "")
+        badgeWidth := 0
+        if badge != "" {
+            badgeWidth = textWidth(badge) + 1
+        }
+
+        name := fitWidth(prefix+conversationIcon(conv.Kind)+conv.Name, s.width-badgeWidth)
+        switch {
+        case conv.ID == s.cursor:
+            name = sidebarCursorStyle.Render(name)
+        case conv.ID == activeID:
+            name = sidebarActiveStyle.Render(name)
+        }
+        sb.WriteString(name)
+        if badge != "" {
+            sb.WriteString(" " + badge)
+        }
+        sb.WriteString("\n")
+
+        if conv.Preview != "" {
+            preview := truncateWidth("    "+strings.ReplaceAll(conv.Preview, "\n", " "), s.width)
+            sb.WriteString(sidebarPreviewStyle.Render(preview))
+            sb.WriteString("\n")
+        }
+    }
+
+    return sidebarStyle.
+        Width(s.width).
+        Height(s.height).
+        MaxHeight(s.height).
+        Render(sb.String())
+}
+
+func conversationIcon(kind conversationKind) string {
+    switch kind {
+    case globalConversation:
+        return "# "
+    case groupConversation:
+        return "📦 "
+    default:
+        return "@ "
+    }
+}
@@ -0,0 +1,207 @@
+// internal/client/tui/sidepane.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"textual/internal/client/i18n"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+    sidePaneWidth    = 30
+    sidePaneMinWidth = 100 // below this terminal width the pane is hidden
+)
+
+// paneKind is what the side pane shows, ctrl+r cycles through them
+type paneKind int
+
+const (
+    paneClosed paneKind = iota
+    paneMembers
+    paneRequests
+    paneNotifications
+)
+
+// SidePane is the optional column at the right of the chat, it scrolls on
+// its own with alt+pgup/alt+pgdown or the mouse wheel
+type SidePane struct {
+    kind      paneKind
+    viewport  viewport.Model
+    height    int
+    hidden    bool            // terminal too narrow
+    requested map[string]bool // groups whose members were requested
+}
+
+func NewSidePane() *SidePane {
+    vp := viewport.New(sidePaneWidth, 0)
+    vp.KeyMap = viewport.KeyMap{} // scrolled by the model, not by the chat keys
+    return &SidePane{
+        viewport:  vp,
+        requested: make(map[string]bool),
+    }
+}
+
+// Width returns the columns taken by the pane, 0 when closed or hidden
+func (p *SidePane) Width() int {
+    if p.kind == paneClosed || p.hidden {
+        return 0
+    }
+    return sidePaneWidth + 2 // border and padding
+}
+
+func (p *SidePane) Resize(termWidth, height int) {
+    p.hidden = termWidth < sidePaneMinWidth
+    p.height = height
+    p.viewport.Width = sidePaneWidth
+    p.viewport.Height = height - 2 // title and blank line
+}
+
+// Cycle shows the next kind of content, closing the pane after the last one
+func (p *SidePane) Cycle() {
+    p.kind = (p.kind + 1) % (paneNotifications + 1)
+    p.viewport.GotoTop()
+}
+
+// Scroll moves the content by delta lines
+func (p *SidePane) Scroll(delta int) {
+    if delta < 0 {
+        p.viewport.LineUp(-delta)
+    } else {
+        p.viewport.LineDown(delta)
+    }
+}
+
+// Contains tells if the column x of the terminal is in the pane
+func (p *SidePane) Contains(termWidth, x int) bool {
+    return p.Width() > 0 && x >= termWidth-p.Width()
+}
+
+func (p *SidePane) Update(msg tea.Msg) tea.Cmd {
+    var cmd tea.Cmd
+    p.viewport, cmd = p.viewport.Update(msg)
+    return cmd
+}
+
+func (p *SidePane) View(title, content string) string {
+    if p.Width() == 0 {
+        return ""
+    }
+    p.viewport.SetContent(content)
+    return sidePaneStyle.
+        Width(sidePaneWidth).
+        Height(p.height).
+        MaxHeight(p.height).
+        Render(sidebarSectionStyle.Render(title) + "\n\n" + p.viewport.View())
+}
+
+// togglePane cycles the side pane, the chat is narrower while it is open
+func (m *Model) togglePane() {
+    m.pane.Cycle()
+    if m.pane.kind != paneClosed && m.pane.hidden {
+        m.notice = i18n.T("The terminal is too narrow for the side pane")
+    }
+    m.loadPaneMembers()
+    m.resize()
+    m.updateContent()
+}
+
+// loadPaneMembers requests the members of the open group once, when the pane
+// lists them
+func (m *Model) loadPaneMembers() {
+    groupID := m.paneGroup()
+    if m.pane.kind != paneMembers || groupID == "" || m.connection == nil {
+        return
+    }
+    if _, ok := m.groupsView.members.members[groupID]; ok || m.pane.requested[groupID] {
+        return
+    }
+    if err := m.connection.LoadGroupMembers(groupID); err != nil {
+        m.err = err
+        return
+    }
+    m.pane.requested[groupID] = true
+}
+
+// paneView draws the side pane for the current conversation
+func (m Model) paneView() string {
+    switch m.pane.kind {
+    case paneMembers:
+        return m.pane.View(i18n.T("Members"), m.paneMembers())
+    case paneRequests:
+        return m.pane.View(i18n.T("Friend requests"), m.paneRequests())
+    case paneNotifications:
+        return m.pane.View(i18n.T("Notifications"), m.paneNotifications())
+    }
+    return ""
+}
+
+// paneGroup returns the group open on the Groups page, if any
+func (m Model) paneGroup() string {
+    if m.currentPage != GroupsPage || m.groupsView == nil {
+        return ""
+    }
+    return m.groupsView.ActiveGroup()
+}
+
+func (m Model) paneMembers() string {
+    groupID := m.paneGroup()
+    if groupID == "" {
+        return timestampStyleBase.Render(i18n.T("Open a group to see its members"))
+    }
+    members, ok := m.groupsView.members.members[groupID]
+    if !ok {
+        return timestampStyleBase.Render(i18n.T("Loading members..."))
+    }
+
+    var sb strings.Builder
+    for _, member := range members {
+        line := fmt.Sprintf("%s %s", statusIcon(member.Status), member.Username)
+        line += m.groupsView.roleBadge(groupID, member)
+        sb.WriteString(line + "\n")
+    }
+    return sb.String()
+}
+
+func (m Model) paneRequests() string {
+    if m.friendsView == nil || len(m.friendsView.pendingRequests)+len(m.friendsView.sentRequests) == 0 {
+        return timestampStyleBase.Render(i18n.T("No pending friend request"))
+    }
+
+    var sb strings.Builder
+    for _, req := range m.friendsView.pendingRequests {
+        sb.WriteString(fmt.Sprintf("📨 %s\n", req.FromUser))
+        sb.WriteString(timestampStyleBase.Render("   "+i18n.FormatTimestamp(i18n.InZone(req.CreatedAt), time.Now())) + "\n")
+    }
+    for _, req := range m.friendsView.sentRequests {
+        sb.WriteString(timestampStyleBase.Render(fmt.Sprintf("📤 %s", req.ToUser)) + "\n")
+    }
+    sb.WriteString("\n" + timestampStyleBase.Render(i18n.T("Answer them in the Friends tab")))
+    return sb.String()
+}
+
+func (m Model) paneNotifications() string {
+    if len(m.notifications.notifications) == 0 {
+        return timestampStyleBase.Render(i18n.T("No notification"))
+    }
+
+    // newest first, like the Notifications tab
+    var sb strings.Builder
+    for _, notif := range m.notifications.notifications {
+        title := runewidth.Truncate(notificationTitle(notif), sidePaneWidth, "…")
+        if !notif.Read {
+            title = sidebarCursorStyle.Render(title)
+        }
+        sb.WriteString(title + "\n")
+        detail := i18n.FormatTimestamp(i18n.InZone(notif.CreatedAt), time.Now())
+        if text := notificationText(notif); text != "" {
+            detail += " " + text
+        }
+        sb.WriteString(timestampStyleBase.Render(runewidth.Truncate(detail, sidePaneWidth, "…")) + "\n")
+    }
+    return sb.String()
+}
@@ -0,0 +1,62 @@
+// internal/client/tui/suggestions.go
+package tui
+
+import "strings"
+
+// suggestions is the list shown above the input while a word is completed,
+// moved through with the arrows. The completers embed it and keep their
+// own matches and how they complete the input
+type suggestions struct {
+    count  int
+    cursor int
+}
+
+// show sets the number of suggestions, the selected one stays when it still
+// is one
+func (s *suggestions) show(count int) {
+    s.count = count
+    if s.cursor >= count {
+        s.cursor = 0
+    }
+}
+
+func (s *suggestions) Active() bool {
+    return s.count > 0
+}
+
+func (s *suggestions) Dismiss() {
+    s.count = 0
+    s.cursor = 0
+}
+
+// handleKey handles the navigation keys while suggestions are shown, accept
+// completes the input with the selected one. It returns false when the key
+// is not for the completer
+func (s *suggestions) handleKey(key string, accept func(selected int)) bool {
+    switch key {
+    case "up", "shift+tab":
+        s.cursor = (s.cursor - 1 + s.count) % s.count
+    case "down":
+        s.cursor = (s.cursor + 1) % s.count
+    case "tab", "enter":
+        accept(s.cursor)
+        s.Dismiss()
+    case "esc":
+        s.Dismiss()
+    default:
+        return false
+    }
+    return true
+}
+
+// render draws the suggestions joined by sep, label draws one of them
+func (s *suggestions) render(sep string, label func(i int, selected bool) string) string {
+    if !s.Active() {
+        return ""
+    }
+    parts := make([]string, 0, s.count)
+    for i := 0; i < s.count; i++ {
+        parts = append(parts, label(i, i == s.cursor))
+    }
+    return strings.Join(parts, sep)
+}
@@ -0,0 +1,208 @@
+// internal/client/tui/switcher.go
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"textual/internal/client/i18n"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// max number of conversations listed by the quick switcher
+const maxSwitcherResults = 10
+
+// QuickSwitcher is the ctrl+k overlay jumping to a conversation by typing
+// a part of its name
+type QuickSwitcher struct {
+    input   textinput.Model
+    active  bool
+    matches []conversation
+    cursor  int
+}
+
+func NewQuickSwitcher() QuickSwitcher {
+    input := textinput.New()
+    input.Placeholder = i18n.T("Jump to a conversation...")
+    input.Prompt = "> "
+    input.CharLimit = 50
+
+    return QuickSwitcher{input: input}
+}
+
+func (s QuickSwitcher) Active() bool {
+    return s.active
+}
+
+// Open shows the switcher with every conversation
+func (s *QuickSwitcher) Open(convs []conversation) tea.Cmd {
+    s.active = true
+    s.input.Reset()
+    s.filter(convs)
+    return s.input.Focus()
+}
+
+func (s *QuickSwitcher) Close() {
+    s.active = false
+    s.matches = nil
+    s.cursor = 0
+    s.input.Blur()
+}
+
+// HandleKey updates the query and the cursor, it returns the chosen
+// conversation when enter is pressed
+func (s *QuickSwitcher) HandleKey(msg tea.KeyMsg, convs []conversation) (conversation, bool, tea.Cmd) {
+    switch msg.String() {
+    case "esc", "ctrl+k":
+        s.Close()
+        return conversation{}, false, nil
+    case "enter":
+        if s.cursor >= len(s.matches) {
+            return conversation{}, false, nil
+        }
+        conv := s.matches[s.cursor]
+        s.Close()
+        return conv, true, nil
+    case "up", "ctrl+p", "shift+tab":
+        if s.cursor > 0 {
+            s.cursor--
+        }
+        return conversation{}, false, nil
+    case "down", "ctrl+n", "tab":
+        if s.cursor < len(s.matches)-1 {
+            s.cursor++
+        }
+        return conversation{}, false, nil
+    }
+
+    var cmd tea.Cmd
+    before := s.input.Value()
+    s.input, cmd = s.input.Update(msg)
+    if s.input.Value() != before {
+        s.filter(convs)
+    }
+    return conversation{}, false, cmd
+}
+
+// filter keeps the conversations matching the query, best matches first
+func (s *QuickSwitcher) filter(convs []conversation) {
+    query := strings.TrimSpace(s.input.Value())
+    s.cursor = 0
+
+    type scored struct {
+        conv  conversation
+        score int
+    }
+    var results []scored
+    for _, conv := range convs {
+        if score, ok := fuzzyScore(query, conv.Name); ok {
+            results = append(results, scored{conv, score})
+        }
+    }
+    sort.SliceStable(results, func(i, j int) bool {
+        return results[i].score > results[j].score
+    })
+
+    s.matches = s.matches[:0]
+    for _, result := range results {
+        if len(s.matches) == maxSwitcherResults {
+            break
+        }
+        s.matches = append(s.matches, result.conv)
+    }
+}
+
+func (s QuickSwitcher) View(width int) string {
+    var sb strings.Builder
+    sb.WriteString(s.input.View())
+    sb.WriteString("\n\n")
+
+    if len(s.matches) == 0 {
+        sb.WriteString(timestampStyleBase.Render(i18n.T("No conversation found")))
+    }
+    for i, conv := range s.matches {
+        line := conversationIcon(conv.Kind) + conv.Name
+        if conv.Unread > 0 {
+            line += " " + badgeStyle.Render(fmt.Sprintf(" %d ", conv.Unread))
+        }
+        if i == s.cursor {
+            sb.WriteString(selectionMarkerStyle.Render("▌") + sidebarCursorStyle.Render(line))
+        } else {
+            sb.WriteString(" " + line)
+        }
+        sb.WriteString("\n")
+    }
+    sb.WriteString("\n")
+    sb.WriteString(timestampStyleBase.Render(i18n.T("↑/↓ move • enter open • esc close")))
+
+    boxWidth := 50
+    if width-4 < boxWidth {
+        boxWidth = width - 4
+    }
+    return inputStyle.Width(boxWidth).Render(sb.String())
+}
+
+// fuzzyScore matches the letters of the pattern in order in the name, case
+// insensitive. Consecutive letters and letters starting a word score higher,
+// an empty pattern matches everything
+func fuzzyScore(pattern, name string) (int, bool) {
+    p := []rune(strings.ToLower(pattern))
+    n := []rune(strings.ToLower(name))
+    if len(p) == 0 {
+        return 0, true
+    }
+
+    score, pi := 0, 0
+    previous := -2
+    for i, r := range n {
+        if pi == len(p) {
+            break
+        }
+        if r != p[pi] {
+            continue
+        }
+        score++
+        if i == previous+1 {
+            score += 5
+        }
+        if i == 0 || !unicode.IsLetter(n[i-1]) && !unicode.IsDigit(n[i-1]) {
+            score += 8
+        }
+        previous = i
+        pi++
+    }
+    if pi < len(p) {
+        return 0, false
+    }
+    // shorter names are closer to what was typed
+    return score*10 - len(n), true
+}
+
+// switcherView draws the quick switcher over the main area
+func (m Model) switcherView(width, height int) string {
+    return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Top, m.switcher.View(width))
+}
+
+// switcherCandidates returns the conversations of the sidebar and the friends
+// without a conversation yet
+func (m Model) switcherCandidates() []conversation {
+    convs := m.conversations()
+    if m.friendsView == nil {
+        return convs
+    }
+
+    known := make(map[string]bool, len(convs))
+    for _, conv := range convs {
+        known[conv.ID] = true
+    }
+    for _, friend := range m.friendsView.friends {
+        if !known[friend.ID] {
+            convs = append(convs, conversation{ID: friend.ID, Kind: directConversation, Name: friend.Username})
+        }
+    }
+    return convs
+}
@@ -0,0 +1,54 @@
+// internal/client/tui/sync.go
+package tui
+
+import (
+	"textual/internal/client/config"
+	"textual/internal/client/models"
+)
+
+// applySync takes the state the server sends after each login: the display
+// preferences, the lists, the latest messages of each chat and the unread
+// direct messages. After a reconnect it replaces what the client missed
+func (m *Model) applySync(msg models.Synced) {
+    if m.friendsView != nil {
+        m.friendsView.SetFriends(msg.Friends)
+        for _, req := range msg.Requests {
+            m.friendsView.AddPendingRequest(req)
+        }
+    }
+    m.applyDisplayPrefs(msg.Prefs)
+    m.messagesView.SetFriends(msg.Friends)
+    if m.groupsView != nil {
+        m.groupsView.SetGroups(msg.Groups)
+    }
+
+    for _, conv := range msg.Conversations {
+        m.applyHistory(conv)
+        // the latest page is there, opening the chat doesn't ask for it
+        if conv.GroupID != "" && m.groupsView != nil {
+            m.groupsView.historyLoaded[conv.GroupID] = true
+        } else if conv.RecipientID != "" {
+            m.historyLoaded[conv.RecipientID] = true
+        }
+    }
+
+    // the server counts the direct messages, they may have been read on
+    // another device in the meantime
+    for chatID := range m.unread {
+        if _, ok := m.messagesView.contacts[chatID]; ok && msg.Unread[chatID] == 0 {
+            delete(m.unread, chatID)
+            delete(m.mentions, chatID)
+        }
+    }
+    for senderID, count := range msg.Unread {
+        if m.isViewing(senderID) {
+            m.sendReadMarker(senderID)
+            continue
+        }
+        if m.config.Notifications.Level(senderID) != config.LevelNone {
+            m.unread[senderID] = count
+        }
+    }
+
+    m.refreshContacts()
+}
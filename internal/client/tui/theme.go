@@ -0,0 +1,315 @@
+// internal/client/tui/theme.go
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"textual/internal/client/config"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is the set of colors used by every view
+type Theme struct {
+    Name      string
+    Primary   lipgloss.Color // header, usernames, borders
+    Secondary lipgloss.Color // titles and highlighted conversations
+    Text      lipgloss.Color // text drawn on colored backgrounds
+    Surface   lipgloss.Color // inactive tabs, sidebar cursor
+    Muted     lipgloss.Color // timestamps, previews
+    Error     lipgloss.Color
+    Warning   lipgloss.Color
+    Success   lipgloss.Color
+    Pending   lipgloss.Color // pending friend requests
+    Badge     lipgloss.Color // unread counters
+    Mention   lipgloss.Color // messages mentioning the user
+    Names     []lipgloss.Color // sender names, picked from the user ID
+}
+
+var themes = map[string]Theme{
+    "dark": {
+        Name:      "dark",
+        Primary:   "#874BFD",
+        Secondary: "#FF87D7",
+        Text:      "#FAFAFA",
+        Surface:   "#383838",
+        Muted:     "#666666",
+        Error:     "#FF0000",
+        Warning:   "#FFD700",
+        Success:   "#5AF78E",
+        Pending:   "#FFB6C1",
+        Badge:     "#FF5F87",
+        Mention:   "#FFAF00",
+        Names:     []lipgloss.Color{"#874BFD", "#FF87D7", "#5FD7FF", "#5AF78E", "#FFD75F", "#FF875F", "#AF87FF", "#87FFD7"},
+    },
+    "light": {
+        Name:      "light",
+        Primary:   "#5A2FC2",
+        Secondary: "#C4307E",
+        Text:      "#FFFFFF",
+        Surface:   "#D0D0D0",
+        Muted:     "#808080",
+        Error:     "#CC0000",
+        Warning:   "#B8860B",
+        Success:   "#1E8C45",
+        Pending:   "#C2185B",
+        Badge:     "#D81B60",
+        Mention:   "#E65100",
+        Names:     []lipgloss.Color{"#5A2FC2", "#C4307E", "#00796B", "#1E8C45", "#8D6E00", "#D84315", "#1565C0", "#6A1B9A"},
+    },
+    "high-contrast": {
+        Name:      "high-contrast",
+        Primary:   "#0000FF",
+        Secondary: "#FFFF00",
+        Text:      "#FFFFFF",
+        Surface:   "#000000",
+        Muted:     "#C0C0C0",
+        Error:     "#FF0000",
+        Warning:   "#FFFF00",
+        Success:   "#00FF00",
+        Pending:   "#FF00FF",
+        Badge:     "#FF0000",
+        Mention:   "#00FFFF",
+        Names:     []lipgloss.Color{"#FFFF00", "#00FFFF", "#00FF00", "#FF00FF", "#FFFFFF"},
+    },
+}
+
+// styles depending on the theme, rebuilt by ApplyTheme
+var (
+    currentTheme Theme
+
+    headerStyle         lipgloss.Style
+    tabStyle            lipgloss.Style
+    activeTabStyle      lipgloss.Style
+    timestampStyle      lipgloss.Style
+    timestampStyleBase  lipgloss.Style
+    usernameStyle       lipgloss.Style
+    editedStyle         lipgloss.Style
+    readStyle           lipgloss.Style
+    inputStyle          lipgloss.Style
+    offlineInputStyle   lipgloss.Style
+    errorStyle          lipgloss.Style
+    noticeStyle         lipgloss.Style
+    titleStyle          lipgloss.Style
+    friendTitleStyle    lipgloss.Style
+    successStyle        lipgloss.Style
+    notificationStyle   lipgloss.Style
+    pendingRequestStyle lipgloss.Style
+
+    conversationHeaderStyle lipgloss.Style
+    sidebarStyle            lipgloss.Style
+    sidePaneStyle           lipgloss.Style
+    sidebarSectionStyle     lipgloss.Style
+    sidebarCursorStyle      lipgloss.Style
+    sidebarActiveStyle      lipgloss.Style
+    sidebarPreviewStyle     lipgloss.Style
+    badgeStyle              lipgloss.Style
+    roleBadgeStyle          lipgloss.Style
+    ownerBadgeStyle         lipgloss.Style
+    mentionStyle            lipgloss.Style
+    mentionBadgeStyle       lipgloss.Style
+    selectionMarkerStyle    lipgloss.Style
+    unreadDividerStyle      lipgloss.Style
+    announcementStyle       lipgloss.Style
+    topicStyle              lipgloss.Style
+    systemMessageStyle      lipgloss.Style
+)
+
+func init() {
+    ApplyTheme(themes["dark"])
+}
+
+// ThemeNames returns the available presets
+func ThemeNames() []string {
+    names := make([]string, 0, len(themes))
+    for name := range themes {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func CurrentTheme() Theme {
+    return currentTheme
+}
+
+// ThemeFromConfig returns the preset named in the config with its color overrides applied
+func ThemeFromConfig(cfg config.Config) (Theme, error) {
+    theme, ok := themes[cfg.Theme]
+    if !ok {
+        return themes["dark"], fmt.Errorf("unknown theme %q (available: %s)", cfg.Theme, strings.Join(ThemeNames(), ", "))
+    }
+
+    colors := map[string]*lipgloss.Color{
+        "primary":   &theme.Primary,
+        "secondary": &theme.Secondary,
+        "text":      &theme.Text,
+        "surface":   &theme.Surface,
+        "muted":     &theme.Muted,
+        "error":     &theme.Error,
+        "warning":   &theme.Warning,
+        "success":   &theme.Success,
+        "pending":   &theme.Pending,
+        "badge":     &theme.Badge,
+        "mention":   &theme.Mention,
+    }
+    for key, value := range cfg.Colors {
+        color, ok := colors[strings.ToLower(key)]
+        if !ok {
+            return theme, fmt.Errorf("unknown color %q in theme overrides", key)
+        }
+        *color = lipgloss.Color(value)
+    }
+
+    return theme, nil
+}
+
+// ApplyTheme rebuilds the styles of every view with the colors of the theme
+func ApplyTheme(t Theme) {
+    currentTheme = t
+
+    headerStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Text).
+        Background(t.Primary).
+        Padding(0, 1)
+
+    tabStyle = headerStyle.
+        Background(t.Surface)
+
+    activeTabStyle = headerStyle.
+        Background(t.Primary).
+        Underline(true)
+
+    timestampStyle = lipgloss.NewStyle().
+        Foreground(t.Muted).
+        Width(10)
+
+    timestampStyleBase = lipgloss.NewStyle().
+        Foreground(t.Muted)
+
+    usernameStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Primary).
+        PaddingRight(1).
+        Align(lipgloss.Left)
+
+    editedStyle = lipgloss.NewStyle().
+        Foreground(t.Muted).
+        Italic(true)
+
+    readStyle = lipgloss.NewStyle().
+        Foreground(t.Success)
+
+    inputStyle = lipgloss.NewStyle().
+        BorderStyle(lipgloss.RoundedBorder()).
+        BorderForeground(t.Primary).
+        Padding(0, 1)
+
+    offlineInputStyle = inputStyle.
+        BorderForeground(t.Muted).
+        Foreground(t.Muted)
+
+    errorStyle = lipgloss.NewStyle().
+        Foreground(t.Error).
+        Bold(true)
+
+    noticeStyle = lipgloss.NewStyle().
+        Foreground(t.Warning)
+
+    titleStyle = lipgloss.NewStyle().
+        Foreground(t.Secondary).
+        Bold(true).
+        MarginBottom(1)
+
+    friendTitleStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Secondary)
+
+    successStyle = lipgloss.NewStyle().
+        Foreground(t.Success)
+
+    notificationStyle = lipgloss.NewStyle().
+        Foreground(t.Warning).
+        Bold(true)
+
+    pendingRequestStyle = lipgloss.NewStyle().
+        Foreground(t.Pending).
+        Bold(true)
+
+    conversationHeaderStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Secondary)
+
+    sidebarStyle = lipgloss.NewStyle().
+        BorderStyle(lipgloss.NormalBorder()).
+        BorderRight(true).
+        BorderForeground(t.Surface).
+        PaddingRight(1)
+
+    sidePaneStyle = lipgloss.NewStyle().
+        BorderStyle(lipgloss.NormalBorder()).
+        BorderLeft(true).
+        BorderForeground(t.Surface).
+        PaddingLeft(1)
+
+    sidebarSectionStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Primary)
+
+    sidebarCursorStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Text).
+        Background(t.Surface)
+
+    sidebarActiveStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Secondary)
+
+    sidebarPreviewStyle = lipgloss.NewStyle().
+        Foreground(t.Muted)
+
+    badgeStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Text).
+        Background(t.Badge)
+
+    roleBadgeStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Text).
+        Background(t.Primary)
+
+    ownerBadgeStyle = roleBadgeStyle.
+        Background(t.Secondary)
+
+    mentionStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Mention)
+
+    mentionBadgeStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Surface).
+        Background(t.Mention)
+
+    selectionMarkerStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Secondary)
+
+    unreadDividerStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Badge)
+
+    announcementStyle = lipgloss.NewStyle().
+        Bold(true).
+        Foreground(t.Warning)
+
+    topicStyle = lipgloss.NewStyle().
+        Foreground(t.Secondary).
+        Italic(true)
+
+    systemMessageStyle = lipgloss.NewStyle().
+        Foreground(t.Muted).
+        Faint(true).
+        Italic(true)
+}
@@ -0,0 +1,298 @@
+// internal/client/tui/thread.go
+package tui
+
+import (
+	"errors"
+	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
+	"textual/internal/client/models"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// threadView is the pane shown over the conversation while a thread is
+// open: its root, the replies and an input to answer
+type threadView struct {
+    root     models.Message
+    replies  []models.Message
+    input    textinput.Model
+    viewport viewport.Model
+    loading  bool
+}
+
+// openThreadMsg is sent by the group chat to open the thread of a message
+type openThreadMsg struct {
+    root models.Message
+}
+
+// openThread shows the thread of a message and loads its replies
+func (m *Model) openThread(root models.Message) {
+    if root.ID == "" || root.ThreadRootID != nil || root.IsSystem() {
+        m.notice = i18n.T("Only a message sent can start a thread")
+        return
+    }
+    if root.Encrypted || root.GroupID != nil && m.connection != nil && m.connection.GroupEncrypted(*root.GroupID) {
+        m.notice = i18n.T("Threads are not available in encrypted conversations")
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    input := textinput.New()
+    input.Placeholder = i18n.T("Reply in the thread...")
+    input.CharLimit = m.input.CharLimit
+    input.Width = m.input.Width
+    input.Focus()
+    m.thread = &threadView{
+        root:     root,
+        input:    input,
+        viewport: viewport.New(m.mainWidth(), m.viewport.Height-1),
+        loading:  true,
+    }
+    m.stopSelection()
+    m.input.Blur()
+    delete(m.threadUnread, root.ID)
+
+    if err := m.connection.LoadThread(root.ID, "", m.config.History.ThreadPage()); err != nil {
+        m.err = err
+        m.thread.loading = false
+    }
+    m.updateThread()
+}
+
+func (m *Model) closeThread() {
+    m.thread = nil
+    if m.currentPage == GlobalPage || m.currentPage == MessagesPage && m.selectedChat != "" {
+        m.input.Focus()
+    } else if m.currentPage == GroupsPage && m.groupsView != nil && m.groupsView.mode == GroupChatMode {
+        m.groupsView.input.Focus()
+    }
+    m.updateContent()
+}
+
+// handleThreadKey handles the keys while a thread is open, it returns false
+// when the key must go through the normal handling
+func (m *Model) handleThreadKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+    switch {
+    case key.Matches(msg, globalKeys.Quit, globalKeys.NextPage, globalKeys.Switcher, globalKeys.NextConversation, globalKeys.PrevConversation):
+        m.closeThread()
+        return false, nil
+
+    case key.Matches(msg, threadKeys.Close):
+        m.closeThread()
+        return true, nil
+
+    case key.Matches(msg, threadKeys.ScrollUp):
+        m.thread.viewport.HalfViewUp()
+        return true, nil
+    case key.Matches(msg, threadKeys.ScrollDown):
+        m.thread.viewport.HalfViewDown()
+        return true, nil
+
+    case key.Matches(msg, threadKeys.Reply):
+        content := strings.TrimSpace(m.thread.input.Value())
+        if content == "" {
+            return true, m.retryReplies()
+        }
+        if m.offline() {
+            m.err = errors.New(i18n.T("offline: the message stays in the input until the connection is back"))
+            return true, nil
+        }
+        m.thread.input.Reset()
+        return true, m.sendReply(content)
+    }
+
+    var cmd tea.Cmd
+    m.thread.input, cmd = m.thread.input.Update(msg)
+    return true, cmd
+}
+
+// sendReply sends a reply to the open thread, in the conversation of its root
+func (m *Model) sendReply(content string) tea.Cmd {
+    root := m.thread.root
+    var recipientID *string
+    if root.RecipientID != nil {
+        partner := m.getChatID(root)
+        recipientID = &partner
+    }
+    msg := newPendingMessage(m.userID, m.username, content, recipientID, root.GroupID)
+    msg.ThreadRootID = &root.ID
+    return m.sendPending(msg)
+}
+
+// retryReplies sends the failed replies of the open thread again
+func (m *Model) retryReplies() tea.Cmd {
+    var cmds []tea.Cmd
+    for _, reply := range m.thread.replies {
+        if reply.SendState == models.SendFailed {
+            cmds = append(cmds, m.retry(reply))
+        }
+    }
+    return tea.Batch(cmds...)
+}
+
+// addReplies puts replies in the open thread, a reply the server echoed
+// replaces its pending copy
+func (t *threadView) addReplies(replies ...models.Message) {
+    for _, reply := range replies {
+        t.replies = replacePending(t.replies, reply)
+        duplicate := false
+        for _, existing := range t.replies {
+            if reply.ID != "" && existing.ID == reply.ID {
+                duplicate = true
+                break
+            }
+        }
+        if !duplicate {
+            t.replies = append(t.replies, reply)
+        }
+    }
+}
+
+// receiveReply handles a reply: it goes to the thread when open, otherwise
+// the thread counts it as unread
+func (m *Model) receiveReply(msg models.Message) {
+    chatID := m.getChatID(msg)
+    rootID := *msg.ThreadRootID
+    m.typing.Remove(chatID, msg.SenderID)
+    m.notifyMessage(chatID, msg)
+
+    open := m.thread != nil && m.thread.root.ID == rootID
+    if open {
+        before := len(m.thread.replies)
+        m.thread.addReplies(msg)
+        if len(m.thread.replies) > before || msg.SenderID == m.userID {
+            m.thread.root.ReplyCount++
+        }
+        m.updateThread()
+    } else if msg.SenderID != m.userID && m.config.Notifications.Level(chatID) != config.LevelNone {
+        m.threadUnread[rootID]++
+    }
+
+    countReply(m.messages[chatID], rootID)
+    if msg.IsGroup() && m.groupsView != nil {
+        countReply(m.groupsView.messages[chatID], rootID)
+    }
+    if m.isViewing(chatID) {
+        m.updateContent()
+    }
+}
+
+// countReply adds a reply to the count of its root
+func countReply(chat []models.Message, rootID string) {
+    for i := range chat {
+        if chat[i].ID == rootID {
+            chat[i].ReplyCount++
+            return
+        }
+    }
+}
+
+// threadLoaded shows the replies of the open thread, the page is newest first
+func (m *Model) threadLoaded(msg models.ThreadLoaded) {
+    if m.thread == nil || m.thread.root.ID != msg.Root.ID {
+        return
+    }
+    m.thread.loading = false
+    m.thread.root = msg.Root
+    replies := make([]models.Message, 0, len(msg.Messages))
+    for i := len(msg.Messages) - 1; i >= 0; i-- {
+        replies = append(replies, msg.Messages[i])
+    }
+    // the pending replies stay after the stored ones
+    pending := m.thread.replies
+    m.thread.replies = replies
+    m.thread.addReplies(pending...)
+    m.updateThread()
+}
+
+// editThreadMessage shows an edit, or a link preview, of the root or of a
+// reply of the open thread
+func (m *Model) editThreadMessage(msg models.Message) {
+    if m.thread == nil {
+        return
+    }
+    if m.thread.root.ID == msg.ID {
+        m.thread.root.Content = msg.Content
+        m.thread.root.EditedAt = msg.EditedAt
+        m.thread.root.Preview = msg.Preview
+    }
+    for i := range m.thread.replies {
+        if m.thread.replies[i].ID == msg.ID {
+            m.thread.replies[i].Content = msg.Content
+            m.thread.replies[i].EditedAt = msg.EditedAt
+            m.thread.replies[i].Preview = msg.Preview
+        }
+    }
+    m.updateThread()
+}
+
+func (m *Model) updateThread() {
+    if m.thread == nil {
+        return
+    }
+    var sb strings.Builder
+    sb.WriteString(m.renderMessages([]models.Message{m.thread.root}))
+    label := i18n.T("%d replies", len(m.thread.replies))
+    if m.thread.loading {
+        label = i18n.T("Loading the replies...")
+    }
+    label = " " + label + " "
+    side := (m.thread.viewport.Width - lipgloss.Width(label)) / 2
+    if side < 2 {
+        side = 2
+    }
+    sb.WriteString(timestampStyleBase.Render(strings.Repeat("─", side)+label+strings.Repeat("─", side)) + "\n")
+    sb.WriteString(m.renderMessages(m.thread.replies))
+    m.thread.viewport.SetContent(sb.String())
+    m.thread.viewport.GotoBottom()
+}
+
+// threadContent draws the open thread in place of the conversation
+func (m Model) threadContent() string {
+    var sb strings.Builder
+    sb.WriteString(titleStyle.Render(i18n.T("Thread")) + " " +
+        timestampStyleBase.Render(i18n.T("enter reply • empty enter retries the failed replies • pgup/pgdown scroll • esc close")) + "\n")
+    sb.WriteString(m.thread.viewport.View())
+    sb.WriteString("\n")
+    sb.WriteString(inputStyle.Render(m.thread.input.View()))
+    return sb.String()
+}
+
+// resizeThread follows the size of the conversation
+func (m *Model) resizeThread() {
+    if m.thread == nil {
+        return
+    }
+    m.thread.viewport.Width = m.mainWidth()
+    m.thread.viewport.Height = m.viewport.Height - 1
+    m.thread.input.Width = m.input.Width
+    m.updateThread()
+}
+
+// renderReplies draws the line under a thread root: the replies and how many
+// are new
+func renderReplies(msg models.Message, unread int) string {
+    if msg.ReplyCount == 0 || msg.ThreadRootID != nil {
+        return ""
+    }
+    line := timestampStyleBase.Render(i18n.T("↳ %d replies", msg.ReplyCount))
+    if unread > 0 {
+        line += " " + unreadDividerStyle.Render(i18n.T("(%d new)", unread))
+    }
+    return line
+}
+
+// openThreadCmd asks the model to open the thread of a message
+func openThreadCmd(root models.Message) tea.Cmd {
+    return func() tea.Msg {
+        return openThreadMsg{root: root}
+    }
+}
@@ -0,0 +1,72 @@
+// internal/client/tui/timeline.go
+package tui
+
+import (
+	"strings"
+	"textual/internal/client/i18n"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how often the relative times ("2m ago") are written again
+const relativeRefresh = 30 * time.Second
+
+var (
+    daySeparatorsEnabled = true
+    relativeTimesEnabled = false
+)
+
+// relativeTickMsg redraws the messages so their relative times stay right
+type relativeTickMsg struct{}
+
+// SetTimeline enables the lines between the days of a conversation and the
+// relative times of the messages
+func SetTimeline(daySeparators, relativeTimes bool) {
+    daySeparatorsEnabled = daySeparators
+    relativeTimesEnabled = relativeTimes
+}
+
+// tickRelative schedules the next redraw of the relative times, nil when they
+// are disabled
+func tickRelative() tea.Cmd {
+    if !relativeTimesEnabled {
+        return nil
+    }
+    return tea.Tick(relativeRefresh, func(time.Time) tea.Msg {
+        return relativeTickMsg{}
+    })
+}
+
+// messageTime writes when a message was sent, relative to now when enabled.
+// withDate adds the date of the messages of other days
+func messageTime(t time.Time, withDate bool) string {
+    t = i18n.InZone(t)
+    switch {
+    case relativeTimesEnabled:
+        return i18n.FormatRelative(t, time.Now())
+    case withDate:
+        return i18n.FormatTimestamp(t, time.Now())
+    }
+    return i18n.FormatTime(t)
+}
+
+// daySeparator returns the line to write before a message sent on another day
+// than the previous one, "" when there is none
+func daySeparator(prev, t time.Time, width int) string {
+    if !daySeparatorsEnabled {
+        return ""
+    }
+    prev, t = i18n.InZone(prev), i18n.InZone(t)
+    if !prev.IsZero() && prev.Year() == t.Year() && prev.YearDay() == t.YearDay() {
+        return ""
+    }
+
+    label := " " + i18n.FormatDay(t, time.Now()) + " "
+    side := (width - lipgloss.Width(label)) / 2
+    if side < 2 {
+        side = 2
+    }
+    return timestampStyleBase.Render(strings.Repeat("─", side)+label+strings.Repeat("─", side)) + "\n"
+}
@@ -0,0 +1,102 @@
+// internal/client/tui/typing.go
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+    // a user stops being shown as typing after this delay without notification
+    typingTimeout = 5 * time.Second
+    // min delay between two typing notifications sent for the same chat
+    typingThrottle = 3 * time.Second
+)
+
+// typingExpiredMsg is sent when typing notifications may have expired
+type typingExpiredMsg struct{}
+
+type typingUser struct {
+    name    string
+    expires time.Time
+}
+
+// typingTracker remembers who is typing in each chat, and when the user's own
+// typing was last announced
+type typingTracker struct {
+    users    map[string]map[string]typingUser // chat ID -> user ID
+    lastSent time.Time
+    lastChat string
+}
+
+func newTypingTracker() *typingTracker {
+    return &typingTracker{users: make(map[string]map[string]typingUser)}
+}
+
+// Add records that a user is typing, the returned command expires it
+func (t *typingTracker) Add(chatID, userID, name string) tea.Cmd {
+    if t.users[chatID] == nil {
+        t.users[chatID] = make(map[string]typingUser)
+    }
+    t.users[chatID][userID] = typingUser{name: name, expires: time.Now().Add(typingTimeout)}
+
+    return tea.Tick(typingTimeout, func(time.Time) tea.Msg {
+        return typingExpiredMsg{}
+    })
+}
+
+// Remove forgets a user, e.g. once their message arrived
+func (t *typingTracker) Remove(chatID, userID string) {
+    delete(t.users[chatID], userID)
+}
+
+// Expire removes the users whose last notification is too old
+func (t *typingTracker) Expire() {
+    now := time.Now()
+    for chatID, users := range t.users {
+        for userID, user := range users {
+            if now.After(user.expires) {
+                delete(users, userID)
+            }
+        }
+        if len(users) == 0 {
+            delete(t.users, chatID)
+        }
+    }
+}
+
+// Indicator returns "alice is typing…" for the chat, or "" when nobody types
+func (t *typingTracker) Indicator(chatID string) string {
+    users := t.users[chatID]
+    names := make([]string, 0, len(users))
+    for _, user := range users {
+        names = append(names, user.name)
+    }
+    sort.Strings(names)
+
+    switch len(names) {
+    case 0:
+        return ""
+    case 1:
+        return fmt.Sprintf("%s is typing…", names[0])
+    case 2:
+        return fmt.Sprintf("%s and %s are typing…", names[0], names[1])
+    case 3:
+        return fmt.Sprintf("%s, %s and %s are typing…", names[0], names[1], names[2])
+    default:
+        return fmt.Sprintf("%s, %s and %d others are typing…", names[0], names[1], len(names)-2)
+    }
+}
+
+// ShouldSend reports whether the user's typing must be announced in the chat
+func (t *typingTracker) ShouldSend(chatID string) bool {
+    if chatID == t.lastChat && time.Since(t.lastSent) < typingThrottle {
+        return false
+    }
+    t.lastChat = chatID
+    t.lastSent = time.Now()
+    return true
+}
@@ -0,0 +1,36 @@
+// internal/client/tui/unsupported.go
+package tui
+
+import (
+	"textual/internal/client/i18n"
+	"textual/pkg/protocol"
+)
+
+// features names what the user tried for the requests an older server may
+// not know, the other types are shown as they are
+var features = map[protocol.MessageType]string{
+    protocol.TypeGroupTopic:        "group topics (/group topic)",
+    protocol.TypeGroupTopicHistory: "group topics (/group topic)",
+    protocol.TypeFetchContext:      "message links (/goto)",
+    protocol.TypeDisplayPrefs:      "synced display preferences (/prefs)",
+    protocol.TypeMentionsList:      "the Mentions section",
+    protocol.TypeNotificationPrefs: "synced notification levels",
+    protocol.TypeReminderCreate:    "reminders (/remind)",
+    protocol.TypeReminderList:      "reminders (/remind)",
+    protocol.TypeReminderCancel:    "reminders (/remind)",
+    protocol.TypeGif:               "GIF search (/gif)",
+    protocol.TypeVoiceMessage:      "voice messages",
+    protocol.TypeLoadThread:        "threads",
+    protocol.TypeMessageRevisions:  "the edit history of messages",
+    protocol.TypeGroupDirectory:    "the public group directory",
+}
+
+// unsupportedError tells the user what the server lacks, it runs an older
+// version than the client
+func unsupportedError(msgType string) string {
+    feature := msgType
+    if name, ok := features[protocol.MessageType(msgType)]; ok {
+        feature = i18n.T(name)
+    }
+    return i18n.T("The server does not support %s, it may run an older version of Textual", feature)
+}
@@ -0,0 +1,326 @@
+// internal/client/tui/voice.go
+package tui
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"textual/internal/client/config"
+	"textual/internal/client/i18n"
+	"textual/internal/client/logging"
+	"textual/internal/client/models"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+    // bars of the waveform sent with a voice message
+    waveformBars = 32
+    // shorter recordings are dropped, the command was likely run by mistake
+    minVoiceDuration = 500 * time.Millisecond
+    // how long the recorder has to write its file once interrupted
+    recorderStopTimeout = 3 * time.Second
+)
+
+// recording is a voice message being recorded for a conversation
+type recording struct {
+    cmd         *exec.Cmd
+    path        string
+    chatID      string
+    recipientID *string
+    groupID     *string
+    started     time.Time
+}
+
+// voiceCommand runs /voice: it starts a recording, sends the one in progress,
+// or drops it with "cancel"
+func (m *Model) voiceCommand(args string) tea.Cmd {
+    switch args {
+    case "":
+        if m.recording != nil {
+            return m.sendRecording()
+        }
+        m.startRecording()
+    case "cancel":
+        if m.recording == nil {
+            m.notice = i18n.T("Nothing is being recorded")
+            return nil
+        }
+        stopRecorder(m.recording.cmd)
+        os.Remove(m.recording.path)
+        m.recording = nil
+        m.notice = i18n.T("Recording dropped")
+    default:
+        m.err = errors.New(i18n.T("usage: /voice [cancel]"))
+    }
+    return nil
+}
+
+func (m *Model) startRecording() {
+    chatID := m.activeConversation()
+    if chatID == "" {
+        m.notice = i18n.T("Open a conversation first")
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+
+    rec := &recording{chatID: chatID}
+    switch {
+    case m.currentPage == MessagesPage:
+        rec.recipientID = &chatID
+    case m.currentPage == GroupsPage:
+        rec.groupID = &chatID
+    }
+    if rec.recipientID != nil && m.connection.Encrypted(chatID) || rec.groupID != nil && m.connection.GroupEncrypted(chatID) {
+        m.err = errors.New(i18n.T("voice messages can't be sent in encrypted conversations"))
+        return
+    }
+    if m.config.Voice.Recorder == "" {
+        m.err = errors.New(i18n.T("no recorder set, add [voice] recorder to the config file"))
+        return
+    }
+
+    // the recording is made next to the downloads, it stays there once sent
+    path, err := config.AttachmentPath("recording-" + newClientID() + ".wav")
+    if err == nil {
+        err = os.MkdirAll(filepath.Dir(path), 0700)
+    }
+    if err != nil {
+        m.err = err
+        return
+    }
+    rec.path = path
+    rec.cmd = voiceCommandLine(m.config.Voice.Recorder, path)
+    if err := rec.cmd.Start(); err != nil {
+        m.err = fmt.Errorf(i18n.T("failed to start the recorder: %v"), err)
+        return
+    }
+    rec.started = time.Now()
+    m.recording = rec
+    m.notice = i18n.T("🎤 Recording… /voice to send, /voice cancel to drop")
+}
+
+// stopRecorder interrupts the recorder so it finishes its file
+func stopRecorder(cmd *exec.Cmd) {
+    if err := cmd.Process.Signal(os.Interrupt); err != nil {
+        cmd.Process.Kill()
+    }
+    done := make(chan struct{})
+    go func() {
+        // interrupted recorders exit with an error
+        cmd.Wait()
+        close(done)
+    }()
+    select {
+    case <-done:
+    case <-time.After(recorderStopTimeout):
+        logging.Warnf("Recorder did not stop, killing it")
+        cmd.Process.Kill()
+        <-done
+    }
+}
+
+// sendRecording stops the recording and sends it to its conversation
+func (m *Model) sendRecording() tea.Cmd {
+    rec := m.recording
+    m.recording = nil
+    stopRecorder(rec.cmd)
+
+    duration, waveform, err := readWAV(rec.path)
+    if err != nil {
+        logging.Warnf("Failed to read the recording: %v", err)
+        duration = time.Since(rec.started)
+    }
+    if duration < minVoiceDuration {
+        os.Remove(rec.path)
+        m.notice = i18n.T("Recording too short, dropped")
+        return nil
+    }
+
+    ms := duration.Milliseconds()
+    msg := newPendingMessage(m.userID, m.username, fmt.Sprintf(i18n.T("🎤 Voice message (%s)"), formatVoiceDuration(ms)), rec.recipientID, rec.groupID)
+    msg.Voice = &models.Voice{Duration: ms, Waveform: waveform, Path: rec.path}
+    m.notice = ""
+    return m.sendPending(msg)
+}
+
+// playVoice plays the last voice message of the conversation, downloading it
+// first when needed
+func (m *Model) playVoice(_ string) {
+    chatID := m.activeConversation()
+    var voice *models.Voice
+    for _, msg := range m.messages[chatID] {
+        if msg.Voice != nil {
+            voice = msg.Voice
+        }
+    }
+    if voice == nil {
+        m.notice = i18n.T("No voice message in this conversation")
+        return
+    }
+
+    // not uploaded yet
+    if voice.AttachmentID == "" {
+        m.play(voice.Path)
+        return
+    }
+    path, err := config.AttachmentPath(voice.AttachmentID)
+    if err != nil {
+        m.err = err
+        return
+    }
+    if _, err := os.Stat(path); err == nil {
+        m.play(path)
+        return
+    }
+    if m.connection == nil {
+        m.err = errors.New(i18n.T("not connected"))
+        return
+    }
+    if err := m.connection.DownloadAttachment(voice.AttachmentID, path); err != nil {
+        m.err = err
+        return
+    }
+    m.playing = voice.AttachmentID
+    m.notice = i18n.T("Downloading the voice message...")
+}
+
+// attachmentDownloaded plays the voice message /play was waiting for
+func (m *Model) attachmentDownloaded(msg models.AttachmentDownloaded) {
+    if msg.ID != m.playing {
+        return
+    }
+    m.playing = ""
+    m.play(msg.Path)
+}
+
+// play starts the player in the background
+func (m *Model) play(path string) {
+    if m.config.Voice.Player == "" {
+        m.err = errors.New(i18n.T("no player set, add [voice] player to the config file"))
+        return
+    }
+    cmd := voiceCommandLine(m.config.Voice.Player, path)
+    if err := cmd.Start(); err != nil {
+        m.err = fmt.Errorf(i18n.T("failed to start the player: %v"), err)
+        return
+    }
+    go cmd.Wait()
+    m.notice = i18n.T("▶ Playing the voice message")
+}
+
+// voiceCommandLine builds the recorder or player command, run by the shell
+// so the users can write it as in their terminal
+func voiceCommandLine(command, path string) *exec.Cmd {
+    quoted := "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+    return exec.Command("sh", "-c", "exec "+strings.ReplaceAll(command, "{file}", quoted))
+}
+
+// readWAV returns the duration and the waveform of a PCM WAV file. The
+// waveform is the peak of each bar, from 0 to 255, for 16-bit samples only
+func readWAV(path string) (time.Duration, []byte, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return 0, nil, err
+    }
+    if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+        return 0, nil, errors.New("not a WAV file")
+    }
+
+    var channels, bits int
+    var byteRate int64
+    for pos := 12; pos+8 <= len(data); {
+        id := string(data[pos : pos+4])
+        size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+        pos += 8
+        // recorders interrupted before writing the sizes leave them at the max
+        if size < 0 || size > len(data)-pos {
+            size = len(data) - pos
+        }
+        switch id {
+        case "fmt ":
+            if size < 16 {
+                return 0, nil, errors.New("invalid WAV format")
+            }
+            channels = int(binary.LittleEndian.Uint16(data[pos+2:]))
+            byteRate = int64(binary.LittleEndian.Uint32(data[pos+8:]))
+            bits = int(binary.LittleEndian.Uint16(data[pos+14:]))
+        case "data":
+            if byteRate == 0 {
+                return 0, nil, errors.New("WAV data before its format")
+            }
+            samples := data[pos : pos+size]
+            duration := time.Duration(int64(len(samples)) * int64(time.Second) / byteRate)
+            if bits != 16 || channels < 1 {
+                return duration, nil, nil
+            }
+            return duration, waveform(samples, channels), nil
+        }
+        pos += size + size%2
+    }
+    return 0, nil, errors.New("WAV file without data")
+}
+
+// waveform keeps the peak of the first channel in each bar
+func waveform(samples []byte, channels int) []byte {
+    frame := 2 * channels
+    frames := len(samples) / frame
+    if frames == 0 {
+        return nil
+    }
+    bars := make([]byte, waveformBars)
+    for i := range bars {
+        var peak int
+        for f := i * frames / waveformBars; f < (i+1)*frames/waveformBars; f++ {
+            sample := int(int16(binary.LittleEndian.Uint16(samples[f*frame:])))
+            if sample < 0 {
+                sample = -sample
+            }
+            if sample > peak {
+                peak = sample
+            }
+        }
+        bars[i] = byte(peak * 255 / 32768)
+    }
+    return bars
+}
+
+// renderContent writes the text of a message, or the waveform and duration
+// of a voice message
+func renderContent(msg models.Message, textStyle lipgloss.Style) string {
+    if msg.Voice == nil {
+        return renderLinks(msg.Content, textStyle)
+    }
+    return renderVoice(*msg.Voice)
+}
+
+var waveformLevels = []rune("▁▂▃▄▅▆▇█")
+
+// renderVoice draws a voice message as "🎤 ▂▅▇▃ 0:12"
+func renderVoice(voice models.Voice) string {
+    var sb strings.Builder
+    sb.WriteString("🎤 ")
+    if len(voice.Waveform) == 0 {
+        sb.WriteString(i18n.T("Voice message"))
+    }
+    for _, level := range voice.Waveform {
+        sb.WriteRune(waveformLevels[int(level)*len(waveformLevels)/256])
+    }
+    return lipgloss.NewStyle().Foreground(currentTheme.Primary).Render(sb.String()) + " " +
+        timestampStyleBase.Render(formatVoiceDuration(voice.Duration))
+}
+
+// formatVoiceDuration writes a duration in milliseconds as m:ss
+func formatVoiceDuration(ms int64) string {
+    seconds := (ms + 500) / 1000
+    return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,28 @@
+// internal/client/tui/width.go
+package tui
+
+import (
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// the widths are counted in terminal cells as the terminals do: wide runes
+// and emoji take two, combining marks and joiners none. Measuring bytes or
+// runes breaks the columns as soon as a name isn't ASCII
+
+// textWidth returns the cells taken by a text, its styles excluded
+func textWidth(s string) int {
+    return runewidth.StringWidth(ansi.Strip(s))
+}
+
+// truncateWidth cuts a plain text to width cells, ending with an ellipsis
+// when something was cut
+func truncateWidth(s string, width int) string {
+    return runewidth.Truncate(s, width, "…")
+}
+
+// fitWidth cuts or pads a plain text to exactly width cells, for the
+// columns
+func fitWidth(s string, width int) string {
+    return runewidth.FillRight(truncateWidth(s, width), width)
+}
@@ -0,0 +1,40 @@
+// internal/client/tui/width_test.go
+package tui
+
+import "testing"
+
+func TestTextWidth(t *testing.T) {
+    for _, test := range []struct {
+        in   string
+        want int
+    }{
+        {"alice", 5},
+        {"日本語", 6},
+        {"café", 4},
+        {"cafe\u0301", 4},
+        {"🎉 party", 8},
+        {"\x1b[1;31mbold\x1b[0m", 4},
+    } {
+        if got := textWidth(test.in); got != test.want {
+            t.Errorf("textWidth(%q) = %d, want %d", test.in, got, test.want)
+        }
+    }
+}
+
+func TestFitWidth(t *testing.T) {
+    for _, name := range []string{"bob", "日本語のとても長い名前", "🎉🎉🎉🎉🎉🎉🎉🎉", "ünïcödé"} {
+        if got := fitWidth(name, 14); textWidth(got) != 14 {
+            t.Errorf("fitWidth(%q, 14) = %q, %d cells", name, got, textWidth(got))
+        }
+    }
+}
+
+func TestRenderSenderColumn(t *testing.T) {
+    defer SetIdentity(true, false)
+    SetIdentity(true, false)
+    for _, name := range []string{"bob", "日本語のとても長い名前", "ñoño", "🎉 party"} {
+        if got := textWidth(renderSender("u1", name)); got != 15 {
+            t.Errorf("renderSender(%q) takes %d cells, want 15", name, got)
+        }
+    }
+}
@@ -0,0 +1,23 @@
+// internal/client/tui/wrap.go
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// below this width the text is wrapped anyway, the terminal cuts the rest
+const minWrapWidth = 10
+
+// wrapMessage wraps the text of a message to the room left after its prefix
+// (time and author), the next lines start under the text. The width is
+// counted in cells, wide runes and emoji take two, styles and links none
+func wrapMessage(content string, width, indent int) string {
+    width -= indent
+    if width < minWrapWidth {
+        width = minWrapWidth
+    }
+    lines := strings.Split(ansi.Wrap(content, width, "-"), "\n")
+    return strings.Join(lines, "\n"+strings.Repeat(" ", indent))
+}
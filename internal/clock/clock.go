@@ -0,0 +1,149 @@
+// internal/clock/clock.go
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time seen by the timers of the server and the client, the
+// tests replace it with a Fake they move forward instead of sleeping
+type Clock interface {
+    Now() time.Time
+    After(d time.Duration) <-chan time.Time
+    NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is a time.Ticker of a Clock
+type Ticker interface {
+    C() <-chan time.Time
+    Stop()
+}
+
+// Real is the clock of the system
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+    *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// Fake is a clock that only moves with Advance. Its timers fire during the
+// call to Advance that reaches them, and like those of the time package
+// drop the ticks a slow reader misses
+type Fake struct {
+    mu      sync.Mutex
+    cond    *sync.Cond
+    now     time.Time
+    waiters []*waiter
+}
+
+// waiter is a timer of After or a ticker, period is 0 for a timer
+type waiter struct {
+    at     time.Time
+    period time.Duration
+    c      chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+    f := &Fake{now: now}
+    f.cond = sync.NewCond(&f.mu)
+    return f
+}
+
+func (f *Fake) Now() time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    w := &waiter{at: f.now.Add(d), c: make(chan time.Time, 1)}
+    if d <= 0 {
+        w.c <- f.now
+        return w.c
+    }
+    f.add(w)
+    return w.c
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+    if d <= 0 {
+        panic("clock: non-positive interval for NewTicker")
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    w := &waiter{at: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+    f.add(w)
+    return &fakeTicker{f: f, w: w}
+}
+
+func (f *Fake) add(w *waiter) {
+    f.waiters = append(f.waiters, w)
+    f.cond.Broadcast()
+}
+
+// Advance moves the clock forward and fires the timers due on the way, in
+// the order of their time
+func (f *Fake) Advance(d time.Duration) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    end := f.now.Add(d)
+    for {
+        sort.Slice(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+        if len(f.waiters) == 0 || f.waiters[0].at.After(end) {
+            break
+        }
+        w := f.waiters[0]
+        f.now = w.at
+        select {
+        case w.c <- w.at:
+        default:
+        }
+        if w.period > 0 {
+            w.at = w.at.Add(w.period)
+        } else {
+            f.waiters = f.waiters[1:]
+        }
+    }
+    f.now = end
+}
+
+// BlockUntil waits for n timers and tickers to be waiting, so a test moves
+// the clock once the goroutine it checks has set its timer
+func (f *Fake) BlockUntil(n int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for len(f.waiters) < n {
+        f.cond.Wait()
+    }
+}
+
+func (f *Fake) remove(w *waiter) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for i, other := range f.waiters {
+        if other == w {
+            f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+            return
+        }
+    }
+}
+
+type fakeTicker struct {
+    f *Fake
+    w *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t *fakeTicker) Stop()               { t.f.remove(t.w) }
@@ -0,0 +1,94 @@
+// internal/clock/clock_test.go
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func fired(c <-chan time.Time) (time.Time, bool) {
+    select {
+    case t := <-c:
+        return t, true
+    default:
+        return time.Time{}, false
+    }
+}
+
+func TestFakeAfter(t *testing.T) {
+    f := NewFake(epoch)
+    c := f.After(time.Minute)
+
+    f.Advance(59 * time.Second)
+    if _, ok := fired(c); ok {
+        t.Fatal("fired before its time")
+    }
+    f.Advance(time.Second)
+    if at, ok := fired(c); !ok || !at.Equal(epoch.Add(time.Minute)) {
+        t.Fatalf("fired = %v at %v, want at %v", ok, at, epoch.Add(time.Minute))
+    }
+    if !f.Now().Equal(epoch.Add(time.Minute)) {
+        t.Errorf("now = %v", f.Now())
+    }
+
+    if _, ok := fired(f.After(0)); !ok {
+        t.Error("a timer of 0 did not fire at once")
+    }
+}
+
+func TestFakeTicker(t *testing.T) {
+    f := NewFake(epoch)
+    ticker := f.NewTicker(10 * time.Second)
+
+    f.Advance(25 * time.Second)
+    // like time.Ticker, the ticks not read are dropped
+    if at, ok := fired(ticker.C()); !ok || !at.Equal(epoch.Add(10*time.Second)) {
+        t.Fatalf("tick = %v at %v", ok, at)
+    }
+    if _, ok := fired(ticker.C()); ok {
+        t.Fatal("more than one tick kept")
+    }
+
+    f.Advance(5 * time.Second)
+    if at, ok := fired(ticker.C()); !ok || !at.Equal(epoch.Add(30*time.Second)) {
+        t.Fatalf("tick = %v at %v, want at 30s", ok, at)
+    }
+
+    ticker.Stop()
+    f.Advance(time.Minute)
+    if _, ok := fired(ticker.C()); ok {
+        t.Error("a stopped ticker ticked")
+    }
+}
+
+func TestFakeOrder(t *testing.T) {
+    f := NewFake(epoch)
+    late := f.After(3 * time.Second)
+    early := f.After(time.Second)
+
+    f.Advance(5 * time.Second)
+    a, _ := fired(early)
+    b, _ := fired(late)
+    if !a.Before(b) {
+        t.Errorf("early fired at %v, late at %v", a, b)
+    }
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+    f := NewFake(epoch)
+    done := make(chan struct{})
+    go func() {
+        <-f.After(time.Second)
+        close(done)
+    }()
+
+    f.BlockUntil(1)
+    f.Advance(time.Second)
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("the goroutine was not woken")
+    }
+}
@@ -0,0 +1,173 @@
+// internal/server/api/integrations.go
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"textual/internal/server/database"
+	"textual/internal/server/integrations"
+	"textual/internal/server/models"
+)
+
+// largest delivery read, GitHub caps its payloads at 25 MB but the events
+// posted are far smaller
+const maxDeliverySize = 1 << 20
+
+// handleCreateIntegration adds a GitHub or GitLab integration to a group of
+// which the user is an admin. The answer holds the URL and the secret to set
+// in the webhook settings of the repository
+func (s *Server) handleCreateIntegration(w http.ResponseWriter, r *http.Request, user *models.User, _ string) {
+    groupID := r.PathValue("id")
+    if !s.requireGroupAdmin(r.Context(), w, user, groupID) {
+        return
+    }
+    var body struct {
+        Kind string `json:"kind"`
+    }
+    if err := decodeBody(w, r, &body); err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if !integrations.Valid(body.Kind) {
+        writeError(w, http.StatusBadRequest, `kind must be "github" or "gitlab"`)
+        return
+    }
+    // the server posts in clear, it can't write in an encrypted group
+    if encrypted, err := s.db.IsGroupEncrypted(r.Context(), groupID); err != nil {
+        writeAPIError(w, err)
+        return
+    } else if encrypted {
+        writeError(w, http.StatusBadRequest, "integrations can't post in encrypted groups")
+        return
+    }
+
+    raw := make([]byte, 24)
+    if _, err := rand.Read(raw); err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    integration := &models.Integration{
+        GroupID:   groupID,
+        Kind:      body.Kind,
+        Secret:    hex.EncodeToString(raw),
+        CreatedBy: user.ID,
+    }
+    if err := s.db.CreateIntegration(r.Context(), integration); err != nil {
+        writeAPIError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusCreated, map[string]interface{}{
+        "id":         integration.ID,
+        "kind":       integration.Kind,
+        "url":        hookURL(r, integration.ID),
+        "secret":     integration.Secret,
+        "created_at": integration.CreatedAt.Unix(),
+    })
+}
+
+// handleIntegrations lists the integrations of a group, without the secrets
+func (s *Server) handleIntegrations(w http.ResponseWriter, r *http.Request, user *models.User, _ string) {
+    groupID := r.PathValue("id")
+    if !s.requireGroupAdmin(r.Context(), w, user, groupID) {
+        return
+    }
+    list, err := s.db.GetGroupIntegrations(r.Context(), groupID)
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    response := make([]map[string]interface{}, 0, len(list))
+    for _, integration := range list {
+        response = append(response, map[string]interface{}{
+            "id":         integration.ID,
+            "kind":       integration.Kind,
+            "url":        hookURL(r, integration.ID),
+            "created_by": integration.CreatedBy,
+            "created_at": integration.CreatedAt.Unix(),
+        })
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"integrations": response})
+}
+
+func (s *Server) handleDeleteIntegration(w http.ResponseWriter, r *http.Request, user *models.User, _ string) {
+    groupID := r.PathValue("id")
+    if !s.requireGroupAdmin(r.Context(), w, user, groupID) {
+        return
+    }
+    deleted, err := s.db.DeleteIntegration(r.Context(), groupID, r.PathValue("integration"))
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    if !deleted {
+        writeError(w, http.StatusNotFound, "no such integration")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHook receives a delivery of GitHub or GitLab, authenticated by the
+// secret of the integration instead of a token
+func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
+    integration, err := s.db.GetIntegration(r.Context(), r.PathValue("id"))
+    if err == database.ErrIntegrationNotFound {
+        writeError(w, http.StatusNotFound, "no such integration")
+        return
+    }
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+
+    body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDeliverySize))
+    if err != nil {
+        writeError(w, http.StatusRequestEntityTooLarge, "delivery too large")
+        return
+    }
+    if err := integrations.Verify(integration.Kind, integration.Secret, r.Header, body); err != nil {
+        writeError(w, http.StatusUnauthorized, err.Error())
+        return
+    }
+
+    content, err := integrations.Format(integration.Kind, r.Header, body)
+    if errors.Is(err, integrations.ErrIgnored) {
+        w.WriteHeader(http.StatusNoContent)
+        return
+    }
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if err := s.messages.PostIntegrationMessage(r.Context(), integration, content, integrations.DeliveryID(integration.Kind, r.Header)); err != nil {
+        log.Printf("Failed to post %s delivery in group %s: %v", integration.Kind, integration.GroupID, err)
+        writeAPIError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// requireGroupAdmin answers 403 unless the user is an admin of the group
+func (s *Server) requireGroupAdmin(ctx context.Context, w http.ResponseWriter, user *models.User, groupID string) bool {
+    role, err := s.db.GetGroupRole(ctx, user.ID, groupID)
+    if err != nil || role != "admin" {
+        writeError(w, http.StatusForbidden, "only the admins of the group manage its integrations")
+        return false
+    }
+    return true
+}
+
+// hookURL is the address of the webhook as seen by the client, a proxy in
+// front of the server may set the scheme
+func hookURL(r *http.Request, id string) string {
+    scheme := "http"
+    if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+        scheme = "https"
+    }
+    return scheme + "://" + r.Host + "/api/v1/hooks/" + id
+}
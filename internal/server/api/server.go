@@ -0,0 +1,408 @@
+// internal/server/api/server.go
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"textual/internal/server/database"
+	"textual/internal/server/handlers"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+const (
+    // largest request body, a message and its fields
+    maxBodySize = 64 << 10
+    // history page returned when the request sets no limit
+    defaultPageSize = 50
+    maxPageSize     = 100
+    // direct conversations listed by /conversations
+    maxConversations = 200
+    tokenPrefix      = "txt_"
+)
+
+// Server is the REST API: the same conversations as the TCP protocol, for
+// scripts and dashboards. Requests are authenticated with a token created
+// with the password of the user, see handleCreateToken
+type Server struct {
+    db       database.Store
+    messages *handlers.MessageHandler
+    http     *http.Server
+}
+
+func NewServer(db database.Store, messages *handlers.MessageHandler) *Server {
+    s := &Server{db: db, messages: messages}
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("POST /api/v1/tokens", s.handleCreateToken)
+    mux.HandleFunc("DELETE /api/v1/tokens/{id}", s.authenticated(s.handleDeleteToken))
+    mux.HandleFunc("GET /api/v1/conversations", s.authenticated(s.handleConversations))
+    mux.HandleFunc("GET /api/v1/messages", s.authenticated(s.handleHistory))
+    mux.HandleFunc("POST /api/v1/messages", s.authenticated(s.handlePost))
+    mux.HandleFunc("POST /api/v1/groups/{id}/integrations", s.authenticated(s.handleCreateIntegration))
+    mux.HandleFunc("GET /api/v1/groups/{id}/integrations", s.authenticated(s.handleIntegrations))
+    mux.HandleFunc("DELETE /api/v1/groups/{id}/integrations/{integration}", s.authenticated(s.handleDeleteIntegration))
+    mux.HandleFunc("POST /api/v1/hooks/{id}", s.handleHook)
+
+    s.http = &http.Server{
+        Handler:           mux,
+        ReadHeaderTimeout: 10 * time.Second,
+        ReadTimeout:       30 * time.Second,
+        WriteTimeout:      30 * time.Second,
+    }
+    return s
+}
+
+// Start listens on the port and serves in the background
+func (s *Server) Start(port string) error {
+    listener, err := net.Listen("tcp", ":"+port)
+    if err != nil {
+        return err
+    }
+
+    log.Printf("REST API started on port %s", port)
+    go func() {
+        if err := s.http.Serve(listener); err != nil && err != http.ErrServerClosed {
+            log.Printf("REST API error: %v", err)
+        }
+    }()
+    return nil
+}
+
+// Stop lets the requests in progress finish
+func (s *Server) Stop() {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    s.http.Shutdown(ctx)
+}
+
+type userHandler func(w http.ResponseWriter, r *http.Request, user *models.User, tokenID string)
+
+// authenticated checks the "Authorization: Bearer <token>" header
+func (s *Server) authenticated(next userHandler) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !ok || !strings.HasPrefix(token, tokenPrefix) {
+            writeError(w, http.StatusUnauthorized, "missing bearer token")
+            return
+        }
+        user, tokenID, err := s.db.GetAPITokenUser(r.Context(), hashToken(token))
+        if err == database.ErrInvalidToken {
+            writeError(w, http.StatusUnauthorized, "invalid token")
+            return
+        }
+        if err != nil {
+            log.Printf("API authentication error: %v", err)
+            writeError(w, http.StatusServiceUnavailable, "authentication unavailable")
+            return
+        }
+        next(w, r, user, tokenID)
+    }
+}
+
+// handleCreateToken makes a token for the user of the basic auth header. The
+// token is only in this answer, the server keeps its hash
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+    username, password, ok := r.BasicAuth()
+    if !ok {
+        w.Header().Set("WWW-Authenticate", `Basic realm="textual"`)
+        writeError(w, http.StatusUnauthorized, "username and password required")
+        return
+    }
+    var body struct {
+        Name string `json:"name"`
+    }
+    if r.ContentLength != 0 {
+        if err := decodeBody(w, r, &body); err != nil {
+            writeError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+    }
+    if len(body.Name) > 50 {
+        writeError(w, http.StatusBadRequest, "token name too long (50 characters max)")
+        return
+    }
+
+    user, err := s.db.VerifyPassword(r.Context(), username, password)
+    if err == database.ErrInvalidCredentials {
+        writeError(w, http.StatusUnauthorized, err.Error())
+        return
+    }
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+
+    raw := make([]byte, 32)
+    if _, err := rand.Read(raw); err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    token := tokenPrefix + hex.EncodeToString(raw)
+    id, createdAt, err := s.db.CreateAPIToken(r.Context(), user.ID, body.Name, hashToken(token))
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusCreated, map[string]interface{}{
+        "id":         id,
+        "name":       body.Name,
+        "token":      token,
+        "user_id":    user.ID,
+        "created_at": createdAt.Unix(),
+    })
+}
+
+// handleDeleteToken revokes a token of the user, "current" is the token of
+// the request
+func (s *Server) handleDeleteToken(w http.ResponseWriter, r *http.Request, user *models.User, tokenID string) {
+    id := r.PathValue("id")
+    if id == "current" {
+        id = tokenID
+    }
+    deleted, err := s.db.DeleteAPIToken(r.Context(), user.ID, id)
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    if !deleted {
+        writeError(w, http.StatusNotFound, "no such token")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+type conversation struct {
+    Type string `json:"type"` // global, group or direct
+    ID   string `json:"id"`
+    Name string `json:"name"`
+}
+
+// handleConversations lists the global chat, the groups of the user and the
+// users it exchanged direct messages with
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request, user *models.User, _ string) {
+    groups, err := s.db.GetUserGroups(r.Context(), user.ID)
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    contacts, err := s.db.GetDirectContacts(r.Context(), user.ID, maxConversations)
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+
+    conversations := []conversation{{Type: "global", ID: "global", Name: "Global"}}
+    for _, group := range groups {
+        // the server can't read the messages of encrypted groups
+        if !group.Encrypted {
+            conversations = append(conversations, conversation{Type: "group", ID: group.ID, Name: group.Name})
+        }
+    }
+    for _, contact := range contacts {
+        conversations = append(conversations, conversation{Type: "direct", ID: contact.ID, Name: contact.Username})
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"conversations": conversations})
+}
+
+// handleHistory returns a page of a conversation, newest first: ?user=<id>
+// for a direct conversation, ?group=<id> for a group, none for the global
+// chat. ?before=<message id> and ?limit= page through it, next_before is the
+// value of before for the next page
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, user *models.User, _ string) {
+    query := r.URL.Query()
+    limit := defaultPageSize
+    if value := query.Get("limit"); value != "" {
+        n, err := strconv.Atoi(value)
+        if err != nil || n < 1 || n > maxPageSize {
+            writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+            return
+        }
+        limit = n
+    }
+    if query.Get("user") != "" && query.Get("group") != "" {
+        writeError(w, http.StatusBadRequest, "user and group can't be used together")
+        return
+    }
+    if query.Get("user") != "" && !s.userExists(r.Context(), w, query.Get("user")) {
+        return
+    }
+
+    answers, err := s.messages.HandleAPI(r.Context(), user, protocol.NewMessage(protocol.TypeLoadMessages, protocol.LoadMessagesPayload{
+        RecipientID: query.Get("user"),
+        GroupID:     query.Get("group"),
+        BeforeID:    query.Get("before"),
+        Limit:       limit,
+    }))
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    var history struct {
+        Messages []models.Message `json:"messages"`
+    }
+    if len(answers) == 0 || answers[0].Type != protocol.TypeMessageHistory || remarshal(answers[0].Payload, &history) != nil {
+        writeError(w, http.StatusInternalServerError, "no history")
+        return
+    }
+
+    response := map[string]interface{}{"messages": history.Messages}
+    if history.Messages == nil {
+        response["messages"] = []models.Message{}
+    }
+    if len(history.Messages) == limit {
+        response["next_before"] = history.Messages[len(history.Messages)-1].ID
+    }
+    writeJSON(w, http.StatusOK, response)
+}
+
+// handlePost sends a message, to the user of user_id, the group of group_id
+// or the global chat. A client_id makes the request safe to retry: the
+// message is stored once
+func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, user *models.User, _ string) {
+    var body struct {
+        Content     string `json:"content"`
+        RecipientID string `json:"user_id"`
+        GroupID     string `json:"group_id"`
+        ClientID    string `json:"client_id"`
+    }
+    if err := decodeBody(w, r, &body); err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    switch {
+    case strings.TrimSpace(body.Content) == "":
+        writeError(w, http.StatusBadRequest, "missing content")
+        return
+    case body.RecipientID != "" && body.GroupID != "":
+        writeError(w, http.StatusBadRequest, "user_id and group_id can't be used together")
+        return
+    case len(body.ClientID) > 64:
+        writeError(w, http.StatusBadRequest, "client_id too long (64 characters max)")
+        return
+    }
+    if body.RecipientID != "" && !s.userExists(r.Context(), w, body.RecipientID) {
+        return
+    }
+    if body.ClientID == "" {
+        raw := make([]byte, 16)
+        if _, err := rand.Read(raw); err != nil {
+            writeAPIError(w, err)
+            return
+        }
+        body.ClientID = "api-" + hex.EncodeToString(raw)
+    }
+
+    payload := map[string]interface{}{"content": body.Content, "client_id": body.ClientID}
+    msgType := protocol.TypeGlobalMessage
+    switch {
+    case body.RecipientID != "":
+        msgType = protocol.TypeDirectMessage
+        payload["recipient_id"] = body.RecipientID
+    case body.GroupID != "":
+        msgType = protocol.TypeGroupMessage
+        payload["group_id"] = body.GroupID
+    }
+    if _, err := s.messages.HandleAPI(r.Context(), user, protocol.NewMessage(msgType, payload)); err != nil {
+        writeAPIError(w, err)
+        return
+    }
+
+    id, err := s.db.GetMessageIDByClientID(r.Context(), user.ID, body.ClientID)
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    msg, err := s.db.GetMessage(r.Context(), id)
+    if err != nil {
+        writeAPIError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, msg)
+}
+
+// userExists answers 404 when there is no such user, the handlers would fail
+// on the foreign key
+func (s *Server) userExists(ctx context.Context, w http.ResponseWriter, userID string) bool {
+    if _, err := s.db.GetUser(ctx, userID); err != nil {
+        writeError(w, http.StatusNotFound, "user not found")
+        return false
+    }
+    return true
+}
+
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, target interface{}) error {
+    decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(target); err != nil {
+        return fmt.Errorf("invalid JSON body: %v", err)
+    }
+    return nil
+}
+
+// remarshal converts a payload built by the handlers to its type
+func remarshal(payload interface{}, target interface{}) error {
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, target)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(body); err != nil {
+        log.Printf("Failed to write API response: %v", err)
+    }
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeAPIError answers with the status matching a protocol error, the other
+// errors are logged and hidden
+func writeAPIError(w http.ResponseWriter, err error) {
+    var protoErr protocol.Error
+    if !errors.As(err, &protoErr) {
+        log.Printf("API error: %v", err)
+        writeError(w, http.StatusInternalServerError, "internal error")
+        return
+    }
+
+    status := http.StatusBadRequest
+    switch protoErr.Code {
+    case protocol.ErrCodeAccessDenied, protocol.ErrCodeNotAuthorized:
+        status = http.StatusForbidden
+    case protocol.ErrCodeUserNotFound, protocol.ErrCodeGroupNotFound:
+        status = http.StatusNotFound
+    case protocol.ErrCodeAlreadyExists:
+        status = http.StatusConflict
+    case protocol.ErrCodeUnavailable:
+        status = http.StatusServiceUnavailable
+    case protocol.ErrCodeRateLimited:
+        status = http.StatusTooManyRequests
+        w.Header().Set("Retry-After", strconv.Itoa(protoErr.RetryAfterSeconds()))
+    case protocol.ErrCodeInternalError:
+        status = http.StatusInternalServerError
+    }
+    writeError(w, status, protoErr.Message)
+}
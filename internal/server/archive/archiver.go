@@ -0,0 +1,224 @@
+// internal/server/archive/archiver.go
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"textual/internal/clock"
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+	"time"
+)
+
+// Archiver periodically moves old messages out of the messages table into
+// gzip-compressed JSON lines files, keeping a pointer to each archived message
+type Archiver struct {
+    db        database.Store
+    dir       string
+    maxAge    time.Duration
+    interval  time.Duration
+    batchSize int
+    clock     clock.Clock
+    done      chan struct{}
+}
+
+func NewArchiver(db database.Store, dir string, maxAge, interval time.Duration, batchSize int) *Archiver {
+    return &Archiver{
+        db:        db,
+        dir:       dir,
+        maxAge:    maxAge,
+        interval:  interval,
+        batchSize: batchSize,
+        clock:     clock.Real,
+        done:      make(chan struct{}),
+    }
+}
+
+// SetClock replaces the clock of the runs and of the age of the messages,
+// before Start
+func (a *Archiver) SetClock(c clock.Clock) {
+    a.clock = c
+}
+
+func (a *Archiver) Start() error {
+    if err := os.MkdirAll(a.dir, 0755); err != nil {
+        return fmt.Errorf("failed to create archive directory: %v", err)
+    }
+
+    log.Printf("Message archiver started: messages older than %s go to %s", a.maxAge, a.dir)
+    go a.run()
+    return nil
+}
+
+func (a *Archiver) Stop() {
+    close(a.done)
+}
+
+func (a *Archiver) run() {
+    // the batches are not tied to a request
+    ctx := context.Background()
+    ticker := a.clock.NewTicker(a.interval)
+    defer ticker.Stop()
+
+    a.archiveAll(ctx)
+    for {
+        select {
+        case <-a.done:
+            return
+        case <-ticker.C():
+            a.archiveAll(ctx)
+        }
+    }
+}
+
+// archiveAll archives batches until no message is older than the threshold
+func (a *Archiver) archiveAll(ctx context.Context) {
+    for {
+        count, err := a.ArchiveOnce(ctx)
+        if err != nil {
+            log.Printf("Archive error: %v", err)
+            return
+        }
+        if count < a.batchSize {
+            return
+        }
+    }
+}
+
+// ArchiveOnce archives a single batch and returns the number of archived messages
+func (a *Archiver) ArchiveOnce(ctx context.Context) (int, error) {
+    cutoff := a.clock.Now().Add(-a.maxAge)
+    records, err := a.db.GetMessagesOlderThan(ctx, cutoff, a.batchSize)
+    if err != nil {
+        return 0, err
+    }
+    if len(records) == 0 {
+        return 0, nil
+    }
+
+    path := filepath.Join(a.dir, fmt.Sprintf("messages-%s.jsonl.gz", time.Now().UTC().Format("20060102-150405.000000000")))
+    if err := writeArchive(path, records); err != nil {
+        os.Remove(path)
+        return 0, err
+    }
+
+    if err := a.db.ArchiveMessages(ctx, records, path); err != nil {
+        os.Remove(path)
+        return 0, err
+    }
+
+    log.Printf("Archived %d messages to %s", len(records), path)
+    return len(records), nil
+}
+
+func writeArchive(path string, records []database.ArchiveRecord) error {
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+    if err != nil {
+        return fmt.Errorf("failed to create archive file: %v", err)
+    }
+    defer file.Close()
+
+    gz := gzip.NewWriter(file)
+    encoder := json.NewEncoder(gz)
+    for _, record := range records {
+        if err := encoder.Encode(record); err != nil {
+            return fmt.Errorf("failed to write archived message: %v", err)
+        }
+    }
+
+    if err := gz.Close(); err != nil {
+        return fmt.Errorf("failed to flush archive file: %v", err)
+    }
+    return file.Sync()
+}
+
+// LoadMessage reads an archived message back from its archive file
+func (a *Archiver) LoadMessage(ctx context.Context, messageID string) (*models.Message, error) {
+    archived, err := a.db.GetArchivedMessage(ctx, messageID)
+    if err != nil {
+        return nil, err
+    }
+    records, err := ReadRecords([]database.ArchivedMessage{*archived})
+    if err != nil {
+        return nil, err
+    }
+    return &records[0].Message, nil
+}
+
+// ReadMessages reads archived messages back from their archive files, in
+// the order of the pointers
+func ReadMessages(pointers []database.ArchivedMessage) ([]models.Message, error) {
+    records, err := ReadRecords(pointers)
+    if err != nil {
+        return nil, err
+    }
+    messages := make([]models.Message, len(records))
+    for i := range records {
+        messages[i] = records[i].Message
+    }
+    return messages, nil
+}
+
+// ReadRecords reads archived messages with their edit history, in the
+// order of the pointers. Each file is read once
+func ReadRecords(pointers []database.ArchivedMessage) ([]database.ArchiveRecord, error) {
+    // position of the pointers of each file, by line
+    files := make(map[string]map[int]int)
+    for i, p := range pointers {
+        if files[p.ArchivePath] == nil {
+            files[p.ArchivePath] = make(map[int]int)
+        }
+        files[p.ArchivePath][p.ArchiveLine] = i
+    }
+
+    records := make([]database.ArchiveRecord, len(pointers))
+    for path, lines := range files {
+        if err := readLines(path, lines, records); err != nil {
+            return nil, err
+        }
+    }
+    return records, nil
+}
+
+// readLines decodes the wanted lines of an archive file into records, at
+// the position given for each line
+func readLines(path string, lines map[int]int, records []database.ArchiveRecord) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("failed to open archive file: %v", err)
+    }
+    defer file.Close()
+
+    gz, err := gzip.NewReader(file)
+    if err != nil {
+        return fmt.Errorf("failed to read archive file: %v", err)
+    }
+    defer gz.Close()
+
+    found := 0
+    scanner := bufio.NewScanner(gz)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for line := 0; scanner.Scan() && found < len(lines); line++ {
+        i, ok := lines[line]
+        if !ok {
+            continue
+        }
+        if err := json.Unmarshal(scanner.Bytes(), &records[i]); err != nil {
+            return fmt.Errorf("failed to decode archived message: %v", err)
+        }
+        found++
+    }
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("failed to scan archive file: %v", err)
+    }
+    if found < len(lines) {
+        return fmt.Errorf("%d messages missing from %s", len(lines)-found, path)
+    }
+    return nil
+}
@@ -0,0 +1,140 @@
+// internal/server/attachments/store.go
+package attachments
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// an upload with no chunk for this long is dropped
+const uploadTimeout = 10 * time.Minute
+
+var (
+    ErrTooLarge   = errors.New("attachment too large")
+    ErrBadChunk   = errors.New("chunk out of order")
+    ErrIncomplete = errors.New("attachment incomplete")
+)
+
+// Store keeps the uploaded files on disk, named by their ID. The uploads in
+// progress are written to temporary files next to them
+type Store struct {
+    dir     string
+    maxSize int64
+    mu      sync.Mutex
+    uploads map[string]*upload
+}
+
+type upload struct {
+    file     *os.File
+    size     int64
+    written  int64
+    lastSeen time.Time
+}
+
+func NewStore(dir string, maxSize int64) (*Store, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, fmt.Errorf("failed to create attachment directory: %v", err)
+    }
+    return &Store{
+        dir:     dir,
+        maxSize: maxSize,
+        uploads: make(map[string]*upload),
+    }, nil
+}
+
+func (s *Store) MaxSize() int64 {
+    return s.maxSize
+}
+
+// Write appends a chunk to the upload of a user. It returns the temporary
+// file once the last chunk is written, to be committed under the ID
+func (s *Store) Write(userID string, chunk protocol.AttachmentChunkPayload) (string, bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.expire()
+
+    key := userID + "/" + chunk.UploadID
+    up, ok := s.uploads[key]
+    if chunk.Offset == 0 {
+        if ok {
+            s.abort(key, up)
+        }
+        if chunk.Size <= 0 || chunk.Size > s.maxSize {
+            return "", false, ErrTooLarge
+        }
+        file, err := os.CreateTemp(s.dir, ".upload-*")
+        if err != nil {
+            return "", false, fmt.Errorf("failed to create upload file: %v", err)
+        }
+        up = &upload{file: file, size: chunk.Size, lastSeen: time.Now()}
+        s.uploads[key] = up
+    } else if !ok || chunk.Offset != up.written {
+        return "", false, ErrBadChunk
+    }
+
+    if up.written+int64(len(chunk.Data)) > up.size {
+        s.abort(key, up)
+        return "", false, ErrTooLarge
+    }
+    if _, err := up.file.Write(chunk.Data); err != nil {
+        s.abort(key, up)
+        return "", false, fmt.Errorf("failed to write upload: %v", err)
+    }
+    up.written += int64(len(chunk.Data))
+    up.lastSeen = time.Now()
+
+    if !chunk.Final {
+        return "", false, nil
+    }
+    delete(s.uploads, key)
+    up.file.Close()
+    if up.written != up.size {
+        os.Remove(up.file.Name())
+        return "", false, ErrIncomplete
+    }
+    return up.file.Name(), true, nil
+}
+
+// Commit moves a finished upload to its place
+func (s *Store) Commit(tmpPath, id string) error {
+    if err := os.Rename(tmpPath, s.path(id)); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to store attachment: %v", err)
+    }
+    return nil
+}
+
+// Discard drops a finished upload that was not committed
+func (s *Store) Discard(tmpPath string) {
+    os.Remove(tmpPath)
+}
+
+// Open returns the content of an attachment
+func (s *Store) Open(id string) (*os.File, error) {
+    return os.Open(s.path(id))
+}
+
+// path keeps the ID from naming a file out of the directory
+func (s *Store) path(id string) string {
+    return filepath.Join(s.dir, filepath.Base(id))
+}
+
+func (s *Store) abort(key string, up *upload) {
+    up.file.Close()
+    os.Remove(up.file.Name())
+    delete(s.uploads, key)
+}
+
+// expire drops the uploads left unfinished, the lock is held
+func (s *Store) expire() {
+    for key, up := range s.uploads {
+        if time.Since(up.lastSeen) > uploadTimeout {
+            s.abort(key, up)
+        }
+    }
+}
@@ -0,0 +1,151 @@
+// internal/server/bootstrap/bootstrap.go
+package bootstrap
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+
+	"textual/internal/chaos"
+	"textual/internal/server/api"
+	"textual/internal/server/archive"
+	"textual/internal/server/attachments"
+	"textual/internal/server/chat"
+	"textual/internal/server/config"
+	"textual/internal/server/database"
+	"textual/internal/server/gifs"
+	"textual/internal/server/metrics"
+	"textual/internal/server/previews"
+	"textual/internal/server/web"
+	"textual/internal/wiretap"
+)
+
+// Start sets a chat server up on db with the settings of cfg: the jobs, the
+// optional features and the side servers. The chat server is left to serve,
+// stop ends the rest once it stopped serving. The connections are closed
+// when ctx is done
+func Start(ctx context.Context, db database.Store, cfg config.Config) (server *chat.Server, stop func(), err error) {
+    // undone in reverse order, on error too
+    var stops []func()
+    undo := func() {
+        for i := len(stops) - 1; i >= 0; i-- {
+            stops[i]()
+        }
+    }
+    defer func() {
+        if err != nil {
+            undo()
+        }
+    }()
+
+    db.ConfigurePool(cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBConnMaxLifetime)
+    db.SetSlowQueryThreshold(cfg.DBSlowQuery)
+    db.SetQuotas(database.Quotas{
+        MembersPerGroup: cfg.MaxGroupMembers,
+        GroupsPerUser:   cfg.MaxGroupsPerUser,
+        FriendsPerUser:  cfg.MaxFriendsPerUser,
+    })
+
+    if cfg.PresenceFlushInterval > 0 {
+        stops = append(stops, db.StartPresenceWriter(cfg.PresenceFlushInterval))
+    }
+
+    if cfg.ArchiveDir != "" {
+        archiver := archive.NewArchiver(db, cfg.ArchiveDir, cfg.ArchiveAfter, cfg.ArchiveInterval, cfg.ArchiveBatchSize)
+        if err := archiver.Start(); err != nil {
+            return nil, nil, fmt.Errorf("archiver error: %v", err)
+        }
+        stops = append(stops, archiver.Stop)
+    }
+
+    server = chat.NewServer(db)
+    server.Messages().SetContext(ctx)
+    server.SetBroadcastShards(cfg.BroadcastShards)
+    server.Messages().SetFriendRequestLimits(cfg.FriendRequestsPerHour, cfg.FriendRequestCooldown)
+    server.Messages().SetGlobalMessageLimit(cfg.GlobalMessagesPerMinute)
+    server.Messages().SetAdmins(cfg.Admins)
+
+    if cfg.AttachmentDir != "" {
+        store, err := attachments.NewStore(cfg.AttachmentDir, cfg.AttachmentMaxSize)
+        if err != nil {
+            return nil, nil, fmt.Errorf("attachment store error: %v", err)
+        }
+        server.Messages().SetAttachments(store)
+    }
+
+    if cfg.LinkPreviews {
+        server.Messages().SetPreviews(previews.NewFetcher(cfg.LinkPreviewTimeout, cfg.LinkPreviewCacheTTL), cfg.LinkPreviewTimeout)
+    }
+
+    if cfg.GifAPIKey != "" {
+        searcher, err := gifs.NewSearcher(cfg.GifProvider, cfg.GifAPIKey, cfg.GifRating, cfg.GifTimeout)
+        if err != nil {
+            return nil, nil, fmt.Errorf("GIF search error: %v", err)
+        }
+        server.Messages().SetGifs(searcher, cfg.GifTimeout)
+    }
+
+    // the reminders and the integrations post as the system account
+    if err := db.CheckSystemUser(ctx); err != nil {
+        return nil, nil, fmt.Errorf("system account error: %v", err)
+    }
+    stops = append(stops, server.Messages().StartReminders(cfg.ReminderInterval))
+
+    if cfg.APIPort != "" {
+        apiServer := api.NewServer(db, server.Messages())
+        if err := apiServer.Start(cfg.APIPort); err != nil {
+            return nil, nil, fmt.Errorf("REST API error: %v", err)
+        }
+        stops = append(stops, apiServer.Stop)
+    }
+
+    if cfg.WebPort != "" {
+        webServer := web.NewServer(func(conn net.Conn) { server.HandleConnection(ctx, conn) })
+        if err := webServer.Start(cfg.WebPort); err != nil {
+            return nil, nil, fmt.Errorf("web client error: %v", err)
+        }
+        stops = append(stops, webServer.Stop)
+    }
+
+    if cfg.MetricsAddr != "" {
+        metrics.Publish("delivery", expvar.Func(server.DeliveryStats))
+        metricsServer := metrics.NewServer(db.Healthy)
+        if err := metricsServer.Start(cfg.MetricsAddr); err != nil {
+            return nil, nil, fmt.Errorf("metrics server error: %v", err)
+        }
+        stops = append(stops, metricsServer.Stop)
+    }
+
+    if cfg.WireTap != "" {
+        tap, err := wiretap.Open(cfg.WireTap, wiretap.Server)
+        if err != nil {
+            return nil, nil, fmt.Errorf("wire tap error: %v", err)
+        }
+        stops = append(stops, func() { tap.Close() })
+        server.SetWireTap(tap)
+        log.Printf("Recording the frames in %s", cfg.WireTap)
+    }
+
+    if cfg.Chaos != "" {
+        chaosConfig, err := chaos.Parse(cfg.Chaos)
+        if err != nil {
+            return nil, nil, fmt.Errorf("invalid CHAOS: %v", err)
+        }
+        network := chaos.New(chaosConfig)
+        server.SetChaos(network)
+        log.Printf("Connections go through a bad network: %s", network.Config())
+    }
+
+    if cfg.DebugPort != "" {
+        debugServer := metrics.NewDebugServer()
+        if err := debugServer.Start("127.0.0.1:" + cfg.DebugPort); err != nil {
+            return nil, nil, fmt.Errorf("debug server error: %v", err)
+        }
+        stops = append(stops, debugServer.Stop)
+    }
+
+    stops = append(stops, db.MonitorHealth(cfg.DBHealthInterval, server.AnnounceDatabaseState))
+    return server, undo, nil
+}
@@ -0,0 +1,141 @@
+// internal/server/chat/bench_test.go
+package chat
+
+import (
+	"context"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"textual/internal/server/handlers"
+	"textual/pkg/protocol"
+)
+
+// the logs are formatted as in production but not written
+func quiet(b *testing.B) {
+    out := log.Writer()
+    log.SetOutput(io.Discard)
+    b.Cleanup(func() { log.SetOutput(out) })
+}
+
+func benchPayload() protocol.Message {
+    return protocol.NewMessage(protocol.TypeGlobalMessage, map[string]interface{}{
+        "id":          "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+        "content":     "did anyone read the release notes? the migration looks painful",
+        "sender_id":   "9b2c3d4e-1f2a-4b5c-8d9e-0a1b2c3d4e5f",
+        "sender_name": "alice",
+        "sent_at":     int64(1704110400),
+        "client_id":   "1704110400000-42",
+    })
+}
+
+// BenchmarkBroadcast measures a message of the global chat reaching every
+// connected client, from the broadcast channel to their Send channels. The
+// next message waits for the last copy, a burst would fill the channels and
+// disconnect the clients
+func BenchmarkBroadcast(b *testing.B) {
+    quiet(b)
+    for _, clients := range []int{10, 100, 1000} {
+        b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+            s := NewServer(nil)
+            var delivered sync.WaitGroup
+            done := make(chan struct{})
+            defer close(done)
+            for i := 0; i < clients; i++ {
+                client := handlers.NewClient(nil, fmt.Sprintf("user-%d", i), fmt.Sprintf("user%d", i))
+                s.register(client)
+                go func() {
+                    for {
+                        select {
+                        case <-client.Send:
+                            delivered.Done()
+                        case <-done:
+                            return
+                        }
+                    }
+                }()
+            }
+            go s.handleBroadcast()
+            defer close(s.broadcast)
+
+            msg := benchPayload()
+            b.ReportAllocs()
+            b.ResetTimer()
+            for i := 0; i < b.N; i++ {
+                delivered.Add(clients)
+                s.broadcast <- msg
+                delivered.Wait()
+            }
+        })
+    }
+}
+
+// discardConn is a connection whose writes succeed without going anywhere,
+// wrote gets the number of messages of each one
+type discardConn struct {
+    net.Conn
+    wrote chan int
+}
+
+func (c *discardConn) Write(p []byte) (int, error) {
+    c.wrote <- bytes.Count(p, []byte("\n"))
+    return len(p), nil
+}
+
+func (c *discardConn) SetWriteDeadline(time.Time) error { return nil }
+
+// stopPump closes the Send channel and waits for the pump to return, it
+// doesn't log after the benchmark
+func stopPump(client *handlers.Client, errChan <-chan error) {
+    close(client.Send)
+    <-errChan
+}
+
+// BenchmarkWritePump measures the writes of a client, from its Send channel
+// to the connection
+func BenchmarkWritePump(b *testing.B) {
+    quiet(b)
+    s := NewServer(nil)
+    conn := &discardConn{wrote: make(chan int, 1)}
+    client := handlers.NewClient(conn, "user", "user")
+    errChan := make(chan error, 2)
+    go s.writePump(context.Background(), client, errChan)
+    defer stopPump(client, errChan)
+
+    msg := benchPayload()
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        client.Send <- msg
+        <-conn.wrote
+    }
+}
+
+// BenchmarkWritePumpBacklog measures the writes of a client receiving
+// faster than it is written to, the messages queued go out in batches
+func BenchmarkWritePumpBacklog(b *testing.B) {
+    quiet(b)
+    s := NewServer(nil)
+    conn := &discardConn{wrote: make(chan int, 1)}
+    client := handlers.NewClient(conn, "user", "user")
+    errChan := make(chan error, 2)
+    go s.writePump(context.Background(), client, errChan)
+    defer stopPump(client, errChan)
+
+    msg := benchPayload()
+    b.ReportAllocs()
+    b.ResetTimer()
+    go func() {
+        for i := 0; i < b.N; i++ {
+            client.Send <- msg
+        }
+    }()
+    for written := 0; written < b.N; {
+        written += <-conn.wrote
+    }
+}
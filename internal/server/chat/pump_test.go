@@ -0,0 +1,130 @@
+// internal/server/chat/pump_test.go
+package chat
+
+import (
+	"context"
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"textual/internal/server/handlers"
+	"textual/pkg/protocol"
+)
+
+// recordConn keeps each write
+type recordConn struct {
+    net.Conn
+    writes chan []byte
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+    c.writes <- append([]byte(nil), p...)
+    return len(p), nil
+}
+
+func (c *recordConn) SetWriteDeadline(time.Time) error { return nil }
+
+// nextWrite returns the types of the messages of the next write
+func nextWrite(t *testing.T, conn *recordConn) []protocol.MessageType {
+    t.Helper()
+    var data []byte
+    select {
+    case data = <-conn.writes:
+    case <-time.After(5 * time.Second):
+        t.Fatal("nothing written")
+    }
+    if !bytes.HasSuffix(data, []byte("\n")) {
+        t.Fatalf("write %q does not end a line", data)
+    }
+    var types []protocol.MessageType
+    for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+        var msg protocol.Message
+        if err := json.Unmarshal([]byte(line), &msg); err != nil {
+            t.Fatalf("line %q: %v", line, err)
+        }
+        types = append(types, msg.Type)
+    }
+    return types
+}
+
+func startPump(t *testing.T, queued ...protocol.Message) (*handlers.Client, *recordConn, chan error) {
+    t.Helper()
+    conn := &recordConn{writes: make(chan []byte, 10)}
+    client := handlers.NewClient(conn, "user", "user")
+    for _, msg := range queued {
+        client.Send <- msg
+    }
+    errChan := make(chan error, 2)
+    go NewServer(nil).writePump(context.Background(), client, errChan)
+    return client, conn, errChan
+}
+
+func TestWritePumpBatchesBacklog(t *testing.T) {
+    client, conn, errChan := startPump(t,
+        protocol.NewMessage(protocol.TypeGlobalMessage, nil),
+        protocol.NewMessage(protocol.TypeTyping, nil),
+        protocol.NewMessage(protocol.TypeStatusUpdate, nil),
+    )
+
+    got := nextWrite(t, conn)
+    want := []protocol.MessageType{protocol.TypeGlobalMessage, protocol.TypeTyping, protocol.TypeStatusUpdate}
+    if strings.Join(typeNames(got), ",") != strings.Join(typeNames(want), ",") {
+        t.Fatalf("first write %v, want %v in order", got, want)
+    }
+
+    client.Send <- protocol.NewMessage(protocol.TypeDirectMessage, nil)
+    if got := nextWrite(t, conn); len(got) != 1 || got[0] != protocol.TypeDirectMessage {
+        t.Fatalf("second write %v, want the direct message alone", got)
+    }
+
+    close(client.Send)
+    select {
+    case err := <-errChan:
+        if err == nil {
+            t.Error("closing the channel ended the pump without error")
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("the pump did not stop")
+    }
+}
+
+func TestWritePumpBatchLimit(t *testing.T) {
+    queued := make([]protocol.Message, maxBatch+5)
+    for i := range queued {
+        queued[i] = protocol.NewMessage(protocol.TypeGlobalMessage, nil)
+    }
+    _, conn, _ := startPump(t, queued...)
+
+    if got := nextWrite(t, conn); len(got) != maxBatch {
+        t.Errorf("first write of %d messages, want %d", len(got), maxBatch)
+    }
+    if got := nextWrite(t, conn); len(got) != 5 {
+        t.Errorf("second write of %d messages, want 5", len(got))
+    }
+}
+
+func TestWritePumpFlushesBeforeClosing(t *testing.T) {
+    client, conn, errChan := startPump(t,
+        protocol.NewMessage(protocol.TypeGlobalMessage, nil),
+        protocol.NewMessage(protocol.TypeGlobalMessage, nil),
+    )
+    close(client.Send)
+
+    if got := nextWrite(t, conn); len(got) != 2 {
+        t.Errorf("last write of %d messages, want 2", len(got))
+    }
+    if err := <-errChan; err == nil {
+        t.Error("closing the channel ended the pump without error")
+    }
+}
+
+func typeNames(types []protocol.MessageType) []string {
+    names := make([]string, len(types))
+    for i, t := range types {
+        names[i] = string(t)
+    }
+    return names
+}
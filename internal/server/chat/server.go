@@ -0,0 +1,408 @@
+// internal/server/chat/server.go
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"textual/internal/chaos"
+	"textual/internal/clock"
+	"textual/internal/server/database"
+	"textual/internal/server/handlers"
+	"textual/internal/server/metrics"
+	"textual/internal/server/models"
+	"textual/internal/wiretap"
+	"textual/pkg/protocol"
+)
+
+// the server pings each client this often, a dead connection fails the write
+const pingInterval = 30 * time.Second
+
+// deadline of the handling of a message
+const requestTimeout = 30 * time.Second
+
+// a client with a backlog gets the messages queued in one write, up to these
+// limits. The messages stay one JSON document per line, the readers of the
+// stream see no difference
+const (
+    maxBatch      = 64
+    maxBatchBytes = 64 << 10
+)
+
+// Server is the chat server: it authenticates the connections, reads the
+// messages of the clients and writes them theirs
+type Server struct {
+    db           database.Store
+    sessions     *handlers.Sessions
+    broadcast    chan protocol.Message
+    shards       []*shard
+    authHandler  *handlers.AuthHandler
+    msgHandler   *handlers.MessageHandler
+    clock        clock.Clock
+    wireTap      *wiretap.Tap // nil unless the frames are recorded
+    chaos        *chaos.Network // nil unless the network is made bad on purpose
+}
+
+func NewServer(db database.Store) *Server {
+    broadcast := make(chan protocol.Message, 100) // load 100 messages into the buffer
+    sessions := handlers.NewSessions()
+    
+    server := &Server{
+        db:        db,
+        sessions:  sessions,
+        broadcast: broadcast,
+        shards:    newShards(runtime.NumCPU()),
+        clock:     clock.Real,
+    }
+
+    server.authHandler = handlers.NewAuthHandler(db, broadcast)
+    server.msgHandler = handlers.NewMessageHandler(db, broadcast, sessions)
+
+    return server
+}
+
+// Messages returns the handler of the messages, to set up its optional
+// features
+func (s *Server) Messages() *handlers.MessageHandler {
+    return s.msgHandler
+}
+
+// SetClock replaces the clock of the pings and of the jobs of the handlers,
+// before Serve
+func (s *Server) SetClock(c clock.Clock) {
+    s.clock = c
+    s.msgHandler.SetClock(c)
+}
+
+// SetWireTap records the frames of every connection in the capture of tap,
+// before Serve
+func (s *Server) SetWireTap(tap *wiretap.Tap) {
+    s.wireTap = tap
+}
+
+// SetChaos sends every connection through a bad network, to test the
+// clients against it, before Serve
+func (s *Server) SetChaos(network *chaos.Network) {
+    s.chaos = network
+}
+
+func (s *Server) Start(ctx context.Context, port string) error {
+    listener, err := net.Listen("tcp", ":"+port)
+    if err != nil {
+        return err
+    }
+    log.Printf("Server started on port %s", port)
+    return s.Serve(ctx, listener)
+}
+
+// Serve accepts the connections of the listener until ctx is done or the
+// listener is closed, then closes the connections and returns once their
+// clients are logged out
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    stop := context.AfterFunc(ctx, func() { listener.Close() })
+    defer stop()
+
+    // start broadcast routine
+    go s.handleBroadcast()
+
+    var conns sync.WaitGroup
+    for {
+        conn, err := listener.Accept()
+        if errors.Is(err, net.ErrClosed) {
+            break
+        }
+        if err != nil {
+            log.Printf("Error accepting connection: %v", err)
+            continue
+        }
+
+        conns.Add(1)
+        go func() {
+            defer conns.Done()
+            s.HandleConnection(ctx, conn)
+        }()
+    }
+
+    cancel()
+    conns.Wait()
+    return nil
+}
+
+// HandleConnection serves a client from its login to its disconnection, the
+// web client hands its WebSocket connections to it. The connection is closed
+// when ctx is done
+func (s *Server) HandleConnection(ctx context.Context, conn net.Conn) {
+    // the tap records the frames as the server sees them
+    if s.chaos != nil {
+        conn = s.chaos.Wrap(conn)
+    }
+    if s.wireTap != nil {
+        conn = s.wireTap.Wrap(conn)
+    }
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    // closing the connection ends the reads in progress, the login's too
+    stop := context.AfterFunc(ctx, func() { conn.Close() })
+    defer stop()
+
+    defer func() {
+        conn.Close()
+        log.Printf("Connection closed")
+    }()
+
+    // authenticate user, the client is registered next to the other
+    // sessions of the user before the login is answered: the messages sent
+    // meanwhile wait in its channel for the write pump
+    var client *handlers.Client
+    user, err := s.authHandler.HandleAuth(ctx, conn, func(user *models.User) {
+        client = handlers.NewClient(conn, user.ID, user.Username)
+        s.register(client)
+    })
+    if err != nil {
+        if client != nil {
+            s.unregister(client)
+            client.Close()
+        }
+        log.Printf("Authentication error: %v", err)
+        return
+    }
+
+    log.Printf("User %s authenticated successfully", user.Username)
+    log.Printf("Client registered: %s (session %s)", user.Username, client.SessionID)
+    connectedCtx, cancelConnected := context.WithTimeout(ctx, requestTimeout)
+    s.msgHandler.HandleConnected(connectedCtx, client)
+    cancelConnected()
+
+    // disconnect client on exit, the user is logged out with its last session
+    defer func() {
+        log.Printf("Cleaning up client: %s (session %s)", user.Username, client.SessionID)
+        // out of its shard before its channel is closed
+        last := s.unregister(client)
+        client.Close()
+        if last {
+            // the status is written when the server stops too
+            logoutCtx, cancelLogout := context.WithTimeout(context.WithoutCancel(ctx), requestTimeout)
+            s.authHandler.HandleLogout(logoutCtx, user.ID)
+            cancelLogout()
+        }
+    }()
+
+    // start clent routines, each sends its error then nil: none blocks once
+    // the first one is read
+    errChan := make(chan error, 4)
+    go s.readPump(ctx, client, errChan)
+    go s.writePump(ctx, client, errChan)
+
+    // wait for errors
+    err = <-errChan
+    if err != nil && err != io.EOF {
+        log.Printf("Client error: %v", err)
+    }
+}
+
+func (s *Server) readPump(ctx context.Context, client *handlers.Client, errChan chan<- error) {
+    defer func() {
+        errChan <- nil
+    }()
+
+    // a single decoder, it may read ahead of the message it returns
+    decoder := json.NewDecoder(client.Conn)
+    for {
+        var msg protocol.Message
+        if err := decoder.Decode(&msg); err != nil {
+            if err != io.EOF {
+                errChan <- fmt.Errorf("read error: %v", err)
+            }
+            return
+        }
+
+        log.Printf("Received message from %s: %v", client.Username, msg.Type)
+
+        // handle the type of message
+        reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+        err := s.msgHandler.HandleMessage(reqCtx, client, msg)
+        cancel()
+        if err != nil {
+            log.Printf("Error handling message: %v", err)
+            errorMsg := protocol.NewErrorMessage(protocol.ErrCodeInternalError, err.Error())
+            if protoErr, ok := err.(protocol.Error); ok {
+                errorMsg = protoErr.Reply()
+            }
+            select {
+            case client.Send <- errorMsg:
+            default:
+                log.Printf("Client send channel full")
+                errChan <- fmt.Errorf("client send channel full")
+                return
+            }
+        }
+    }
+}
+
+// writePump writes the messages of the client until ctx is done or a write
+// fails
+func (s *Server) writePump(ctx context.Context, client *handlers.Client, errChan chan<- error) {
+    ticker := s.clock.NewTicker(pingInterval)
+    defer func() {
+        ticker.Stop()
+        errChan <- nil
+    }()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+
+        case msg, ok := <-client.Send:
+            if !ok {
+                errChan <- fmt.Errorf("client channel closed")
+                return
+            }
+
+            degraded := client.Delivery.Degraded()
+            count, closed, err := writeBatch(client, msg, degraded)
+            if err != nil {
+                errChan <- fmt.Errorf("write error: %v", err)
+                return
+            }
+            switch {
+            case count == 1:
+                log.Printf("Sent message to %s: %v", client.Username, msg.Type)
+            case count > 1:
+                log.Printf("Sent %d messages to %s in one write", count, client.Username)
+            }
+            if closed {
+                errChan <- fmt.Errorf("session closed")
+                return
+            }
+            if err := s.checkLag(client, degraded); err != nil {
+                errChan <- err
+                return
+            }
+
+        case <-ticker.C():
+            if err := writeMessage(client, protocol.NewMessage(protocol.TypePing, nil)); err != nil {
+                errChan <- fmt.Errorf("ping error: %v", err)
+                return
+            }
+        }
+    }
+}
+
+// writeBatch writes msg with the messages already queued behind it in one
+// write, and returns how many were written. closed reports the send channel
+// closed while reading the backlog, or a disconnect notice: the messages
+// before it are written, the session ends after the write. A degraded client is spared the presence and typing events, and
+// the same history page queued twice in a row is written once
+func writeBatch(client *handlers.Client, msg protocol.Message, degraded bool) (count int, closed bool, err error) {
+    // pooled per batch, an idle client holds no buffer
+    batch := protocol.GetBuffer()
+    defer batch.Release()
+
+    // a history page held until the next message tells if it is repeated
+    var held *protocol.Message
+    var heldKey string
+    flush := func() error {
+        if held == nil {
+            return nil
+        }
+        count++
+        err := batch.Encode(*held)
+        held = nil
+        return err
+    }
+    add := func(m protocol.Message) error {
+        if !degraded {
+            count++
+            return batch.Encode(m)
+        }
+        if skippable(m.Type) {
+            metrics.DeliverySkipped.Add(1)
+            return nil
+        }
+        if key, ok := historyKey(m); ok {
+            if held != nil && key == heldKey {
+                metrics.DeliveryCoalesced.Add(1)
+            } else if err := flush(); err != nil {
+                return err
+            }
+            held, heldKey = &m, key
+            return nil
+        }
+        if err := flush(); err != nil {
+            return err
+        }
+        count++
+        return batch.Encode(m)
+    }
+
+    if err := add(msg); err != nil {
+        return 0, false, err
+    }
+    closed = msg.Type == protocol.TypeDisconnectNotice
+backlog:
+    for read := 1; !closed && read < maxBatch && batch.Len() < maxBatchBytes; read++ {
+        select {
+        case next, ok := <-client.Send:
+            if !ok {
+                closed = true
+                break backlog
+            }
+            if err := add(next); err != nil {
+                return count, closed, err
+            }
+            closed = next.Type == protocol.TypeDisconnectNotice
+        default:
+            break backlog
+        }
+    }
+    if err := flush(); err != nil {
+        return count, closed, err
+    }
+    if count == 0 {
+        return 0, closed, nil
+    }
+
+    client.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+    _, err = client.Conn.Write(batch.Bytes())
+    return count, closed, err
+}
+
+func writeMessage(client *handlers.Client, msg protocol.Message) error {
+    buf := protocol.GetBuffer()
+    defer buf.Release()
+
+    if err := buf.Encode(msg); err != nil {
+        return err
+    }
+    client.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+    _, err := client.Conn.Write(buf.Bytes())
+    return err
+}
+
+// AnnounceDatabaseState tells connected clients that the server is running
+// in degraded mode while the database is unreachable
+func (s *Server) AnnounceDatabaseState(healthy bool) {
+    notice := protocol.NotificationPayload{
+        Type:    protocol.NoticeDegraded,
+        Message: "The server database is unavailable, messages cannot be saved or loaded for now",
+    }
+    if healthy {
+        notice = protocol.NotificationPayload{
+            Type:    protocol.NoticeRestored,
+            Message: "The server database is available again",
+        }
+    }
+
+    s.broadcast <- protocol.NewMessage(protocol.TypeNotification, notice)
+}
@@ -0,0 +1,886 @@
+// internal/server/chat/server_test.go
+package chat_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"textual/internal/chaos"
+	"textual/internal/server/chat"
+	"textual/internal/server/database"
+	"textual/internal/testutil"
+	"textual/pkg/protocol"
+)
+
+func TestAuth(t *testing.T) {
+    srv := testutil.StartServer(t)
+
+    alice := srv.Connect(t, "alice")
+    if alice.ID == "" || alice.Username != "alice" {
+        t.Fatalf("auth response: id %q, username %q", alice.ID, alice.Username)
+    }
+    alice.Close()
+
+    // the account was created by the first login
+    again := srv.Connect(t, "alice")
+    if again.ID != alice.ID {
+        t.Errorf("second login id = %q, want %q", again.ID, alice.ID)
+    }
+
+    wrong := testutil.Dial(t, srv.Addr)
+    wrong.Send(protocol.TypeAuth, protocol.AuthPayload{Username: "alice", Password: "wrong"})
+    if e := wrong.ExpectError(); e.Code != protocol.ErrCodeInvalidAuth {
+        t.Errorf("wrong password: error code %d, want %d", e.Code, protocol.ErrCodeInvalidAuth)
+    }
+
+    system := testutil.Dial(t, srv.Addr)
+    system.Send(protocol.TypeAuth, protocol.AuthPayload{Username: "textual", Password: testutil.Password})
+    if e := system.ExpectError(); e.Code != protocol.ErrCodeInvalidAuth {
+        t.Errorf("system account: error code %d, want %d", e.Code, protocol.ErrCodeInvalidAuth)
+    }
+}
+
+func TestStatusBroadcast(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    alice.ExpectFunc(func(m testutil.Message) bool {
+        var status protocol.StatusUpdatePayload
+        return m.Type == protocol.TypeStatusUpdate && m.Decode(&status) == nil &&
+            status.UserID == bob.ID && status.Status == protocol.StatusOnline
+    })
+}
+
+func TestDirectMessage(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+    carol := srv.Connect(t, "carol")
+
+    alice.Send(protocol.TypeDirectMessage, map[string]string{
+        "content":      "hello bob",
+        "recipient_id": bob.ID,
+        "client_id":    "dm-1",
+    })
+
+    var received protocol.MessagePayload
+    bob.Expect(protocol.TypeDirectMessage, &received)
+    if received.Content != "hello bob" || received.SenderID != alice.ID {
+        t.Errorf("bob received %+v", received)
+    }
+    var echo protocol.MessagePayload
+    alice.Expect(protocol.TypeDirectMessage, &echo)
+    if echo.ID != received.ID || echo.ClientID != "dm-1" {
+        t.Errorf("alice got back %+v, bob got %+v", echo, received)
+    }
+    carol.ExpectNone(protocol.TypeDirectMessage, 200*time.Millisecond)
+
+    // the same client ID is not stored twice
+    alice.Send(protocol.TypeDirectMessage, map[string]string{
+        "content":      "hello bob",
+        "recipient_id": bob.ID,
+        "client_id":    "dm-1",
+    })
+    var duplicate protocol.MessagePayload
+    alice.Expect(protocol.TypeDirectMessage, &duplicate)
+    if duplicate.ID != received.ID {
+        t.Errorf("resent message stored as %q, want %q", duplicate.ID, received.ID)
+    }
+    bob.ExpectNone(protocol.TypeDirectMessage, 200*time.Millisecond)
+}
+
+func TestMessageStatus(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+    bobLaptop := srv.Connect(t, "bob")
+
+    alice.Send(protocol.TypeDirectMessage, map[string]string{
+        "content":      "hello bob",
+        "recipient_id": bob.ID,
+    })
+    // on both devices of bob
+    var received protocol.MessagePayload
+    bob.Expect(protocol.TypeDirectMessage, &received)
+    bobLaptop.Expect(protocol.TypeDirectMessage, nil)
+    var echo struct {
+        ID     string `json:"id"`
+        Status string `json:"status"`
+    }
+    alice.Expect(protocol.TypeDirectMessage, &echo)
+    if echo.ID != received.ID || echo.Status != protocol.MessageDelivered {
+        t.Errorf("alice got back %+v, want %s delivered", echo, received.ID)
+    }
+
+    // read on the phone: the laptop clears it, alice sees it read
+    bob.Send(protocol.TypeReadMarker, protocol.ReadMarkerPayload{ChatID: alice.ID, MessageID: received.ID})
+    var marker protocol.ReadMarkerPayload
+    bobLaptop.Expect(protocol.TypeReadMarker, &marker)
+    if marker.ChatID != alice.ID || marker.MessageID != received.ID {
+        t.Errorf("laptop got marker %+v", marker)
+    }
+    var status protocol.MessageStatusPayload
+    alice.Expect(protocol.TypeMessageStatus, &status)
+    if status.ChatID != bob.ID || status.MessageID != received.ID || status.Status != protocol.MessageRead {
+        t.Errorf("alice got status %+v", status)
+    }
+    bob.ExpectNone(protocol.TypeReadMarker, 200*time.Millisecond)
+
+    // sent while bob is offline, delivered when he logs in
+    bob.Close()
+    bobLaptop.Close()
+    alice.ExpectFunc(func(m testutil.Message) bool {
+        var update protocol.StatusUpdatePayload
+        return m.Type == protocol.TypeStatusUpdate && m.Decode(&update) == nil &&
+            update.UserID == bob.ID && update.Status == protocol.StatusOffline
+    })
+    alice.Send(protocol.TypeDirectMessage, map[string]string{
+        "content":      "are you there?",
+        "recipient_id": bob.ID,
+    })
+    alice.Expect(protocol.TypeDirectMessage, &echo)
+    if echo.Status != protocol.MessageSent {
+        t.Errorf("message to offline bob is %s", echo.Status)
+    }
+    srv.Connect(t, "bob")
+    alice.Expect(protocol.TypeMessageStatus, &status)
+    if status.MessageID != echo.ID || status.Status != protocol.MessageDelivered {
+        t.Errorf("alice got status %+v once bob logged in", status)
+    }
+}
+
+func TestDirectMessageToSystem(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+
+    alice.Send(protocol.TypeDirectMessage, map[string]string{
+        "content":      "hi",
+        "recipient_id": protocol.SystemUserID,
+    })
+    alice.ExpectError()
+}
+
+func TestGroupMessage(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+    carol := srv.Connect(t, "carol")
+
+    group, err := srv.DB.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    bob.ExpectFunc(func(m testutil.Message) bool {
+        var join protocol.GroupJoinPayload
+        return m.Type == protocol.TypeGroupJoin && m.Decode(&join) == nil && join.UserID == bob.ID
+    })
+
+    bob.Send(protocol.TypeGroupMessage, map[string]string{"content": "hi all", "group_id": group.ID})
+    for _, member := range []*testutil.Client{alice, bob} {
+        var msg protocol.MessagePayload
+        member.Expect(protocol.TypeGroupMessage, &msg)
+        if msg.Content != "hi all" || msg.GroupID != group.ID {
+            t.Errorf("%s received %+v", member.Username, msg)
+        }
+    }
+    carol.ExpectNone(protocol.TypeGroupMessage, 200*time.Millisecond)
+
+    // not a member
+    carol.Send(protocol.TypeGroupMessage, map[string]string{"content": "let me in", "group_id": group.ID})
+    if e := carol.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("non member: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+}
+
+func TestGroupCreate(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+
+    alice.Send(protocol.TypeGroupCreate, protocol.GroupCreatePayload{Name: " book club ", Public: true})
+    var created protocol.GroupPayload
+    alice.Expect(protocol.TypeGroupCreate, &created)
+    if created.Name != "book club" || created.CreatedBy != alice.ID || !created.Public {
+        t.Errorf("created %+v", created)
+    }
+
+    alice.Send(protocol.TypeGroupList, nil)
+    var list protocol.GroupListPayload
+    alice.Expect(protocol.TypeGroupList, &list)
+    if len(list.Groups) != 1 || list.Groups[0].ID != created.ID {
+        t.Errorf("group list %+v", list.Groups)
+    }
+
+    alice.Send(protocol.TypeGroupCreate, protocol.GroupCreatePayload{Name: "  "})
+    if e := alice.ExpectError(); e.Code != protocol.ErrCodeInvalidRequest {
+        t.Errorf("empty name: error code %d, want %d", e.Code, protocol.ErrCodeInvalidRequest)
+    }
+}
+
+func TestGroupRoleUpdate(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    group, err := srv.DB.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    bob.ExpectFunc(func(m testutil.Message) bool {
+        var join protocol.GroupJoinPayload
+        return m.Type == protocol.TypeGroupJoin && m.Decode(&join) == nil && join.UserID == bob.ID
+    })
+
+    // a member can't promote anybody
+    bob.Send(protocol.TypeGroupRoleUpdate, protocol.GroupRolePayload{GroupID: group.ID, UserID: bob.ID, Role: protocol.GroupRoleAdmin})
+    if e := bob.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("member promoting: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+
+    alice.Send(protocol.TypeGroupRoleUpdate, protocol.GroupRolePayload{GroupID: group.ID, UserID: bob.ID, Role: protocol.GroupRoleAdmin})
+    var notice protocol.NotificationPayload
+    bob.Expect(protocol.TypeNotification, &notice)
+    if notice.Type != protocol.NoticeGroupPromoted || notice.Actor != "alice" || notice.RelatedID != group.ID {
+        t.Errorf("bob notified %+v", notice)
+    }
+    for _, member := range []*testutil.Client{alice, bob} {
+        member.ExpectFunc(func(m testutil.Message) bool {
+            var members protocol.GroupMembersPayload
+            if m.Type != protocol.TypeGroupMembers || m.Decode(&members) != nil {
+                return false
+            }
+            for _, info := range members.Members {
+                if info.ID == bob.ID && info.Role == protocol.GroupRoleAdmin {
+                    return true
+                }
+            }
+            return false
+        })
+    }
+
+    // the creator stays admin, and an admin can't change their own role
+    bob.Send(protocol.TypeGroupRoleUpdate, protocol.GroupRolePayload{GroupID: group.ID, UserID: alice.ID, Role: protocol.GroupRoleMember})
+    bob.ExpectError()
+    bob.Send(protocol.TypeGroupRoleUpdate, protocol.GroupRolePayload{GroupID: group.ID, UserID: bob.ID, Role: protocol.GroupRoleMember})
+    bob.ExpectError()
+}
+
+func TestGroupSystemMessages(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    group, err := srv.DB.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    var event protocol.SystemMessagePayload
+    alice.Expect(protocol.TypeSystemMessage, &event)
+    if event.Kind != protocol.SystemJoined || event.Actor != "bob" || event.GroupID != group.ID {
+        t.Errorf("alice received %+v", event)
+    }
+
+    bob.Send(protocol.TypeGroupLeave, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    bob.ExpectFunc(func(m testutil.Message) bool {
+        var leave protocol.GroupJoinPayload
+        return m.Type == protocol.TypeGroupLeave && m.Decode(&leave) == nil && leave.GroupID == group.ID
+    })
+    alice.Expect(protocol.TypeSystemMessage, &event)
+    if event.Kind != protocol.SystemLeft || event.Actor != "bob" {
+        t.Errorf("alice received %+v", event)
+    }
+
+    // the creator can't leave
+    alice.Send(protocol.TypeGroupLeave, protocol.GroupJoinPayload{GroupID: group.ID, UserID: alice.ID})
+    if e := alice.ExpectError(); e.Code != protocol.ErrCodeInvalidRequest {
+        t.Errorf("creator leaving: error code %d, want %d", e.Code, protocol.ErrCodeInvalidRequest)
+    }
+}
+
+func TestNotificationLevels(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    group, err := srv.DB.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    bob.ExpectFunc(func(m testutil.Message) bool {
+        var join protocol.GroupJoinPayload
+        return m.Type == protocol.TypeGroupJoin && m.Decode(&join) == nil && join.UserID == bob.ID
+    })
+
+    var prefs protocol.NotificationPrefsPayload
+    alice.Send(protocol.TypeNotificationPrefs, protocol.NotificationPrefsPayload{Levels: map[string]string{group.ID: "loud"}})
+    alice.ExpectError()
+    alice.Send(protocol.TypeNotificationPrefs, protocol.NotificationPrefsPayload{Levels: map[string]string{group.ID: protocol.LevelMentions}})
+    alice.Expect(protocol.TypeNotificationPrefs, &prefs)
+    if prefs.Levels[group.ID] != protocol.LevelMentions {
+        t.Fatalf("levels %v, want mentions for the group", prefs.Levels)
+    }
+
+    // the mentions still notify
+    bob.Send(protocol.TypeGroupMessage, map[string]string{"content": "@alice page 12", "group_id": group.ID})
+    var notice protocol.NotificationPayload
+    alice.Expect(protocol.TypeNotification, &notice)
+    if notice.Type != protocol.NoticeMention || notice.RelatedID != group.ID {
+        t.Errorf("alice notified %+v", notice)
+    }
+
+    alice.Send(protocol.TypeNotificationPrefs, protocol.NotificationPrefsPayload{Levels: map[string]string{group.ID: protocol.LevelBadge}})
+    alice.Expect(protocol.TypeNotificationPrefs, &prefs)
+    bob.Send(protocol.TypeGroupMessage, map[string]string{"content": "@alice page 13", "group_id": group.ID})
+    alice.ExpectNone(protocol.TypeNotification, 200*time.Millisecond)
+}
+
+func TestUserSearch(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    srv.Connect(t, "bob")
+    srv.Connect(t, "bobby")
+
+    var found protocol.UserSearchPayload
+    names := func() []string {
+        var names []string
+        for _, user := range found.Users {
+            names = append(names, user.Username)
+        }
+        return names
+    }
+
+    // without a query the online users, never the searcher
+    alice.Send(protocol.TypeUserSearch, protocol.UserSearchPayload{})
+    alice.Expect(protocol.TypeUserSearch, &found)
+    if got := names(); len(got) != 2 || got[0] != "bob" || got[1] != "bobby" {
+        t.Errorf("online users %v, want [bob bobby]", got)
+    }
+
+    alice.Send(protocol.TypeUserSearch, protocol.UserSearchPayload{Query: "BO"})
+    alice.Expect(protocol.TypeUserSearch, &found)
+    if got := names(); len(got) != 2 || found.Query != "BO" {
+        t.Errorf("search BO: %v, want bob and bobby", got)
+    }
+
+    // "_" matches itself only, the empty list is left out of the payload
+    found = protocol.UserSearchPayload{}
+    alice.Send(protocol.TypeUserSearch, protocol.UserSearchPayload{Query: "b_b"})
+    alice.Expect(protocol.TypeUserSearch, &found)
+    if got := names(); len(got) != 0 {
+        t.Errorf("search b_b: %v, want nobody", got)
+    }
+}
+
+func TestAdmin(t *testing.T) {
+    srv := testutil.StartServer(t)
+    srv.Messages().SetAdmins([]string{"Alice"})
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    // the stats come with the login of an admin only
+    var stats protocol.AdminStatsPayload
+    alice.Expect(protocol.TypeAdminStats, &stats)
+    bob.ExpectNone(protocol.TypeAdminStats, 100*time.Millisecond)
+
+    bob.Send(protocol.TypeAdminStats, nil)
+    if e := bob.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("stats of a user: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+    bob.Send(protocol.TypeAdminKick, protocol.AdminKickPayload{Username: "alice"})
+    if e := bob.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("kick by a user: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+
+    bob.Send(protocol.TypeGlobalMessage, protocol.GlobalMessagePayload{Content: "hello"})
+    bob.Expect(protocol.TypeGlobalMessage, nil)
+    alice.Send(protocol.TypeAdminStats, nil)
+    alice.Expect(protocol.TypeAdminStats, &stats)
+    if stats.OnlineUsers != 2 || stats.Sessions != 2 || stats.MessagesPerMinute != 1 {
+        t.Errorf("stats %+v, want 2 users, 2 sessions and 1 message", stats)
+    }
+
+    alice.Send(protocol.TypeAdminAnnounce, protocol.AdminAnnouncePayload{Message: "restart at noon"})
+    var notice protocol.NotificationPayload
+    bob.Expect(protocol.TypeNotification, &notice)
+    if notice.Type != protocol.NoticeAnnouncement || notice.Message != "restart at noon" || notice.Actor != "alice" {
+        t.Errorf("announcement %+v", notice)
+    }
+
+    alice.Send(protocol.TypeAdminKick, protocol.AdminKickPayload{Username: "bob", Reason: "spam"})
+    var disconnect protocol.DisconnectNoticePayload
+    bob.Expect(protocol.TypeDisconnectNotice, &disconnect)
+    if disconnect.Reason != "spam" || disconnect.By != "alice" {
+        t.Errorf("disconnect notice %+v", disconnect)
+    }
+    bob.ExpectClosed()
+    alice.ExpectFunc(func(m testutil.Message) bool {
+        if m.Type != protocol.TypeAdminStats {
+            return false
+        }
+        m.Decode(&stats)
+        return len(stats.Events) == 2
+    })
+    if kick := stats.Events[1]; kick.Action != protocol.ModerationKick || kick.Actor != "alice" || kick.Target != "bob" || kick.Detail != "spam" {
+        t.Errorf("kick recorded as %+v", kick)
+    }
+
+    alice.Send(protocol.TypeAdminKick, protocol.AdminKickPayload{Username: "alice"})
+    if e := alice.ExpectError(); e.Code != protocol.ErrCodeInvalidRequest {
+        t.Errorf("self kick: error code %d, want %d", e.Code, protocol.ErrCodeInvalidRequest)
+    }
+}
+
+func TestMessageRevisions(t *testing.T) {
+    srv := testutil.StartServer(t)
+    srv.Messages().SetAdmins([]string{"carol"})
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+    carol := srv.Connect(t, "carol")
+
+    alice.Send(protocol.TypeGlobalMessage, protocol.GlobalMessagePayload{Content: "helo"})
+    var sent protocol.MessagePayload
+    alice.Expect(protocol.TypeGlobalMessage, &sent)
+    alice.Send(protocol.TypeMessageEdit, protocol.MessageEditPayload{MessageID: sent.ID, Content: "hello"})
+    alice.Expect(protocol.TypeMessageEdit, nil)
+
+    // the author and the server admins see the earlier versions
+    for _, c := range []*testutil.Client{alice, carol} {
+        c.Send(protocol.TypeMessageRevisions, protocol.MessageRevisionsPayload{MessageID: sent.ID})
+        var revisions protocol.MessageRevisionsPayload
+        c.Expect(protocol.TypeMessageRevisions, &revisions)
+        if len(revisions.Revisions) != 1 || revisions.Revisions[0].Content != "helo" {
+            t.Errorf("%s got the revisions %+v", c.Username, revisions)
+        }
+    }
+    bob.Send(protocol.TypeMessageRevisions, protocol.MessageRevisionsPayload{MessageID: sent.ID})
+    if e := bob.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("revisions for a user: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+}
+
+func TestFriendRequest(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    alice.Send(protocol.TypeFriendRequest, protocol.FriendRequestPayload{ToUser: "bob"})
+    var sent protocol.FriendRequestPayload
+    alice.Expect(protocol.TypeFriendRequest, &sent)
+    if sent.ToUser != "bob" || sent.Status != "sent" {
+        t.Errorf("confirmation %+v", sent)
+    }
+
+    var notice protocol.NotificationPayload
+    bob.Expect(protocol.TypeNotification, &notice)
+    if notice.Type != protocol.NoticeFriendRequest || notice.Actor != "alice" || notice.RelatedID != alice.ID {
+        t.Errorf("bob notified with %+v", notice)
+    }
+
+    var pending protocol.FriendRequestPayload
+    bob.Expect(protocol.TypeFriendRequest, &pending)
+    if pending.FromUser != "alice" || pending.Status != "pending" || pending.RequestID != sent.RequestID {
+        t.Errorf("bob received %+v", pending)
+    }
+
+    // the requests of a blocked user are dropped, without telling them
+    bob.Send(protocol.TypeFriendBlock, protocol.FriendRemovePayload{FriendID: alice.ID})
+    bob.Expect(protocol.TypeFriendBlock, nil)
+    alice.Send(protocol.TypeFriendRequest, protocol.FriendRequestPayload{ToUser: "bob"})
+    alice.Expect(protocol.TypeFriendRequest, &sent)
+    if sent.Status != "sent" {
+        t.Errorf("blocked: confirmation %+v", sent)
+    }
+    bob.ExpectNone(protocol.TypeNotification, 200*time.Millisecond)
+
+    // the one who blocked can't ask
+    bob.Send(protocol.TypeFriendRequest, protocol.FriendRequestPayload{ToUser: "alice"})
+    if e := bob.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("blocker: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+}
+
+func TestFriendResponse(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    alice.Send(protocol.TypeFriendRequest, protocol.FriendRequestPayload{ToUser: "bob"})
+    var request protocol.FriendRequestPayload
+    bob.Expect(protocol.TypeFriendRequest, &request)
+
+    // only the user asked can answer
+    alice.Send(protocol.TypeFriendResponse, protocol.FriendResponsePayload{RequestID: request.RequestID, Accept: true})
+    if e := alice.ExpectError(); e.Code != protocol.ErrCodeInvalidRequest {
+        t.Errorf("requester answering: error code %d, want %d", e.Code, protocol.ErrCodeInvalidRequest)
+    }
+
+    bob.Send(protocol.TypeFriendResponse, protocol.FriendResponsePayload{RequestID: request.RequestID, Accept: true})
+    var response protocol.FriendResponsePayload
+    alice.Expect(protocol.TypeFriendResponse, &response)
+    if !response.Accept || response.FromUser != "bob" || response.RequestID != request.RequestID {
+        t.Errorf("alice received %+v", response)
+    }
+    bob.Expect(protocol.TypeFriendResponse, &response)
+    if !response.Accept || response.FromUser != "alice" {
+        t.Errorf("bob received %+v", response)
+    }
+    var friends protocol.FriendListPayload
+    alice.Expect(protocol.TypeFriendList, &friends)
+    if len(friends.Friends) != 1 || friends.Friends[0].Username != "bob" {
+        t.Errorf("alice's friends %+v", friends.Friends)
+    }
+
+    // answered already
+    bob.Send(protocol.TypeFriendResponse, protocol.FriendResponsePayload{RequestID: request.RequestID, Accept: false})
+    bob.ExpectError()
+}
+
+func TestSync(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    group, err := srv.DB.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeDirectMessage, map[string]string{"content": "hello alice", "recipient_id": alice.ID})
+    alice.Expect(protocol.TypeDirectMessage, nil)
+    alice.Close()
+
+    // a new session gets the whole state in one message
+    again := srv.Connect(t, "alice")
+    var sync protocol.SyncPayload
+    again.Expect(protocol.TypeSync, &sync)
+    if sync.Profile.ID != alice.ID || sync.Profile.Username != "alice" {
+        t.Errorf("profile %+v", sync.Profile)
+    }
+    if len(sync.Groups) != 1 || sync.Groups[0].ID != group.ID {
+        t.Errorf("groups %+v", sync.Groups)
+    }
+    if sync.Unread[bob.ID] != 1 {
+        t.Errorf("unread %v, want 1 from bob", sync.Unread)
+    }
+    found := false
+    for _, conv := range sync.Conversations {
+        if conv.RecipientID != bob.ID {
+            continue
+        }
+        var messages []struct {
+            Content string `json:"content"`
+        }
+        if err := json.Unmarshal(conv.Messages, &messages); err != nil {
+            t.Fatal(err)
+        }
+        found = len(messages) == 1 && messages[0].Content == "hello alice"
+    }
+    if !found {
+        t.Errorf("no conversation with bob in %+v", sync.Conversations)
+    }
+}
+
+func TestMentionsList(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    bob.Send(protocol.TypeGlobalMessage, map[string]string{"content": "hi @alice"})
+    alice.Expect(protocol.TypeNotification, nil)
+
+    alice.Send(protocol.TypeMentionsList, nil)
+    var list protocol.MentionsListPayload
+    alice.Expect(protocol.TypeMentionsList, &list)
+    if len(list.Mentions) != 1 || list.Mentions[0].Sender != "bob" || list.Mentions[0].Content != "hi @alice" || list.Mentions[0].GroupID != "" {
+        t.Errorf("alice's mentions %+v", list.Mentions)
+    }
+
+    bob.Send(protocol.TypeMentionsList, nil)
+    bob.Expect(protocol.TypeMentionsList, &list)
+    if len(list.Mentions) != 0 {
+        t.Errorf("bob's mentions %+v", list.Mentions)
+    }
+}
+
+func TestQuotas(t *testing.T) {
+    db := testutil.NewDB(t)
+    db.SetQuotas(database.Quotas{MembersPerGroup: 2, FriendsPerUser: 1})
+    srv := testutil.StartServerWith(t, db)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+    carol := srv.Connect(t, "carol")
+
+    group, err := db.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    bob.Expect(protocol.TypeGroupJoin, nil)
+    carol.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: carol.ID})
+    if e := carol.ExpectError(); e.Code != protocol.ErrCodeQuotaExceeded {
+        t.Errorf("full group: error code %d, want %d", e.Code, protocol.ErrCodeQuotaExceeded)
+    }
+
+    alice.Send(protocol.TypeFriendRequest, protocol.FriendRequestPayload{ToUser: "bob"})
+    var request protocol.FriendRequestPayload
+    bob.Expect(protocol.TypeFriendRequest, &request)
+    bob.Send(protocol.TypeFriendResponse, protocol.FriendResponsePayload{RequestID: request.RequestID, Accept: true})
+    alice.Expect(protocol.TypeFriendResponse, nil)
+
+    alice.Send(protocol.TypeFriendRequest, protocol.FriendRequestPayload{ToUser: "carol"})
+    if e := alice.ExpectError(); e.Code != protocol.ErrCodeQuotaExceeded {
+        t.Errorf("second friend: error code %d, want %d", e.Code, protocol.ErrCodeQuotaExceeded)
+    }
+}
+
+func TestGroupTopic(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    group, err := srv.DB.CreateGroup(context.Background(), "book club", "", alice.ID, true, false, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    bob.Send(protocol.TypeGroupJoin, protocol.GroupJoinPayload{GroupID: group.ID, UserID: bob.ID})
+    bob.Expect(protocol.TypeGroupJoin, nil)
+
+    bob.Send(protocol.TypeGroupTopic, protocol.GroupTopicPayload{GroupID: group.ID, Topic: "mine"})
+    if e := bob.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("member setting the topic: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+
+    alice.Send(protocol.TypeGroupTopic, protocol.GroupTopicPayload{GroupID: group.ID, Topic: "  chapter\n 12 "})
+    for _, member := range []*testutil.Client{alice, bob} {
+        var topic protocol.GroupTopicPayload
+        member.Expect(protocol.TypeGroupTopic, &topic)
+        if topic.Topic != "chapter 12" || topic.SetBy != "alice" || topic.GroupID != group.ID {
+            t.Errorf("%s received %+v", member.Username, topic)
+        }
+        // the join of bob is announced too
+        var event protocol.SystemMessagePayload
+        member.ExpectFunc(func(m testutil.Message) bool {
+            return m.Type == protocol.TypeSystemMessage && m.Decode(&event) == nil && event.Kind != protocol.SystemJoined
+        })
+        if event.Kind != protocol.SystemTopic || event.Target != "chapter 12" || event.At != topic.SetAt {
+            t.Errorf("%s received %+v", member.Username, event)
+        }
+    }
+
+    bob.Send(protocol.TypeGroupTopicHistory, protocol.GroupTopicHistoryPayload{GroupID: group.ID})
+    var history protocol.GroupTopicHistoryPayload
+    bob.Expect(protocol.TypeGroupTopicHistory, &history)
+    if len(history.Changes) != 1 || history.Changes[0].Topic != "chapter 12" {
+        t.Errorf("topic history %+v", history.Changes)
+    }
+}
+
+func TestFetchContext(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+    carol := srv.Connect(t, "carol")
+
+    var ids []string
+    for _, content := range []string{"one", "two", "three", "four", "five"} {
+        alice.Send(protocol.TypeGlobalMessage, map[string]string{"content": content})
+        var sent protocol.MessagePayload
+        alice.Expect(protocol.TypeGlobalMessage, &sent)
+        ids = append(ids, sent.ID)
+    }
+
+    var context struct {
+        MessageID string `json:"message_id"`
+        Messages  []struct {
+            ID string `json:"id"`
+        } `json:"messages"`
+        Complete bool `json:"complete"`
+    }
+    got := func() []string {
+        var messages []string
+        for _, msg := range context.Messages {
+            messages = append(messages, msg.ID)
+        }
+        return messages
+    }
+
+    bob.Send(protocol.TypeFetchContext, protocol.FetchContextPayload{MessageID: ids[2], Before: 1})
+    bob.Expect(protocol.TypeFetchContext, &context)
+    want := []string{ids[4], ids[3], ids[2], ids[1]}
+    if context.MessageID != ids[2] || !context.Complete || fmt.Sprint(got()) != fmt.Sprint(want) {
+        t.Errorf("context of %s: %+v, want %v", ids[2], context, want)
+    }
+
+    // up to the oldest message bob has
+    bob.Send(protocol.TypeFetchContext, protocol.FetchContextPayload{MessageID: ids[2], Before: 1, UntilID: ids[4]})
+    bob.Expect(protocol.TypeFetchContext, &context)
+    want = []string{ids[3], ids[2], ids[1]}
+    if fmt.Sprint(got()) != fmt.Sprint(want) {
+        t.Errorf("context until %s: %v, want %v", ids[4], got(), want)
+    }
+
+    alice.Send(protocol.TypeDirectMessage, map[string]string{"content": "secret", "recipient_id": bob.ID})
+    var direct protocol.MessagePayload
+    bob.Expect(protocol.TypeDirectMessage, &direct)
+    bob.Send(protocol.TypeFetchContext, protocol.FetchContextPayload{MessageID: direct.ID})
+    bob.Expect(protocol.TypeFetchContext, &context)
+    if fmt.Sprint(got()) != fmt.Sprint([]string{direct.ID}) {
+        t.Errorf("direct context: %v", got())
+    }
+    carol.Send(protocol.TypeFetchContext, protocol.FetchContextPayload{MessageID: direct.ID})
+    if e := carol.ExpectError(); e.Code != protocol.ErrCodeAccessDenied {
+        t.Errorf("someone else's message: error code %d, want %d", e.Code, protocol.ErrCodeAccessDenied)
+    }
+}
+
+func TestNotes(t *testing.T) {
+    srv := testutil.StartServer(t)
+    phone := srv.Connect(t, "alice")
+    laptop := srv.Connect(t, "alice")
+
+    phone.Send(protocol.TypeDirectMessage, map[string]string{"content": "buy milk", "recipient_id": phone.ID})
+    for _, device := range []*testutil.Client{phone, laptop} {
+        var note struct {
+            Content string `json:"content"`
+            Status  string `json:"status"`
+        }
+        device.Expect(protocol.TypeDirectMessage, &note)
+        if note.Content != "buy milk" || note.Status != protocol.MessageRead {
+            t.Errorf("note received %+v", note)
+        }
+        // once, not as the sender and again as the recipient
+        device.ExpectNone(protocol.TypeDirectMessage, 200*time.Millisecond)
+    }
+
+    again := srv.Connect(t, "alice")
+    var sync protocol.SyncPayload
+    again.Expect(protocol.TypeSync, &sync)
+    if len(sync.Unread) != 0 {
+        t.Errorf("unread %v, the notes are read", sync.Unread)
+    }
+    found := false
+    for _, conv := range sync.Conversations {
+        found = found || conv.RecipientID == phone.ID
+    }
+    if !found {
+        t.Errorf("no notes in %+v", sync.Conversations)
+    }
+}
+
+func TestDisplayPrefs(t *testing.T) {
+    srv := testutil.StartServer(t)
+    phone := srv.Connect(t, "alice")
+    laptop := srv.Connect(t, "alice")
+
+    phone.Send(protocol.TypeDisplayPrefs, protocol.DisplayPrefsPayload{Timezone: "Mars/Olympus"})
+    if e := phone.ExpectError(); e.Code != protocol.ErrCodeInvalidRequest {
+        t.Errorf("unknown timezone: error code %d, want %d", e.Code, protocol.ErrCodeInvalidRequest)
+    }
+
+    want := protocol.DisplayPrefsPayload{Timezone: "Europe/Paris", TimeFormat: protocol.TimeFormat12h, Locale: "fr"}
+    phone.Send(protocol.TypeDisplayPrefs, want)
+    for _, device := range []*testutil.Client{phone, laptop} {
+        var prefs protocol.DisplayPrefsPayload
+        device.Expect(protocol.TypeDisplayPrefs, &prefs)
+        if prefs != want {
+            t.Errorf("device received %+v, want %+v", prefs, want)
+        }
+    }
+
+    again := srv.Connect(t, "alice")
+    var sync protocol.SyncPayload
+    again.Expect(protocol.TypeSync, &sync)
+    if sync.Prefs != want {
+        t.Errorf("synced prefs %+v, want %+v", sync.Prefs, want)
+    }
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+    srv := testutil.StartServer(t)
+    srv.Messages().SetGlobalMessageLimit(2)
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    for _, content := range []string{"one", "two"} {
+        alice.Send(protocol.TypeGlobalMessage, map[string]string{"content": content})
+        alice.Expect(protocol.TypeGlobalMessage, nil)
+    }
+    alice.Send(protocol.TypeGlobalMessage, map[string]string{"content": "three"})
+    e := alice.ExpectError()
+    if e.Code != protocol.ErrCodeRateLimited {
+        t.Errorf("third message: error code %d, want %d", e.Code, protocol.ErrCodeRateLimited)
+    }
+    if e.RetryAfter < 1 || e.RetryAfter > 60 {
+        t.Errorf("retry after %ds, want up to a minute", e.RetryAfter)
+    }
+
+    // the limit is per user
+    bob.Send(protocol.TypeGlobalMessage, map[string]string{"content": "hi"})
+    bob.ExpectFunc(func(m testutil.Message) bool {
+        var sent protocol.MessagePayload
+        return m.Type == protocol.TypeGlobalMessage && m.Decode(&sent) == nil && sent.Content == "hi"
+    })
+}
+
+func TestUnsupportedType(t *testing.T) {
+    srv := testutil.StartServer(t)
+    alice := srv.Connect(t, "alice")
+
+    alice.Send("hologram_call", map[string]string{"to": "bob"})
+    var notice protocol.UnsupportedPayload
+    alice.Expect(protocol.TypeUnsupported, &notice)
+    if notice.Type != "hologram_call" {
+        t.Errorf("unsupported type %q, want hologram_call", notice.Type)
+    }
+
+    // the notice of a client is not answered
+    alice.Send(protocol.TypeUnsupported, protocol.UnsupportedPayload{Type: "hologram_ring"})
+    alice.ExpectNone(protocol.TypeUnsupported, 200*time.Millisecond)
+    alice.ExpectNone(protocol.TypeError, 0)
+}
+
+func TestChaosNetwork(t *testing.T) {
+    // slow frames, half of them written in two parts: the order and the
+    // dedupe by client ID hold
+    srv := testutil.StartServerWith(t, testutil.NewDB(t), func(s *chat.Server) {
+        s.SetChaos(chaos.New(chaos.Config{Latency: 5 * time.Millisecond, Jitter: 5 * time.Millisecond, Partial: 0.5, Seed: 1}))
+    })
+    alice := srv.Connect(t, "alice")
+    bob := srv.Connect(t, "bob")
+
+    for i := 1; i <= 10; i++ {
+        alice.Send(protocol.TypeDirectMessage, map[string]string{
+            "content":      fmt.Sprintf("message %d", i),
+            "recipient_id": bob.ID,
+            "client_id":    fmt.Sprintf("chaos-%d", i),
+        })
+    }
+    ids := make(map[string]string)
+    for i := 1; i <= 10; i++ {
+        var received, echo protocol.MessagePayload
+        bob.Expect(protocol.TypeDirectMessage, &received)
+        if want := fmt.Sprintf("message %d", i); received.Content != want {
+            t.Fatalf("bob received %q, want %q", received.Content, want)
+        }
+        alice.Expect(protocol.TypeDirectMessage, &echo)
+        ids[echo.ClientID] = echo.ID
+    }
+
+    alice.Send(protocol.TypeDirectMessage, map[string]string{
+        "content":      "message 3",
+        "recipient_id": bob.ID,
+        "client_id":    "chaos-3",
+    })
+    var duplicate protocol.MessagePayload
+    alice.Expect(protocol.TypeDirectMessage, &duplicate)
+    if duplicate.ID != ids["chaos-3"] {
+        t.Errorf("resent message stored as %q, want %q", duplicate.ID, ids["chaos-3"])
+    }
+    bob.ExpectNone(protocol.TypeDirectMessage, 200*time.Millisecond)
+}
@@ -0,0 +1,116 @@
+// internal/server/chat/shards.go
+package chat
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+
+	"textual/internal/server/handlers"
+	"textual/internal/server/metrics"
+	"textual/pkg/protocol"
+)
+
+// shard delivers the broadcasts to a part of the clients, each client
+// belongs to a single one: its messages arrive in the order broadcast. The
+// sessions of a user share the shard of the user
+type shard struct {
+    mu       sync.Mutex
+    clients  map[string]*handlers.Client // session ID -> client
+    messages chan protocol.Message
+}
+
+func newShards(n int) []*shard {
+    if n < 1 {
+        n = 1
+    }
+    shards := make([]*shard, n)
+    for i := range shards {
+        shards[i] = &shard{
+            clients:  make(map[string]*handlers.Client),
+            messages: make(chan protocol.Message, 100),
+        }
+    }
+    return shards
+}
+
+// SetBroadcastShards sets the number of workers delivering the broadcasts,
+// before Serve and the first connection
+func (s *Server) SetBroadcastShards(n int) {
+    s.shards = newShards(n)
+}
+
+func (s *Server) shardOf(userID string) *shard {
+    h := fnv.New32a()
+    h.Write([]byte(userID))
+    return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// register adds a session to the sessions and to the shard of its user
+func (s *Server) register(client *handlers.Client) {
+    s.sessions.Add(client)
+    sh := s.shardOf(client.ID)
+    sh.mu.Lock()
+    sh.clients[client.SessionID] = client
+    sh.mu.Unlock()
+}
+
+// unregister removes a session from the sessions and from its shard, it
+// returns true when it was the last one of the user. The other sessions of
+// the user are left in place
+func (s *Server) unregister(client *handlers.Client) bool {
+    last := s.sessions.Remove(client)
+    sh := s.shardOf(client.ID)
+    sh.mu.Lock()
+    delete(sh.clients, client.SessionID)
+    sh.mu.Unlock()
+    return last
+}
+
+// handleBroadcast hands each message to every shard, a slow client or a
+// large global chat only holds up the clients of its shard
+func (s *Server) handleBroadcast() {
+    for _, sh := range s.shards {
+        go sh.run()
+    }
+    defer func() {
+        for _, sh := range s.shards {
+            close(sh.messages)
+        }
+    }()
+
+    for msg := range s.broadcast {
+        log.Printf("Broadcasting message type %v to %d clients", msg.Type, s.sessions.Len())
+        for _, sh := range s.shards {
+            sh.messages <- msg
+        }
+    }
+}
+
+func (sh *shard) run() {
+    for msg := range sh.messages {
+        sh.deliver(msg)
+    }
+}
+
+// deliver queues msg for the clients of the shard, but the presence events
+// for the degraded ones. A client whose queue is full is disconnected, its
+// connection handler unregisters it
+func (sh *shard) deliver(msg protocol.Message) {
+    sh.mu.Lock()
+    defer sh.mu.Unlock()
+    for id, client := range sh.clients {
+        if skippable(msg.Type) && client.Delivery.Degraded() {
+            metrics.DeliverySkipped.Add(1)
+            continue
+        }
+        select {
+        case client.Send <- msg:
+            log.Printf("Broadcast message sent to %s", client.Username)
+        default:
+            log.Printf("Failed to send broadcast to %s: channel full", client.Username)
+            client.Conn.Close()
+            delete(sh.clients, id)
+        }
+    }
+}
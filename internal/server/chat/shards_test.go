@@ -0,0 +1,120 @@
+// internal/server/chat/shards_test.go
+package chat
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"textual/internal/server/handlers"
+	"textual/pkg/protocol"
+)
+
+// closeConn records its closing
+type closeConn struct {
+    net.Conn
+    closed chan struct{}
+}
+
+func (c *closeConn) Close() error {
+    close(c.closed)
+    return nil
+}
+
+func startShards(t *testing.T, shards int, clients ...*handlers.Client) *Server {
+    t.Helper()
+    s := NewServer(nil)
+    s.SetBroadcastShards(shards)
+    for _, client := range clients {
+        s.register(client)
+    }
+    go s.handleBroadcast()
+    t.Cleanup(func() { close(s.broadcast) })
+    return s
+}
+
+func TestBroadcastShardsKeepOrder(t *testing.T) {
+    const count = 50
+    clients := make([]*handlers.Client, 20)
+    for i := range clients {
+        clients[i] = handlers.NewClient(nil, fmt.Sprintf("user-%d", i), fmt.Sprintf("user%d", i))
+    }
+    s := startShards(t, 4, clients...)
+
+    used := make(map[*shard]bool)
+    for _, client := range clients {
+        used[s.shardOf(client.ID)] = true
+    }
+    if len(used) < 2 {
+        t.Fatalf("clients spread on %d shard, want several", len(used))
+    }
+
+    for i := 0; i < count; i++ {
+        s.broadcast <- protocol.NewMessage(protocol.TypeGlobalMessage, i)
+    }
+    for _, client := range clients {
+        for i := 0; i < count; i++ {
+            select {
+            case msg := <-client.Send:
+                if n, _ := msg.Payload.(int); n != i {
+                    t.Fatalf("%s got message %v, want %d", client.Username, msg.Payload, i)
+                }
+            case <-time.After(5 * time.Second):
+                t.Fatalf("%s got %d messages, want %d", client.Username, i, count)
+            }
+        }
+    }
+}
+
+func TestBroadcastDisconnectsFullClient(t *testing.T) {
+    conn := &closeConn{closed: make(chan struct{})}
+    slow := handlers.NewClient(conn, "slow", "slow")
+    for len(slow.Send) < cap(slow.Send) {
+        slow.Send <- protocol.NewMessage(protocol.TypePing, nil)
+    }
+    other := handlers.NewClient(nil, "other", "other")
+    s := startShards(t, 1, slow, other)
+
+    s.broadcast <- protocol.NewMessage(protocol.TypeGlobalMessage, "hello")
+    select {
+    case <-conn.closed:
+    case <-time.After(5 * time.Second):
+        t.Fatal("full client not disconnected")
+    }
+    select {
+    case <-other.Send:
+    case <-time.After(5 * time.Second):
+        t.Fatal("broadcast not delivered to the other client")
+    }
+
+    sh := s.shardOf(slow.ID)
+    sh.mu.Lock()
+    _, kept := sh.clients[slow.SessionID]
+    sh.mu.Unlock()
+    if kept {
+        t.Fatal("full client still in its shard")
+    }
+}
+
+func TestUnregisterKeepsOtherSessions(t *testing.T) {
+    s := NewServer(nil)
+    old := handlers.NewClient(nil, "user", "user")
+    newer := handlers.NewClient(nil, "user", "user")
+    s.register(old)
+    s.register(newer)
+
+    sh := s.shardOf("user")
+    if sh.clients[old.SessionID] != old || sh.clients[newer.SessionID] != newer {
+        t.Fatal("sessions of the user missing from their shard")
+    }
+    if s.unregister(old) {
+        t.Fatal("first session reported as the last one")
+    }
+    if sh.clients[newer.SessionID] != newer {
+        t.Fatal("other session removed from its shard")
+    }
+    if !s.unregister(newer) {
+        t.Fatal("last session not reported")
+    }
+}
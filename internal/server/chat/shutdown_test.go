@@ -0,0 +1,65 @@
+// internal/server/chat/shutdown_test.go
+package chat
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"textual/internal/server/handlers"
+)
+
+func TestServeStopsWithContext(t *testing.T) {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    served := make(chan error, 1)
+    go func() { served <- NewServer(nil).Serve(ctx, listener) }()
+
+    // connected, waiting in the login
+    conn, err := net.Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    time.Sleep(50 * time.Millisecond)
+
+    cancel()
+    select {
+    case err := <-served:
+        if err != nil {
+            t.Fatalf("Serve returned %v", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("Serve did not return")
+    }
+
+    conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    if _, err := conn.Read(make([]byte, 1)); err == nil {
+        t.Fatal("connection still open after the shutdown")
+    }
+    if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+        t.Fatal("listener still accepting after the shutdown")
+    }
+}
+
+func TestWritePumpStopsWithContext(t *testing.T) {
+    client := handlers.NewClient(&recordConn{writes: make(chan []byte, 1)}, "user", "user")
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    errChan := make(chan error, 2)
+    go NewServer(nil).writePump(ctx, client, errChan)
+    select {
+    case err := <-errChan:
+        if err != nil {
+            t.Fatalf("pump stopped with %v", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("the pump did not stop")
+    }
+}
@@ -0,0 +1,113 @@
+// internal/server/chat/slow.go
+package chat
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"textual/internal/server/handlers"
+	"textual/internal/server/metrics"
+	"textual/pkg/protocol"
+)
+
+// a client with this many messages queued, or whose queue has not emptied
+// for this long, is degraded until it catches up. Behind for maxLag, it is
+// disconnected: it reconnects and loads what it missed
+const (
+    degradeQueue = 64
+    degradeLag   = 2 * time.Second
+    maxLag       = 30 * time.Second
+)
+
+// the slowest clients listed by DeliveryStats
+const slowestListed = 10
+
+// skippable tells the events a degraded client goes without, the next ones
+// replace them
+func skippable(t protocol.MessageType) bool {
+    return t == protocol.TypeStatusUpdate || t == protocol.TypeTyping
+}
+
+// historyKey identifies the page of a history message: its conversation
+// and cursor
+func historyKey(msg protocol.Message) (string, bool) {
+    if msg.Type != protocol.TypeMessageHistory {
+        return "", false
+    }
+    payload, _ := msg.Payload.(map[string]interface{})
+    return fmt.Sprintf("%v|%v|%v", payload["recipient_id"], payload["group_id"], payload["before_id"]), true
+}
+
+// checkLag degrades a client falling behind after a write, and restores it
+// once its queue is empty. It fails for a client behind for too long
+func (s *Server) checkLag(client *handlers.Client, degraded bool) error {
+    queued := len(client.Send)
+    lag := client.Delivery.Update(s.clock.Now(), queued)
+    switch {
+    case lag >= maxLag:
+        metrics.SlowClientsDisconnected.Add(1)
+        return fmt.Errorf("client too slow: %d messages queued, %s behind", queued, lag.Round(time.Second))
+    case !degraded && (queued >= degradeQueue || lag >= degradeLag):
+        log.Printf("Client %s falling behind (%d messages queued, %s), degrading", client.Username, queued, lag.Round(time.Millisecond))
+        metrics.SlowClientsDegraded.Add(1)
+        client.Delivery.SetDegraded(true)
+    case degraded && queued == 0:
+        log.Printf("Client %s caught up", client.Username)
+        client.Delivery.SetDegraded(false)
+    }
+    return nil
+}
+
+// SlowClient is the delivery state of a client behind
+type SlowClient struct {
+    Username string `json:"username"`
+    Queued   int    `json:"queued"`
+    LagMs    int64  `json:"lag_ms"`
+    Degraded bool   `json:"degraded"`
+}
+
+// deliveryStats are the metrics of the queues of the clients
+type deliveryStats struct {
+    Clients   int          `json:"clients"`
+    Degraded  int          `json:"degraded"`
+    MaxQueued int          `json:"max_queued"`
+    MaxLagMs  int64        `json:"max_lag_ms"`
+    Slowest   []SlowClient `json:"slowest"`
+}
+
+// DeliveryStats returns the queues of the clients for the metrics: the
+// deepest, the longest behind and the slowest clients
+func (s *Server) DeliveryStats() interface{} {
+    now := s.clock.Now()
+    var stats deliveryStats
+    stats.Clients = s.sessions.Len()
+    s.sessions.Each(func(client *handlers.Client) {
+        slow := SlowClient{
+            Username: client.Username,
+            Queued:   len(client.Send),
+            LagMs:    client.Delivery.Lag(now).Milliseconds(),
+            Degraded: client.Delivery.Degraded(),
+        }
+        if slow.Degraded {
+            stats.Degraded++
+        }
+        stats.MaxQueued = max(stats.MaxQueued, slow.Queued)
+        stats.MaxLagMs = max(stats.MaxLagMs, slow.LagMs)
+        if slow.Queued > 0 || slow.LagMs > 0 {
+            stats.Slowest = append(stats.Slowest, slow)
+        }
+    })
+
+    sort.Slice(stats.Slowest, func(i, j int) bool {
+        if stats.Slowest[i].LagMs != stats.Slowest[j].LagMs {
+            return stats.Slowest[i].LagMs > stats.Slowest[j].LagMs
+        }
+        return stats.Slowest[i].Queued > stats.Slowest[j].Queued
+    })
+    if len(stats.Slowest) > slowestListed {
+        stats.Slowest = stats.Slowest[:slowestListed]
+    }
+    return stats
+}
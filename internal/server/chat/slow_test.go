@@ -0,0 +1,136 @@
+// internal/server/chat/slow_test.go
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"textual/internal/clock"
+	"textual/internal/server/handlers"
+	"textual/pkg/protocol"
+)
+
+func history(recipientID string) protocol.Message {
+    return protocol.NewMessage(protocol.TypeMessageHistory, map[string]interface{}{
+        "messages":     nil,
+        "recipient_id": recipientID,
+    })
+}
+
+func TestWriteBatchDegraded(t *testing.T) {
+    conn := &recordConn{writes: make(chan []byte, 10)}
+    client := handlers.NewClient(conn, "user", "user")
+    for _, msg := range []protocol.Message{
+        protocol.NewMessage(protocol.TypeStatusUpdate, nil),
+        history("bob"),
+        history("bob"),
+        protocol.NewMessage(protocol.TypeTyping, nil),
+        history("bob"),
+        history("carol"),
+        protocol.NewMessage(protocol.TypeDirectMessage, nil),
+    } {
+        client.Send <- msg
+    }
+
+    count, _, err := writeBatch(client, protocol.NewMessage(protocol.TypeGlobalMessage, nil), true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := typeNames(nextWrite(t, conn))
+    want := "global_message,message_history,message_history,direct_message"
+    if strings.Join(got, ",") != want || count != 4 {
+        t.Fatalf("wrote %d messages %v, want %s", count, got, want)
+    }
+}
+
+func TestWriteBatchDegradedSkipsAll(t *testing.T) {
+    conn := &recordConn{writes: make(chan []byte, 10)}
+    client := handlers.NewClient(conn, "user", "user")
+
+    count, _, err := writeBatch(client, protocol.NewMessage(protocol.TypeTyping, nil), true)
+    if err != nil || count != 0 {
+        t.Fatalf("wrote %d messages (%v), want none", count, err)
+    }
+    select {
+    case data := <-conn.writes:
+        t.Fatalf("wrote %q for skipped events", data)
+    default:
+    }
+}
+
+func TestWriteBatchDisconnectNotice(t *testing.T) {
+    conn := &recordConn{writes: make(chan []byte, 10)}
+    client := handlers.NewClient(conn, "user", "user")
+    client.Send <- protocol.NewMessage(protocol.TypeDisconnectNotice, nil)
+    client.Send <- protocol.NewMessage(protocol.TypeDirectMessage, nil)
+
+    // the notice is the last message written, the session ends with it
+    count, closed, err := writeBatch(client, protocol.NewMessage(protocol.TypeGlobalMessage, nil), false)
+    if err != nil || !closed || count != 2 {
+        t.Fatalf("wrote %d messages, closed %t (%v), want 2 and closed", count, closed, err)
+    }
+    got := typeNames(nextWrite(t, conn))
+    if want := "global_message,disconnect_notice"; strings.Join(got, ",") != want {
+        t.Fatalf("wrote %v, want %s", got, want)
+    }
+}
+
+func TestCheckLag(t *testing.T) {
+    clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+    s := NewServer(nil)
+    s.SetClock(clk)
+    client := handlers.NewClient(nil, "user", "user")
+    client.Send <- protocol.NewMessage(protocol.TypeGlobalMessage, nil)
+
+    if err := s.checkLag(client, false); err != nil || client.Delivery.Degraded() {
+        t.Fatalf("degraded on the first write behind (%v)", err)
+    }
+    clk.Advance(degradeLag)
+    if err := s.checkLag(client, false); err != nil || !client.Delivery.Degraded() {
+        t.Fatalf("not degraded behind for %s (%v)", degradeLag, err)
+    }
+
+    <-client.Send
+    if err := s.checkLag(client, true); err != nil || client.Delivery.Degraded() {
+        t.Fatalf("still degraded with an empty queue (%v)", err)
+    }
+    if lag := client.Delivery.Lag(clk.Now()); lag != 0 {
+        t.Fatalf("lag %s once caught up", lag)
+    }
+
+    for len(client.Send) < degradeQueue {
+        client.Send <- protocol.NewMessage(protocol.TypeGlobalMessage, nil)
+    }
+    if err := s.checkLag(client, false); err != nil || !client.Delivery.Degraded() {
+        t.Fatalf("not degraded with %d messages queued (%v)", degradeQueue, err)
+    }
+    clk.Advance(maxLag)
+    if err := s.checkLag(client, true); err == nil {
+        t.Fatalf("not disconnected behind for %s", maxLag)
+    }
+}
+
+func TestDeliveryStats(t *testing.T) {
+    clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+    s := NewServer(nil)
+    s.SetClock(clk)
+    fast := handlers.NewClient(nil, "fast", "fast")
+    slow := handlers.NewClient(nil, "slow", "slow")
+    s.register(fast)
+    s.register(slow)
+    for i := 0; i < 3; i++ {
+        slow.Send <- protocol.NewMessage(protocol.TypeGlobalMessage, nil)
+    }
+    slow.Delivery.Update(clk.Now(), 3)
+    slow.Delivery.SetDegraded(true)
+    clk.Advance(1500 * time.Millisecond)
+
+    got := s.DeliveryStats().(deliveryStats)
+    if got.Clients != 2 || got.Degraded != 1 || got.MaxQueued != 3 || got.MaxLagMs != 1500 {
+        t.Fatalf("stats %+v", got)
+    }
+    if len(got.Slowest) != 1 || got.Slowest[0].Username != "slow" {
+        t.Fatalf("slowest %+v, want slow alone", got.Slowest)
+    }
+}
@@ -0,0 +1,205 @@
+// internal/server/config/config.go
+package config
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the optional server settings read from the environment
+type Config struct {
+    // message archiving (disabled when ArchiveDir is empty)
+    ArchiveDir       string
+    ArchiveAfter     time.Duration
+    ArchiveInterval  time.Duration
+    ArchiveBatchSize int
+
+    // database pool and health checks
+    DBMaxOpenConns    int
+    DBMaxIdleConns    int
+    DBConnMaxLifetime time.Duration
+    DBHealthInterval  time.Duration
+    DBSlowQuery       time.Duration
+
+    // workers delivering the broadcasts, the clients are spread across them
+    BroadcastShards int
+
+    // interval between batched presence writes (0 writes immediately)
+    PresenceFlushInterval time.Duration
+
+    // uploaded files, voice messages included (disabled when AttachmentDir is empty)
+    AttachmentDir     string
+    AttachmentMaxSize int64
+
+    // previews of the links in the messages, fetched by the server
+    LinkPreviews        bool
+    LinkPreviewTimeout  time.Duration
+    LinkPreviewCacheTTL time.Duration
+
+    // GIF search of /gif (disabled when GifAPIKey is empty)
+    GifProvider string
+    GifAPIKey   string
+    GifRating   string
+    GifTimeout  time.Duration
+
+    // friend requests a user can send per hour (0 for no limit), and how
+    // long the requests to a user who refused one are ignored
+    FriendRequestsPerHour int
+    FriendRequestCooldown time.Duration
+
+    // messages a user can post per minute in the global chat (0 for no
+    // limit)
+    GlobalMessagesPerMinute int
+
+    // members of a group, groups and friends of a user (0 for no limit)
+    MaxGroupMembers   int
+    MaxGroupsPerUser  int
+    MaxFriendsPerUser int
+
+    // how often the due reminders are looked for
+    ReminderInterval time.Duration
+
+    // usernames of the server admins, they get the Server page of the client
+    Admins []string
+
+    // port of the REST API (disabled when empty)
+    APIPort string
+
+    // port of the web client and its WebSocket endpoint (disabled when empty)
+    WebPort string
+
+    // address serving the metrics, host included (disabled when empty)
+    MetricsAddr string
+
+    // port of the profiles and metrics, on 127.0.0.1 only (disabled when empty)
+    DebugPort string
+
+    // capture of the frames of every connection, for cmd/replay (disabled
+    // when empty)
+    WireTap string
+
+    // bad network every connection goes through, see chaos.Parse (disabled
+    // when empty). For tests only
+    Chaos string
+}
+
+func Load() Config {
+    return Config{
+        ArchiveDir:       os.Getenv("ARCHIVE_DIR"),
+        ArchiveAfter:     time.Duration(Int("ARCHIVE_AFTER_DAYS", 90)) * 24 * time.Hour,
+        ArchiveInterval:  Duration("ARCHIVE_INTERVAL", time.Hour),
+        ArchiveBatchSize: Int("ARCHIVE_BATCH_SIZE", 1000),
+
+        DBMaxOpenConns:    Int("DB_MAX_OPEN_CONNS", 25),
+        DBMaxIdleConns:    Int("DB_MAX_IDLE_CONNS", 10),
+        DBConnMaxLifetime: Duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+        DBHealthInterval:  Duration("DB_HEALTH_INTERVAL", 10*time.Second),
+        DBSlowQuery:       Duration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+
+        BroadcastShards: Int("BROADCAST_SHARDS", runtime.NumCPU()),
+
+        PresenceFlushInterval: Duration("PRESENCE_FLUSH_INTERVAL", 5*time.Second),
+
+        AttachmentDir:     os.Getenv("ATTACHMENT_DIR"),
+        AttachmentMaxSize: int64(Int("ATTACHMENT_MAX_SIZE_KB", 4096)) << 10,
+
+        LinkPreviews:        Bool("LINK_PREVIEWS", true),
+        LinkPreviewTimeout:  Duration("LINK_PREVIEW_TIMEOUT", 5*time.Second),
+        LinkPreviewCacheTTL: Duration("LINK_PREVIEW_CACHE_TTL", time.Hour),
+
+        GifProvider: String("GIF_PROVIDER", "giphy"),
+        GifAPIKey:   os.Getenv("GIF_API_KEY"),
+        GifRating:   String("GIF_RATING", "pg"),
+        GifTimeout:  Duration("GIF_TIMEOUT", 5*time.Second),
+
+        FriendRequestsPerHour: Int("FRIEND_REQUESTS_PER_HOUR", 10),
+        FriendRequestCooldown: Duration("FRIEND_REQUEST_COOLDOWN", 24*time.Hour),
+
+        GlobalMessagesPerMinute: Int("GLOBAL_MESSAGES_PER_MINUTE", 20),
+
+        MaxGroupMembers:   Int("MAX_GROUP_MEMBERS", 0),
+        MaxGroupsPerUser:  Int("MAX_GROUPS_PER_USER", 0),
+        MaxFriendsPerUser: Int("MAX_FRIENDS_PER_USER", 0),
+
+        ReminderInterval: Duration("REMINDER_INTERVAL", 15*time.Second),
+
+        Admins: List("ADMINS"),
+
+        APIPort: os.Getenv("API_PORT"),
+        WebPort: os.Getenv("WEB_PORT"),
+
+        MetricsAddr: os.Getenv("METRICS_ADDR"),
+        DebugPort:   os.Getenv("DEBUG_PORT"),
+
+        WireTap: os.Getenv("WIRE_TAP"),
+        Chaos:   os.Getenv("CHAOS"),
+    }
+}
+
+// Int reads an integer variable, falling back to def when unset or invalid
+func Int(key string, def int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return def
+    }
+
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        log.Printf("Invalid value for %s: %q, using default %d", key, value, def)
+        return def
+    }
+    return n
+}
+
+// Duration reads a duration variable (e.g. "30s", "5m"), falling back to def when unset or invalid
+func Duration(key string, def time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return def
+    }
+
+    d, err := time.ParseDuration(value)
+    if err != nil {
+        log.Printf("Invalid value for %s: %q, using default %s", key, value, def)
+        return def
+    }
+    return d
+}
+
+// Bool reads a boolean variable, falling back to def when unset or invalid
+func Bool(key string, def bool) bool {
+    value := os.Getenv(key)
+    if value == "" {
+        return def
+    }
+
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        log.Printf("Invalid value for %s: %q, using default %t", key, value, def)
+        return def
+    }
+    return b
+}
+
+// List reads a comma-separated variable, the blank items are dropped
+func List(key string) []string {
+    var items []string
+    for _, item := range strings.Split(os.Getenv(key), ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// String reads a variable, falling back to def when unset
+func String(key, def string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return def
+}
@@ -0,0 +1,326 @@
+// internal/server/database/archive.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"textual/internal/server/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ArchivedMessage points to a message stored in an archive file
+type ArchivedMessage struct {
+    ID          string
+    SenderID    *string
+    RecipientID *string
+    GroupID     *string
+    SentAt      time.Time
+    ArchivePath string
+    ArchiveLine int
+    // set on the replies of a thread
+    ThreadRootID *string
+}
+
+// ArchiveRecord is a line of an archive file: a message with its edit
+// history, whose rows go with the message
+type ArchiveRecord struct {
+    models.Message
+    Revisions []models.MessageRevision `json:"revisions,omitempty"`
+}
+
+// GetMessagesOlderThan returns the oldest messages sent before cutoff
+func (db *DB) GetMessagesOlderThan(ctx context.Context, cutoff time.Time, limit int) ([]ArchiveRecord, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT messages.id,
+               messages.content,
+               messages.sender_id,
+               messages.recipient_id,
+               messages.group_id,
+               messages.sent_at,
+               messages.read_at,
+               messages.edited_at,
+               messages.status,
+               COALESCE(messages.client_id, ''),
+               COALESCE(users.username, '') as sender_name,
+               messages.thread_root_id
+        FROM messages
+        LEFT JOIN users ON messages.sender_id = users.id
+        WHERE messages.sent_at < $1
+        ORDER BY messages.sent_at ASC
+        LIMIT $2
+    `, cutoff, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get old messages: %v", err)
+    }
+    defer rows.Close()
+
+    var records []ArchiveRecord
+    for rows.Next() {
+        var record ArchiveRecord
+        msg := &record.Message
+        var senderID *string
+        if err := rows.Scan(
+            &msg.ID,
+            &msg.Content,
+            &senderID,
+            &msg.RecipientID,
+            &msg.GroupID,
+            &msg.SentAt,
+            &msg.ReadAt,
+            &msg.EditedAt,
+            &msg.Status,
+            &msg.ClientID,
+            &msg.SenderName,
+            &msg.ThreadRootID,
+        ); err != nil {
+            return nil, fmt.Errorf("failed to scan old message: %v", err)
+        }
+        if senderID != nil {
+            msg.SenderID = *senderID
+        }
+        records = append(records, record)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("failed to get old messages: %v", err)
+    }
+
+    if err := db.attachRevisions(ctx, records); err != nil {
+        return nil, err
+    }
+    if err := db.attachRecordDetails(ctx, records); err != nil {
+        return nil, err
+    }
+    return records, nil
+}
+
+// attachRecordDetails sets the recording of the voice messages and the
+// preview of the messages with a link
+func (db *DB) attachRecordDetails(ctx context.Context, records []ArchiveRecord) error {
+    messages := make([]models.Message, len(records))
+    for i := range records {
+        messages[i] = records[i].Message
+    }
+    if err := db.AttachVoice(ctx, messages); err != nil {
+        return err
+    }
+    if err := db.AttachPreviews(ctx, messages); err != nil {
+        return err
+    }
+    for i := range records {
+        records[i].Voice = messages[i].Voice
+        records[i].Preview = messages[i].Preview
+    }
+    return nil
+}
+
+// attachRevisions sets the edit history of the records of edited messages
+func (db *DB) attachRevisions(ctx context.Context, records []ArchiveRecord) error {
+    ids := make([]string, 0, len(records))
+    byID := make(map[string]*ArchiveRecord, len(records))
+    for i := range records {
+        if records[i].EditedAt != nil {
+            ids = append(ids, records[i].ID)
+            byID[records[i].ID] = &records[i]
+        }
+    }
+    if len(ids) == 0 {
+        return nil
+    }
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, message_id, content, COALESCE(edited_by::text, ''), edited_at
+        FROM message_revisions
+        WHERE message_id = ANY($1::uuid[])
+        ORDER BY edited_at ASC
+    `, pq.Array(ids))
+    if err != nil {
+        return fmt.Errorf("failed to get message revisions: %v", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var rev models.MessageRevision
+        if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.Content, &rev.EditedBy, &rev.EditedAt); err != nil {
+            return fmt.Errorf("failed to scan message revision: %v", err)
+        }
+        if record, ok := byID[rev.MessageID]; ok {
+            record.Revisions = append(record.Revisions, rev)
+        }
+    }
+    return rows.Err()
+}
+
+// ArchiveMessages records the archive location of the given messages and
+// removes them from the messages table, in a single transaction. Their
+// revisions, recordings and previews go with them, they are in the archive
+// file
+func (db *DB) ArchiveMessages(ctx context.Context, records []ArchiveRecord, archivePath string) error {
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin archive transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    for i, msg := range records {
+        var senderID, attachmentID *string
+        if msg.SenderID != "" {
+            senderID = &msg.SenderID
+        }
+        if msg.Voice != nil {
+            attachmentID = &msg.Voice.AttachmentID
+        }
+
+        if _, err := db.execTx(ctx, tx, `
+            INSERT INTO archived_messages (id, sender_id, recipient_id, group_id, sent_at, archive_path, archive_line, attachment_id, thread_root_id)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+            ON CONFLICT (id) DO NOTHING
+        `, msg.ID, senderID, msg.RecipientID, msg.GroupID, msg.SentAt, archivePath, i, attachmentID, msg.ThreadRootID); err != nil {
+            return fmt.Errorf("failed to record archived message: %v", err)
+        }
+
+        if _, err := db.execTx(ctx, tx, `DELETE FROM messages WHERE id = $1`, msg.ID); err != nil {
+            return fmt.Errorf("failed to delete archived message: %v", err)
+        }
+    }
+
+    return tx.Commit()
+}
+
+// GetArchivedMessage returns the archive pointer for a message
+func (db *DB) GetArchivedMessage(ctx context.Context, messageID string) (*ArchivedMessage, error) {
+    var archived ArchivedMessage
+    err := db.QueryRowContext(ctx, `
+        SELECT id, sender_id, recipient_id, group_id, sent_at, archive_path, archive_line, thread_root_id
+        FROM archived_messages
+        WHERE id::text = $1
+    `, messageID).Scan(
+        &archived.ID,
+        &archived.SenderID,
+        &archived.RecipientID,
+        &archived.GroupID,
+        &archived.SentAt,
+        &archived.ArchivePath,
+        &archived.ArchiveLine,
+        &archived.ThreadRootID,
+    )
+    if err != nil {
+        return nil, fmt.Errorf("archived message not found: %v", err)
+    }
+
+    return &archived, nil
+}
+
+// GetArchivedMessages returns the pointers to a page of the archived
+// messages of a conversation sent before a time, newest first: the direct
+// messages between two users, a group when groupID is set, the global chat
+// when both are empty. The replies of the threads are left out
+func (db *DB) GetArchivedMessages(ctx context.Context, userID, otherID, groupID string, before time.Time, limit int) ([]ArchivedMessage, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, sender_id, recipient_id, group_id, sent_at, archive_path, archive_line, thread_root_id
+        FROM archived_messages
+        WHERE (
+            ($3 <> '' AND group_id::text = $3)
+            OR ($3 = '' AND $2 <> '' AND ((sender_id::text = $1 AND recipient_id::text = $2)
+                                       OR (sender_id::text = $2 AND recipient_id::text = $1)))
+            OR ($3 = '' AND $2 = '' AND recipient_id IS NULL AND group_id IS NULL)
+        )
+        AND thread_root_id IS NULL
+        AND sent_at < $4
+        ORDER BY sent_at DESC
+        LIMIT $5
+    `, userID, otherID, groupID, before, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get archived messages: %v", err)
+    }
+    return scanArchived(rows)
+}
+
+// GetArchivedReplies returns the pointers to a page of the archived replies
+// of a thread sent before a time, newest first
+func (db *DB) GetArchivedReplies(ctx context.Context, rootID string, before time.Time, limit int) ([]ArchivedMessage, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, sender_id, recipient_id, group_id, sent_at, archive_path, archive_line, thread_root_id
+        FROM archived_messages
+        WHERE thread_root_id::text = $1
+        AND sent_at < $2
+        ORDER BY sent_at DESC
+        LIMIT $3
+    `, rootID, before, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get archived replies: %v", err)
+    }
+    return scanArchived(rows)
+}
+
+func scanArchived(rows *sql.Rows) ([]ArchivedMessage, error) {
+    defer rows.Close()
+
+    var pointers []ArchivedMessage
+    for rows.Next() {
+        var archived ArchivedMessage
+        if err := rows.Scan(
+            &archived.ID,
+            &archived.SenderID,
+            &archived.RecipientID,
+            &archived.GroupID,
+            &archived.SentAt,
+            &archived.ArchivePath,
+            &archived.ArchiveLine,
+            &archived.ThreadRootID,
+        ); err != nil {
+            return nil, fmt.Errorf("failed to scan archived message: %v", err)
+        }
+        pointers = append(pointers, archived)
+    }
+    return pointers, rows.Err()
+}
+
+// MessageSentAt returns when a message was sent, archived or not
+func (db *DB) MessageSentAt(ctx context.Context, messageID string) (time.Time, error) {
+    var sentAt time.Time
+    err := db.QueryRowContext(ctx, `
+        SELECT sent_at FROM messages WHERE id::text = $1
+        UNION ALL
+        SELECT sent_at FROM archived_messages WHERE id::text = $1
+        LIMIT 1
+    `, messageID).Scan(&sentAt)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("message not found: %v", err)
+    }
+    return sentAt, nil
+}
+
+// CountReplies returns the number of replies of the given thread roots,
+// archived or not, for the roots read from the archive
+func (db *DB) CountReplies(ctx context.Context, rootIDs []string) (map[string]int, error) {
+    counts := make(map[string]int)
+    if len(rootIDs) == 0 {
+        return counts, nil
+    }
+    rows, err := db.QueryContext(ctx, `
+        SELECT thread_root_id, COUNT(*)
+        FROM (
+            SELECT thread_root_id FROM messages WHERE thread_root_id = ANY($1::uuid[])
+            UNION ALL
+            SELECT thread_root_id FROM archived_messages WHERE thread_root_id = ANY($1::uuid[])
+        ) replies
+        GROUP BY thread_root_id
+    `, pq.Array(rootIDs))
+    if err != nil {
+        return nil, fmt.Errorf("failed to count replies: %v", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var rootID string
+        var count int
+        if err := rows.Scan(&rootID, &count); err != nil {
+            return nil, fmt.Errorf("failed to scan reply count: %v", err)
+        }
+        counts[rootID] = count
+    }
+    return counts, rows.Err()
+}
@@ -0,0 +1,117 @@
+// internal/server/database/attachments.go
+package database
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+
+	"github.com/lib/pq"
+)
+
+// SaveAttachment stores an uploaded file, its ID and creation time are set by
+// the database
+func (db *DB) SaveAttachment(ctx context.Context, a *models.Attachment) error {
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO attachments (uploader_id, name, mime_type, size)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `, a.UploaderID, a.Name, a.MimeType, a.Size).Scan(&a.ID, &a.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to save attachment: %v", err)
+    }
+    return nil
+}
+
+func (db *DB) GetAttachment(ctx context.Context, id string) (*models.Attachment, error) {
+    var a models.Attachment
+    err := db.QueryRowContext(ctx, `
+        SELECT id, uploader_id, name, mime_type, size, created_at
+        FROM attachments
+        WHERE id = $1
+    `, id).Scan(&a.ID, &a.UploaderID, &a.Name, &a.MimeType, &a.Size, &a.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &a, nil
+}
+
+// CanReadAttachment tells if a user uploaded the file or can see a message
+// it was sent with, archived or not
+func (db *DB) CanReadAttachment(ctx context.Context, userID, attachmentID string) (bool, error) {
+    var ok bool
+    err := db.QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM attachments WHERE id = $2 AND uploader_id = $1
+        ) OR EXISTS(
+            SELECT 1
+            FROM voice_messages v
+            JOIN messages m ON m.id = v.message_id
+            WHERE v.attachment_id = $2 AND (
+                (m.recipient_id IS NULL AND m.group_id IS NULL)
+                OR m.sender_id = $1 OR m.recipient_id = $1
+                OR m.group_id IN (SELECT group_id FROM group_members WHERE user_id = $1)
+            )
+        ) OR EXISTS(
+            SELECT 1
+            FROM archived_messages m
+            WHERE m.attachment_id = $2 AND (
+                (m.recipient_id IS NULL AND m.group_id IS NULL)
+                OR m.sender_id = $1 OR m.recipient_id = $1
+                OR m.group_id IN (SELECT group_id FROM group_members WHERE user_id = $1)
+            )
+        )
+    `, userID, attachmentID).Scan(&ok)
+    if err != nil {
+        return false, fmt.Errorf("failed to check attachment access: %v", err)
+    }
+    return ok, nil
+}
+
+// SaveVoice links a stored message to its recording
+func (db *DB) SaveVoice(ctx context.Context, messageID string, voice models.Voice) error {
+    _, err := db.ExecContext(ctx, `
+        INSERT INTO voice_messages (message_id, attachment_id, duration_ms, waveform)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (message_id) DO NOTHING
+    `, messageID, voice.AttachmentID, voice.Duration, voice.Waveform)
+    if err != nil {
+        return fmt.Errorf("failed to save voice message: %v", err)
+    }
+    return nil
+}
+
+// AttachVoice sets the recording of the voice messages among the messages
+func (db *DB) AttachVoice(ctx context.Context, messages []models.Message) error {
+    if len(messages) == 0 {
+        return nil
+    }
+    ids := make([]string, 0, len(messages))
+    byID := make(map[string]*models.Message, len(messages))
+    for i := range messages {
+        ids = append(ids, messages[i].ID)
+        byID[messages[i].ID] = &messages[i]
+    }
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT message_id, attachment_id, duration_ms, waveform
+        FROM voice_messages
+        WHERE message_id = ANY($1::uuid[])
+    `, pq.Array(ids))
+    if err != nil {
+        return fmt.Errorf("failed to get voice messages: %v", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var messageID string
+        var voice models.Voice
+        if err := rows.Scan(&messageID, &voice.AttachmentID, &voice.Duration, &voice.Waveform); err != nil {
+            return fmt.Errorf("failed to scan voice message: %v", err)
+        }
+        if msg, ok := byID[messageID]; ok {
+            msg.Voice = &voice
+        }
+    }
+    return rows.Err()
+}
@@ -0,0 +1,31 @@
+// internal/server/database/display.go
+package database
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+)
+
+// SetDisplayPrefs saves the display preferences of a user
+func (db *DB) SetDisplayPrefs(ctx context.Context, userID string, prefs models.DisplayPrefs) error {
+    _, err := db.ExecContext(ctx, `
+        UPDATE users SET timezone = $2, time_format = $3, locale = $4 WHERE id = $1
+    `, userID, prefs.Timezone, prefs.TimeFormat, prefs.Locale)
+    if err != nil {
+        return fmt.Errorf("failed to save display prefs: %v", err)
+    }
+    return nil
+}
+
+// GetDisplayPrefs returns the display preferences of a user
+func (db *DB) GetDisplayPrefs(ctx context.Context, userID string) (models.DisplayPrefs, error) {
+    var prefs models.DisplayPrefs
+    err := db.QueryRowContext(ctx, `
+        SELECT timezone, time_format, locale FROM users WHERE id = $1
+    `, userID).Scan(&prefs.Timezone, &prefs.TimeFormat, &prefs.Locale)
+    if err != nil {
+        return prefs, fmt.Errorf("failed to get display prefs: %v", err)
+    }
+    return prefs, nil
+}
@@ -0,0 +1,199 @@
+// internal/server/database/e2ee.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// SaveDeviceKeys stores the public keys of a device, replacing the previous
+// ones, and adds its new one-time prekeys
+func (db *DB) SaveDeviceKeys(ctx context.Context, userID string, device protocol.DeviceBundle, oneTimeKeys []protocol.OneTimePreKey) error {
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    _, err = db.execTx(ctx, tx, `
+        INSERT INTO device_keys (user_id, device_id, identity_key, signing_key, signed_prekey, signed_prekey_id, signature)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (user_id, device_id) DO UPDATE SET
+            identity_key = EXCLUDED.identity_key,
+            signing_key = EXCLUDED.signing_key,
+            signed_prekey = EXCLUDED.signed_prekey,
+            signed_prekey_id = EXCLUDED.signed_prekey_id,
+            signature = EXCLUDED.signature,
+            updated_at = CURRENT_TIMESTAMP
+    `, userID, device.DeviceID, device.IdentityKey, device.SigningKey, device.SignedPreKey, device.SignedPreKeyID, device.Signature)
+    if err != nil {
+        return fmt.Errorf("failed to save device keys: %v", err)
+    }
+
+    for _, key := range oneTimeKeys {
+        _, err = db.execTx(ctx, tx, `
+            INSERT INTO one_time_prekeys (user_id, device_id, key_id, public_key)
+            VALUES ($1, $2, $3, $4)
+            ON CONFLICT DO NOTHING
+        `, userID, device.DeviceID, key.ID, key.Key)
+        if err != nil {
+            return fmt.Errorf("failed to save one-time prekey: %v", err)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit device keys: %v", err)
+    }
+    return nil
+}
+
+// ClaimKeyBundles returns the keys of every device of a user, each with a
+// one-time prekey deleted as it is handed out
+func (db *DB) ClaimKeyBundles(ctx context.Context, userID string) ([]protocol.DeviceBundle, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT device_id, identity_key, signing_key, signed_prekey, signed_prekey_id, signature
+        FROM device_keys
+        WHERE user_id = $1
+        ORDER BY updated_at DESC
+    `, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get device keys: %v", err)
+    }
+
+    var bundles []protocol.DeviceBundle
+    for rows.Next() {
+        var b protocol.DeviceBundle
+        if err := rows.Scan(&b.DeviceID, &b.IdentityKey, &b.SigningKey, &b.SignedPreKey, &b.SignedPreKeyID, &b.Signature); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("failed to scan device keys: %v", err)
+        }
+        bundles = append(bundles, b)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    for i := range bundles {
+        // no one-time prekey left is not an error, X3DH works without
+        err := db.QueryRowContext(ctx, `
+            DELETE FROM one_time_prekeys
+            WHERE (user_id, device_id, key_id) = (
+                SELECT user_id, device_id, key_id FROM one_time_prekeys
+                WHERE user_id = $1 AND device_id = $2
+                ORDER BY key_id
+                LIMIT 1
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING key_id, public_key
+        `, userID, bundles[i].DeviceID).Scan(&bundles[i].OneTimePreKeyID, &bundles[i].OneTimePreKey)
+        if err != nil && err != sql.ErrNoRows {
+            return nil, fmt.Errorf("failed to claim one-time prekey: %v", err)
+        }
+    }
+    return bundles, nil
+}
+
+// QueueEncryptedMessage stores an encrypted message until its recipient gets
+// it, the ID and time are set by the database
+func (db *DB) QueueEncryptedMessage(ctx context.Context, msg *protocol.EncryptedMessagePayload) error {
+    envelopes, err := json.Marshal(msg.Envelopes)
+    if err != nil {
+        return fmt.Errorf("failed to encode envelopes: %v", err)
+    }
+
+    var sentAt time.Time
+    err = db.QueryRowContext(ctx, `
+        INSERT INTO encrypted_messages (sender_id, sender_device, recipient_id, group_id, sender_key, client_id, envelopes)
+        VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, NULLIF($6, ''), $7)
+        RETURNING id, sent_at
+    `, msg.SenderID, msg.SenderDevice, msg.RecipientID, msg.GroupID, msg.SenderKey, msg.ClientID, envelopes).Scan(&msg.ID, &sentAt)
+    if err != nil {
+        return fmt.Errorf("failed to queue encrypted message: %v", err)
+    }
+    msg.SentAt = sentAt.Unix()
+    return nil
+}
+
+// QueueGroupEncryptedMessage stores a group message for each other member
+// using encryption, one copy per recipient as each deletes its own
+func (db *DB) QueueGroupEncryptedMessage(ctx context.Context, msg *protocol.EncryptedMessagePayload) ([]protocol.EncryptedMessagePayload, error) {
+    envelopes, err := json.Marshal(msg.Envelopes)
+    if err != nil {
+        return nil, fmt.Errorf("failed to encode envelopes: %v", err)
+    }
+
+    rows, err := db.QueryContext(ctx, `
+        INSERT INTO encrypted_messages (sender_id, sender_device, recipient_id, group_id, client_id, envelopes)
+        SELECT $1, $2, gm.user_id, $3, NULLIF($4, ''), $5
+        FROM group_members gm
+        WHERE gm.group_id = $3 AND gm.user_id != $1
+          AND EXISTS (SELECT 1 FROM device_keys dk WHERE dk.user_id = gm.user_id)
+        RETURNING id, recipient_id, sent_at
+    `, msg.SenderID, msg.SenderDevice, msg.GroupID, msg.ClientID, envelopes)
+    if err != nil {
+        return nil, fmt.Errorf("failed to queue group encrypted message: %v", err)
+    }
+    defer rows.Close()
+
+    var queued []protocol.EncryptedMessagePayload
+    for rows.Next() {
+        q := *msg
+        var sentAt time.Time
+        if err := rows.Scan(&q.ID, &q.RecipientID, &sentAt); err != nil {
+            return nil, fmt.Errorf("failed to scan queued message: %v", err)
+        }
+        q.SentAt = sentAt.Unix()
+        queued = append(queued, q)
+    }
+    return queued, rows.Err()
+}
+
+// DeleteEncryptedMessage drops a queued message once delivered
+func (db *DB) DeleteEncryptedMessage(ctx context.Context, id string) error {
+    if _, err := db.ExecContext(ctx, `DELETE FROM encrypted_messages WHERE id = $1`, id); err != nil {
+        return fmt.Errorf("failed to delete encrypted message: %v", err)
+    }
+    return nil
+}
+
+// TakeEncryptedMessages returns the messages waiting for a user, oldest
+// first, and deletes them
+func (db *DB) TakeEncryptedMessages(ctx context.Context, userID string) ([]protocol.EncryptedMessagePayload, error) {
+    rows, err := db.QueryContext(ctx, `
+        WITH taken AS (
+            DELETE FROM encrypted_messages WHERE recipient_id = $1
+            RETURNING id, sender_id, sender_device, recipient_id, group_id, sender_key, client_id, envelopes, sent_at
+        )
+        SELECT taken.id, taken.sender_id, u.username, taken.sender_device, taken.recipient_id,
+               COALESCE(taken.group_id::text, ''), taken.sender_key,
+               COALESCE(taken.client_id, ''), taken.envelopes, taken.sent_at
+        FROM taken
+        JOIN users u ON u.id = taken.sender_id
+        ORDER BY taken.sent_at
+    `, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get encrypted messages: %v", err)
+    }
+    defer rows.Close()
+
+    var messages []protocol.EncryptedMessagePayload
+    for rows.Next() {
+        var msg protocol.EncryptedMessagePayload
+        var envelopes []byte
+        var sentAt time.Time
+        if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.SenderName, &msg.SenderDevice, &msg.RecipientID, &msg.GroupID, &msg.SenderKey, &msg.ClientID, &envelopes, &sentAt); err != nil {
+            return nil, fmt.Errorf("failed to scan encrypted message: %v", err)
+        }
+        if err := json.Unmarshal(envelopes, &msg.Envelopes); err != nil {
+            return nil, fmt.Errorf("failed to decode envelopes: %v", err)
+        }
+        msg.SentAt = sentAt.Unix()
+        messages = append(messages, msg)
+    }
+    return messages, rows.Err()
+}
@@ -0,0 +1,97 @@
+// internal/server/database/health.go
+package database
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"textual/internal/server/metrics"
+	"time"
+)
+
+const (
+    maxHealthBackoff = 30 * time.Second
+    pingTimeout      = 5 * time.Second
+)
+
+// ConfigurePool applies the connection pool limits, zero values keep the driver defaults
+func (db *DB) ConfigurePool(maxOpen, maxIdle int, maxLifetime time.Duration) {
+    if maxOpen > 0 {
+        db.SetMaxOpenConns(maxOpen)
+    }
+    if maxIdle > 0 {
+        db.SetMaxIdleConns(maxIdle)
+    }
+    if maxLifetime > 0 {
+        db.SetConnMaxLifetime(maxLifetime)
+    }
+}
+
+// Healthy reports whether the last health check succeeded
+func (db *DB) Healthy() bool {
+    return atomic.LoadInt32(&db.down) == 0
+}
+
+// MonitorHealth pings the database every interval. While the database is
+// unreachable it retries with exponential backoff, and onChange is called
+// each time the database goes down or comes back.
+func (db *DB) MonitorHealth(interval time.Duration, onChange func(healthy bool)) (stop func()) {
+    done := make(chan struct{})
+    metrics.DBUp.Set(1)
+
+    go func() {
+        delay := interval
+        for {
+            select {
+            case <-done:
+                return
+            case <-time.After(delay):
+            }
+
+            err := db.ping(pingTimeout)
+            stats := db.Stats()
+            metrics.DBOpenConns.Set(int64(stats.OpenConnections))
+            metrics.DBInUseConns.Set(int64(stats.InUse))
+
+            if err != nil {
+                metrics.DBPingFailures.Add(1)
+                if atomic.CompareAndSwapInt32(&db.down, 0, 1) {
+                    log.Printf("Database unavailable: %v", err)
+                    metrics.DBUp.Set(0)
+                    if onChange != nil {
+                        onChange(false)
+                    }
+                    delay = time.Second
+                } else {
+                    log.Printf("Database still unavailable (retrying in %s): %v", delay, err)
+                    delay *= 2
+                    if delay > maxHealthBackoff {
+                        delay = maxHealthBackoff
+                    }
+                }
+                continue
+            }
+
+            if atomic.CompareAndSwapInt32(&db.down, 1, 0) {
+                log.Printf("Database connection restored")
+                metrics.DBUp.Set(1)
+                if onChange != nil {
+                    onChange(true)
+                }
+            }
+            delay = interval
+        }
+    }()
+
+    return func() { close(done) }
+}
+
+func (db *DB) ping(timeout time.Duration) error {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    start := time.Now()
+    err := db.PingContext(ctx)
+    metrics.DBPingMillis.Set(time.Since(start).Milliseconds())
+    return err
+}
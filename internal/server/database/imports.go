@@ -0,0 +1,106 @@
+// internal/server/database/imports.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+)
+
+// ErrAccountTaken is returned when an imported author has the name of an
+// account that was not created by an import
+var ErrAccountTaken = errors.New("name taken by an account that was not imported")
+
+// GetOrCreateImportedUser returns the imported account named username,
+// creating it when missing. The accounts created have no password, like the
+// system account, so nobody logs in with them until an admin sets one. A
+// real account, or the system one, is never reused: ErrAccountTaken
+func (db *DB) GetOrCreateImportedUser(ctx context.Context, username string) (*models.User, bool, error) {
+    if !validUsername(username) {
+        return nil, false, fmt.Errorf("invalid username %q", username)
+    }
+    var user models.User
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO users (username, password_hash, status)
+        VALUES ($1, '!', 'offline')
+        ON CONFLICT (username) DO NOTHING
+        RETURNING id, username
+    `, username).Scan(&user.ID, &user.Username)
+    if err == nil {
+        return &user, true, nil
+    }
+    if err != sql.ErrNoRows {
+        return nil, false, err
+    }
+
+    err = db.QueryRowContext(ctx, `
+        SELECT id, username FROM users
+        WHERE username = $1 AND password_hash = '!' AND id::text <> $2
+    `, username, protocol.SystemUserID).Scan(&user.ID, &user.Username)
+    if err == sql.ErrNoRows {
+        return nil, false, ErrAccountTaken
+    }
+    if err != nil {
+        return nil, false, err
+    }
+    return &user, false, nil
+}
+
+// AccountTaken tells if username is the name of an account that was not
+// created by an import, the system one included
+func (db *DB) AccountTaken(ctx context.Context, username string) (bool, error) {
+    var taken bool
+    err := db.QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM users
+            WHERE username = $1 AND (password_hash <> '!' OR id::text = $2)
+        )
+    `, username, protocol.SystemUserID).Scan(&taken)
+    return taken, err
+}
+
+// FindImportedGroup returns the id of the oldest active group with this name
+// created by an import, or "" when there is none, so an import run again
+// fills the same group
+func (db *DB) FindImportedGroup(ctx context.Context, name string) (string, error) {
+    var id string
+    err := db.QueryRowContext(ctx, `
+        SELECT id FROM groups
+        WHERE name = $1 AND status = 'active' AND imported
+        ORDER BY created_at
+        LIMIT 1
+    `, name).Scan(&id)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    return id, err
+}
+
+// GroupTaken tells if name is the name of an active group that was not
+// created by an import
+func (db *DB) GroupTaken(ctx context.Context, name string) (bool, error) {
+    var taken bool
+    err := db.QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM groups
+            WHERE name = $1 AND status = 'active' AND NOT imported
+        )
+    `, name).Scan(&taken)
+    return taken, err
+}
+
+// CreateImportedGroup creates a group for an import, found again by
+// FindImportedGroup
+func (db *DB) CreateImportedGroup(ctx context.Context, name, description, creatorID string) (*models.Group, error) {
+    group, err := db.CreateGroup(ctx, name, description, creatorID, false, false, false)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := db.ExecContext(ctx, `UPDATE groups SET imported = TRUE WHERE id = $1`, group.ID); err != nil {
+        return nil, fmt.Errorf("failed to mark group imported: %v", err)
+    }
+    return group, nil
+}
@@ -0,0 +1,111 @@
+// internal/server/database/instrument.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"textual/internal/server/metrics"
+	"time"
+)
+
+// The methods below shadow the ones of the embedded *sql.DB so that every
+// query issued by the storage layer is timed and slow ones are logged. They
+// take the context of the request, its timeout stops the query
+
+// SetSlowQueryThreshold sets the duration above which queries are logged, zero disables logging
+func (db *DB) SetSlowQueryThreshold(threshold time.Duration) {
+    db.slowQuery = threshold
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+    start := time.Now()
+    rows, err := db.DB.QueryContext(ctx, query, args...)
+    db.observe(start, args, err)
+    return rows, err
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+    start := time.Now()
+    row := db.DB.QueryRowContext(ctx, query, args...)
+    db.observe(start, args, row.Err())
+    return row
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+    start := time.Now()
+    result, err := db.DB.ExecContext(ctx, query, args...)
+    db.observe(start, args, err)
+    return result, err
+}
+
+// execTx runs a statement of a transaction, timed like the other queries
+func (db *DB) execTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+    start := time.Now()
+    result, err := tx.ExecContext(ctx, query, args...)
+    db.observe(start, args, err)
+    return result, err
+}
+
+// queryRowTx reads a row in a transaction, timed like the other queries
+func (db *DB) queryRowTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+    start := time.Now()
+    row := tx.QueryRowContext(ctx, query, args...)
+    db.observe(start, args, row.Err())
+    return row
+}
+
+func (db *DB) observe(start time.Time, args []interface{}, err error) {
+    elapsed := time.Since(start)
+    name := queryName()
+
+    metrics.DBQueryDurations.Observe(name, elapsed)
+    if err != nil && err != sql.ErrNoRows {
+        metrics.DBQueryErrors.Add(name, 1)
+    }
+
+    if db.slowQuery > 0 && elapsed > db.slowQuery {
+        log.Printf("Slow query %s took %s (args: %s)", name, elapsed, redactArgs(args))
+    }
+}
+
+// queryName returns the name of the DB method that issued the query
+func queryName() string {
+    // 0: queryName, 1: observe, 2: QueryContext/QueryRowContext/ExecContext/execTx/queryRowTx, 3: caller
+    pc, _, _, ok := runtime.Caller(3)
+    if !ok {
+        return "unknown"
+    }
+
+    fn := runtime.FuncForPC(pc)
+    if fn == nil {
+        return "unknown"
+    }
+
+    name := fn.Name()
+    if i := strings.LastIndex(name, "."); i >= 0 {
+        name = name[i+1:]
+    }
+    return name
+}
+
+// redactArgs describes query parameters without their values
+func redactArgs(args []interface{}) string {
+    parts := make([]string, 0, len(args))
+    for i, arg := range args {
+        switch v := arg.(type) {
+        case nil:
+            parts = append(parts, fmt.Sprintf("$%d=nil", i+1))
+        case string:
+            parts = append(parts, fmt.Sprintf("$%d=string(%d)", i+1, len(v)))
+        case []byte:
+            parts = append(parts, fmt.Sprintf("$%d=bytes(%d)", i+1, len(v)))
+        default:
+            parts = append(parts, fmt.Sprintf("$%d=%T", i+1, v))
+        }
+    }
+    return "[" + strings.Join(parts, " ") + "]"
+}
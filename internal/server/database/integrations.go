@@ -0,0 +1,84 @@
+// internal/server/database/integrations.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"textual/internal/server/models"
+)
+
+// ErrIntegrationNotFound is returned for an unknown or deleted integration
+var ErrIntegrationNotFound = fmt.Errorf("integration not found")
+
+// CreateIntegration stores an integration, its ID and creation time are set
+// by the database
+func (db *DB) CreateIntegration(ctx context.Context, integration *models.Integration) error {
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO group_integrations (group_id, kind, secret, created_by)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `, integration.GroupID, integration.Kind, integration.Secret, integration.CreatedBy).Scan(&integration.ID, &integration.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to create integration: %v", err)
+    }
+    return nil
+}
+
+// GetIntegration returns an integration with its secret
+func (db *DB) GetIntegration(ctx context.Context, id string) (*models.Integration, error) {
+    var integration models.Integration
+    var createdBy sql.NullString
+    err := db.QueryRowContext(ctx, `
+        SELECT id, group_id, kind, secret, created_by, created_at
+        FROM group_integrations
+        WHERE id::text = $1
+    `, id).Scan(&integration.ID, &integration.GroupID, &integration.Kind, &integration.Secret, &createdBy, &integration.CreatedAt)
+    if err == sql.ErrNoRows {
+        return nil, ErrIntegrationNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get integration: %v", err)
+    }
+    integration.CreatedBy = createdBy.String
+    return &integration, nil
+}
+
+// GetGroupIntegrations returns the integrations of a group, oldest first
+func (db *DB) GetGroupIntegrations(ctx context.Context, groupID string) ([]models.Integration, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, group_id, kind, created_by, created_at
+        FROM group_integrations
+        WHERE group_id = $1
+        ORDER BY created_at
+    `, groupID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get integrations: %v", err)
+    }
+    defer rows.Close()
+
+    var integrations []models.Integration
+    for rows.Next() {
+        var integration models.Integration
+        var createdBy sql.NullString
+        if err := rows.Scan(&integration.ID, &integration.GroupID, &integration.Kind, &createdBy, &integration.CreatedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan integration: %v", err)
+        }
+        integration.CreatedBy = createdBy.String
+        integrations = append(integrations, integration)
+    }
+    return integrations, rows.Err()
+}
+
+// DeleteIntegration removes an integration of a group, it returns false when
+// the group has none with this ID
+func (db *DB) DeleteIntegration(ctx context.Context, groupID, id string) (bool, error) {
+    result, err := db.ExecContext(ctx, `
+        DELETE FROM group_integrations WHERE id::text = $1 AND group_id = $2
+    `, id, groupID)
+    if err != nil {
+        return false, fmt.Errorf("failed to delete integration: %v", err)
+    }
+    n, err := result.RowsAffected()
+    return n > 0, err
+}
@@ -0,0 +1,1054 @@
+// internal/server/database/memory.go
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Memory is a Store kept in the process, lost when it stops: the tests run
+// on it without a database, the standalone client embeds it. It answers as
+// the queries of DB do, the rows in the order they were written when the
+// queries leave it open
+type Memory struct {
+    mu     sync.Mutex
+    quotas Quotas
+
+    users      map[string]*memUser
+    userOrder  []string
+    usernames  map[string]string // username to id, the names are unique
+    friends    []*memFriend
+    groups     map[string]*memGroup
+    groupOrder []string
+    members    []*memMember
+    topics     []memTopic
+
+    messages     []*models.Message
+    messageByID  map[string]*models.Message
+    revisions    []models.MessageRevision
+    voices       map[string]models.Voice
+    previews     map[string]models.LinkPreview
+    attachments  map[string]models.Attachment
+    archived     []memArchived
+    mentions     []memMention
+
+    notifications []models.Notification
+    levels        map[[2]string]string
+    reminders     []models.Reminder
+    tokens        []*memToken
+    integrations  []models.Integration
+
+    devices   []*memDevice
+    prekeys   []memPreKey
+    encrypted []memEncrypted
+}
+
+type memUser struct {
+    user  models.User
+    prefs models.DisplayPrefs
+}
+
+type memFriend struct {
+    user1, user2 string
+    status       string
+    createdAt    time.Time
+    updatedAt    *time.Time
+}
+
+type memGroup struct {
+    group    models.Group
+    imported bool
+}
+
+type memMember struct {
+    groupID, userID string
+    role            string
+    joinedAt        time.Time
+}
+
+type memTopic struct {
+    groupID string
+    topic   string
+    setBy   string
+    setAt   time.Time
+}
+
+// NewMemory returns an empty store holding the system account, as the
+// migrations leave the database
+func NewMemory() *Memory {
+    m := &Memory{
+        users:       make(map[string]*memUser),
+        usernames:   make(map[string]string),
+        groups:      make(map[string]*memGroup),
+        messageByID: make(map[string]*models.Message),
+        voices:      make(map[string]models.Voice),
+        previews:    make(map[string]models.LinkPreview),
+        attachments: make(map[string]models.Attachment),
+        levels:      make(map[[2]string]string),
+    }
+    m.addUser(models.User{
+        ID:           protocol.SystemUserID,
+        Username:     protocol.SystemUsername,
+        PasswordHash: "!",
+        Status:       models.StatusOffline,
+        CreatedAt:    time.Now(),
+    })
+    return m
+}
+
+// newID returns a random UUID, as the database sets them
+func newID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        panic(err)
+    }
+    b[6] = b[6]&0x0f | 0x40
+    b[8] = b[8]&0x3f | 0x80
+    h := hex.EncodeToString(b)
+    return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func copyString(s *string) *string {
+    if s == nil {
+        return nil
+    }
+    c := *s
+    return &c
+}
+
+func copyTime(t *time.Time) *time.Time {
+    if t == nil {
+        return nil
+    }
+    c := *t
+    return &c
+}
+
+// the usernames are VARCHAR(50)
+const maxUsernameLength = 50
+
+func (m *Memory) addUser(user models.User) *memUser {
+    u := &memUser{user: user}
+    m.users[user.ID] = u
+    m.userOrder = append(m.userOrder, user.ID)
+    m.usernames[user.Username] = user.ID
+    return u
+}
+
+func (m *Memory) username(id string) (string, bool) {
+    if u, ok := m.users[id]; ok {
+        return u.user.Username, true
+    }
+    return "", false
+}
+
+// statusText is the custom status of a user, empty once expired
+func (u *memUser) statusText() (string, *time.Time) {
+    if u.user.StatusExpiresAt != nil && !u.user.StatusExpiresAt.After(time.Now()) {
+        return "", nil
+    }
+    if u.user.StatusText == "" {
+        return "", nil
+    }
+    return u.user.StatusText, copyTime(u.user.StatusExpiresAt)
+}
+
+// lifecycle, nothing to connect to
+
+func (m *Memory) ConfigurePool(maxOpen, maxIdle int, maxLifetime time.Duration) {}
+
+func (m *Memory) Healthy() bool {
+    return true
+}
+
+func (m *Memory) MonitorHealth(interval time.Duration, onChange func(healthy bool)) (stop func()) {
+    return func() {}
+}
+
+func (m *Memory) SetSlowQueryThreshold(threshold time.Duration) {}
+
+// StartPresenceWriter has nothing to batch, the statuses are written at once
+func (m *Memory) StartPresenceWriter(interval time.Duration) (stop func()) {
+    return func() {}
+}
+
+func (m *Memory) SetQuotas(quotas Quotas) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.quotas = quotas
+}
+
+func (m *Memory) Close() error {
+    return nil
+}
+
+// users
+
+// userNamed returns the user named username with its hash, bcrypt runs
+// without holding the lock
+func (m *Memory) userNamed(username string) (models.User, bool) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    id, ok := m.usernames[username]
+    if !ok {
+        return models.User{}, false
+    }
+    return m.users[id].user, true
+}
+
+func (m *Memory) AuthenticateUser(ctx context.Context, username, password string) (*models.User, error) {
+    user, found := m.userNamed(username)
+    if found {
+        if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+            return nil, fmt.Errorf("invalid password")
+        }
+    } else {
+        if strings.EqualFold(username, protocol.SystemUsername) {
+            return nil, fmt.Errorf("username reserved")
+        }
+        if !validUsername(username) {
+            return nil, fmt.Errorf("invalid username")
+        }
+        if len([]rune(username)) > maxUsernameLength {
+            return nil, fmt.Errorf("error creating user: value too long for type character varying(%d)", maxUsernameLength)
+        }
+        hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+        if err != nil {
+            return nil, fmt.Errorf("error hashing password: %v", err)
+        }
+        user = models.User{
+            ID:           newID(),
+            Username:     username,
+            PasswordHash: string(hashedBytes),
+            Status:       models.StatusOffline,
+            LastSeen:     time.Now(),
+            CreatedAt:    time.Now(),
+        }
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    u, ok := m.users[user.ID]
+    if !found {
+        // created by another login while hashing
+        if _, taken := m.usernames[username]; taken {
+            return nil, fmt.Errorf("username already taken")
+        }
+        u, ok = m.addUser(user), true
+    }
+    if !ok {
+        return nil, fmt.Errorf("user not found")
+    }
+    u.user.LastLogin = time.Now()
+
+    return &models.User{ID: u.user.ID, Username: u.user.Username, Status: u.user.Status, LastSeen: u.user.LastSeen}, nil
+}
+
+func (m *Memory) VerifyPassword(ctx context.Context, username, password string) (*models.User, error) {
+    user, ok := m.userNamed(username)
+    if !ok || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+        return nil, ErrInvalidCredentials
+    }
+    return &models.User{ID: user.ID, Username: user.Username, Status: user.Status}, nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, userID string) (*models.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.getUser(userID)
+}
+
+func (m *Memory) getUser(userID string) (*models.User, error) {
+    u, ok := m.users[userID]
+    if !ok {
+        return nil, fmt.Errorf("user not found")
+    }
+    return &models.User{ID: u.user.ID, Username: u.user.Username, Status: u.user.Status, LastSeen: u.user.LastSeen}, nil
+}
+
+func (m *Memory) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, id := range m.userOrder {
+        u := m.users[id]
+        if strings.ToLower(u.user.Username) == strings.ToLower(username) {
+            return &models.User{ID: u.user.ID, Username: u.user.Username, Status: u.user.Status, LastSeen: u.user.LastSeen}, nil
+        }
+    }
+    return nil, fmt.Errorf("user not found: %s", username)
+}
+
+func (m *Memory) SearchUsers(ctx context.Context, userID, query string, limit int) ([]models.UserSummary, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    prefix := strings.ToLower(query)
+    var found []*memUser
+    for _, id := range m.userOrder {
+        u := m.users[id]
+        if id == userID || u.user.PasswordHash == "!" {
+            continue
+        }
+        if query == "" && u.user.Status == models.StatusOffline {
+            continue
+        }
+        if query != "" && !strings.HasPrefix(strings.ToLower(u.user.Username), prefix) {
+            continue
+        }
+        if m.friendship(userID, id, models.FriendStatusBlocked) != nil {
+            continue
+        }
+        found = append(found, u)
+    }
+    sort.SliceStable(found, func(i, j int) bool {
+        oi, oj := found[i].user.Status == models.StatusOffline, found[j].user.Status == models.StatusOffline
+        if oi != oj {
+            return oj
+        }
+        return strings.ToLower(found[i].user.Username) < strings.ToLower(found[j].user.Username)
+    })
+
+    var users []models.UserSummary
+    for _, u := range found {
+        if len(users) == limit {
+            break
+        }
+        text, _ := u.statusText()
+        users = append(users, models.UserSummary{
+            ID:         u.user.ID,
+            Username:   u.user.Username,
+            Status:     u.user.Status,
+            StatusText: text,
+            IsFriend:   m.friendship(userID, u.user.ID, models.FriendStatusAccepted) != nil,
+        })
+    }
+    return users, nil
+}
+
+// QueueUserStatus writes the status at once, there is nothing to batch
+func (m *Memory) QueueUserStatus(ctx context.Context, userID, status string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    u, ok := m.users[userID]
+    if !ok {
+        return fmt.Errorf("user not found")
+    }
+    u.user.Status = status
+    u.user.LastSeen = time.Now()
+    return nil
+}
+
+func (m *Memory) UpdateUserStatusText(ctx context.Context, userID, text string, expiresAt *time.Time) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if u, ok := m.users[userID]; ok {
+        u.user.StatusText = text
+        u.user.StatusExpiresAt = copyTime(expiresAt)
+    }
+    return nil
+}
+
+func (m *Memory) GetStatusText(ctx context.Context, userID string) (string, *time.Time, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    u, ok := m.users[userID]
+    if !ok {
+        return "", nil, fmt.Errorf("failed to get status text: %v", sql.ErrNoRows)
+    }
+    text, expiresAt := u.statusText()
+    return text, expiresAt, nil
+}
+
+func (m *Memory) SetDisplayPrefs(ctx context.Context, userID string, prefs models.DisplayPrefs) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if u, ok := m.users[userID]; ok {
+        u.prefs = prefs
+    }
+    return nil
+}
+
+func (m *Memory) GetDisplayPrefs(ctx context.Context, userID string) (models.DisplayPrefs, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    u, ok := m.users[userID]
+    if !ok {
+        return models.DisplayPrefs{}, fmt.Errorf("failed to get display prefs: %v", sql.ErrNoRows)
+    }
+    return u.prefs, nil
+}
+
+func (m *Memory) CheckSystemUser(ctx context.Context) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    name, ok := m.username(protocol.SystemUserID)
+    if !ok {
+        return fmt.Errorf("system account %s missing", protocol.SystemUserID)
+    }
+    if name != protocol.SystemUsername {
+        return fmt.Errorf("system account %s is named %q instead of %q", protocol.SystemUserID, name, protocol.SystemUsername)
+    }
+    return nil
+}
+
+// friends and blocks
+
+// friendship returns the row between two users with this status, in either
+// direction
+func (m *Memory) friendship(userID1, userID2, status string) *memFriend {
+    for _, f := range m.friends {
+        if f.status != status {
+            continue
+        }
+        if (f.user1 == userID1 && f.user2 == userID2) || (f.user1 == userID2 && f.user2 == userID1) {
+            return f
+        }
+    }
+    return nil
+}
+
+func (m *Memory) friendIDs(userID string) []string {
+    var ids []string
+    for _, f := range m.friends {
+        if f.status != models.FriendStatusAccepted {
+            continue
+        }
+        if f.user1 == userID {
+            ids = append(ids, f.user2)
+        } else if f.user2 == userID {
+            ids = append(ids, f.user1)
+        }
+    }
+    return ids
+}
+
+// checkFriendQuota tells if each user can have one more friend
+func (m *Memory) checkFriendQuota(userIDs ...string) error {
+    limit := m.quotas.FriendsPerUser
+    if limit <= 0 {
+        return nil
+    }
+    for _, userID := range userIDs {
+        if len(m.friendIDs(userID)) >= limit {
+            return &QuotaError{Quota: QuotaFriendsPerUser, Limit: limit, UserID: userID}
+        }
+    }
+    return nil
+}
+
+func (m *Memory) GetFriends(ctx context.Context, userID string) ([]models.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var friends []models.User
+    for _, id := range m.friendIDs(userID) {
+        u, ok := m.users[id]
+        if !ok {
+            continue
+        }
+        text, expiresAt := u.statusText()
+        friends = append(friends, models.User{
+            ID:              u.user.ID,
+            Username:        u.user.Username,
+            Status:          u.user.Status,
+            LastSeen:        u.user.LastSeen,
+            StatusText:      text,
+            StatusExpiresAt: expiresAt,
+        })
+    }
+    return friends, nil
+}
+
+func (m *Memory) GetFriendList(ctx context.Context, userID string) ([]string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.friendIDs(userID), nil
+}
+
+func (m *Memory) CreateFriendRequest(ctx context.Context, fromUserID, toUserID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if err := m.checkFriendQuota(fromUserID, toUserID); err != nil {
+        return err
+    }
+    for _, f := range m.friends {
+        if f.user1 == fromUserID && f.user2 == toUserID {
+            if f.status == models.FriendStatusRejected {
+                now := time.Now()
+                f.status = models.FriendStatusPending
+                f.updatedAt = &now
+            }
+            return nil
+        }
+    }
+    if _, ok := m.users[fromUserID]; !ok {
+        return fmt.Errorf("user %s not found", fromUserID)
+    }
+    if _, ok := m.users[toUserID]; !ok {
+        return fmt.Errorf("user %s not found", toUserID)
+    }
+    m.friends = append(m.friends, &memFriend{user1: fromUserID, user2: toUserID, status: models.FriendStatusPending, createdAt: time.Now()})
+    return nil
+}
+
+// setFriendStatus moves the pending rows between two users to status
+func (m *Memory) setFriendStatus(userID1, userID2, status string) error {
+    changed := false
+    for _, f := range m.friends {
+        if f.status != models.FriendStatusPending {
+            continue
+        }
+        if (f.user1 == userID1 && f.user2 == userID2) || (f.user1 == userID2 && f.user2 == userID1) {
+            now := time.Now()
+            f.status = status
+            f.updatedAt = &now
+            changed = true
+        }
+    }
+    if !changed {
+        return fmt.Errorf("no pending friend request found")
+    }
+    return nil
+}
+
+func (m *Memory) AcceptFriendRequest(ctx context.Context, userID1, userID2 string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    // other requests may have been accepted since this one was sent
+    if err := m.checkFriendQuota(userID1, userID2); err != nil {
+        return err
+    }
+    return m.setFriendStatus(userID1, userID2, models.FriendStatusAccepted)
+}
+
+func (m *Memory) RejectFriendRequest(ctx context.Context, fromUserID, toUserID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.setFriendStatus(fromUserID, toUserID, models.FriendStatusRejected)
+}
+
+func (m *Memory) FriendRequestRejectedAt(ctx context.Context, fromUserID, toUserID string) (time.Time, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, f := range m.friends {
+        if f.user1 == fromUserID && f.user2 == toUserID && f.status == models.FriendStatusRejected {
+            if f.updatedAt != nil {
+                return *f.updatedAt, nil
+            }
+            return f.createdAt, nil
+        }
+    }
+    return time.Time{}, nil
+}
+
+func (m *Memory) GetFriendRequestUsers(ctx context.Context, requestID string) (*models.User, *models.User, error) {
+    // the request ID is "fr:{fromID}:{toID}:{timestamp}"
+    parts := strings.Split(requestID, ":")
+    if len(parts) != 4 || parts[0] != "fr" {
+        return nil, nil, fmt.Errorf("invalid request ID format")
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    fromUser, err := m.getUser(parts[1])
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to get sender: %v", err)
+    }
+    toUser, err := m.getUser(parts[2])
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to get recipient: %v", err)
+    }
+    return fromUser, toUser, nil
+}
+
+func (m *Memory) GetPendingFriendRequests(ctx context.Context, userID string) ([]models.FriendRequest, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var requests []models.FriendRequest
+    for _, f := range m.friends {
+        if f.user2 != userID || f.status != models.FriendStatusPending {
+            continue
+        }
+        fromName, ok1 := m.username(f.user1)
+        toName, ok2 := m.username(f.user2)
+        if !ok1 || !ok2 {
+            continue
+        }
+        requests = append(requests, models.FriendRequest{
+            FromUserID:   f.user1,
+            ToUserID:     f.user2,
+            CreatedAt:    f.createdAt,
+            FromUsername: fromName,
+            ToUsername:   toName,
+        })
+    }
+    return requests, nil
+}
+
+func (m *Memory) RemoveFriend(ctx context.Context, userID1, userID2 string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.friendship(userID1, userID2, models.FriendStatusAccepted) == nil {
+        return fmt.Errorf("friend relationship not found")
+    }
+    m.removeFriends(func(row *memFriend) bool { return row.status == models.FriendStatusAccepted && m.between(row, userID1, userID2) })
+    return nil
+}
+
+func (m *Memory) between(f *memFriend, userID1, userID2 string) bool {
+    return (f.user1 == userID1 && f.user2 == userID2) || (f.user1 == userID2 && f.user2 == userID1)
+}
+
+func (m *Memory) removeFriends(drop func(*memFriend) bool) {
+    kept := m.friends[:0]
+    for _, f := range m.friends {
+        if !drop(f) {
+            kept = append(kept, f)
+        }
+    }
+    m.friends = kept
+}
+
+func (m *Memory) BlockUser(ctx context.Context, userID, blockedUserID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.removeFriends(func(f *memFriend) bool { return m.between(f, userID, blockedUserID) })
+    m.friends = append(m.friends, &memFriend{user1: userID, user2: blockedUserID, status: models.FriendStatusBlocked, createdAt: time.Now()})
+    return nil
+}
+
+func (m *Memory) IsBlocked(ctx context.Context, userID1, userID2 string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.friendship(userID1, userID2, models.FriendStatusBlocked) != nil, nil
+}
+
+func (m *Memory) HasBlocked(ctx context.Context, blockerID, userID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, f := range m.friends {
+        if f.user1 == blockerID && f.user2 == userID && f.status == models.FriendStatusBlocked {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// groups
+
+// checkGroupQuotas tells if userID can join groupID, an empty groupID only
+// checks the groups of the user
+func (m *Memory) checkGroupQuotas(userID, groupID string) error {
+    if limit := m.quotas.GroupsPerUser; limit > 0 {
+        count := 0
+        for _, member := range m.members {
+            if g, ok := m.groups[member.groupID]; member.userID == userID && ok && g.group.Status != models.GroupStatusDeleted {
+                count++
+            }
+        }
+        if count >= limit {
+            return &QuotaError{Quota: QuotaGroupsPerUser, Limit: limit, UserID: userID}
+        }
+    }
+
+    if limit := m.quotas.MembersPerGroup; limit > 0 && groupID != "" {
+        if len(m.memberIDs(groupID, "")) >= limit {
+            return &QuotaError{Quota: QuotaMembersPerGroup, Limit: limit}
+        }
+    }
+    return nil
+}
+
+// memberIDs returns the members of a group, only those with role when set
+func (m *Memory) memberIDs(groupID, role string) []string {
+    var ids []string
+    for _, member := range m.members {
+        if member.groupID == groupID && (role == "" || member.role == role) {
+            ids = append(ids, member.userID)
+        }
+    }
+    return ids
+}
+
+func (m *Memory) member(userID, groupID string) *memMember {
+    for _, member := range m.members {
+        if member.groupID == groupID && member.userID == userID {
+            return member
+        }
+    }
+    return nil
+}
+
+func (m *Memory) CreateGroup(ctx context.Context, name, description, creatorID string, public, encrypted, announcement bool) (*models.Group, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.createGroup(name, description, creatorID, public, encrypted, announcement)
+}
+
+func (m *Memory) createGroup(name, description, creatorID string, public, encrypted, announcement bool) (*models.Group, error) {
+    if err := m.checkGroupQuotas(creatorID, ""); err != nil {
+        return nil, err
+    }
+    if _, ok := m.users[creatorID]; !ok {
+        return nil, fmt.Errorf("user %s not found", creatorID)
+    }
+    g := &memGroup{group: models.Group{
+        ID:           newID(),
+        Name:         name,
+        Description:  description,
+        CreatedBy:    creatorID,
+        CreatedAt:    time.Now(),
+        Status:       models.GroupStatusActive,
+        Public:       public,
+        Encrypted:    encrypted,
+        Announcement: announcement,
+    }}
+    m.groups[g.group.ID] = g
+    m.groupOrder = append(m.groupOrder, g.group.ID)
+    m.members = append(m.members, &memMember{groupID: g.group.ID, userID: creatorID, role: models.GroupRoleAdmin, joinedAt: g.group.CreatedAt})
+
+    group := g.group
+    group.Status = ""
+    group.Members = []string{creatorID}
+    if announcement {
+        group.Posters = []string{creatorID}
+    }
+    return &group, nil
+}
+
+// withMembers returns a copy of a group with its members and posters
+func (m *Memory) withMembers(g *memGroup) models.Group {
+    group := g.group
+    if group.Announcement {
+        group.Posters = m.memberIDs(group.ID, models.GroupRoleAdmin)
+    }
+    group.Members = m.memberIDs(group.ID, "")
+    return group
+}
+
+func (m *Memory) GetGroup(ctx context.Context, groupID string) (*models.Group, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    g, ok := m.groups[groupID]
+    if !ok || g.group.Status == models.GroupStatusDeleted {
+        return nil, sql.ErrNoRows
+    }
+    group := m.withMembers(g)
+    group.Status = ""
+    return &group, nil
+}
+
+func (m *Memory) GetUserGroups(ctx context.Context, userID string) ([]models.Group, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var groups []models.Group
+    for _, id := range m.groupOrder {
+        g := m.groups[id]
+        if g.group.Status == models.GroupStatusDeleted || m.member(userID, id) == nil {
+            continue
+        }
+        groups = append(groups, m.withMembers(g))
+    }
+    return groups, nil
+}
+
+func (m *Memory) GetPublicGroups(ctx context.Context, userID string, limit int) ([]models.GroupSummary, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var groups []models.GroupSummary
+    for _, id := range m.groupOrder {
+        g := m.groups[id]
+        if !g.group.Public || g.group.Status != models.GroupStatusActive {
+            continue
+        }
+        groups = append(groups, models.GroupSummary{
+            ID:           id,
+            Name:         g.group.Name,
+            Description:  g.group.Description,
+            MemberCount:  len(m.memberIDs(id, "")),
+            IsMember:     m.member(userID, id) != nil,
+            Announcement: g.group.Announcement,
+        })
+    }
+    sort.SliceStable(groups, func(i, j int) bool {
+        if groups[i].MemberCount != groups[j].MemberCount {
+            return groups[i].MemberCount > groups[j].MemberCount
+        }
+        return groups[i].Name < groups[j].Name
+    })
+    if len(groups) > limit {
+        groups = groups[:limit]
+    }
+    return groups, nil
+}
+
+func (m *Memory) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.memberIDs(groupID, ""), nil
+}
+
+func (m *Memory) GetGroupMemberDetails(ctx context.Context, groupID string) ([]models.GroupMember, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var members []models.GroupMember
+    for _, member := range m.members {
+        u, ok := m.users[member.userID]
+        if member.groupID != groupID || !ok {
+            continue
+        }
+        members = append(members, models.GroupMember{
+            GroupID:  groupID,
+            UserID:   member.userID,
+            Username: u.user.Username,
+            Role:     member.role,
+            Status:   u.user.Status,
+            JoinedAt: member.joinedAt,
+        })
+    }
+    // admins first
+    sort.SliceStable(members, func(i, j int) bool {
+        ai, aj := members[i].Role == models.GroupRoleAdmin, members[j].Role == models.GroupRoleAdmin
+        if ai != aj {
+            return ai
+        }
+        return members[i].Username < members[j].Username
+    })
+    return members, nil
+}
+
+func (m *Memory) AddUserToGroup(ctx context.Context, userID, groupID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.member(userID, groupID) != nil {
+        return nil
+    }
+    if err := m.checkGroupQuotas(userID, groupID); err != nil {
+        return err
+    }
+    if _, ok := m.groups[groupID]; !ok {
+        return fmt.Errorf("group %s not found", groupID)
+    }
+    if _, ok := m.users[userID]; !ok {
+        return fmt.Errorf("user %s not found", userID)
+    }
+    m.members = append(m.members, &memMember{groupID: groupID, userID: userID, role: models.GroupRoleMember, joinedAt: time.Now()})
+    return nil
+}
+
+func (m *Memory) RemoveUserFromGroup(ctx context.Context, userID, groupID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    g, ok := m.groups[groupID]
+    member := m.member(userID, groupID)
+    if member == nil || (ok && g.group.CreatedBy == userID) {
+        return fmt.Errorf("user not found in group or is the group creator")
+    }
+    kept := m.members[:0]
+    for _, other := range m.members {
+        if other != member {
+            kept = append(kept, other)
+        }
+    }
+    m.members = kept
+    return nil
+}
+
+func (m *Memory) IsGroupMember(ctx context.Context, userID, groupID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.member(userID, groupID) != nil, nil
+}
+
+func (m *Memory) IsAnnouncementGroup(ctx context.Context, groupID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    g, ok := m.groups[groupID]
+    if !ok {
+        return false, sql.ErrNoRows
+    }
+    return g.group.Announcement, nil
+}
+
+func (m *Memory) IsGroupEncrypted(ctx context.Context, groupID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    g, ok := m.groups[groupID]
+    if !ok {
+        return false, sql.ErrNoRows
+    }
+    return g.group.Encrypted, nil
+}
+
+func (m *Memory) GetGroupRole(ctx context.Context, userID, groupID string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    member := m.member(userID, groupID)
+    if member == nil {
+        return "", fmt.Errorf("user not found in group")
+    }
+    return member.role, nil
+}
+
+func (m *Memory) UpdateGroupRole(ctx context.Context, userID, groupID, newRole string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    g, ok := m.groups[groupID]
+    if !ok {
+        return fmt.Errorf("failed to get group creator: %v", sql.ErrNoRows)
+    }
+    if userID == g.group.CreatedBy {
+        return fmt.Errorf("cannot change role of group creator")
+    }
+    member := m.member(userID, groupID)
+    if member == nil {
+        return fmt.Errorf("user not found in group")
+    }
+    member.role = newRole
+    return nil
+}
+
+func (m *Memory) SetGroupTopic(ctx context.Context, groupID, topic, userID string) (models.TopicChange, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    g, ok := m.groups[groupID]
+    if !ok {
+        return models.TopicChange{}, fmt.Errorf("failed to record topic change: group %s not found", groupID)
+    }
+    g.group.Topic = topic
+    t := memTopic{groupID: groupID, topic: topic, setBy: userID, setAt: time.Now()}
+    m.topics = append(m.topics, t)
+    return m.topicChange(t), nil
+}
+
+func (m *Memory) GetTopicChanges(ctx context.Context, groupID string, limit int) ([]models.TopicChange, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var changes []models.TopicChange
+    for i := len(m.topics) - 1; i >= 0 && len(changes) < limit; i-- {
+        if t := m.topics[i]; t.groupID == groupID {
+            changes = append(changes, m.topicChange(t))
+        }
+    }
+    return changes, nil
+}
+
+func (m *Memory) topicChange(t memTopic) models.TopicChange {
+    setBy, _ := m.username(t.setBy)
+    return models.TopicChange{Topic: t.topic, SetBy: setBy, SetAt: t.setAt}
+}
+
+// imports
+
+func (m *Memory) GetOrCreateImportedUser(ctx context.Context, username string) (*models.User, bool, error) {
+    if !validUsername(username) {
+        return nil, false, fmt.Errorf("invalid username %q", username)
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    id, ok := m.usernames[username]
+    if !ok {
+        if len([]rune(username)) > maxUsernameLength {
+            return nil, false, fmt.Errorf("value too long for type character varying(%d)", maxUsernameLength)
+        }
+        u := m.addUser(models.User{
+            ID:           newID(),
+            Username:     username,
+            PasswordHash: "!",
+            Status:       models.StatusOffline,
+            CreatedAt:    time.Now(),
+        })
+        return &models.User{ID: u.user.ID, Username: u.user.Username}, true, nil
+    }
+    u := m.users[id]
+    if u.user.PasswordHash != "!" || id == protocol.SystemUserID {
+        return nil, false, ErrAccountTaken
+    }
+    return &models.User{ID: u.user.ID, Username: u.user.Username}, false, nil
+}
+
+func (m *Memory) AccountTaken(ctx context.Context, username string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    id, ok := m.usernames[username]
+    return ok && (m.users[id].user.PasswordHash != "!" || id == protocol.SystemUserID), nil
+}
+
+func (m *Memory) FindImportedGroup(ctx context.Context, name string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, id := range m.groupOrder {
+        if g := m.groups[id]; g.imported && g.group.Name == name && g.group.Status == models.GroupStatusActive {
+            return id, nil
+        }
+    }
+    return "", nil
+}
+
+func (m *Memory) GroupTaken(ctx context.Context, name string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, id := range m.groupOrder {
+        if g := m.groups[id]; !g.imported && g.group.Name == name && g.group.Status == models.GroupStatusActive {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+func (m *Memory) CreateImportedGroup(ctx context.Context, name, description, creatorID string) (*models.Group, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    group, err := m.createGroup(name, description, creatorID, false, false, false)
+    if err != nil {
+        return nil, err
+    }
+    m.groups[group.ID].imported = true
+    return group, nil
+}
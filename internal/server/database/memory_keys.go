@@ -0,0 +1,290 @@
+// internal/server/database/memory_keys.go
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+type memToken struct {
+    id, userID, name, hash string
+    createdAt              time.Time
+    lastUsedAt             *time.Time
+}
+
+type memDevice struct {
+    userID    string
+    bundle    protocol.DeviceBundle
+    updatedAt time.Time
+}
+
+type memPreKey struct {
+    userID, deviceID string
+    key              protocol.OneTimePreKey
+}
+
+// memEncrypted is a queued message, its envelopes encoded as the database
+// keeps them
+type memEncrypted struct {
+    msg       protocol.EncryptedMessagePayload
+    envelopes []byte
+    sentAt    time.Time
+}
+
+// API tokens
+
+func (m *Memory) CreateAPIToken(ctx context.Context, userID, name, tokenHash string) (string, time.Time, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.users[userID]; !ok {
+        return "", time.Time{}, fmt.Errorf("failed to create API token: user %s not found", userID)
+    }
+    t := &memToken{id: newID(), userID: userID, name: name, hash: tokenHash, createdAt: time.Now()}
+    m.tokens = append(m.tokens, t)
+    return t.id, t.createdAt, nil
+}
+
+func (m *Memory) GetAPITokenUser(ctx context.Context, tokenHash string) (*models.User, string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, t := range m.tokens {
+        u, ok := m.users[t.userID]
+        if t.hash != tokenHash || !ok {
+            continue
+        }
+        now := time.Now()
+        t.lastUsedAt = &now
+        return &models.User{ID: u.user.ID, Username: u.user.Username, Status: u.user.Status}, t.id, nil
+    }
+    return nil, "", ErrInvalidToken
+}
+
+// removeTokens drops the tokens matched, it returns how many there were
+func (m *Memory) removeTokens(drop func(*memToken) bool) int64 {
+    var removed int64
+    kept := m.tokens[:0]
+    for _, t := range m.tokens {
+        if drop(t) {
+            removed++
+            continue
+        }
+        kept = append(kept, t)
+    }
+    m.tokens = kept
+    return removed
+}
+
+func (m *Memory) DeleteUserAPITokens(ctx context.Context, userID string) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.removeTokens(func(t *memToken) bool { return t.userID == userID }), nil
+}
+
+func (m *Memory) DeleteAPIToken(ctx context.Context, userID, tokenID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.removeTokens(func(t *memToken) bool { return t.id == tokenID && t.userID == userID }) > 0, nil
+}
+
+// end-to-end encryption
+
+func copyBytes(b []byte) []byte {
+    if b == nil {
+        return nil
+    }
+    return append([]byte{}, b...)
+}
+
+func (m *Memory) SaveDeviceKeys(ctx context.Context, userID string, device protocol.DeviceBundle, oneTimeKeys []protocol.OneTimePreKey) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.users[userID]; !ok {
+        return fmt.Errorf("failed to save device keys: user %s not found", userID)
+    }
+    bundle := protocol.DeviceBundle{
+        DeviceID:       device.DeviceID,
+        IdentityKey:    copyBytes(device.IdentityKey),
+        SigningKey:     copyBytes(device.SigningKey),
+        SignedPreKey:   copyBytes(device.SignedPreKey),
+        SignedPreKeyID: device.SignedPreKeyID,
+        Signature:      copyBytes(device.Signature),
+    }
+    saved := false
+    for _, d := range m.devices {
+        if d.userID == userID && d.bundle.DeviceID == device.DeviceID {
+            d.bundle = bundle
+            d.updatedAt = time.Now()
+            saved = true
+        }
+    }
+    if !saved {
+        m.devices = append(m.devices, &memDevice{userID: userID, bundle: bundle, updatedAt: time.Now()})
+    }
+
+    for _, key := range oneTimeKeys {
+        if m.hasPreKey(userID, device.DeviceID, key.ID) {
+            continue
+        }
+        m.prekeys = append(m.prekeys, memPreKey{
+            userID:   userID,
+            deviceID: device.DeviceID,
+            key:      protocol.OneTimePreKey{ID: key.ID, Key: copyBytes(key.Key)},
+        })
+    }
+    return nil
+}
+
+func (m *Memory) hasPreKey(userID, deviceID string, keyID uint32) bool {
+    for _, k := range m.prekeys {
+        if k.userID == userID && k.deviceID == deviceID && k.key.ID == keyID {
+            return true
+        }
+    }
+    return false
+}
+
+func (m *Memory) ClaimKeyBundles(ctx context.Context, userID string) ([]protocol.DeviceBundle, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var devices []*memDevice
+    for _, d := range m.devices {
+        if d.userID == userID {
+            devices = append(devices, d)
+        }
+    }
+    sort.SliceStable(devices, func(i, j int) bool { return devices[i].updatedAt.After(devices[j].updatedAt) })
+
+    var bundles []protocol.DeviceBundle
+    for _, d := range devices {
+        bundle := d.bundle
+        // no one-time prekey left is not an error, X3DH works without
+        claimed := -1
+        for i, k := range m.prekeys {
+            if k.userID == userID && k.deviceID == d.bundle.DeviceID && (claimed < 0 || k.key.ID < m.prekeys[claimed].key.ID) {
+                claimed = i
+            }
+        }
+        if claimed >= 0 {
+            bundle.OneTimePreKeyID = m.prekeys[claimed].key.ID
+            bundle.OneTimePreKey = m.prekeys[claimed].key.Key
+            m.prekeys = append(m.prekeys[:claimed], m.prekeys[claimed+1:]...)
+        }
+        bundles = append(bundles, bundle)
+    }
+    return bundles, nil
+}
+
+func (m *Memory) QueueEncryptedMessage(ctx context.Context, msg *protocol.EncryptedMessagePayload) error {
+    envelopes, err := json.Marshal(msg.Envelopes)
+    if err != nil {
+        return fmt.Errorf("failed to encode envelopes: %v", err)
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    msg.ID = newID()
+    sentAt := time.Now()
+    msg.SentAt = sentAt.Unix()
+    m.queueEncrypted(*msg, envelopes, sentAt)
+    return nil
+}
+
+func (m *Memory) queueEncrypted(msg protocol.EncryptedMessagePayload, envelopes []byte, sentAt time.Time) {
+    msg.SenderName = ""
+    msg.Envelopes = nil
+    m.encrypted = append(m.encrypted, memEncrypted{msg: msg, envelopes: envelopes, sentAt: sentAt})
+}
+
+func (m *Memory) QueueGroupEncryptedMessage(ctx context.Context, msg *protocol.EncryptedMessagePayload) ([]protocol.EncryptedMessagePayload, error) {
+    envelopes, err := json.Marshal(msg.Envelopes)
+    if err != nil {
+        return nil, fmt.Errorf("failed to encode envelopes: %v", err)
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    sentAt := time.Now()
+    var queued []protocol.EncryptedMessagePayload
+    for _, memberID := range m.memberIDs(msg.GroupID, "") {
+        if memberID == msg.SenderID || !m.hasDevice(memberID) {
+            continue
+        }
+        q := *msg
+        q.ID = newID()
+        q.RecipientID = memberID
+        q.SentAt = sentAt.Unix()
+
+        // the copies of a group message are not sender key messages
+        stored := q
+        stored.SenderKey = false
+        m.queueEncrypted(stored, envelopes, sentAt)
+        queued = append(queued, q)
+    }
+    return queued, nil
+}
+
+func (m *Memory) hasDevice(userID string) bool {
+    for _, d := range m.devices {
+        if d.userID == userID {
+            return true
+        }
+    }
+    return false
+}
+
+func (m *Memory) DeleteEncryptedMessage(ctx context.Context, id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.takeEncrypted(func(e memEncrypted) bool { return e.msg.ID == id })
+    return nil
+}
+
+// takeEncrypted removes the queued messages matched and returns them
+func (m *Memory) takeEncrypted(take func(memEncrypted) bool) []memEncrypted {
+    var taken []memEncrypted
+    kept := m.encrypted[:0]
+    for _, e := range m.encrypted {
+        if take(e) {
+            taken = append(taken, e)
+            continue
+        }
+        kept = append(kept, e)
+    }
+    m.encrypted = kept
+    return taken
+}
+
+func (m *Memory) TakeEncryptedMessages(ctx context.Context, userID string) ([]protocol.EncryptedMessagePayload, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    taken := m.takeEncrypted(func(e memEncrypted) bool { return e.msg.RecipientID == userID })
+    sort.SliceStable(taken, func(i, j int) bool { return taken[i].sentAt.Before(taken[j].sentAt) })
+
+    var messages []protocol.EncryptedMessagePayload
+    for _, e := range taken {
+        name, ok := m.username(e.msg.SenderID)
+        if !ok {
+            continue
+        }
+        msg := e.msg
+        msg.SenderName = name
+        if err := json.Unmarshal(e.envelopes, &msg.Envelopes); err != nil {
+            return nil, fmt.Errorf("failed to decode envelopes: %v", err)
+        }
+        messages = append(messages, msg)
+    }
+    return messages, nil
+}
@@ -0,0 +1,820 @@
+// internal/server/database/memory_messages.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"textual/internal/server/models"
+	"time"
+)
+
+type memArchived struct {
+    pointer      ArchivedMessage
+    attachmentID *string
+}
+
+type memMention struct {
+    userID, messageID string
+}
+
+// selectMessages returns the messages kept by keep, by time sent, newest
+// first when desc, at most limit of them
+func (m *Memory) selectMessages(keep func(*models.Message) bool, desc bool, limit int) []*models.Message {
+    var selected []*models.Message
+    for _, msg := range m.messages {
+        if keep(msg) {
+            selected = append(selected, msg)
+        }
+    }
+    sort.SliceStable(selected, func(i, j int) bool {
+        if desc {
+            return selected[i].SentAt.After(selected[j].SentAt)
+        }
+        return selected[i].SentAt.Before(selected[j].SentAt)
+    })
+    if limit >= 0 && len(selected) > limit {
+        selected = selected[:limit]
+    }
+    return selected
+}
+
+// view returns a copy of a stored message with its sender name and number
+// of replies, the fields left out by a query are cleared by the caller
+func (m *Memory) view(msg *models.Message) models.Message {
+    v := *msg
+    v.RecipientID = copyString(msg.RecipientID)
+    v.GroupID = copyString(msg.GroupID)
+    v.ThreadRootID = copyString(msg.ThreadRootID)
+    v.ReadAt = copyTime(msg.ReadAt)
+    v.EditedAt = copyTime(msg.EditedAt)
+    v.SenderName, _ = m.username(msg.SenderID)
+    v.ClientID = ""
+    for _, reply := range m.messages {
+        if reply.ThreadRootID != nil && *reply.ThreadRootID == msg.ID {
+            v.ReplyCount++
+        }
+    }
+    return v
+}
+
+// before returns the filter of the messages sent before beforeID, none when
+// it is unknown and all of them when it is empty
+func (m *Memory) before(beforeID string) func(*models.Message) bool {
+    if beforeID == "" {
+        return func(*models.Message) bool { return true }
+    }
+    anchor, ok := m.messageByID[beforeID]
+    if !ok {
+        return func(*models.Message) bool { return false }
+    }
+    sentAt := anchor.SentAt
+    return func(msg *models.Message) bool { return msg.SentAt.Before(sentAt) }
+}
+
+func isGlobal(msg *models.Message) bool {
+    return msg.RecipientID == nil && msg.GroupID == nil
+}
+
+// isDirect tells if a message is between two users, either way
+func isDirect(msg *models.Message, userID, otherID string) bool {
+    if msg.RecipientID == nil || msg.SenderID == "" {
+        return false
+    }
+    return (msg.SenderID == userID && *msg.RecipientID == otherID) || (msg.SenderID == otherID && *msg.RecipientID == userID)
+}
+
+func inGroup(msg *models.Message, groupID string) bool {
+    return msg.GroupID != nil && *msg.GroupID == groupID
+}
+
+func isReply(msg *models.Message) bool {
+    return msg.ThreadRootID != nil
+}
+
+func (m *Memory) SaveMessage(ctx context.Context, msg *models.Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if msg.SentAt.IsZero() {
+        msg.SentAt = time.Now()
+    }
+
+    // the message was retried
+    if msg.ClientID != "" {
+        for _, stored := range m.messages {
+            if stored.SenderID == msg.SenderID && stored.ClientID == msg.ClientID {
+                msg.ID = stored.ID
+                msg.Content = stored.Content
+                msg.SentAt = stored.SentAt
+                return ErrDuplicateMessage
+            }
+        }
+    }
+
+    stored := &models.Message{
+        ID:           newID(),
+        Content:      msg.Content,
+        SenderID:     msg.SenderID,
+        RecipientID:  copyString(msg.RecipientID),
+        GroupID:      copyString(msg.GroupID),
+        Status:       models.MessageStatusSent,
+        SentAt:       msg.SentAt,
+        ClientID:     msg.ClientID,
+        ThreadRootID: copyString(msg.ThreadRootID),
+    }
+    m.messages = append(m.messages, stored)
+    m.messageByID[stored.ID] = stored
+    msg.ID = stored.ID
+    return nil
+}
+
+func (m *Memory) GetMessage(ctx context.Context, messageID string) (*models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.getMessage(messageID)
+}
+
+func (m *Memory) getMessage(messageID string) (*models.Message, error) {
+    stored, ok := m.messageByID[messageID]
+    if !ok {
+        return nil, fmt.Errorf("message not found")
+    }
+    msg := m.view(stored)
+    return &msg, nil
+}
+
+// globalMessages returns the messages of the global chat, without the
+// status, as GetMessages and GetMessagesBeforeID list them
+func (m *Memory) globalMessages(keep func(*models.Message) bool, limit int) []models.Message {
+    var messages []models.Message
+    for _, stored := range m.selectMessages(func(msg *models.Message) bool {
+        return isGlobal(msg) && !isReply(msg) && keep(msg)
+    }, true, limit) {
+        msg := m.view(stored)
+        msg.Status = ""
+        messages = append(messages, msg)
+    }
+    return messages
+}
+
+func (m *Memory) GetMessages(ctx context.Context, userID string, limit int) ([]models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.globalMessages(func(*models.Message) bool { return true }, limit), nil
+}
+
+func (m *Memory) GetMessagesBeforeID(ctx context.Context, userID, beforeID string, limit int) ([]models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    // unlike the other pages, an empty beforeID matches no message
+    if _, ok := m.messageByID[beforeID]; !ok {
+        return nil, nil
+    }
+    return m.globalMessages(m.before(beforeID), limit), nil
+}
+
+func (m *Memory) GetConversationMessages(ctx context.Context, userID, otherID, groupID, beforeID string, limit int) ([]models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    before := m.before(beforeID)
+    var messages []models.Message
+    for _, stored := range m.selectMessages(func(msg *models.Message) bool {
+        if groupID != "" {
+            return inGroup(msg, groupID) && !isReply(msg) && before(msg)
+        }
+        return isDirect(msg, userID, otherID) && !isReply(msg) && before(msg)
+    }, true, limit) {
+        messages = append(messages, m.view(stored))
+    }
+    return messages, nil
+}
+
+func (m *Memory) GetMessagesAfter(ctx context.Context, userID, otherID, groupID string, after, until time.Time, limit int) ([]models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var messages []models.Message
+    for _, stored := range m.selectMessages(func(msg *models.Message) bool {
+        switch {
+        case groupID != "":
+            if !inGroup(msg, groupID) {
+                return false
+            }
+        case otherID != "":
+            if !isDirect(msg, userID, otherID) {
+                return false
+            }
+        default:
+            if !isGlobal(msg) {
+                return false
+            }
+        }
+        return !isReply(msg) && msg.SentAt.After(after) && (until.IsZero() || msg.SentAt.Before(until))
+    }, false, limit) {
+        messages = append(messages, m.view(stored))
+    }
+    return messages, nil
+}
+
+func (m *Memory) GetGroupMessages(ctx context.Context, groupID string) ([]models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var messages []models.Message
+    for _, stored := range m.selectMessages(func(msg *models.Message) bool {
+        _, ok := m.users[msg.SenderID]
+        return inGroup(msg, groupID) && ok
+    }, true, 100) {
+        v := m.view(stored)
+        messages = append(messages, models.Message{
+            ID:         v.ID,
+            Content:    v.Content,
+            SenderID:   v.SenderID,
+            GroupID:    &groupID,
+            SentAt:     v.SentAt,
+            ReadAt:     v.ReadAt,
+            SenderName: v.SenderName,
+        })
+    }
+    return messages, nil
+}
+
+func (m *Memory) GetThreadMessages(ctx context.Context, rootID, beforeID string, limit int) ([]models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    before := m.before(beforeID)
+    var messages []models.Message
+    for _, stored := range m.selectMessages(func(msg *models.Message) bool {
+        return msg.ThreadRootID != nil && *msg.ThreadRootID == rootID && before(msg)
+    }, true, limit) {
+        msg := m.view(stored)
+        msg.Status = ""
+        msg.ReplyCount = 0
+        messages = append(messages, msg)
+    }
+    return messages, nil
+}
+
+func (m *Memory) GetMessageIDByClientID(ctx context.Context, senderID, clientID string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, msg := range m.messages {
+        if msg.SenderID == senderID && msg.ClientID == clientID && clientID != "" {
+            return msg.ID, nil
+        }
+    }
+    return "", fmt.Errorf("failed to get message: %v", sql.ErrNoRows)
+}
+
+func (m *Memory) GetDirectContacts(ctx context.Context, userID string, limit int) ([]models.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    latest := make(map[string]time.Time)
+    var order []string
+    for _, msg := range m.messages {
+        if msg.RecipientID == nil || (msg.SenderID != userID && *msg.RecipientID != userID) {
+            continue
+        }
+        other := msg.SenderID
+        if msg.SenderID == userID {
+            other = *msg.RecipientID
+        }
+        if _, ok := m.users[other]; !ok {
+            continue
+        }
+        at, seen := latest[other]
+        if !seen {
+            order = append(order, other)
+        }
+        if !seen || msg.SentAt.After(at) {
+            latest[other] = msg.SentAt
+        }
+    }
+    sort.SliceStable(order, func(i, j int) bool { return latest[order[i]].After(latest[order[j]]) })
+    if len(order) > limit {
+        order = order[:limit]
+    }
+
+    var users []models.User
+    for _, id := range order {
+        u := m.users[id]
+        users = append(users, models.User{ID: u.user.ID, Username: u.user.Username, Status: u.user.Status})
+    }
+    return users, nil
+}
+
+func (m *Memory) EditMessage(ctx context.Context, messageID, editorID, content string) (*models.Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    msg, ok := m.messageByID[messageID]
+    if !ok {
+        return nil, fmt.Errorf("message not found")
+    }
+    now := time.Now()
+    m.revisions = append(m.revisions, models.MessageRevision{
+        ID:        newID(),
+        MessageID: messageID,
+        Content:   msg.Content,
+        EditedBy:  editorID,
+        EditedAt:  now,
+    })
+    msg.Content = content
+    msg.EditedAt = &now
+    return m.getMessage(messageID)
+}
+
+func (m *Memory) GetMessageRevisions(ctx context.Context, messageID string) ([]models.MessageRevision, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.messageRevisions(messageID), nil
+}
+
+// messageRevisions returns the revisions of a message, oldest first
+func (m *Memory) messageRevisions(messageID string) []models.MessageRevision {
+    var revisions []models.MessageRevision
+    for _, rev := range m.revisions {
+        if rev.MessageID == messageID {
+            revisions = append(revisions, rev)
+        }
+    }
+    return revisions
+}
+
+func (m *Memory) MarkMessageAsRead(ctx context.Context, messageID, userID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    msg, ok := m.messageByID[messageID]
+    if !ok || msg.RecipientID == nil || *msg.RecipientID != userID || msg.ReadAt != nil {
+        return fmt.Errorf("message not found or already read")
+    }
+    now := time.Now()
+    msg.ReadAt = &now
+    msg.Status = models.MessageStatusRead
+    return nil
+}
+
+// receipts
+
+func (m *Memory) MarkDelivered(ctx context.Context, messageID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if msg, ok := m.messageByID[messageID]; ok && msg.Status == models.MessageStatusSent {
+        msg.Status = models.MessageStatusDelivered
+    }
+    return nil
+}
+
+func (m *Memory) DeliverPending(ctx context.Context, userID string) (map[string]string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    last := make(map[string]string)
+    lastAt := make(map[string]time.Time)
+    for _, msg := range m.messages {
+        if msg.RecipientID == nil || *msg.RecipientID != userID || msg.Status != models.MessageStatusSent {
+            continue
+        }
+        msg.Status = models.MessageStatusDelivered
+        // the sender may have deleted the account
+        if msg.SenderID == "" {
+            continue
+        }
+        if at, ok := lastAt[msg.SenderID]; !ok || msg.SentAt.After(at) {
+            last[msg.SenderID] = msg.ID
+            lastAt[msg.SenderID] = msg.SentAt
+        }
+    }
+    return last, nil
+}
+
+func (m *Memory) MarkConversationRead(ctx context.Context, userID, otherID, messageID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var upTo *time.Time
+    if messageID != "" {
+        anchor, ok := m.messageByID[messageID]
+        if !ok {
+            return false, nil
+        }
+        upTo = &anchor.SentAt
+    }
+    now := time.Now()
+    changed := false
+    for _, msg := range m.messages {
+        if msg.SenderID != otherID || msg.RecipientID == nil || *msg.RecipientID != userID || msg.ReadAt != nil {
+            continue
+        }
+        if upTo != nil && msg.SentAt.After(*upTo) {
+            continue
+        }
+        msg.Status = models.MessageStatusRead
+        msg.ReadAt = &now
+        changed = true
+    }
+    return changed, nil
+}
+
+func (m *Memory) GetUnreadCounts(ctx context.Context, userID string) (map[string]int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    counts := make(map[string]int)
+    for _, msg := range m.messages {
+        if msg.RecipientID != nil && *msg.RecipientID == userID && msg.ReadAt == nil && msg.SenderID != "" && !isReply(msg) {
+            counts[msg.SenderID]++
+        }
+    }
+    return counts, nil
+}
+
+// archive
+
+func (m *Memory) GetMessagesOlderThan(ctx context.Context, cutoff time.Time, limit int) ([]ArchiveRecord, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var records []ArchiveRecord
+    for _, stored := range m.selectMessages(func(msg *models.Message) bool { return msg.SentAt.Before(cutoff) }, false, limit) {
+        record := ArchiveRecord{Message: m.view(stored)}
+        record.ClientID = stored.ClientID
+        record.ReplyCount = 0
+        if record.EditedAt != nil {
+            record.Revisions = m.messageRevisions(record.ID)
+        }
+        m.attach(&record.Message)
+        records = append(records, record)
+    }
+    return records, nil
+}
+
+func (m *Memory) ArchiveMessages(ctx context.Context, records []ArchiveRecord, archivePath string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for i, msg := range records {
+        var senderID, attachmentID *string
+        if msg.SenderID != "" {
+            senderID = copyString(&msg.SenderID)
+        }
+        if msg.Voice != nil {
+            attachmentID = copyString(&msg.Voice.AttachmentID)
+        }
+        if _, ok := m.archivedPointer(msg.ID); !ok {
+            m.archived = append(m.archived, memArchived{
+                pointer: ArchivedMessage{
+                    ID:           msg.ID,
+                    SenderID:     senderID,
+                    RecipientID:  copyString(msg.RecipientID),
+                    GroupID:      copyString(msg.GroupID),
+                    SentAt:       msg.SentAt,
+                    ArchivePath:  archivePath,
+                    ArchiveLine:  i,
+                    ThreadRootID: copyString(msg.ThreadRootID),
+                },
+                attachmentID: attachmentID,
+            })
+        }
+        m.deleteMessage(msg.ID)
+    }
+    return nil
+}
+
+// deleteMessage removes a message with its revisions, recording and preview,
+// the mentions stay as they point to the archive
+func (m *Memory) deleteMessage(id string) {
+    if _, ok := m.messageByID[id]; !ok {
+        return
+    }
+    delete(m.messageByID, id)
+    kept := m.messages[:0]
+    for _, msg := range m.messages {
+        if msg.ID != id {
+            kept = append(kept, msg)
+        }
+    }
+    m.messages = kept
+
+    revisions := m.revisions[:0]
+    for _, rev := range m.revisions {
+        if rev.MessageID != id {
+            revisions = append(revisions, rev)
+        }
+    }
+    m.revisions = revisions
+    delete(m.voices, id)
+    delete(m.previews, id)
+}
+
+func (m *Memory) archivedPointer(id string) (ArchivedMessage, bool) {
+    for _, a := range m.archived {
+        if a.pointer.ID == id {
+            return m.copyArchived(a.pointer), true
+        }
+    }
+    return ArchivedMessage{}, false
+}
+
+func (m *Memory) copyArchived(a ArchivedMessage) ArchivedMessage {
+    a.SenderID = copyString(a.SenderID)
+    a.RecipientID = copyString(a.RecipientID)
+    a.GroupID = copyString(a.GroupID)
+    a.ThreadRootID = copyString(a.ThreadRootID)
+    return a
+}
+
+func (m *Memory) GetArchivedMessage(ctx context.Context, messageID string) (*ArchivedMessage, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    archived, ok := m.archivedPointer(messageID)
+    if !ok {
+        return nil, fmt.Errorf("archived message not found: %v", sql.ErrNoRows)
+    }
+    return &archived, nil
+}
+
+// selectArchived returns the pointers kept by keep, newest first
+func (m *Memory) selectArchived(keep func(ArchivedMessage) bool, limit int) []ArchivedMessage {
+    var pointers []ArchivedMessage
+    for _, a := range m.archived {
+        if keep(a.pointer) {
+            pointers = append(pointers, m.copyArchived(a.pointer))
+        }
+    }
+    sort.SliceStable(pointers, func(i, j int) bool { return pointers[i].SentAt.After(pointers[j].SentAt) })
+    if len(pointers) > limit {
+        pointers = pointers[:limit]
+    }
+    return pointers
+}
+
+func (m *Memory) GetArchivedMessages(ctx context.Context, userID, otherID, groupID string, before time.Time, limit int) ([]ArchivedMessage, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return m.selectArchived(func(a ArchivedMessage) bool {
+        if a.ThreadRootID != nil || !a.SentAt.Before(before) {
+            return false
+        }
+        msg := &models.Message{RecipientID: a.RecipientID, GroupID: a.GroupID}
+        if a.SenderID != nil {
+            msg.SenderID = *a.SenderID
+        }
+        switch {
+        case groupID != "":
+            return inGroup(msg, groupID)
+        case otherID != "":
+            return isDirect(msg, userID, otherID)
+        default:
+            return isGlobal(msg)
+        }
+    }, limit), nil
+}
+
+func (m *Memory) GetArchivedReplies(ctx context.Context, rootID string, before time.Time, limit int) ([]ArchivedMessage, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return m.selectArchived(func(a ArchivedMessage) bool {
+        return a.ThreadRootID != nil && *a.ThreadRootID == rootID && a.SentAt.Before(before)
+    }, limit), nil
+}
+
+func (m *Memory) MessageSentAt(ctx context.Context, messageID string) (time.Time, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if msg, ok := m.messageByID[messageID]; ok {
+        return msg.SentAt, nil
+    }
+    if archived, ok := m.archivedPointer(messageID); ok {
+        return archived.SentAt, nil
+    }
+    return time.Time{}, fmt.Errorf("message not found: %v", sql.ErrNoRows)
+}
+
+func (m *Memory) CountReplies(ctx context.Context, rootIDs []string) (map[string]int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    roots := make(map[string]bool, len(rootIDs))
+    for _, id := range rootIDs {
+        roots[id] = true
+    }
+    counts := make(map[string]int)
+    for _, msg := range m.messages {
+        if msg.ThreadRootID != nil && roots[*msg.ThreadRootID] {
+            counts[*msg.ThreadRootID]++
+        }
+    }
+    for _, a := range m.archived {
+        if a.pointer.ThreadRootID != nil && roots[*a.pointer.ThreadRootID] {
+            counts[*a.pointer.ThreadRootID]++
+        }
+    }
+    return counts, nil
+}
+
+// attachments, recordings and previews
+
+func (m *Memory) SaveAttachment(ctx context.Context, a *models.Attachment) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    a.ID = newID()
+    a.CreatedAt = time.Now()
+    m.attachments[a.ID] = *a
+    return nil
+}
+
+func (m *Memory) GetAttachment(ctx context.Context, id string) (*models.Attachment, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    a, ok := m.attachments[id]
+    if !ok {
+        return nil, sql.ErrNoRows
+    }
+    return &a, nil
+}
+
+// canSee tells if a user sees a message, archived or not: in the global
+// chat, sent or received, or in one of the groups of the user
+func (m *Memory) canSee(userID string, senderID, recipientID, groupID *string) bool {
+    if recipientID == nil && groupID == nil {
+        return true
+    }
+    if (senderID != nil && *senderID == userID) || (recipientID != nil && *recipientID == userID) {
+        return true
+    }
+    return groupID != nil && m.member(userID, *groupID) != nil
+}
+
+func (m *Memory) CanReadAttachment(ctx context.Context, userID, attachmentID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if a, ok := m.attachments[attachmentID]; ok && a.UploaderID == userID {
+        return true, nil
+    }
+    for messageID, voice := range m.voices {
+        msg, ok := m.messageByID[messageID]
+        if voice.AttachmentID != attachmentID || !ok {
+            continue
+        }
+        if m.canSee(userID, &msg.SenderID, msg.RecipientID, msg.GroupID) {
+            return true, nil
+        }
+    }
+    for _, a := range m.archived {
+        if a.attachmentID == nil || *a.attachmentID != attachmentID {
+            continue
+        }
+        if m.canSee(userID, a.pointer.SenderID, a.pointer.RecipientID, a.pointer.GroupID) {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+func (m *Memory) SaveVoice(ctx context.Context, messageID string, voice models.Voice) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.messageByID[messageID]; !ok {
+        return fmt.Errorf("failed to save voice message: message %s not found", messageID)
+    }
+    if _, ok := m.voices[messageID]; !ok {
+        voice.Waveform = append([]byte(nil), voice.Waveform...)
+        m.voices[messageID] = voice
+    }
+    return nil
+}
+
+// attach sets the recording and the preview of a message
+func (m *Memory) attach(msg *models.Message) {
+    if voice, ok := m.voices[msg.ID]; ok {
+        voice.Waveform = append([]byte(nil), voice.Waveform...)
+        msg.Voice = &voice
+    }
+    if preview, ok := m.previews[msg.ID]; ok {
+        msg.Preview = &preview
+    }
+}
+
+func (m *Memory) AttachVoice(ctx context.Context, messages []models.Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for i := range messages {
+        if voice, ok := m.voices[messages[i].ID]; ok {
+            voice.Waveform = append([]byte(nil), voice.Waveform...)
+            messages[i].Voice = &voice
+        }
+    }
+    return nil
+}
+
+func (m *Memory) SaveLinkPreview(ctx context.Context, messageID string, preview models.LinkPreview) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.messageByID[messageID]; !ok {
+        return fmt.Errorf("failed to save link preview: message %s not found", messageID)
+    }
+    m.previews[messageID] = preview
+    return nil
+}
+
+func (m *Memory) DeleteLinkPreview(ctx context.Context, messageID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    delete(m.previews, messageID)
+    return nil
+}
+
+func (m *Memory) AttachPreviews(ctx context.Context, messages []models.Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for i := range messages {
+        if preview, ok := m.previews[messages[i].ID]; ok {
+            messages[i].Preview = &preview
+        }
+    }
+    return nil
+}
+
+// mentions
+
+func (m *Memory) AddMention(ctx context.Context, userID, messageID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, mention := range m.mentions {
+        if mention.userID == userID && mention.messageID == messageID {
+            return nil
+        }
+    }
+    m.mentions = append(m.mentions, memMention{userID: userID, messageID: messageID})
+    return nil
+}
+
+func (m *Memory) GetMentions(ctx context.Context, userID string, limit int) ([]models.Mention, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var mentions []models.Mention
+    for _, mn := range m.mentions {
+        if mn.userID != userID {
+            continue
+        }
+        mention := models.Mention{MessageID: mn.messageID}
+        var senderID *string
+        if msg, ok := m.messageByID[mn.messageID]; ok {
+            if msg.Status == models.MessageStatusDeleted {
+                continue
+            }
+            senderID = &msg.SenderID
+            mention.GroupID = copyString(msg.GroupID)
+            mention.Content = msg.Content
+            mention.SentAt = msg.SentAt
+        } else if archived, ok := m.archivedPointer(mn.messageID); ok {
+            senderID = archived.SenderID
+            mention.GroupID = archived.GroupID
+            mention.SentAt = archived.SentAt
+            mention.Archived = true
+        } else {
+            continue
+        }
+        if mention.GroupID != nil {
+            if m.member(userID, *mention.GroupID) == nil {
+                continue
+            }
+            if g, ok := m.groups[*mention.GroupID]; ok {
+                mention.GroupName = g.group.Name
+            }
+        }
+        if senderID != nil {
+            mention.SenderName, _ = m.username(*senderID)
+        }
+        mentions = append(mentions, mention)
+    }
+    sort.SliceStable(mentions, func(i, j int) bool { return mentions[i].SentAt.After(mentions[j].SentAt) })
+    if len(mentions) > limit {
+        mentions = mentions[:limit]
+    }
+    return mentions, nil
+}
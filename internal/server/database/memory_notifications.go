@@ -0,0 +1,220 @@
+// internal/server/database/memory_notifications.go
+package database
+
+import (
+	"context"
+	"sort"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+func (m *Memory) CreateNotification(ctx context.Context, n *models.Notification) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    n.ID = newID()
+    n.CreatedAt = time.Now()
+    stored := *n
+    stored.RelatedID = copyString(n.RelatedID)
+    stored.ReadAt = nil
+    m.notifications = append(m.notifications, stored)
+    return nil
+}
+
+func (m *Memory) GetNotifications(ctx context.Context, userID string, limit int) ([]models.Notification, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var notifications []models.Notification
+    for i := len(m.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+        if n := m.notifications[i]; n.UserID == userID {
+            n.RelatedID = copyString(n.RelatedID)
+            n.ReadAt = copyTime(n.ReadAt)
+            notifications = append(notifications, n)
+        }
+    }
+    return notifications, nil
+}
+
+func (m *Memory) MarkNotificationsRead(ctx context.Context, userID string, ids []string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    marked := make(map[string]bool, len(ids))
+    for _, id := range ids {
+        marked[id] = true
+    }
+    now := time.Now()
+    for i := range m.notifications {
+        n := &m.notifications[i]
+        if n.UserID == userID && n.ReadAt == nil && (len(ids) == 0 || marked[n.ID]) {
+            n.ReadAt = &now
+        }
+    }
+    return nil
+}
+
+func (m *Memory) SetNotificationLevel(ctx context.Context, userID, chatID, level string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    key := [2]string{userID, chatID}
+    if level == protocol.LevelAll {
+        delete(m.levels, key)
+    } else {
+        m.levels[key] = level
+    }
+    return nil
+}
+
+func (m *Memory) GetNotificationLevels(ctx context.Context, userID string) (map[string]string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    levels := make(map[string]string)
+    for key, level := range m.levels {
+        if key[0] == userID {
+            levels[key[1]] = level
+        }
+    }
+    return levels, nil
+}
+
+func (m *Memory) GetNotificationLevel(ctx context.Context, userID, chatID string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if level, ok := m.levels[[2]string{userID, chatID}]; ok {
+        return level, nil
+    }
+    return protocol.LevelAll, nil
+}
+
+// reminders
+
+func (m *Memory) CreateReminder(ctx context.Context, r *models.Reminder) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    r.ID = newID()
+    r.CreatedAt = time.Now()
+    stored := *r
+    stored.DeliveredAt = nil
+    m.reminders = append(m.reminders, stored)
+    return nil
+}
+
+// pendingReminders returns the reminders not delivered kept by keep,
+// soonest first
+func (m *Memory) pendingReminders(keep func(models.Reminder) bool) []models.Reminder {
+    var reminders []models.Reminder
+    for _, r := range m.reminders {
+        if r.DeliveredAt == nil && keep(r) {
+            reminders = append(reminders, r)
+        }
+    }
+    sort.SliceStable(reminders, func(i, j int) bool { return reminders[i].RemindAt.Before(reminders[j].RemindAt) })
+    return reminders
+}
+
+func (m *Memory) GetReminders(ctx context.Context, userID string) ([]models.Reminder, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.pendingReminders(func(r models.Reminder) bool { return r.UserID == userID }), nil
+}
+
+func (m *Memory) CountReminders(ctx context.Context, userID string) (int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return len(m.pendingReminders(func(r models.Reminder) bool { return r.UserID == userID })), nil
+}
+
+func (m *Memory) CancelReminder(ctx context.Context, userID, reminderID string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for i, r := range m.reminders {
+        if r.ID == reminderID && r.UserID == userID && r.DeliveredAt == nil {
+            m.reminders = append(m.reminders[:i], m.reminders[i+1:]...)
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+func (m *Memory) GetDueReminders(ctx context.Context, now time.Time, limit int) ([]models.Reminder, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    reminders := m.pendingReminders(func(r models.Reminder) bool { return !r.RemindAt.After(now) })
+    if len(reminders) > limit {
+        reminders = reminders[:limit]
+    }
+    return reminders, nil
+}
+
+func (m *Memory) MarkReminderDelivered(ctx context.Context, reminderID string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    now := time.Now()
+    for i := range m.reminders {
+        if m.reminders[i].ID == reminderID {
+            m.reminders[i].DeliveredAt = &now
+        }
+    }
+    return nil
+}
+
+// integrations
+
+func (m *Memory) CreateIntegration(ctx context.Context, integration *models.Integration) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    integration.ID = newID()
+    integration.CreatedAt = time.Now()
+    m.integrations = append(m.integrations, *integration)
+    return nil
+}
+
+func (m *Memory) GetIntegration(ctx context.Context, id string) (*models.Integration, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, integration := range m.integrations {
+        if integration.ID == id {
+            return &integration, nil
+        }
+    }
+    return nil, ErrIntegrationNotFound
+}
+
+func (m *Memory) GetGroupIntegrations(ctx context.Context, groupID string) ([]models.Integration, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var integrations []models.Integration
+    for _, integration := range m.integrations {
+        if integration.GroupID == groupID {
+            // the secret is only read to check a delivery
+            integration.Secret = ""
+            integrations = append(integrations, integration)
+        }
+    }
+    return integrations, nil
+}
+
+func (m *Memory) DeleteIntegration(ctx context.Context, groupID, id string) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for i, integration := range m.integrations {
+        if integration.ID == id && integration.GroupID == groupID {
+            m.integrations = append(m.integrations[:i], m.integrations[i+1:]...)
+            return true, nil
+        }
+    }
+    return false, nil
+}
@@ -0,0 +1,18 @@
+-- internal/server/database/migrations/003_message_archive.sql
+
+-- Pointers to messages moved out of the messages table by the archiver.
+-- The message body lives in the archive file, at the given line.
+CREATE TABLE archived_messages (
+    id UUID PRIMARY KEY,
+    sender_id UUID,
+    recipient_id UUID,
+    group_id UUID,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    archive_path TEXT NOT NULL,
+    archive_line INTEGER NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_archived_messages_sent_at ON archived_messages(sent_at);
+CREATE INDEX idx_archived_messages_recipient ON archived_messages(recipient_id);
+CREATE INDEX idx_archived_messages_group ON archived_messages(group_id);
@@ -0,0 +1,8 @@
+-- internal/server/database/migrations/005_user_status_text.sql
+
+-- Do not disturb status, set by the user
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users ADD CONSTRAINT users_status_check CHECK (status IN ('online', 'offline', 'away', 'dnd'));
+
+-- Custom status text ("in a meeting")
+ALTER TABLE users ADD COLUMN status_text VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- internal/server/database/migrations/006_public_groups.sql
+
+-- Public groups are listed in the group directory and can be joined by anyone
+ALTER TABLE groups ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_groups_public ON groups(is_public) WHERE status = 'active';
@@ -0,0 +1,7 @@
+-- internal/server/database/migrations/007_message_client_id.sql
+
+-- Key chosen by the sending client, a message retried after a timeout is
+-- stored once
+ALTER TABLE messages ADD COLUMN client_id VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_messages_client_id ON messages(sender_id, client_id) WHERE client_id IS NOT NULL;
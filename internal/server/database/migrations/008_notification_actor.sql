@@ -0,0 +1,7 @@
+-- internal/server/database/migrations/008_notification_actor.sql
+
+-- User who caused the notification: sender of the friend request, admin who
+-- sent the group invite, author of the mention
+ALTER TABLE notifications ADD COLUMN actor VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
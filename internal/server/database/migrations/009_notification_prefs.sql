@@ -0,0 +1,10 @@
+-- internal/server/database/migrations/009_notification_prefs.sql
+
+-- Notification level chosen by a user for a conversation: "global", a group
+-- or a user ID. Conversations without a row notify everything
+CREATE TABLE notification_prefs (
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    chat_id VARCHAR(64) NOT NULL,
+    level VARCHAR(10) NOT NULL CHECK (level IN ('badge', 'none')),
+    PRIMARY KEY (user_id, chat_id)
+);
@@ -0,0 +1,38 @@
+-- internal/server/database/migrations/010_e2ee.sql
+
+-- Public keys of the devices using end-to-end encryption
+CREATE TABLE device_keys (
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    device_id VARCHAR(32) NOT NULL,
+    identity_key BYTEA NOT NULL,
+    signing_key BYTEA NOT NULL,
+    signed_prekey BYTEA NOT NULL,
+    signed_prekey_id BIGINT NOT NULL,
+    signature BYTEA NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, device_id)
+);
+
+-- One-time prekeys, each one is handed out once
+CREATE TABLE one_time_prekeys (
+    user_id UUID NOT NULL,
+    device_id VARCHAR(32) NOT NULL,
+    key_id BIGINT NOT NULL,
+    public_key BYTEA NOT NULL,
+    PRIMARY KEY (user_id, device_id, key_id),
+    FOREIGN KEY (user_id, device_id) REFERENCES device_keys(user_id, device_id) ON DELETE CASCADE
+);
+
+-- Encrypted direct messages waiting for their recipient, the server can't
+-- read them and deletes them once delivered
+CREATE TABLE encrypted_messages (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sender_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    sender_device VARCHAR(32) NOT NULL,
+    recipient_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(64),
+    envelopes JSONB NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_encrypted_messages_recipient ON encrypted_messages(recipient_id, sent_at);
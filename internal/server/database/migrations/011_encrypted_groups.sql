@@ -0,0 +1,10 @@
+-- internal/server/database/migrations/011_encrypted_groups.sql
+
+-- Encrypted groups: the members share sender keys, the server only relays
+-- the messages and keeps no history
+ALTER TABLE groups ADD COLUMN is_encrypted BOOLEAN NOT NULL DEFAULT false;
+
+-- a queued message is a direct message, a group message (group_id) or the
+-- sender key of a group member sent to one other member (sender_key)
+ALTER TABLE encrypted_messages ADD COLUMN group_id UUID REFERENCES groups(id) ON DELETE CASCADE;
+ALTER TABLE encrypted_messages ADD COLUMN sender_key BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,23 @@
+-- internal/server/database/migrations/012_attachments.sql
+
+-- Files uploaded by the users, the content is stored in ATTACHMENT_DIR under
+-- the ID
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    uploader_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Voice messages: the message holds a text for the clients that can't play
+-- it, the recording is an attachment
+CREATE TABLE voice_messages (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    attachment_id UUID NOT NULL REFERENCES attachments(id) ON DELETE CASCADE,
+    duration_ms BIGINT NOT NULL,
+    waveform BYTEA
+);
+
+CREATE INDEX idx_voice_messages_attachment ON voice_messages(attachment_id);
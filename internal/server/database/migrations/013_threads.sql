@@ -0,0 +1,8 @@
+-- internal/server/database/migrations/013_threads.sql
+
+-- Replies in a thread point to the first message of the thread, they are
+-- left out of the history of the conversation. No foreign key: the archive
+-- moves the root out of the table before its replies
+ALTER TABLE messages ADD COLUMN thread_root_id UUID;
+
+CREATE INDEX idx_messages_thread_root ON messages(thread_root_id, sent_at) WHERE thread_root_id IS NOT NULL;
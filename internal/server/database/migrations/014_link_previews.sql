@@ -0,0 +1,13 @@
+-- internal/server/database/migrations/014_link_previews.sql
+
+-- Preview of the first link of a message, fetched by the server once the
+-- message is stored
+CREATE TABLE link_previews (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
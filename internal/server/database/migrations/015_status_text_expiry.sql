@@ -0,0 +1,5 @@
+-- internal/server/database/migrations/015_status_text_expiry.sql
+
+-- A custom status text can clear itself ("in a meeting" for an hour), NULL
+-- keeps it until changed
+ALTER TABLE users ADD COLUMN status_text_expires_at TIMESTAMP WITH TIME ZONE;
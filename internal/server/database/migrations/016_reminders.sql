@@ -0,0 +1,22 @@
+-- internal/server/database/migrations/016_reminders.sql
+
+-- Account sending the reminders as direct messages. Its password hash is not
+-- a bcrypt hash, no password logs in with it. Only a second run is skipped:
+-- a user already named Textual fails the migration, rename them first
+INSERT INTO users (id, username, password_hash, status)
+VALUES ('00000000-0000-0000-0000-000000000001', 'Textual', '!', 'offline')
+ON CONFLICT (id) DO NOTHING;
+
+-- Reminders set with /remind, delivered_at is set once the message is sent
+-- so the pending ones survive a restart
+CREATE TABLE reminders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    remind_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_reminders_due ON reminders(remind_at) WHERE delivered_at IS NULL;
+CREATE INDEX idx_reminders_user ON reminders(user_id) WHERE delivered_at IS NULL;
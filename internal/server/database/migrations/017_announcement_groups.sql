@@ -0,0 +1,5 @@
+-- internal/server/database/migrations/017_announcement_groups.sql
+
+-- Announcement groups: only the admins post, the other members read. Meant
+-- for server news, in a public group anyone can join
+ALTER TABLE groups ADD COLUMN is_announcement BOOLEAN NOT NULL DEFAULT false;
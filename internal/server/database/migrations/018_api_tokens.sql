@@ -0,0 +1,14 @@
+-- internal/server/database/migrations/018_api_tokens.sql
+
+-- Tokens of the REST API, only their SHA-256 is kept: the token itself is
+-- shown once, when created
+CREATE TABLE api_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL DEFAULT '',
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_api_tokens_user ON api_tokens(user_id);
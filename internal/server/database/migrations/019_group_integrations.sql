@@ -0,0 +1,14 @@
+-- internal/server/database/migrations/019_group_integrations.sql
+
+-- Incoming integrations posting the events of GitHub or GitLab in a group.
+-- The secret signs (GitHub) or comes with (GitLab) each delivery
+CREATE TABLE group_integrations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('github', 'gitlab')),
+    secret VARCHAR(64) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_group_integrations_group ON group_integrations(group_id);
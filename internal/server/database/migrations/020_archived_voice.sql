@@ -0,0 +1,7 @@
+-- internal/server/database/migrations/020_archived_voice.sql
+
+-- Recording of an archived voice message: the voice_messages row goes with
+-- the message, the recipients keep the right to download the file
+ALTER TABLE archived_messages ADD COLUMN attachment_id UUID;
+
+CREATE INDEX idx_archived_messages_attachment ON archived_messages(attachment_id) WHERE attachment_id IS NOT NULL;
@@ -0,0 +1,7 @@
+-- internal/server/database/migrations/021_archived_threads.sql
+
+-- Archived replies stay in their thread, out of the history of the
+-- conversation
+ALTER TABLE archived_messages ADD COLUMN thread_root_id UUID;
+
+CREATE INDEX idx_archived_messages_thread_root ON archived_messages(thread_root_id, sent_at) WHERE thread_root_id IS NOT NULL;
@@ -0,0 +1,5 @@
+-- internal/server/database/migrations/022_notification_mentions.sql
+
+-- A group can notify only the messages mentioning the user
+ALTER TABLE notification_prefs DROP CONSTRAINT notification_prefs_level_check;
+ALTER TABLE notification_prefs ADD CONSTRAINT notification_prefs_level_check CHECK (level IN ('mentions', 'badge', 'none'));
@@ -0,0 +1,12 @@
+-- internal/server/database/migrations/023_mentions.sql
+
+-- Messages of the global chat and of the groups naming a user with
+-- "@username", listed in the mentions inbox of the user
+CREATE TABLE mentions (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX idx_mentions_user ON mentions(user_id, created_at DESC);
@@ -0,0 +1,15 @@
+-- internal/server/database/migrations/024_group_topics.sql
+
+-- Topic of a group, set by its admins and shown above the chat
+ALTER TABLE groups ADD COLUMN topic TEXT NOT NULL DEFAULT '';
+
+-- Every topic set, the clients show them among the messages of the group
+CREATE TABLE group_topic_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    topic TEXT NOT NULL,
+    set_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    set_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_group_topic_changes_group ON group_topic_changes(group_id, set_at DESC);
@@ -0,0 +1,7 @@
+-- internal/server/database/migrations/025_display_prefs.sql
+
+-- How the clients of a user write the times and the interface, the same on
+-- all their devices; empty keeps the setting of each device
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN time_format TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';
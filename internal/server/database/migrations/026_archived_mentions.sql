@@ -0,0 +1,5 @@
+-- internal/server/database/migrations/026_archived_mentions.sql
+
+-- The mentions of the messages the archiver moves out stay in the inbox,
+-- they are read from the archive
+ALTER TABLE mentions DROP CONSTRAINT IF EXISTS mentions_message_id_fkey;
@@ -0,0 +1,5 @@
+-- internal/server/database/migrations/027_imported_groups.sql
+
+-- Groups created by textual-import: the next imports fill them again, they
+-- never write into a group of the users
+ALTER TABLE groups ADD COLUMN imported BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,174 @@
+// internal/server/database/notifications.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+
+	"github.com/lib/pq"
+)
+
+// CreateNotification stores a notification, its ID and creation time are set
+// by the database
+func (db *DB) CreateNotification(ctx context.Context, n *models.Notification) error {
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO notifications (user_id, type, actor, content, related_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, n.UserID, n.Type, n.Actor, n.Content, n.RelatedID).Scan(&n.ID, &n.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to create notification: %v", err)
+    }
+    return nil
+}
+
+// GetNotifications returns the newest notifications of a user first
+func (db *DB) GetNotifications(ctx context.Context, userID string, limit int) ([]models.Notification, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, user_id, type, actor, content, related_id, created_at, read_at
+        FROM notifications
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+        LIMIT $2
+    `, userID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get notifications: %v", err)
+    }
+    defer rows.Close()
+
+    var notifications []models.Notification
+    for rows.Next() {
+        var n models.Notification
+        if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Actor, &n.Content, &n.RelatedID, &n.CreatedAt, &n.ReadAt); err != nil {
+            return nil, fmt.Errorf("failed to scan notification: %v", err)
+        }
+        notifications = append(notifications, n)
+    }
+    return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marks notifications of a user as read, all of them
+// when ids is empty
+func (db *DB) MarkNotificationsRead(ctx context.Context, userID string, ids []string) error {
+    var err error
+    if len(ids) == 0 {
+        _, err = db.ExecContext(ctx, `
+            UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+            WHERE user_id = $1 AND read_at IS NULL
+        `, userID)
+    } else {
+        _, err = db.ExecContext(ctx, `
+            UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+            WHERE user_id = $1 AND id = ANY($2::uuid[]) AND read_at IS NULL
+        `, userID, pq.Array(ids))
+    }
+    if err != nil {
+        return fmt.Errorf("failed to mark notifications as read: %v", err)
+    }
+    return nil
+}
+
+// SetNotificationLevel saves the notification level of a conversation, the
+// "all" level is the default and removes the row
+func (db *DB) SetNotificationLevel(ctx context.Context, userID, chatID, level string) error {
+    var err error
+    if level == protocol.LevelAll {
+        _, err = db.ExecContext(ctx, `DELETE FROM notification_prefs WHERE user_id = $1 AND chat_id = $2`, userID, chatID)
+    } else {
+        _, err = db.ExecContext(ctx, `
+            INSERT INTO notification_prefs (user_id, chat_id, level)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (user_id, chat_id) DO UPDATE SET level = EXCLUDED.level
+        `, userID, chatID, level)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to save notification level: %v", err)
+    }
+    return nil
+}
+
+// GetNotificationLevels returns the levels set by a user, by conversation
+func (db *DB) GetNotificationLevels(ctx context.Context, userID string) (map[string]string, error) {
+    rows, err := db.QueryContext(ctx, `SELECT chat_id, level FROM notification_prefs WHERE user_id = $1`, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get notification levels: %v", err)
+    }
+    defer rows.Close()
+
+    levels := make(map[string]string)
+    for rows.Next() {
+        var chatID, level string
+        if err := rows.Scan(&chatID, &level); err != nil {
+            return nil, fmt.Errorf("failed to scan notification level: %v", err)
+        }
+        levels[chatID] = level
+    }
+    return levels, rows.Err()
+}
+
+// GetNotificationLevel returns the level of one conversation, "all" when the
+// user did not change it
+func (db *DB) GetNotificationLevel(ctx context.Context, userID, chatID string) (string, error) {
+    var level string
+    err := db.QueryRowContext(ctx, `
+        SELECT level FROM notification_prefs WHERE user_id = $1 AND chat_id = $2
+    `, userID, chatID).Scan(&level)
+    if err == sql.ErrNoRows {
+        return protocol.LevelAll, nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to get notification level: %v", err)
+    }
+    return level, nil
+}
+
+// AddMention records that a message names a user, a second record is ignored
+func (db *DB) AddMention(ctx context.Context, userID, messageID string) error {
+    _, err := db.ExecContext(ctx, `
+        INSERT INTO mentions (user_id, message_id) VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `, userID, messageID)
+    if err != nil {
+        return fmt.Errorf("failed to add mention: %v", err)
+    }
+    return nil
+}
+
+// GetMentions returns the newest messages naming a user first, leaving out
+// the deleted ones and those of the groups the user left. The archived ones
+// are marked, their content is read from the archive by the caller
+func (db *DB) GetMentions(ctx context.Context, userID string, limit int) ([]models.Mention, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT mn.message_id, COALESCE(m.group_id, a.group_id), COALESCE(g.name, ''), COALESCE(u.username, ''),
+               COALESCE(m.content, ''), COALESCE(m.sent_at, a.sent_at), m.id IS NULL
+        FROM mentions mn
+        LEFT JOIN messages m ON m.id = mn.message_id
+        LEFT JOIN archived_messages a ON a.id = mn.message_id
+        LEFT JOIN users u ON u.id = COALESCE(m.sender_id, a.sender_id)
+        LEFT JOIN groups g ON g.id = COALESCE(m.group_id, a.group_id)
+        WHERE mn.user_id = $1
+        AND ((m.id IS NOT NULL AND m.status != 'deleted') OR a.id IS NOT NULL)
+        AND (COALESCE(m.group_id, a.group_id) IS NULL OR EXISTS (
+            SELECT 1 FROM group_members gm WHERE gm.group_id = COALESCE(m.group_id, a.group_id) AND gm.user_id = $1
+        ))
+        ORDER BY COALESCE(m.sent_at, a.sent_at) DESC
+        LIMIT $2
+    `, userID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get mentions: %v", err)
+    }
+    defer rows.Close()
+
+    var mentions []models.Mention
+    for rows.Next() {
+        var mention models.Mention
+        if err := rows.Scan(&mention.MessageID, &mention.GroupID, &mention.GroupName, &mention.SenderName, &mention.Content, &mention.SentAt, &mention.Archived); err != nil {
+            return nil, fmt.Errorf("failed to scan mention: %v", err)
+        }
+        mentions = append(mentions, mention)
+    }
+    return mentions, rows.Err()
+}
@@ -2,25 +2,42 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"textual/internal/server/models"
+	"textual/pkg/protocol"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrDuplicateMessage is returned by SaveMessage when the sender already sent
+// a message with the same client ID, the message then holds the stored copy
+var ErrDuplicateMessage = errors.New("message already saved")
+
 type DB struct {
     *sql.DB
+    down      int32 // set by the health monitor while the database is unreachable
+    slowQuery time.Duration
+    presence  *presenceBuffer
+    quotas    Quotas
 }
 
 func NewDB(host, port, user, password, dbname string) (*DB, error) {
     connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
         host, port, user, password, dbname)
-    
+    return Open(connStr)
+}
+
+// Open connects with a connection string of lib/pq
+func Open(connStr string) (*DB, error) {
     db, err := sql.Open("postgres", connStr)
     if err != nil {
         return nil, err
@@ -30,28 +47,36 @@ func NewDB(host, port, user, password, dbname string) (*DB, error) {
         return nil, err
     }
 
-    return &DB{db}, nil
+    return &DB{DB: db}, nil
 }
 
 
-func (db *DB) AuthenticateUser(username, password string) (*models.User, error) {
+func (db *DB) AuthenticateUser(ctx context.Context, username, password string) (*models.User, error) {
     var user models.User
     var hashedPassword string
 
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT id, username, password_hash, status, last_seen
         FROM users 
         WHERE username = $1
     `, username).Scan(&user.ID, &user.Username, &hashedPassword, &user.Status, &user.LastSeen)
 
     if err == sql.ErrNoRows {
+        // the name of the system account is reserved, in any case
+        if strings.EqualFold(username, protocol.SystemUsername) {
+            return nil, fmt.Errorf("username reserved")
+        }
+        if !validUsername(username) {
+            return nil, fmt.Errorf("invalid username")
+        }
+
         // Create new user
         hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
         if err != nil {
             return nil, fmt.Errorf("error hashing password: %v", err)
         }
 
-        err = db.QueryRow(`
+        err = db.QueryRowContext(ctx, `
             INSERT INTO users (username, password_hash, status, last_seen)
             VALUES ($1, $2, 'offline', NOW())
             RETURNING id, username, status, last_seen
@@ -71,12 +96,11 @@ func (db *DB) AuthenticateUser(username, password string) (*models.User, error)
         }
     }
 
-    // Update last login and status
-    _, err = db.Exec(`
+    // the status and last_seen go through QueueUserStatus, batched with the
+    // others
+    _, err = db.ExecContext(ctx, `
         UPDATE users 
-        SET last_login = NOW(),
-            last_seen = NOW(),
-            status = 'online'
+        SET last_login = NOW()
         WHERE id = $1
     `, user.ID)
 
@@ -87,9 +111,24 @@ func (db *DB) AuthenticateUser(username, password string) (*models.User, error)
     return &user, nil
 }
 
-func (db *DB) GetUser(userID string) (*models.User, error) {
+// validUsername tells if a new account may take a name: the names are shown
+// everywhere, a control character would drive the terminals showing them
+// and an invisible one would pass for another user
+func validUsername(username string) bool {
+    if !utf8.ValidString(username) {
+        return false
+    }
+    for _, r := range username {
+        if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+            return false
+        }
+    }
+    return true
+}
+
+func (db *DB) GetUser(ctx context.Context, userID string) (*models.User, error) {
     var user models.User
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT id, username, status, last_seen
         FROM users
         WHERE id = $1
@@ -98,11 +137,52 @@ func (db *DB) GetUser(userID string) (*models.User, error) {
     if err == sql.ErrNoRows {
         return nil, fmt.Errorf("user not found")
     }
+    if status, ok := db.queuedStatus(user.ID); ok {
+        user.Status = status
+    }
     return &user, err
 }
 
-func (db *DB) UpdateUserStatus(userID, status string) error {
-    result, err := db.Exec(`
+// activeStatusText selects the status text of u, or '' once it expired
+const activeStatusText = `
+    CASE WHEN u.status_text_expires_at IS NULL OR u.status_text_expires_at > NOW()
+        THEN u.status_text ELSE '' END`
+
+// UpdateUserStatusText sets the custom status of a user, kept until
+// expiresAt when set
+func (db *DB) UpdateUserStatusText(ctx context.Context, userID, text string, expiresAt *time.Time) error {
+    _, err := db.ExecContext(ctx, `
+        UPDATE users
+        SET status_text = $1, status_text_expires_at = $2
+        WHERE id = $3
+    `, text, expiresAt, userID)
+    if err != nil {
+        return fmt.Errorf("failed to update status text: %v", err)
+    }
+    return nil
+}
+
+// GetStatusText returns the custom status of a user and when it expires, an
+// expired one is empty
+func (db *DB) GetStatusText(ctx context.Context, userID string) (string, *time.Time, error) {
+    var text string
+    var expiresAt *time.Time
+    err := db.QueryRowContext(ctx, `
+        SELECT `+activeStatusText+`, u.status_text_expires_at
+        FROM users u
+        WHERE u.id = $1
+    `, userID).Scan(&text, &expiresAt)
+    if err != nil {
+        return "", nil, fmt.Errorf("failed to get status text: %v", err)
+    }
+    if text == "" {
+        expiresAt = nil
+    }
+    return text, expiresAt, nil
+}
+
+func (db *DB) UpdateUserStatus(ctx context.Context, userID, status string) error {
+    result, err := db.ExecContext(ctx, `
         UPDATE users
         SET status = $1,
             last_seen = NOW()
@@ -125,9 +205,9 @@ func (db *DB) UpdateUserStatus(userID, status string) error {
 }
 
 // Friend management methods
-func (db *DB) GetFriends(userID string) ([]models.User, error) {
-    rows, err := db.Query(`
-        SELECT u.id, u.username, u.status, u.last_seen
+func (db *DB) GetFriends(ctx context.Context, userID string) ([]models.User, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT u.id, u.username, u.status, u.last_seen, `+activeStatusText+`, u.status_text_expires_at
         FROM users u
         JOIN friends f ON (f.user_id1 = $1 AND f.user_id2 = u.id)
            OR (f.user_id2 = $1 AND f.user_id1 = u.id)
@@ -142,10 +222,16 @@ func (db *DB) GetFriends(userID string) ([]models.User, error) {
     var friends []models.User
     for rows.Next() {
         var friend models.User
-        err := rows.Scan(&friend.ID, &friend.Username, &friend.Status, &friend.LastSeen)
+        err := rows.Scan(&friend.ID, &friend.Username, &friend.Status, &friend.LastSeen, &friend.StatusText, &friend.StatusExpiresAt)
         if err != nil {
             return nil, err
         }
+        if friend.StatusText == "" {
+            friend.StatusExpiresAt = nil
+        }
+        if status, ok := db.queuedStatus(friend.ID); ok {
+            friend.Status = status
+        }
         friends = append(friends, friend)
     }
 
@@ -153,8 +239,13 @@ func (db *DB) GetFriends(userID string) ([]models.User, error) {
 }
 
 
-func (db *DB) CreateFriendRequest(fromUserID, toUserID string) error {
-    _, err := db.Exec(`
+// CreateFriendRequest stores a pending request, a QuotaError is returned
+// when one of the users has the most friends allowed
+func (db *DB) CreateFriendRequest(ctx context.Context, fromUserID, toUserID string) error {
+    if err := db.checkFriendQuota(ctx, fromUserID, toUserID); err != nil {
+        return err
+    }
+    _, err := db.ExecContext(ctx, `
         INSERT INTO friends (user_id1, user_id2, status, created_at)
         VALUES ($1, $2, 'pending', NOW())
         ON CONFLICT (user_id1, user_id2) DO UPDATE
@@ -165,8 +256,12 @@ func (db *DB) CreateFriendRequest(fromUserID, toUserID string) error {
     return err
 }
 
-func (db *DB) AcceptFriendRequest(userID1, userID2 string) error {
-    result, err := db.Exec(`
+func (db *DB) AcceptFriendRequest(ctx context.Context, userID1, userID2 string) error {
+    // other requests may have been accepted since this one was sent
+    if err := db.checkFriendQuota(ctx, userID1, userID2); err != nil {
+        return err
+    }
+    result, err := db.ExecContext(ctx, `
         UPDATE friends
         SET status = 'accepted',
             updated_at = NOW()
@@ -187,13 +282,16 @@ func (db *DB) AcceptFriendRequest(userID1, userID2 string) error {
 }
 
 // Group management methods
-func (db *DB) CreateGroup(name, description, creatorID string) (*models.Group, error) {
+func (db *DB) CreateGroup(ctx context.Context, name, description, creatorID string, public, encrypted, announcement bool) (*models.Group, error) {
+    if err := db.checkGroupQuotas(ctx, creatorID, ""); err != nil {
+        return nil, err
+    }
     var group models.Group
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         WITH new_group AS (
-            INSERT INTO groups (name, description, created_by)
-            VALUES ($1, $2, $3)
-            RETURNING id, name, description, created_by, created_at
+            INSERT INTO groups (name, description, created_by, is_public, is_encrypted, is_announcement)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id, name, description, created_by, created_at, is_public, is_encrypted, is_announcement
         )
         INSERT INTO group_members (group_id, user_id, role)
         SELECT id, $3, 'admin'
@@ -202,13 +300,19 @@ func (db *DB) CreateGroup(name, description, creatorID string) (*models.Group, e
                   (SELECT name FROM new_group),
                   (SELECT description FROM new_group),
                   (SELECT created_by FROM new_group),
-                  (SELECT created_at FROM new_group)
-    `, name, description, creatorID).Scan(
+                  (SELECT created_at FROM new_group),
+                  (SELECT is_public FROM new_group),
+                  (SELECT is_encrypted FROM new_group),
+                  (SELECT is_announcement FROM new_group)
+    `, name, description, creatorID, public, encrypted, announcement).Scan(
         &group.ID,
         &group.Name,
         &group.Description,
         &group.CreatedBy,
         &group.CreatedAt,
+        &group.Public,
+        &group.Encrypted,
+        &group.Announcement,
     )
 
     if err != nil {
@@ -216,13 +320,16 @@ func (db *DB) CreateGroup(name, description, creatorID string) (*models.Group, e
     }
 
     group.Members = []string{creatorID}
+    if group.Announcement {
+        group.Posters = []string{creatorID}
+    }
     return &group, nil
 }
 
-func (db *DB) GetGroup(groupID string) (*models.Group, error) {
+func (db *DB) GetGroup(ctx context.Context, groupID string) (*models.Group, error) {
     var group models.Group
-    err := db.QueryRow(`
-        SELECT id, name, description, created_by, created_at
+    err := db.QueryRowContext(ctx, `
+        SELECT id, name, description, created_by, created_at, is_public, is_encrypted, is_announcement, topic
         FROM groups
         WHERE id = $1 AND status != 'deleted'
     `, groupID).Scan(
@@ -231,13 +338,22 @@ func (db *DB) GetGroup(groupID string) (*models.Group, error) {
         &group.Description,
         &group.CreatedBy,
         &group.CreatedAt,
+        &group.Public,
+        &group.Encrypted,
+        &group.Announcement,
+        &group.Topic,
     )
 
     if err != nil {
         return nil, err
     }
+    if group.Announcement {
+        if group.Posters, err = db.GetGroupAdmins(ctx, groupID); err != nil {
+            return nil, err
+        }
+    }
 
-    members, err := db.GetGroupMembers(groupID)
+    members, err := db.GetGroupMembers(ctx, groupID)
     if err != nil {
         return nil, err
     }
@@ -246,8 +362,8 @@ func (db *DB) GetGroup(groupID string) (*models.Group, error) {
     return &group, nil
 }
 
-func (db *DB) GetGroupMembers(groupID string) ([]string, error) {
-    rows, err := db.Query(`
+func (db *DB) GetGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+    rows, err := db.QueryContext(ctx, `
         SELECT user_id
         FROM group_members
         WHERE group_id = $1
@@ -268,8 +384,46 @@ func (db *DB) GetGroupMembers(groupID string) ([]string, error) {
     return members, nil
 }
 
-func (db *DB) AddUserToGroup(userID, groupID string) error {
-    _, err := db.Exec(`
+// GetGroupMemberDetails returns the members of a group with their username
+// and role, admins first
+func (db *DB) GetGroupMemberDetails(ctx context.Context, groupID string) ([]models.GroupMember, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT gm.group_id, gm.user_id, u.username, gm.role, u.status, gm.joined_at
+        FROM group_members gm
+        JOIN users u ON u.id = gm.user_id
+        WHERE gm.group_id = $1
+        ORDER BY gm.role = 'admin' DESC, u.username
+    `, groupID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get group members: %v", err)
+    }
+    defer rows.Close()
+
+    var members []models.GroupMember
+    for rows.Next() {
+        var member models.GroupMember
+        err := rows.Scan(&member.GroupID, &member.UserID, &member.Username, &member.Role, &member.Status, &member.JoinedAt)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan group member: %v", err)
+        }
+        if status, ok := db.queuedStatus(member.UserID); ok {
+            member.Status = status
+        }
+        members = append(members, member)
+    }
+    return members, rows.Err()
+}
+
+// AddUserToGroup adds a member to a group, a QuotaError is returned when the
+// group is full or the user in the most groups allowed
+func (db *DB) AddUserToGroup(ctx context.Context, userID, groupID string) error {
+    if isMember, err := db.IsGroupMember(ctx, userID, groupID); err != nil || isMember {
+        return err
+    }
+    if err := db.checkGroupQuotas(ctx, userID, groupID); err != nil {
+        return err
+    }
+    _, err := db.ExecContext(ctx, `
         INSERT INTO group_members (group_id, user_id, role)
         VALUES ($1, $2, 'member')
         ON CONFLICT (group_id, user_id) DO NOTHING
@@ -277,9 +431,9 @@ func (db *DB) AddUserToGroup(userID, groupID string) error {
     return err
 }
 
-func (db *DB) IsGroupMember(userID, groupID string) (bool, error) {
+func (db *DB) IsGroupMember(ctx context.Context, userID, groupID string) (bool, error) {
     var exists bool
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT EXISTS(
             SELECT 1 
             FROM group_members 
@@ -289,19 +443,70 @@ func (db *DB) IsGroupMember(userID, groupID string) (bool, error) {
     return exists, err
 }
 
+// IsAnnouncementGroup tells if only the admins of a group can post in it
+func (db *DB) IsAnnouncementGroup(ctx context.Context, groupID string) (bool, error) {
+    var announcement bool
+    err := db.QueryRowContext(ctx, `
+        SELECT is_announcement FROM groups WHERE id = $1
+    `, groupID).Scan(&announcement)
+    return announcement, err
+}
+
+// GetGroupAdmins returns the IDs of the admins of a group
+func (db *DB) GetGroupAdmins(ctx context.Context, groupID string) ([]string, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT user_id FROM group_members WHERE group_id = $1 AND role = 'admin'
+    `, groupID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get group admins: %v", err)
+    }
+    defer rows.Close()
+
+    var admins []string
+    for rows.Next() {
+        var id string
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("failed to scan group admin: %v", err)
+        }
+        admins = append(admins, id)
+    }
+    return admins, rows.Err()
+}
+
+// IsGroupEncrypted tells if the messages of a group are end-to-end encrypted
+func (db *DB) IsGroupEncrypted(ctx context.Context, groupID string) (bool, error) {
+    var encrypted bool
+    err := db.QueryRowContext(ctx, `
+        SELECT is_encrypted FROM groups WHERE id = $1
+    `, groupID).Scan(&encrypted)
+    return encrypted, err
+}
+
 // Message management methods
-func (db *DB) SaveMessage(msg *models.Message) error {
+func (db *DB) SaveMessage(ctx context.Context, msg *models.Message) error {
     // S'assurer que le message a une date d'envoi
     if msg.SentAt.IsZero() {
         msg.SentAt = time.Now()
     }
 
-    err := db.QueryRow(`
-        INSERT INTO messages (sender_id, recipient_id, group_id, content, sent_at, status)
-        VALUES ($1, $2, $3, $4, $5, 'sent')
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO messages (sender_id, recipient_id, group_id, content, sent_at, status, client_id, thread_root_id)
+        VALUES ($1, $2, $3, $4, $5, 'sent', NULLIF($6, ''), $7)
+        ON CONFLICT (sender_id, client_id) WHERE client_id IS NOT NULL DO NOTHING
         RETURNING id
-    `, msg.SenderID, msg.RecipientID, msg.GroupID, msg.Content, msg.SentAt).Scan(&msg.ID)
-    
+    `, msg.SenderID, msg.RecipientID, msg.GroupID, msg.Content, msg.SentAt, msg.ClientID, msg.ThreadRootID).Scan(&msg.ID)
+
+    // nothing inserted: the message was retried
+    if err == sql.ErrNoRows && msg.ClientID != "" {
+        err = db.QueryRowContext(ctx, `
+            SELECT id, content, sent_at FROM messages
+            WHERE sender_id = $1 AND client_id = $2
+        `, msg.SenderID, msg.ClientID).Scan(&msg.ID, &msg.Content, &msg.SentAt)
+        if err != nil {
+            return fmt.Errorf("failed to get retried message: %v", err)
+        }
+        return ErrDuplicateMessage
+    }
     if err != nil {
         return fmt.Errorf("failed to save message: %v", err)
     }
@@ -309,8 +514,8 @@ func (db *DB) SaveMessage(msg *models.Message) error {
     return nil
 }
 
-func (db *DB) GetMessages(userID string, limit int) ([]models.Message, error) {
-    rows, err := db.Query(`
+func (db *DB) GetMessages(ctx context.Context, userID string, limit int) ([]models.Message, error) {
+    rows, err := db.QueryContext(ctx, `
         SELECT messages.id, 
                messages.content, 
                messages.sender_id, 
@@ -318,10 +523,13 @@ func (db *DB) GetMessages(userID string, limit int) ([]models.Message, error) {
                messages.group_id, 
                messages.sent_at,
                messages.read_at,
-               users.username as sender_name
+               messages.edited_at,
+               users.username as sender_name,
+               (SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = messages.id) as reply_count
         FROM messages 
         LEFT JOIN users ON messages.sender_id = users.id
         WHERE (messages.recipient_id IS NULL AND messages.group_id IS NULL)
+        AND messages.thread_root_id IS NULL
         ORDER BY messages.sent_at DESC
         LIMIT $1
     `, limit)
@@ -343,7 +551,9 @@ func (db *DB) GetMessages(userID string, limit int) ([]models.Message, error) {
             &msg.GroupID,
             &msg.SentAt,
             &readAt,
+            &msg.EditedAt,
             &msg.SenderName,
+            &msg.ReplyCount,
         ); err != nil {
             return nil, err
         }
@@ -358,8 +568,8 @@ func (db *DB) GetMessages(userID string, limit int) ([]models.Message, error) {
     return messages, nil
 }
 
-func (db *DB) GetMessagesBeforeID(userID string, beforeID string, limit int) ([]models.Message, error) {
-    rows, err := db.Query(`
+func (db *DB) GetMessagesBeforeID(ctx context.Context, userID string, beforeID string, limit int) ([]models.Message, error) {
+    rows, err := db.QueryContext(ctx, `
         WITH msg AS (
             SELECT sent_at 
             FROM messages 
@@ -372,11 +582,14 @@ func (db *DB) GetMessagesBeforeID(userID string, beforeID string, limit int) ([]
                messages.group_id, 
                messages.sent_at,
                messages.read_at,
-               users.username as sender_name
+               messages.edited_at,
+               users.username as sender_name,
+               (SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = messages.id) as reply_count
         FROM messages 
         LEFT JOIN users ON messages.sender_id = users.id
         CROSS JOIN msg
         WHERE (messages.recipient_id IS NULL AND messages.group_id IS NULL)
+        AND messages.thread_root_id IS NULL
         AND messages.sent_at < (SELECT sent_at FROM msg)
         ORDER BY messages.sent_at DESC
         LIMIT $2
@@ -399,7 +612,9 @@ func (db *DB) GetMessagesBeforeID(userID string, beforeID string, limit int) ([]
             &msg.GroupID,
             &msg.SentAt,
             &readAt,
+            &msg.EditedAt,
             &msg.SenderName,
+            &msg.ReplyCount,
         ); err != nil {
             return nil, err
         }
@@ -415,8 +630,124 @@ func (db *DB) GetMessagesBeforeID(userID string, beforeID string, limit int) ([]
 }
 
 
-func (db *DB) MarkMessageAsRead(messageID string, userID string) error {
-    result, err := db.Exec(`
+// GetConversationMessages returns a page of the direct messages between two
+// users, or of a group when groupID is set, newest first. An empty beforeID
+// returns the latest messages.
+func (db *DB) GetConversationMessages(ctx context.Context, userID, otherID, groupID, beforeID string, limit int) ([]models.Message, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT messages.id,
+               messages.content,
+               messages.sender_id,
+               messages.recipient_id,
+               messages.group_id,
+               messages.sent_at,
+               messages.read_at,
+               messages.edited_at,
+               messages.status,
+               users.username as sender_name,
+               (SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = messages.id) as reply_count
+        FROM messages
+        LEFT JOIN users ON messages.sender_id = users.id
+        WHERE (
+            ($3 <> '' AND messages.group_id::text = $3)
+            OR ($3 = '' AND ((messages.sender_id::text = $1 AND messages.recipient_id::text = $2)
+                          OR (messages.sender_id::text = $2 AND messages.recipient_id::text = $1)))
+        )
+        AND messages.thread_root_id IS NULL
+        AND ($4 = '' OR messages.sent_at < (SELECT sent_at FROM messages WHERE id::text = $4))
+        ORDER BY messages.sent_at DESC
+        LIMIT $5
+    `, userID, otherID, groupID, beforeID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get conversation messages: %v", err)
+    }
+    defer rows.Close()
+
+    var messages []models.Message
+    for rows.Next() {
+        var msg models.Message
+        if err := rows.Scan(
+            &msg.ID,
+            &msg.Content,
+            &msg.SenderID,
+            &msg.RecipientID,
+            &msg.GroupID,
+            &msg.SentAt,
+            &msg.ReadAt,
+            &msg.EditedAt,
+            &msg.Status,
+            &msg.SenderName,
+            &msg.ReplyCount,
+        ); err != nil {
+            return nil, fmt.Errorf("failed to scan conversation message: %v", err)
+        }
+        messages = append(messages, msg)
+    }
+
+    return messages, nil
+}
+
+// GetMessagesAfter returns the messages of a conversation sent after a time
+// and before until when it is set, oldest first. Without otherID or groupID
+// the conversation is the global chat
+func (db *DB) GetMessagesAfter(ctx context.Context, userID, otherID, groupID string, after, until time.Time, limit int) ([]models.Message, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT messages.id,
+               messages.content,
+               messages.sender_id,
+               messages.recipient_id,
+               messages.group_id,
+               messages.sent_at,
+               messages.read_at,
+               messages.edited_at,
+               messages.status,
+               users.username as sender_name,
+               (SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = messages.id) as reply_count
+        FROM messages
+        LEFT JOIN users ON messages.sender_id = users.id
+        WHERE (
+            ($3 <> '' AND messages.group_id::text = $3)
+            OR ($3 = '' AND $2 <> '' AND ((messages.sender_id::text = $1 AND messages.recipient_id::text = $2)
+                                       OR (messages.sender_id::text = $2 AND messages.recipient_id::text = $1)))
+            OR ($3 = '' AND $2 = '' AND messages.recipient_id IS NULL AND messages.group_id IS NULL)
+        )
+        AND messages.thread_root_id IS NULL
+        AND messages.sent_at > $4
+        AND ($5::timestamptz IS NULL OR messages.sent_at < $5)
+        ORDER BY messages.sent_at ASC
+        LIMIT $6
+    `, userID, otherID, groupID, after, sql.NullTime{Time: until, Valid: !until.IsZero()}, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get messages after: %v", err)
+    }
+    defer rows.Close()
+
+    var messages []models.Message
+    for rows.Next() {
+        var msg models.Message
+        if err := rows.Scan(
+            &msg.ID,
+            &msg.Content,
+            &msg.SenderID,
+            &msg.RecipientID,
+            &msg.GroupID,
+            &msg.SentAt,
+            &msg.ReadAt,
+            &msg.EditedAt,
+            &msg.Status,
+            &msg.SenderName,
+            &msg.ReplyCount,
+        ); err != nil {
+            return nil, fmt.Errorf("failed to scan message: %v", err)
+        }
+        messages = append(messages, msg)
+    }
+
+    return messages, rows.Err()
+}
+
+func (db *DB) MarkMessageAsRead(ctx context.Context, messageID string, userID string) error {
+    result, err := db.ExecContext(ctx, `
         UPDATE messages 
         SET read_at = NOW(),
             status = 'read'
@@ -437,8 +768,122 @@ func (db *DB) MarkMessageAsRead(messageID string, userID string) error {
     return nil
 }
 
-func (db *DB) GetGroupMessages(groupID string) ([]models.Message, error) {
-    rows, err := db.Query(`
+func (db *DB) GetMessage(ctx context.Context, messageID string) (*models.Message, error) {
+    var msg models.Message
+    var senderID sql.NullString
+    err := db.QueryRowContext(ctx, `
+        SELECT messages.id,
+               messages.content,
+               messages.sender_id,
+               messages.recipient_id,
+               messages.group_id,
+               messages.sent_at,
+               messages.read_at,
+               messages.edited_at,
+               messages.status,
+               COALESCE(users.username, '') as sender_name,
+               messages.thread_root_id,
+               (SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = messages.id)
+        FROM messages
+        LEFT JOIN users ON messages.sender_id = users.id
+        WHERE messages.id = $1
+    `, messageID).Scan(
+        &msg.ID,
+        &msg.Content,
+        &senderID,
+        &msg.RecipientID,
+        &msg.GroupID,
+        &msg.SentAt,
+        &msg.ReadAt,
+        &msg.EditedAt,
+        &msg.Status,
+        &msg.SenderName,
+        &msg.ThreadRootID,
+        &msg.ReplyCount,
+    )
+
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("message not found")
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get message: %v", err)
+    }
+
+    msg.SenderID = senderID.String
+    return &msg, nil
+}
+
+// EditMessage replaces the content of a message, keeping the previous
+// content as a revision
+func (db *DB) EditMessage(ctx context.Context, messageID, editorID, content string) (*models.Message, error) {
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to begin edit transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    _, err = db.execTx(ctx, tx, `
+        INSERT INTO message_revisions (message_id, content, edited_by, edited_at)
+        SELECT id, content, $2, NOW()
+        FROM messages
+        WHERE id = $1
+    `, messageID, editorID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to save message revision: %v", err)
+    }
+
+    result, err := db.execTx(ctx, tx, `
+        UPDATE messages
+        SET content = $2,
+            edited_at = NOW()
+        WHERE id = $1
+    `, messageID, content)
+    if err != nil {
+        return nil, fmt.Errorf("failed to edit message: %v", err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get affected rows: %v", err)
+    }
+    if rows == 0 {
+        return nil, fmt.Errorf("message not found")
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("failed to commit message edit: %v", err)
+    }
+
+    return db.GetMessage(ctx, messageID)
+}
+
+// GetMessageRevisions returns the previous contents of a message, oldest first
+func (db *DB) GetMessageRevisions(ctx context.Context, messageID string) ([]models.MessageRevision, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, message_id, content, COALESCE(edited_by::text, ''), edited_at
+        FROM message_revisions
+        WHERE message_id = $1
+        ORDER BY edited_at ASC
+    `, messageID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get message revisions: %v", err)
+    }
+    defer rows.Close()
+
+    var revisions []models.MessageRevision
+    for rows.Next() {
+        var rev models.MessageRevision
+        if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.Content, &rev.EditedBy, &rev.EditedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan message revision: %v", err)
+        }
+        revisions = append(revisions, rev)
+    }
+
+    return revisions, nil
+}
+
+func (db *DB) GetGroupMessages(ctx context.Context, groupID string) ([]models.Message, error) {
+    rows, err := db.QueryContext(ctx, `
         SELECT id, content, sender_id, sent_at, read_at, users.username as sender_name
         FROM messages
         JOIN users ON messages.sender_id = users.id
@@ -474,8 +919,8 @@ func (db *DB) Close() error {
     return db.DB.Close()
 }
 
-func (db *DB) GetFriendList(userID string) ([]string, error) {
-    rows, err := db.Query(`
+func (db *DB) GetFriendList(ctx context.Context, userID string) ([]string, error) {
+    rows, err := db.QueryContext(ctx, `
         SELECT CASE 
             WHEN user_id1 = $1 THEN user_id2
             ELSE user_id1
@@ -501,8 +946,8 @@ func (db *DB) GetFriendList(userID string) ([]string, error) {
     return friendIDs, nil
 }
 
-func (db *DB) RemoveUserFromGroup(userID string, groupID string) error {
-    result, err := db.Exec(`
+func (db *DB) RemoveUserFromGroup(ctx context.Context, userID string, groupID string) error {
+    result, err := db.ExecContext(ctx, `
         DELETE FROM group_members
         WHERE group_id = $1 AND user_id = $2
         AND user_id NOT IN (
@@ -528,11 +973,11 @@ func (db *DB) RemoveUserFromGroup(userID string, groupID string) error {
     return nil
 }
 
-func (db *DB) RemoveFriend(userID1 string, userID2 string) error {
-    result, err := db.Exec(`
+func (db *DB) RemoveFriend(ctx context.Context, userID1 string, userID2 string) error {
+    result, err := db.ExecContext(ctx, `
         DELETE FROM friends
-        WHERE (user_id1 = $1 AND user_id2 = $2)
-           OR (user_id1 = $2 AND user_id2 = $1)
+        WHERE ((user_id1 = $1 AND user_id2 = $2)
+           OR (user_id1 = $2 AND user_id2 = $1))
         AND status = 'accepted'
     `, userID1, userID2)
 
@@ -552,25 +997,74 @@ func (db *DB) RemoveFriend(userID1 string, userID2 string) error {
     return nil
 }
 
-func (db *DB) BlockUser(userID string, blockedUserID string) error {
-    _, err := db.Exec(`
+// BlockUser replaces any relationship between the two users (friendship,
+// pending request) with a block
+func (db *DB) BlockUser(ctx context.Context, userID string, blockedUserID string) error {
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin block transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    _, err = db.execTx(ctx, tx, `
+        DELETE FROM friends
+        WHERE (user_id1 = $1 AND user_id2 = $2)
+           OR (user_id1 = $2 AND user_id2 = $1)
+    `, userID, blockedUserID)
+    if err != nil {
+        return fmt.Errorf("failed to remove friendship: %v", err)
+    }
+
+    _, err = db.execTx(ctx, tx, `
         INSERT INTO friends (user_id1, user_id2, status, created_at)
         VALUES ($1, $2, 'blocked', NOW())
-        ON CONFLICT (user_id1, user_id2) DO UPDATE
-        SET status = 'blocked',
-            updated_at = NOW()
     `, userID, blockedUserID)
-
     if err != nil {
         return fmt.Errorf("failed to block user: %v", err)
     }
 
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit block: %v", err)
+    }
     return nil
 }
 
-func (db *DB) GetGroupRole(userID string, groupID string) (string, error) {
+// IsBlocked reports whether one of the two users blocked the other
+func (db *DB) IsBlocked(ctx context.Context, userID1 string, userID2 string) (bool, error) {
+    var blocked bool
+    err := db.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM friends
+            WHERE ((user_id1 = $1 AND user_id2 = $2)
+               OR (user_id1 = $2 AND user_id2 = $1))
+            AND status = 'blocked'
+        )
+    `, userID1, userID2).Scan(&blocked)
+    if err != nil {
+        return false, fmt.Errorf("failed to check block: %v", err)
+    }
+    return blocked, nil
+}
+
+// HasBlocked reports whether blockerID blocked userID
+func (db *DB) HasBlocked(ctx context.Context, blockerID, userID string) (bool, error) {
+    var blocked bool
+    err := db.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM friends
+            WHERE user_id1 = $1 AND user_id2 = $2
+            AND status = 'blocked'
+        )
+    `, blockerID, userID).Scan(&blocked)
+    if err != nil {
+        return false, fmt.Errorf("failed to check block: %v", err)
+    }
+    return blocked, nil
+}
+
+func (db *DB) GetGroupRole(ctx context.Context, userID string, groupID string) (string, error) {
     var role string
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT role
         FROM group_members
         WHERE group_id = $1 AND user_id = $2
@@ -586,10 +1080,10 @@ func (db *DB) GetGroupRole(userID string, groupID string) (string, error) {
     return role, nil
 }
 
-func (db *DB) UpdateGroupRole(userID string, groupID string, newRole string) error {
+func (db *DB) UpdateGroupRole(ctx context.Context, userID string, groupID string, newRole string) error {
     // check if user is the creator of the group
     var creatorID string
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT created_by
         FROM groups
         WHERE id = $1
@@ -603,7 +1097,7 @@ func (db *DB) UpdateGroupRole(userID string, groupID string, newRole string) err
         return fmt.Errorf("cannot change role of group creator")
     }
 
-    result, err := db.Exec(`
+    result, err := db.ExecContext(ctx, `
         UPDATE group_members
         SET role = $3
         WHERE group_id = $1 AND user_id = $2
@@ -625,9 +1119,9 @@ func (db *DB) UpdateGroupRole(userID string, groupID string, newRole string) err
     return nil
 }
 
-func (db *DB) GetUnreadMessageCount(userID string) (int, error) {
+func (db *DB) GetUnreadMessageCount(ctx context.Context, userID string) (int, error) {
     var count int
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT COUNT(*)
         FROM messages
         WHERE (recipient_id = $1 OR 
@@ -643,9 +1137,9 @@ func (db *DB) GetUnreadMessageCount(userID string) (int, error) {
     return count, nil
 }
 
-func (db *DB) GetUserGroups(userID string) ([]models.Group, error) {
-    rows, err := db.Query(`
-        SELECT g.id, g.name, g.description, g.created_by, g.created_at, g.status
+func (db *DB) GetUserGroups(ctx context.Context, userID string) ([]models.Group, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT g.id, g.name, g.description, g.created_by, g.created_at, g.status, g.is_public, g.is_encrypted, g.is_announcement, g.topic
         FROM groups g
         JOIN group_members gm ON g.id = gm.group_id
         WHERE gm.user_id = $1 AND g.status != 'deleted'
@@ -665,13 +1159,22 @@ func (db *DB) GetUserGroups(userID string) ([]models.Group, error) {
             &group.CreatedBy,
             &group.CreatedAt,
             &group.Status,
+            &group.Public,
+            &group.Encrypted,
+            &group.Announcement,
+            &group.Topic,
         )
         if err != nil {
             return nil, fmt.Errorf("failed to scan group: %v", err)
         }
+        if group.Announcement {
+            if group.Posters, err = db.GetGroupAdmins(ctx, group.ID); err != nil {
+                return nil, err
+            }
+        }
 
         // Get group members
-        members, err := db.GetGroupMembers(group.ID)
+        members, err := db.GetGroupMembers(ctx, group.ID)
         if err != nil {
             return nil, fmt.Errorf("failed to get group members: %v", err)
         }
@@ -683,9 +1186,86 @@ func (db *DB) GetUserGroups(userID string) ([]models.Group, error) {
     return groups, nil
 }
 
-func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+// GetPublicGroups returns the public groups, the biggest first, telling
+// whether userID is already a member
+func (db *DB) GetPublicGroups(ctx context.Context, userID string, limit int) ([]models.GroupSummary, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT g.id, g.name, COALESCE(g.description, ''),
+               COUNT(gm.user_id),
+               COALESCE(BOOL_OR(gm.user_id = $1), false),
+               g.is_announcement
+        FROM groups g
+        LEFT JOIN group_members gm ON gm.group_id = g.id
+        WHERE g.is_public AND g.status = 'active'
+        GROUP BY g.id
+        ORDER BY COUNT(gm.user_id) DESC, g.name
+        LIMIT $2
+    `, userID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get public groups: %v", err)
+    }
+    defer rows.Close()
+
+    var groups []models.GroupSummary
+    for rows.Next() {
+        var group models.GroupSummary
+        if err := rows.Scan(&group.ID, &group.Name, &group.Description, &group.MemberCount, &group.IsMember, &group.Announcement); err != nil {
+            return nil, fmt.Errorf("failed to scan public group: %v", err)
+        }
+        groups = append(groups, group)
+    }
+    return groups, rows.Err()
+}
+
+// SearchUsers returns the users whose name starts with query, or the online
+// ones when it is empty, the online first. The accounts without password,
+// system and imported ones, and the users blocked either way are left out
+func (db *DB) SearchUsers(ctx context.Context, userID, query string, limit int) ([]models.UserSummary, error) {
+    pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(query)) + "%"
+    rows, err := db.QueryContext(ctx, `
+        SELECT u.id, u.username, u.status, `+activeStatusText+`,
+               EXISTS (
+                   SELECT 1 FROM friends f
+                   WHERE ((f.user_id1 = $1 AND f.user_id2 = u.id) OR (f.user_id2 = $1 AND f.user_id1 = u.id))
+                   AND f.status = 'accepted'
+               )
+        FROM users u
+        WHERE u.id <> $1 AND u.password_hash <> '!'
+        AND (($2 = '' AND u.status <> 'offline') OR ($2 <> '' AND LOWER(u.username) LIKE $3))
+        AND NOT EXISTS (
+            SELECT 1 FROM friends f
+            WHERE ((f.user_id1 = $1 AND f.user_id2 = u.id) OR (f.user_id2 = $1 AND f.user_id1 = u.id))
+            AND f.status = 'blocked'
+        )
+        ORDER BY u.status = 'offline', LOWER(u.username)
+        LIMIT $4
+    `, userID, query, pattern, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to search users: %v", err)
+    }
+    defer rows.Close()
+
+    var users []models.UserSummary
+    for rows.Next() {
+        var user models.UserSummary
+        if err := rows.Scan(&user.ID, &user.Username, &user.Status, &user.StatusText, &user.IsFriend); err != nil {
+            return nil, fmt.Errorf("failed to scan user: %v", err)
+        }
+        if status, ok := db.queuedStatus(user.ID); ok {
+            user.Status = status
+        }
+        // logged out, the status is not written yet
+        if query == "" && user.Status == models.StatusOffline {
+            continue
+        }
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+func (db *DB) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
     var user models.User
-    err := db.QueryRow(`
+    err := db.QueryRowContext(ctx, `
         SELECT id, username, status, last_seen
         FROM users
         WHERE LOWER(username) = LOWER($1)
@@ -705,9 +1285,16 @@ func (db *DB) GetUserByUsername(username string) (*models.User, error) {
 
 
 
-func (db *DB) GetFriendRequestUsers(requestID string) (*models.User, *models.User, error) {
-    // Extract user IDs from request ID format "fr-{fromID}-{toID}-{timestamp}"
-    parts := strings.Split(requestID, "-")
+// FriendRequestID names a friend request for the clients, the users are
+// read back from it by GetFriendRequestUsers
+func FriendRequestID(fromUserID, toUserID string, createdAt time.Time) string {
+    // the user IDs are UUIDs, they have dashes but no colon
+    return fmt.Sprintf("fr:%s:%s:%d", fromUserID, toUserID, createdAt.Unix())
+}
+
+func (db *DB) GetFriendRequestUsers(ctx context.Context, requestID string) (*models.User, *models.User, error) {
+    // Extract user IDs from request ID format "fr:{fromID}:{toID}:{timestamp}"
+    parts := strings.Split(requestID, ":")
     if len(parts) != 4 || parts[0] != "fr" {
         return nil, nil, fmt.Errorf("invalid request ID format")
     }
@@ -716,13 +1303,13 @@ func (db *DB) GetFriendRequestUsers(requestID string) (*models.User, *models.Use
     toID := parts[2]
 
     // Get sender info
-    fromUser, err := db.GetUser(fromID)
+    fromUser, err := db.GetUser(ctx, fromID)
     if err != nil {
         return nil, nil, fmt.Errorf("failed to get sender: %v", err)
     }
 
     // Get recipient info
-    toUser, err := db.GetUser(toID)
+    toUser, err := db.GetUser(ctx, toID)
     if err != nil {
         return nil, nil, fmt.Errorf("failed to get recipient: %v", err)
     }
@@ -730,8 +1317,8 @@ func (db *DB) GetFriendRequestUsers(requestID string) (*models.User, *models.Use
     return fromUser, toUser, nil
 }
 
-func (db *DB) RejectFriendRequest(fromUserID, toUserID string) error {
-    result, err := db.Exec(`
+func (db *DB) RejectFriendRequest(ctx context.Context, fromUserID, toUserID string) error {
+    result, err := db.ExecContext(ctx, `
         UPDATE friends
         SET status = 'rejected',
             updated_at = NOW()
@@ -751,8 +1338,27 @@ func (db *DB) RejectFriendRequest(fromUserID, toUserID string) error {
     return err
 }
 
-func (db *DB) GetPendingFriendRequests(userID string) ([]models.FriendRequest, error) {
-    rows, err := db.Query(`
+// FriendRequestRejectedAt returns when toUserID refused the last friend
+// request of fromUserID, zero when it didn't
+func (db *DB) FriendRequestRejectedAt(ctx context.Context, fromUserID, toUserID string) (time.Time, error) {
+    var rejectedAt time.Time
+    err := db.QueryRowContext(ctx, `
+        SELECT COALESCE(updated_at, created_at)
+        FROM friends
+        WHERE user_id1 = $1 AND user_id2 = $2
+        AND status = 'rejected'
+    `, fromUserID, toUserID).Scan(&rejectedAt)
+    if err == sql.ErrNoRows {
+        return time.Time{}, nil
+    }
+    if err != nil {
+        return time.Time{}, fmt.Errorf("failed to get friend request: %v", err)
+    }
+    return rejectedAt, nil
+}
+
+func (db *DB) GetPendingFriendRequests(ctx context.Context, userID string) ([]models.FriendRequest, error) {
+    rows, err := db.QueryContext(ctx, `
         SELECT f.user_id1, f.user_id2, f.created_at,
                u1.username as from_username,
                u2.username as to_username
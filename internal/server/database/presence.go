@@ -0,0 +1,130 @@
+// internal/server/database/presence.go
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type pendingStatus struct {
+    status   string
+    lastSeen time.Time
+}
+
+// presenceBuffer holds the status changes waiting to be written
+type presenceBuffer struct {
+    mu      sync.Mutex
+    pending map[string]pendingStatus
+}
+
+// StartPresenceWriter enables write-behind for QueueUserStatus: status and
+// last_seen changes are kept in memory and written in one batch every
+// interval. The returned stop function writes the remaining changes.
+func (db *DB) StartPresenceWriter(interval time.Duration) (stop func()) {
+    db.presence = &presenceBuffer{pending: make(map[string]pendingStatus)}
+    // the batches outlive the requests that queued them
+    ctx := context.Background()
+    done := make(chan struct{})
+    stopped := make(chan struct{})
+
+    go func() {
+        defer close(stopped)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+            select {
+            case <-done:
+                return
+            case <-ticker.C:
+                if err := db.FlushPresence(ctx); err != nil {
+                    log.Printf("Failed to flush presence updates: %v", err)
+                }
+            }
+        }
+    }()
+
+    return func() {
+        close(done)
+        <-stopped
+        if err := db.FlushPresence(ctx); err != nil {
+            log.Printf("Failed to flush presence updates: %v", err)
+        }
+    }
+}
+
+// QueueUserStatus records a status change, written immediately when the
+// presence writer is not running
+func (db *DB) QueueUserStatus(ctx context.Context, userID, status string) error {
+    if db.presence == nil {
+        return db.UpdateUserStatus(ctx, userID, status)
+    }
+
+    db.presence.mu.Lock()
+    db.presence.pending[userID] = pendingStatus{status: status, lastSeen: time.Now()}
+    db.presence.mu.Unlock()
+    return nil
+}
+
+// FlushPresence writes all queued status changes in a single statement
+func (db *DB) FlushPresence(ctx context.Context) error {
+    if db.presence == nil {
+        return nil
+    }
+
+    db.presence.mu.Lock()
+    pending := db.presence.pending
+    db.presence.pending = make(map[string]pendingStatus)
+    db.presence.mu.Unlock()
+
+    if len(pending) == 0 {
+        return nil
+    }
+
+    ids := make([]string, 0, len(pending))
+    statuses := make([]string, 0, len(pending))
+    lastSeen := make([]string, 0, len(pending))
+    for userID, p := range pending {
+        ids = append(ids, userID)
+        statuses = append(statuses, p.status)
+        lastSeen = append(lastSeen, p.lastSeen.Format(time.RFC3339Nano))
+    }
+
+    _, err := db.ExecContext(ctx, `
+        UPDATE users AS u
+        SET status = v.status,
+            last_seen = v.last_seen
+        FROM unnest($1::uuid[], $2::text[], $3::timestamptz[]) AS v(id, status, last_seen)
+        WHERE u.id = v.id
+    `, pq.Array(ids), pq.Array(statuses), pq.Array(lastSeen))
+    if err != nil {
+        // keep the changes for the next flush unless newer ones were queued
+        db.presence.mu.Lock()
+        for userID, p := range pending {
+            if _, ok := db.presence.pending[userID]; !ok {
+                db.presence.pending[userID] = p
+            }
+        }
+        db.presence.mu.Unlock()
+        return fmt.Errorf("failed to write presence batch: %v", err)
+    }
+
+    return nil
+}
+
+// queuedStatus returns the status waiting to be written for a user, if any
+func (db *DB) queuedStatus(userID string) (string, bool) {
+    if db.presence == nil {
+        return "", false
+    }
+
+    db.presence.mu.Lock()
+    defer db.presence.mu.Unlock()
+    p, ok := db.presence.pending[userID]
+    return p.status, ok
+}
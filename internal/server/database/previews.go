@@ -0,0 +1,73 @@
+// internal/server/database/previews.go
+package database
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+
+	"github.com/lib/pq"
+)
+
+// SaveLinkPreview sets the preview of a message, replacing the one of a link
+// edited out
+func (db *DB) SaveLinkPreview(ctx context.Context, messageID string, preview models.LinkPreview) error {
+    _, err := db.ExecContext(ctx, `
+        INSERT INTO link_previews (message_id, url, title, description, image_url, site_name)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (message_id) DO UPDATE SET
+            url = EXCLUDED.url,
+            title = EXCLUDED.title,
+            description = EXCLUDED.description,
+            image_url = EXCLUDED.image_url,
+            site_name = EXCLUDED.site_name,
+            fetched_at = CURRENT_TIMESTAMP
+    `, messageID, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.SiteName)
+    if err != nil {
+        return fmt.Errorf("failed to save link preview: %v", err)
+    }
+    return nil
+}
+
+// DeleteLinkPreview drops the preview of a message whose link was edited
+func (db *DB) DeleteLinkPreview(ctx context.Context, messageID string) error {
+    if _, err := db.ExecContext(ctx, `DELETE FROM link_previews WHERE message_id = $1`, messageID); err != nil {
+        return fmt.Errorf("failed to delete link preview: %v", err)
+    }
+    return nil
+}
+
+// AttachPreviews sets the link preview of the messages that have one
+func (db *DB) AttachPreviews(ctx context.Context, messages []models.Message) error {
+    if len(messages) == 0 {
+        return nil
+    }
+    ids := make([]string, 0, len(messages))
+    byID := make(map[string]*models.Message, len(messages))
+    for i := range messages {
+        ids = append(ids, messages[i].ID)
+        byID[messages[i].ID] = &messages[i]
+    }
+
+    rows, err := db.QueryContext(ctx, `
+        SELECT message_id, url, title, description, image_url, site_name
+        FROM link_previews
+        WHERE message_id = ANY($1::uuid[])
+    `, pq.Array(ids))
+    if err != nil {
+        return fmt.Errorf("failed to get link previews: %v", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var messageID string
+        var preview models.LinkPreview
+        if err := rows.Scan(&messageID, &preview.URL, &preview.Title, &preview.Description, &preview.ImageURL, &preview.SiteName); err != nil {
+            return fmt.Errorf("failed to scan link preview: %v", err)
+        }
+        if msg, ok := byID[messageID]; ok {
+            msg.Preview = &preview
+        }
+    }
+    return rows.Err()
+}
@@ -0,0 +1,93 @@
+// internal/server/database/quotas.go
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// the quotas a QuotaError names
+const (
+    QuotaMembersPerGroup = "members_per_group"
+    QuotaGroupsPerUser   = "groups_per_user"
+    QuotaFriendsPerUser  = "friends_per_user"
+)
+
+// Quotas limit the size of the groups and what one user can join, 0 for no
+// limit
+type Quotas struct {
+    MembersPerGroup int
+    GroupsPerUser   int
+    FriendsPerUser  int
+}
+
+// QuotaError is returned when a change would go past a quota, UserID is the
+// user at the limit (empty for a full group)
+type QuotaError struct {
+    Quota  string
+    Limit  int
+    UserID string
+}
+
+func (e *QuotaError) Error() string {
+    return fmt.Sprintf("%s quota of %d reached", e.Quota, e.Limit)
+}
+
+// SetQuotas sets the limits checked when a group is created or joined and
+// when a friend request is sent or accepted. Set them before serving
+func (db *DB) SetQuotas(quotas Quotas) {
+    db.quotas = quotas
+}
+
+// checkGroupQuotas tells if userID can join groupID, an empty groupID only
+// checks the groups of the user
+func (db *DB) checkGroupQuotas(ctx context.Context, userID, groupID string) error {
+    if limit := db.quotas.GroupsPerUser; limit > 0 {
+        var count int
+        err := db.QueryRowContext(ctx, `
+            SELECT COUNT(*) FROM group_members gm
+            JOIN groups g ON g.id = gm.group_id
+            WHERE gm.user_id = $1 AND g.status != 'deleted'
+        `, userID).Scan(&count)
+        if err != nil {
+            return fmt.Errorf("failed to count groups: %v", err)
+        }
+        if count >= limit {
+            return &QuotaError{Quota: QuotaGroupsPerUser, Limit: limit, UserID: userID}
+        }
+    }
+
+    if limit := db.quotas.MembersPerGroup; limit > 0 && groupID != "" {
+        var count int
+        err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM group_members WHERE group_id = $1`, groupID).Scan(&count)
+        if err != nil {
+            return fmt.Errorf("failed to count group members: %v", err)
+        }
+        if count >= limit {
+            return &QuotaError{Quota: QuotaMembersPerGroup, Limit: limit}
+        }
+    }
+    return nil
+}
+
+// checkFriendQuota tells if each user can have one more friend
+func (db *DB) checkFriendQuota(ctx context.Context, userIDs ...string) error {
+    limit := db.quotas.FriendsPerUser
+    if limit <= 0 {
+        return nil
+    }
+    for _, userID := range userIDs {
+        var count int
+        err := db.QueryRowContext(ctx, `
+            SELECT COUNT(*) FROM friends
+            WHERE (user_id1 = $1 OR user_id2 = $1) AND status = 'accepted'
+        `, userID).Scan(&count)
+        if err != nil {
+            return fmt.Errorf("failed to count friends: %v", err)
+        }
+        if count >= limit {
+            return &QuotaError{Quota: QuotaFriendsPerUser, Limit: limit, UserID: userID}
+        }
+    }
+    return nil
+}
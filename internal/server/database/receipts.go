@@ -0,0 +1,102 @@
+// internal/server/database/receipts.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MarkDelivered sets a direct message delivered, once a device of its
+// recipient got it
+func (db *DB) MarkDelivered(ctx context.Context, messageID string) error {
+    _, err := db.ExecContext(ctx, `
+        UPDATE messages SET status = 'delivered'
+        WHERE id = $1 AND status = 'sent'
+    `, messageID)
+    if err != nil {
+        return fmt.Errorf("failed to mark message as delivered: %v", err)
+    }
+    return nil
+}
+
+// DeliverPending sets delivered the direct messages sent to a user while
+// offline, it returns the last one of each sender
+func (db *DB) DeliverPending(ctx context.Context, userID string) (map[string]string, error) {
+    rows, err := db.QueryContext(ctx, `
+        UPDATE messages SET status = 'delivered'
+        WHERE recipient_id = $1 AND status = 'sent'
+        RETURNING id, sender_id, sent_at
+    `, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to deliver pending messages: %v", err)
+    }
+    defer rows.Close()
+
+    last := make(map[string]string)
+    lastAt := make(map[string]time.Time)
+    for rows.Next() {
+        var id string
+        var senderID sql.NullString
+        var sentAt time.Time
+        if err := rows.Scan(&id, &senderID, &sentAt); err != nil {
+            return nil, fmt.Errorf("failed to scan delivered message: %v", err)
+        }
+        // the sender may have deleted the account
+        if !senderID.Valid {
+            continue
+        }
+        if at, ok := lastAt[senderID.String]; !ok || sentAt.After(at) {
+            last[senderID.String] = id
+            lastAt[senderID.String] = sentAt
+        }
+    }
+    return last, rows.Err()
+}
+
+// MarkConversationRead sets read the direct messages otherID sent to userID,
+// up to messageID or all of them when it is empty. It returns false when
+// none was unread
+func (db *DB) MarkConversationRead(ctx context.Context, userID, otherID, messageID string) (bool, error) {
+    result, err := db.ExecContext(ctx, `
+        UPDATE messages SET status = 'read', read_at = NOW()
+        WHERE sender_id::text = $2 AND recipient_id::text = $1 AND read_at IS NULL
+        AND ($3 = '' OR sent_at <= (SELECT sent_at FROM messages WHERE id::text = $3))
+    `, userID, otherID, messageID)
+    if err != nil {
+        return false, fmt.Errorf("failed to mark conversation as read: %v", err)
+    }
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return false, err
+    }
+    return rows > 0, nil
+}
+
+// GetUnreadCounts returns how many direct messages of each sender userID has
+// not read
+func (db *DB) GetUnreadCounts(ctx context.Context, userID string) (map[string]int, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT sender_id::text, COUNT(*)
+        FROM messages
+        WHERE recipient_id::text = $1 AND read_at IS NULL AND sender_id IS NOT NULL
+        AND thread_root_id IS NULL
+        GROUP BY sender_id
+    `, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get unread counts: %v", err)
+    }
+    defer rows.Close()
+
+    counts := make(map[string]int)
+    for rows.Next() {
+        var senderID string
+        var count int
+        if err := rows.Scan(&senderID, &count); err != nil {
+            return nil, fmt.Errorf("failed to scan unread count: %v", err)
+        }
+        counts[senderID] = count
+    }
+    return counts, rows.Err()
+}
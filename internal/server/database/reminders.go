@@ -0,0 +1,124 @@
+// internal/server/database/reminders.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// CheckSystemUser fails when the account sending the reminders is missing,
+// or has another name: the migration creating it stops on a user already
+// named like it
+func (db *DB) CheckSystemUser(ctx context.Context) error {
+    var username string
+    err := db.QueryRowContext(ctx, `SELECT username FROM users WHERE id::text = $1`, protocol.SystemUserID).Scan(&username)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("system account %s missing, apply the migration 016_reminders.sql", protocol.SystemUserID)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to get the system account: %v", err)
+    }
+    if username != protocol.SystemUsername {
+        return fmt.Errorf("system account %s is named %q instead of %q", protocol.SystemUserID, username, protocol.SystemUsername)
+    }
+    return nil
+}
+
+// CreateReminder stores a reminder, its ID and creation time are set by the
+// database
+func (db *DB) CreateReminder(ctx context.Context, r *models.Reminder) error {
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO reminders (user_id, content, remind_at)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at
+    `, r.UserID, r.Content, r.RemindAt).Scan(&r.ID, &r.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to create reminder: %v", err)
+    }
+    return nil
+}
+
+// GetReminders returns the reminders of a user not delivered yet, soonest
+// first
+func (db *DB) GetReminders(ctx context.Context, userID string) ([]models.Reminder, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, user_id, content, remind_at, created_at
+        FROM reminders
+        WHERE user_id = $1 AND delivered_at IS NULL
+        ORDER BY remind_at
+    `, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get reminders: %v", err)
+    }
+    defer rows.Close()
+    return scanReminders(rows)
+}
+
+// CountReminders returns the number of reminders a user has waiting
+func (db *DB) CountReminders(ctx context.Context, userID string) (int, error) {
+    var count int
+    err := db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM reminders WHERE user_id = $1 AND delivered_at IS NULL
+    `, userID).Scan(&count)
+    if err != nil {
+        return 0, fmt.Errorf("failed to count reminders: %v", err)
+    }
+    return count, nil
+}
+
+// CancelReminder deletes a reminder of the user not delivered yet, it returns
+// false when there is none with this ID
+func (db *DB) CancelReminder(ctx context.Context, userID, reminderID string) (bool, error) {
+    result, err := db.ExecContext(ctx, `
+        DELETE FROM reminders
+        WHERE id = $1 AND user_id = $2 AND delivered_at IS NULL
+    `, reminderID, userID)
+    if err != nil {
+        return false, fmt.Errorf("failed to cancel reminder: %v", err)
+    }
+    deleted, err := result.RowsAffected()
+    return deleted > 0, err
+}
+
+// GetDueReminders returns the reminders to deliver at now, the oldest first
+func (db *DB) GetDueReminders(ctx context.Context, now time.Time, limit int) ([]models.Reminder, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT id, user_id, content, remind_at, created_at
+        FROM reminders
+        WHERE delivered_at IS NULL AND remind_at <= $1
+        ORDER BY remind_at
+        LIMIT $2
+    `, now, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get due reminders: %v", err)
+    }
+    defer rows.Close()
+    return scanReminders(rows)
+}
+
+// MarkReminderDelivered keeps a delivered reminder out of the next rounds
+func (db *DB) MarkReminderDelivered(ctx context.Context, reminderID string) error {
+    _, err := db.ExecContext(ctx, `
+        UPDATE reminders SET delivered_at = CURRENT_TIMESTAMP WHERE id = $1
+    `, reminderID)
+    if err != nil {
+        return fmt.Errorf("failed to mark reminder as delivered: %v", err)
+    }
+    return nil
+}
+
+func scanReminders(rows *sql.Rows) ([]models.Reminder, error) {
+    var reminders []models.Reminder
+    for rows.Next() {
+        var r models.Reminder
+        if err := rows.Scan(&r.ID, &r.UserID, &r.Content, &r.RemindAt, &r.CreatedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan reminder: %v", err)
+        }
+        reminders = append(reminders, r)
+    }
+    return reminders, rows.Err()
+}
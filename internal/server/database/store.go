@@ -0,0 +1,157 @@
+// internal/server/database/store.go
+package database
+
+import (
+	"context"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// Store is what the server keeps: DB on PostgreSQL, Memory in the process
+// for the tests and the standalone client
+type Store interface {
+    // lifecycle
+    ConfigurePool(maxOpen, maxIdle int, maxLifetime time.Duration)
+    Healthy() bool
+    MonitorHealth(interval time.Duration, onChange func(healthy bool)) (stop func())
+    SetSlowQueryThreshold(threshold time.Duration)
+    StartPresenceWriter(interval time.Duration) (stop func())
+    SetQuotas(quotas Quotas)
+    Close() error
+
+    // users
+    AuthenticateUser(ctx context.Context, username, password string) (*models.User, error)
+    VerifyPassword(ctx context.Context, username, password string) (*models.User, error)
+    GetUser(ctx context.Context, userID string) (*models.User, error)
+    GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+    SearchUsers(ctx context.Context, userID, query string, limit int) ([]models.UserSummary, error)
+    QueueUserStatus(ctx context.Context, userID, status string) error
+    UpdateUserStatusText(ctx context.Context, userID, text string, expiresAt *time.Time) error
+    GetStatusText(ctx context.Context, userID string) (string, *time.Time, error)
+    SetDisplayPrefs(ctx context.Context, userID string, prefs models.DisplayPrefs) error
+    GetDisplayPrefs(ctx context.Context, userID string) (models.DisplayPrefs, error)
+    CheckSystemUser(ctx context.Context) error
+
+    // API tokens
+    CreateAPIToken(ctx context.Context, userID, name, tokenHash string) (string, time.Time, error)
+    GetAPITokenUser(ctx context.Context, tokenHash string) (*models.User, string, error)
+    DeleteUserAPITokens(ctx context.Context, userID string) (int64, error)
+    DeleteAPIToken(ctx context.Context, userID, tokenID string) (bool, error)
+
+    // friends and blocks
+    GetFriends(ctx context.Context, userID string) ([]models.User, error)
+    GetFriendList(ctx context.Context, userID string) ([]string, error)
+    CreateFriendRequest(ctx context.Context, fromUserID, toUserID string) error
+    AcceptFriendRequest(ctx context.Context, userID1, userID2 string) error
+    RejectFriendRequest(ctx context.Context, fromUserID, toUserID string) error
+    FriendRequestRejectedAt(ctx context.Context, fromUserID, toUserID string) (time.Time, error)
+    GetFriendRequestUsers(ctx context.Context, requestID string) (*models.User, *models.User, error)
+    GetPendingFriendRequests(ctx context.Context, userID string) ([]models.FriendRequest, error)
+    RemoveFriend(ctx context.Context, userID1, userID2 string) error
+    BlockUser(ctx context.Context, userID, blockedUserID string) error
+    IsBlocked(ctx context.Context, userID1, userID2 string) (bool, error)
+    HasBlocked(ctx context.Context, blockerID, userID string) (bool, error)
+
+    // groups
+    CreateGroup(ctx context.Context, name, description, creatorID string, public, encrypted, announcement bool) (*models.Group, error)
+    GetGroup(ctx context.Context, groupID string) (*models.Group, error)
+    GetUserGroups(ctx context.Context, userID string) ([]models.Group, error)
+    GetPublicGroups(ctx context.Context, userID string, limit int) ([]models.GroupSummary, error)
+    GetGroupMembers(ctx context.Context, groupID string) ([]string, error)
+    GetGroupMemberDetails(ctx context.Context, groupID string) ([]models.GroupMember, error)
+    AddUserToGroup(ctx context.Context, userID, groupID string) error
+    RemoveUserFromGroup(ctx context.Context, userID, groupID string) error
+    IsGroupMember(ctx context.Context, userID, groupID string) (bool, error)
+    IsAnnouncementGroup(ctx context.Context, groupID string) (bool, error)
+    IsGroupEncrypted(ctx context.Context, groupID string) (bool, error)
+    GetGroupRole(ctx context.Context, userID, groupID string) (string, error)
+    UpdateGroupRole(ctx context.Context, userID, groupID, newRole string) error
+    SetGroupTopic(ctx context.Context, groupID, topic, userID string) (models.TopicChange, error)
+    GetTopicChanges(ctx context.Context, groupID string, limit int) ([]models.TopicChange, error)
+
+    // messages
+    SaveMessage(ctx context.Context, msg *models.Message) error
+    GetMessage(ctx context.Context, messageID string) (*models.Message, error)
+    GetMessages(ctx context.Context, userID string, limit int) ([]models.Message, error)
+    GetMessagesBeforeID(ctx context.Context, userID, beforeID string, limit int) ([]models.Message, error)
+    GetConversationMessages(ctx context.Context, userID, otherID, groupID, beforeID string, limit int) ([]models.Message, error)
+    GetMessagesAfter(ctx context.Context, userID, otherID, groupID string, after, until time.Time, limit int) ([]models.Message, error)
+    GetGroupMessages(ctx context.Context, groupID string) ([]models.Message, error)
+    GetThreadMessages(ctx context.Context, rootID, beforeID string, limit int) ([]models.Message, error)
+    GetMessageIDByClientID(ctx context.Context, senderID, clientID string) (string, error)
+    GetDirectContacts(ctx context.Context, userID string, limit int) ([]models.User, error)
+    EditMessage(ctx context.Context, messageID, editorID, content string) (*models.Message, error)
+    GetMessageRevisions(ctx context.Context, messageID string) ([]models.MessageRevision, error)
+    MarkMessageAsRead(ctx context.Context, messageID, userID string) error
+
+    // receipts
+    MarkDelivered(ctx context.Context, messageID string) error
+    DeliverPending(ctx context.Context, userID string) (map[string]string, error)
+    MarkConversationRead(ctx context.Context, userID, otherID, messageID string) (bool, error)
+    GetUnreadCounts(ctx context.Context, userID string) (map[string]int, error)
+
+    // archive
+    GetMessagesOlderThan(ctx context.Context, cutoff time.Time, limit int) ([]ArchiveRecord, error)
+    ArchiveMessages(ctx context.Context, records []ArchiveRecord, archivePath string) error
+    GetArchivedMessage(ctx context.Context, messageID string) (*ArchivedMessage, error)
+    GetArchivedMessages(ctx context.Context, userID, otherID, groupID string, before time.Time, limit int) ([]ArchivedMessage, error)
+    GetArchivedReplies(ctx context.Context, rootID string, before time.Time, limit int) ([]ArchivedMessage, error)
+    MessageSentAt(ctx context.Context, messageID string) (time.Time, error)
+    CountReplies(ctx context.Context, rootIDs []string) (map[string]int, error)
+
+    // attachments, recordings and previews
+    SaveAttachment(ctx context.Context, a *models.Attachment) error
+    GetAttachment(ctx context.Context, id string) (*models.Attachment, error)
+    CanReadAttachment(ctx context.Context, userID, attachmentID string) (bool, error)
+    SaveVoice(ctx context.Context, messageID string, voice models.Voice) error
+    AttachVoice(ctx context.Context, messages []models.Message) error
+    SaveLinkPreview(ctx context.Context, messageID string, preview models.LinkPreview) error
+    DeleteLinkPreview(ctx context.Context, messageID string) error
+    AttachPreviews(ctx context.Context, messages []models.Message) error
+
+    // notifications and mentions
+    CreateNotification(ctx context.Context, n *models.Notification) error
+    GetNotifications(ctx context.Context, userID string, limit int) ([]models.Notification, error)
+    MarkNotificationsRead(ctx context.Context, userID string, ids []string) error
+    SetNotificationLevel(ctx context.Context, userID, chatID, level string) error
+    GetNotificationLevels(ctx context.Context, userID string) (map[string]string, error)
+    GetNotificationLevel(ctx context.Context, userID, chatID string) (string, error)
+    AddMention(ctx context.Context, userID, messageID string) error
+    GetMentions(ctx context.Context, userID string, limit int) ([]models.Mention, error)
+
+    // reminders
+    CreateReminder(ctx context.Context, r *models.Reminder) error
+    GetReminders(ctx context.Context, userID string) ([]models.Reminder, error)
+    CountReminders(ctx context.Context, userID string) (int, error)
+    CancelReminder(ctx context.Context, userID, reminderID string) (bool, error)
+    GetDueReminders(ctx context.Context, now time.Time, limit int) ([]models.Reminder, error)
+    MarkReminderDelivered(ctx context.Context, reminderID string) error
+
+    // integrations
+    CreateIntegration(ctx context.Context, integration *models.Integration) error
+    GetIntegration(ctx context.Context, id string) (*models.Integration, error)
+    GetGroupIntegrations(ctx context.Context, groupID string) ([]models.Integration, error)
+    DeleteIntegration(ctx context.Context, groupID, id string) (bool, error)
+
+    // end-to-end encryption
+    SaveDeviceKeys(ctx context.Context, userID string, device protocol.DeviceBundle, oneTimeKeys []protocol.OneTimePreKey) error
+    ClaimKeyBundles(ctx context.Context, userID string) ([]protocol.DeviceBundle, error)
+    QueueEncryptedMessage(ctx context.Context, msg *protocol.EncryptedMessagePayload) error
+    QueueGroupEncryptedMessage(ctx context.Context, msg *protocol.EncryptedMessagePayload) ([]protocol.EncryptedMessagePayload, error)
+    DeleteEncryptedMessage(ctx context.Context, id string) error
+    TakeEncryptedMessages(ctx context.Context, userID string) ([]protocol.EncryptedMessagePayload, error)
+
+    // imports
+    GetOrCreateImportedUser(ctx context.Context, username string) (*models.User, bool, error)
+    AccountTaken(ctx context.Context, username string) (bool, error)
+    FindImportedGroup(ctx context.Context, name string) (string, error)
+    GroupTaken(ctx context.Context, name string) (bool, error)
+    CreateImportedGroup(ctx context.Context, name, description, creatorID string) (*models.Group, error)
+}
+
+// both keep the same data
+var (
+    _ Store = (*DB)(nil)
+    _ Store = (*Memory)(nil)
+)
@@ -0,0 +1,57 @@
+// internal/server/database/threads.go
+package database
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+)
+
+// GetThreadMessages returns a page of the replies of a thread, newest first.
+// An empty beforeID returns the latest replies
+func (db *DB) GetThreadMessages(ctx context.Context, rootID, beforeID string, limit int) ([]models.Message, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT messages.id,
+               messages.content,
+               messages.sender_id,
+               messages.recipient_id,
+               messages.group_id,
+               messages.sent_at,
+               messages.read_at,
+               messages.edited_at,
+               COALESCE(users.username, '') as sender_name,
+               messages.thread_root_id
+        FROM messages
+        LEFT JOIN users ON messages.sender_id = users.id
+        WHERE messages.thread_root_id = $1
+        AND ($2 = '' OR messages.sent_at < (SELECT sent_at FROM messages WHERE id::text = $2))
+        ORDER BY messages.sent_at DESC
+        LIMIT $3
+    `, rootID, beforeID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get thread messages: %v", err)
+    }
+    defer rows.Close()
+
+    var messages []models.Message
+    for rows.Next() {
+        var msg models.Message
+        if err := rows.Scan(
+            &msg.ID,
+            &msg.Content,
+            &msg.SenderID,
+            &msg.RecipientID,
+            &msg.GroupID,
+            &msg.SentAt,
+            &msg.ReadAt,
+            &msg.EditedAt,
+            &msg.SenderName,
+            &msg.ThreadRootID,
+        ); err != nil {
+            return nil, fmt.Errorf("failed to scan thread message: %v", err)
+        }
+        messages = append(messages, msg)
+    }
+
+    return messages, rows.Err()
+}
@@ -0,0 +1,138 @@
+// internal/server/database/tokens.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"textual/internal/server/models"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+    ErrInvalidCredentials = errors.New("invalid username or password")
+    ErrInvalidToken       = errors.New("invalid token")
+)
+
+// VerifyPassword checks the password of an existing user. Unlike
+// AuthenticateUser it never creates the account nor changes the status
+func (db *DB) VerifyPassword(ctx context.Context, username, password string) (*models.User, error) {
+    var user models.User
+    var hashedPassword string
+    err := db.QueryRowContext(ctx, `
+        SELECT id, username, password_hash, status
+        FROM users
+        WHERE username = $1
+    `, username).Scan(&user.ID, &user.Username, &hashedPassword, &user.Status)
+    if err == sql.ErrNoRows {
+        return nil, ErrInvalidCredentials
+    }
+    if err != nil {
+        return nil, fmt.Errorf("database error: %v", err)
+    }
+    if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) != nil {
+        return nil, ErrInvalidCredentials
+    }
+    return &user, nil
+}
+
+// CreateAPIToken stores the hash of a new token of the user
+func (db *DB) CreateAPIToken(ctx context.Context, userID, name, tokenHash string) (string, time.Time, error) {
+    var id string
+    var createdAt time.Time
+    err := db.QueryRowContext(ctx, `
+        INSERT INTO api_tokens (user_id, name, token_hash)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at
+    `, userID, name, tokenHash).Scan(&id, &createdAt)
+    if err != nil {
+        return "", time.Time{}, fmt.Errorf("failed to create API token: %v", err)
+    }
+    return id, createdAt, nil
+}
+
+// GetAPITokenUser returns the owner of a token and the ID of the token,
+// noting when it was last used
+func (db *DB) GetAPITokenUser(ctx context.Context, tokenHash string) (*models.User, string, error) {
+    var user models.User
+    var tokenID string
+    err := db.QueryRowContext(ctx, `
+        UPDATE api_tokens t SET last_used_at = CURRENT_TIMESTAMP
+        FROM users u
+        WHERE t.token_hash = $1 AND u.id = t.user_id
+        RETURNING t.id, u.id, u.username, u.status
+    `, tokenHash).Scan(&tokenID, &user.ID, &user.Username, &user.Status)
+    if err == sql.ErrNoRows {
+        return nil, "", ErrInvalidToken
+    }
+    if err != nil {
+        return nil, "", fmt.Errorf("failed to get API token: %v", err)
+    }
+    return &user, tokenID, nil
+}
+
+// DeleteUserAPITokens revokes every token of a user, it returns how many
+// there were
+func (db *DB) DeleteUserAPITokens(ctx context.Context, userID string) (int64, error) {
+    result, err := db.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, userID)
+    if err != nil {
+        return 0, fmt.Errorf("failed to delete API tokens: %v", err)
+    }
+    return result.RowsAffected()
+}
+
+// DeleteAPIToken revokes a token of the user, it returns false when the user
+// has none with this ID
+func (db *DB) DeleteAPIToken(ctx context.Context, userID, tokenID string) (bool, error) {
+    result, err := db.ExecContext(ctx, `
+        DELETE FROM api_tokens WHERE id::text = $1 AND user_id = $2
+    `, tokenID, userID)
+    if err != nil {
+        return false, fmt.Errorf("failed to delete API token: %v", err)
+    }
+    deleted, err := result.RowsAffected()
+    return deleted > 0, err
+}
+
+// GetDirectContacts returns the users the user exchanged direct messages
+// with, the most recent conversation first
+func (db *DB) GetDirectContacts(ctx context.Context, userID string, limit int) ([]models.User, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT u.id, u.username, u.status
+        FROM messages m
+        JOIN users u ON u.id = CASE WHEN m.sender_id = $1 THEN m.recipient_id ELSE m.sender_id END
+        WHERE m.recipient_id IS NOT NULL AND (m.sender_id = $1 OR m.recipient_id = $1)
+        GROUP BY u.id, u.username, u.status
+        ORDER BY MAX(m.sent_at) DESC
+        LIMIT $2
+    `, userID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get direct contacts: %v", err)
+    }
+    defer rows.Close()
+
+    var users []models.User
+    for rows.Next() {
+        var user models.User
+        if err := rows.Scan(&user.ID, &user.Username, &user.Status); err != nil {
+            return nil, fmt.Errorf("failed to scan direct contact: %v", err)
+        }
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+// GetMessageIDByClientID finds a message from the key its sender chose
+func (db *DB) GetMessageIDByClientID(ctx context.Context, senderID, clientID string) (string, error) {
+    var id string
+    err := db.QueryRowContext(ctx, `
+        SELECT id FROM messages WHERE sender_id = $1 AND client_id = $2
+    `, senderID, clientID).Scan(&id)
+    if err != nil {
+        return "", fmt.Errorf("failed to get message: %v", err)
+    }
+    return id, nil
+}
@@ -0,0 +1,62 @@
+// internal/server/database/topics.go
+package database
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+)
+
+// SetGroupTopic changes the topic of a group and records the change, an
+// empty topic clears it
+func (db *DB) SetGroupTopic(ctx context.Context, groupID, topic, userID string) (models.TopicChange, error) {
+    var change models.TopicChange
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return change, fmt.Errorf("failed to set group topic: %v", err)
+    }
+    defer tx.Rollback()
+
+    if _, err := db.execTx(ctx, tx, `UPDATE groups SET topic = $2 WHERE id = $1`, groupID, topic); err != nil {
+        return change, fmt.Errorf("failed to set group topic: %v", err)
+    }
+    err = db.queryRowTx(ctx, tx, `
+        WITH change AS (
+            INSERT INTO group_topic_changes (group_id, topic, set_by)
+            VALUES ($1, $2, $3)
+            RETURNING topic, set_by, set_at
+        )
+        SELECT change.topic, COALESCE(users.username, ''), change.set_at
+        FROM change LEFT JOIN users ON users.id = change.set_by
+    `, groupID, topic, userID).Scan(&change.Topic, &change.SetBy, &change.SetAt)
+    if err != nil {
+        return change, fmt.Errorf("failed to record topic change: %v", err)
+    }
+    return change, tx.Commit()
+}
+
+// GetTopicChanges returns the latest topics set on a group, newest first
+func (db *DB) GetTopicChanges(ctx context.Context, groupID string, limit int) ([]models.TopicChange, error) {
+    rows, err := db.QueryContext(ctx, `
+        SELECT c.topic, COALESCE(u.username, ''), c.set_at
+        FROM group_topic_changes c
+        LEFT JOIN users u ON u.id = c.set_by
+        WHERE c.group_id = $1
+        ORDER BY c.set_at DESC
+        LIMIT $2
+    `, groupID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get topic changes: %v", err)
+    }
+    defer rows.Close()
+
+    var changes []models.TopicChange
+    for rows.Next() {
+        var change models.TopicChange
+        if err := rows.Scan(&change.Topic, &change.SetBy, &change.SetAt); err != nil {
+            return nil, fmt.Errorf("failed to scan topic change: %v", err)
+        }
+        changes = append(changes, change)
+    }
+    return changes, rows.Err()
+}
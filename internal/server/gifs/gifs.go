@@ -0,0 +1,155 @@
+// internal/server/gifs/gifs.go
+package gifs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+    ProviderGiphy = "giphy"
+    ProviderTenor = "tenor"
+
+    // largest answer read from the search APIs
+    maxResponseSize = 1 << 20
+)
+
+var ErrNotFound = errors.New("no GIF found")
+
+// GIF is the first result of a search: the animation, a still image of it
+// and a title
+type GIF struct {
+    URL      string
+    StillURL string
+    Title    string
+    Provider string
+}
+
+// Searcher finds GIFs with the search API of Giphy or Tenor, the key comes
+// from the server configuration
+type Searcher struct {
+    provider string
+    apiKey   string
+    rating   string
+    client   *http.Client
+}
+
+func NewSearcher(provider, apiKey, rating string, timeout time.Duration) (*Searcher, error) {
+    if provider != ProviderGiphy && provider != ProviderTenor {
+        return nil, fmt.Errorf("unknown GIF provider %q", provider)
+    }
+    return &Searcher{
+        provider: provider,
+        apiKey:   apiKey,
+        rating:   rating,
+        client:   &http.Client{Timeout: timeout},
+    }, nil
+}
+
+// Name is how the provider is shown under the GIFs
+func (s *Searcher) Name() string {
+    if s.provider == ProviderTenor {
+        return "Tenor"
+    }
+    return "GIPHY"
+}
+
+// Search returns the best GIF for the query
+func (s *Searcher) Search(ctx context.Context, query string) (*GIF, error) {
+    if s.provider == ProviderTenor {
+        return s.searchTenor(ctx, query)
+    }
+    return s.searchGiphy(ctx, query)
+}
+
+func (s *Searcher) searchGiphy(ctx context.Context, query string) (*GIF, error) {
+    params := url.Values{
+        "api_key": {s.apiKey},
+        "q":       {query},
+        "limit":   {"1"},
+        "rating":  {s.rating},
+    }
+    var result struct {
+        Data []struct {
+            Title  string `json:"title"`
+            Images struct {
+                Original struct {
+                    URL string `json:"url"`
+                } `json:"original"`
+                Still struct {
+                    URL string `json:"url"`
+                } `json:"original_still"`
+            } `json:"images"`
+        } `json:"data"`
+    }
+    if err := s.get(ctx, "https://api.giphy.com/v1/gifs/search?"+params.Encode(), &result); err != nil {
+        return nil, err
+    }
+    if len(result.Data) == 0 || result.Data[0].Images.Original.URL == "" {
+        return nil, ErrNotFound
+    }
+    gif := result.Data[0]
+    return &GIF{URL: gif.Images.Original.URL, StillURL: gif.Images.Still.URL, Title: gif.Title, Provider: s.Name()}, nil
+}
+
+func (s *Searcher) searchTenor(ctx context.Context, query string) (*GIF, error) {
+    params := url.Values{
+        "key":           {s.apiKey},
+        "q":             {query},
+        "limit":         {"1"},
+        "media_filter":  {"gif,gifpreview"},
+        "contentfilter": {tenorFilter(s.rating)},
+    }
+    var result struct {
+        Results []struct {
+            Description string `json:"content_description"`
+            Media       map[string]struct {
+                URL string `json:"url"`
+            } `json:"media_formats"`
+        } `json:"results"`
+    }
+    if err := s.get(ctx, "https://tenor.googleapis.com/v2/search?"+params.Encode(), &result); err != nil {
+        return nil, err
+    }
+    if len(result.Results) == 0 || result.Results[0].Media["gif"].URL == "" {
+        return nil, ErrNotFound
+    }
+    gif := result.Results[0]
+    return &GIF{URL: gif.Media["gif"].URL, StillURL: gif.Media["gifpreview"].URL, Title: gif.Description, Provider: s.Name()}, nil
+}
+
+func (s *Searcher) get(ctx context.Context, link string, result interface{}) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+    if err != nil {
+        return err
+    }
+    resp, err := s.client.Do(req)
+    if err != nil {
+        // the error holds the URL, and so the key
+        return fmt.Errorf("%s search failed: %v", s.Name(), errors.Unwrap(err))
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("%s search failed: %s", s.Name(), resp.Status)
+    }
+    return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result)
+}
+
+// tenorFilter maps the ratings of Giphy to the content filters of Tenor
+func tenorFilter(rating string) string {
+    switch rating {
+    case "g":
+        return "high"
+    case "pg", "pg-13":
+        return "medium"
+    case "r":
+        return "off"
+    }
+    return "medium"
+}
@@ -0,0 +1,212 @@
+// internal/server/handlers/admin.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"textual/pkg/protocol"
+)
+
+// moderation events kept for the admins, the oldest go first
+const maxModerationEvents = 50
+
+// longest announcement an admin can send, and reason of a kick
+const (
+    maxAnnouncementLength = 500
+    maxKickReasonLength   = 200
+)
+
+// messageRate counts the chat messages received during the last minute, by
+// second
+type messageRate struct {
+    mu      sync.Mutex
+    counts  [60]int
+    seconds [60]int64
+}
+
+func (r *messageRate) Add(now time.Time) {
+    second := now.Unix()
+    i := second % 60
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.seconds[i] != second {
+        r.seconds[i] = second
+        r.counts[i] = 0
+    }
+    r.counts[i]++
+}
+
+// LastMinute returns the messages counted during the last 60 seconds
+func (r *messageRate) LastMinute(now time.Time) int {
+    second := now.Unix()
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    total := 0
+    for i, at := range r.seconds {
+        if second-at < 60 {
+            total += r.counts[i]
+        }
+    }
+    return total
+}
+
+// SetAdmins sets the usernames of the server admins, they see the stats of
+// the server and can announce and kick
+func (h *MessageHandler) SetAdmins(usernames []string) {
+    h.adminMu.Lock()
+    defer h.adminMu.Unlock()
+    h.admins = make(map[string]bool, len(usernames))
+    for _, name := range usernames {
+        h.admins[strings.ToLower(name)] = true
+    }
+}
+
+func (h *MessageHandler) isAdmin(username string) bool {
+    h.adminMu.Lock()
+    defer h.adminMu.Unlock()
+    return h.admins[strings.ToLower(username)]
+}
+
+func (h *MessageHandler) requireAdmin(sender *Client) error {
+    if !h.isAdmin(sender.Username) {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Only the server admins can do this")
+    }
+    return nil
+}
+
+// recordModeration keeps a moderation event for the admins
+func (h *MessageHandler) recordModeration(action, actor, target, detail string) {
+    h.adminMu.Lock()
+    defer h.adminMu.Unlock()
+    h.moderation = append(h.moderation, protocol.ModerationEvent{
+        Action: action,
+        Actor:  actor,
+        Target: target,
+        Detail: detail,
+        At:     h.clock.Now().Unix(),
+    })
+    if len(h.moderation) > maxModerationEvents {
+        h.moderation = h.moderation[len(h.moderation)-maxModerationEvents:]
+    }
+}
+
+// adminStats measures the sessions and their queues
+func (h *MessageHandler) adminStats() protocol.AdminStatsPayload {
+    stats := protocol.AdminStatsPayload{
+        MessagesPerMinute: h.messageRate.LastMinute(h.clock.Now()),
+    }
+    users := make(map[string]bool)
+    h.sessions.Each(func(client *Client) {
+        users[client.ID] = true
+        stats.Sessions++
+        queued := len(client.Send)
+        stats.QueuedMessages += queued
+        stats.MaxQueued = max(stats.MaxQueued, queued)
+        if client.Delivery.Degraded() {
+            stats.Degraded++
+        }
+    })
+    stats.OnlineUsers = len(users)
+
+    h.adminMu.Lock()
+    stats.Events = append([]protocol.ModerationEvent(nil), h.moderation...)
+    h.adminMu.Unlock()
+    return stats
+}
+
+func (h *MessageHandler) sendAdminStats(client *Client) {
+    select {
+    case client.Send <- protocol.NewMessage(protocol.TypeAdminStats, h.adminStats()):
+    default:
+        log.Printf("Failed to send admin stats to %s: channel full", client.Username)
+    }
+}
+
+// handleAdminStats answers an admin with the stats of the server
+func (h *MessageHandler) handleAdminStats(sender *Client) error {
+    if err := h.requireAdmin(sender); err != nil {
+        return err
+    }
+    h.sendAdminStats(sender)
+    return nil
+}
+
+// handleAdminAnnounce sends the notice of an admin to every connected user
+func (h *MessageHandler) handleAdminAnnounce(sender *Client, msg protocol.Message) error {
+    if err := h.requireAdmin(sender); err != nil {
+        return err
+    }
+    var payload protocol.AdminAnnouncePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid announce payload: %v", err)
+    }
+    message := strings.TrimSpace(sanitizeText(payload.Message))
+    if message == "" || len(message) > maxAnnouncementLength {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("An announcement has 1 to %d characters", maxAnnouncementLength))
+    }
+
+    h.broadcast <- protocol.NewMessage(protocol.TypeNotification, protocol.NotificationPayload{
+        Type:    protocol.NoticeAnnouncement,
+        Message: message,
+        Actor:   sender.Username,
+    })
+    h.recordModeration(protocol.ModerationAnnounce, sender.Username, "", message)
+    h.sendAdminStats(sender)
+    return nil
+}
+
+// handleAdminKick disconnects every session of a user
+func (h *MessageHandler) handleAdminKick(ctx context.Context, sender *Client, msg protocol.Message) error {
+    if err := h.requireAdmin(sender); err != nil {
+        return err
+    }
+    var payload protocol.AdminKickPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid kick payload: %v", err)
+    }
+    user, err := h.db.GetUserByUsername(ctx, payload.Username)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeUserNotFound, fmt.Sprintf("User %s not found", payload.Username))
+    }
+    if user.ID == sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "You can't kick yourself")
+    }
+    reason := strings.TrimSpace(sanitizeText(payload.Reason))
+    if len(reason) > maxKickReasonLength {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("The reason has %d characters at most", maxKickReasonLength))
+    }
+
+    // the sessions end once the notice is written, and are cleaned up like
+    // any lost connection
+    notice := protocol.NewMessage(protocol.TypeDisconnectNotice, protocol.DisconnectNoticePayload{
+        Reason: reason,
+        By:     sender.Username,
+    })
+    sessions := h.sessions.Disconnect(user.ID, notice)
+    if sessions == 0 {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("%s is not connected", user.Username))
+    }
+    log.Printf("%s kicked %s (%d sessions)", sender.Username, user.Username, sessions)
+    h.recordModeration(protocol.ModerationKick, sender.Username, user.Username, reason)
+    h.sendAdminStats(sender)
+    return nil
+}
+
+// recordGroupKick keeps a member removed from a group by one of its admins,
+// it returns the username of the member
+func (h *MessageHandler) recordGroupKick(ctx context.Context, actor, userID, groupID string) string {
+    target, group := userID, groupID
+    if user, err := h.db.GetUser(ctx, userID); err == nil {
+        target = user.Username
+    }
+    if g, err := h.db.GetGroup(ctx, groupID); err == nil {
+        group = g.Name
+    }
+    h.recordModeration(protocol.ModerationGroupKick, actor, target, group)
+    return target
+}
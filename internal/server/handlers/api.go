@@ -0,0 +1,58 @@
+// internal/server/handlers/api.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+)
+
+// HandleAPI runs a request of the REST API as a message sent by a client of
+// the user, and returns the messages the handler answered to that client.
+// The user's own session, when online, gets them too: it would have received
+// the copy of a direct message sent from it. ctx ends with the request
+func (h *MessageHandler) HandleAPI(ctx context.Context, user *models.User, msg protocol.Message) ([]protocol.Message, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    switch msg.Type {
+    case protocol.TypeLoadMessages, protocol.TypeGlobalMessage, protocol.TypeDirectMessage, protocol.TypeGroupMessage:
+    default:
+        return nil, fmt.Errorf("unsupported API message type: %s", msg.Type)
+    }
+    if !h.db.Healthy() {
+        return nil, protocol.NewError(protocol.ErrCodeUnavailable, "Database unavailable, please try again later")
+    }
+
+    // never registered, nothing else writes to it
+    sender := &Client{ID: user.ID, Username: user.Username, Send: make(chan protocol.Message, 16)}
+    var err error
+    switch msg.Type {
+    case protocol.TypeLoadMessages:
+        err = h.handleLoadMessages(ctx, sender, msg)
+    case protocol.TypeGlobalMessage:
+        err = h.handleGlobalMessage(ctx, sender, msg)
+    case protocol.TypeDirectMessage:
+        err = h.handleDirectMessage(ctx, sender, msg)
+    case protocol.TypeGroupMessage:
+        err = h.handleGroupMessage(ctx, sender, msg)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var answers []protocol.Message
+    for len(sender.Send) > 0 {
+        answers = append(answers, <-sender.Send)
+    }
+    if msg.Type == protocol.TypeLoadMessages {
+        return answers, nil
+    }
+
+    // the devices of the user see what was sent in their name
+    for _, answer := range answers {
+        h.sessions.Send(user.ID, answer)
+    }
+    return answers, nil
+}
@@ -0,0 +1,119 @@
+// internal/server/handlers/archive.go
+package handlers
+
+import (
+	"context"
+	"log"
+	"textual/internal/server/archive"
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+	"time"
+)
+
+// withArchived completes a page of history with the archived messages when
+// the messages table has no more. The archiver moves the oldest messages
+// first, the archived ones are older than any message left in the table
+func withArchived(ctx context.Context, db database.Store, page []models.Message, userID, otherID, groupID, beforeID string, limit int) []models.Message {
+    if len(page) >= limit {
+        return page
+    }
+    before, ok := archiveCursor(ctx, db, page, beforeID)
+    if !ok {
+        return page
+    }
+    pointers, err := db.GetArchivedMessages(ctx, userID, otherID, groupID, before, limit-len(page))
+    if err != nil {
+        log.Printf("Failed to get archived messages: %v", err)
+        return page
+    }
+    return append(page, readArchived(ctx, db, pointers)...)
+}
+
+// withArchivedReplies completes a page of the replies of a thread with the
+// archived ones
+func withArchivedReplies(ctx context.Context, db database.Store, page []models.Message, rootID, beforeID string, limit int) []models.Message {
+    if len(page) >= limit {
+        return page
+    }
+    before, ok := archiveCursor(ctx, db, page, beforeID)
+    if !ok {
+        return page
+    }
+    pointers, err := db.GetArchivedReplies(ctx, rootID, before, limit-len(page))
+    if err != nil {
+        log.Printf("Failed to get archived replies: %v", err)
+        return page
+    }
+    return append(page, readArchived(ctx, db, pointers)...)
+}
+
+// archiveCursor returns the time before which the archived messages follow
+// a page: the oldest message of the page, or the one the page was loaded
+// before
+func archiveCursor(ctx context.Context, db database.Store, page []models.Message, beforeID string) (time.Time, bool) {
+    switch {
+    case len(page) > 0:
+        return page[len(page)-1].SentAt, true
+    case beforeID != "":
+        sentAt, err := db.MessageSentAt(ctx, beforeID)
+        return sentAt, err == nil
+    }
+    return time.Now(), true
+}
+
+// readArchived reads the messages of the pointers from the archive files,
+// with the replies of those starting a thread. A file that can't be read
+// leaves the page without them
+func readArchived(ctx context.Context, db database.Store, pointers []database.ArchivedMessage) []models.Message {
+    if len(pointers) == 0 {
+        return nil
+    }
+    messages, err := archive.ReadMessages(pointers)
+    if err != nil {
+        log.Printf("Failed to read archived messages: %v", err)
+        return nil
+    }
+
+    ids := make([]string, 0, len(messages))
+    for _, msg := range messages {
+        ids = append(ids, msg.ID)
+    }
+    counts, err := db.CountReplies(ctx, ids)
+    if err != nil {
+        log.Printf("Failed to count archived replies: %v", err)
+    }
+    for i := range messages {
+        messages[i].ReplyCount = counts[messages[i].ID]
+    }
+    return messages
+}
+
+// getMessage returns a message from the messages table or the archive
+func getMessage(ctx context.Context, db database.Store, messageID string) (*models.Message, error) {
+    msg, err := db.GetMessage(ctx, messageID)
+    if err == nil {
+        return msg, nil
+    }
+    archived, archiveErr := db.GetArchivedMessage(ctx, messageID)
+    if archiveErr != nil {
+        return nil, err
+    }
+    messages := readArchived(ctx, db, []database.ArchivedMessage{*archived})
+    if len(messages) == 0 {
+        return nil, err
+    }
+    return &messages[0], nil
+}
+
+// getArchivedRecord reads an archived message with its edit history
+func getArchivedRecord(ctx context.Context, db database.Store, messageID string) (*database.ArchiveRecord, error) {
+    archived, err := db.GetArchivedMessage(ctx, messageID)
+    if err != nil {
+        return nil, err
+    }
+    records, err := archive.ReadRecords([]database.ArchivedMessage{*archived})
+    if err != nil {
+        return nil, err
+    }
+    return &records[0], nil
+}
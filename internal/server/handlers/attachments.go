@@ -0,0 +1,259 @@
+// internal/server/handlers/attachments.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"textual/internal/server/attachments"
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// limits of the voice messages
+const (
+    maxVoiceDuration  = 10 * time.Minute
+    maxWaveformLength = 128
+    maxAttachmentName = 255
+)
+
+// SetAttachments enables the uploads, stored in the given store
+func (h *MessageHandler) SetAttachments(store *attachments.Store) {
+    h.attachments = store
+}
+
+// handleAttachmentUpload writes a chunk of an upload, the last one stores the
+// attachment and the uploader gets its ID
+func (h *MessageHandler) handleAttachmentUpload(ctx context.Context, sender *Client, msg protocol.Message) error {
+    if h.attachments == nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Attachments are disabled on this server")
+    }
+    var chunk protocol.AttachmentChunkPayload
+    if err := h.decodePayload(msg.Payload, &chunk); err != nil {
+        return fmt.Errorf("invalid attachment payload: %v", err)
+    }
+    if chunk.UploadID == "" || len(chunk.Data) > protocol.AttachmentChunkSize {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid attachment chunk")
+    }
+
+    tmpPath, done, err := h.attachments.Write(sender.ID, chunk)
+    switch err {
+    case nil:
+    case attachments.ErrTooLarge:
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Attachments are limited to %d KB", h.attachments.MaxSize()>>10))
+    case attachments.ErrBadChunk, attachments.ErrIncomplete:
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "The upload was interrupted, send it again")
+    default:
+        return err
+    }
+    if !done {
+        return nil
+    }
+
+    name := sanitizeText(chunk.Name)
+    if len(name) > maxAttachmentName {
+        name = name[:maxAttachmentName]
+    }
+    mimeType := chunk.MimeType
+    if mimeType == "" || len(mimeType) > 100 {
+        mimeType = "application/octet-stream"
+    }
+    attachment := &models.Attachment{UploaderID: sender.ID, Name: name, MimeType: mimeType, Size: chunk.Size}
+    if err := h.db.SaveAttachment(ctx, attachment); err != nil {
+        h.attachments.Discard(tmpPath)
+        return err
+    }
+    if err := h.attachments.Commit(tmpPath, attachment.ID); err != nil {
+        return err
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeAttachmentUpload, protocol.AttachmentChunkPayload{
+        UploadID:     chunk.UploadID,
+        AttachmentID: attachment.ID,
+        Name:         attachment.Name,
+        MimeType:     attachment.MimeType,
+        Size:         attachment.Size,
+        Final:        true,
+    }):
+        return nil
+    default:
+        return fmt.Errorf("failed to acknowledge upload: channel full")
+    }
+}
+
+// handleAttachmentDownload sends an attachment in chunks to a user allowed to
+// see it
+func (h *MessageHandler) handleAttachmentDownload(ctx context.Context, sender *Client, msg protocol.Message) error {
+    if h.attachments == nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Attachments are disabled on this server")
+    }
+    var request protocol.AttachmentChunkPayload
+    if err := h.decodePayload(msg.Payload, &request); err != nil {
+        return fmt.Errorf("invalid attachment request: %v", err)
+    }
+
+    if ok, err := h.db.CanReadAttachment(ctx, sender.ID, request.AttachmentID); err != nil {
+        return err
+    } else if !ok {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Attachment not found")
+    }
+    attachment, err := h.db.GetAttachment(ctx, request.AttachmentID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Attachment not found")
+    }
+    file, err := h.attachments.Open(attachment.ID)
+    if err != nil {
+        return fmt.Errorf("failed to open attachment: %v", err)
+    }
+    defer file.Close()
+
+    buf := make([]byte, protocol.AttachmentChunkSize)
+    for offset := int64(0); ; {
+        n, err := io.ReadFull(file, buf)
+        if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+            return fmt.Errorf("failed to read attachment: %v", err)
+        }
+        final := offset+int64(n) >= attachment.Size || n < len(buf)
+        chunk := protocol.AttachmentChunkPayload{
+            AttachmentID: attachment.ID,
+            Name:         attachment.Name,
+            MimeType:     attachment.MimeType,
+            Size:         attachment.Size,
+            Offset:       offset,
+            Data:         append([]byte(nil), buf[:n]...),
+            Final:        final,
+        }
+        select {
+        case sender.Send <- protocol.NewMessage(protocol.TypeAttachmentDownload, chunk):
+        default:
+            return fmt.Errorf("failed to send attachment: channel full")
+        }
+        offset += int64(n)
+        if final {
+            return nil
+        }
+    }
+}
+
+// handleVoiceMessage stores a voice message with a text for the clients that
+// can't play it, then delivers it like a message of its conversation
+func (h *MessageHandler) handleVoiceMessage(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.VoiceMessagePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid voice message payload: %v", err)
+    }
+    voice := payload.Voice
+    if voice.Duration <= 0 || time.Duration(voice.Duration)*time.Millisecond > maxVoiceDuration || len(voice.Waveform) > maxWaveformLength {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid voice message")
+    }
+
+    attachment, err := h.db.GetAttachment(ctx, voice.AttachmentID)
+    if err != nil || attachment.UploaderID != sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Attachment not found")
+    }
+    if !strings.HasPrefix(attachment.MimeType, "audio/") {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "A voice message must be audio")
+    }
+
+    dbMsg := &models.Message{
+        Content:    fmt.Sprintf("🎤 Voice message (%s)", formatDuration(voice.Duration)),
+        SenderID:   sender.ID,
+        SenderName: sender.Username,
+        SentAt:     time.Now(),
+        Status:     models.MessageStatusSent,
+        ClientID:   payload.ClientID,
+        Voice:      &models.Voice{AttachmentID: voice.AttachmentID, Duration: voice.Duration, Waveform: voice.Waveform},
+    }
+
+    msgType := protocol.TypeGlobalMessage
+    switch {
+    case payload.RecipientID == protocol.SystemUserID:
+        return errSystemRecipient
+    case payload.RecipientID != "":
+        if blocked, err := h.db.IsBlocked(ctx, sender.ID, payload.RecipientID); err != nil {
+            return err
+        } else if blocked {
+            return protocol.NewError(protocol.ErrCodeAccessDenied, "You can't send messages to this user")
+        }
+        msgType = protocol.TypeDirectMessage
+        dbMsg.RecipientID = &payload.RecipientID
+    case payload.GroupID != "":
+        if isMember, err := h.db.IsGroupMember(ctx, sender.ID, payload.GroupID); err != nil {
+            return fmt.Errorf("failed to check group membership: %v", err)
+        } else if !isMember {
+            return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+        }
+        if encrypted, err := h.db.IsGroupEncrypted(ctx, payload.GroupID); err != nil {
+            return fmt.Errorf("failed to get group: %v", err)
+        } else if encrypted {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "This group is encrypted, its messages can't be sent in clear")
+        }
+        if err := requirePoster(ctx, h.db, sender.ID, payload.GroupID); err != nil {
+            return err
+        }
+        msgType = protocol.TypeGroupMessage
+        dbMsg.GroupID = &payload.GroupID
+    }
+
+    if err := h.db.SaveMessage(ctx, dbMsg); err == database.ErrDuplicateMessage {
+        if err := h.db.AttachVoice(ctx, []models.Message{*dbMsg}); err != nil {
+            return err
+        }
+        return h.ackDuplicate(sender, msgType, dbMsg)
+    } else if err != nil {
+        return fmt.Errorf("failed to save message: %v", err)
+    }
+    if err := h.db.SaveVoice(ctx, dbMsg.ID, *dbMsg.Voice); err != nil {
+        return err
+    }
+
+    h.deliverMessage(ctx, sender, msgType, dbMsg)
+    return nil
+}
+
+// deliverMessage sends a stored message to the users of its conversation
+func (h *MessageHandler) deliverMessage(ctx context.Context, sender *Client, msgType protocol.MessageType, dbMsg *models.Message) {
+    if isNote(dbMsg) {
+        h.markNoteRead(ctx, dbMsg)
+    }
+    out := protocol.NewMessage(msgType, h.createMessagePayload(dbMsg))
+
+    var recipients []string
+    senderOut := out
+    switch {
+    case isNote(dbMsg):
+        recipients = []string{sender.ID}
+    case dbMsg.RecipientID != nil:
+        recipients = []string{*dbMsg.RecipientID, sender.ID}
+        senderOut = h.markRecipientDND(ctx, out, *dbMsg.RecipientID)
+    case dbMsg.GroupID != nil:
+        members, err := h.db.GetGroupMembers(ctx, *dbMsg.GroupID)
+        if err != nil {
+            log.Printf("Failed to get group members: %v", err)
+            return
+        }
+        recipients = members
+    default:
+        h.broadcast <- out
+        return
+    }
+
+    for _, userID := range recipients {
+        msg := out
+        if userID == sender.ID {
+            msg = senderOut
+        }
+        h.sessions.Send(userID, msg)
+    }
+}
+
+// formatDuration writes a duration in milliseconds as m:ss
+func formatDuration(ms int64) string {
+    seconds := (ms + 500) / 1000
+    return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -13,20 +14,20 @@ import (
 )
 
 type AuthHandler struct {
-    db         *database.DB
-    clients    map[string]*Client
+    db         database.Store
     broadcast  chan<- protocol.Message
 }
 
-func NewAuthHandler(db *database.DB, clients map[string]*Client, broadcast chan<- protocol.Message) *AuthHandler {
+func NewAuthHandler(db database.Store, broadcast chan<- protocol.Message) *AuthHandler {
     return &AuthHandler{
         db:        db,
-        clients:   clients,
         broadcast: broadcast,
     }
 }
 
-func (h *AuthHandler) HandleAuth(conn net.Conn) (*models.User, error) {
+// HandleAuth logs the user of conn in, register is called before the login is
+// answered: nothing sent to the user from then on is missed
+func (h *AuthHandler) HandleAuth(ctx context.Context, conn net.Conn, register func(user *models.User)) (*models.User, error) {
     // Set a read deadline to prevent hanging
     conn.SetReadDeadline(time.Now().Add(30 * time.Second))
     
@@ -49,7 +50,7 @@ func (h *AuthHandler) HandleAuth(conn net.Conn) (*models.User, error) {
     }
 
     // Authenticate user
-    user, err := h.db.AuthenticateUser(authPayload.Username, authPayload.Password)
+    user, err := h.db.AuthenticateUser(ctx, authPayload.Username, authPayload.Password)
     if err != nil {
         errorResponse := protocol.NewMessage(protocol.TypeError, protocol.ErrorPayload{
             Code:    protocol.ErrCodeInvalidAuth,
@@ -69,10 +70,21 @@ func (h *AuthHandler) HandleAuth(conn net.Conn) (*models.User, error) {
         Status:   protocol.StatusOnline,
     }
 
+    // the custom status outlives the session
+    text, expiresAt, err := h.db.GetStatusText(ctx, modelUser.ID)
+    if err != nil {
+        log.Printf("Failed to get status text: %v", err)
+    }
+    modelUser.StatusText, modelUser.StatusExpiresAt = text, expiresAt
+    register(modelUser)
+
     // Send success response
     response := protocol.NewMessage(protocol.TypeAuthResponse, protocol.AuthResponsePayload{
-        Success: true,
-        UserID:  modelUser.ID,
+        Success:         true,
+        UserID:          modelUser.ID,
+        Username:        modelUser.Username,
+        StatusText:      modelUser.StatusText,
+        StatusExpiresAt: unixTime(modelUser.StatusExpiresAt),
     })
 
     if err := h.sendResponse(conn, response); err != nil {
@@ -80,19 +92,21 @@ func (h *AuthHandler) HandleAuth(conn net.Conn) (*models.User, error) {
     }
 
     // Update user status
-    if err := h.db.UpdateUserStatus(modelUser.ID, protocol.StatusOnline); err != nil {
+    if err := h.db.QueueUserStatus(ctx, modelUser.ID, protocol.StatusOnline); err != nil {
         log.Printf("Failed to update user status: %v", err)
     }
 
     // Send initial data
-    if err := h.sendInitialData(conn, modelUser.ID); err != nil {
+    if err := h.sendInitialData(ctx, conn, modelUser.ID); err != nil {
         log.Printf("Failed to send initial data: %v", err)
     }
 
     // Notify other users
     statusUpdate := protocol.NewMessage(protocol.TypeStatusUpdate, protocol.StatusUpdatePayload{
-        UserID: modelUser.ID,
-        Status: protocol.StatusOnline,
+        UserID:    modelUser.ID,
+        Status:    protocol.StatusOnline,
+        Text:      modelUser.StatusText,
+        ExpiresAt: unixTime(modelUser.StatusExpiresAt),
     })
     h.broadcast <- statusUpdate
 
@@ -144,11 +158,7 @@ func (h *AuthHandler) HandleAuth(conn net.Conn) (*models.User, error) {
 // }
 
 func (h *AuthHandler) decodePayload(payload interface{}, target interface{}) error {
-    data, err := json.Marshal(payload)
-    if err != nil {
-        return err
-    }
-    return json.Unmarshal(data, target)
+    return protocol.DecodePayload(payload, target)
 }
 
 func (h *AuthHandler) sendResponse(conn net.Conn, msg protocol.Message) error {
@@ -160,27 +170,30 @@ func (h *AuthHandler) sendResponse(conn net.Conn, msg protocol.Message) error {
     return encoder.Encode(msg)
 }
 
-func (h *AuthHandler) HandleLogout(userID string) error {
+func (h *AuthHandler) HandleLogout(ctx context.Context, userID string) error {
     // Update user status in database
-    if err := h.db.UpdateUserStatus(userID, protocol.StatusOffline); err != nil {
+    if err := h.db.QueueUserStatus(ctx, userID, protocol.StatusOffline); err != nil {
         return err
     }
 
-    // Notify other users
+    // Notify other users, the custom status stays shown while offline
+    text, expiresAt, err := h.db.GetStatusText(ctx, userID)
+    if err != nil {
+        log.Printf("Failed to get status text: %v", err)
+    }
     statusUpdate := protocol.NewMessage(protocol.TypeStatusUpdate, protocol.StatusUpdatePayload{
-        UserID: userID,
-        Status: protocol.StatusOffline,
+        UserID:    userID,
+        Status:    protocol.StatusOffline,
+        Text:      text,
+        ExpiresAt: unixTime(expiresAt),
     })
     h.broadcast <- statusUpdate
 
-    // Remove client from active clients
-    delete(h.clients, userID)
-
     return nil
 }
 
-func (h *AuthHandler) ValidateSession(userID string) error {
-    user, err := h.db.GetUser(userID)
+func (h *AuthHandler) ValidateSession(ctx context.Context, userID string) error {
+    user, err := h.db.GetUser(ctx, userID)
     if err != nil {
         return fmt.Errorf("invalid session: user not found")
     }
@@ -192,64 +205,14 @@ func (h *AuthHandler) ValidateSession(userID string) error {
     return nil
 }
 
-func (h *AuthHandler) sendInitialData(conn net.Conn, userID string) error {
-    // Get message history
-    messages, err := h.db.GetMessages(userID, 100)
-    if err == nil {
-        historyMsg := protocol.NewMessage(protocol.TypeMessageHistory, map[string]interface{}{
-            "messages": messages,
-        })
-        if err := h.sendResponse(conn, historyMsg); err != nil {
-            return fmt.Errorf("failed to send message history: %v", err)
-        }
-    }
-
-    // Send friend list
-    friends, err := h.db.GetFriends(userID)
-    if err == nil {
-        friendInfos := make([]protocol.UserInfo, 0, len(friends))
-        for _, friend := range friends {
-            friendInfos = append(friendInfos, protocol.UserInfo{
-                ID:       friend.ID,
-                Username: friend.Username,
-                Status:   friend.Status,
-            })
-        }
-
-        friendList := protocol.NewMessage(protocol.TypeFriendList, map[string]interface{}{
-            "friends": friendInfos,
-        })
-
-        if err := h.sendResponse(conn, friendList); err != nil {
-            return fmt.Errorf("failed to send friend list: %v", err)
-        }
+// sendInitialData sends the state of the user in a single sync message
+func (h *AuthHandler) sendInitialData(ctx context.Context, conn net.Conn, userID string) error {
+    payload, err := h.syncPayload(ctx, userID)
+    if err != nil {
+        return err
     }
-
-    // Send pending friend requests
-    requests, err := h.db.GetPendingFriendRequests(userID)
-    if err == nil {
-        log.Printf("Found %d pending friend requests for user %s", len(requests), userID)
-        for _, req := range requests {
-            notification := protocol.Message{
-                Type: protocol.TypeFriendRequest,
-                Payload: protocol.FriendRequestPayload{
-                    RequestID: fmt.Sprintf("fr-%s-%s-%d", req.FromUserID, req.ToUserID, req.CreatedAt.Unix()),
-                    FromUser:  req.FromUsername,
-                    ToUser:    req.ToUsername,
-                    Status:    "pending",
-                },
-                Timestamp: req.CreatedAt.Unix(),
-            }
-
-            if err := h.sendResponse(conn, notification); err != nil {
-                log.Printf("Failed to send friend request notification: %v", err)
-            } else {
-                log.Printf("Sent friend request notification from %s to %s", req.FromUsername, req.ToUsername)
-            }
-        }
-    } else {
-        log.Printf("Error getting pending friend requests: %v", err)
+    if err := h.sendResponse(conn, protocol.NewMessage(protocol.TypeSync, payload)); err != nil {
+        return fmt.Errorf("failed to send sync: %v", err)
     }
-
     return nil
 }
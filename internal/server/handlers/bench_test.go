@@ -0,0 +1,96 @@
+// internal/server/handlers/bench_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+)
+
+// benchMessage is a group message as most of the traffic, reply marks
+// it as a reply of a thread with a link preview
+func benchMessage(reply bool) *models.Message {
+    groupID, rootID := "7c9e6679-7425-40de-944b-e07fc1f90ae7", "16fd2706-8baf-433b-82eb-8c7fada847da"
+    msg := &models.Message{
+        ID:         "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+        Content:    "did anyone read the release notes? https://example.com/notes the migration looks painful",
+        SenderID:   "9b2c3d4e-1f2a-4b5c-8d9e-0a1b2c3d4e5f",
+        SenderName: "alice",
+        GroupID:    &groupID,
+        SentAt:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+        ClientID:   "1704110400000-42",
+    }
+    if reply {
+        msg.ThreadRootID = &rootID
+        msg.ReplyCount = 3
+        msg.Preview = &models.LinkPreview{URL: "https://example.com/notes", Title: "Release notes", Description: "What changed in this release"}
+    }
+    return msg
+}
+
+func BenchmarkCreateMessagePayload(b *testing.B) {
+    h := &MessageHandler{}
+    for _, bench := range []struct {
+        name  string
+        reply bool
+    }{{"plain", false}, {"reply", true}} {
+        msg := benchMessage(bench.reply)
+        b.Run(bench.name, func(b *testing.B) {
+            b.ReportAllocs()
+            for i := 0; i < b.N; i++ {
+                h.createMessagePayload(msg)
+            }
+        })
+    }
+}
+
+// BenchmarkEncodeMessage encodes a message as writePump does, in a pooled
+// buffer
+func BenchmarkEncodeMessage(b *testing.B) {
+    h := &MessageHandler{}
+    msg := protocol.Message{
+        Type:      protocol.TypeGroupMessage,
+        Payload:   h.createMessagePayload(benchMessage(false)),
+        Timestamp: 1704110400,
+    }
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        buf := protocol.GetBuffer()
+        if err := buf.Encode(msg); err != nil {
+            b.Fatal(err)
+        }
+        buf.Release()
+    }
+}
+
+// BenchmarkDecodePayload decodes a message as it is read, then its payload
+// as the handlers do
+func BenchmarkDecodePayload(b *testing.B) {
+    data, err := json.Marshal(protocol.NewMessage(protocol.TypeGroupMessage, map[string]string{
+        "content":   benchMessage(false).Content,
+        "group_id":  *benchMessage(false).GroupID,
+        "client_id": "1704110400000-42",
+    }))
+    if err != nil {
+        b.Fatal(err)
+    }
+    h := &MessageHandler{}
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        var msg protocol.Message
+        if err := json.Unmarshal(data, &msg); err != nil {
+            b.Fatal(err)
+        }
+        var payload struct {
+            Content  string `json:"content"`
+            GroupID  string `json:"group_id"`
+            ClientID string `json:"client_id"`
+        }
+        if err := h.decodePayload(msg.Payload, &payload); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
@@ -3,22 +3,74 @@ package handlers
 
 import (
 	"net"
+	"sync"
 	"textual/pkg/protocol"
+	"time"
 )
 
 type Client struct {
     Conn     net.Conn
     ID       string
     Username string
+    // identifies the connection, a user may have several
+    SessionID string
     Send     chan protocol.Message
+    // how far behind its writes are, kept by the write pump
+    Delivery Delivery
+}
+
+// Delivery tracks a client falling behind: its queue not emptied by the
+// writes since behindSince, and the degraded mode the server puts it in
+type Delivery struct {
+    mu          sync.Mutex
+    behindSince time.Time
+    degraded    bool
+}
+
+// Update records the messages left queued after a write and returns for
+// how long the queue has not been emptied
+func (d *Delivery) Update(now time.Time, queued int) time.Duration {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if queued == 0 {
+        d.behindSince = time.Time{}
+        return 0
+    }
+    if d.behindSince.IsZero() {
+        d.behindSince = now
+    }
+    return now.Sub(d.behindSince)
+}
+
+// Lag returns for how long the queue has not been emptied
+func (d *Delivery) Lag(now time.Time) time.Duration {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if d.behindSince.IsZero() {
+        return 0
+    }
+    return now.Sub(d.behindSince)
+}
+
+func (d *Delivery) Degraded() bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return d.degraded
+}
+
+func (d *Delivery) SetDegraded(degraded bool) {
+    d.mu.Lock()
+    d.degraded = degraded
+    d.mu.Unlock()
 }
 
 func NewClient(conn net.Conn, id string, username string) *Client {
     return &Client{
-        Conn:     conn,
-        ID:       id,
-        Username: username,
-        Send:     make(chan protocol.Message, 256),
+        Conn:      conn,
+        ID:        id,
+        Username:  username,
+        SessionID: nextSessionID(),
+        Send:      make(chan protocol.Message, 256),
     }
 }
 
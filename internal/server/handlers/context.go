@@ -0,0 +1,97 @@
+// internal/server/handlers/context.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// the messages sent after the one to jump to, a longer gap with the
+// messages of the client is not filled
+const maxContextAfter = 500
+
+// handleFetchContext sends a message with the messages around it, for the
+// client to show it in its conversation. A reply of a thread is shown
+// through its root
+func (h *MessageHandler) handleFetchContext(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.FetchContextPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid fetch context payload: %v", err)
+    }
+    if payload.Before <= 0 || payload.Before > maxHistoryPage {
+        payload.Before = maxHistoryPage
+    }
+
+    target, err := getMessage(ctx, h.db, payload.MessageID)
+    if err == nil && target.ThreadRootID != nil {
+        target, err = getMessage(ctx, h.db, *target.ThreadRootID)
+    }
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Message not found")
+    }
+    if ok, err := h.canSeeMessage(ctx, sender.ID, target); err != nil {
+        return err
+    } else if !ok {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Message not found")
+    }
+
+    var groupID, otherID string
+    switch {
+    case target.GroupID != nil:
+        groupID = *target.GroupID
+    case target.RecipientID != nil && target.SenderID == sender.ID:
+        otherID = *target.RecipientID
+    case target.RecipientID != nil:
+        otherID = target.SenderID
+    }
+
+    var until time.Time
+    if payload.UntilID != "" {
+        if until, err = h.db.MessageSentAt(ctx, payload.UntilID); err != nil {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "Message not found")
+        }
+    }
+    newer, err := h.db.GetMessagesAfter(ctx, sender.ID, otherID, groupID, target.SentAt, until, maxContextAfter+1)
+    if err != nil {
+        return err
+    }
+    complete := len(newer) <= maxContextAfter
+    if !complete {
+        newer = newer[:maxContextAfter]
+    }
+    older, err := h.historyPage(ctx, sender.ID, otherID, groupID, target.ID, payload.Before)
+    if err != nil {
+        return err
+    }
+
+    // newest first, like the pages of history
+    messages := make([]models.Message, 0, len(newer)+1+len(older))
+    for i := len(newer) - 1; i >= 0; i-- {
+        messages = append(messages, newer[i])
+    }
+    messages = append(messages, *target)
+    messages = append(messages, older...)
+    if err := h.db.AttachVoice(ctx, messages); err != nil {
+        return err
+    }
+    if err := h.db.AttachPreviews(ctx, messages); err != nil {
+        return err
+    }
+
+    response := protocol.NewMessage(protocol.TypeFetchContext, map[string]interface{}{
+        "message_id":   target.ID,
+        "messages":     messages,
+        "recipient_id": otherID,
+        "group_id":     groupID,
+        "complete":     complete,
+    })
+    select {
+    case sender.Send <- response:
+        return nil
+    default:
+        return fmt.Errorf("failed to send context: channel full")
+    }
+}
@@ -0,0 +1,58 @@
+// internal/server/handlers/display.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// a language tag such as fr, pt_BR or zh-Hant, the clients know which ones
+// they translate
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([_-][A-Za-z0-9]{2,8})?$`)
+
+// handleDisplayPrefs saves the display preferences of the user and sends
+// them to all their devices
+func (h *MessageHandler) handleDisplayPrefs(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.DisplayPrefsPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid display prefs payload: %v", err)
+    }
+
+    if payload.Timezone != "" {
+        // "Local" would be the timezone of the server
+        if _, err := time.LoadLocation(payload.Timezone); err != nil || payload.Timezone == "Local" {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "Unknown timezone")
+        }
+    }
+    switch payload.TimeFormat {
+    case "", protocol.TimeFormat24h, protocol.TimeFormat12h:
+    default:
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid time format")
+    }
+    if payload.Locale != "" && !localePattern.MatchString(payload.Locale) {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid locale")
+    }
+
+    prefs := models.DisplayPrefs{
+        Timezone:   payload.Timezone,
+        TimeFormat: payload.TimeFormat,
+        Locale:     payload.Locale,
+    }
+    if err := h.db.SetDisplayPrefs(ctx, sender.ID, prefs); err != nil {
+        return err
+    }
+    h.sessions.Send(sender.ID, protocol.NewMessage(protocol.TypeDisplayPrefs, displayPrefsPayload(prefs)))
+    return nil
+}
+
+func displayPrefsPayload(prefs models.DisplayPrefs) protocol.DisplayPrefsPayload {
+    return protocol.DisplayPrefsPayload{
+        Timezone:   prefs.Timezone,
+        TimeFormat: prefs.TimeFormat,
+        Locale:     prefs.Locale,
+    }
+}
@@ -0,0 +1,213 @@
+// internal/server/handlers/e2ee.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// limits of the encryption messages, the server can't check more
+const (
+    maxDeviceIDLength   = 32
+    maxOneTimePreKeys   = 100
+    maxEnvelopes        = 10
+    maxCiphertextLength = 64 << 10
+)
+
+// handleKeyBundle publishes the keys of the device of the sender, then
+// delivers the encrypted messages that waited for it
+func (h *MessageHandler) handleKeyBundle(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.KeyBundlePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid key bundle payload: %v", err)
+    }
+    if len(payload.Devices) != 1 || len(payload.OneTimePreKeys) > maxOneTimePreKeys {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid key bundle")
+    }
+    device := payload.Devices[0]
+    if device.DeviceID == "" || len(device.DeviceID) > maxDeviceIDLength ||
+        len(device.IdentityKey) != 32 || len(device.SignedPreKey) != 32 || len(device.SigningKey) != 32 || len(device.Signature) != 64 {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid key bundle")
+    }
+    for _, key := range payload.OneTimePreKeys {
+        if len(key.Key) != 32 {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid one-time prekey")
+        }
+    }
+
+    if err := h.db.SaveDeviceKeys(ctx, sender.ID, device, payload.OneTimePreKeys); err != nil {
+        return err
+    }
+
+    queued, err := h.db.TakeEncryptedMessages(ctx, sender.ID)
+    if err != nil {
+        return err
+    }
+    for i := range queued {
+        select {
+        case sender.Send <- protocol.NewMessage(protocol.TypeEncryptedMessage, queued[i]):
+        default:
+            // kept for the next connection
+            if err := h.db.QueueEncryptedMessage(ctx, &queued[i]); err != nil {
+                log.Printf("Failed to queue encrypted message again: %v", err)
+            }
+        }
+    }
+    return nil
+}
+
+// handleKeyBundleRequest sends the keys of the devices of a user, with a
+// one-time prekey each
+func (h *MessageHandler) handleKeyBundleRequest(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.KeyBundleRequestPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid key bundle request: %v", err)
+    }
+    if payload.UserID == "" {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Missing user")
+    }
+    if blocked, err := h.db.IsBlocked(ctx, sender.ID, payload.UserID); err != nil {
+        return err
+    } else if blocked {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "You can't send messages to this user")
+    }
+
+    devices, err := h.db.ClaimKeyBundles(ctx, payload.UserID)
+    if err != nil {
+        return err
+    }
+    response := protocol.KeyBundlePayload{UserID: payload.UserID, Devices: devices}
+    if response.Devices == nil {
+        response.Devices = []protocol.DeviceBundle{}
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeKeyBundle, response):
+        return nil
+    default:
+        return fmt.Errorf("failed to send key bundle: channel full")
+    }
+}
+
+// handleEncryptedMessage relays an encrypted direct message or sender key.
+// It is queued until the recipient is online, then deleted: the server never
+// keeps it
+func (h *MessageHandler) handleEncryptedMessage(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.EncryptedMessagePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid encrypted message payload: %v", err)
+    }
+    if payload.SenderDevice == "" || len(payload.SenderDevice) > maxDeviceIDLength ||
+        len(payload.Envelopes) == 0 || len(payload.Envelopes) > maxEnvelopes {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid encrypted message")
+    }
+    for _, env := range payload.Envelopes {
+        if len(env.Ciphertext) > maxCiphertextLength || len(env.Header) > maxCiphertextLength {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "Encrypted message too long")
+        }
+    }
+    payload.SenderID = sender.ID
+    payload.SenderName = sender.Username
+
+    if payload.GroupID != "" && !payload.SenderKey {
+        return h.relayGroupEncryptedMessage(ctx, sender, payload)
+    }
+    if payload.RecipientID == "" || payload.RecipientID == sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid encrypted message")
+    }
+    if payload.SenderKey {
+        // both ends must be in the group, the key is of no use to anyone else
+        if err := h.requireEncryptedGroup(ctx, payload.GroupID, sender.ID, payload.RecipientID); err != nil {
+            return err
+        }
+    } else if payload.RecipientID == protocol.SystemUserID {
+        return errSystemRecipient
+    } else if blocked, err := h.db.IsBlocked(ctx, sender.ID, payload.RecipientID); err != nil {
+        return err
+    } else if blocked {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "You can't send messages to this user")
+    }
+
+    if err := h.db.QueueEncryptedMessage(ctx, &payload); err != nil {
+        return err
+    }
+    h.deliverEncrypted(ctx, payload)
+
+    if payload.SenderKey {
+        return nil
+    }
+    // the sender gets the ID and time, it kept the text
+    return h.ackEncrypted(sender, payload)
+}
+
+// relayGroupEncryptedMessage queues a group message for the members using
+// encryption and pushes it to those online
+func (h *MessageHandler) relayGroupEncryptedMessage(ctx context.Context, sender *Client, payload protocol.EncryptedMessagePayload) error {
+    if len(payload.Envelopes) != 1 || payload.Envelopes[0].DeviceID != "" {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid encrypted group message")
+    }
+    if err := h.requireEncryptedGroup(ctx, payload.GroupID, sender.ID); err != nil {
+        return err
+    }
+
+    payload.RecipientID = ""
+    queued, err := h.db.QueueGroupEncryptedMessage(ctx, &payload)
+    if err != nil {
+        return err
+    }
+    for _, q := range queued {
+        h.deliverEncrypted(ctx, q)
+    }
+
+    ack := payload
+    ack.SentAt = time.Now().Unix()
+    if len(queued) > 0 {
+        ack.ID, ack.SentAt = queued[0].ID, queued[0].SentAt
+    }
+    return h.ackEncrypted(sender, ack)
+}
+
+// requireEncryptedGroup checks that the group is encrypted and that the users
+// are members
+func (h *MessageHandler) requireEncryptedGroup(ctx context.Context, groupID string, userIDs ...string) error {
+    encrypted, err := h.db.IsGroupEncrypted(ctx, groupID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeGroupNotFound, "Group not found")
+    }
+    if !encrypted {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "This group is not encrypted")
+    }
+    for _, userID := range userIDs {
+        if isMember, err := h.db.IsGroupMember(ctx, userID, groupID); err != nil {
+            return fmt.Errorf("failed to check group membership: %v", err)
+        } else if !isMember {
+            return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+        }
+    }
+    return nil
+}
+
+// deliverEncrypted pushes a queued message to the devices of its recipient
+// when online, and deletes it once sent to one
+func (h *MessageHandler) deliverEncrypted(ctx context.Context, payload protocol.EncryptedMessagePayload) {
+    if h.sessions.Send(payload.RecipientID, protocol.NewMessage(protocol.TypeEncryptedMessage, payload)) == 0 {
+        return
+    }
+    if err := h.db.DeleteEncryptedMessage(ctx, payload.ID); err != nil {
+        log.Printf("Failed to delete delivered encrypted message: %v", err)
+    }
+}
+
+func (h *MessageHandler) ackEncrypted(sender *Client, payload protocol.EncryptedMessagePayload) error {
+    ack := payload
+    ack.Envelopes = nil
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeEncryptedMessage, ack):
+        return nil
+    default:
+        return fmt.Errorf("failed to acknowledge encrypted message: channel full")
+    }
+}
@@ -4,7 +4,6 @@ package handlers
 import (
 	"sync"
 	"textual/pkg/protocol"
-	"time"
 )
 
 type EventHandler struct {
@@ -66,69 +65,3 @@ func (h *EventHandler) NotifyUser(userID string, msg protocol.Message) {
         }
     }
 }
-
-type StatusMonitor struct {
-    eventHandler *EventHandler
-    userStatus   map[string]string
-    lastSeen    map[string]time.Time
-    mu          sync.RWMutex
-}
-
-func NewStatusMonitor(eh *EventHandler) *StatusMonitor {
-    sm := &StatusMonitor{
-        eventHandler: eh,
-        userStatus:   make(map[string]string),
-        lastSeen:    make(map[string]time.Time),
-    }
-
-    // Démarrer le moniteur de statut
-    go sm.monitor()
-    return sm
-}
-
-func (sm *StatusMonitor) monitor() {
-    ticker := time.NewTicker(time.Minute)
-    defer ticker.Stop()
-
-    for range ticker.C {
-        sm.checkInactiveUsers()
-    }
-}
-
-func (sm *StatusMonitor) checkInactiveUsers() {
-    sm.mu.Lock()
-    defer sm.mu.Unlock()
-
-    now := time.Now()
-    for userID, lastSeen := range sm.lastSeen {
-        if now.Sub(lastSeen) > 5*time.Minute && sm.userStatus[userID] == "online" {
-            sm.userStatus[userID] = "away"
-            sm.eventHandler.Broadcast(protocol.NewMessage(protocol.TypeStatusUpdate, protocol.StatusUpdatePayload{
-                UserID: userID,
-                Status: "away",
-            }))
-        }
-    }
-}
-
-func (sm *StatusMonitor) UpdateStatus(userID, status string) {
-    sm.mu.Lock()
-    defer sm.mu.Unlock()
-
-    if oldStatus, exists := sm.userStatus[userID]; !exists || oldStatus != status {
-        sm.userStatus[userID] = status
-        sm.lastSeen[userID] = time.Now()
-
-        sm.eventHandler.Broadcast(protocol.NewMessage(protocol.TypeStatusUpdate, protocol.StatusUpdatePayload{
-            UserID: userID,
-            Status: status,
-        }))
-    }
-}
-
-func (sm *StatusMonitor) UpdateLastSeen(userID string) {
-    sm.mu.Lock()
-    defer sm.mu.Unlock()
-
-    sm.lastSeen[userID] = time.Now()
-}
\ No newline at end of file
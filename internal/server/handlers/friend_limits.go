@@ -0,0 +1,64 @@
+// internal/server/handlers/friend_limits.go
+package handlers
+
+import (
+	"context"
+	"time"
+)
+
+// the friend requests a user can send in friendRequestWindow, and how long
+// after a refusal the same request is ignored
+const (
+    defaultFriendRequestsPerHour = 10
+    defaultFriendRequestCooldown = 24 * time.Hour
+    friendRequestWindow          = time.Hour
+)
+
+// SetFriendRequestLimits sets how many friend requests a user can send per
+// hour, 0 for no limit, and how long the requests to a user who refused
+// one are ignored, 0 to never ignore them. Set them before serving
+func (h *MessageHandler) SetFriendRequestLimits(perHour int, cooldown time.Duration) {
+    h.friendRequestsPerHour = perHour
+    h.friendRequestCooldown = cooldown
+}
+
+// allowFriendRequest counts a friend request of a user, false past the limit
+// of the window
+func (h *MessageHandler) allowFriendRequest(userID string) bool {
+    if h.friendRequestsPerHour <= 0 {
+        return true
+    }
+    h.friendMu.Lock()
+    defer h.friendMu.Unlock()
+
+    now := h.clock.Now()
+    recent := h.friendRequests[userID][:0]
+    for _, at := range h.friendRequests[userID] {
+        if now.Sub(at) < friendRequestWindow {
+            recent = append(recent, at)
+        }
+    }
+    if len(recent) >= h.friendRequestsPerHour {
+        h.friendRequests[userID] = recent
+        return false
+    }
+    h.friendRequests[userID] = append(recent, now)
+    return true
+}
+
+// ignoreFriendRequest tells if a request is dropped without the sender
+// knowing: the target blocked them, or refused them within the cooldown.
+// Told, they would only try again another way
+func (h *MessageHandler) ignoreFriendRequest(ctx context.Context, senderID, targetID string) (bool, error) {
+    if blocked, err := h.db.HasBlocked(ctx, targetID, senderID); err != nil || blocked {
+        return blocked, err
+    }
+    if h.friendRequestCooldown <= 0 {
+        return false, nil
+    }
+    rejectedAt, err := h.db.FriendRequestRejectedAt(ctx, senderID, targetID)
+    if err != nil || rejectedAt.IsZero() {
+        return false, err
+    }
+    return h.clock.Now().Sub(rejectedAt) < h.friendRequestCooldown, nil
+}
@@ -0,0 +1,43 @@
+// internal/server/handlers/friend_limits_test.go
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"textual/internal/clock"
+)
+
+func TestAllowFriendRequest(t *testing.T) {
+    fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+    h := NewMessageHandler(nil, nil, nil)
+    h.SetClock(fake)
+    h.SetFriendRequestLimits(3, time.Hour)
+
+    for i := 0; i < 3; i++ {
+        if !h.allowFriendRequest("alice") {
+            t.Fatalf("request %d refused", i+1)
+        }
+        fake.Advance(10 * time.Minute)
+    }
+    if h.allowFriendRequest("alice") {
+        t.Fatal("fourth request in the hour allowed")
+    }
+    if !h.allowFriendRequest("bob") {
+        t.Fatal("the limit is per user")
+    }
+
+    // the first request leaves the window
+    fake.Advance(30 * time.Minute)
+    if !h.allowFriendRequest("alice") {
+        t.Fatal("request refused once the first left the window")
+    }
+    if h.allowFriendRequest("alice") {
+        t.Fatal("request allowed past the limit")
+    }
+
+    h.SetFriendRequestLimits(0, time.Hour)
+    if !h.allowFriendRequest("alice") {
+        t.Fatal("request refused without a limit")
+    }
+}
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"textual/internal/server/database"
@@ -10,37 +11,37 @@ import (
 )
 
 type FriendHandler struct {
-    db        *database.DB
-    clients   map[string]*Client
+    db        database.Store
+    sessions  *Sessions
     broadcast chan protocol.Message
 }
 
-func NewFriendHandler(db *database.DB, clients map[string]*Client, broadcast chan protocol.Message) *FriendHandler {
+func NewFriendHandler(db database.Store, sessions *Sessions, broadcast chan protocol.Message) *FriendHandler {
     return &FriendHandler{
         db:        db,
-        clients:   clients,
+        sessions:  sessions,
         broadcast: broadcast,
     }
 }
 
-func (h *FriendHandler) HandleFriendRequest(senderID string, request protocol.FriendRequestPayload) error {
+func (h *FriendHandler) HandleFriendRequest(ctx context.Context, senderID string, request protocol.FriendRequestPayload) error {
     // get sender info
-    sender, err := h.db.GetUser(senderID)
+    sender, err := h.db.GetUser(ctx, senderID)
     if err != nil {
         return fmt.Errorf("failed to get sender info: %v", err)
     }
 
     // get target user info
-    targetUser, err := h.db.GetUserByUsername(request.ToUser)
+    targetUser, err := h.db.GetUserByUsername(ctx, request.ToUser)
     if err != nil {
         return fmt.Errorf("target user not found: %v", err)
     }
 
     // generate request ID
-    requestID := fmt.Sprintf("fr-%s-%s-%d", sender.ID, targetUser.ID, time.Now().Unix())
+    requestID := database.FriendRequestID(sender.ID, targetUser.ID, time.Now())
 
     // Create friend request in database
-    err = h.db.CreateFriendRequest(sender.ID, targetUser.ID)
+    err = h.db.CreateFriendRequest(ctx, sender.ID, targetUser.ID)
     if err != nil {
         return fmt.Errorf("failed to create friend request: %v", err)
     }
@@ -58,42 +59,31 @@ func (h *FriendHandler) HandleFriendRequest(senderID string, request protocol.Fr
     }
 
     // send notification to target user if online
-    if targetClient, ok := h.clients[targetUser.ID]; ok {
-        log.Printf("Sending friend request notification to %s", targetUser.Username)
-        select {
-        case targetClient.Send <- notification:
-            log.Printf("Friend request notification sent to %s", targetUser.Username)
-        default:
-            log.Printf("Failed to send friend request notification to %s: channel full", targetUser.Username)
-        }
+    if h.sessions.Send(targetUser.ID, notification) > 0 {
+        log.Printf("Friend request notification sent to %s", targetUser.Username)
     }
 
     // send confirmation to sender
-    if senderClient, ok := h.clients[senderID]; ok {
-        confirmation := protocol.Message{
-            Type: protocol.TypeFriendRequest,
-            Payload: protocol.FriendRequestPayload{
-                RequestID: requestID,
-                FromUser:  sender.Username,
-                ToUser:    targetUser.Username,
-                Status:    "sent",
-            },
-            Timestamp: time.Now().Unix(),
-        }
-        select {
-        case senderClient.Send <- confirmation:
-            log.Printf("Friend request confirmation sent to %s", sender.Username)
-        default:
-            log.Printf("Failed to send friend request confirmation to %s: channel full", sender.Username)
-        }
+    confirmation := protocol.Message{
+        Type: protocol.TypeFriendRequest,
+        Payload: protocol.FriendRequestPayload{
+            RequestID: requestID,
+            FromUser:  sender.Username,
+            ToUser:    targetUser.Username,
+            Status:    "sent",
+        },
+        Timestamp: time.Now().Unix(),
+    }
+    if h.sessions.Send(senderID, confirmation) > 0 {
+        log.Printf("Friend request confirmation sent to %s", sender.Username)
     }
 
     return nil
 }
 
-func (h *FriendHandler) HandleFriendResponse(userID string, response protocol.FriendResponsePayload) error {
+func (h *FriendHandler) HandleFriendResponse(ctx context.Context, userID string, response protocol.FriendResponsePayload) error {
     // get users involved in the friend request
-    fromUser, toUser, err := h.db.GetFriendRequestUsers(response.RequestID)
+    fromUser, toUser, err := h.db.GetFriendRequestUsers(ctx, response.RequestID)
     if err != nil {
         return fmt.Errorf("failed to get friend request: %v", err)
     }
@@ -105,9 +95,9 @@ func (h *FriendHandler) HandleFriendResponse(userID string, response protocol.Fr
 
     // update the db
     if response.Accept {
-        err = h.db.AcceptFriendRequest(fromUser.ID, toUser.ID)
+        err = h.db.AcceptFriendRequest(ctx, fromUser.ID, toUser.ID)
     } else {
-        err = h.db.RejectFriendRequest(fromUser.ID, toUser.ID)
+        err = h.db.RejectFriendRequest(ctx, fromUser.ID, toUser.ID)
     }
 
     if err != nil {
@@ -131,46 +121,36 @@ func (h *FriendHandler) HandleFriendResponse(userID string, response protocol.Fr
     }
 
     // notify the requester
-    if requesterClient, ok := h.clients[fromUser.ID]; ok {
-        select {
-        case requesterClient.Send <- notification:
-            log.Printf("Friend request response sent to %s: %s", fromUser.Username, status)
-        default:
-            log.Printf("Failed to send friend request response to %s: channel full", fromUser.Username)
-        }
+    if h.sessions.Send(fromUser.ID, notification) > 0 {
+        log.Printf("Friend request response sent to %s: %s", fromUser.Username, status)
     }
 
     // send confirmation to responder
-    if responderClient, ok := h.clients[toUser.ID]; ok {
-        confirmation := protocol.Message{
-            Type: protocol.TypeFriendResponse,
-            Payload: protocol.FriendResponsePayload{
-                RequestID: response.RequestID,
-                FromUser:  fromUser.Username,  // Original requester
-                Accept:    response.Accept,
-            },
-            Timestamp: time.Now().Unix(),
-        }
-        select {
-        case responderClient.Send <- confirmation:
-            log.Printf("Friend request response confirmation sent to %s", toUser.Username)
-        default:
-            log.Printf("Failed to send friend request response confirmation to %s: channel full", toUser.Username)
-        }
+    confirmation := protocol.Message{
+        Type: protocol.TypeFriendResponse,
+        Payload: protocol.FriendResponsePayload{
+            RequestID: response.RequestID,
+            FromUser:  fromUser.Username,  // Original requester
+            Accept:    response.Accept,
+        },
+        Timestamp: time.Now().Unix(),
+    }
+    if h.sessions.Send(toUser.ID, confirmation) > 0 {
+        log.Printf("Friend request response confirmation sent to %s", toUser.Username)
     }
 
     // if accepted, update friend list fot both users
     if response.Accept {
-        h.sendUpdatedFriendList(fromUser.ID)
-        h.sendUpdatedFriendList(toUser.ID)
+        h.sendUpdatedFriendList(ctx, fromUser.ID)
+        h.sendUpdatedFriendList(ctx, toUser.ID)
     }
 
     return nil
 }
 
-func (h *FriendHandler) sendUpdatedFriendList(userID string) error {
+func (h *FriendHandler) sendUpdatedFriendList(ctx context.Context, userID string) error {
     // get friend list
-    friends, err := h.db.GetFriends(userID)
+    friends, err := h.db.GetFriends(ctx, userID)
     if err != nil {
         return fmt.Errorf("failed to get friend list: %v", err)
     }
@@ -179,27 +159,24 @@ func (h *FriendHandler) sendUpdatedFriendList(userID string) error {
     friendInfos := make([]protocol.UserInfo, 0, len(friends))
     for _, friend := range friends {
         friendInfos = append(friendInfos, protocol.UserInfo{
-            ID:       friend.ID,
-            Username: friend.Username,
-            Status:   friend.Status,
+            ID:            friend.ID,
+            Username:      friend.Username,
+            Status:        friend.Status,
+            Text:          friend.StatusText,
+            TextExpiresAt: unixTime(friend.StatusExpiresAt),
         })
     }
 
     // send updated friend list to user
-    if client, ok := h.clients[userID]; ok {
-        msg := protocol.Message{
-            Type: protocol.TypeFriendList,
-            Payload: protocol.FriendListPayload{
-                Friends: friendInfos,
-            },
-            Timestamp: time.Now().Unix(),
-        }
-        select {
-        case client.Send <- msg:
-            log.Printf("Updated friend list sent to user %s", userID)
-        default:
-            log.Printf("Failed to send updated friend list to user %s: channel full", userID)
-        }
+    msg := protocol.Message{
+        Type: protocol.TypeFriendList,
+        Payload: protocol.FriendListPayload{
+            Friends: friendInfos,
+        },
+        Timestamp: time.Now().Unix(),
+    }
+    if h.sessions.Send(userID, msg) > 0 {
+        log.Printf("Updated friend list sent to user %s", userID)
     }
 
     return nil
@@ -207,20 +184,20 @@ func (h *FriendHandler) sendUpdatedFriendList(userID string) error {
 
 
 
-func (h *FriendHandler) SendFriendRequest(userID, friendUsername string) error {
-    friend, err := h.db.GetUser(friendUsername)
+func (h *FriendHandler) SendFriendRequest(ctx context.Context, userID, friendUsername string) error {
+    friend, err := h.db.GetUser(ctx, friendUsername)
     if err != nil {
         return err
     }
     
-    return h.db.CreateFriendRequest(userID, friend.ID)
+    return h.db.CreateFriendRequest(ctx, userID, friend.ID)
 }
 
-func (h *FriendHandler) AcceptFriendRequest(userID, friendID string) error {
-    return h.db.AcceptFriendRequest(userID, friendID)
+func (h *FriendHandler) AcceptFriendRequest(ctx context.Context, userID, friendID string) error {
+    return h.db.AcceptFriendRequest(ctx, userID, friendID)
 }
 
-func (h *FriendHandler) GetFriendList(userID string) ([]string, error) {
-    return h.db.GetFriendList(userID)
+func (h *FriendHandler) GetFriendList(ctx context.Context, userID string) ([]string, error) {
+    return h.db.GetFriendList(ctx, userID)
 }
 
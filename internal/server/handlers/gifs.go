@@ -0,0 +1,177 @@
+// internal/server/handlers/gifs.go
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"textual/internal/server/gifs"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+    // searches running at the same time, the others are refused
+    maxGifSearches = 4
+    // searches a user can make in gifWindow, each one costs the quota of
+    // the API key of the server
+    gifsPerUser = 5
+    gifWindow   = time.Minute
+)
+
+// SetGifs enables /gif, searched with the provider of the searcher
+func (h *MessageHandler) SetGifs(searcher *gifs.Searcher, timeout time.Duration) {
+    h.gifs = searcher
+    h.gifTimeout = timeout
+    h.gifSlots = make(chan struct{}, maxGifSearches)
+    h.gifSearches = make(map[string][]time.Time)
+}
+
+// handleGif searches a GIF in the background then posts it like a message of
+// the user, with a preview whose image is a still of the GIF
+func (h *MessageHandler) handleGif(ctx context.Context, sender *Client, msg protocol.Message) error {
+    if h.gifs == nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "GIFs are not enabled on this server")
+    }
+    var payload protocol.GifPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("failed to decode gif payload: %v", err)
+    }
+    payload.Query = strings.TrimSpace(payload.Query)
+    switch {
+    case payload.Query == "":
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Missing GIF search")
+    case utf8.RuneCountInString(payload.Query) > protocol.MaxGifQuery:
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("GIF search too long (%d characters max)", protocol.MaxGifQuery))
+    case payload.RecipientID != "" && payload.GroupID != "":
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "A GIF goes to a user or a group, not both")
+    }
+
+    // the conversation is checked before the search, which is paid for
+    switch {
+    case payload.RecipientID != "":
+        if _, err := h.db.GetUser(ctx, payload.RecipientID); err != nil {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "User not found")
+        }
+        if err := h.checkRecipient(ctx, sender.ID, payload.RecipientID); err != nil {
+            return err
+        }
+    case payload.GroupID != "":
+        if err := h.checkGroupPoster(ctx, sender.ID, payload.GroupID); err != nil {
+            return err
+        }
+    }
+    if !h.allowGif(sender.ID) {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Too many GIFs, %d per minute at most", gifsPerUser))
+    }
+
+    if payload.ClientID == "" {
+        raw := make([]byte, 8)
+        if _, err := rand.Read(raw); err != nil {
+            return fmt.Errorf("failed to generate gif client id: %v", err)
+        }
+        payload.ClientID = "gif-" + hex.EncodeToString(raw)
+    }
+
+    select {
+    case h.gifSlots <- struct{}{}:
+    default:
+        return protocol.NewError(protocol.ErrCodeUnavailable, "Too many GIF searches, please try again later")
+    }
+    user := &models.User{ID: sender.ID, Username: sender.Username}
+    go func() {
+        defer func() { <-h.gifSlots }()
+        if err := h.postGif(user, payload); err != nil {
+            log.Printf("Failed to post GIF for %s: %v", user.Username, err)
+            h.sendToUser(user.ID, errorMessage(err))
+        }
+    }()
+    return nil
+}
+
+// allowGif counts a search of a user, false past the limit of the window
+func (h *MessageHandler) allowGif(userID string) bool {
+    h.gifMu.Lock()
+    defer h.gifMu.Unlock()
+
+    now := h.clock.Now()
+    recent := h.gifSearches[userID][:0]
+    for _, at := range h.gifSearches[userID] {
+        if now.Sub(at) < gifWindow {
+            recent = append(recent, at)
+        }
+    }
+    if len(recent) >= gifsPerUser {
+        h.gifSearches[userID] = recent
+        return false
+    }
+    h.gifSearches[userID] = append(recent, now)
+    return true
+}
+
+func (h *MessageHandler) postGif(user *models.User, payload protocol.GifPayload) error {
+    ctx, cancel := context.WithTimeout(h.ctx, h.gifTimeout)
+    defer cancel()
+    gif, err := h.gifs.Search(ctx, payload.Query)
+    if err == gifs.ErrNotFound {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("No GIF found for %q", payload.Query))
+    }
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeUnavailable, "GIF search unavailable, please try again later")
+    }
+
+    // the message is the link of the GIF, readable by any client; it goes
+    // through the checks of the conversation like a message typed
+    post := map[string]interface{}{"content": gif.URL, "client_id": payload.ClientID}
+    msgType := protocol.TypeGlobalMessage
+    switch {
+    case payload.RecipientID != "":
+        msgType = protocol.TypeDirectMessage
+        post["recipient_id"] = payload.RecipientID
+    case payload.GroupID != "":
+        msgType = protocol.TypeGroupMessage
+        post["group_id"] = payload.GroupID
+    }
+    if _, err := h.HandleAPI(ctx, user, protocol.NewMessage(msgType, post)); err != nil {
+        return err
+    }
+
+    id, err := h.db.GetMessageIDByClientID(ctx, user.ID, payload.ClientID)
+    if err != nil {
+        return err
+    }
+    message, err := h.db.GetMessage(ctx, id)
+    if err != nil {
+        return err
+    }
+    title := gif.Title
+    if title == "" {
+        title = payload.Query
+    }
+    preview := models.LinkPreview{URL: gif.URL, Title: "GIF: " + title, ImageURL: gif.StillURL, SiteName: gif.Provider}
+    sanitizePreview(&preview)
+    if err := h.db.SaveLinkPreview(ctx, message.ID, preview); err != nil {
+        return err
+    }
+    message.Preview = &preview
+    return h.sendToConversation(ctx, message, protocol.NewMessage(protocol.TypeLinkPreview, h.createMessagePayload(message)))
+}
+
+// sendToUser sends a message to the sessions of a user, if online
+func (h *MessageHandler) sendToUser(userID string, msg protocol.Message) {
+    h.sessions.Send(userID, msg)
+}
+
+// errorMessage is the answer to a request that failed, as the read loop
+// sends it
+func errorMessage(err error) protocol.Message {
+    if protoErr, ok := err.(protocol.Error); ok {
+        return protoErr.Reply()
+    }
+    return protocol.NewErrorMessage(protocol.ErrCodeInternalError, err.Error())
+}
@@ -0,0 +1,43 @@
+// internal/server/handlers/global_limits.go
+package handlers
+
+import (
+	"time"
+)
+
+// the messages a user can post in the global chat in globalWindow, everyone
+// reads them so they are limited more than the other conversations
+const (
+    defaultGlobalMessagesPerMinute = 20
+    globalWindow                   = time.Minute
+)
+
+// SetGlobalMessageLimit sets how many messages a user can post per minute in
+// the global chat, 0 for no limit. Set it before serving
+func (h *MessageHandler) SetGlobalMessageLimit(perMinute int) {
+    h.globalMessagesPerMinute = perMinute
+}
+
+// allowGlobalMessage counts a message of a user in the global chat, past the
+// limit of the window it returns how long until the oldest one leaves it
+func (h *MessageHandler) allowGlobalMessage(userID string) (time.Duration, bool) {
+    if h.globalMessagesPerMinute <= 0 {
+        return 0, true
+    }
+    h.globalMu.Lock()
+    defer h.globalMu.Unlock()
+
+    now := h.clock.Now()
+    recent := h.globalMessages[userID][:0]
+    for _, at := range h.globalMessages[userID] {
+        if now.Sub(at) < globalWindow {
+            recent = append(recent, at)
+        }
+    }
+    if len(recent) >= h.globalMessagesPerMinute {
+        h.globalMessages[userID] = recent
+        return recent[0].Add(globalWindow).Sub(now), false
+    }
+    h.globalMessages[userID] = append(recent, now)
+    return 0, true
+}
@@ -0,0 +1,44 @@
+// internal/server/handlers/global_limits_test.go
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"textual/internal/clock"
+)
+
+func TestAllowGlobalMessage(t *testing.T) {
+    fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+    h := NewMessageHandler(nil, nil, nil)
+    h.SetClock(fake)
+    h.SetGlobalMessageLimit(2)
+
+    for i := 0; i < 2; i++ {
+        if _, ok := h.allowGlobalMessage("alice"); !ok {
+            t.Fatalf("message %d refused", i+1)
+        }
+        fake.Advance(20 * time.Second)
+    }
+    wait, ok := h.allowGlobalMessage("alice")
+    if ok {
+        t.Fatal("third message in the minute allowed")
+    }
+    // the first message leaves the window 20s later
+    if wait != 20*time.Second {
+        t.Errorf("wait %v, want 20s", wait)
+    }
+    if _, ok := h.allowGlobalMessage("bob"); !ok {
+        t.Fatal("the limit is per user")
+    }
+
+    fake.Advance(wait)
+    if _, ok := h.allowGlobalMessage("alice"); !ok {
+        t.Fatal("message refused once the first left the window")
+    }
+
+    h.SetGlobalMessageLimit(0)
+    if _, ok := h.allowGlobalMessage("alice"); !ok {
+        t.Fatal("message refused without a limit")
+    }
+}
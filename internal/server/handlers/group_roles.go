@@ -0,0 +1,59 @@
+// internal/server/handlers/group_roles.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"textual/pkg/protocol"
+)
+
+// handleGroupRoleUpdate promotes a member to admin or demotes an admin, the
+// admins only. The member is notified and the members get the new list
+func (h *MessageHandler) handleGroupRoleUpdate(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupRolePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group role payload: %v", err)
+    }
+
+    if payload.Role != protocol.GroupRoleAdmin && payload.Role != protocol.GroupRoleMember {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Invalid role: %s", payload.Role))
+    }
+    if err := h.requireGroupAdmin(ctx, sender.ID, payload.GroupID); err != nil {
+        return err
+    }
+    // the last admin would leave the group without one
+    if payload.UserID == sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "You can't change your own role")
+    }
+
+    current, err := h.db.GetGroupRole(ctx, payload.UserID, payload.GroupID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "This user is not a member of the group")
+    }
+    if current == payload.Role {
+        return h.sendGroupMembers(ctx, payload.GroupID)
+    }
+    if err := h.db.UpdateGroupRole(ctx, payload.UserID, payload.GroupID, payload.Role); err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "The role of this member can't be changed")
+    }
+
+    group, err := h.db.GetGroup(ctx, payload.GroupID)
+    if err != nil {
+        return fmt.Errorf("failed to get group: %v", err)
+    }
+    kind := protocol.NoticeGroupPromoted
+    if payload.Role == protocol.GroupRoleMember {
+        kind = protocol.NoticeGroupDemoted
+    }
+    h.notify(ctx, payload.UserID, kind, sender.Username, group.Name, group.ID)
+    if user, err := h.db.GetUser(ctx, payload.UserID); err == nil {
+        event := protocol.SystemPromoted
+        if payload.Role == protocol.GroupRoleMember {
+            event = protocol.SystemDemoted
+        }
+        h.sendSystemMessage(ctx, group.ID, event, sender.Username, user.Username)
+    }
+
+    return h.sendGroupMembers(ctx, payload.GroupID)
+}
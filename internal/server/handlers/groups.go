@@ -2,64 +2,110 @@
 package handlers
 
 import (
+	"context"
+	"log"
+	"strings"
 	"textual/internal/server/database"
 	"textual/internal/server/models"
 	"textual/pkg/protocol"
 )
 
 type GroupHandler struct {
-    db        *database.DB
+    db        database.Store
     broadcast chan<- protocol.Message
+    sessions  *Sessions
 }
 
-func NewGroupHandler(db *database.DB, broadcast chan<- protocol.Message) *GroupHandler {
+func NewGroupHandler(db database.Store, broadcast chan<- protocol.Message, sessions *Sessions) *GroupHandler {
     return &GroupHandler{
         db:        db,
         broadcast: broadcast,
+        sessions:  sessions,
     }
 }
 
 
-func (h *GroupHandler) HandleGroupCreate(userID string, payload protocol.GroupCreatePayload) error {
+// HandleGroupCreate creates a group with its creator as admin. The sessions
+// of the creator get the group, the members added get it as a join
+func (h *GroupHandler) HandleGroupCreate(ctx context.Context, userID string, payload protocol.GroupCreatePayload) error {
+    // the server could not check who posts
+    if payload.Announcement && payload.Encrypted {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "An announcement group can't be encrypted")
+    }
+    name := strings.TrimSpace(sanitizeText(payload.Name))
+    if name == "" {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "A group needs a name")
+    }
+
     // create the group
-    group, err := h.db.CreateGroup(
-        payload.Name,
-        payload.Description,
+    group, err := h.db.CreateGroup(ctx, 
+        name,
+        sanitizeText(payload.Description),
         userID,
+        payload.Public,
+        payload.Encrypted,
+        payload.Announcement,
     )
     if err != nil {
-        return err
+        return quotaError(err, "failed to create group")
     }
 
-    // add the creator to the group
+    // the creator is a member already
+    var added []string
     for _, memberID := range payload.MemberIDs {
-        if err := h.db.AddUserToGroup(memberID, group.ID); err != nil {
-            continue // Log error but continue
+        if memberID == userID {
+            continue
+        }
+        if err := h.db.AddUserToGroup(ctx, memberID, group.ID); err != nil {
+            log.Printf("Failed to add %s to the new group %s: %v", memberID, group.ID, err)
+            continue
         }
+        added = append(added, memberID)
+        group.Members = append(group.Members, memberID)
+    }
 
-        // Notify the group members
-        h.broadcast <- protocol.NewMessage(protocol.TypeGroupJoin, protocol.GroupJoinPayload{
+    info := groupPayload(group)
+    for _, memberID := range added {
+        h.sessions.Send(memberID, protocol.NewMessage(protocol.TypeGroupJoin, protocol.GroupJoinPayload{
             GroupID: group.ID,
             UserID:  memberID,
-        })
+            Group:   &info,
+        }))
     }
+    h.sessions.Send(userID, protocol.NewMessage(protocol.TypeGroupCreate, info))
+    return nil
+}
 
-    // Notify the creator
-    h.broadcast <- protocol.NewMessage(protocol.TypeGroupCreate, group)
+// HandleGroupList answers a session with the groups of its user
+func (h *GroupHandler) HandleGroupList(ctx context.Context, sender *Client) error {
+    groups, err := h.db.GetUserGroups(ctx, sender.ID)
+    if err != nil {
+        return err
+    }
+
+    payload := protocol.GroupListPayload{Groups: make([]protocol.GroupPayload, 0, len(groups))}
+    for i := range groups {
+        payload.Groups = append(payload.Groups, groupPayload(&groups[i]))
+    }
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeGroupList, payload):
+    default:
+        log.Printf("Failed to send group list to %s: channel full", sender.Username)
+    }
     return nil
 }
 
 
-func (h *GroupHandler) HandleGroupJoin(userID string, groupID string) error {
+func (h *GroupHandler) HandleGroupJoin(ctx context.Context, userID string, groupID string) error {
     // check if the group exists
-    group, err := h.db.GetGroup(groupID)
+    group, err := h.db.GetGroup(ctx, groupID)
     if err != nil {
         return err
     }
 
     // add the user to the group
-    if err := h.db.AddUserToGroup(userID, group.ID); err != nil {
-        return err
+    if err := h.db.AddUserToGroup(ctx, userID, group.ID); err != nil {
+        return quotaError(err, "failed to join group")
     }
 
     // Notify the group members
@@ -72,12 +118,15 @@ func (h *GroupHandler) HandleGroupJoin(userID string, groupID string) error {
 }
 
 
-func (h *GroupHandler) HandleGroupMessage(userID string, payload protocol.GroupMessagePayload) error {
+func (h *GroupHandler) HandleGroupMessage(ctx context.Context, userID string, payload protocol.GroupMessagePayload) error {
     // check if the user is a member of the group
-    isMember, err := h.db.IsGroupMember(userID, payload.GroupID)
+    isMember, err := h.db.IsGroupMember(ctx, userID, payload.GroupID)
     if err != nil || !isMember {
         return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
     }
+    if err := requirePoster(ctx, h.db, userID, payload.GroupID); err != nil {
+        return err
+    }
 
     // save the message
     msg := &models.Message{
@@ -86,7 +135,7 @@ func (h *GroupHandler) HandleGroupMessage(userID string, payload protocol.GroupM
         Content:  payload.Content,
     }
     
-    if err := h.db.SaveMessage(msg); err != nil {
+    if err := h.db.SaveMessage(ctx, msg); err != nil {
         return err
     }
 
@@ -95,9 +144,9 @@ func (h *GroupHandler) HandleGroupMessage(userID string, payload protocol.GroupM
     return nil
 }
 
-func (h *GroupHandler) HandleGroupLeave(userID string, groupID string) error {
+func (h *GroupHandler) HandleGroupLeave(ctx context.Context, userID string, groupID string) error {
     // check if the user is a member of the group
-    isMember, err := h.db.IsGroupMember(userID, groupID)
+    isMember, err := h.db.IsGroupMember(ctx, userID, groupID)
     if err != nil {
         return err
     }
@@ -106,7 +155,7 @@ func (h *GroupHandler) HandleGroupLeave(userID string, groupID string) error {
     }
 
     // remove the user from the group
-    if err := h.db.RemoveUserFromGroup(userID, groupID); err != nil {
+    if err := h.db.RemoveUserFromGroup(ctx, userID, groupID); err != nil {
         return err
     }
 
@@ -119,6 +168,6 @@ func (h *GroupHandler) HandleGroupLeave(userID string, groupID string) error {
     return nil
 }
 
-func (h *GroupHandler) GetGroupMessages(groupID string) ([]models.Message, error) {
-    return h.db.GetGroupMessages(groupID)
+func (h *GroupHandler) GetGroupMessages(ctx context.Context, groupID string) ([]models.Message, error) {
+    return h.db.GetGroupMessages(ctx, groupID)
 }
@@ -0,0 +1,39 @@
+// internal/server/handlers/integrations.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// PostIntegrationMessage posts the message of an integration in its group,
+// as the system account. A delivery sent again with the same key is posted
+// once
+func (h *MessageHandler) PostIntegrationMessage(ctx context.Context, integration *models.Integration, content, key string) error {
+    if !h.db.Healthy() {
+        return protocol.NewError(protocol.ErrCodeUnavailable, "Database unavailable, please try again later")
+    }
+
+    // the content comes from another service, written by anyone
+    dbMsg := &models.Message{
+        Content:    sanitizeText(content),
+        SenderID:   protocol.SystemUserID,
+        SenderName: protocol.SystemUsername,
+        GroupID:    &integration.GroupID,
+        SentAt:     time.Now(),
+        Status:     models.MessageStatusSent,
+    }
+    if key != "" {
+        dbMsg.ClientID = fmt.Sprintf("%s-%.40s", integration.Kind, key)
+    }
+    if err := h.db.SaveMessage(ctx, dbMsg); err == database.ErrDuplicateMessage {
+        return nil
+    } else if err != nil {
+        return err
+    }
+    return h.sendToConversation(ctx, dbMsg, protocol.NewMessage(protocol.TypeGroupMessage, h.createMessagePayload(dbMsg)))
+}
@@ -2,51 +2,127 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"textual/internal/clock"
+	"textual/internal/server/attachments"
 	"textual/internal/server/database"
+	"textual/internal/server/gifs"
 	"textual/internal/server/models"
+	"textual/internal/server/previews"
 	"textual/pkg/protocol"
 	"time"
+	"unicode/utf8"
 )
 
+// largest history page a client can request
+const maxHistoryPage = 100
+
+// number of public groups listed in the group directory
+const groupDirectorySize = 50
+
+// number of users listed by a user search
+const userSearchSize = 50
+
 type MessageHandler struct {
-    db        *database.DB
-    broadcast chan<- protocol.Message
-    clients   map[string]*Client
-    mu        sync.RWMutex
+    db             database.Store
+    broadcast      chan<- protocol.Message
+    sessions       *Sessions
+    attachments    *attachments.Store // nil when uploads are disabled
+    previews       *previews.Fetcher  // nil when link previews are disabled
+    previewTimeout time.Duration
+    gifs           *gifs.Searcher // nil when /gif is disabled
+    gifTimeout     time.Duration
+    gifSlots       chan struct{}
+    gifMu          sync.Mutex
+    gifSearches    map[string][]time.Time // recent searches by user
+    friendMu       sync.Mutex
+    friendRequests map[string][]time.Time // recent friend requests by user
+    friendRequestsPerHour int
+    friendRequestCooldown time.Duration
+    globalMu       sync.Mutex
+    globalMessages map[string][]time.Time // recent global chat messages by user
+    globalMessagesPerMinute int
+    groups         *GroupHandler
+    adminMu        sync.Mutex
+    admins         map[string]bool // lowercase usernames
+    moderation     []protocol.ModerationEvent
+    messageRate    messageRate
+    clock          clock.Clock
+    // the lifetime of the server, for the work that outlives a request
+    ctx            context.Context
 }
 
-func NewMessageHandler(db *database.DB, broadcast chan<- protocol.Message, clients map[string]*Client) *MessageHandler {
+func NewMessageHandler(db database.Store, broadcast chan<- protocol.Message, sessions *Sessions) *MessageHandler {
     return &MessageHandler{
         db:        db,
         broadcast: broadcast,
-        clients:   clients,
+        sessions:  sessions,
+        clock:     clock.Real,
+        ctx:       context.Background(),
+        friendRequests:        make(map[string][]time.Time),
+        friendRequestsPerHour: defaultFriendRequestsPerHour,
+        friendRequestCooldown: defaultFriendRequestCooldown,
+        globalMessages:          make(map[string][]time.Time),
+        globalMessagesPerMinute: defaultGlobalMessagesPerMinute,
+        groups:                NewGroupHandler(db, broadcast, sessions),
     }
 }
 
-func (h *MessageHandler) HandleMessage(senderID string, msg protocol.Message) error {
-    log.Printf("Handling message of type %s from user %s", msg.Type, senderID)
+// SetClock replaces the clock of the reminders and of the GIF search, friend
+// request and global chat limits
+func (h *MessageHandler) SetClock(c clock.Clock) {
+    h.clock = c
+}
+
+// SetContext sets the context of the server, the previews and the GIF
+// searches running in the background stop with it. Set it before serving
+func (h *MessageHandler) SetContext(ctx context.Context) {
+    h.ctx = ctx
+}
+
+// HandleMessage runs a message of the session of a client. ctx ends with the
+// connection, or at the deadline of the request
+func (h *MessageHandler) HandleMessage(ctx context.Context, sender *Client, msg protocol.Message) error {
+    log.Printf("Handling message of type %s from user %s", msg.Type, sender.ID)
+    if err := ctx.Err(); err != nil {
+        return err
+    }
 
-    h.mu.RLock()
-    sender, exists := h.clients[senderID]
-    h.mu.RUnlock()
+    // fail fast instead of waiting on every query while the database is down
+    if msg.Type != protocol.TypePing && !h.db.Healthy() {
+        return protocol.NewError(protocol.ErrCodeUnavailable, "Database unavailable, please try again later")
+    }
 
-    if !exists {
-        return fmt.Errorf("sender not found")
+    switch msg.Type {
+    case protocol.TypeGlobalMessage, protocol.TypeDirectMessage, protocol.TypeGroupMessage, protocol.TypeEncryptedMessage, protocol.TypeVoiceMessage:
+        h.messageRate.Add(h.clock.Now())
     }
 
     switch msg.Type {
     case protocol.TypeLoadMessages:
-        return h.handleLoadMessages(sender, msg)
+        return h.handleLoadMessages(ctx, sender, msg)
     case protocol.TypeGlobalMessage:
-        return h.handleGlobalMessage(sender, msg)
+        return h.handleGlobalMessage(ctx, sender, msg)
     case protocol.TypeDirectMessage:
-        return h.handleDirectMessage(sender, msg)
+        return h.handleDirectMessage(ctx, sender, msg)
     case protocol.TypeGroupMessage:
-        return h.handleGroupMessage(sender, msg)
+        return h.handleGroupMessage(ctx, sender, msg)
+    case protocol.TypeMessageEdit:
+        return h.handleMessageEdit(ctx, sender, msg)
+    case protocol.TypeMessageRevisions:
+        return h.handleMessageRevisions(ctx, sender, msg)
+    case protocol.TypeTyping:
+        return h.handleTyping(ctx, sender, msg)
+    case protocol.TypeUserLookup:
+        return h.handleUserLookup(ctx, sender, msg)
+    case protocol.TypeUserSearch:
+        return h.handleUserSearch(ctx, sender, msg)
+    case protocol.TypeStatusUpdate:
+        return h.handleStatusUpdate(ctx, sender, msg)
     case protocol.TypePing:
         return h.handlePing(sender)
     case protocol.TypeFriendRequest:
@@ -54,18 +130,117 @@ func (h *MessageHandler) HandleMessage(senderID string, msg protocol.Message) er
         if err := h.decodePayload(msg.Payload, &payload); err != nil {
             return fmt.Errorf("invalid friend request payload: %v", err)
         }
-        return h.handleFriendRequest(sender, payload)
+        return h.handleFriendRequest(ctx, sender, payload)
+    case protocol.TypeFriendResponse:
+        var payload protocol.FriendResponsePayload
+        if err := h.decodePayload(msg.Payload, &payload); err != nil {
+            return fmt.Errorf("invalid friend response payload: %v", err)
+        }
+        return h.handleFriendResponse(ctx, sender, payload)
+    case protocol.TypeFriendRemove, protocol.TypeFriendBlock:
+        var payload protocol.FriendRemovePayload
+        if err := h.decodePayload(msg.Payload, &payload); err != nil {
+            return fmt.Errorf("invalid friend remove payload: %v", err)
+        }
+        return h.handleFriendRemove(ctx, sender, payload, msg.Type == protocol.TypeFriendBlock)
+    case protocol.TypeGroupCreate:
+        var payload protocol.GroupCreatePayload
+        if err := h.decodePayload(msg.Payload, &payload); err != nil {
+            return fmt.Errorf("invalid group create payload: %v", err)
+        }
+        return h.groups.HandleGroupCreate(ctx, sender.ID, payload)
+    case protocol.TypeGroupList:
+        return h.groups.HandleGroupList(ctx, sender)
+    case protocol.TypeGroupDirectory:
+        return h.handleGroupDirectory(ctx, sender)
+    case protocol.TypeGroupJoin:
+        return h.handleGroupJoin(ctx, sender, msg)
+    case protocol.TypeGroupMembers:
+        return h.handleGroupMembers(ctx, sender, msg)
+    case protocol.TypeGroupInvite:
+        return h.handleGroupInvite(ctx, sender, msg)
+    case protocol.TypeGroupKick:
+        return h.handleGroupKick(ctx, sender, msg)
+    case protocol.TypeGroupLeave:
+        return h.handleGroupLeave(ctx, sender, msg)
+    case protocol.TypeGroupTopic:
+        return h.handleGroupTopic(ctx, sender, msg)
+    case protocol.TypeGroupTopicHistory:
+        return h.handleGroupTopicHistory(ctx, sender, msg)
+    case protocol.TypeGroupRoleUpdate:
+        return h.handleGroupRoleUpdate(ctx, sender, msg)
+    case protocol.TypeNotificationList:
+        return h.handleNotificationList(ctx, sender)
+    case protocol.TypeNotificationRead:
+        return h.handleNotificationRead(ctx, sender, msg)
+    case protocol.TypeMentionsList:
+        return h.handleMentionsList(ctx, sender)
+    case protocol.TypeReadMarker:
+        return h.handleReadMarker(ctx, sender, msg)
+    case protocol.TypeNotificationPrefs:
+        return h.handleNotificationPrefs(ctx, sender, msg)
+    case protocol.TypeDisplayPrefs:
+        return h.handleDisplayPrefs(ctx, sender, msg)
+    case protocol.TypeKeyBundle:
+        return h.handleKeyBundle(ctx, sender, msg)
+    case protocol.TypeKeyBundleRequest:
+        return h.handleKeyBundleRequest(ctx, sender, msg)
+    case protocol.TypeEncryptedMessage:
+        return h.handleEncryptedMessage(ctx, sender, msg)
+    case protocol.TypeAttachmentUpload:
+        return h.handleAttachmentUpload(ctx, sender, msg)
+    case protocol.TypeAttachmentDownload:
+        return h.handleAttachmentDownload(ctx, sender, msg)
+    case protocol.TypeVoiceMessage:
+        return h.handleVoiceMessage(ctx, sender, msg)
+    case protocol.TypeLoadThread:
+        return h.handleLoadThread(ctx, sender, msg)
+    case protocol.TypeFetchContext:
+        return h.handleFetchContext(ctx, sender, msg)
+    case protocol.TypeReminderCreate:
+        return h.handleReminderCreate(ctx, sender, msg)
+    case protocol.TypeReminderList:
+        return h.handleReminderList(ctx, sender)
+    case protocol.TypeReminderCancel:
+        return h.handleReminderCancel(ctx, sender, msg)
+    case protocol.TypeGif:
+        return h.handleGif(ctx, sender, msg)
+    case protocol.TypeAdminStats:
+        return h.handleAdminStats(sender)
+    case protocol.TypeAdminAnnounce:
+        return h.handleAdminAnnounce(sender, msg)
+    case protocol.TypeAdminKick:
+        return h.handleAdminKick(ctx, sender, msg)
+    case protocol.TypeUnsupported:
+        // never answered, two versions not knowing each other's types would
+        // send them back and forth
+        var payload protocol.UnsupportedPayload
+        if err := h.decodePayload(msg.Payload, &payload); err == nil {
+            log.Printf("The client of %s does not support %s", sender.Username, payload.Type)
+        }
+        return nil
     default:
-        log.Printf("Unknown message type received: %s", msg.Type)
-        return fmt.Errorf("unknown message type: %s", msg.Type)
+        // a newer client, it tells the user what this server lacks
+        reply := protocol.NewMessage(protocol.TypeUnsupported, protocol.UnsupportedPayload{Type: msg.Type})
+        select {
+        case sender.Send <- reply:
+            return nil
+        default:
+            return fmt.Errorf("failed to send unsupported notice: channel full")
+        }
     }
 }
 
-func (h *MessageHandler) handleFriendRequest(sender *Client, payload protocol.FriendRequestPayload) error {
+func (h *MessageHandler) handleFriendRequest(ctx context.Context, sender *Client, payload protocol.FriendRequestPayload) error {
     log.Printf("Processing friend request from %s to %s", sender.Username, payload.ToUser)
 
+    // counted before the lookup, guessing the usernames costs as much
+    if !h.allowFriendRequest(sender.ID) {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Too many friend requests, %d per hour at most", h.friendRequestsPerHour))
+    }
+
     // get the target user
-    targetUser, err := h.db.GetUserByUsername(payload.ToUser)
+    targetUser, err := h.db.GetUserByUsername(ctx, payload.ToUser)
     if err != nil {
         errMsg := protocol.NewMessage(protocol.TypeError, map[string]interface{}{
             "error": fmt.Sprintf("User not found: %s", payload.ToUser),
@@ -74,68 +249,213 @@ func (h *MessageHandler) handleFriendRequest(sender *Client, payload protocol.Fr
         return fmt.Errorf("target user not found: %v", err)
     }
 
-    requestID := fmt.Sprintf("fr-%s-%s-%d", sender.ID, targetUser.ID, time.Now().Unix())
+    if targetUser.ID == protocol.SystemUserID {
+        return errSystemRecipient
+    }
+
+    requestID := database.FriendRequestID(sender.ID, targetUser.ID, h.clock.Now())
 
-    if err := h.db.CreateFriendRequest(sender.ID, targetUser.ID); err != nil {
-        return fmt.Errorf("failed to create friend request: %v", err)
+    if ignored, err := h.ignoreFriendRequest(ctx, sender.ID, targetUser.ID); err != nil {
+        return err
+    } else if ignored {
+        log.Printf("Ignoring friend request from %s to %s", sender.Username, targetUser.Username)
+        h.confirmFriendRequest(sender, targetUser.Username, requestID)
+        return nil
+    }
+    if blocked, err := h.db.IsBlocked(ctx, sender.ID, targetUser.ID); err != nil {
+        return err
+    } else if blocked {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, fmt.Sprintf("You can't send a friend request to %s", payload.ToUser))
     }
 
-    // Send to target user if online
-    h.mu.RLock()
-    if recipient, ok := h.clients[targetUser.ID]; ok {
-        // Le message doit être du même format que dans models.MessageReceived
-        notification := protocol.Message{
-            Type: protocol.TypeGlobalMessage, // Pour que ce soit traité comme un message normal
-            Payload: map[string]interface{}{
-                "id":          requestID,
-                "content":     "Friend request",
-                "sender_id":   sender.ID,
-                "sender_name": sender.Username,
-                "sent_at":     time.Now().Unix(),
-            },
-            Timestamp: time.Now().Unix(),
-        }
+    if err := h.db.CreateFriendRequest(ctx, sender.ID, targetUser.ID); err != nil {
+        return quotaError(err, "failed to create friend request")
+    }
 
-        select {
-        case recipient.Send <- notification:
-            log.Printf("Friend request sent to %s", targetUser.Username)
-        default:
-            log.Printf("Failed to send notification: channel full")
-        }
+    // on every device of the target, the pending requests come again at login
+    notification := protocol.NewMessage(protocol.TypeFriendRequest, protocol.FriendRequestPayload{
+        RequestID: requestID,
+        FromUser:  sender.Username,
+        ToUser:    targetUser.Username,
+        Status:    "pending",
+    })
+    if h.sessions.Send(targetUser.ID, notification) > 0 {
+        log.Printf("Friend request sent to %s", targetUser.Username)
     }
-    h.mu.RUnlock()
 
-    // Confirm to sender
+    // kept until read, the request shows up in the notification center
+    h.notify(ctx, targetUser.ID, protocol.NoticeFriendRequest, sender.Username, "", sender.ID)
+
+    h.confirmFriendRequest(sender, targetUser.Username, requestID)
+    return nil
+}
+
+// confirmFriendRequest tells the sender their request was sent
+func (h *MessageHandler) confirmFriendRequest(sender *Client, toUser, requestID string) {
     confirmationMsg := protocol.NewMessage(protocol.TypeFriendRequest, protocol.FriendRequestPayload{
         RequestID: requestID,
         FromUser:  sender.Username,
-        ToUser:    targetUser.Username,
+        ToUser:    toUser,
         Status:    "sent",
     })
 
-    select {
-    case sender.Send <- confirmationMsg:
+    // on every device of the sender
+    if h.sessions.Send(sender.ID, confirmationMsg) > 0 {
         log.Printf("Request confirmation sent to %s", sender.Username)
-    default:
-        log.Printf("Failed to send confirmation: channel full")
+    }
+}
+
+// handleFriendResponse accepts or declines a friend request sent to the
+// sender. Both users get a friend_response, FromUser being the other one, and
+// their friend lists once accepted
+func (h *MessageHandler) handleFriendResponse(ctx context.Context, sender *Client, payload protocol.FriendResponsePayload) error {
+    fromUser, toUser, err := h.db.GetFriendRequestUsers(ctx, payload.RequestID)
+    if err != nil || toUser.ID != sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Unknown friend request")
     }
 
+    if payload.Accept {
+        err = h.db.AcceptFriendRequest(ctx, fromUser.ID, toUser.ID)
+    } else {
+        err = h.db.RejectFriendRequest(ctx, fromUser.ID, toUser.ID)
+    }
+    if isQuotaError(err) {
+        return quotaError(err, "failed to accept friend request")
+    } else if err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "This friend request was already answered")
+    }
+    log.Printf("%s answered the friend request of %s (accepted: %v)", toUser.Username, fromUser.Username, payload.Accept)
+
+    h.sessions.Send(fromUser.ID, protocol.NewMessage(protocol.TypeFriendResponse, protocol.FriendResponsePayload{
+        RequestID: payload.RequestID,
+        FromUser:  toUser.Username,
+        Accept:    payload.Accept,
+    }))
+    h.sessions.Send(toUser.ID, protocol.NewMessage(protocol.TypeFriendResponse, protocol.FriendResponsePayload{
+        RequestID: payload.RequestID,
+        FromUser:  fromUser.Username,
+        Accept:    payload.Accept,
+    }))
+
+    if payload.Accept {
+        h.sendFriendList(ctx, fromUser.ID)
+        h.sendFriendList(ctx, toUser.ID)
+    }
+    return nil
+}
+
+// sendFriendList sends the friends of a user to their sessions
+func (h *MessageHandler) sendFriendList(ctx context.Context, userID string) {
+    friends, err := h.db.GetFriends(ctx, userID)
+    if err != nil {
+        log.Printf("Failed to get the friends of %s: %v", userID, err)
+        return
+    }
+
+    infos := make([]protocol.UserInfo, 0, len(friends))
+    for _, friend := range friends {
+        infos = append(infos, protocol.UserInfo{
+            ID:            friend.ID,
+            Username:      friend.Username,
+            Status:        friend.Status,
+            Text:          friend.StatusText,
+            TextExpiresAt: unixTime(friend.StatusExpiresAt),
+        })
+    }
+    h.sessions.Send(userID, protocol.NewMessage(protocol.TypeFriendList, protocol.FriendListPayload{
+        Friends: infos,
+    }))
+}
+
+// handleFriendRemove ends a friendship, or blocks the user when block is set.
+// Both users get a friend_remove so they can update their lists, the blocked
+// user is not told about the block
+func (h *MessageHandler) handleFriendRemove(ctx context.Context, sender *Client, payload protocol.FriendRemovePayload, block bool) error {
+    if payload.FriendID == "" || payload.FriendID == sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid friend")
+    }
+
+    if block {
+        if err := h.db.BlockUser(ctx, sender.ID, payload.FriendID); err != nil {
+            return err
+        }
+    } else if err := h.db.RemoveFriend(ctx, sender.ID, payload.FriendID); err != nil {
+        return protocol.NewError(protocol.ErrCodeUserNotFound, "This user is not your friend")
+    }
+
+    confirmType := protocol.TypeFriendRemove
+    if block {
+        confirmType = protocol.TypeFriendBlock
+    }
+    confirmation := protocol.NewMessage(confirmType, protocol.FriendRemovePayload{
+        FriendID: payload.FriendID,
+        Blocked:  block,
+    })
+    h.sessions.Send(sender.ID, confirmation)
+
+    notice := protocol.NewMessage(protocol.TypeFriendRemove, protocol.FriendRemovePayload{
+        FriendID: sender.ID,
+    })
+    h.sessions.Send(payload.FriendID, notice)
+
     return nil
 }
 
-func (h *MessageHandler) handleLoadMessages(sender *Client, msg protocol.Message) error {
+// historyPage returns the messages of a conversation sent before beforeID,
+// newest first, the archived ones included. Without otherID or groupID the
+// conversation is the global chat
+func (h *MessageHandler) historyPage(ctx context.Context, userID, otherID, groupID, beforeID string, limit int) ([]models.Message, error) {
+    var messages []models.Message
+    var err error
+    switch {
+    case groupID != "" || otherID != "":
+        messages, err = h.db.GetConversationMessages(ctx, userID, otherID, groupID, beforeID, limit)
+    case beforeID == "":
+        messages, err = h.db.GetMessages(ctx, userID, limit)
+    default:
+        messages, err = h.db.GetMessagesBeforeID(ctx, userID, beforeID, limit)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to load messages: %v", err)
+    }
+    return withArchived(ctx, h.db, messages, userID, otherID, groupID, beforeID, limit), nil
+}
+
+func (h *MessageHandler) handleLoadMessages(ctx context.Context, sender *Client, msg protocol.Message) error {
     var payload protocol.LoadMessagesPayload
     if err := h.decodePayload(msg.Payload, &payload); err != nil {
         return fmt.Errorf("invalid load messages payload: %v", err)
     }
 
-    messages, err := h.db.GetMessagesBeforeID(sender.ID, payload.BeforeID, payload.Limit)
+    if payload.Limit <= 0 || payload.Limit > maxHistoryPage {
+        payload.Limit = maxHistoryPage
+    }
+
+    if payload.GroupID != "" {
+        isMember, err := h.db.IsGroupMember(ctx, sender.ID, payload.GroupID)
+        if err != nil {
+            return fmt.Errorf("failed to check group membership: %v", err)
+        }
+        if !isMember {
+            return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+        }
+    }
+    messages, err := h.historyPage(ctx, sender.ID, payload.RecipientID, payload.GroupID, payload.BeforeID, payload.Limit)
     if err != nil {
-        return fmt.Errorf("failed to load messages: %v", err)
+        return err
+    }
+    if err := h.db.AttachVoice(ctx, messages); err != nil {
+        return err
+    }
+    if err := h.db.AttachPreviews(ctx, messages); err != nil {
+        return err
     }
 
     response := protocol.NewMessage(protocol.TypeMessageHistory, map[string]interface{}{
-        "messages": messages,
+        "messages":     messages,
+        "before_id":    payload.BeforeID,
+        "recipient_id": payload.RecipientID,
+        "group_id":     payload.GroupID,
     })
 
     select {
@@ -146,18 +466,24 @@ func (h *MessageHandler) handleLoadMessages(sender *Client, msg protocol.Message
     }
 }
 
-func (h *MessageHandler) handleGlobalMessage(sender *Client, msg protocol.Message) error {
+func (h *MessageHandler) handleGlobalMessage(ctx context.Context, sender *Client, msg protocol.Message) error {
     var payload struct {
-        Content string `json:"content"`
+        Content      string `json:"content"`
+        ClientID     string `json:"client_id"`
+        ThreadRootID string `json:"thread_root_id"`
     }
 
     if err := h.decodePayload(msg.Payload, &payload); err != nil {
         return fmt.Errorf("failed to decode global message payload: %v", err)
     }
 
+    payload.Content = sanitizeText(payload.Content)
     if payload.Content == "" {
         return fmt.Errorf("empty message content")
     }
+    if wait, ok := h.allowGlobalMessage(sender.ID); !ok {
+        return protocol.NewRateLimitError(fmt.Sprintf("Too many messages in the global chat, %d per minute at most", h.globalMessagesPerMinute), wait)
+    }
 
     // Save to database
     dbMsg := &models.Message{
@@ -166,9 +492,15 @@ func (h *MessageHandler) handleGlobalMessage(sender *Client, msg protocol.Messag
         SenderName: sender.Username,
         SentAt:     time.Now(),
         Status:     models.MessageStatusSent,
+        ClientID:   payload.ClientID,
+    }
+    if err := h.setThreadRoot(ctx, dbMsg, payload.ThreadRootID); err != nil {
+        return err
     }
 
-    if err := h.db.SaveMessage(dbMsg); err != nil {
+    if err := h.db.SaveMessage(ctx, dbMsg); err == database.ErrDuplicateMessage {
+        return h.ackDuplicate(sender, protocol.TypeGlobalMessage, dbMsg)
+    } else if err != nil {
         return fmt.Errorf("failed to save message: %v", err)
     }
 
@@ -180,23 +512,32 @@ func (h *MessageHandler) handleGlobalMessage(sender *Client, msg protocol.Messag
     }
 
     h.broadcast <- broadcastMsg
+    h.notifyMentions(ctx, sender, dbMsg)
+    h.previewLink(dbMsg)
     return nil
 }
 
-func (h *MessageHandler) handleDirectMessage(sender *Client, msg protocol.Message) error {
+func (h *MessageHandler) handleDirectMessage(ctx context.Context, sender *Client, msg protocol.Message) error {
     var payload struct {
-        Content     string `json:"content"`
-        RecipientID string `json:"recipient_id"`
+        Content      string `json:"content"`
+        RecipientID  string `json:"recipient_id"`
+        ClientID     string `json:"client_id"`
+        ThreadRootID string `json:"thread_root_id"`
     }
 
     if err := h.decodePayload(msg.Payload, &payload); err != nil {
         return fmt.Errorf("failed to decode direct message payload: %v", err)
     }
 
+    payload.Content = sanitizeText(payload.Content)
     if payload.Content == "" || payload.RecipientID == "" {
         return fmt.Errorf("invalid message content or recipient")
     }
 
+    if err := h.checkRecipient(ctx, sender.ID, payload.RecipientID); err != nil {
+        return err
+    }
+
     // Save to database
     dbMsg := &models.Message{
         Content:     payload.Content,
@@ -205,19 +546,23 @@ func (h *MessageHandler) handleDirectMessage(sender *Client, msg protocol.Messag
         RecipientID: &payload.RecipientID,
         SentAt:      time.Now(),
         Status:      models.MessageStatusSent,
+        ClientID:    payload.ClientID,
+    }
+    if err := h.setThreadRoot(ctx, dbMsg, payload.ThreadRootID); err != nil {
+        return err
     }
 
-    if err := h.db.SaveMessage(dbMsg); err != nil {
+    if err := h.db.SaveMessage(ctx, dbMsg); err == database.ErrDuplicateMessage {
+        return h.ackDuplicate(sender, protocol.TypeDirectMessage, dbMsg)
+    } else if err != nil {
         return fmt.Errorf("failed to save message: %v", err)
     }
 
-    // Send to recipient
-    h.mu.RLock()
-    recipient, exists := h.clients[payload.RecipientID]
-    h.mu.RUnlock()
-
-    if !exists {
-        return fmt.Errorf("recipient not found")
+    if isNote(dbMsg) {
+        h.markNoteRead(ctx, dbMsg)
+        h.sessions.Send(sender.ID, protocol.NewMessage(protocol.TypeDirectMessage, h.createMessagePayload(dbMsg)))
+        h.previewLink(dbMsg)
+        return nil
     }
 
     directMsg := protocol.Message{
@@ -226,41 +571,75 @@ func (h *MessageHandler) handleDirectMessage(sender *Client, msg protocol.Messag
         Timestamp: time.Now().Unix(),
     }
 
-    // Send to both recipient and sender
-    select {
-    case recipient.Send <- directMsg:
-        log.Printf("Message sent to recipient %s", recipient.Username)
-    default:
-        log.Printf("Failed to send to recipient %s: channel full", recipient.Username)
+    // Send to the devices of the recipient if online, the message is stored
+    // either way
+    if sent := h.sessions.Send(payload.RecipientID, directMsg); sent > 0 {
+        log.Printf("Message sent to %d session(s) of the recipient", sent)
+        // the copy of the sender shows it delivered, a new payload: the
+        // recipient's is queued already
+        h.markDelivered(ctx, dbMsg)
+        directMsg.Payload = h.createMessagePayload(dbMsg)
     }
 
-    select {
-    case sender.Send <- directMsg:
+    // the confirmation goes to every device of the sender, the others show
+    // the conversation too
+    if sent := h.sessions.Send(sender.ID, h.markRecipientDND(ctx, directMsg, payload.RecipientID)); sent > 0 {
         log.Printf("Message confirmation sent to sender %s", sender.Username)
-    default:
-        log.Printf("Failed to send confirmation to sender %s: channel full", sender.Username)
     }
 
+    h.previewLink(dbMsg)
     return nil
 }
 
-func (h *MessageHandler) handleGroupMessage(sender *Client, msg protocol.Message) error {
+// checkRecipient tells if a user may send a direct message to another
+func (h *MessageHandler) checkRecipient(ctx context.Context, senderID, recipientID string) error {
+    if recipientID == protocol.SystemUserID {
+        return errSystemRecipient
+    }
+    if blocked, err := h.db.IsBlocked(ctx, senderID, recipientID); err != nil {
+        return err
+    } else if blocked {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "You can't send messages to this user")
+    }
+    return nil
+}
+
+// checkGroupPoster tells if a user may send a message in clear to a group
+func (h *MessageHandler) checkGroupPoster(ctx context.Context, senderID, groupID string) error {
+    isMember, err := h.db.IsGroupMember(ctx, senderID, groupID)
+    if err != nil {
+        return fmt.Errorf("failed to check group membership: %v", err)
+    }
+    if !isMember {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "You are not a member of this group")
+    }
+    if encrypted, err := h.db.IsGroupEncrypted(ctx, groupID); err != nil {
+        return fmt.Errorf("failed to get group: %v", err)
+    } else if encrypted {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "This group is encrypted, its messages can't be sent in clear")
+    }
+    return requirePoster(ctx, h.db, senderID, groupID)
+}
+
+func (h *MessageHandler) handleGroupMessage(ctx context.Context, sender *Client, msg protocol.Message) error {
     var payload struct {
-        Content string `json:"content"`
-        GroupID string `json:"group_id"`
+        Content      string `json:"content"`
+        GroupID      string `json:"group_id"`
+        ClientID     string `json:"client_id"`
+        ThreadRootID string `json:"thread_root_id"`
     }
 
     if err := h.decodePayload(msg.Payload, &payload); err != nil {
         return fmt.Errorf("failed to decode group message payload: %v", err)
     }
 
-    // Check if user is member of the group
-    isMember, err := h.db.IsGroupMember(sender.ID, payload.GroupID)
-    if err != nil {
-        return fmt.Errorf("failed to check group membership: %v", err)
+    payload.Content = sanitizeText(payload.Content)
+    if payload.Content == "" {
+        return fmt.Errorf("empty message content")
     }
-    if !isMember {
-        return fmt.Errorf("user is not a member of this group")
+
+    if err := h.checkGroupPoster(ctx, sender.ID, payload.GroupID); err != nil {
+        return err
     }
 
     // Save message
@@ -271,14 +650,20 @@ func (h *MessageHandler) handleGroupMessage(sender *Client, msg protocol.Message
         GroupID:    &payload.GroupID,
         SentAt:     time.Now(),
         Status:     models.MessageStatusSent,
+        ClientID:   payload.ClientID,
+    }
+    if err := h.setThreadRoot(ctx, dbMsg, payload.ThreadRootID); err != nil {
+        return err
     }
 
-    if err := h.db.SaveMessage(dbMsg); err != nil {
+    if err := h.db.SaveMessage(ctx, dbMsg); err == database.ErrDuplicateMessage {
+        return h.ackDuplicate(sender, protocol.TypeGroupMessage, dbMsg)
+    } else if err != nil {
         return fmt.Errorf("failed to save message: %v", err)
     }
 
     // Get group members and send message
-    members, err := h.db.GetGroupMembers(payload.GroupID)
+    members, err := h.db.GetGroupMembers(ctx, payload.GroupID)
     if err != nil {
         return fmt.Errorf("failed to get group members: %v", err)
     }
@@ -289,18 +674,580 @@ func (h *MessageHandler) handleGroupMessage(sender *Client, msg protocol.Message
         Timestamp: time.Now().Unix(),
     }
 
-    h.mu.RLock()
     for _, memberID := range members {
-        if client, ok := h.clients[memberID]; ok {
-            select {
-            case client.Send <- groupMsg:
-                log.Printf("Group message sent to member %s", client.Username)
-            default:
-                log.Printf("Failed to send to member %s: channel full", client.Username)
-            }
+        h.sessions.Send(memberID, groupMsg)
+    }
+
+    h.notifyMentions(ctx, sender, dbMsg)
+    h.previewLink(dbMsg)
+    return nil
+}
+
+// handleGroupDirectory sends the list of public groups
+func (h *MessageHandler) handleGroupDirectory(ctx context.Context, sender *Client) error {
+    groups, err := h.db.GetPublicGroups(ctx, sender.ID, groupDirectorySize)
+    if err != nil {
+        return err
+    }
+
+    payload := protocol.GroupDirectoryPayload{
+        Groups: make([]protocol.GroupDirectoryEntry, 0, len(groups)),
+    }
+    for _, group := range groups {
+        payload.Groups = append(payload.Groups, protocol.GroupDirectoryEntry{
+            ID:          group.ID,
+            Name:        group.Name,
+            Description: group.Description,
+            MemberCount: group.MemberCount,
+            IsMember:    group.IsMember,
+            Announcement: group.Announcement,
+        })
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeGroupDirectory, payload):
+    default:
+        log.Printf("Failed to send group directory to %s: channel full", sender.Username)
+    }
+    return nil
+}
+
+// handleGroupJoin adds the sender to a public group, private groups need an
+// invite from an admin
+func (h *MessageHandler) handleGroupJoin(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupJoinPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group join payload: %v", err)
+    }
+
+    group, err := h.db.GetGroup(ctx, payload.GroupID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeGroupNotFound, "Group not found")
+    }
+    if !group.Public {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "This group is private, ask an admin for an invite")
+    }
+
+    if err := h.db.AddUserToGroup(ctx, sender.ID, group.ID); err != nil {
+        return quotaError(err, "failed to join group")
+    }
+
+    if group.Members, err = h.db.GetGroupMembers(ctx, group.ID); err != nil {
+        return fmt.Errorf("failed to get group members: %v", err)
+    }
+    groupInfo := groupPayload(group)
+    confirmation := protocol.NewMessage(protocol.TypeGroupJoin, protocol.GroupJoinPayload{
+        GroupID: group.ID,
+        UserID:  sender.ID,
+        Group:   &groupInfo,
+    })
+    select {
+    case sender.Send <- confirmation:
+    default:
+        log.Printf("Failed to send group join confirmation to %s: channel full", sender.Username)
+    }
+    h.sendSystemMessage(ctx, group.ID, protocol.SystemJoined, sender.Username, "")
+
+    return h.sendGroupMembers(ctx, group.ID)
+}
+
+// handleGroupMembers sends the member list of a group to one of its members
+func (h *MessageHandler) handleGroupMembers(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupMembersPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group members payload: %v", err)
+    }
+
+    isMember, err := h.db.IsGroupMember(ctx, sender.ID, payload.GroupID)
+    if err != nil {
+        return fmt.Errorf("failed to check group membership: %v", err)
+    }
+    if !isMember {
+        return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+    }
+
+    members, err := h.groupMembers(ctx, payload.GroupID)
+    if err != nil {
+        return err
+    }
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeGroupMembers, members):
+    default:
+        log.Printf("Failed to send group members to %s: channel full", sender.Username)
+    }
+    return nil
+}
+
+// handleGroupInvite adds a user to a group, the new member gets the group
+// and the other members the updated member list
+func (h *MessageHandler) handleGroupInvite(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupInvitePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group invite payload: %v", err)
+    }
+
+    if err := h.requireGroupAdmin(ctx, sender.ID, payload.GroupID); err != nil {
+        return err
+    }
+
+    user, err := h.db.GetUserByUsername(ctx, payload.ToUser)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeUserNotFound, fmt.Sprintf("User not found: %s", payload.ToUser))
+    }
+    if isMember, err := h.db.IsGroupMember(ctx, user.ID, payload.GroupID); err != nil {
+        return fmt.Errorf("failed to check group membership: %v", err)
+    } else if isMember {
+        return protocol.NewError(protocol.ErrCodeAlreadyExists, fmt.Sprintf("%s is already a member", user.Username))
+    }
+
+    if err := h.db.AddUserToGroup(ctx, user.ID, payload.GroupID); err != nil {
+        return quotaError(err, "failed to add user to group")
+    }
+
+    group, err := h.db.GetGroup(ctx, payload.GroupID)
+    if err != nil {
+        return fmt.Errorf("failed to get group: %v", err)
+    }
+    groupInfo := groupPayload(group)
+
+    invite := protocol.NewMessage(protocol.TypeGroupInvite, protocol.GroupInvitePayload{
+        GroupID:  group.ID,
+        FromUser: sender.Username,
+        ToUser:   user.Username,
+        Group:    &groupInfo,
+    })
+    h.sessions.Send(user.ID, invite)
+    h.notify(ctx, user.ID, protocol.NoticeGroupInvite, sender.Username, group.Name, group.ID)
+    h.sendSystemMessage(ctx, group.ID, protocol.SystemAdded, sender.Username, user.Username)
+
+    return h.sendGroupMembers(ctx, group.ID)
+}
+
+// handleGroupKick removes a member from a group, the group creator can't be removed
+func (h *MessageHandler) handleGroupKick(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupJoinPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group kick payload: %v", err)
+    }
+
+    if err := h.requireGroupAdmin(ctx, sender.ID, payload.GroupID); err != nil {
+        return err
+    }
+    if payload.UserID == sender.ID {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "You can't kick yourself, leave the group instead")
+    }
+
+    if err := h.db.RemoveUserFromGroup(ctx, payload.UserID, payload.GroupID); err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "This member can't be removed from the group")
+    }
+
+    h.sessions.Send(payload.UserID, protocol.NewMessage(protocol.TypeGroupKick, payload))
+    target := h.recordGroupKick(ctx, sender.Username, payload.UserID, payload.GroupID)
+    h.sendSystemMessage(ctx, payload.GroupID, protocol.SystemRemoved, sender.Username, target)
+
+    return h.sendGroupMembers(ctx, payload.GroupID)
+}
+
+// handleGroupLeave removes the sender from a group, the creator stays. The
+// sessions of the sender drop the group and the members see them leave
+func (h *MessageHandler) handleGroupLeave(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupJoinPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group leave payload: %v", err)
+    }
+
+    if isMember, err := h.db.IsGroupMember(ctx, sender.ID, payload.GroupID); err != nil {
+        return fmt.Errorf("failed to check group membership: %v", err)
+    } else if !isMember {
+        return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+    }
+    if err := h.db.RemoveUserFromGroup(ctx, sender.ID, payload.GroupID); err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "The creator of a group can't leave it")
+    }
+
+    h.sessions.Send(sender.ID, protocol.NewMessage(protocol.TypeGroupLeave, protocol.GroupJoinPayload{
+        GroupID: payload.GroupID,
+        UserID:  sender.ID,
+    }))
+    h.sendSystemMessage(ctx, payload.GroupID, protocol.SystemLeft, sender.Username, "")
+
+    return h.sendGroupMembers(ctx, payload.GroupID)
+}
+
+// requireGroupAdmin fails unless the user is an admin of the group
+func (h *MessageHandler) requireGroupAdmin(ctx context.Context, userID, groupID string) error {
+    role, err := h.db.GetGroupRole(ctx, userID, groupID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+    }
+    if role != protocol.GroupRoleAdmin {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Only group admins can manage members")
+    }
+    return nil
+}
+
+// sendGroupMembers sends the member list to the online members of a group
+func (h *MessageHandler) sendGroupMembers(ctx context.Context, groupID string) error {
+    payload, err := h.groupMembers(ctx, groupID)
+    if err != nil {
+        return err
+    }
+
+    msg := protocol.NewMessage(protocol.TypeGroupMembers, payload)
+    for _, member := range payload.Members {
+        h.sessions.Send(member.ID, msg)
+    }
+    return nil
+}
+
+// sendSystemMessage shows an event of a group to its online members
+func (h *MessageHandler) sendSystemMessage(ctx context.Context, groupID, kind, actor, target string) {
+    h.sendSystemMessageAt(ctx, groupID, kind, actor, target, h.clock.Now())
+}
+
+// sendSystemMessageAt sends a system message dated at, for the events also
+// stored with their time
+func (h *MessageHandler) sendSystemMessageAt(ctx context.Context, groupID, kind, actor, target string, at time.Time) {
+    members, err := h.db.GetGroupMembers(ctx, groupID)
+    if err != nil {
+        log.Printf("Failed to get the members of group %s: %v", groupID, err)
+        return
+    }
+
+    msg := protocol.NewMessage(protocol.TypeSystemMessage, protocol.SystemMessagePayload{
+        GroupID: groupID,
+        Kind:    kind,
+        Actor:   actor,
+        Target:  target,
+        At:      at.Unix(),
+    })
+    for _, memberID := range members {
+        h.sessions.Send(memberID, msg)
+    }
+}
+
+func (h *MessageHandler) groupMembers(ctx context.Context, groupID string) (protocol.GroupMembersPayload, error) {
+    members, err := h.db.GetGroupMemberDetails(ctx, groupID)
+    if err != nil {
+        return protocol.GroupMembersPayload{}, err
+    }
+
+    payload := protocol.GroupMembersPayload{
+        GroupID: groupID,
+        Members: make([]protocol.GroupMemberInfo, 0, len(members)),
+    }
+    for _, member := range members {
+        payload.Members = append(payload.Members, protocol.GroupMemberInfo{
+            ID:       member.UserID,
+            Username: member.Username,
+            Role:     member.Role,
+            Status:   member.Status,
+        })
+    }
+    return payload, nil
+}
+
+func groupPayload(group *models.Group) protocol.GroupPayload {
+    return protocol.GroupPayload{
+        ID:          group.ID,
+        Name:        group.Name,
+        Description: group.Description,
+        CreatedBy:   group.CreatedBy,
+        CreatedAt:   group.CreatedAt.Unix(),
+        MemberIDs:   group.Members,
+        Public:      group.Public,
+        Encrypted:   group.Encrypted,
+        Announcement: group.Announcement,
+        Posters:     group.Posters,
+        Topic:       group.Topic,
+    }
+}
+
+// requirePoster refuses the messages of the members who are not admins of an
+// announcement group
+func requirePoster(ctx context.Context, db database.Store, userID, groupID string) error {
+    announcement, err := db.IsAnnouncementGroup(ctx, groupID)
+    if err != nil {
+        return fmt.Errorf("failed to get group: %v", err)
+    }
+    if !announcement {
+        return nil
+    }
+    if role, err := db.GetGroupRole(ctx, userID, groupID); err != nil {
+        return err
+    } else if role != protocol.GroupRoleAdmin {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Only the admins can post in this announcement group")
+    }
+    return nil
+}
+
+func (h *MessageHandler) handleMessageEdit(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.MessageEditPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid message edit payload: %v", err)
+    }
+
+    payload.Content = sanitizeText(payload.Content)
+    if payload.MessageID == "" || payload.Content == "" {
+        return fmt.Errorf("invalid message id or content")
+    }
+
+    original, err := h.db.GetMessage(ctx, payload.MessageID)
+    if err != nil {
+        return err
+    }
+
+    // only the author can edit a message
+    if original.SenderID != sender.ID {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "You can only edit your own messages")
+    }
+
+    if original.Content == payload.Content {
+        return nil
+    }
+
+    edited, err := h.db.EditMessage(ctx, payload.MessageID, sender.ID, payload.Content)
+    if err != nil {
+        return err
+    }
+    if err := h.previewEdit(ctx, original, edited); err != nil {
+        return err
+    }
+
+    editMsg := protocol.Message{
+        Type:      protocol.TypeMessageEdit,
+        Payload:   h.createMessagePayload(edited),
+        Timestamp: time.Now().Unix(),
+    }
+
+    return h.sendToConversation(ctx, edited, editMsg)
+}
+
+func (h *MessageHandler) handleMessageRevisions(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.MessageRevisionsPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid message revisions payload: %v", err)
+    }
+
+    // the history of an archived message is in its archive file
+    var archived *database.ArchiveRecord
+    message, err := h.db.GetMessage(ctx, payload.MessageID)
+    if err != nil {
+        if archived, _ = getArchivedRecord(ctx, h.db, payload.MessageID); archived == nil {
+            return err
         }
+        message = &archived.Message
+    }
+
+    // the author, the server admins and the admins of the group can see the
+    // edit history
+    allowed := message.SenderID == sender.ID || h.isAdmin(sender.Username)
+    if !allowed && message.GroupID != nil {
+        role, err := h.db.GetGroupRole(ctx, sender.ID, *message.GroupID)
+        allowed = err == nil && role == models.GroupRoleAdmin
+    }
+    if !allowed {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Not allowed to view this message's history")
+    }
+
+    var revisions []models.MessageRevision
+    if archived != nil {
+        revisions = archived.Revisions
+    } else if revisions, err = h.db.GetMessageRevisions(ctx, message.ID); err != nil {
+        return err
+    }
+
+    response := protocol.MessageRevisionsPayload{
+        MessageID: message.ID,
+        Revisions: make([]protocol.MessageRevision, 0, len(revisions)),
+    }
+    for _, rev := range revisions {
+        response.Revisions = append(response.Revisions, protocol.MessageRevision{
+            Content:  rev.Content,
+            EditedBy: rev.EditedBy,
+            EditedAt: rev.EditedAt.Unix(),
+        })
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeMessageRevisions, response):
+        return nil
+    default:
+        return fmt.Errorf("failed to send message revisions: channel full")
+    }
+}
+
+// handleStatusUpdate saves the status chosen by the user and tells the other users
+func (h *MessageHandler) handleStatusUpdate(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.StatusUpdatePayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid status update payload: %v", err)
+    }
+
+    switch payload.Status {
+    case protocol.StatusOnline, protocol.StatusAway, protocol.StatusDND:
+    default:
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Invalid status: %s", payload.Status))
+    }
+
+    payload.Text = strings.TrimSpace(sanitizeText(payload.Text))
+    if utf8.RuneCountInString(payload.Text) > protocol.MaxStatusText {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Status text is limited to %d characters", protocol.MaxStatusText))
+    }
+
+    var expiresAt *time.Time
+    if payload.Text == "" {
+        payload.ExpiresAt = 0
+    } else if payload.ExpiresAt != 0 {
+        t := time.Unix(payload.ExpiresAt, 0)
+        if !t.After(time.Now()) || time.Until(t) > protocol.MaxStatusDuration {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "A status text can be set for up to 7 days")
+        }
+        expiresAt = &t
+    }
+
+    wasDND := h.inDND(ctx, sender.ID)
+    if err := h.db.QueueUserStatus(ctx, sender.ID, payload.Status); err != nil {
+        return fmt.Errorf("failed to update status: %v", err)
+    }
+    if err := h.db.UpdateUserStatusText(ctx, sender.ID, payload.Text, expiresAt); err != nil {
+        return err
+    }
+
+    payload.UserID = sender.ID
+    h.broadcast <- protocol.NewMessage(protocol.TypeStatusUpdate, payload)
+
+    // the notifications held while in do not disturb
+    if wasDND && payload.Status != protocol.StatusDND {
+        return h.handleNotificationList(ctx, sender)
+    }
+    return nil
+}
+
+// unixTime writes an optional time as a Unix time, 0 when unset
+func unixTime(t *time.Time) int64 {
+    if t == nil {
+        return 0
+    }
+    return t.Unix()
+}
+
+// handleTyping relays a typing notification to the other users of the conversation
+func (h *MessageHandler) handleTyping(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.TypingPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid typing payload: %v", err)
+    }
+
+    // the sender is set by the server, never trusted from the client
+    payload.UserID = sender.ID
+    payload.Username = sender.Username
+
+    conversation := &models.Message{SenderID: sender.ID}
+    switch {
+    case payload.GroupID != "":
+        isMember, err := h.db.IsGroupMember(ctx, sender.ID, payload.GroupID)
+        if err != nil {
+            return fmt.Errorf("failed to check group membership: %v", err)
+        }
+        if !isMember {
+            return protocol.NewError(protocol.ErrCodeAccessDenied, "You are not a member of this group")
+        }
+        conversation.GroupID = &payload.GroupID
+    case payload.RecipientID != "":
+        conversation.RecipientID = &payload.RecipientID
+    }
+
+    return h.sendToConversation(ctx, conversation, protocol.NewMessage(protocol.TypeTyping, payload))
+}
+
+// handleUserLookup finds the ID of a user from the username
+func (h *MessageHandler) handleUserLookup(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.UserLookupPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid user lookup payload: %v", err)
+    }
+
+    user, err := h.db.GetUserByUsername(ctx, payload.Username)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeUserNotFound, fmt.Sprintf("User %s not found", payload.Username))
+    }
+
+    response := protocol.NewMessage(protocol.TypeUserLookup, protocol.UserLookupPayload{
+        Username: user.Username,
+        UserID:   user.ID,
+        Status:   user.Status,
+    })
+    select {
+    case sender.Send <- response:
+    default:
+        log.Printf("Failed to send user lookup to %s: channel full", sender.Username)
+    }
+    return nil
+}
+
+// handleUserSearch lists the users whose name starts with the query, the
+// online users without one
+func (h *MessageHandler) handleUserSearch(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.UserSearchPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid user search payload: %v", err)
+    }
+    query := strings.TrimSpace(payload.Query)
+    if len(query) > 50 {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Search too long")
+    }
+
+    users, err := h.db.SearchUsers(ctx, sender.ID, query, userSearchSize)
+    if err != nil {
+        return err
+    }
+    response := protocol.UserSearchPayload{
+        Query: query,
+        Users: make([]protocol.UserSearchEntry, 0, len(users)),
+    }
+    for _, user := range users {
+        response.Users = append(response.Users, protocol.UserSearchEntry{
+            ID:         user.ID,
+            Username:   user.Username,
+            Status:     user.Status,
+            StatusText: user.StatusText,
+            IsFriend:   user.IsFriend,
+        })
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeUserSearch, response):
+    default:
+        log.Printf("Failed to send user search to %s: channel full", sender.Username)
+    }
+    return nil
+}
+
+// sendToConversation delivers msg to everyone who can see the given message:
+// all clients for global messages, both participants for direct messages and
+// the online members for group messages
+func (h *MessageHandler) sendToConversation(ctx context.Context, message *models.Message, msg protocol.Message) error {
+    var recipients []string
+    switch {
+    case message.GroupID != nil:
+        members, err := h.db.GetGroupMembers(ctx, *message.GroupID)
+        if err != nil {
+            return fmt.Errorf("failed to get group members: %v", err)
+        }
+        recipients = members
+    case isNote(message):
+        recipients = []string{message.SenderID}
+    case message.RecipientID != nil:
+        recipients = []string{message.SenderID, *message.RecipientID}
+    default:
+        h.broadcast <- msg
+        return nil
+    }
+
+    for _, userID := range recipients {
+        h.sessions.Send(userID, msg)
     }
-    h.mu.RUnlock()
 
     return nil
 }
@@ -333,19 +1280,51 @@ func (h *MessageHandler) createMessagePayload(msg *models.Message) map[string]in
     if msg.ReadAt != nil {
         payload["read_at"] = msg.ReadAt.Unix()
     }
+    if msg.RecipientID != nil && msg.Status != "" {
+        payload["status"] = msg.Status
+    }
+    if msg.EditedAt != nil {
+        payload["edited_at"] = msg.EditedAt.Unix()
+    }
+    if msg.ClientID != "" {
+        payload["client_id"] = msg.ClientID
+    }
+    if msg.Voice != nil {
+        payload["voice"] = msg.Voice
+    }
+    if msg.ThreadRootID != nil {
+        payload["thread_root_id"] = *msg.ThreadRootID
+    }
+    if msg.ReplyCount > 0 {
+        payload["reply_count"] = msg.ReplyCount
+    }
+    if msg.Preview != nil {
+        payload["preview"] = msg.Preview
+    }
 
     return payload
 }
 
-func (h *MessageHandler) decodePayload(payload interface{}, target interface{}) error {
-    data, err := json.Marshal(payload)
-    if err != nil {
-        return fmt.Errorf("failed to marshal payload: %v", err)
+// ackDuplicate answers a message sent again with the same client ID: it was
+// stored and delivered the first time, only the sender needs the stored copy
+func (h *MessageHandler) ackDuplicate(sender *Client, msgType protocol.MessageType, msg *models.Message) error {
+    ack := protocol.Message{
+        Type:      msgType,
+        Payload:   h.createMessagePayload(msg),
+        Timestamp: time.Now().Unix(),
     }
-    
-    if err := json.Unmarshal(data, target); err != nil {
-        return fmt.Errorf("failed to unmarshal payload: %v", err)
+
+    select {
+    case sender.Send <- ack:
+        return nil
+    default:
+        return fmt.Errorf("failed to acknowledge message %s: channel full", msg.ID)
+    }
+}
+
+func (h *MessageHandler) decodePayload(payload interface{}, target interface{}) error {
+    if err := protocol.DecodePayload(payload, target); err != nil {
+        return fmt.Errorf("failed to decode payload: %v", err)
     }
-    
     return nil
 }
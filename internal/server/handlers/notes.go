@@ -0,0 +1,25 @@
+// internal/server/handlers/notes.go
+package handlers
+
+import (
+	"context"
+	"log"
+	"textual/internal/server/models"
+)
+
+// isNote tells if a direct message is a note, sent by a user to themselves.
+// The notes reach the other devices of the user like any direct message
+func isNote(msg *models.Message) bool {
+    return msg.RecipientID != nil && *msg.RecipientID == msg.SenderID
+}
+
+// markNoteRead stores a note read, the notes never count as unread
+func (h *MessageHandler) markNoteRead(ctx context.Context, msg *models.Message) {
+    if err := h.db.MarkMessageAsRead(ctx, msg.ID, msg.SenderID); err != nil {
+        log.Printf("Failed to mark note read: %v", err)
+        return
+    }
+    now := h.clock.Now()
+    msg.Status = models.MessageStatusRead
+    msg.ReadAt = &now
+}
@@ -2,118 +2,264 @@
 package handlers
 
 import (
-	"sync"
-	"textual/internal/server/database"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"textual/internal/server/models"
 	"textual/pkg/protocol"
-	"time"
+	"unicode"
 )
 
-type NotificationType string
+// number of notifications sent to a client asking for its list
+const notificationPageSize = 100
 
-const (
-    NotifyNewMessage     NotificationType = "new_message"
-    NotifyFriendRequest  NotificationType = "friend_request"
-    NotifyGroupInvite    NotificationType = "group_invite"
-)
+// users notified at most for the mentions of one message
+const maxMentions = 10
 
-type Notification struct {
-    Type      NotificationType `json:"type"`
-    UserID    string          `json:"user_id"`
-    Data      interface{}     `json:"data"`
-    Timestamp time.Time       `json:"timestamp"`
-    Read      bool           `json:"read"`
+// notify stores a notification for a user and pushes it if the user is online.
+// relatedID is the conversation to open from it, see NotificationPayload
+func (h *MessageHandler) notify(ctx context.Context, userID, kind, actor, content, relatedID string) {
+    n := &models.Notification{
+        UserID:  userID,
+        Type:    kind,
+        Actor:   actor,
+        Content: content,
+    }
+    if relatedID != "" {
+        n.RelatedID = &relatedID
+    }
+    if err := h.db.CreateNotification(ctx, n); err != nil {
+        log.Printf("Failed to store %s notification for %s: %v", kind, userID, err)
+        return
+    }
+
+    if !h.sessions.Online(userID) {
+        return
+    }
+    // kept for when the user leaves do not disturb
+    if h.inDND(ctx, userID) {
+        return
+    }
+
+    h.sessions.Send(userID, protocol.NewMessage(protocol.TypeNotification, notificationPayload(n)))
 }
 
-type NotificationHandler struct {
-    db           *database.DB
-    eventHandler *EventHandler
-    notifications map[string][]Notification
-    mu           sync.RWMutex
+// inDND tells if a user set the do not disturb status
+func (h *MessageHandler) inDND(ctx context.Context, userID string) bool {
+    user, err := h.db.GetUser(ctx, userID)
+    return err == nil && user.Status == protocol.StatusDND
 }
 
-func NewNotificationHandler(db *database.DB, eh *EventHandler) *NotificationHandler {
-    return &NotificationHandler{
-        db:            db,
-        eventHandler:  eh,
-        notifications: make(map[string][]Notification),
+// markRecipientDND flags the sender's copy of a direct message sent to a user
+// in do not disturb: the message is stored and delivered, but notifies
+// nothing until the recipient is back
+func (h *MessageHandler) markRecipientDND(ctx context.Context, msg protocol.Message, recipientID string) protocol.Message {
+    payload, ok := msg.Payload.(map[string]interface{})
+    if !ok || !h.inDND(ctx, recipientID) {
+        return msg
+    }
+    marked := make(map[string]interface{}, len(payload)+1)
+    for key, value := range payload {
+        marked[key] = value
     }
+    marked["recipient_dnd"] = true
+    msg.Payload = marked
+    return msg
 }
 
-func (h *NotificationHandler) AddNotification(userID string, notifType NotificationType, data interface{}) {
-    h.mu.Lock()
-    defer h.mu.Unlock()
-
-    notification := Notification{
-        Type:      notifType,
-        UserID:    userID,
-        Data:      data,
-        Timestamp: time.Now(),
-        Read:      false,
+// notifyMentions notifies the users named with "@username" in a global or group
+// message, direct messages already notify their recipient
+func (h *MessageHandler) notifyMentions(ctx context.Context, sender *Client, msg *models.Message) {
+    if msg.RecipientID != nil {
+        return
     }
 
-    if _, ok := h.notifications[userID]; !ok {
-        h.notifications[userID] = make([]Notification, 0)
+    for _, username := range mentionedUsers(msg.Content) {
+        if strings.EqualFold(username, sender.Username) {
+            continue
+        }
+        user, err := h.db.GetUserByUsername(ctx, username)
+        if err != nil {
+            continue
+        }
+
+        relatedID, chatID := "", "global"
+        if msg.GroupID != nil {
+            // only the members can read the message
+            if isMember, err := h.db.IsGroupMember(ctx, user.ID, *msg.GroupID); err != nil || !isMember {
+                continue
+            }
+            relatedID, chatID = *msg.GroupID, *msg.GroupID
+        }
+        // the inbox lists the mentions of the muted conversations too
+        if err := h.db.AddMention(ctx, user.ID, msg.ID); err != nil {
+            log.Printf("Failed to record the mention of %s: %v", user.Username, err)
+        }
+        // the user only wants the badge, or nothing, from this conversation
+        if level, err := h.db.GetNotificationLevel(ctx, user.ID, chatID); err != nil || level == protocol.LevelBadge || level == protocol.LevelNone {
+            continue
+        }
+        h.notify(ctx, user.ID, protocol.NoticeMention, sender.Username, msg.Content, relatedID)
     }
+}
 
-    h.notifications[userID] = append(h.notifications[userID], notification)
+// mentionedUsers returns the distinct names following an "@" in the content,
+// "mail@example.com" is not a mention
+func mentionedUsers(content string) []string {
+    var names []string
+    seen := make(map[string]bool)
+    for _, word := range strings.FieldsFunc(content, func(r rune) bool {
+        return r != '@' && !isMentionRune(r)
+    }) {
+        if !strings.HasPrefix(word, "@") {
+            continue
+        }
+        // a mention can end a sentence
+        name := strings.TrimRight(strings.SplitN(word[1:], "@", 2)[0], ".")
+        if name == "" || seen[strings.ToLower(name)] {
+            continue
+        }
+        seen[strings.ToLower(name)] = true
+        names = append(names, name)
+        if len(names) == maxMentions {
+            break
+        }
+    }
+    return names
+}
 
-    // Notify the user
-    h.eventHandler.NotifyUser(userID, protocol.NewMessage(protocol.TypeStatusUpdate, map[string]interface{}{
-        "type":         "notification",
-        "notification": notification,
-    }))
+func isMentionRune(r rune) bool {
+    return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
 }
 
-func (h *NotificationHandler) GetUnreadCount(userID string) int {
-    h.mu.RLock()
-    defer h.mu.RUnlock()
+// handleNotificationList sends the latest notifications of the user
+func (h *MessageHandler) handleNotificationList(ctx context.Context, sender *Client) error {
+    notifications, err := h.db.GetNotifications(ctx, sender.ID, notificationPageSize)
+    if err != nil {
+        return err
+    }
 
-    count := 0
-    if notifications, ok := h.notifications[userID]; ok {
-        for _, n := range notifications {
-            if !n.Read {
-                count++
-            }
-        }
+    payload := protocol.NotificationListPayload{
+        Notifications: make([]protocol.NotificationPayload, 0, len(notifications)),
+    }
+    for i := range notifications {
+        payload.Notifications = append(payload.Notifications, notificationPayload(&notifications[i]))
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeNotificationList, payload):
+        return nil
+    default:
+        return fmt.Errorf("failed to send notifications: channel full")
     }
-    return count
 }
 
-func (h *NotificationHandler) MarkAsRead(userID string, notifType NotificationType) {
-    h.mu.Lock()
-    defer h.mu.Unlock()
+// handleMentionsList sends the latest messages mentioning the user
+func (h *MessageHandler) handleMentionsList(ctx context.Context, sender *Client) error {
+    mentions, err := h.db.GetMentions(ctx, sender.ID, notificationPageSize)
+    if err != nil {
+        return err
+    }
 
-    if notifications, ok := h.notifications[userID]; ok {
-        for i := range notifications {
-            if notifications[i].Type == notifType {
-                notifications[i].Read = true
+    payload := protocol.MentionsListPayload{
+        Mentions: make([]protocol.MentionPayload, 0, len(mentions)),
+    }
+    for _, mention := range mentions {
+        if mention.Archived {
+            msg, err := getMessage(ctx, h.db, mention.MessageID)
+            if err != nil || msg.Status == models.MessageStatusDeleted {
+                continue
             }
+            mention.Content = msg.Content
+        }
+        entry := protocol.MentionPayload{
+            MessageID: mention.MessageID,
+            GroupName: mention.GroupName,
+            Sender:    mention.SenderName,
+            Content:   mention.Content,
+            SentAt:    mention.SentAt.Unix(),
+        }
+        if mention.GroupID != nil {
+            entry.GroupID = *mention.GroupID
         }
+        payload.Mentions = append(payload.Mentions, entry)
     }
-}
 
-func (h *NotificationHandler) GetNotifications(userID string) []Notification {
-    h.mu.RLock()
-    defer h.mu.RUnlock()
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeMentionsList, payload):
+        return nil
+    default:
+        return fmt.Errorf("failed to send mentions: channel full")
+    }
+}
 
-    if notifications, ok := h.notifications[userID]; ok {
-        return notifications
+// handleNotificationRead marks notifications of the user as read
+func (h *MessageHandler) handleNotificationRead(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.NotificationReadPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid notification read payload: %v", err)
     }
-    return []Notification{}
+    if len(payload.IDs) > notificationPageSize {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Too many notifications")
+    }
+
+    return h.db.MarkNotificationsRead(ctx, sender.ID, payload.IDs)
 }
 
-func (h *NotificationHandler) CleanOldNotifications() {
-    h.mu.Lock()
-    defer h.mu.Unlock()
+// max number of levels changed by one message
+const maxNotificationPrefs = 100
 
-    cutoff := time.Now().Add(-7 * 24 * time.Hour) // keep notifications for 1 week
-    for userID, notifications := range h.notifications {
-        var recent []Notification
-        for _, n := range notifications {
-            if n.Timestamp.After(cutoff) {
-                recent = append(recent, n)
-            }
+// handleNotificationPrefs saves the notification levels sent by the client
+// and answers with all the levels of the user
+func (h *MessageHandler) handleNotificationPrefs(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.NotificationPrefsPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid notification prefs payload: %v", err)
+    }
+    if len(payload.Levels) > maxNotificationPrefs {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Too many conversations")
+    }
+    for chatID, level := range payload.Levels {
+        if chatID == "" || len(chatID) > 64 {
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid conversation")
         }
-        h.notifications[userID] = recent
+        switch level {
+        case protocol.LevelAll, protocol.LevelMentions, protocol.LevelBadge, protocol.LevelNone:
+        default:
+            return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid notification level")
+        }
+    }
+
+    for chatID, level := range payload.Levels {
+        if err := h.db.SetNotificationLevel(ctx, sender.ID, chatID, level); err != nil {
+            return err
+        }
+    }
+
+    levels, err := h.db.GetNotificationLevels(ctx, sender.ID)
+    if err != nil {
+        return err
+    }
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeNotificationPrefs, protocol.NotificationPrefsPayload{Levels: levels}):
+        return nil
+    default:
+        return fmt.Errorf("failed to send notification levels: channel full")
+    }
+}
+
+func notificationPayload(n *models.Notification) protocol.NotificationPayload {
+    payload := protocol.NotificationPayload{
+        ID:        n.ID,
+        Type:      n.Type,
+        Message:   n.Content,
+        Actor:     n.Actor,
+        CreatedAt: n.CreatedAt.Unix(),
+        Read:      n.ReadAt != nil,
+    }
+    if n.RelatedID != nil {
+        payload.RelatedID = *n.RelatedID
     }
+    return payload
 }
@@ -0,0 +1,71 @@
+// internal/server/handlers/previews.go
+package handlers
+
+import (
+	"context"
+	"log"
+	"textual/internal/server/models"
+	"textual/internal/server/previews"
+	"textual/pkg/protocol"
+	"time"
+)
+
+// SetPreviews enables the link previews, fetched in the background once a
+// message is stored
+func (h *MessageHandler) SetPreviews(fetcher *previews.Fetcher, timeout time.Duration) {
+    h.previews = fetcher
+    h.previewTimeout = timeout
+}
+
+// previewLink fetches the page of the first link of a message, then sends the
+// message again with its preview to the conversation
+func (h *MessageHandler) previewLink(msg *models.Message) {
+    if h.previews == nil {
+        return
+    }
+    link := previews.FirstURL(msg.Content)
+    if link == "" {
+        return
+    }
+
+    message := *msg
+    go func() {
+        // the redirects and a wait for a free slot come on top of the fetch
+        ctx, cancel := context.WithTimeout(h.ctx, 2*h.previewTimeout)
+        defer cancel()
+
+        preview, err := h.previews.Fetch(ctx, link)
+        if err != nil {
+            log.Printf("No preview for %s: %v", link, err)
+            return
+        }
+        sanitizePreview(preview)
+        if err := h.db.SaveLinkPreview(ctx, message.ID, *preview); err != nil {
+            log.Printf("Failed to save preview of %s: %v", link, err)
+            return
+        }
+
+        message.Preview = preview
+        if err := h.sendToConversation(ctx, &message, protocol.NewMessage(protocol.TypeLinkPreview, h.createMessagePayload(&message))); err != nil {
+            log.Printf("Failed to send preview of %s: %v", link, err)
+        }
+    }()
+}
+
+// previewEdit sets the preview of an edited message. An edit that changed its
+// link drops the old preview and fetches the new link
+func (h *MessageHandler) previewEdit(ctx context.Context, original, edited *models.Message) error {
+    if previews.FirstURL(original.Content) == previews.FirstURL(edited.Content) {
+        messages := []models.Message{*edited}
+        if err := h.db.AttachPreviews(ctx, messages); err != nil {
+            return err
+        }
+        edited.Preview = messages[0].Preview
+        return nil
+    }
+    if err := h.db.DeleteLinkPreview(ctx, edited.ID); err != nil {
+        return err
+    }
+    h.previewLink(edited)
+    return nil
+}
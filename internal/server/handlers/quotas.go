@@ -0,0 +1,31 @@
+// internal/server/handlers/quotas.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"textual/internal/server/database"
+	"textual/pkg/protocol"
+)
+
+// quotaError turns a quota the database refused a change for into an error
+// for the client, the other errors are wrapped with what failed
+func quotaError(err error, failed string) error {
+    var quota *database.QuotaError
+    if !errors.As(err, &quota) {
+        return fmt.Errorf("%s: %v", failed, err)
+    }
+    switch quota.Quota {
+    case database.QuotaMembersPerGroup:
+        return protocol.NewError(protocol.ErrCodeQuotaExceeded, fmt.Sprintf("The group is full, %d members at most", quota.Limit))
+    case database.QuotaGroupsPerUser:
+        return protocol.NewError(protocol.ErrCodeQuotaExceeded, fmt.Sprintf("The limit of %d groups per user is reached", quota.Limit))
+    default:
+        return protocol.NewError(protocol.ErrCodeQuotaExceeded, fmt.Sprintf("The limit of %d friends per user is reached", quota.Limit))
+    }
+}
+
+func isQuotaError(err error) bool {
+    var quota *database.QuotaError
+    return errors.As(err, &quota)
+}
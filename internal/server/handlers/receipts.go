@@ -0,0 +1,61 @@
+// internal/server/handlers/receipts.go
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+)
+
+// HandleConnected runs once a session is registered: the direct messages
+// sent to the user while offline are delivered with it, their senders are
+// told
+func (h *MessageHandler) HandleConnected(ctx context.Context, client *Client) {
+    // the stats tell the client it is an admin's
+    if h.isAdmin(client.Username) {
+        h.sendAdminStats(client)
+    }
+    last, err := h.db.DeliverPending(ctx, client.ID)
+    if err != nil {
+        log.Printf("Failed to deliver pending messages of %s: %v", client.Username, err)
+        return
+    }
+    for senderID, messageID := range last {
+        h.sendMessageStatus(senderID, client.ID, messageID, protocol.MessageDelivered)
+    }
+}
+
+// markDelivered sets a direct message delivered once a device of its
+// recipient got it
+func (h *MessageHandler) markDelivered(ctx context.Context, msg *models.Message) {
+    if err := h.db.MarkDelivered(ctx, msg.ID); err != nil {
+        log.Printf("%v", err)
+        return
+    }
+    msg.Status = models.MessageStatusDelivered
+}
+
+// markConversationRead sets read the direct messages of otherID the reader
+// read, up to messageID, and tells their sender
+func (h *MessageHandler) markConversationRead(ctx context.Context, reader *Client, otherID, messageID string) {
+    read, err := h.db.MarkConversationRead(ctx, reader.ID, otherID, messageID)
+    if err != nil {
+        log.Printf("%v", err)
+        return
+    }
+    if read {
+        h.sendMessageStatus(otherID, reader.ID, messageID, protocol.MessageRead)
+    }
+}
+
+// sendMessageStatus tells the devices of the sender of direct messages that
+// those sent to recipientID, up to messageID, reached status
+func (h *MessageHandler) sendMessageStatus(senderID, recipientID, messageID, status string) {
+    h.sessions.Send(senderID, protocol.NewMessage(protocol.TypeMessageStatus, protocol.MessageStatusPayload{
+        ChatID:    recipientID,
+        MessageID: messageID,
+        Status:    status,
+    }))
+}
@@ -0,0 +1,187 @@
+// internal/server/handlers/reminders.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+	"time"
+	"unicode/utf8"
+)
+
+// reminders delivered at most in one round, the others wait for the next
+const reminderBatchSize = 100
+
+// a reminder set for right now can reach the server a bit late
+const reminderClockSkew = time.Minute
+
+// errSystemRecipient refuses what is written to the system account
+var errSystemRecipient = protocol.NewError(protocol.ErrCodeAccessDenied, protocol.SystemUsername+" only sends reminders, it can't be answered")
+
+// handleReminderCreate saves a reminder and answers with it
+func (h *MessageHandler) handleReminderCreate(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.ReminderPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid reminder payload: %v", err)
+    }
+    content := strings.TrimSpace(sanitizeText(payload.Content))
+    if content == "" {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Missing reminder text")
+    }
+    if utf8.RuneCountInString(content) > protocol.MaxReminderText {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Reminder too long (%d characters max)", protocol.MaxReminderText))
+    }
+    remindAt := time.Unix(payload.RemindAt, 0)
+    if remindAt.Before(h.clock.Now().Add(-reminderClockSkew)) {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "The reminder time is already past")
+    }
+    if remindAt.After(h.clock.Now().Add(protocol.MaxReminderDelay)) {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Reminders can be set up to a year ahead")
+    }
+
+    count, err := h.db.CountReminders(ctx, sender.ID)
+    if err != nil {
+        return err
+    }
+    if count >= protocol.MaxPendingReminders {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("Too many reminders waiting (%d max), cancel some first", protocol.MaxPendingReminders))
+    }
+
+    reminder := &models.Reminder{UserID: sender.ID, Content: content, RemindAt: remindAt}
+    if err := h.db.CreateReminder(ctx, reminder); err != nil {
+        return err
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeReminderCreate, reminderPayload(reminder)):
+        return nil
+    default:
+        return fmt.Errorf("failed to send reminder: channel full")
+    }
+}
+
+// handleReminderList sends the reminders of the user waiting to be delivered
+func (h *MessageHandler) handleReminderList(ctx context.Context, sender *Client) error {
+    reminders, err := h.db.GetReminders(ctx, sender.ID)
+    if err != nil {
+        return err
+    }
+
+    payload := protocol.ReminderListPayload{
+        Reminders: make([]protocol.ReminderPayload, 0, len(reminders)),
+    }
+    for i := range reminders {
+        payload.Reminders = append(payload.Reminders, reminderPayload(&reminders[i]))
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeReminderList, payload):
+        return nil
+    default:
+        return fmt.Errorf("failed to send reminders: channel full")
+    }
+}
+
+// handleReminderCancel deletes a reminder and answers with the ones left
+func (h *MessageHandler) handleReminderCancel(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.ReminderCancelPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid reminder cancel payload: %v", err)
+    }
+    if payload.ID == "" {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Missing reminder")
+    }
+
+    cancelled, err := h.db.CancelReminder(ctx, sender.ID, payload.ID)
+    if err != nil {
+        return err
+    }
+    if !cancelled {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "No such reminder, it may have been delivered already")
+    }
+    return h.handleReminderList(ctx, sender)
+}
+
+// StartReminders delivers the reminders when they are due, checking every
+// interval. The reminders missed while the server was down go out on start
+func (h *MessageHandler) StartReminders(interval time.Duration) (stop func()) {
+    done := make(chan struct{})
+    stopped := make(chan struct{})
+
+    go func() {
+        defer close(stopped)
+        ticker := h.clock.NewTicker(interval)
+        defer ticker.Stop()
+
+        h.deliverReminders(h.ctx)
+        for {
+            select {
+            case <-done:
+                return
+            case <-ticker.C():
+                h.deliverReminders(h.ctx)
+            }
+        }
+    }()
+
+    return func() {
+        close(done)
+        <-stopped
+    }
+}
+
+func (h *MessageHandler) deliverReminders(ctx context.Context) {
+    if !h.db.Healthy() {
+        return
+    }
+    reminders, err := h.db.GetDueReminders(ctx, h.clock.Now(), reminderBatchSize)
+    if err != nil {
+        log.Printf("Failed to get due reminders: %v", err)
+        return
+    }
+    for i := range reminders {
+        h.deliverReminder(ctx, &reminders[i])
+    }
+}
+
+// deliverReminder sends a reminder as a direct message of the system account.
+// The message is keyed by the reminder, so a reminder saved but not marked
+// before a crash is not sent twice
+func (h *MessageHandler) deliverReminder(ctx context.Context, reminder *models.Reminder) {
+    dbMsg := &models.Message{
+        Content:     "⏰ " + reminder.Content,
+        SenderID:    protocol.SystemUserID,
+        SenderName:  protocol.SystemUsername,
+        RecipientID: &reminder.UserID,
+        SentAt:      time.Now(),
+        Status:      models.MessageStatusSent,
+        ClientID:    "reminder-" + reminder.ID,
+    }
+    err := h.db.SaveMessage(ctx, dbMsg)
+    if err != nil && err != database.ErrDuplicateMessage {
+        log.Printf("Failed to deliver reminder %s: %v", reminder.ID, err)
+        return
+    }
+    if err := h.db.MarkReminderDelivered(ctx, reminder.ID); err != nil {
+        log.Printf("Failed to mark reminder %s as delivered: %v", reminder.ID, err)
+    }
+    // already in the history of the user
+    if err == database.ErrDuplicateMessage {
+        return
+    }
+
+    h.sessions.Send(reminder.UserID, protocol.NewMessage(protocol.TypeDirectMessage, h.createMessagePayload(dbMsg)))
+}
+
+func reminderPayload(r *models.Reminder) protocol.ReminderPayload {
+    return protocol.ReminderPayload{
+        ID:        r.ID,
+        Content:   r.Content,
+        RemindAt:  r.RemindAt.Unix(),
+        CreatedAt: r.CreatedAt.Unix(),
+    }
+}
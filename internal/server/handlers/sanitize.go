@@ -0,0 +1,110 @@
+// internal/server/handlers/sanitize.go
+package handlers
+
+import (
+	"strings"
+	"textual/internal/server/models"
+	"unicode"
+	"unicode/utf8"
+)
+
+// combining marks kept on a character, enough for any script, the rest
+// only stacks up over the lines around
+const maxCombiningMarks = 4
+
+// sanitizeText cleans a text written by a user before it is stored and sent
+// to the others: the escape sequences and the control characters would
+// drive their terminals, the zero-width characters hide what a text says.
+// The line breaks and the tabs are kept, and the joiners of the emoji
+// sequences
+func sanitizeText(s string) string {
+    var sb strings.Builder
+    sb.Grow(len(s))
+    marks := 0
+    var prev rune
+    for i := 0; i < len(s); {
+        r, size := utf8.DecodeRuneInString(s[i:])
+        if r == 0x1b {
+            i += escapeLength(s[i:])
+            continue
+        }
+        i += size
+
+        switch {
+        case r == '\n' || r == '\t':
+        case r == '\r':
+            // a carriage return alone would overwrite the line
+            continue
+        case unicode.IsControl(r), invisible(r):
+            continue
+        case r == '\u200d' && prev < 0x2000:
+            // a joiner only means something between emojis
+            continue
+        case unicode.In(r, unicode.Mn, unicode.Me):
+            if marks++; marks > maxCombiningMarks {
+                continue
+            }
+            sb.WriteRune(r)
+            continue
+        }
+        marks = 0
+        prev = r
+        sb.WriteRune(r)
+    }
+    return sb.String()
+}
+
+// sanitizePreview cleans the text of a preview, it comes from the page or
+// the GIF provider and is shown like the message
+func sanitizePreview(preview *models.LinkPreview) {
+    preview.Title = sanitizeText(preview.Title)
+    preview.Description = sanitizeText(preview.Description)
+    preview.SiteName = sanitizeText(preview.SiteName)
+}
+
+// invisible tells the characters without width that change nothing but what
+// is shown: zero-width spaces, direction overrides and byte order marks
+func invisible(r rune) bool {
+    switch {
+    case r >= '\u200b' && r <= '\u200c', r >= '\u200e' && r <= '\u200f':
+    case r >= '\u202a' && r <= '\u202e':
+    case r >= '\u2060' && r <= '\u206f':
+    case r == '\ufeff', r == '\u00ad', r == '\u180e':
+    default:
+        return false
+    }
+    return true
+}
+
+// escapeLength returns the length of the escape sequence s starts with:
+// the parameters of a CSI sequence, the text of an OSC, DCS, SOS, PM or APC
+// string up to its terminator, or the character after the escape
+func escapeLength(s string) int {
+    if len(s) < 2 {
+        return len(s)
+    }
+    switch s[1] {
+    case '[':
+        for i := 2; i < len(s); i++ {
+            if s[i] >= 0x40 && s[i] <= 0x7e {
+                return i + 1
+            }
+        }
+        return len(s)
+    case ']', 'P', 'X', '^', '_':
+        for i := 2; i < len(s); i++ {
+            switch {
+            case s[i] == 0x07:
+                return i + 1
+            case s[i] == 0x1b:
+                if i+1 < len(s) && s[i+1] == '\\' {
+                    return i + 2
+                }
+                return i
+            }
+        }
+        return len(s)
+    }
+    _, size := utf8.DecodeRuneInString(s[1:])
+    return 1 + size
+}
@@ -0,0 +1,47 @@
+// internal/server/handlers/sanitize_test.go
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"textual/internal/server/models"
+)
+
+func TestSanitizeText(t *testing.T) {
+    for _, test := range []struct {
+        name, in, want string
+    }{
+        {"plain", "hello, world", "hello, world"},
+        {"lines and tabs", "a\n\tb\r\nc", "a\n\tb\nc"},
+        {"colors", "\x1b[31mred\x1b[0m", "red"},
+        {"clear screen", "\x1b[2J\x1b[Hgone", "gone"},
+        {"title", "\x1b]0;owned\x07text", "text"},
+        {"hyperlink", "\x1b]8;;http://evil\x1b\\link\x1b]8;;\x1b\\", "link"},
+        {"unterminated", "text\x1b[31", "text"},
+        {"controls", "be\x07ll\x08\x7f\u009b", "bell"},
+        {"zero width", "pay\u200bpal\u202e\ufeff", "paypal"},
+        {"emoji sequence", "👨\u200d👩\u200d👧", "👨\u200d👩\u200d👧"},
+        {"lonely joiner", "a\u200db", "ab"},
+        {"accents", "été", "été"},
+        {"zalgo", "z" + strings.Repeat("\u0336", 40) + "!", "z" + strings.Repeat("\u0336", maxCombiningMarks) + "!"},
+        {"unicode", "日本語 ünïcödé", "日本語 ünïcödé"},
+    } {
+        if got := sanitizeText(test.in); got != test.want {
+            t.Errorf("%s: sanitizeText(%q) = %q, want %q", test.name, test.in, got, test.want)
+        }
+    }
+}
+
+func TestSanitizePreview(t *testing.T) {
+    preview := models.LinkPreview{
+        URL:         "https://example.com",
+        Title:       "\x1b]0;owned\x07News",
+        Description: "\x1b[2Jall\u202e gone",
+        SiteName:    "Exa\x1b[31mmple",
+    }
+    sanitizePreview(&preview)
+    want := models.LinkPreview{URL: "https://example.com", Title: "News", Description: "all gone", SiteName: "Example"}
+    if preview != want {
+        t.Errorf("sanitizePreview = %+v, want %+v", preview, want)
+    }
+}
@@ -0,0 +1,165 @@
+// internal/server/handlers/sessions.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"textual/pkg/protocol"
+)
+
+// lastSession numbers the connections, a session ID is never reused
+var lastSession atomic.Uint64
+
+func nextSessionID() string {
+    return strconv.FormatUint(lastSession.Add(1), 10)
+}
+
+// Sessions are the connected clients, keyed by connection: a user logged in
+// on several devices has one session on each, and each gets what is sent to
+// the user
+type Sessions struct {
+    mu     sync.RWMutex
+    byUser map[string]map[string]*Client // user ID -> session ID -> client
+    count  int
+}
+
+func NewSessions() *Sessions {
+    return &Sessions{byUser: make(map[string]map[string]*Client)}
+}
+
+// Add registers the session of a client
+func (s *Sessions) Add(client *Client) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    sessions, ok := s.byUser[client.ID]
+    if !ok {
+        sessions = make(map[string]*Client)
+        s.byUser[client.ID] = sessions
+    }
+    if _, ok := sessions[client.SessionID]; !ok {
+        s.count++
+    }
+    sessions[client.SessionID] = client
+}
+
+// Remove unregisters the session of a client, it returns true when it was
+// the last one of the user and false when it was not registered
+func (s *Sessions) Remove(client *Client) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    sessions := s.byUser[client.ID]
+    if sessions[client.SessionID] != client {
+        return false
+    }
+    delete(sessions, client.SessionID)
+    s.count--
+    if len(sessions) > 0 {
+        return false
+    }
+    delete(s.byUser, client.ID)
+    return true
+}
+
+// Online tells if a user has a session
+func (s *Sessions) Online(userID string) bool {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return len(s.byUser[userID]) > 0
+}
+
+// Of returns the sessions of a user
+func (s *Sessions) Of(userID string) []*Client {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    clients := make([]*Client, 0, len(s.byUser[userID]))
+    for _, client := range s.byUser[userID] {
+        clients = append(clients, client)
+    }
+    return clients
+}
+
+// Len returns the number of sessions
+func (s *Sessions) Len() int {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.count
+}
+
+// Each calls fn with every session, fn must not block
+func (s *Sessions) Each(fn func(*Client)) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    for _, sessions := range s.byUser {
+        for _, client := range sessions {
+            fn(client)
+        }
+    }
+}
+
+// Send queues msg for every session of a user and returns how many got it,
+// a full queue is skipped. The sessions can't be closed meanwhile: they are
+// removed before their channel is closed
+func (s *Sessions) Send(userID string, msg protocol.Message) int {
+    return s.SendExcept(userID, nil, msg)
+}
+
+// Disconnect ends every session of a user with notice, written last by the
+// write pump. A session whose queue is full is closed without it. It returns
+// how many sessions were ended
+func (s *Sessions) Disconnect(userID string, notice protocol.Message) int {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    for _, client := range s.byUser[userID] {
+        select {
+        case client.Send <- notice:
+        default:
+            log.Printf("Failed to send %s to %s: channel full", notice.Type, client.Username)
+            client.Conn.Close()
+        }
+    }
+    return len(s.byUser[userID])
+}
+
+// SendExcept queues msg for the sessions of a user but one, the one that
+// sent what msg tells the others about
+func (s *Sessions) SendExcept(userID string, except *Client, msg protocol.Message) int {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    sent := 0
+    for _, client := range s.byUser[userID] {
+        if client == except {
+            continue
+        }
+        select {
+        case client.Send <- msg:
+            sent++
+        default:
+            log.Printf("Failed to send %s to %s: channel full", msg.Type, client.Username)
+        }
+    }
+    return sent
+}
+
+// handleReadMarker relays a conversation read on a device to the other
+// devices of the user, they clear its unread count, and sets read the
+// direct messages it holds
+func (h *MessageHandler) handleReadMarker(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.ReadMarkerPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid read marker payload: %v", err)
+    }
+    if payload.ChatID == "" || len(payload.ChatID) > 64 || len(payload.MessageID) > 64 {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Invalid read marker")
+    }
+    h.sessions.SendExcept(sender.ID, sender, protocol.NewMessage(protocol.TypeReadMarker, payload))
+    // the other user of a direct conversation sees its messages read
+    if payload.ChatID != "global" {
+        h.markConversationRead(ctx, sender, payload.ChatID, payload.MessageID)
+    }
+    return nil
+}
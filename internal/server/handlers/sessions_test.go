@@ -0,0 +1,76 @@
+// internal/server/handlers/sessions_test.go
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"textual/pkg/protocol"
+)
+
+func TestSessions(t *testing.T) {
+    s := NewSessions()
+    phone := NewClient(nil, "alice", "alice")
+    laptop := NewClient(nil, "alice", "alice")
+    bob := NewClient(nil, "bob", "bob")
+    s.Add(phone)
+    s.Add(laptop)
+    s.Add(bob)
+
+    if s.Len() != 3 || len(s.Of("alice")) != 2 {
+        t.Fatalf("%d sessions, %d for alice, want 3 and 2", s.Len(), len(s.Of("alice")))
+    }
+    if sent := s.Send("alice", protocol.NewMessage(protocol.TypePong, nil)); sent != 2 {
+        t.Fatalf("message sent to %d sessions of alice, want 2", sent)
+    }
+    if len(phone.Send) != 1 || len(laptop.Send) != 1 || len(bob.Send) != 0 {
+        t.Fatal("message not queued for every session of alice only")
+    }
+
+    if s.Remove(phone) {
+        t.Fatal("first session of alice reported as the last one")
+    }
+    if !s.Online("alice") {
+        t.Fatal("alice offline with a session left")
+    }
+    if s.Remove(phone) {
+        t.Fatal("session removed twice")
+    }
+    if !s.Remove(laptop) || s.Online("alice") {
+        t.Fatal("alice still online without a session")
+    }
+    if s.Len() != 1 {
+        t.Fatalf("%d sessions left, want 1", s.Len())
+    }
+}
+
+func TestReadMarkerRelayed(t *testing.T) {
+    sessions := NewSessions()
+    h := NewMessageHandler(nil, nil, sessions)
+    phone := NewClient(nil, "alice", "alice")
+    laptop := NewClient(nil, "alice", "alice")
+    sessions.Add(phone)
+    sessions.Add(laptop)
+
+    marker := protocol.NewMessage(protocol.TypeReadMarker, protocol.ReadMarkerPayload{ChatID: "global", MessageID: "42"})
+    if err := h.handleReadMarker(context.Background(), phone, marker); err != nil {
+        t.Fatal(err)
+    }
+    if len(phone.Send) != 0 {
+        t.Fatal("marker sent back to the device that read the chat")
+    }
+    select {
+    case msg := <-laptop.Send:
+        var payload protocol.ReadMarkerPayload
+        if err := protocol.DecodePayload(msg.Payload, &payload); err != nil || payload.ChatID != "global" || payload.MessageID != "42" {
+            t.Fatalf("marker %+v (%v), want global/42", payload, err)
+        }
+    default:
+        t.Fatal("marker not relayed to the other device")
+    }
+
+    empty := protocol.NewMessage(protocol.TypeReadMarker, protocol.ReadMarkerPayload{})
+    if err := h.handleReadMarker(context.Background(), phone, empty); err == nil {
+        t.Fatal("marker without chat accepted")
+    }
+}
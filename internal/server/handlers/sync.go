@@ -0,0 +1,146 @@
+// internal/server/handlers/sync.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"textual/internal/server/database"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+)
+
+const (
+    // messages of the global chat in the sync
+    syncGlobalMessages = 100
+    // messages of each group and direct conversation in the sync
+    syncConversationMessages = 20
+)
+
+// syncPayload gathers the state a client draws after the login: the profile
+// and display preferences, the friends and requests, the groups, the unread counts and the latest
+// messages of every conversation
+func (h *AuthHandler) syncPayload(ctx context.Context, userID string) (protocol.SyncPayload, error) {
+    var payload protocol.SyncPayload
+
+    user, err := h.db.GetUser(ctx, userID)
+    if err != nil {
+        return payload, fmt.Errorf("failed to get profile: %v", err)
+    }
+    payload.Profile = userInfo(user)
+
+    prefs, err := h.db.GetDisplayPrefs(ctx, userID)
+    if err != nil {
+        return payload, err
+    }
+    payload.Prefs = displayPrefsPayload(prefs)
+
+    friends, err := h.db.GetFriends(ctx, userID)
+    if err != nil {
+        return payload, fmt.Errorf("failed to get friends: %v", err)
+    }
+    payload.Friends = make([]protocol.UserInfo, 0, len(friends))
+    for i := range friends {
+        payload.Friends = append(payload.Friends, userInfo(&friends[i]))
+    }
+
+    requests, err := h.db.GetPendingFriendRequests(ctx, userID)
+    if err != nil {
+        return payload, fmt.Errorf("failed to get friend requests: %v", err)
+    }
+    payload.Requests = make([]protocol.FriendRequestPayload, 0, len(requests))
+    for _, req := range requests {
+        payload.Requests = append(payload.Requests, protocol.FriendRequestPayload{
+            RequestID: database.FriendRequestID(req.FromUserID, req.ToUserID, req.CreatedAt),
+            FromUser:  req.FromUsername,
+            ToUser:    req.ToUsername,
+            Status:    "pending",
+            CreatedAt: req.CreatedAt.Unix(),
+        })
+    }
+
+    groups, err := h.db.GetUserGroups(ctx, userID)
+    if err != nil {
+        return payload, fmt.Errorf("failed to get groups: %v", err)
+    }
+    payload.Groups = make([]protocol.GroupPayload, 0, len(groups))
+    for i := range groups {
+        payload.Groups = append(payload.Groups, groupPayload(&groups[i]))
+    }
+
+    payload.Unread, err = h.db.GetUnreadCounts(ctx, userID)
+    if err != nil {
+        return payload, err
+    }
+
+    if err := h.addConversation(ctx, &payload, userID, "", "", syncGlobalMessages); err != nil {
+        return payload, err
+    }
+    for _, group := range groups {
+        if err := h.addConversation(ctx, &payload, userID, "", group.ID, syncConversationMessages); err != nil {
+            return payload, err
+        }
+    }
+    // the senders of unread messages may not be friends, the notes are the
+    // conversation with the user
+    partners := map[string]bool{userID: true}
+    for _, friend := range friends {
+        partners[friend.ID] = true
+    }
+    for senderID := range payload.Unread {
+        partners[senderID] = true
+    }
+    for partnerID := range partners {
+        if err := h.addConversation(ctx, &payload, userID, partnerID, "", syncConversationMessages); err != nil {
+            return payload, err
+        }
+    }
+
+    return payload, nil
+}
+
+// addConversation adds the latest messages of a chat to the sync, an empty
+// chat is left out
+func (h *AuthHandler) addConversation(ctx context.Context, payload *protocol.SyncPayload, userID, recipientID, groupID string, limit int) error {
+    var messages []models.Message
+    var err error
+    if recipientID == "" && groupID == "" {
+        messages, err = h.db.GetMessages(ctx, userID, limit)
+    } else {
+        messages, err = h.db.GetConversationMessages(ctx, userID, recipientID, groupID, "", limit)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to load messages: %v", err)
+    }
+    messages = withArchived(ctx, h.db, messages, userID, recipientID, groupID, "", limit)
+    if len(messages) == 0 {
+        return nil
+    }
+    if err := h.db.AttachVoice(ctx, messages); err != nil {
+        return err
+    }
+    if err := h.db.AttachPreviews(ctx, messages); err != nil {
+        return err
+    }
+
+    raw, err := json.Marshal(messages)
+    if err != nil {
+        return fmt.Errorf("failed to encode messages: %v", err)
+    }
+    payload.Conversations = append(payload.Conversations, protocol.SyncConversation{
+        RecipientID: recipientID,
+        GroupID:     groupID,
+        Messages:    raw,
+    })
+    return nil
+}
+
+func userInfo(user *models.User) protocol.UserInfo {
+    return protocol.UserInfo{
+        ID:            user.ID,
+        Username:      user.Username,
+        Status:        user.Status,
+        Text:          user.StatusText,
+        TextExpiresAt: unixTime(user.StatusExpiresAt),
+    }
+}
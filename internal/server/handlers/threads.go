@@ -0,0 +1,99 @@
+// internal/server/handlers/threads.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"textual/internal/server/models"
+	"textual/pkg/protocol"
+)
+
+// setThreadRoot puts a message in the thread of rootID, which must be in the
+// same conversation. A reply to a reply goes to the thread of its root, the
+// threads have one level
+func (h *MessageHandler) setThreadRoot(ctx context.Context, msg *models.Message, rootID string) error {
+    if rootID == "" {
+        return nil
+    }
+    root, err := getMessage(ctx, h.db, rootID)
+    if err == nil && root.ThreadRootID != nil {
+        root, err = getMessage(ctx, h.db, *root.ThreadRootID)
+    }
+    if err != nil || !sameConversation(root, msg) {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Thread not found")
+    }
+    msg.ThreadRootID = &root.ID
+    return nil
+}
+
+// sameConversation tells if two messages are in the same chat
+func sameConversation(a, b *models.Message) bool {
+    switch {
+    case a.GroupID != nil || b.GroupID != nil:
+        return a.GroupID != nil && b.GroupID != nil && *a.GroupID == *b.GroupID
+    case a.RecipientID != nil || b.RecipientID != nil:
+        if a.RecipientID == nil || b.RecipientID == nil {
+            return false
+        }
+        return a.SenderID == b.SenderID && *a.RecipientID == *b.RecipientID ||
+            a.SenderID == *b.RecipientID && *a.RecipientID == b.SenderID
+    }
+    return true
+}
+
+// canSeeMessage tells if a user is in the conversation of a message
+func (h *MessageHandler) canSeeMessage(ctx context.Context, userID string, msg *models.Message) (bool, error) {
+    switch {
+    case msg.GroupID != nil:
+        return h.db.IsGroupMember(ctx, userID, *msg.GroupID)
+    case msg.RecipientID != nil:
+        return msg.SenderID == userID || *msg.RecipientID == userID, nil
+    }
+    return true, nil
+}
+
+// handleLoadThread sends a thread root with a page of its replies
+func (h *MessageHandler) handleLoadThread(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.LoadThreadPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid load thread payload: %v", err)
+    }
+    if payload.Limit <= 0 || payload.Limit > maxHistoryPage {
+        payload.Limit = maxHistoryPage
+    }
+
+    root, err := getMessage(ctx, h.db, payload.RootID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, "Thread not found")
+    }
+    if ok, err := h.canSeeMessage(ctx, sender.ID, root); err != nil {
+        return err
+    } else if !ok {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Thread not found")
+    }
+
+    replies, err := h.db.GetThreadMessages(ctx, root.ID, payload.BeforeID, payload.Limit)
+    if err != nil {
+        return err
+    }
+    replies = withArchivedReplies(ctx, h.db, replies, root.ID, payload.BeforeID, payload.Limit)
+    all := append([]models.Message{*root}, replies...)
+    if err := h.db.AttachVoice(ctx, all); err != nil {
+        return err
+    }
+    if err := h.db.AttachPreviews(ctx, all); err != nil {
+        return err
+    }
+
+    response := protocol.NewMessage(protocol.TypeThreadHistory, map[string]interface{}{
+        "root":      all[0],
+        "messages":  all[1:],
+        "before_id": payload.BeforeID,
+    })
+    select {
+    case sender.Send <- response:
+        return nil
+    default:
+        return fmt.Errorf("failed to send thread: channel full")
+    }
+}
@@ -0,0 +1,91 @@
+// internal/server/handlers/topics.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"textual/pkg/protocol"
+)
+
+const (
+    // longest topic of a group, in characters
+    maxTopicLength = 200
+    // topics sent to a client asking for the history of a group
+    topicHistorySize = 20
+)
+
+// handleGroupTopic sets the topic of a group, the admins only. The members
+// get the topic and a system message dated like the stored change
+func (h *MessageHandler) handleGroupTopic(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupTopicPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group topic payload: %v", err)
+    }
+
+    role, err := h.db.GetGroupRole(ctx, sender.ID, payload.GroupID)
+    if err != nil {
+        return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+    }
+    if role != protocol.GroupRoleAdmin {
+        return protocol.NewError(protocol.ErrCodeAccessDenied, "Only group admins can set the topic")
+    }
+    topic := strings.Join(strings.Fields(sanitizeText(payload.Topic)), " ")
+    if utf8.RuneCountInString(topic) > maxTopicLength {
+        return protocol.NewError(protocol.ErrCodeInvalidRequest, fmt.Sprintf("A topic has at most %d characters", maxTopicLength))
+    }
+
+    change, err := h.db.SetGroupTopic(ctx, payload.GroupID, topic, sender.ID)
+    if err != nil {
+        return err
+    }
+    members, err := h.db.GetGroupMembers(ctx, payload.GroupID)
+    if err != nil {
+        return fmt.Errorf("failed to get group members: %v", err)
+    }
+    update := protocol.NewMessage(protocol.TypeGroupTopic, protocol.GroupTopicPayload{
+        GroupID: payload.GroupID,
+        Topic:   change.Topic,
+        SetBy:   change.SetBy,
+        SetAt:   change.SetAt.Unix(),
+    })
+    for _, memberID := range members {
+        h.sessions.Send(memberID, update)
+    }
+    h.sendSystemMessageAt(ctx, payload.GroupID, protocol.SystemTopic, change.SetBy, change.Topic, change.SetAt)
+    return nil
+}
+
+// handleGroupTopicHistory sends the latest topics of a group to a member
+func (h *MessageHandler) handleGroupTopicHistory(ctx context.Context, sender *Client, msg protocol.Message) error {
+    var payload protocol.GroupTopicHistoryPayload
+    if err := h.decodePayload(msg.Payload, &payload); err != nil {
+        return fmt.Errorf("invalid group topic history payload: %v", err)
+    }
+    if isMember, err := h.db.IsGroupMember(ctx, sender.ID, payload.GroupID); err != nil || !isMember {
+        return protocol.NewError(protocol.ErrCodeNotAuthorized, "Not a member of this group")
+    }
+
+    changes, err := h.db.GetTopicChanges(ctx, payload.GroupID, topicHistorySize)
+    if err != nil {
+        return err
+    }
+    payload.Changes = make([]protocol.GroupTopicPayload, 0, len(changes))
+    for _, change := range changes {
+        payload.Changes = append(payload.Changes, protocol.GroupTopicPayload{
+            GroupID: payload.GroupID,
+            Topic:   change.Topic,
+            SetBy:   change.SetBy,
+            SetAt:   change.SetAt.Unix(),
+        })
+    }
+
+    select {
+    case sender.Send <- protocol.NewMessage(protocol.TypeGroupTopicHistory, payload):
+        return nil
+    default:
+        return fmt.Errorf("failed to send topic history: channel full")
+    }
+}
@@ -0,0 +1,84 @@
+// internal/server/integrations/github.go
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type githubRepository struct {
+    FullName string `json:"full_name"`
+}
+
+type githubUser struct {
+    Login string `json:"login"`
+}
+
+type githubItem struct {
+    Number  int    `json:"number"`
+    Title   string `json:"title"`
+    HTMLURL string `json:"html_url"`
+    Merged  bool   `json:"merged"`
+}
+
+func formatGitHub(event string, body []byte) (string, error) {
+    switch event {
+    case "push":
+        var push struct {
+            Ref        string           `json:"ref"`
+            Deleted    bool             `json:"deleted"`
+            Compare    string           `json:"compare"`
+            Repository githubRepository `json:"repository"`
+            Sender     githubUser       `json:"sender"`
+            Commits    []struct {
+                ID      string `json:"id"`
+                Message string `json:"message"`
+            } `json:"commits"`
+        }
+        if err := json.Unmarshal(body, &push); err != nil {
+            return "", fmt.Errorf("invalid push payload: %v", err)
+        }
+        commits := make([]commit, len(push.Commits))
+        for i, c := range push.Commits {
+            commits[i] = commit{id: c.ID, message: c.Message}
+        }
+        link := push.Compare
+        if push.Deleted {
+            link = ""
+        }
+        return pushMessage(push.Repository.FullName, push.Sender.Login, push.Ref, commits, len(commits), push.Deleted, link), nil
+
+    case "pull_request", "issues":
+        var change struct {
+            Action      string           `json:"action"`
+            Repository  githubRepository `json:"repository"`
+            Sender      githubUser       `json:"sender"`
+            PullRequest *githubItem      `json:"pull_request"`
+            Issue       *githubItem      `json:"issue"`
+        }
+        if err := json.Unmarshal(body, &change); err != nil {
+            return "", fmt.Errorf("invalid %s payload: %v", event, err)
+        }
+        item, name := change.Issue, "issue"
+        if event == "pull_request" {
+            item, name = change.PullRequest, "pull request"
+        }
+        if item == nil {
+            return "", fmt.Errorf("invalid %s payload: no %s", event, name)
+        }
+
+        action := change.Action
+        switch {
+        case action == "closed" && item.Merged:
+            action = "merged"
+        case action == "ready_for_review":
+            action = "marked as ready for review"
+        case action != "opened" && action != "closed" && action != "reopened":
+            // edits, labels, assignments... would flood the group
+            return "", ErrIgnored
+        }
+        return itemMessage(change.Repository.FullName, change.Sender.Login, action, name, item.Number, item.Title, item.HTMLURL), nil
+    }
+    // ping and the events not subscribed on purpose
+    return "", ErrIgnored
+}
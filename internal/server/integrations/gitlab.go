@@ -0,0 +1,82 @@
+// internal/server/integrations/gitlab.go
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sha of a branch deleted by a push
+const gitlabNullSHA = "0000000000000000000000000000000000000000"
+
+type gitlabProject struct {
+    PathWithNamespace string `json:"path_with_namespace"`
+    WebURL            string `json:"web_url"`
+}
+
+// the past tense of the actions posted, the others are ignored
+var gitlabActions = map[string]string{
+    "open":   "opened",
+    "close":  "closed",
+    "reopen": "reopened",
+    "merge":  "merged",
+}
+
+func formatGitLab(event string, body []byte) (string, error) {
+    switch event {
+    case "Push Hook", "Tag Push Hook":
+        var push struct {
+            Ref          string        `json:"ref"`
+            Before       string        `json:"before"`
+            After        string        `json:"after"`
+            UserUsername string        `json:"user_username"`
+            Project      gitlabProject `json:"project"`
+            TotalCommits int           `json:"total_commits_count"`
+            Commits      []struct {
+                ID      string `json:"id"`
+                Message string `json:"message"`
+            } `json:"commits"`
+        }
+        if err := json.Unmarshal(body, &push); err != nil {
+            return "", fmt.Errorf("invalid push payload: %v", err)
+        }
+        commits := make([]commit, len(push.Commits))
+        for i, c := range push.Commits {
+            commits[i] = commit{id: c.ID, message: c.Message}
+        }
+        deleted := push.After == gitlabNullSHA
+        link := ""
+        if !deleted && push.Before != gitlabNullSHA && push.Project.WebURL != "" {
+            link = fmt.Sprintf("%s/-/compare/%s...%s", push.Project.WebURL, push.Before[:min(8, len(push.Before))], push.After[:min(8, len(push.After))])
+        }
+        return pushMessage(push.Project.PathWithNamespace, push.UserUsername, push.Ref, commits, push.TotalCommits, deleted, link), nil
+
+    case "Merge Request Hook", "Issue Hook":
+        var change struct {
+            User struct {
+                Username string `json:"username"`
+            } `json:"user"`
+            Project gitlabProject `json:"project"`
+            Attrs   struct {
+                IID    int    `json:"iid"`
+                Title  string `json:"title"`
+                URL    string `json:"url"`
+                Action string `json:"action"`
+            } `json:"object_attributes"`
+        }
+        if err := json.Unmarshal(body, &change); err != nil {
+            return "", fmt.Errorf("invalid %s payload: %v", strings.ToLower(event), err)
+        }
+        action, ok := gitlabActions[change.Attrs.Action]
+        if !ok {
+            return "", ErrIgnored
+        }
+        item := "issue"
+        if event == "Merge Request Hook" {
+            item = "merge request"
+        }
+        return itemMessage(change.Project.PathWithNamespace, change.User.Username, action, item, change.Attrs.IID, change.Attrs.Title, change.Attrs.URL), nil
+    }
+    return "", ErrIgnored
+}
@@ -0,0 +1,162 @@
+// internal/server/integrations/integrations.go
+package integrations
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+    KindGitHub = "github"
+    KindGitLab = "gitlab"
+
+    // commits listed under a push, the others are counted
+    maxCommits = 5
+    // longest title kept, issues and pull requests can have long ones
+    maxTitleLength = 120
+)
+
+var (
+    ErrBadSignature = errors.New("invalid signature")
+    // ErrIgnored is returned for the events that are not posted, such as the
+    // ping sent when a webhook is set up
+    ErrIgnored = errors.New("event ignored")
+)
+
+// Valid tells if kind is a supported service
+func Valid(kind string) bool {
+    return kind == KindGitHub || kind == KindGitLab
+}
+
+// Verify checks that a delivery comes from the service, using the secret
+// given when the webhook was set up
+func Verify(kind, secret string, header http.Header, body []byte) error {
+    switch kind {
+    case KindGitHub:
+        signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+        if !ok {
+            return ErrBadSignature
+        }
+        expected, err := hex.DecodeString(signature)
+        if err != nil {
+            return ErrBadSignature
+        }
+        mac := hmac.New(sha256.New, []byte(secret))
+        mac.Write(body)
+        if !hmac.Equal(mac.Sum(nil), expected) {
+            return ErrBadSignature
+        }
+    case KindGitLab:
+        if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+            return ErrBadSignature
+        }
+    default:
+        return fmt.Errorf("unknown integration %q", kind)
+    }
+    return nil
+}
+
+// Format writes the message posted for a delivery, it returns ErrIgnored for
+// the events and actions not posted
+func Format(kind string, header http.Header, body []byte) (string, error) {
+    switch kind {
+    case KindGitHub:
+        return formatGitHub(header.Get("X-GitHub-Event"), body)
+    case KindGitLab:
+        return formatGitLab(header.Get("X-Gitlab-Event"), body)
+    }
+    return "", fmt.Errorf("unknown integration %q", kind)
+}
+
+// DeliveryID identifies a delivery, the services send it again with the same
+// ID when it failed; empty when the service gives none
+func DeliveryID(kind string, header http.Header) string {
+    switch kind {
+    case KindGitHub:
+        return header.Get("X-GitHub-Delivery")
+    case KindGitLab:
+        return header.Get("X-Gitlab-Event-UUID")
+    }
+    return ""
+}
+
+type commit struct {
+    id      string
+    message string
+}
+
+// pushMessage writes a push as:
+//
+//	[owner/repo] alice pushed 2 commits to main
+//	  abc1234 Fix the thing
+//	  def5678 Add the other
+//	https://github.com/owner/repo/compare/...
+func pushMessage(repo, user, ref string, commits []commit, total int, deleted bool, link string) string {
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "[%s] %s ", repo, user)
+    name, isTag := strings.CutPrefix(ref, "refs/tags/")
+    if !isTag {
+        name = strings.TrimPrefix(ref, "refs/heads/")
+    }
+    kind := "branch"
+    if isTag {
+        kind = "tag"
+    }
+
+    switch {
+    case deleted:
+        fmt.Fprintf(&sb, "deleted %s %s", kind, name)
+        return sb.String()
+    case isTag:
+        fmt.Fprintf(&sb, "pushed tag %s", name)
+    case total == 0:
+        fmt.Fprintf(&sb, "pushed to %s", name)
+    default:
+        fmt.Fprintf(&sb, "pushed %d commit%s to %s", total, plural(total), name)
+    }
+    for i, c := range commits {
+        if i == maxCommits {
+            fmt.Fprintf(&sb, "\n  … and %d more", total-maxCommits)
+            break
+        }
+        id := c.id
+        if len(id) > 7 {
+            id = id[:7]
+        }
+        title, _, _ := strings.Cut(c.message, "\n")
+        fmt.Fprintf(&sb, "\n  %s %s", id, truncate(title))
+    }
+    if link != "" {
+        sb.WriteString("\n" + link)
+    }
+    return sb.String()
+}
+
+// itemMessage writes the change of an issue or a merge request as:
+//
+//	[owner/repo] alice opened pull request #12: Add the thing
+//	https://github.com/owner/repo/pull/12
+func itemMessage(repo, user, action, item string, number int, title, link string) string {
+    return fmt.Sprintf("[%s] %s %s %s #%d: %s\n%s", repo, user, action, item, number, truncate(title), link)
+}
+
+func truncate(title string) string {
+    title = strings.TrimSpace(title)
+    if runes := []rune(title); len(runes) > maxTitleLength {
+        return string(runes[:maxTitleLength-1]) + "…"
+    }
+    return title
+}
+
+func plural(n int) string {
+    if n == 1 {
+        return ""
+    }
+    return "s"
+}
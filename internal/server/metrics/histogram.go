@@ -0,0 +1,120 @@
+// internal/server/metrics/histogram.go
+package metrics
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// default bucket upper bounds, in milliseconds
+var defaultBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// Histogram counts observed durations into fixed buckets
+type Histogram struct {
+    mu      sync.Mutex
+    buckets []float64
+    counts  []int64 // last slot counts values above every bucket
+    count   int64
+    sumMs   float64
+    maxMs   float64
+}
+
+func NewHistogram() *Histogram {
+    return &Histogram{
+        buckets: defaultBuckets,
+        counts:  make([]int64, len(defaultBuckets)+1),
+    }
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+    ms := float64(d) / float64(time.Millisecond)
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    i := 0
+    for i < len(h.buckets) && ms > h.buckets[i] {
+        i++
+    }
+    h.counts[i]++
+    h.count++
+    h.sumMs += ms
+    if ms > h.maxMs {
+        h.maxMs = ms
+    }
+}
+
+type histogramSnapshot struct {
+    Count   int64            `json:"count"`
+    SumMs   float64          `json:"sum_ms"`
+    MaxMs   float64          `json:"max_ms"`
+    Buckets map[string]int64 `json:"buckets"`
+}
+
+// String implements expvar.Var, buckets are cumulative like Prometheus' "le" buckets
+func (h *Histogram) String() string {
+    h.mu.Lock()
+    snapshot := histogramSnapshot{
+        Count:   h.count,
+        SumMs:   h.sumMs,
+        MaxMs:   h.maxMs,
+        Buckets: make(map[string]int64, len(h.counts)),
+    }
+    var cumulative int64
+    for i, bound := range h.buckets {
+        cumulative += h.counts[i]
+        snapshot.Buckets[formatBound(bound)] = cumulative
+    }
+    snapshot.Buckets["+Inf"] = h.count
+    h.mu.Unlock()
+
+    data, _ := json.Marshal(snapshot)
+    return string(data)
+}
+
+func formatBound(bound float64) string {
+    data, _ := json.Marshal(bound)
+    return string(data)
+}
+
+// HistogramVec is a set of histograms keyed by name
+type HistogramVec struct {
+    mu         sync.RWMutex
+    histograms map[string]*Histogram
+}
+
+func NewHistogramVec() *HistogramVec {
+    return &HistogramVec{histograms: make(map[string]*Histogram)}
+}
+
+func (v *HistogramVec) Observe(name string, d time.Duration) {
+    v.mu.RLock()
+    h, ok := v.histograms[name]
+    v.mu.RUnlock()
+
+    if !ok {
+        v.mu.Lock()
+        if h, ok = v.histograms[name]; !ok {
+            h = NewHistogram()
+            v.histograms[name] = h
+        }
+        v.mu.Unlock()
+    }
+
+    h.Observe(d)
+}
+
+// String implements expvar.Var
+func (v *HistogramVec) String() string {
+    v.mu.RLock()
+    defer v.mu.RUnlock()
+
+    out := make(map[string]json.RawMessage, len(v.histograms))
+    for name, h := range v.histograms {
+        out[name] = json.RawMessage(h.String())
+    }
+
+    data, _ := json.Marshal(out)
+    return string(data)
+}
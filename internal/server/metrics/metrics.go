@@ -0,0 +1,47 @@
+// internal/server/metrics/metrics.go
+package metrics
+
+import (
+	"expvar"
+)
+
+// Server metrics, published through expvar under the "textual" map
+var (
+    vars = expvar.NewMap("textual")
+
+    DBUp           = new(expvar.Int)
+    DBPingFailures = new(expvar.Int)
+    DBPingMillis   = new(expvar.Int)
+    DBOpenConns    = new(expvar.Int)
+    DBInUseConns   = new(expvar.Int)
+
+    // per-query durations and errors, keyed by the DB method name
+    DBQueryDurations = NewHistogramVec()
+    DBQueryErrors    = new(expvar.Map).Init()
+
+    // slow clients: degraded, then disconnected, and the events they were
+    // spared
+    SlowClientsDegraded     = new(expvar.Int)
+    SlowClientsDisconnected = new(expvar.Int)
+    DeliverySkipped         = new(expvar.Int)
+    DeliveryCoalesced       = new(expvar.Int)
+)
+
+func init() {
+    vars.Set("db_up", DBUp)
+    vars.Set("db_ping_failures", DBPingFailures)
+    vars.Set("db_ping_ms", DBPingMillis)
+    vars.Set("db_open_conns", DBOpenConns)
+    vars.Set("db_in_use_conns", DBInUseConns)
+    vars.Set("db_query_duration_ms", DBQueryDurations)
+    vars.Set("db_query_errors", DBQueryErrors)
+    vars.Set("slow_clients_degraded", SlowClientsDegraded)
+    vars.Set("slow_clients_disconnected", SlowClientsDisconnected)
+    vars.Set("delivery_skipped", DeliverySkipped)
+    vars.Set("delivery_coalesced", DeliveryCoalesced)
+}
+
+// Publish registers an additional metric under the "textual" map
+func Publish(name string, v expvar.Var) {
+    vars.Set(name, v)
+}
@@ -0,0 +1,80 @@
+// internal/server/metrics/server.go
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Server exposes the metrics as JSON at /debug/vars, the "textual" map
+// next to the memory statistics of the runtime, and the health of the
+// server at /healthz for the probes of a load balancer or an orchestrator
+type Server struct {
+    http *http.Server
+    // logged once listening
+    endpoint string
+}
+
+// NewServer serves the metrics, healthy tells if the database answers the
+// health checks
+func NewServer(healthy func() bool) *Server {
+    mux := http.NewServeMux()
+    mux.Handle("GET /debug/vars", expvar.Handler())
+    mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+        if !healthy() {
+            http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+            return
+        }
+        w.Write([]byte("ok\n"))
+    })
+    return &Server{http: &http.Server{
+        Handler:           mux,
+        ReadHeaderTimeout: 10 * time.Second,
+    }, endpoint: "/debug/vars"}
+}
+
+// NewDebugServer serves the profiles of net/http/pprof under /debug/pprof/
+// and the metrics at /debug/vars, to diagnose a live server. Profiles show
+// the internals of the server, listen on the loopback interface only
+func NewDebugServer() *Server {
+    mux := http.NewServeMux()
+    mux.Handle("GET /debug/vars", expvar.Handler())
+    mux.HandleFunc("/debug/pprof/", pprof.Index)
+    mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+    mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+    mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+    mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+    // no write timeout, a CPU profile or a trace runs for the seconds asked
+    return &Server{http: &http.Server{
+        Handler:           mux,
+        ReadHeaderTimeout: 10 * time.Second,
+    }, endpoint: "/debug/pprof/"}
+}
+
+// Start listens on the address, such as 127.0.0.1:9100, and serves in the
+// background
+func (s *Server) Start(addr string) error {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+
+    log.Printf("Metrics served on %s%s", listener.Addr(), s.endpoint)
+    go func() {
+        if err := s.http.Serve(listener); err != nil && err != http.ErrServerClosed {
+            log.Printf("Metrics server error: %v", err)
+        }
+    }()
+    return nil
+}
+
+func (s *Server) Stop() {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    s.http.Shutdown(ctx)
+}
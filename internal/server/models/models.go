@@ -11,11 +11,22 @@ type User struct {
     Username     string     `json:"username"`
     PasswordHash string     `json:"-"`
     Status       string     `json:"status"`
+    // StatusText is the custom status, empty once StatusExpiresAt is past
+    StatusText      string     `json:"status_text,omitempty"`
+    StatusExpiresAt *time.Time `json:"status_expires_at,omitempty"`
     LastSeen     time.Time  `json:"last_seen"`
     LastLogin    time.Time  `json:"last_login"`
     CreatedAt    time.Time  `json:"created_at"`
 }
 
+// DisplayPrefs are how the clients of a user write the times and the
+// interface, empty fields keep the setting of each device
+type DisplayPrefs struct {
+    Timezone   string `json:"timezone"`
+    TimeFormat string `json:"time_format"`
+    Locale     string `json:"locale"`
+}
+
 type Message struct {
     ID          string     `json:"id"`
     Content     string     `json:"content"`
@@ -25,10 +36,51 @@ type Message struct {
     Status      string     `json:"status"`
     SentAt      time.Time  `json:"sent_at"`
     ReadAt      *time.Time `json:"read_at,omitempty"`
+    EditedAt    *time.Time `json:"edited_at,omitempty"`
     SenderName  string     `json:"sender_name,omitempty"`
+    ClientID    string     `json:"client_id,omitempty"` // idempotency key chosen by the sender
+    Voice       *Voice     `json:"voice,omitempty"`
+    // ThreadRootID is set on the replies of a thread, ReplyCount on its root
+    ThreadRootID *string   `json:"thread_root_id,omitempty"`
+    ReplyCount  int        `json:"reply_count,omitempty"`
+    Preview     *LinkPreview `json:"preview,omitempty"`
     // Timestamp   time.Time  `json:"timestamp"`
 }
 
+// Voice is the recording of a voice message
+type Voice struct {
+    AttachmentID string `json:"attachment_id"`
+    Duration     int64  `json:"duration_ms"`
+    Waveform     []byte `json:"waveform,omitempty"`
+}
+
+// LinkPreview describes the page of the first link of a message
+type LinkPreview struct {
+    URL         string `json:"url"`
+    Title       string `json:"title,omitempty"`
+    Description string `json:"description,omitempty"`
+    ImageURL    string `json:"image_url,omitempty"`
+    SiteName    string `json:"site_name,omitempty"`
+}
+
+// Attachment is an uploaded file, its content is on disk
+type Attachment struct {
+    ID         string    `json:"id"`
+    UploaderID string    `json:"uploader_id"`
+    Name       string    `json:"name"`
+    MimeType   string    `json:"mime_type"`
+    Size       int64     `json:"size"`
+    CreatedAt  time.Time `json:"created_at"`
+}
+
+type MessageRevision struct {
+    ID        string    `json:"id"`
+    MessageID string    `json:"message_id"`
+    Content   string    `json:"content"`
+    EditedBy  string    `json:"edited_by"`
+    EditedAt  time.Time `json:"edited_at"`
+}
+
 type Group struct {
     ID          string    `json:"id"`
     Name        string    `json:"name"`
@@ -36,13 +88,47 @@ type Group struct {
     CreatedBy   string    `json:"created_by"`
     CreatedAt   time.Time `json:"created_at"`
     Status      string    `json:"status"`
+    Public      bool      `json:"public"`
+    Encrypted   bool      `json:"encrypted"`
+    // only the Posters, the admins, write in an announcement group
+    Announcement bool     `json:"announcement"`
+    Posters     []string  `json:"posters,omitempty"`
     Members     []string  `json:"members"`
+    Topic       string    `json:"topic,omitempty"`
+}
+
+// TopicChange is a topic set on a group, SetBy is the username
+type TopicChange struct {
+    Topic string    `json:"topic"`
+    SetBy string    `json:"set_by"`
+    SetAt time.Time `json:"set_at"`
+}
+
+// GroupSummary is a public group as listed in the group directory
+type GroupSummary struct {
+    ID          string `json:"id"`
+    Name        string `json:"name"`
+    Description string `json:"description"`
+    MemberCount int    `json:"member_count"`
+    IsMember    bool   `json:"is_member"`
+    Announcement bool  `json:"announcement"`
+}
+
+// UserSummary is a user listed by a user search
+type UserSummary struct {
+    ID         string `json:"id"`
+    Username   string `json:"username"`
+    Status     string `json:"status"`
+    StatusText string `json:"status_text"`
+    IsFriend   bool   `json:"is_friend"`
 }
 
 type GroupMember struct {
     GroupID   string    `json:"group_id"`
     UserID    string    `json:"user_id"`
+    Username  string    `json:"username"`
     Role      string    `json:"role"`
+    Status    string    `json:"status"`
     JoinedAt  time.Time `json:"joined_at"`
 }
 
@@ -57,16 +143,53 @@ type FriendRequest struct {
     UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Notification is kept for a user until read: friend requests, group invites
+// and mentions
 type Notification struct {
     ID        string    `json:"id"`
     UserID    string    `json:"user_id"`
     Type      string    `json:"type"`
+    Actor     string    `json:"actor"`
     Content   string    `json:"content"`
-    RelatedID string    `json:"related_id"`
+    RelatedID *string   `json:"related_id,omitempty"`
     CreatedAt time.Time `json:"created_at"`
     ReadAt    *time.Time `json:"read_at,omitempty"`
 }
 
+// Mention is a message of the global chat or of a group naming the user,
+// GroupName is empty for the global chat
+type Mention struct {
+    MessageID  string    `json:"message_id"`
+    GroupID    *string   `json:"group_id,omitempty"`
+    GroupName  string    `json:"group_name,omitempty"`
+    SenderName string    `json:"sender_name"`
+    Content    string    `json:"content"`
+    SentAt     time.Time `json:"sent_at"`
+    // the message is in the archive, Content is empty
+    Archived bool `json:"archived,omitempty"`
+}
+
+// Reminder is a message the system account sends to the user at RemindAt
+type Reminder struct {
+    ID          string     `json:"id"`
+    UserID      string     `json:"user_id"`
+    Content     string     `json:"content"`
+    RemindAt    time.Time  `json:"remind_at"`
+    CreatedAt   time.Time  `json:"created_at"`
+    DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Integration posts the events of an external service in a group, Kind is
+// "github" or "gitlab"
+type Integration struct {
+    ID        string    `json:"id"`
+    GroupID   string    `json:"group_id"`
+    Kind      string    `json:"kind"`
+    Secret    string    `json:"-"`
+    CreatedBy string    `json:"created_by"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
 // Client représente une connexion client active
 type Client struct {
     ID       string    `json:"id"`
@@ -81,6 +204,7 @@ const (
     StatusOnline  = "online"
     StatusOffline = "offline"
     StatusAway    = "away"
+    StatusDND     = "dnd"
 )
 
 // Constantes pour les statuts de messages
@@ -141,6 +265,10 @@ func (m *Message) IsGroupMessage() bool {
     return m.GroupID != nil
 }
 
+func (m *Message) IsEdited() bool {
+    return m.EditedAt != nil
+}
+
 // Méthodes utilitaires pour Group
 func (g *Group) IsActive() bool {
     return g.Status == GroupStatusActive
@@ -0,0 +1,231 @@
+// internal/server/previews/fetcher.go
+package previews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"textual/internal/server/models"
+	"time"
+)
+
+const (
+    // only the start of a page is read, the meta tags are in its head
+    maxPageSize  = 512 << 10
+    maxRedirects = 3
+    // pages fetched at the same time, the others wait
+    maxFetches = 8
+    // previews kept in memory, the oldest are dropped past it
+    maxCacheEntries = 1000
+    userAgent       = "TextualLinkPreview/1.0"
+)
+
+var (
+    ErrForbiddenAddress = errors.New("address not allowed")
+    ErrNoPreview        = errors.New("page without title nor description")
+)
+
+// ranges the Is* methods of net.IP don't cover: shared address space,
+// benchmarking, reserved and the NAT64 prefix that can map to any of them
+var blockedNetworks = parseNetworks(
+    "0.0.0.0/8",
+    "100.64.0.0/10",
+    "192.0.0.0/24",
+    "198.18.0.0/15",
+    "240.0.0.0/4",
+    "64:ff9b::/96",
+)
+
+// Fetcher reads the pages of the links to build their previews. It only
+// connects to public addresses, checked once the host is resolved so a
+// name can't point to the local network, and keeps the results for a while
+// so a link shared again is not fetched again
+type Fetcher struct {
+    client *http.Client
+    ttl    time.Duration
+    slots  chan struct{}
+    // the addresses it connects to, allowed unless a test reaches its own
+    // server
+    allow func(net.IP) bool
+
+    mu    sync.Mutex
+    cache map[string]*cached
+}
+
+// cached is a preview or the error fetching it, failures are kept too so a
+// broken link is not fetched on every message
+type cached struct {
+    preview *models.LinkPreview
+    err     error
+    expires time.Time
+}
+
+func NewFetcher(timeout, ttl time.Duration) *Fetcher {
+    f := &Fetcher{
+        ttl:   ttl,
+        slots: make(chan struct{}, maxFetches),
+        cache: make(map[string]*cached),
+        allow: allowed,
+    }
+    dialer := &net.Dialer{
+        Timeout: timeout,
+        Control: func(network, address string, _ syscall.RawConn) error {
+            host, _, err := net.SplitHostPort(address)
+            if err != nil {
+                return err
+            }
+            if ip := net.ParseIP(host); ip == nil || !f.allow(ip) {
+                return ErrForbiddenAddress
+            }
+            return nil
+        },
+    }
+    transport := &http.Transport{
+        // a proxy would connect in our place, out of the checks of the dialer
+        Proxy:                 nil,
+        DialContext:           dialer.DialContext,
+        TLSHandshakeTimeout:   timeout,
+        ResponseHeaderTimeout: timeout,
+        MaxIdleConns:          maxFetches,
+        IdleConnTimeout:       30 * time.Second,
+    }
+    f.client = &http.Client{
+        Transport: transport,
+        Timeout:   timeout,
+        CheckRedirect: func(req *http.Request, via []*http.Request) error {
+            if len(via) > maxRedirects {
+                return errors.New("too many redirects")
+            }
+            if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+                return fmt.Errorf("redirect to %s not allowed", req.URL.Scheme)
+            }
+            return nil
+        },
+    }
+    return f
+}
+
+// Fetch returns the preview of a page, from the cache when it was fetched
+// recently
+func (f *Fetcher) Fetch(ctx context.Context, link string) (*models.LinkPreview, error) {
+    f.mu.Lock()
+    entry, ok := f.cache[link]
+    f.mu.Unlock()
+    if ok && time.Now().Before(entry.expires) {
+        return entry.preview, entry.err
+    }
+
+    select {
+    case f.slots <- struct{}{}:
+        defer func() { <-f.slots }()
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+
+    preview, err := f.fetch(ctx, link)
+    // a cancelled fetch says nothing about the page
+    if ctx.Err() == nil {
+        f.store(link, &cached{preview: preview, err: err, expires: time.Now().Add(f.ttl)})
+    }
+    return preview, err
+}
+
+func (f *Fetcher) fetch(ctx context.Context, link string) (*models.LinkPreview, error) {
+    u, err := url.Parse(link)
+    if err != nil {
+        return nil, err
+    }
+    if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || u.User != nil {
+        return nil, fmt.Errorf("unsupported link %q", link)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("User-Agent", userAgent)
+    req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+    resp, err := f.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("status %s", resp.Status)
+    }
+    mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+    if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+        return nil, fmt.Errorf("not a page: %s", mediaType)
+    }
+
+    page, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+    if err != nil {
+        return nil, err
+    }
+    // the image is relative to the page after the redirects
+    preview := parsePage(string(page), resp.Request.URL)
+    if preview.Title == "" && preview.Description == "" {
+        return nil, ErrNoPreview
+    }
+    preview.URL = link
+    return preview, nil
+}
+
+// store keeps an entry, making room by dropping the expired ones then any
+func (f *Fetcher) store(link string, entry *cached) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if len(f.cache) >= maxCacheEntries {
+        now := time.Now()
+        for key, e := range f.cache {
+            if now.After(e.expires) {
+                delete(f.cache, key)
+            }
+        }
+        for key := range f.cache {
+            if len(f.cache) < maxCacheEntries {
+                break
+            }
+            delete(f.cache, key)
+        }
+    }
+    f.cache[link] = entry
+}
+
+// allowed tells if an address is on the internet, not on this host nor on
+// a private network
+func allowed(ip net.IP) bool {
+    if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+        ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+        ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+        return false
+    }
+    for _, network := range blockedNetworks {
+        if network.Contains(ip) {
+            return false
+        }
+    }
+    return true
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+    networks := make([]*net.IPNet, 0, len(cidrs))
+    for _, cidr := range cidrs {
+        _, network, err := net.ParseCIDR(cidr)
+        if err != nil {
+            panic(err)
+        }
+        networks = append(networks, network)
+    }
+    return networks
+}
@@ -0,0 +1,132 @@
+// internal/server/previews/fetcher_test.go
+package previews
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        ip   string
+        ok   bool
+    }{
+        {"loopback", "127.0.0.1", false},
+        {"loopback range", "127.8.9.10", false},
+        {"loopback v6", "::1", false},
+        {"unspecified", "0.0.0.0", false},
+        {"this network", "0.1.2.3", false},
+        {"private 10", "10.1.2.3", false},
+        {"private 172", "172.16.0.1", false},
+        {"private 192", "192.168.1.1", false},
+        {"unique local v6", "fd12:3456::1", false},
+        {"link local", "169.254.169.254", false},
+        {"link local v6", "fe80::1", false},
+        {"multicast", "224.0.0.1", false},
+        {"cgnat", "100.64.0.1", false},
+        {"cgnat end", "100.127.255.254", false},
+        {"benchmarking", "198.18.0.1", false},
+        {"reserved", "240.0.0.1", false},
+        {"nat64 loopback", "64:ff9b::7f00:1", false},
+        {"nat64 public", "64:ff9b::808:808", false},
+        {"mapped loopback", "::ffff:127.0.0.1", false},
+        {"mapped private", "::ffff:192.168.1.1", false},
+        {"mapped cgnat", "::ffff:100.64.0.1", false},
+        {"public", "93.184.216.34", true},
+        {"public next to cgnat", "100.128.0.1", true},
+        {"public v6", "2606:4700:4700::1111", true},
+        {"mapped public", "::ffff:93.184.216.34", true},
+    } {
+        ip := net.ParseIP(test.ip)
+        if ip == nil {
+            t.Fatalf("%s: bad address %s", test.name, test.ip)
+        }
+        if got := allowed(ip); got != test.ok {
+            t.Errorf("%s: allowed(%s) = %v, want %v", test.name, test.ip, got, test.ok)
+        }
+    }
+}
+
+func TestFetchRedirects(t *testing.T) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        w.Write([]byte(`<html><head><title>The page</title><meta property="og:image" content="/cover.png"></head></html>`))
+    })
+    mux.Handle("/moved", http.RedirectHandler("/page", http.StatusFound))
+    mux.Handle("/loop", http.RedirectHandler("/loop", http.StatusFound))
+    mux.Handle("/ftp", http.RedirectHandler("ftp://example.com/file", http.StatusFound))
+    mux.Handle("/internal", http.RedirectHandler("http://10.0.0.1/admin", http.StatusFound))
+    mux.Handle("/metadata", http.RedirectHandler("http://[::ffff:169.254.169.254]/latest", http.StatusFound))
+    mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "image/png")
+        w.Write([]byte("png"))
+    })
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    // the test server is on this host: only its address is let through,
+    // the redirects are still checked by allowed
+    host, _, _ := net.SplitHostPort(server.Listener.Addr().String())
+    local := net.ParseIP(host)
+
+    for _, test := range []struct {
+        path  string
+        title string
+        err   string
+        is    error
+    }{
+        {path: "/page", title: "The page"},
+        {path: "/moved", title: "The page"},
+        {path: "/loop", err: "too many redirects"},
+        {path: "/ftp", err: "redirect to ftp not allowed"},
+        {path: "/internal", is: ErrForbiddenAddress},
+        {path: "/metadata", is: ErrForbiddenAddress},
+        {path: "/image", err: "not a page"},
+    } {
+        t.Run(test.path, func(t *testing.T) {
+            f := NewFetcher(2*time.Second, time.Minute)
+            f.allow = func(ip net.IP) bool { return ip.Equal(local) || allowed(ip) }
+
+            link := server.URL + test.path
+            preview, err := f.Fetch(context.Background(), link)
+            switch {
+            case test.is != nil:
+                if !errors.Is(err, test.is) {
+                    t.Fatalf("Fetch: %v, want %v", err, test.is)
+                }
+            case test.err != "":
+                if err == nil || !strings.Contains(err.Error(), test.err) {
+                    t.Fatalf("Fetch: %v, want %q", err, test.err)
+                }
+            default:
+                if err != nil {
+                    t.Fatal(err)
+                }
+                // the image is relative to the page the redirects ended on
+                if preview.Title != test.title || preview.URL != link || preview.ImageURL != server.URL+"/cover.png" {
+                    t.Fatalf("preview %+v", preview)
+                }
+            }
+        })
+    }
+}
+
+func TestFetchForbidsLocalServer(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        t.Error("the fetcher reached a server on this host")
+    }))
+    defer server.Close()
+
+    _, err := NewFetcher(2*time.Second, time.Minute).Fetch(context.Background(), server.URL)
+    if !errors.Is(err, ErrForbiddenAddress) {
+        t.Fatalf("Fetch: %v, want %v", err, ErrForbiddenAddress)
+    }
+}
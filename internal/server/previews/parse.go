@@ -0,0 +1,89 @@
+// internal/server/previews/parse.go
+package previews
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"textual/internal/server/models"
+	"unicode/utf8"
+)
+
+// longest texts kept in a preview, in characters
+const (
+    maxTitleLength       = 200
+    maxDescriptionLength = 300
+    maxSiteNameLength    = 100
+)
+
+var (
+    linkPattern  = regexp.MustCompile(`https?://[^\s<>"]+`)
+    titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+    metaPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+    attrPattern  = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// FirstURL returns the first link of a message, without the punctuation that
+// usually ends a sentence
+func FirstURL(content string) string {
+    link := linkPattern.FindString(content)
+    return strings.TrimRight(link, ".,;:!?)]'")
+}
+
+// parsePage reads the title, description, image and site name of a page,
+// the Open Graph tags first
+func parsePage(page string, base *url.URL) *models.LinkPreview {
+    meta := make(map[string]string)
+    for _, tag := range metaPattern.FindAllString(page, -1) {
+        attrs := make(map[string]string)
+        for _, attr := range attrPattern.FindAllStringSubmatch(tag, -1) {
+            attrs[strings.ToLower(attr[1])] = attr[2] + attr[3] + attr[4]
+        }
+        name := attrs["property"]
+        if name == "" {
+            name = attrs["name"]
+        }
+        name = strings.ToLower(name)
+        if _, seen := meta[name]; name != "" && !seen {
+            meta[name] = attrs["content"]
+        }
+    }
+
+    preview := &models.LinkPreview{
+        Title:       clean(first(meta["og:title"], meta["twitter:title"]), maxTitleLength),
+        Description: clean(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+        SiteName:    clean(meta["og:site_name"], maxSiteNameLength),
+    }
+    if preview.Title == "" {
+        if match := titlePattern.FindStringSubmatch(page); match != nil {
+            preview.Title = clean(match[1], maxTitleLength)
+        }
+    }
+    if image := strings.TrimSpace(html.UnescapeString(first(meta["og:image"], meta["twitter:image"]))); image != "" {
+        if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+            preview.ImageURL = u.String()
+        }
+    }
+    return preview
+}
+
+func first(values ...string) string {
+    for _, value := range values {
+        if strings.TrimSpace(value) != "" {
+            return value
+        }
+    }
+    return ""
+}
+
+// clean decodes the entities, joins the lines and cuts the text to max
+// characters
+func clean(text string, max int) string {
+    text = strings.ToValidUTF8(html.UnescapeString(text), "")
+    text = strings.Join(strings.Fields(text), " ")
+    if utf8.RuneCountInString(text) > max {
+        text = string([]rune(text)[:max-1]) + "…"
+    }
+    return text
+}
@@ -0,0 +1,105 @@
+// internal/server/previews/parse_test.go
+package previews
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"textual/internal/server/models"
+)
+
+func TestParsePage(t *testing.T) {
+    base, _ := url.Parse("https://example.com/news/article")
+    for _, test := range []struct {
+        name string
+        page string
+        want models.LinkPreview
+    }{
+        {
+            name: "open graph",
+            page: `<head><title>Page title</title>
+                <meta property="og:title" content="OG title">
+                <meta property="og:description" content="OG description">
+                <meta property="og:site_name" content="Example">
+                <meta property="og:image" content="https://cdn.example.com/a.png"></head>`,
+            want: models.LinkPreview{Title: "OG title", Description: "OG description", SiteName: "Example", ImageURL: "https://cdn.example.com/a.png"},
+        },
+        {
+            name: "title tag",
+            page: `<html><head><TITLE lang="en">  The
+                title  </TITLE><meta name="description" content="Plain description"></head></html>`,
+            want: models.LinkPreview{Title: "The title", Description: "Plain description"},
+        },
+        {
+            name: "twitter fallback",
+            page: `<meta name="twitter:title" content="Tweet title"><meta name="twitter:description" content='Single quoted'>
+                <meta name="twitter:image" content="/img.png">`,
+            want: models.LinkPreview{Title: "Tweet title", Description: "Single quoted", ImageURL: "https://example.com/img.png"},
+        },
+        {
+            name: "og first",
+            page: `<meta name="description" content="plain"><meta property="og:description" content="og">`,
+            want: models.LinkPreview{Description: "og"},
+        },
+        {
+            name: "first tag kept",
+            page: `<meta property="og:title" content="First"><meta property="og:title" content="Second">`,
+            want: models.LinkPreview{Title: "First"},
+        },
+        {
+            name: "entities",
+            page: `<meta property="og:title" content="Tom &amp; Jerry &#8212; &quot;live&quot;">`,
+            want: models.LinkPreview{Title: `Tom & Jerry — "live"`},
+        },
+        {
+            name: "attributes in any order and case",
+            page: `<META CONTENT="Reversed" PROPERTY="OG:TITLE">`,
+            want: models.LinkPreview{Title: "Reversed"},
+        },
+        {
+            name: "relative image",
+            page: `<meta property="og:title" content="x"><meta property="og:image" content="../cover.jpg">`,
+            want: models.LinkPreview{Title: "x", ImageURL: "https://example.com/cover.jpg"},
+        },
+        {
+            name: "script image",
+            page: `<meta property="og:title" content="x"><meta property="og:image" content="javascript:alert(1)">`,
+            want: models.LinkPreview{Title: "x"},
+        },
+        {
+            name: "empty og title",
+            page: `<title>Fallback</title><meta property="og:title" content="  ">`,
+            want: models.LinkPreview{Title: "Fallback"},
+        },
+        {
+            name: "nothing",
+            page: `<html><body>no head</body></html>`,
+        },
+    } {
+        if got := parsePage(test.page, base); *got != test.want {
+            t.Errorf("%s: parsePage = %+v, want %+v", test.name, *got, test.want)
+        }
+    }
+}
+
+func TestParsePageCuts(t *testing.T) {
+    base, _ := url.Parse("https://example.com/")
+    page := `<meta property="og:title" content="` + strings.Repeat("é", maxTitleLength+10) + `">`
+    title := parsePage(page, base).Title
+    if n := len([]rune(title)); n != maxTitleLength || !strings.HasSuffix(title, "…") {
+        t.Errorf("title of %d characters: %q", n, title)
+    }
+}
+
+func TestFirstURL(t *testing.T) {
+    for in, want := range map[string]string{
+        "see https://example.com/a.":            "https://example.com/a",
+        "(http://example.com/b)":                "http://example.com/b",
+        "no link here":                          "",
+        "two https://a.example https://b.example": "https://a.example",
+    } {
+        if got := FirstURL(in); got != want {
+            t.Errorf("FirstURL(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
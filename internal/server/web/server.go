@@ -0,0 +1,71 @@
+// internal/server/web/server.go
+package web
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+//go:embed static
+var static embed.FS
+
+// Server serves the web client and the WebSocket endpoint it connects to.
+// The sockets speak the TCP protocol, one JSON message per frame, and are
+// handed to the same handling as the TCP connections
+type Server struct {
+    http *http.Server
+}
+
+func NewServer(handle func(net.Conn)) *Server {
+    assets, err := fs.Sub(static, "static")
+    if err != nil {
+        panic(err)
+    }
+
+    mux := http.NewServeMux()
+    mux.Handle("GET /", http.FileServer(http.FS(assets)))
+    // no cookie nor session is involved, the socket authenticates with the
+    // auth message like a TCP client, so any origin may connect
+    mux.HandleFunc("GET /ws", func(w http.ResponseWriter, r *http.Request) {
+        conn, err := Upgrade(w, r)
+        if err != nil {
+            log.Printf("WebSocket upgrade error: %v", err)
+            return
+        }
+        handle(conn)
+    })
+
+    return &Server{http: &http.Server{
+        Handler:           mux,
+        ReadHeaderTimeout: 10 * time.Second,
+    }}
+}
+
+// Start listens on the port and serves in the background
+func (s *Server) Start(port string) error {
+    listener, err := net.Listen("tcp", ":"+port)
+    if err != nil {
+        return err
+    }
+
+    log.Printf("Web client started on port %s", port)
+    go func() {
+        if err := s.http.Serve(listener); err != nil && err != http.ErrServerClosed {
+            log.Printf("Web client error: %v", err)
+        }
+    }()
+    return nil
+}
+
+// Stop closes the listener, the sockets already open are not tracked by the
+// HTTP server and end with the process
+func (s *Server) Stop() {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    s.http.Shutdown(ctx)
+}
@@ -0,0 +1,146 @@
+// minimal web client: the global chat over the WebSocket endpoint, which
+// speaks the same JSON messages as the TCP protocol
+(function () {
+  "use strict";
+
+  const $ = (id) => document.getElementById(id);
+  const pageSize = 50;
+  let socket = null;
+  let userID = "";
+  let oldestID = "";
+  const shown = new Set();
+
+  function send(type, payload) {
+    socket.send(JSON.stringify({ type: type, payload: payload, timestamp: Math.floor(Date.now() / 1000) }));
+  }
+
+  function setStatus(text) {
+    $("status").textContent = text;
+  }
+
+  // the history has RFC 3339 dates, the live messages unix seconds
+  function sentAt(value) {
+    const date = typeof value === "number" ? new Date(value * 1000) : new Date(value);
+    return isNaN(date) ? "" : date.toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+  }
+
+  function isGlobal(msg) {
+    return !msg.recipient_id && !msg.group_id && !msg.thread_root_id;
+  }
+
+  function render(msg) {
+    const item = document.createElement("li");
+    if (msg.sender_id === userID) {
+      item.className = "own";
+    }
+    const time = document.createElement("span");
+    time.className = "time";
+    time.textContent = sentAt(msg.sent_at) + " ";
+    const sender = document.createElement("span");
+    sender.className = "sender";
+    sender.textContent = msg.sender_name || "?";
+    item.append(time, sender, ": " + msg.content);
+    return item;
+  }
+
+  // messages come newest first in the pages, older ones go on top
+  function addMessages(messages, older) {
+    const list = $("messages");
+    const atBottom = list.scrollTop + list.clientHeight >= list.scrollHeight - 4;
+    const items = [];
+    for (const msg of messages) {
+      if (!isGlobal(msg) || shown.has(msg.id)) {
+        continue;
+      }
+      shown.add(msg.id);
+      items.push(render(msg));
+      if (older) {
+        oldestID = msg.id;
+      }
+    }
+    if (older) {
+      const height = list.scrollHeight;
+      list.prepend(...items);
+      list.scrollTop += list.scrollHeight - height;
+    } else {
+      list.append(...items);
+      if (atBottom) {
+        list.scrollTop = list.scrollHeight;
+      }
+    }
+  }
+
+  function handle(msg) {
+    const payload = msg.payload || {};
+    switch (msg.type) {
+      case "auth_response":
+        userID = payload.user_id;
+        setStatus("connected as " + payload.username);
+        $("login").hidden = true;
+        $("chat").hidden = false;
+        $("content").focus();
+        break;
+      case "message_history":
+        if (payload.recipient_id || payload.group_id) {
+          break;
+        }
+        const messages = payload.messages || [];
+        if (payload.before_id || !oldestID) {
+          addMessages(messages, true);
+        }
+        $("older").hidden = messages.length < pageSize && !!payload.before_id;
+        break;
+      case "global_message":
+        addMessages([payload], false);
+        break;
+      case "error":
+        const text = payload.message || payload.error || "error";
+        if (!userID) {
+          $("login-error").textContent = text;
+        } else {
+          setStatus("error: " + text);
+        }
+        break;
+    }
+  }
+
+  function connect(username, password) {
+    const scheme = location.protocol === "https:" ? "wss://" : "ws://";
+    socket = new WebSocket(scheme + location.host + "/ws");
+    setStatus("connecting...");
+    socket.onopen = () => send("auth", { username: username, password: password });
+    // a frame holds a message per line, several when the server had a backlog
+    socket.onmessage = (event) => {
+      for (const line of event.data.split("\n")) {
+        if (line) handle(JSON.parse(line));
+      }
+    };
+    socket.onclose = () => {
+      setStatus("disconnected");
+      if (!userID && !$("login-error").textContent) {
+        $("login-error").textContent = "Connection closed";
+      }
+    };
+  }
+
+  $("login").addEventListener("submit", (event) => {
+    event.preventDefault();
+    $("login-error").textContent = "";
+    connect($("username").value.trim(), $("password").value);
+  });
+
+  $("composer").addEventListener("submit", (event) => {
+    event.preventDefault();
+    const content = $("content").value.trim();
+    if (!content || !socket || socket.readyState !== WebSocket.OPEN) {
+      return;
+    }
+    const clientID = "web-" + Date.now().toString(36) + Math.random().toString(36).slice(2);
+    send("global_message", { content: content, client_id: clientID });
+    $("content").value = "";
+  });
+
+  $("older").addEventListener("click", () => {
+    send("load_messages", { before_id: oldestID, limit: pageSize });
+  });
+})();
@@ -0,0 +1,226 @@
+// internal/server/web/websocket.go
+package web
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+    opContinuation = 0x0
+    opText         = 0x1
+    opBinary       = 0x2
+    opClose        = 0x8
+    opPing         = 0x9
+    opPong         = 0xA
+
+    // the GUID of RFC 6455, hashed with the key of the client
+    acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+    // longest frame accepted, a message is a few kilobytes
+    maxFrameSize = 1 << 20
+)
+
+var errProtocol = errors.New("websocket protocol error")
+
+// Conn is a WebSocket connection seen as the stream of the TCP protocol: the
+// data frames are read one after the other, each write is sent as a text
+// frame, holding one message per line. The handlers, which read and write JSON on a net.Conn, run over it
+// unchanged
+type Conn struct {
+    net.Conn
+    reader *bufio.Reader
+
+    // what is left of the data frame being read
+    remaining int64
+    mask      [4]byte
+    maskPos   int
+
+    writeMu sync.Mutex
+    closed  bool
+}
+
+// Upgrade answers the handshake of a WebSocket request and takes over its
+// connection
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+    key := r.Header.Get("Sec-WebSocket-Key")
+    if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+        http.Error(w, "WebSocket connection expected", http.StatusBadRequest)
+        return nil, errors.New("not a websocket handshake")
+    }
+    if r.Header.Get("Sec-WebSocket-Version") != "13" {
+        w.Header().Set("Sec-WebSocket-Version", "13")
+        http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+        return nil, errors.New("unsupported websocket version")
+    }
+
+    hijacker, ok := w.(http.Hijacker)
+    if !ok {
+        http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+        return nil, errors.New("connection can't be hijacked")
+    }
+    conn, rw, err := hijacker.Hijack()
+    if err != nil {
+        return nil, err
+    }
+    // the deadlines of the HTTP server stay on the connection
+    conn.SetDeadline(time.Time{})
+
+    sum := sha1.Sum([]byte(key + acceptGUID))
+    fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+        base64.StdEncoding.EncodeToString(sum[:]))
+    if err := rw.Flush(); err != nil {
+        conn.Close()
+        return nil, err
+    }
+    return &Conn{Conn: conn, reader: rw.Reader}, nil
+}
+
+func headerContains(header http.Header, name, token string) bool {
+    for _, value := range header.Values(name) {
+        for _, part := range strings.Split(value, ",") {
+            if strings.EqualFold(strings.TrimSpace(part), token) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// Read returns the payload of the data frames, answering the control frames
+// met between them
+func (c *Conn) Read(p []byte) (int, error) {
+    for c.remaining == 0 {
+        if err := c.nextFrame(); err != nil {
+            return 0, err
+        }
+    }
+
+    if int64(len(p)) > c.remaining {
+        p = p[:c.remaining]
+    }
+    n, err := c.reader.Read(p)
+    for i := 0; i < n; i++ {
+        p[i] ^= c.mask[c.maskPos]
+        c.maskPos = (c.maskPos + 1) % 4
+    }
+    c.remaining -= int64(n)
+    return n, err
+}
+
+// nextFrame reads a frame header, and the whole frame when it is a control
+// frame
+func (c *Conn) nextFrame() error {
+    var header [2]byte
+    if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+        return err
+    }
+    opcode := header[0] & 0x0F
+    // the frames of the clients are always masked
+    if header[1]&0x80 == 0 {
+        return errProtocol
+    }
+
+    length := int64(header[1] & 0x7F)
+    switch length {
+    case 126:
+        var ext [2]byte
+        if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+            return err
+        }
+        length = int64(binary.BigEndian.Uint16(ext[:]))
+    case 127:
+        var ext [8]byte
+        if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+            return err
+        }
+        length = int64(binary.BigEndian.Uint64(ext[:]))
+    }
+    if length < 0 || length > maxFrameSize {
+        return errProtocol
+    }
+    if _, err := io.ReadFull(c.reader, c.mask[:]); err != nil {
+        return err
+    }
+    c.maskPos = 0
+
+    switch opcode {
+    case opText, opBinary, opContinuation:
+        c.remaining = length
+        return nil
+    case opClose, opPing, opPong:
+        if length > 125 {
+            return errProtocol
+        }
+    default:
+        return errProtocol
+    }
+
+    payload := make([]byte, length)
+    if _, err := io.ReadFull(c.reader, payload); err != nil {
+        return err
+    }
+    for i := range payload {
+        payload[i] ^= c.mask[i%4]
+    }
+    switch opcode {
+    case opPing:
+        return c.writeFrame(opPong, payload)
+    case opClose:
+        c.writeFrame(opClose, nil)
+        return io.EOF
+    }
+    return nil
+}
+
+// Write sends p as one text frame, a batch of messages of the server included
+func (c *Conn) Write(p []byte) (int, error) {
+    if err := c.writeFrame(opText, p); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+    c.writeMu.Lock()
+    defer c.writeMu.Unlock()
+    if c.closed {
+        return net.ErrClosed
+    }
+
+    header := make([]byte, 2, 10)
+    header[0] = 0x80 | opcode
+    switch n := len(payload); {
+    case n < 126:
+        header[1] = byte(n)
+    case n <= 0xFFFF:
+        header[1] = 126
+        header = binary.BigEndian.AppendUint16(header, uint16(n))
+    default:
+        header[1] = 127
+        header = binary.BigEndian.AppendUint64(header, uint64(n))
+    }
+    if _, err := c.Conn.Write(append(header, payload...)); err != nil {
+        return err
+    }
+    if opcode == opClose {
+        c.closed = true
+    }
+    return nil
+}
+
+// Close says goodbye to the browser before closing the connection
+func (c *Conn) Close() error {
+    c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+    c.writeFrame(opClose, nil)
+    return c.Conn.Close()
+}
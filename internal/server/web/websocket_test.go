@@ -0,0 +1,263 @@
+// internal/server/web/websocket_test.go
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connPair returns the server side of a TCP connection, past the handshake,
+// and the client side to write raw frames on
+func connPair(t *testing.T) (*Conn, net.Conn) {
+    t.Helper()
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+
+    client, err := net.Dial("tcp", ln.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    server, err := ln.Accept()
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        client.Close()
+        server.Close()
+    })
+    deadline := time.Now().Add(5 * time.Second)
+    client.SetDeadline(deadline)
+    server.SetDeadline(deadline)
+    return &Conn{Conn: server, reader: bufio.NewReader(server)}, client
+}
+
+// clientFrame builds a frame as a browser sends it, masked
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+    frame := []byte{opcode, 0x80}
+    if fin {
+        frame[0] |= 0x80
+    }
+    switch n := len(payload); {
+    case n < 126:
+        frame[1] |= byte(n)
+    case n <= 0xFFFF:
+        frame[1] |= 126
+        frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+    default:
+        frame[1] |= 127
+        frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+    }
+    mask := [4]byte{0x37, 0xfa, 0x21, 0x3d}
+    frame = append(frame, mask[:]...)
+    for i, b := range payload {
+        frame = append(frame, b^mask[i%4])
+    }
+    return frame
+}
+
+// readServerFrame reads a frame of the server, which are never masked
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+    t.Helper()
+    var header [2]byte
+    if _, err := io.ReadFull(r, header[:]); err != nil {
+        t.Fatalf("read frame: %v", err)
+    }
+    if header[0]&0x80 == 0 {
+        t.Fatal("server frame without FIN")
+    }
+    if header[1]&0x80 != 0 {
+        t.Fatal("server frame masked")
+    }
+    length := uint64(header[1] & 0x7F)
+    switch length {
+    case 126:
+        var ext [2]byte
+        io.ReadFull(r, ext[:])
+        length = uint64(binary.BigEndian.Uint16(ext[:]))
+    case 127:
+        var ext [8]byte
+        io.ReadFull(r, ext[:])
+        length = binary.BigEndian.Uint64(ext[:])
+    }
+    payload := make([]byte, length)
+    if _, err := io.ReadFull(r, payload); err != nil {
+        t.Fatalf("read payload: %v", err)
+    }
+    return header[0] & 0x0F, payload
+}
+
+func TestUpgradeAccept(t *testing.T) {
+    upgraded := make(chan error, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := Upgrade(w, r)
+        upgraded <- err
+        if err == nil {
+            conn.Close()
+        }
+    }))
+    defer srv.Close()
+
+    conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(5 * time.Second))
+    // the sample handshake of RFC 6455
+    io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+        "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+    reader := bufio.NewReader(conn)
+    resp, err := http.ReadResponse(reader, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := <-upgraded; err != nil {
+        t.Fatalf("upgrade: %v", err)
+    }
+    if resp.StatusCode != http.StatusSwitchingProtocols {
+        t.Fatalf("status = %d, want 101", resp.StatusCode)
+    }
+    if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+        t.Errorf("Sec-WebSocket-Accept = %q", got)
+    }
+    if opcode, _ := readServerFrame(t, reader); opcode != opClose {
+        t.Errorf("opcode on close = %#x, want a close frame", opcode)
+    }
+}
+
+func TestUpgradeRejected(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if conn, err := Upgrade(w, r); err == nil {
+            conn.Close()
+        }
+    }))
+    defer srv.Close()
+
+    for _, test := range []struct {
+        name    string
+        headers map[string]string
+        status  int
+    }{
+        {"plain request", nil, http.StatusBadRequest},
+        {"no key", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+        {"old version", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+    } {
+        req, _ := http.NewRequest("GET", srv.URL, nil)
+        for name, value := range test.headers {
+            req.Header.Set(name, value)
+        }
+        resp, err := http.DefaultClient.Do(req)
+        if err != nil {
+            t.Fatalf("%s: %v", test.name, err)
+        }
+        resp.Body.Close()
+        if resp.StatusCode != test.status {
+            t.Errorf("%s: status = %d, want %d", test.name, resp.StatusCode, test.status)
+        }
+    }
+}
+
+func TestReadFrames(t *testing.T) {
+    conn, client := connPair(t)
+    long := bytes.Repeat([]byte("0123456789"), 30)
+    huge := bytes.Repeat([]byte("x"), 70000)
+
+    var frames []byte
+    frames = append(frames, clientFrame(true, opText, []byte(`{"type":"ping"}`))...)
+    frames = append(frames, clientFrame(true, opText, long)...)
+    frames = append(frames, clientFrame(true, opBinary, huge)...)
+    // a message in fragments reads as one stream
+    frames = append(frames, clientFrame(false, opText, []byte("frag"))...)
+    frames = append(frames, clientFrame(true, opContinuation, []byte("ment"))...)
+    go client.Write(frames)
+
+    want := append(append(append([]byte(`{"type":"ping"}`), long...), huge...), "fragment"...)
+    got := make([]byte, len(want))
+    if _, err := io.ReadFull(conn, got); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(got, want) {
+        t.Error("the payloads were not unmasked")
+    }
+}
+
+func TestReadAnswersPing(t *testing.T) {
+    conn, client := connPair(t)
+    frames := append(clientFrame(true, opPing, []byte("are you there")), clientFrame(true, opText, []byte("data"))...)
+    client.Write(frames)
+
+    buf := make([]byte, 16)
+    n, err := conn.Read(buf)
+    if err != nil || string(buf[:n]) != "data" {
+        t.Fatalf("read = %q, %v", buf[:n], err)
+    }
+    opcode, payload := readServerFrame(t, client)
+    if opcode != opPong || string(payload) != "are you there" {
+        t.Errorf("answer = %#x %q, want a pong with the ping payload", opcode, payload)
+    }
+}
+
+func TestReadClose(t *testing.T) {
+    conn, client := connPair(t)
+    client.Write(clientFrame(true, opClose, []byte{0x03, 0xe8}))
+
+    if _, err := conn.Read(make([]byte, 16)); err != io.EOF {
+        t.Fatalf("read after close = %v, want EOF", err)
+    }
+    if opcode, _ := readServerFrame(t, client); opcode != opClose {
+        t.Errorf("answer = %#x, want a close frame", opcode)
+    }
+    if _, err := conn.Write([]byte("late")); !errors.Is(err, net.ErrClosed) {
+        t.Errorf("write after close = %v, want %v", err, net.ErrClosed)
+    }
+}
+
+func TestReadInvalidFrames(t *testing.T) {
+    tooLong := []byte{0x80 | opBinary, 0x80 | 127}
+    tooLong = binary.BigEndian.AppendUint64(tooLong, maxFrameSize+1)
+    tooLong = append(tooLong, 0, 0, 0, 0)
+
+    unmasked := clientFrame(true, opText, []byte("hi"))
+    unmasked[1] &^= 0x80
+
+    for _, test := range []struct {
+        name  string
+        frame []byte
+    }{
+        {"unmasked", unmasked},
+        {"too long", tooLong},
+        {"long control frame", clientFrame(true, opPing, bytes.Repeat([]byte("p"), 126))},
+        {"unknown opcode", clientFrame(true, 0x3, []byte("hi"))},
+    } {
+        conn, client := connPair(t)
+        client.Write(test.frame)
+        if _, err := conn.Read(make([]byte, 16)); !errors.Is(err, errProtocol) {
+            t.Errorf("%s: err = %v, want %v", test.name, err, errProtocol)
+        }
+    }
+}
+
+func TestWriteFrames(t *testing.T) {
+    conn, client := connPair(t)
+    for _, size := range []int{0, 125, 126, 300, 0xFFFF, 70000} {
+        payload := bytes.Repeat([]byte("y"), size)
+        go conn.Write(payload)
+        opcode, got := readServerFrame(t, client)
+        if opcode != opText || !bytes.Equal(got, payload) {
+            t.Errorf("size %d: frame %#x of %d bytes", size, opcode, len(got))
+        }
+    }
+}
@@ -0,0 +1,210 @@
+// internal/testutil/client.go
+package testutil
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"textual/pkg/protocol"
+)
+
+// Timeout is how long a client waits for an expected message
+const Timeout = 5 * time.Second
+
+// Message is a message received, its payload decoded on demand
+type Message struct {
+    Type      protocol.MessageType `json:"type"`
+    Payload   json.RawMessage      `json:"payload"`
+    Timestamp int64                `json:"timestamp"`
+}
+
+// Decode decodes the payload into v
+func (m Message) Decode(v interface{}) error {
+    return json.Unmarshal(m.Payload, v)
+}
+
+// Client is a scripted client speaking the protocol as the real one. The
+// messages of the server are kept until a test expects them, in the order
+// they came, so a test only states the messages it cares about
+type Client struct {
+    t        testing.TB
+    conn     net.Conn
+    ID       string
+    Username string
+
+    mu       sync.Mutex
+    received []Message
+    // signaled for each message read, closed with the connection
+    arrived chan struct{}
+    err     error
+}
+
+// Dial connects to the server without logging in
+func Dial(t testing.TB, addr string) *Client {
+    t.Helper()
+    conn, err := net.DialTimeout("tcp", addr, Timeout)
+    if err != nil {
+        t.Fatalf("failed to connect to %s: %v", addr, err)
+    }
+    c := &Client{t: t, conn: conn, arrived: make(chan struct{}, 1)}
+    t.Cleanup(func() { conn.Close() })
+    go c.read()
+    return c
+}
+
+func (c *Client) read() {
+    dec := json.NewDecoder(c.conn)
+    for {
+        var msg Message
+        err := dec.Decode(&msg)
+        c.mu.Lock()
+        if err != nil {
+            c.err = err
+            c.mu.Unlock()
+            close(c.arrived)
+            return
+        }
+        c.received = append(c.received, msg)
+        c.mu.Unlock()
+        select {
+        case c.arrived <- struct{}{}:
+        default:
+        }
+    }
+}
+
+// Login authenticates the client and fails the test if it is refused
+func (c *Client) Login(username, password string) protocol.AuthResponsePayload {
+    c.t.Helper()
+    c.Send(protocol.TypeAuth, protocol.AuthPayload{Username: username, Password: password})
+    var response protocol.AuthResponsePayload
+    msg := c.ExpectFunc(func(m Message) bool {
+        return m.Type == protocol.TypeAuthResponse || m.Type == protocol.TypeError
+    })
+    if msg.Type == protocol.TypeError {
+        c.t.Fatalf("login of %s refused: %s", username, msg.Payload)
+    }
+    c.decode(msg, &response)
+    c.ID, c.Username = response.UserID, response.Username
+    return response
+}
+
+// Send writes a message to the server
+func (c *Client) Send(msgType protocol.MessageType, payload interface{}) {
+    c.t.Helper()
+    c.conn.SetWriteDeadline(time.Now().Add(Timeout))
+    if err := json.NewEncoder(c.conn).Encode(protocol.NewMessage(msgType, payload)); err != nil {
+        c.t.Fatalf("%s failed to send %s: %v", c.name(), msgType, err)
+    }
+}
+
+// Expect waits for a message of the type and decodes its payload into v,
+// unless v is nil
+func (c *Client) Expect(msgType protocol.MessageType, v interface{}) Message {
+    c.t.Helper()
+    msg := c.ExpectFunc(func(m Message) bool { return m.Type == msgType })
+    if v != nil {
+        c.decode(msg, v)
+    }
+    return msg
+}
+
+// ExpectFunc waits for the first message matching, the others are kept for
+// the next expectations
+func (c *Client) ExpectFunc(match func(Message) bool) Message {
+    c.t.Helper()
+    msg, ok := c.wait(match, Timeout)
+    if !ok {
+        c.t.Fatalf("%s: expected message not received in %s, got %s", c.name(), Timeout, c.types())
+    }
+    return msg
+}
+
+// ExpectError waits for an error and returns its payload
+func (c *Client) ExpectError() protocol.ErrorPayload {
+    c.t.Helper()
+    var payload protocol.ErrorPayload
+    c.Expect(protocol.TypeError, &payload)
+    return payload
+}
+
+// ExpectNone fails the test if a message of the type arrives within wait
+func (c *Client) ExpectNone(msgType protocol.MessageType, wait time.Duration) {
+    c.t.Helper()
+    if msg, ok := c.wait(func(m Message) bool { return m.Type == msgType }, wait); ok {
+        c.t.Fatalf("%s: unexpected %s: %s", c.name(), msgType, msg.Payload)
+    }
+}
+
+// ExpectClosed waits for the server to close the connection
+func (c *Client) ExpectClosed() {
+    c.t.Helper()
+    c.wait(func(Message) bool { return false }, Timeout)
+    c.mu.Lock()
+    closed := c.err != nil
+    c.mu.Unlock()
+    if !closed {
+        c.t.Fatalf("%s: connection still open after %s", c.name(), Timeout)
+    }
+}
+
+// Close disconnects the client
+func (c *Client) Close() {
+    c.conn.Close()
+}
+
+// wait removes and returns the first message matching, waiting for it until
+// the timeout or the end of the connection
+func (c *Client) wait(match func(Message) bool, timeout time.Duration) (Message, bool) {
+    deadline := time.NewTimer(timeout)
+    defer deadline.Stop()
+    for {
+        c.mu.Lock()
+        for i, msg := range c.received {
+            if match(msg) {
+                c.received = append(c.received[:i], c.received[i+1:]...)
+                c.mu.Unlock()
+                return msg, true
+            }
+        }
+        closed := c.err != nil
+        c.mu.Unlock()
+        if closed {
+            return Message{}, false
+        }
+
+        select {
+        case <-c.arrived:
+        case <-deadline.C:
+            return Message{}, false
+        }
+    }
+}
+
+func (c *Client) decode(msg Message, v interface{}) {
+    c.t.Helper()
+    if err := msg.Decode(v); err != nil {
+        c.t.Fatalf("%s: invalid %s payload %s: %v", c.name(), msg.Type, msg.Payload, err)
+    }
+}
+
+// types lists the messages received and not expected, for the failures
+func (c *Client) types() []protocol.MessageType {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    types := make([]protocol.MessageType, 0, len(c.received))
+    for _, msg := range c.received {
+        types = append(types, msg.Type)
+    }
+    return types
+}
+
+func (c *Client) name() string {
+    if c.Username == "" {
+        return c.conn.LocalAddr().String()
+    }
+    return c.Username
+}
@@ -0,0 +1,104 @@
+// internal/testutil/db.go
+package testutil
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"textual/internal/server/database"
+
+	"github.com/lib/pq"
+)
+
+// DatabaseEnv names the connection string of a Postgres server to run the
+// integration tests on, they run on the in-memory store when it is not set
+const DatabaseEnv = "TEXTUAL_TEST_DATABASE"
+
+// the migrations filling the tables with sample accounts, a test starts empty
+var skippedMigrations = map[string]bool{
+    "002_test_data.sql": true,
+}
+
+// NewDB returns an empty store for a test: in memory, or with DatabaseEnv
+// set a database with the schema of the server, in a schema of its own
+// dropped at the end of the test: the tests run in parallel on the same
+// server without seeing each other
+func NewDB(t testing.TB) database.Store {
+    t.Helper()
+    connStr := os.Getenv(DatabaseEnv)
+    if connStr == "" {
+        return database.NewMemory()
+    }
+    if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+        var err error
+        if connStr, err = pq.ParseURL(connStr); err != nil {
+            t.Fatalf("invalid %s: %v", DatabaseEnv, err)
+        }
+    }
+
+    admin, err := sql.Open("postgres", connStr)
+    if err != nil {
+        t.Fatalf("failed to open the test database: %v", err)
+    }
+    id := make([]byte, 6)
+    if _, err := rand.Read(id); err != nil {
+        t.Fatal(err)
+    }
+    schema := "textual_test_" + hex.EncodeToString(id)
+    if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+        admin.Close()
+        t.Fatalf("failed to create the test schema: %v", err)
+    }
+    t.Cleanup(func() {
+        if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+            t.Logf("failed to drop the test schema %s: %v", schema, err)
+        }
+        admin.Close()
+    })
+
+    // public stays on the path for the extensions already installed there
+    db, err := database.Open(fmt.Sprintf("%s search_path=%s,public", connStr, schema))
+    if err != nil {
+        t.Fatalf("failed to connect to the test schema: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    if err := migrate(db); err != nil {
+        t.Fatal(err)
+    }
+    return db
+}
+
+// migrate applies the migrations of the server in order
+func migrate(db *database.DB) error {
+    _, file, _, _ := runtime.Caller(0)
+    dir := filepath.Join(filepath.Dir(file), "..", "server", "database", "migrations")
+    files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+    if err != nil || len(files) == 0 {
+        return fmt.Errorf("no migration found in %s", dir)
+    }
+    sort.Strings(files)
+
+    for _, path := range files {
+        if skippedMigrations[filepath.Base(path)] {
+            continue
+        }
+        script, err := os.ReadFile(path)
+        if err != nil {
+            return err
+        }
+        // without arguments, the statements of a file run in one exec
+        if _, err := db.DB.Exec(string(script)); err != nil {
+            return fmt.Errorf("migration %s failed: %v", filepath.Base(path), err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,65 @@
+// internal/testutil/server.go
+package testutil
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"textual/internal/server/chat"
+	"textual/internal/server/database"
+)
+
+// Password is the password of the accounts created by Connect
+const Password = "password"
+
+// Server is a chat server listening on a random local port
+type Server struct {
+    *chat.Server
+    DB   database.Store
+    Addr string
+}
+
+// StartServer boots a server on a new test database, stopped with the test
+func StartServer(t testing.TB) *Server {
+    t.Helper()
+    return StartServerWith(t, NewDB(t))
+}
+
+// StartServerWith boots a server on the given database, to set it up first,
+// the setups configure the server before it serves
+func StartServerWith(t testing.TB, db database.Store, setups ...func(*chat.Server)) *Server {
+    t.Helper()
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("failed to listen: %v", err)
+    }
+    srv := &Server{
+        Server: chat.NewServer(db),
+        DB:     db,
+        Addr:   listener.Addr().String(),
+    }
+    for _, setup := range setups {
+        setup(srv.Server)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    served := make(chan struct{})
+    go func() {
+        defer close(served)
+        srv.Serve(ctx, listener)
+    }()
+    t.Cleanup(func() {
+        cancel()
+        <-served
+    })
+    return srv
+}
+
+// Connect logs a user in, the account is created with Password the first
+// time
+func (s *Server) Connect(t testing.TB, username string) *Client {
+    t.Helper()
+    c := Dial(t, s.Addr)
+    c.Login(username, Password)
+    return c
+}
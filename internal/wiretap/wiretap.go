@@ -0,0 +1,218 @@
+// internal/wiretap/wiretap.go
+package wiretap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// who sent a frame (Record.From)
+const (
+    Client = "client"
+    Server = "server"
+)
+
+// Redacted replaces the secrets in the frames recorded
+const Redacted = "[redacted]"
+
+// the fields of a frame never written to a capture, at any depth
+var secrets = map[string]bool{
+    "password": true,
+    "token":    true,
+}
+
+// Record is a line of a capture: a frame and when it went through
+type Record struct {
+    // the connection of the frame, numbered from 1 in the order they were
+    // tapped
+    Conn int `json:"conn"`
+    // milliseconds since the capture started
+    At    int64           `json:"at"`
+    From  string          `json:"from"`
+    Frame json.RawMessage `json:"frame"`
+}
+
+// Tap records the frames of the connections it wraps in a capture, a JSON
+// Record per line. The secrets are redacted, the messages are not: keep the
+// captures private
+type Tap struct {
+    side  string
+    start time.Time
+
+    mu    sync.Mutex
+    file  *os.File
+    out   *bufio.Writer
+    conns int
+}
+
+// Open creates the capture at path, side is Client or Server: the end whose
+// connections are tapped
+func Open(path, side string) (*Tap, error) {
+    if side != Client && side != Server {
+        return nil, fmt.Errorf("unknown side %q", side)
+    }
+    file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+    if err != nil {
+        return nil, err
+    }
+    return &Tap{side: side, start: time.Now(), file: file, out: bufio.NewWriter(file)}, nil
+}
+
+// Close writes the last frames and closes the capture
+func (t *Tap) Close() error {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if err := t.out.Flush(); err != nil {
+        t.file.Close()
+        return err
+    }
+    return t.file.Close()
+}
+
+// Wrap returns conn recording what it reads and writes
+func (t *Tap) Wrap(conn net.Conn) net.Conn {
+    t.mu.Lock()
+    t.conns++
+    id := t.conns
+    t.mu.Unlock()
+
+    peer := Server
+    if t.side == Server {
+        peer = Client
+    }
+    return &tappedConn{Conn: conn, tap: t, id: id, reads: lines{from: peer}, writes: lines{from: t.side}}
+}
+
+// record writes a frame, flushed at once so a crash keeps the frames before it
+func (t *Tap) record(conn int, from string, frame []byte) {
+    line, err := json.Marshal(Record{
+        Conn:  conn,
+        At:    time.Since(t.start).Milliseconds(),
+        From:  from,
+        Frame: Redact(frame),
+    })
+    if err != nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.out.Write(line)
+    t.out.WriteByte('\n')
+    t.out.Flush()
+}
+
+// Redact replaces the secrets of a frame, a line that is not JSON is kept as
+// a string
+func Redact(frame []byte) json.RawMessage {
+    dec := json.NewDecoder(bytes.NewReader(frame))
+    // the numbers stay as they were sent
+    dec.UseNumber()
+    var value interface{}
+    if err := dec.Decode(&value); err != nil {
+        raw, _ := json.Marshal(string(frame))
+        return raw
+    }
+    redacted, err := json.Marshal(redactValue(value))
+    if err != nil {
+        raw, _ := json.Marshal(string(frame))
+        return raw
+    }
+    return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        for key, field := range v {
+            if secrets[key] {
+                if s, ok := field.(string); ok && s != "" {
+                    v[key] = Redacted
+                }
+                continue
+            }
+            v[key] = redactValue(field)
+        }
+    case []interface{}:
+        for i := range v {
+            v[i] = redactValue(v[i])
+        }
+    }
+    return value
+}
+
+// tappedConn is a connection whose frames are recorded, a frame per line as
+// the JSON encoders write them
+type tappedConn struct {
+    net.Conn
+    tap *Tap
+    id  int
+
+    readMu  sync.Mutex
+    reads   lines
+    writeMu sync.Mutex
+    writes  lines
+}
+
+func (c *tappedConn) Read(b []byte) (int, error) {
+    n, err := c.Conn.Read(b)
+    if n > 0 {
+        c.readMu.Lock()
+        c.reads.feed(c.tap, c.id, b[:n])
+        c.readMu.Unlock()
+    }
+    return n, err
+}
+
+// Write records the frames before they leave, the answer can't come first
+func (c *tappedConn) Write(b []byte) (int, error) {
+    c.writeMu.Lock()
+    c.writes.feed(c.tap, c.id, b)
+    c.writeMu.Unlock()
+    return c.Conn.Write(b)
+}
+
+// lines cuts a stream into frames, a read may hold part of a frame or several
+type lines struct {
+    from    string
+    pending []byte
+}
+
+func (l *lines) feed(t *Tap, conn int, data []byte) {
+    l.pending = append(l.pending, data...)
+    for {
+        end := bytes.IndexByte(l.pending, '\n')
+        if end < 0 {
+            return
+        }
+        if frame := bytes.TrimSpace(l.pending[:end]); len(frame) > 0 {
+            t.record(conn, l.from, frame)
+        }
+        l.pending = l.pending[end+1:]
+    }
+}
+
+// ReadCapture reads the records of a capture
+func ReadCapture(r io.Reader) ([]Record, error) {
+    var records []Record
+    scanner := bufio.NewScanner(r)
+    // a frame holds up to an attachment chunk
+    scanner.Buffer(make([]byte, 64*1024), 16<<20)
+    for line := 1; scanner.Scan(); line++ {
+        if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+            continue
+        }
+        var record Record
+        if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+            return nil, fmt.Errorf("line %d: %v", line, err)
+        }
+        records = append(records, record)
+    }
+    return records, scanner.Err()
+}
@@ -0,0 +1,84 @@
+// internal/wiretap/wiretap_test.go
+package wiretap
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTap(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "capture.jsonl")
+    tap, err := Open(path, Client)
+    if err != nil {
+        t.Fatal(err)
+    }
+    client, server := net.Pipe()
+    conn := tap.Wrap(client)
+
+    // the auth frame is written in two parts, the server answers two
+    // frames at once
+    go func() {
+        conn.Write([]byte(`{"type":"auth","payload":{"username":"alice",`))
+        conn.Write([]byte(`"password":"hunter2"},"timestamp":1}` + "\n"))
+    }()
+    buf := make([]byte, 256)
+    var got string
+    for !strings.HasSuffix(got, "\n") {
+        n, err := server.Read(buf)
+        if err != nil {
+            t.Fatal(err)
+        }
+        got += string(buf[:n])
+    }
+    go func() {
+        server.Write([]byte(`{"type":"auth_response","payload":{"token":"secret","success":true}}` + "\n" + `{"type":"pong"}` + "\n"))
+        server.Close()
+    }()
+    io.ReadAll(conn)
+    if err := tap.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    file, err := os.Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    records, err := ReadCapture(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []struct {
+        from  string
+        frame string
+    }{
+        {Client, `{"payload":{"password":"[redacted]","username":"alice"},"timestamp":1,"type":"auth"}`},
+        {Server, `{"payload":{"success":true,"token":"[redacted]"},"type":"auth_response"}`},
+        {Server, `{"type":"pong"}`},
+    }
+    if len(records) != len(want) {
+        t.Fatalf("%d records, want %d: %+v", len(records), len(want), records)
+    }
+    for i, w := range want {
+        if records[i].Conn != 1 || records[i].From != w.from || string(records[i].Frame) != w.frame {
+            t.Errorf("record %d: conn %d from %s %s, want conn 1 from %s %s", i, records[i].Conn, records[i].From, records[i].Frame, w.from, w.frame)
+        }
+    }
+    if strings.Contains(got, Redacted) {
+        t.Errorf("the frame sent was redacted: %s", got)
+    }
+}
+
+func TestRedactKeepsNumbers(t *testing.T) {
+    frame := `{"payload":{"id":12345678901234567890,"items":[{"password":""}]}}`
+    if got := string(Redact([]byte(frame))); got != frame {
+        t.Errorf("Redact(%s) = %s", frame, got)
+    }
+    if got := string(Redact([]byte("not json"))); got != `"not json"` {
+        t.Errorf("Redact(not json) = %s", got)
+    }
+}
@@ -0,0 +1,324 @@
+// pkg/bot/bot.go
+
+// Package bot writes Textual bots: register what to do with the messages and
+// the commands, then Run.
+//
+//	b := bot.New("localhost:8080", "echo", password)
+//	b.OnCommand("echo", func(b *bot.Bot, msg bot.Message, args string) {
+//	    b.Reply(msg, args)
+//	})
+//	log.Fatal(b.Run(context.Background()))
+//
+// The handlers run one at a time, in the order of the messages: a handler
+// doing slow work should start a goroutine. The bot reconnects by itself
+// when the connection is lost.
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"textual/internal/client/models"
+	"textual/internal/client/network"
+	"time"
+)
+
+// how long Run waits for the server to accept the credentials
+const authTimeout = 10 * time.Second
+
+// ErrDisconnected is returned by Run when the server can't be reached anymore
+var ErrDisconnected = errors.New("bot: connection lost")
+
+// Message is a message received by the bot
+type Message struct {
+    ID         string
+    Content    string
+    SenderID   string
+    SenderName string
+    // RecipientID is the bot for a direct message, empty otherwise
+    RecipientID string
+    // GroupID is the group of a group message, empty otherwise
+    GroupID string
+    // ThreadRootID is the message answered, for a reply in a thread
+    ThreadRootID string
+    SentAt       time.Time
+}
+
+// IsDirect tells if the message was sent to the bot only
+func (m Message) IsDirect() bool {
+    return m.RecipientID != ""
+}
+
+// IsGroup tells if the message was sent to a group
+func (m Message) IsGroup() bool {
+    return m.GroupID != ""
+}
+
+// MessageHandler is called with each message sent by someone else
+type MessageHandler func(b *Bot, msg Message)
+
+// CommandHandler is called with a message starting with the prefix and the
+// name of its command, args is the rest of the message
+type CommandHandler func(b *Bot, msg Message, args string)
+
+// Bot is a connection to a server as a user, dispatching the messages it
+// receives to its handlers
+type Bot struct {
+    address  string
+    username string
+    password string
+    prefix   string
+
+    mu       sync.RWMutex
+    handlers []MessageHandler
+    commands map[string]CommandHandler
+    onError  func(b *Bot, err error)
+    onReady  func(b *Bot)
+
+    handler *network.ConnectionHandler
+}
+
+// New makes a bot logging in as username. The account is created by the
+// server on the first connection, like for the client
+func New(address, username, password string) *Bot {
+    return &Bot{
+        address:  address,
+        username: username,
+        password: password,
+        prefix:   "!",
+        commands: make(map[string]CommandHandler),
+    }
+}
+
+// SetCommandPrefix changes the prefix of the commands, "!" by default
+func (b *Bot) SetCommandPrefix(prefix string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.prefix = prefix
+}
+
+// OnMessage registers a handler called with every message, commands included
+func (b *Bot) OnMessage(handler MessageHandler) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.handlers = append(b.handlers, handler)
+}
+
+// OnCommand registers the handler of "!name args", the names are not case
+// sensitive
+func (b *Bot) OnCommand(name string, handler CommandHandler) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.commands[strings.ToLower(name)] = handler
+}
+
+// OnError registers a handler for the errors sent by the server, such as a
+// message refused. They are dropped otherwise
+func (b *Bot) OnError(handler func(b *Bot, err error)) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.onError = handler
+}
+
+// OnReady registers a handler called once logged in, before the first
+// message: the place to join groups or announce the bot
+func (b *Bot) OnReady(handler func(b *Bot)) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.onReady = handler
+}
+
+// Run connects, logs in and dispatches the messages until ctx is done or
+// the connection is lost for good
+func (b *Bot) Run(ctx context.Context) error {
+    conn, err := network.NewConnection(b.address)
+    if err != nil {
+        return fmt.Errorf("bot: connection error: %v", err)
+    }
+
+    events := make(chan interface{}, 256)
+    push := func(event interface{}) {
+        select {
+        case events <- event:
+        case <-ctx.Done():
+        }
+    }
+    handler := network.NewConnectionHandler(conn.GetUnderlyingConn())
+    handler.SetMessageHandler(func(msg models.Message) { push(msg) })
+    handler.SetErrorHandler(func(err error) { push(err) })
+    handler.SetDisconnectHandler(func() { push(ErrDisconnected) })
+    handler.SetReconnect(b.address)
+    handler.Start()
+    defer handler.Close()
+
+    if err := b.login(ctx, handler); err != nil {
+        return err
+    }
+    b.mu.Lock()
+    b.handler = handler
+    onReady := b.onReady
+    b.mu.Unlock()
+    if onReady != nil {
+        onReady(b)
+    }
+
+    for {
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case event := <-events:
+            switch event := event.(type) {
+            case models.Message:
+                b.dispatch(event)
+            case error:
+                if event == ErrDisconnected {
+                    return event
+                }
+                b.mu.RLock()
+                onError := b.onError
+                b.mu.RUnlock()
+                if onError != nil {
+                    onError(b, event)
+                }
+            }
+        }
+    }
+}
+
+func (b *Bot) login(ctx context.Context, handler *network.ConnectionHandler) error {
+    if err := handler.SendAuthRequest(b.username, b.password); err != nil {
+        return fmt.Errorf("bot: authentication error: %v", err)
+    }
+    select {
+    case err := <-handler.AuthResult():
+        if err != nil {
+            return fmt.Errorf("bot: %v", err)
+        }
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-time.After(authTimeout):
+        return errors.New("bot: authentication timeout")
+    }
+}
+
+// dispatch calls the handlers of a message, skipping the bot's own messages
+// echoed by the server
+func (b *Bot) dispatch(received models.Message) {
+    if received.SenderID == b.UserID() {
+        return
+    }
+    msg := convertMessage(received)
+
+    b.mu.RLock()
+    handlers := b.handlers
+    prefix := b.prefix
+    b.mu.RUnlock()
+    for _, handler := range handlers {
+        handler(b, msg)
+    }
+
+    text, ok := strings.CutPrefix(msg.Content, prefix)
+    if !ok || prefix == "" {
+        return
+    }
+    name, args, _ := strings.Cut(text, " ")
+    b.mu.RLock()
+    command := b.commands[strings.ToLower(name)]
+    b.mu.RUnlock()
+    if command != nil {
+        command(b, msg, strings.TrimSpace(args))
+    }
+}
+
+func convertMessage(msg models.Message) Message {
+    converted := Message{
+        ID:         msg.ID,
+        Content:    msg.Content,
+        SenderID:   msg.SenderID,
+        SenderName: msg.SenderName,
+        SentAt:     msg.SentAt,
+    }
+    if msg.RecipientID != nil {
+        converted.RecipientID = *msg.RecipientID
+    }
+    if msg.GroupID != nil {
+        converted.GroupID = *msg.GroupID
+    }
+    if msg.ThreadRootID != nil {
+        converted.ThreadRootID = *msg.ThreadRootID
+    }
+    return converted
+}
+
+func (b *Bot) connection() (*network.ConnectionHandler, error) {
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+    if b.handler == nil {
+        return nil, errors.New("bot: not running")
+    }
+    return b.handler, nil
+}
+
+// UserID is the id of the bot's account, empty until Run logged in
+func (b *Bot) UserID() string {
+    handler, err := b.connection()
+    if err != nil {
+        return ""
+    }
+    return handler.UserID()
+}
+
+// Username is the name the bot logs in with
+func (b *Bot) Username() string {
+    return b.username
+}
+
+// Reply answers a message in its conversation: to its sender for a direct
+// message, in its group or in the global chat
+func (b *Bot) Reply(msg Message, content string) error {
+    switch {
+    case msg.IsDirect():
+        return b.SendDirect(msg.SenderID, content)
+    case msg.IsGroup():
+        return b.SendGroup(msg.GroupID, content)
+    }
+    return b.Send(content)
+}
+
+// Send writes in the global chat
+func (b *Bot) Send(content string) error {
+    return b.send(content, nil, nil)
+}
+
+// SendDirect sends a direct message to a user, by id
+func (b *Bot) SendDirect(userID, content string) error {
+    return b.send(content, &userID, nil)
+}
+
+// SendGroup sends a message to a group the bot is a member of
+func (b *Bot) SendGroup(groupID, content string) error {
+    return b.send(content, nil, &groupID)
+}
+
+func (b *Bot) send(content string, recipientID, groupID *string) error {
+    handler, err := b.connection()
+    if err != nil {
+        return err
+    }
+    if strings.TrimSpace(content) == "" {
+        return errors.New("bot: empty message")
+    }
+    return handler.SendMessage(content, recipientID, groupID)
+}
+
+// JoinGroup joins a public group, its messages then reach the bot
+func (b *Bot) JoinGroup(groupID string) error {
+    handler, err := b.connection()
+    if err != nil {
+        return err
+    }
+    return handler.JoinGroup(groupID)
+}
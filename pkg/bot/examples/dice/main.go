@@ -0,0 +1,64 @@
+// pkg/bot/examples/dice/main.go
+
+// dice answers "!roll 2d6" with the dice thrown, and joins the groups given
+// on the command line.
+//
+//	TEXTUAL_PASSWORD=secret go run ./pkg/bot/examples/dice -group <group id>
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"textual/pkg/bot"
+)
+
+func main() {
+    server := flag.String("server", "localhost:8080", "server address (host:port)")
+    name := flag.String("user", "dice", "username of the bot")
+    group := flag.String("group", "", "public group to join")
+    flag.Parse()
+
+    b := bot.New(*server, *name, os.Getenv("TEXTUAL_PASSWORD"))
+    b.OnCommand("roll", func(b *bot.Bot, msg bot.Message, args string) {
+        var count, sides int
+        if args == "" {
+            args = "1d6"
+        }
+        if _, err := fmt.Sscanf(args, "%dd%d", &count, &sides); err != nil || count < 1 || count > 20 || sides < 2 || sides > 1000 {
+            b.Reply(msg, "usage: !roll <n>d<sides>, like !roll 2d6")
+            return
+        }
+        rolls := make([]string, count)
+        total := 0
+        for i := range rolls {
+            n := rand.Intn(sides) + 1
+            total += n
+            rolls[i] = fmt.Sprint(n)
+        }
+        b.Reply(msg, fmt.Sprintf("🎲 %s rolled %s = %d", msg.SenderName, strings.Join(rolls, " + "), total))
+    })
+    b.OnError(func(b *bot.Bot, err error) {
+        log.Printf("server error: %v", err)
+    })
+
+    b.OnReady(func(b *bot.Bot) {
+        if *group == "" {
+            return
+        }
+        if err := b.JoinGroup(*group); err != nil {
+            log.Printf("failed to join %s: %v", *group, err)
+        }
+    })
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    if err := b.Run(ctx); err != nil && err != context.Canceled {
+        log.Fatal(err)
+    }
+}
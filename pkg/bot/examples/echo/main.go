@@ -0,0 +1,39 @@
+// pkg/bot/examples/echo/main.go
+
+// echo repeats the direct messages it receives, and "!echo text" anywhere.
+//
+//	TEXTUAL_PASSWORD=secret go run ./pkg/bot/examples/echo -server localhost:8080
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"textual/pkg/bot"
+)
+
+func main() {
+    server := flag.String("server", "localhost:8080", "server address (host:port)")
+    name := flag.String("user", "echo", "username of the bot")
+    flag.Parse()
+
+    b := bot.New(*server, *name, os.Getenv("TEXTUAL_PASSWORD"))
+    b.OnMessage(func(b *bot.Bot, msg bot.Message) {
+        if msg.IsDirect() {
+            b.Reply(msg, msg.Content)
+        }
+    })
+    b.OnCommand("echo", func(b *bot.Bot, msg bot.Message, args string) {
+        if args != "" && !msg.IsDirect() {
+            b.Reply(msg, args)
+        }
+    })
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    if err := b.Run(ctx); err != nil && err != context.Canceled {
+        log.Fatal(err)
+    }
+}
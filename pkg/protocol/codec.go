@@ -0,0 +1,61 @@
+// pkg/protocol/codec.go
+package protocol
+
+import (
+    "bytes"
+    "encoding/json"
+    "sync"
+)
+
+// a buffer grown past this size by a large message is left to the garbage
+// collector, the pool keeps the usual sizes
+const maxPooledBuffer = 256 << 10
+
+var bufferPool = sync.Pool{
+    New: func() interface{} {
+        b := &Buffer{}
+        b.buf.Grow(1024)
+        b.enc = json.NewEncoder(&b.buf)
+        return b
+    },
+}
+
+// Buffer is a pooled buffer with its encoder, for the messages written and
+// the payloads decoded. Release it once its bytes are written
+type Buffer struct {
+    buf bytes.Buffer
+    enc *json.Encoder
+}
+
+// GetBuffer returns an empty buffer of the pool
+func GetBuffer() *Buffer {
+    b := bufferPool.Get().(*Buffer)
+    b.buf.Reset()
+    return b
+}
+
+// Encode appends v as a line of JSON
+func (b *Buffer) Encode(v interface{}) error {
+    return b.enc.Encode(v)
+}
+
+func (b *Buffer) Bytes() []byte { return b.buf.Bytes() }
+func (b *Buffer) Len() int      { return b.buf.Len() }
+
+// Release puts the buffer back in the pool, its bytes can't be used anymore
+func (b *Buffer) Release() {
+    if b.buf.Cap() <= maxPooledBuffer {
+        bufferPool.Put(b)
+    }
+}
+
+// DecodePayload decodes the payload of a message read, a generic value,
+// into target
+func DecodePayload(payload interface{}, target interface{}) error {
+    b := GetBuffer()
+    defer b.Release()
+    if err := b.Encode(payload); err != nil {
+        return err
+    }
+    return json.Unmarshal(b.Bytes(), target)
+}
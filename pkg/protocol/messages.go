@@ -2,6 +2,7 @@
 package protocol
 
 import (
+    "encoding/json"
     "fmt"
     "time"
 )
@@ -27,10 +28,84 @@ const (
     TypeGroupLeave      MessageType = "group_leave"
     TypeGroupList       MessageType = "group_list"
     TypeGroupInvite     MessageType = "group_invite"
+    TypeGroupMembers    MessageType = "group_members"
+    TypeGroupKick       MessageType = "group_kick"
+    TypeGroupRoleUpdate MessageType = "group_role_update"
+    TypeGroupDirectory  MessageType = "group_directory"
+    // an admin sets the topic, the members get it back; the history request
+    // is answered with the latest topics set
+    TypeGroupTopic        MessageType = "group_topic"
+    TypeGroupTopicHistory MessageType = "group_topic_history"
     TypePing           MessageType = "ping"
     TypePong           MessageType = "pong"
     TypeError          MessageType = "error"
     TypeFriendRemove    MessageType = "friend_remove"
+    TypeFriendBlock     MessageType = "friend_block"
+    TypeMessageEdit     MessageType = "message_edit"
+    TypeMessageRevisions MessageType = "message_revisions"
+    TypeTyping          MessageType = "typing"
+    TypeUserLookup      MessageType = "user_lookup"
+    TypeUserSearch      MessageType = "user_search"
+    TypeNotificationList MessageType = "notification_list"
+    TypeNotificationRead MessageType = "notification_read"
+    TypeNotificationPrefs MessageType = "notification_prefs"
+    // the timezone, clock and language of the user, sent back to all their
+    // devices once saved
+    TypeDisplayPrefs MessageType = "display_prefs"
+    // the messages mentioning the user, the request has no payload
+    TypeMentionsList MessageType = "mentions_list"
+
+    // a conversation read on a device, relayed to the other devices of the user
+    TypeReadMarker MessageType = "read_marker"
+    // direct messages delivered to or read by their recipient, sent to their sender
+    TypeMessageStatus MessageType = "message_status"
+
+    // end-to-end encrypted direct and group messages, the server relays them opaquely
+    TypeKeyBundle        MessageType = "key_bundle"
+    TypeKeyBundleRequest MessageType = "key_bundle_request"
+    TypeEncryptedMessage MessageType = "encrypted_message"
+
+    // files sent in chunks, and the voice messages made of one
+    TypeAttachmentUpload   MessageType = "attachment_upload"
+    TypeAttachmentDownload MessageType = "attachment_download"
+    TypeVoiceMessage       MessageType = "voice_message"
+
+    // threads
+    TypeLoadThread    MessageType = "load_thread"
+    TypeThreadHistory MessageType = "thread_history"
+
+    // the messages around one, to jump to it; the answer has the same type
+    TypeFetchContext MessageType = "fetch_context"
+
+    // a message sent again with the preview of its link, once the server fetched it
+    TypeLinkPreview MessageType = "link_preview"
+
+    // reminders set by the user, delivered as direct messages of the system account
+    TypeReminderCreate MessageType = "reminder_create"
+    TypeReminderList   MessageType = "reminder_list"
+    TypeReminderCancel MessageType = "reminder_cancel"
+
+    // a GIF searched by the server then posted as a message with its preview
+    TypeGif MessageType = "gif"
+
+    // administration of the server, for the users listed in ADMINS
+    TypeAdminStats    MessageType = "admin_stats"
+    TypeAdminAnnounce MessageType = "admin_announce"
+    TypeAdminKick     MessageType = "admin_kick"
+    // the last message of a session the server ends, the client does not
+    // reconnect
+    TypeDisconnectNotice MessageType = "disconnect_notice"
+
+    // an event of a group shown among its messages, sent live and not stored
+    TypeSystemMessage MessageType = "system_message"
+
+    // the state of the user sent once after the login, and again after a
+    // reconnect
+    TypeSync MessageType = "sync"
+
+    // the answer to a message whose type the receiver does not know, sent
+    // by the server and by the client when their versions differ
+    TypeUnsupported MessageType = "unsupported"
 )
 
 // error codes
@@ -45,6 +120,51 @@ const (
     ErrCodeAlreadyExists   = 1007
     ErrCodeInvalidRequest  = 1008
     ErrCodeInternalError   = 1009
+    ErrCodeUnavailable     = 1010
+    // a limit of the server: members per group, groups or friends per user
+    ErrCodeQuotaExceeded   = 1011
+    // too many messages, ErrorPayload.RetryAfter tells when to send again
+    ErrCodeRateLimited     = 1012
+)
+
+// server notice kinds (NotificationPayload.Type)
+const (
+    NoticeDegraded = "degraded"
+    NoticeRestored = "restored"
+    // sent by an admin to every user, Actor is the admin
+    NoticeAnnouncement = "announcement"
+)
+
+// notification kinds kept by the server until the user reads them
+const (
+    NoticeFriendRequest = "friend_request"
+    NoticeGroupInvite   = "group_invite"
+    NoticeMention       = "mention"
+    // Content is the name of the group, RelatedID its ID
+    NoticeGroupPromoted = "group_promoted"
+    NoticeGroupDemoted  = "group_demoted"
+)
+
+// system message kinds (SystemMessagePayload.Kind)
+const (
+    SystemJoined   = "joined"
+    SystemLeft     = "left"
+    // Actor invited or removed Target
+    SystemAdded    = "added"
+    SystemRemoved  = "removed"
+    // Actor changed the role of Target
+    SystemPromoted = "promoted"
+    SystemDemoted  = "demoted"
+    // Actor set the topic to Target, cleared it when empty
+    SystemTopic    = "topic"
+)
+
+// notification levels of a conversation (NotificationPrefsPayload.Levels)
+const (
+    LevelAll      = "all"
+    LevelMentions = "mentions"
+    LevelBadge    = "badge"
+    LevelNone     = "none"
 )
 
 
@@ -53,6 +173,8 @@ const (
 type ErrorPayload struct {
     Code    int    `json:"code"`
     Message string `json:"message"`
+    // seconds to wait before sending again, with ErrCodeRateLimited
+    RetryAfter int `json:"retry_after,omitempty"`
 }
 
 
@@ -77,19 +199,114 @@ type GlobalMessagePayload struct {
 type StatusUpdatePayload struct {
     UserID string `json:"user_id"`
     Status string `json:"status"`
+    Text   string `json:"text,omitempty"` // custom status text
+    // ExpiresAt clears the text at this Unix time, 0 keeps it until changed
+    ExpiresAt int64 `json:"expires_at,omitempty"`
 }
 
+// NotificationPayload is a server notice (degraded mode, ...) or, when ID is
+// set, a notification kept by the server for the user
 type NotificationPayload struct {
+    ID      string      `json:"id,omitempty"`
     Type    string      `json:"type"`
     Message string      `json:"message"`
     Data    interface{} `json:"data,omitempty"`
+    // Actor is the user who sent the request, the invite or the mention
+    Actor     string `json:"actor,omitempty"`
+    // RelatedID is the conversation of the notification: the group, or the
+    // user for friend requests, empty for the global chat
+    RelatedID string `json:"related_id,omitempty"`
+    CreatedAt int64  `json:"created_at,omitempty"`
+    Read      bool   `json:"read,omitempty"`
+}
+
+// NotificationListPayload answers notification_list, newest first
+type NotificationListPayload struct {
+    Notifications []NotificationPayload `json:"notifications"`
+}
+
+// MentionPayload is a message naming the user, GroupID is empty for the
+// global chat
+type MentionPayload struct {
+    MessageID string `json:"message_id"`
+    GroupID   string `json:"group_id,omitempty"`
+    GroupName string `json:"group_name,omitempty"`
+    Sender    string `json:"sender"`
+    Content   string `json:"content"`
+    SentAt    int64  `json:"sent_at"`
+}
+
+// MentionsListPayload answers mentions_list, newest first
+type MentionsListPayload struct {
+    Mentions []MentionPayload `json:"mentions"`
+}
+
+// NotificationPrefsPayload carries notification levels by conversation
+// ("global", a group or user ID). The client sends the levels it changes, or
+// none to ask for the list; the server answers with all the levels of the user
+type NotificationPrefsPayload struct {
+    Levels map[string]string `json:"levels,omitempty"`
+}
+
+// DisplayPrefsPayload carries the display preferences of a user: an IANA
+// timezone such as "Europe/Paris", TimeFormat24h or TimeFormat12h and a
+// language such as "fr". Empty fields keep the setting of each device
+type DisplayPrefsPayload struct {
+    Timezone   string `json:"timezone,omitempty"`
+    TimeFormat string `json:"time_format,omitempty"`
+    Locale     string `json:"locale,omitempty"`
+}
+
+// clocks of DisplayPrefsPayload.TimeFormat
+const (
+    TimeFormat24h = "24h"
+    TimeFormat12h = "12h"
+)
+
+// NotificationReadPayload marks notifications as read, all of them when IDs
+// is empty
+type NotificationReadPayload struct {
+    IDs []string `json:"ids,omitempty"`
+}
+
+// ReadMarkerPayload tells the other devices of a user that a conversation
+// was read, up to MessageID when set. ChatID is "global", the ID of a group
+// or of the other user of a direct conversation
+type ReadMarkerPayload struct {
+    ChatID    string `json:"chat_id"`
+    MessageID string `json:"message_id,omitempty"`
+}
+
+// MessageStatusPayload tells the sender of direct messages that they reached
+// Status: every message sent to ChatID, the recipient, up to MessageID, or
+// all of them when it is empty
+type MessageStatusPayload struct {
+    ChatID    string `json:"chat_id"`
+    MessageID string `json:"message_id,omitempty"`
+    Status    string `json:"status"`
 }
 
+// statuses of a direct message, in the order they progress
+const (
+    MessageSent      = "sent"
+    MessageDelivered = "delivered"
+    MessageRead      = "read"
+)
+
 type FriendRequestPayload struct {
     RequestID string `json:"request_id"`
     FromUser  string `json:"from_user"`
     ToUser    string `json:"to_user"`
     Status    string `json:"status"`
+    // Unix time of the request, set in the sync
+    CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// FriendRemovePayload is sent for friend_remove and friend_block, the server
+// answers with the same type to confirm
+type FriendRemovePayload struct {
+    FriendID string `json:"friend_id"`
+    Blocked  bool   `json:"blocked,omitempty"`
 }
 
 type FriendListPayload struct {
@@ -100,11 +317,19 @@ type GroupCreatePayload struct {
     Name        string   `json:"name"`
     Description string   `json:"description,omitempty"`
     MemberIDs   []string `json:"member_ids,omitempty"`
+    // Public groups are listed in the group directory
+    Public      bool     `json:"public,omitempty"`
+    // the messages of encrypted groups can only be read by the members
+    Encrypted   bool     `json:"encrypted,omitempty"`
+    // only the admins post in announcement groups, it can't be encrypted
+    Announcement bool    `json:"announcement,omitempty"`
 }
 
 type GroupJoinPayload struct {
     GroupID string `json:"group_id"`
     UserID  string `json:"user_id"`
+    // Group is set in the confirmation sent to the user who joined
+    Group   *GroupPayload `json:"group,omitempty"`
 }
 
 type GroupPayload struct {
@@ -114,6 +339,42 @@ type GroupPayload struct {
     CreatedBy   string    `json:"created_by"`
     CreatedAt   int64     `json:"created_at"`
     MemberIDs   []string  `json:"member_ids"`
+    Public      bool      `json:"public,omitempty"`
+    Encrypted   bool      `json:"encrypted,omitempty"`
+    Announcement bool     `json:"announcement,omitempty"`
+    // Posters are the members allowed to write in an announcement group
+    Posters     []string  `json:"posters,omitempty"`
+    Topic       string    `json:"topic,omitempty"`
+}
+
+// GroupTopicPayload sets the topic of a group, empty to clear it. SetBy and
+// SetAt (Unix time) are filled by the server
+type GroupTopicPayload struct {
+    GroupID string `json:"group_id"`
+    Topic   string `json:"topic"`
+    SetBy   string `json:"set_by,omitempty"`
+    SetAt   int64  `json:"set_at,omitempty"`
+}
+
+// GroupTopicHistoryPayload asks for the topics set on a group, the answer
+// carries them newest first
+type GroupTopicHistoryPayload struct {
+    GroupID string              `json:"group_id"`
+    Changes []GroupTopicPayload `json:"changes,omitempty"`
+}
+
+// GroupDirectoryPayload lists the public groups, the request has no payload
+type GroupDirectoryPayload struct {
+    Groups []GroupDirectoryEntry `json:"groups"`
+}
+
+type GroupDirectoryEntry struct {
+    ID          string `json:"id"`
+    Name        string `json:"name"`
+    Description string `json:"description,omitempty"`
+    MemberCount int    `json:"member_count"`
+    IsMember    bool   `json:"is_member"`
+    Announcement bool  `json:"announcement,omitempty"`
 }
 
 type GroupListPayload struct {
@@ -124,18 +385,46 @@ type GroupInvitePayload struct {
     GroupID  string `json:"group_id"`
     FromUser string `json:"from_user"`
     ToUser   string `json:"to_user"`
+    // Group is set in the invite sent to the new member
+    Group    *GroupPayload `json:"group,omitempty"`
+}
+
+// GroupMembersPayload requests the members of a group (GroupID only) and
+// carries them in the answer
+type GroupMembersPayload struct {
+    GroupID string            `json:"group_id"`
+    Members []GroupMemberInfo `json:"members,omitempty"`
+}
+
+type GroupMemberInfo struct {
+    ID       string `json:"id"`
+    Username string `json:"username"`
+    Role     string `json:"role"`
+    Status   string `json:"status"`
+}
+
+// GroupRolePayload changes the role of a member, admins only
+type GroupRolePayload struct {
+    GroupID string `json:"group_id"`
+    UserID  string `json:"user_id"`
+    Role    string `json:"role"`
 }
 
 type UserInfo struct {
     ID       string `json:"id"`
     Username string `json:"username"`
     Status   string `json:"status"`
+    // custom status text, cleared at TextExpiresAt (Unix time) when set
+    Text          string `json:"text,omitempty"`
+    TextExpiresAt int64  `json:"text_expires_at,omitempty"`
 }
 
 
 type Error struct {
     Code    int
     Message string
+    // set by NewRateLimitError
+    RetryAfter time.Duration
 }
 
 func (e Error) Error() string {
@@ -151,6 +440,16 @@ func NewError(code int, message string) Error {
     }
 }
 
+// NewRateLimitError refuses a request sent too often, the client can send
+// again after retryAfter
+func NewRateLimitError(message string, retryAfter time.Duration) Error {
+    return Error{
+        Code:       ErrCodeRateLimited,
+        Message:    message,
+        RetryAfter: retryAfter,
+    }
+}
+
 func NewErrorMessage(code int, message string) Message {
     return NewMessage(TypeError, ErrorPayload{
         Code:    code,
@@ -158,6 +457,24 @@ func NewErrorMessage(code int, message string) Message {
     })
 }
 
+// Reply is the error message sent to the client
+func (e Error) Reply() Message {
+    return NewMessage(TypeError, ErrorPayload{
+        Code:       e.Code,
+        Message:    e.Message,
+        RetryAfter: e.RetryAfterSeconds(),
+    })
+}
+
+// RetryAfterSeconds rounds the wait of a rate limit up to the second, 0
+// without one
+func (e Error) RetryAfterSeconds() int {
+    if e.RetryAfter <= 0 {
+        return 0
+    }
+    return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
 
 
 
@@ -181,9 +498,39 @@ func NewGroupJoin(groupID, userID string) Message {
 const (
     StatusOnline  = "online"
     StatusAway    = "away"
+    StatusDND     = "dnd"
     StatusOffline = "offline"
 )
 
+// longest custom status text, and how long it can be set for
+const (
+    MaxStatusText     = 100
+    MaxStatusDuration = 7 * 24 * time.Hour
+)
+
+// the account sending the reminders, no one logs in with it nor writes to it
+const (
+    SystemUserID   = "00000000-0000-0000-0000-000000000001"
+    SystemUsername = "Textual"
+)
+
+// longest reminder text, how far ahead it can be set and how many a user can
+// have waiting
+const (
+    MaxReminderText     = 500
+    MaxReminderDelay    = 365 * 24 * time.Hour
+    MaxPendingReminders = 50
+)
+
+// longest GIF search
+const MaxGifQuery = 100
+
+// roles of the group members
+const (
+    GroupRoleAdmin  = "admin"
+    GroupRoleMember = "member"
+)
+
 // Constantes de statut d'ami
 const (
     FriendStatusPending  = "pending"
@@ -211,6 +558,9 @@ type AuthResponsePayload struct {
     Username  string `json:"username"`
     Token     string `json:"token,omitempty"`
     Error     string `json:"error,omitempty"`
+    // the custom status kept from the last session
+    StatusText      string `json:"status_text,omitempty"`
+    StatusExpiresAt int64  `json:"status_expires_at,omitempty"`
 }
 
 type MessagePayload struct {
@@ -221,6 +571,9 @@ type MessagePayload struct {
     GroupID   string `json:"group_id,omitempty"`
     Timestamp int64  `json:"timestamp,omitempty"`
     SenderName string `json:"sender_name,omitempty"`
+    // ClientID is chosen by the sender, a message sent again with the same
+    // one is not stored twice
+    ClientID  string `json:"client_id,omitempty"`
 }
 
 func NewAuthResponse(success bool, userID, username string) Message {
@@ -254,7 +607,7 @@ func NewMessage(msgType MessageType, payload interface{}) Message {
 }
 
 // create a new message from a payload (global message)
-func NewGlobalMessage(content string, senderID string, username string) Message {
+func NewGlobalMessage(content string, senderID string, username string, clientID string) Message {
     return Message{
         Type: TypeGlobalMessage,
         Payload: MessagePayload{
@@ -262,13 +615,14 @@ func NewGlobalMessage(content string, senderID string, username string) Message
             SenderID: senderID,
             Username: username,
             SenderName: username,
+            ClientID: clientID,
         },
         Timestamp: time.Now().Unix(),
     }
 }
 
 // create a new message from a payload (direct message)
-func NewDirectMessage(content string, senderID string, username string, recipientID string) Message {
+func NewDirectMessage(content string, senderID string, username string, recipientID string, clientID string) Message {
     return Message{
         Type: TypeDirectMessage,
         Payload: map[string]interface{}{
@@ -276,13 +630,14 @@ func NewDirectMessage(content string, senderID string, username string, recipien
             "sender_id":    senderID,
             "username":     username,
             "recipient_id": recipientID,
+            "client_id":    clientID,
         },
         Timestamp: time.Now().Unix(),
     }
 }
 
 // create a new message from a payload (group message)
-func NewGroupMessage(content string, senderID string, username string, groupID string) Message {
+func NewGroupMessage(content string, senderID string, username string, groupID string, clientID string) Message {
     return Message{
         Type: TypeGroupMessage,
         Payload: map[string]interface{}{
@@ -290,6 +645,7 @@ func NewGroupMessage(content string, senderID string, username string, groupID s
             "sender_id": senderID,
             "username":  username,
             "group_id":  groupID,
+            "client_id": clientID,
         },
         Timestamp: time.Now().Unix(),
     }
@@ -323,11 +679,32 @@ func NewStatusUpdate(userID string, status string) Message {
     }
 }
 
+// LoadMessagesPayload requests a page of history. Without RecipientID or
+// GroupID the global chat is loaded.
 type LoadMessagesPayload struct {
-    BeforeID string `json:"before_id"`
+    BeforeID    string `json:"before_id"`
+    Limit       int    `json:"limit"`
+    RecipientID string `json:"recipient_id,omitempty"`
+    GroupID     string `json:"group_id,omitempty"`
+}
+
+// LoadThreadPayload requests a page of the replies of a thread, the answer
+// also carries its root
+type LoadThreadPayload struct {
+    RootID   string `json:"root_id"`
+    BeforeID string `json:"before_id,omitempty"`
     Limit    int    `json:"limit"`
 }
 
+// FetchContextPayload requests a message with Before messages sent before it
+// and those sent after it, up to UntilID when set: the oldest message the
+// client has, so that no message is missing in between
+type FetchContextPayload struct {
+    MessageID string `json:"message_id"`
+    Before    int    `json:"before"`
+    UntilID   string `json:"until_id,omitempty"`
+}
+
 func NewLoadMessagesRequest(beforeID string, limit int) Message {
     return Message{
         Type: TypeLoadMessages,
@@ -359,4 +736,261 @@ type FriendResponsePayload struct {
     RequestID string `json:"request_id"`
     FromUser  string `json:"from_user"`
     Accept    bool   `json:"accept"`
-}
\ No newline at end of file
+}
+
+type MessageEditPayload struct {
+    MessageID string `json:"message_id"`
+    Content   string `json:"content"`
+}
+
+// request: only MessageID is set; response: Revisions holds the previous contents, oldest first
+type MessageRevisionsPayload struct {
+    MessageID string            `json:"message_id"`
+    Revisions []MessageRevision `json:"revisions,omitempty"`
+}
+
+type MessageRevision struct {
+    Content  string `json:"content"`
+    EditedBy string `json:"edited_by"`
+    EditedAt int64  `json:"edited_at"`
+}
+
+// sent while the user types; without recipient or group it is for the global chat
+type TypingPayload struct {
+    UserID      string `json:"user_id,omitempty"`
+    Username    string `json:"username,omitempty"`
+    RecipientID string `json:"recipient_id,omitempty"`
+    GroupID     string `json:"group_id,omitempty"`
+}
+
+// request: only Username is set; response: the user found
+type UserLookupPayload struct {
+    Username string `json:"username"`
+    UserID   string `json:"user_id,omitempty"`
+    Status   string `json:"status,omitempty"`
+}
+
+// AdminStatsPayload is the state of the server shown to its admins, the
+// request has no payload. An admin gets it when they connect, the client
+// shows the Server tab once it did
+type AdminStatsPayload struct {
+    OnlineUsers int `json:"online_users"`
+    Sessions    int `json:"sessions"`
+    // chat messages received during the last minute
+    MessagesPerMinute int `json:"messages_per_minute"`
+    // messages waiting in the queues of the sessions
+    QueuedMessages int `json:"queued_messages"`
+    MaxQueued      int `json:"max_queued"`
+    Degraded       int `json:"degraded"`
+    // recent moderation events, oldest first
+    Events []ModerationEvent `json:"events,omitempty"`
+}
+
+// moderation actions (ModerationEvent.Action)
+const (
+    ModerationAnnounce  = "announce"
+    ModerationKick      = "kick"       // an admin disconnected a user
+    ModerationGroupKick = "group_kick" // an admin of a group removed a member, Detail is the group
+)
+
+type ModerationEvent struct {
+    Action string `json:"action"`
+    Actor  string `json:"actor"`
+    Target string `json:"target,omitempty"`
+    Detail string `json:"detail,omitempty"`
+    At     int64  `json:"at"`
+}
+
+// AdminAnnouncePayload is a notice an admin sends to every connected user
+type AdminAnnouncePayload struct {
+    Message string `json:"message"`
+}
+
+// AdminKickPayload disconnects every session of a user, Reason is shown to
+// them
+type AdminKickPayload struct {
+    Username string `json:"username"`
+    Reason   string `json:"reason,omitempty"`
+}
+
+// UnsupportedPayload names the type of the message the receiver does not know
+type UnsupportedPayload struct {
+    Type MessageType `json:"type"`
+}
+
+// DisconnectNoticePayload tells a client why the server ends its session
+type DisconnectNoticePayload struct {
+    Reason string `json:"reason,omitempty"`
+    // the admin who kicked the user
+    By string `json:"by,omitempty"`
+}
+
+// SystemMessagePayload is an event of a group, e.g. a member who joined
+type SystemMessagePayload struct {
+    GroupID string `json:"group_id"`
+    Kind    string `json:"kind"`
+    Actor   string `json:"actor"`
+    Target  string `json:"target,omitempty"`
+    At      int64  `json:"at"`
+}
+
+// SyncPayload is the state of the user sent after the login: everything a
+// client needs to draw its lists and the latest messages of each chat
+type SyncPayload struct {
+    Profile       UserInfo               `json:"profile"`
+    Friends       []UserInfo             `json:"friends"`
+    Requests      []FriendRequestPayload `json:"requests"`
+    Groups        []GroupPayload         `json:"groups"`
+    // unread direct messages by sender
+    Unread        map[string]int         `json:"unread,omitempty"`
+    Conversations []SyncConversation     `json:"conversations"`
+    Prefs         DisplayPrefsPayload    `json:"prefs"`
+}
+
+// SyncConversation holds the latest messages of the global chat (no
+// recipient nor group), of a group or of the direct messages with a friend
+type SyncConversation struct {
+    RecipientID string          `json:"recipient_id,omitempty"`
+    GroupID     string          `json:"group_id,omitempty"`
+    // the messages as in message_history, newest first
+    Messages    json.RawMessage `json:"messages"`
+}
+
+// UserSearchPayload requests the users whose name starts with Query, the
+// online ones without it, and carries them in the answer
+type UserSearchPayload struct {
+    Query string            `json:"query"`
+    Users []UserSearchEntry `json:"users,omitempty"`
+}
+
+type UserSearchEntry struct {
+    ID         string `json:"id"`
+    Username   string `json:"username"`
+    Status     string `json:"status"`
+    StatusText string `json:"status_text,omitempty"`
+    IsFriend   bool   `json:"is_friend"`
+}
+
+// DeviceBundle holds the public keys of one device of a user. The keys are
+// raw X25519 and Ed25519 keys, base64 in JSON
+type DeviceBundle struct {
+    DeviceID       string `json:"device_id"`
+    IdentityKey    []byte `json:"identity_key"`
+    SigningKey     []byte `json:"signing_key"`
+    SignedPreKey   []byte `json:"signed_prekey"`
+    SignedPreKeyID uint32 `json:"signed_prekey_id"`
+    // Signature is the signature of the prekey by the signing key
+    Signature []byte `json:"signature"`
+    // a one-time prekey, handed out once by the server
+    OneTimePreKey   []byte `json:"one_time_prekey,omitempty"`
+    OneTimePreKeyID uint32 `json:"one_time_prekey_id,omitempty"`
+}
+
+type OneTimePreKey struct {
+    ID  uint32 `json:"id"`
+    Key []byte `json:"key"`
+}
+
+// KeyBundlePayload: the client publishes the bundle of its device with new
+// one-time prekeys; the server answers a request with the devices of UserID,
+// none when the user has not enabled encryption
+type KeyBundlePayload struct {
+    UserID         string          `json:"user_id,omitempty"`
+    Devices        []DeviceBundle  `json:"devices"`
+    OneTimePreKeys []OneTimePreKey `json:"one_time_prekeys,omitempty"`
+}
+
+type KeyBundleRequestPayload struct {
+    UserID string `json:"user_id"`
+}
+
+// EncryptedEnvelope is a message encrypted for one device of the recipient
+type EncryptedEnvelope struct {
+    DeviceID   string `json:"device_id"`
+    Header     []byte `json:"header"`
+    Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedMessagePayload is a message the server can't read. The sender gets
+// it back without envelopes once it is relayed or queued, except sender keys.
+//
+// A direct message has one envelope per device of RecipientID. A group
+// message has GroupID and a single envelope, with no device, encrypted with
+// the sender key of its sender. A sender key is sent to each member with
+// RecipientID, GroupID and SenderKey set, encrypted like a direct message
+type EncryptedMessagePayload struct {
+    ID           string              `json:"id,omitempty"`
+    SenderID     string              `json:"sender_id,omitempty"`
+    SenderName   string              `json:"sender_name,omitempty"`
+    SenderDevice string              `json:"sender_device"`
+    RecipientID  string              `json:"recipient_id,omitempty"`
+    GroupID      string              `json:"group_id,omitempty"`
+    SenderKey    bool                `json:"sender_key,omitempty"`
+    ClientID     string              `json:"client_id,omitempty"`
+    SentAt       int64               `json:"sent_at,omitempty"`
+    Envelopes    []EncryptedEnvelope `json:"envelopes,omitempty"`
+}
+
+// AttachmentChunkSize is the size of the chunks of the attachments, sent in
+// order one message each
+const AttachmentChunkSize = 48 << 10
+
+// AttachmentChunkPayload carries a part of a file. The uploader picks the
+// UploadID and the server answers the last chunk with the AttachmentID; a
+// download is requested with the AttachmentID only and answered in chunks
+type AttachmentChunkPayload struct {
+    UploadID     string `json:"upload_id,omitempty"`
+    AttachmentID string `json:"attachment_id,omitempty"`
+    Name         string `json:"name,omitempty"`
+    MimeType     string `json:"mime_type,omitempty"`
+    Size         int64  `json:"size,omitempty"`
+    Offset       int64  `json:"offset"`
+    Data         []byte `json:"data,omitempty"`
+    Final        bool   `json:"final,omitempty"`
+}
+
+// VoicePayload is the audio of a voice message, Waveform has one peak level
+// (0-255) per slice of the recording
+type VoicePayload struct {
+    AttachmentID string `json:"attachment_id"`
+    Duration     int64  `json:"duration_ms"`
+    Waveform     []byte `json:"waveform,omitempty"`
+}
+
+// VoiceMessagePayload sends an uploaded recording to a user, a group or the
+// global chat; it comes back as a message of that conversation with a voice
+type VoiceMessagePayload struct {
+    RecipientID string       `json:"recipient_id,omitempty"`
+    GroupID     string       `json:"group_id,omitempty"`
+    ClientID    string       `json:"client_id,omitempty"`
+    Voice       VoicePayload `json:"voice"`
+}
+
+// ReminderPayload is a reminder of the user, RemindAt in unix seconds. The
+// client sends Content and RemindAt, the server answers with the reminder
+// saved
+type ReminderPayload struct {
+    ID        string `json:"id,omitempty"`
+    Content   string `json:"content"`
+    RemindAt  int64  `json:"remind_at"`
+    CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// ReminderListPayload holds the reminders waiting, soonest first. It answers
+// a list request and a cancellation
+type ReminderListPayload struct {
+    Reminders []ReminderPayload `json:"reminders"`
+}
+
+type ReminderCancelPayload struct {
+    ID string `json:"id"`
+}
+
+// GifPayload asks the server to post the GIF found for Query, in the direct
+// conversation, the group or the global chat when both are empty
+type GifPayload struct {
+    Query       string `json:"query"`
+    RecipientID string `json:"recipient_id,omitempty"`
+    GroupID     string `json:"group_id,omitempty"`
+    ClientID    string `json:"client_id,omitempty"`
+}
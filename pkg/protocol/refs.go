@@ -0,0 +1,81 @@
+// pkg/protocol/refs.go
+package protocol
+
+import (
+    "fmt"
+    "strings"
+)
+
+// refPrefix starts the permalinks of the messages
+const refPrefix = "textual:"
+
+// MessageRef points at a message of a conversation. The conversation is
+// "global", "group:<group id>" or "direct:<user id>:<user id>" with the two
+// users sorted, the same for both of them
+type MessageRef struct {
+    Conversation string
+    MessageID    string
+}
+
+// NewMessageRef returns the reference of a message from its conversation
+func NewMessageRef(groupID, senderID, recipientID, messageID string) MessageRef {
+    conversation := "global"
+    switch {
+    case groupID != "":
+        conversation = "group:" + groupID
+    case recipientID != "":
+        a, b := senderID, recipientID
+        if b < a {
+            a, b = b, a
+        }
+        conversation = "direct:" + a + ":" + b
+    }
+    return MessageRef{Conversation: conversation, MessageID: messageID}
+}
+
+// ParseMessageRef reads a permalink written by String
+func ParseMessageRef(s string) (MessageRef, error) {
+    rest, ok := strings.CutPrefix(strings.TrimSpace(s), refPrefix)
+    i := strings.LastIndex(rest, "/")
+    if !ok || i <= 0 || i == len(rest)-1 {
+        return MessageRef{}, fmt.Errorf("invalid message link %q", s)
+    }
+    ref := MessageRef{Conversation: rest[:i], MessageID: rest[i+1:]}
+    parts := strings.Split(ref.Conversation, ":")
+    switch {
+    case ref.Conversation == "global":
+    case parts[0] == "group" && len(parts) == 2 && parts[1] != "":
+    case parts[0] == "direct" && len(parts) == 3 && parts[1] != "" && parts[2] != "":
+    default:
+        return MessageRef{}, fmt.Errorf("invalid message link %q", s)
+    }
+    return ref, nil
+}
+
+// String returns the permalink of the message
+func (r MessageRef) String() string {
+    return refPrefix + r.Conversation + "/" + r.MessageID
+}
+
+// GroupID returns the group of the message, empty outside of a group
+func (r MessageRef) GroupID() string {
+    if id, ok := strings.CutPrefix(r.Conversation, "group:"); ok {
+        return id
+    }
+    return ""
+}
+
+// Peer returns the other user of a direct conversation of userID, empty for
+// the other conversations
+func (r MessageRef) Peer(userID string) string {
+    parts := strings.Split(r.Conversation, ":")
+    switch {
+    case len(parts) != 3 || parts[0] != "direct":
+        return ""
+    case parts[1] == userID:
+        return parts[2]
+    case parts[2] == userID:
+        return parts[1]
+    }
+    return ""
+}
@@ -0,0 +1,38 @@
+// pkg/protocol/refs_test.go
+package protocol
+
+import "testing"
+
+func TestMessageRef(t *testing.T) {
+    for _, test := range []struct {
+        name, groupID, senderID, recipientID, want string
+    }{
+        {"global", "", "alice", "", "textual:global/m1"},
+        {"group", "g1", "alice", "", "textual:group:g1/m1"},
+        {"direct", "", "bob", "alice", "textual:direct:alice:bob/m1"},
+        {"direct answered", "", "alice", "bob", "textual:direct:alice:bob/m1"},
+    } {
+        ref := NewMessageRef(test.groupID, test.senderID, test.recipientID, "m1")
+        if got := ref.String(); got != test.want {
+            t.Errorf("%s: String() = %q, want %q", test.name, got, test.want)
+        }
+        parsed, err := ParseMessageRef(ref.String())
+        if err != nil || parsed != ref {
+            t.Errorf("%s: ParseMessageRef(%q) = %+v, %v", test.name, ref.String(), parsed, err)
+        }
+        if parsed.GroupID() != test.groupID {
+            t.Errorf("%s: GroupID() = %q, want %q", test.name, parsed.GroupID(), test.groupID)
+        }
+    }
+
+    ref, _ := ParseMessageRef("textual:direct:alice:bob/m1")
+    if ref.Peer("alice") != "bob" || ref.Peer("bob") != "alice" || ref.Peer("carol") != "" {
+        t.Errorf("Peer of %+v: %q, %q, %q", ref, ref.Peer("alice"), ref.Peer("bob"), ref.Peer("carol"))
+    }
+
+    for _, bad := range []string{"", "m1", "textual:", "textual:global/", "textual:/m1", "textual:group:/m1", "textual:direct:alice/m1", "textual:chat/m1"} {
+        if _, err := ParseMessageRef(bad); err == nil {
+            t.Errorf("ParseMessageRef(%q) accepted", bad)
+        }
+    }
+}